@@ -113,50 +113,58 @@ func main() {
 	fmt.Println("---------------------------")
 
 	// Define relations
-	requiresRelation, err := kmac.NewRelation("R1001", "REQUIRES", "NEED_RELATIONSHIP")
+	err = store.AddRelation("R1001", "REQUIRES", "NEED_RELATIONSHIP")
 	if err != nil {
 		log.Fatalf("Failed to create relation: %v", err)
 	}
 	fmt.Println("DEF_RELATION #R1001 [REQUIRES] type=[NEED_RELATIONSHIP]")
 
-	providesRelation, err := kmac.NewRelation("R1002", "PROVIDES", "RESOURCE_CAPABILITY")
+	err = store.AddRelation("R1002", "PROVIDES", "RESOURCE_CAPABILITY")
 	if err != nil {
 		log.Fatalf("Failed to create relation: %v", err)
 	}
 	fmt.Println("DEF_RELATION #R1002 [PROVIDES] type=[RESOURCE_CAPABILITY]")
 
-	suppliedByRelation, err := kmac.NewRelation("R1003", "SUPPLIED_BY", "RESOURCE_OWNERSHIP")
+	err = store.AddRelation("R1003", "SUPPLIED_BY", "RESOURCE_OWNERSHIP")
 	if err != nil {
 		log.Fatalf("Failed to create relation: %v", err)
 	}
 	fmt.Println("DEF_RELATION #R1003 [SUPPLIED_BY] type=[RESOURCE_OWNERSHIP]")
 
-	transportedByRelation, err := kmac.NewRelation("R1004", "TRANSPORTED_BY", "LOGISTICS_CAPABILITY")
+	err = store.AddRelation("R1004", "TRANSPORTED_BY", "LOGISTICS_CAPABILITY")
 	if err != nil {
 		log.Fatalf("Failed to create relation: %v", err)
 	}
 	fmt.Println("DEF_RELATION #R1004 [TRANSPORTED_BY] type=[LOGISTICS_CAPABILITY]")
 
-	constrainedByRelation, err := kmac.NewRelation("R1005", "CONSTRAINED_BY", "LOGISTICS_LIMITATION")
+	err = store.AddRelation("R1005", "CONSTRAINED_BY", "LOGISTICS_LIMITATION")
 	if err != nil {
 		log.Fatalf("Failed to create relation: %v", err)
 	}
 	fmt.Println("DEF_RELATION #R1005 [CONSTRAINED_BY] type=[LOGISTICS_LIMITATION]")
 
-	locatedAtRelation, err := kmac.NewRelation("R1006", "LOCATED_AT", "SPATIAL_RELATIONSHIP")
+	err = store.AddRelation("R1006", "LOCATED_AT", "SPATIAL_RELATIONSHIP")
 	if err != nil {
 		log.Fatalf("Failed to create relation: %v", err)
 	}
 	fmt.Println("DEF_RELATION #R1006 [LOCATED_AT] type=[SPATIAL_RELATIONSHIP]")
 
-	// Create assertions
+	// Create assertions, describing each from the store's labels
+	describe := func(id string) string {
+		description, err := store.DescribeAssertion(id)
+		if err != nil {
+			log.Fatalf("Failed to describe assertion: %v", err)
+		}
+		return description
+	}
+
 	// Population center has an infection outbreak
 	err = store.CreateAssertion("F1001", "E2001", "R1006", "E2002")
 	if err != nil {
 		log.Fatalf("Failed to create assertion: %v", err)
 	}
 	fmt.Println("ASSERT #F1001 subject=[#E2001] relation=[#R1006] object=[#E2002]")
-	fmt.Println("   (Urban Population Center is located at Infection Outbreak)")
+	fmt.Printf("   (%s)\n", describe("F1001"))
 
 	// Infection outbreak requires antibiotics
 	err = store.CreateAssertion("F1002", "E2002", "R1001", "E1001")
@@ -164,7 +172,7 @@ func main() {
 		log.Fatalf("Failed to create assertion: %v", err)
 	}
 	fmt.Println("ASSERT #F1002 subject=[#E2002] relation=[#R1001] object=[#E1001]")
-	fmt.Println("   (Infection Outbreak requires Antibiotic Supply)")
+	fmt.Printf("   (%s)\n", describe("F1002"))
 
 	// Antibiotics supplied by Red Cross
 	err = store.CreateAssertion("F1003", "E1001", "R1003", "E1005")
@@ -172,7 +180,7 @@ func main() {
 		log.Fatalf("Failed to create assertion: %v", err)
 	}
 	fmt.Println("ASSERT #F1003 subject=[#E1001] relation=[#R1003] object=[#E1005]")
-	fmt.Println("   (Antibiotic Supply is supplied by Red Cross)")
+	fmt.Printf("   (%s)\n", describe("F1003"))
 
 	// Antibiotics transported by helicopter
 	err = store.CreateAssertion("F1004", "E1001", "R1004", "E1004")
@@ -180,7 +188,7 @@ func main() {
 		log.Fatalf("Failed to create assertion: %v", err)
 	}
 	fmt.Println("ASSERT #F1004 subject=[#E1001] relation=[#R1004] object=[#E1004]")
-	fmt.Println("   (Antibiotic Supply is transported by Helicopter)")
+	fmt.Printf("   (%s)\n", describe("F1004"))
 
 	// Transport constrained by highway status
 	err = store.CreateAssertion("F1005", "E1004", "R1005", "E3001")
@@ -188,7 +196,7 @@ func main() {
 		log.Fatalf("Failed to create assertion: %v", err)
 	}
 	fmt.Println("ASSERT #F1005 subject=[#E1004] relation=[#R1005] object=[#E3001]")
-	fmt.Println("   (Helicopter transport is constrained by Highway Status)")
+	fmt.Printf("   (%s)\n", describe("F1005"))
 
 	// Population center has drinking water need
 	err = store.CreateAssertion("F1006", "E2001", "R1006", "E2003")
@@ -196,7 +204,7 @@ func main() {
 		log.Fatalf("Failed to create assertion: %v", err)
 	}
 	fmt.Println("ASSERT #F1006 subject=[#E2001] relation=[#R1006] object=[#E2003]")
-	fmt.Println("   (Urban Population Center is located at Drinking Water Need)")
+	fmt.Printf("   (%s)\n", describe("F1006"))
 
 	// Water purifier provides drinking water
 	err = store.CreateAssertion("F1007", "E1003", "R1002", "E2003")
@@ -204,7 +212,7 @@ func main() {
 		log.Fatalf("Failed to create assertion: %v", err)
 	}
 	fmt.Println("ASSERT #F1007 subject=[#E1003] relation=[#R1002] object=[#E2003]")
-	fmt.Println("   (Water Purifier provides Drinking Water Need)")
+	fmt.Printf("   (%s)\n", describe("F1007"))
 
 	// 5. Add Temporal Information
 	fmt.Println("\n5. Temporal Information:")
@@ -218,6 +226,38 @@ func main() {
 	fmt.Println("TEMPORAL #F1001 state=[POINT_IN_TIME] timestamp=[#T1001]")
 	fmt.Println("   (Linking assertion to current time)")
 
+	// Record how infrastructure conditions change as repairs progress
+	morning := time.Date(2025, 5, 19, 8, 0, 0, 0, time.UTC)
+	states := []struct {
+		id        string
+		entity    string
+		attribute string
+		value     string
+		at        time.Time
+	}{
+		{"F3001", "E3001", "passable", "30%", morning},
+		{"F3002", "E3001", "capacity_tons", "12", morning},
+		{"F3003", "E3002", "coverage", "25%", morning},
+		{"F3004", "E3001", "passable", "60%", morning.Add(6 * time.Hour)},
+	}
+	for _, st := range states {
+		state, err := kmac.NewStateAssertion(st.id, st.entity, st.attribute, st.value, st.at)
+		if err != nil {
+			log.Fatalf("Failed to create state assertion: %v", err)
+		}
+		if err := store.AddStateAssertion(state); err != nil {
+			log.Fatalf("Failed to add state assertion: %v", err)
+		}
+		fmt.Println(state.String())
+	}
+
+	if current, ok := store.CurrentState("E3001", "passable"); ok {
+		fmt.Printf("   (Highway currently passable: %s)\n", current.Value())
+	}
+	if earlier, ok := store.StateAt("E3001", "passable", morning.Add(time.Hour)); ok {
+		fmt.Printf("   (Highway passable at 09:00: %s)\n", earlier.Value())
+	}
+
 	// 6. Semantic Analysis
 	fmt.Println("\n6. Semantic Analysis Examples:")
 	fmt.Println("-----------------------------")
@@ -264,16 +304,61 @@ func main() {
 	fmt.Println("\n8. Coordination Planning:")
 	fmt.Println("------------------------")
 
+	// Build the response plan as tasks with estimated durations and dependencies
+	plan, err := kmac.NewPlan("L1001", "Urban_Response_Plan")
+	if err != nil {
+		log.Fatalf("Failed to create plan: %v", err)
+	}
+	planStatements := kmac.NewStatementCollection()
+	planStatements.Add(plan)
+
+	tasks := []struct {
+		id       string
+		label    string
+		duration time.Duration
+	}{
+		{"K1001", "Load_Antibiotics_On_Helicopter", 15 * time.Minute},
+		{"K1002", "Fly_Antibiotics_To_Urban_Center", 45 * time.Minute},
+		{"K1003", "Distribute_Antibiotics", time.Hour},
+		{"K1004", "Transport_Water_Purifier_By_Road", 90 * time.Minute},
+		{"K1005", "Set_Up_Water_Purifier", 2 * time.Hour},
+	}
+	for _, tk := range tasks {
+		task, err := kmac.NewTask(tk.id, tk.label, plan.ID())
+		if err != nil {
+			log.Fatalf("Failed to create task: %v", err)
+		}
+		task.SetDuration(tk.duration)
+		planStatements.Add(task)
+	}
+
+	dependencies := [][2]string{
+		{"K1002", "K1001"}, // Fly after loading
+		{"K1003", "K1002"}, // Distribute after arrival
+		{"K1005", "K1004"}, // Set up after delivery
+	}
+	for _, dp := range dependencies {
+		dependency, err := kmac.NewDependency(dp[0], dp[1])
+		if err != nil {
+			log.Fatalf("Failed to create dependency: %v", err)
+		}
+		planStatements.Add(dependency)
+	}
+
+	schedule, err := planStatements.SchedulePlan(plan.ID())
+	if err != nil {
+		log.Fatalf("Failed to schedule plan: %v", err)
+	}
+
 	fmt.Println("\nOptimized Resource Allocation Plan:")
-	fmt.Println("1. Deploy Antibiotic Supply to Urban Population Center via Helicopter")
-	fmt.Println("   Priority: High (P12 casualties)")
-	fmt.Println("   Constraints: Highway Status Passable 30%")
-	fmt.Println("   Estimated Time: 45 minutes")
-
-	fmt.Println("\n2. Deploy Water Purifier to Urban Population Center via Ground Transport")
-	fmt.Println("   Priority: Medium")
-	fmt.Println("   Setup Time: 2 hours")
-	fmt.Println("   Estimated Operations: 10 hours daily to meet needs")
+	for i, scheduled := range schedule.Tasks {
+		fmt.Printf("%d. %s (start +%s, finish +%s)\n", i+1, scheduled.Task.Label(), scheduled.Start, scheduled.Finish)
+	}
+
+	fmt.Printf("\nCritical Path (%s):\n", schedule.Duration)
+	for _, task := range schedule.CriticalPath {
+		fmt.Printf("- %s\n", task.Label())
+	}
 
 	fmt.Println("\nThis plan was automatically generated based on the TOSID-KMAC semantic representation")
 	fmt.Println("of resources, needs, and constraints, enabling efficient cross-organizational coordination.")
@@ -237,7 +237,11 @@ func main() {
 	disassembler.RegisterEvent(trappist1eDiscovery)
 
 	// Create time references
-	prehistoricTime, err := kmac.NewTimeReference("T1001", "TIMESTAMP", prehistoricDate)
+	prehistoricDate, err := kmac.ParseApproximateTime("before 10000 BCE")
+	if err != nil {
+		log.Fatalf("Failed to parse approximate time: %v", err)
+	}
+	prehistoricTime, err := kmac.NewApproximateTimeReference("T1001", "TIMESTAMP", prehistoricDate)
 	if err != nil {
 		log.Fatalf("Failed to create time reference: %v", err)
 	}
@@ -323,7 +327,6 @@ func main() {
 
 // Helper variables for dates
 var (
-	prehistoricDate        = time.Date(1000, 1, 1, 0, 0, 0, 0, time.UTC)
 	kepler186fDiscoveryDate = time.Date(2014, 4, 17, 0, 0, 0, 0, time.UTC)
 	trappist1eDiscoveryDate = time.Date(2017, 2, 22, 0, 0, 0, 0, time.UTC)
 )
@@ -184,10 +184,7 @@ func createApollo11Example() {
 		object  string
 	}{
 		{"F1001", "E1001", "R1001", "E1002"}, // NASA operates Apollo 11
-		{"F1003", "V1001", "AGENT", "E1002"}, // Landing was done by Apollo 11
 		{"F1004", "V1001", "R1002", "E1003"}, // Landing destination was lunar surface
-		{"F1007", "V1002", "AGENT", "E1004"}, // First steps were by mankind
-		{"F1008", "V1002", "LOCATION", "E1005"}, // First steps were on the moon
 		{"F1009", "V1002", "R1003", "V1001"}, // First steps achieved by landing
 	}
 	
@@ -198,11 +195,28 @@ func createApollo11Example() {
 		fmt.Println(assertion.ConfidenceString())
 	}
 	
+	// Describe who and what took part in each event
+	participants := []struct {
+		event  string
+		role   string
+		entity string
+	}{
+		{"V1001", kmac.RoleAgent, "E1002"},    // Landing was done by Apollo 11
+		{"V1001", kmac.RoleLocation, "E1003"}, // Landing was on the lunar surface
+		{"V1002", kmac.RoleAgent, "E1004"},    // First steps were by mankind
+		{"V1002", kmac.RoleLocation, "E1005"}, // First steps were on the moon
+	}
+	
+	for _, p := range participants {
+		participation, _ := kmac.NewParticipation(p.event, p.role, p.entity)
+		fmt.Println(participation.String())
+	}
+	
 	// Create a time reference
 	fmt.Println("DEF_TIME #T1001 type=[TIMESTAMP] value=[1969-07-20T20:17:40Z]")
 	
 	// Create temporal qualification
-	fmt.Println("TEMPORAL #F1003 state=[POINT_IN_TIME] timestamp=[#T1001]")
+	fmt.Println("TEMPORAL #F1004 state=[POINT_IN_TIME] timestamp=[#T1001]")
 	
 	// Create a part-of relationship
 	fmt.Println("PART_OF #E1003 whole=[#E1005]")
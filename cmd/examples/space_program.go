@@ -73,35 +73,35 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to create assertion: %v", err)
 	}
-	fmt.Println("Ion Propulsion J09 powers Jupiter Orbital Base X47")
+	printAssertion(store, "F1001")
 
 	// Entry vehicle uses the propulsion system
 	err = store.CreateAssertion("F1002", "E1002", "USES", "E1003")
 	if err != nil {
 		log.Fatalf("Failed to create assertion: %v", err)
 	}
-	fmt.Println("Atmospheric Entry D15 uses Ion Propulsion J09")
+	printAssertion(store, "F1002")
 
 	// Platform supports energy extraction
 	err = store.CreateAssertion("F1003", "E1004", "SUPPORTS", "E1005")
 	if err != nil {
 		log.Fatalf("Failed to create assertion: %v", err)
 	}
-	fmt.Println("Aerostatic Platform J31 supports Energy Extraction J27")
+	printAssertion(store, "F1003")
 
 	// Phase relationships
 	err = store.CreateAssertion("F1004", "E2001", "PRECEDES", "E2002")
 	if err != nil {
 		log.Fatalf("Failed to create assertion: %v", err)
 	}
-	fmt.Println("Exploration Phase precedes Development Phase")
+	printAssertion(store, "F1004")
 
 	// Component development during phases
 	err = store.CreateAssertion("F1005", "E1001", "DEVELOPED_DURING", "E2002")
 	if err != nil {
 		log.Fatalf("Failed to create assertion: %v", err)
 	}
-	fmt.Println("Jupiter Orbital Base developed during Development Phase")
+	printAssertion(store, "F1005")
 
 	// Query the semantic store
 	fmt.Println("\n4. Semantic Queries:")
@@ -156,4 +156,12 @@ func main() {
 	fmt.Println("\nSpace program management example completed successfully!")
 	fmt.Println("This demonstrates how TOSID and KMAC can manage complex,")
 	fmt.Println("multi-decade programs with precise semantic relationships.")
-}
\ No newline at end of file
+}
+// printAssertion prints an assertion from the store as a sentence
+func printAssertion(store *semantic.SemanticStore, id string) {
+	description, err := store.DescribeAssertion(id)
+	if err != nil {
+		log.Fatalf("Failed to describe assertion: %v", err)
+	}
+	fmt.Println(description)
+}
@@ -0,0 +1,782 @@
+// Command kmac works with KMAC knowledge files.
+//
+// Usage:
+//
+//	kmac <command> [flags] [file.kmac ...]
+//
+// Files are read as KMAC text; with no files, standard input is read.
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ha1tch/tosid-go/pkg/convert"
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+	"github.com/ha1tch/tosid-go/pkg/lint"
+	"github.com/ha1tch/tosid-go/pkg/replica"
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+	"github.com/ha1tch/tosid-go/pkg/server"
+	"github.com/ha1tch/tosid-go/pkg/shapes"
+)
+
+// command is a kmac subcommand. run returns the process exit code.
+type command struct {
+	summary string
+	run     func(args []string) int
+}
+
+var commands = map[string]command{
+	"naming-report":     {"report entities whose labels break a naming policy", runNamingReport},
+	"confidence-report": {"list assertions by confidence and flag the least certain", runConfidenceReport},
+	"serve":             {"serve a store loaded from KMAC files over HTTP", runServe},
+	"redact":            {"write KMAC files with classified entities stripped or masked", runRedact},
+	"merge":             {"three-way merge KMAC files, marking conflicts", runMerge},
+	"fmt":               {"rewrite KMAC files in the canonical format", runFmt},
+	"init":              {"write a starter KMAC file for a domain from a template", runInit},
+	"lint":              {"check KMAC files for unused relations, unsourced doubts, and more", runLint},
+	"generate":          {"write typed Go accessors for the shapes in a shapes file", runGenerate},
+	"convert":           {"convert KMAC statements between kmac-text, jsonld, rdf, protobuf, and csv", runConvert},
+	"query":             {"run a saved query, such as medical_needs_in(E2001), against KMAC files", runQuery},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "kmac: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	os.Exit(cmd.run(os.Args[2:]))
+}
+
+// usage lists the available commands
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: kmac <command> [flags] [file.kmac ...]")
+	fmt.Fprintln(os.Stderr, "commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", name, commands[name].summary)
+	}
+}
+
+// runNamingReport checks a store against a naming policy file. It exits with
+// 1 if any entity breaks the policy.
+func runNamingReport(args []string) int {
+	flags := flag.NewFlagSet("naming-report", flag.ExitOnError)
+	policyPath := flags.String("policy", "", "naming policy file (JSON)")
+	flags.Parse(args)
+
+	if *policyPath == "" {
+		fmt.Fprintln(os.Stderr, "kmac naming-report: -policy is required")
+		return 2
+	}
+	policyFile, err := os.Open(*policyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kmac naming-report: %v\n", err)
+		return 2
+	}
+	defer policyFile.Close()
+	policy, err := semantic.LoadNamingPolicy(policyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kmac naming-report: %v\n", err)
+		return 2
+	}
+
+	store, err := loadStore(flags.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kmac naming-report: %v\n", err)
+		return 2
+	}
+	report, err := store.CheckNaming(policy)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kmac naming-report: %v\n", err)
+		return 2
+	}
+
+	for _, line := range report {
+		fmt.Println(line)
+	}
+	fmt.Printf("%d entities checked, %d violations\n", store.GetStatistics()["entities"], len(report))
+	if len(report) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runConfidenceReport prints a confidence heatmap of the assertions in KMAC
+// files. It exits with 1 if any assertion is under the threshold.
+func runConfidenceReport(args []string) int {
+	flags := flag.NewFlagSet("confidence-report", flag.ExitOnError)
+	threshold := flags.Float64("threshold", 0.7, "flag assertions with confidence under this level")
+	themeName := flags.String("theme", "confidence", "color theme: default, domain, or confidence")
+	color := flags.String("color", "auto", "color output: auto, always, or never")
+	sourcesPath := flags.String("sources", "", "JSON file of confidence source weights, added to the defaults")
+	flags.Parse(args)
+
+	theme, ok := kmac.LookupTheme(*themeName)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "kmac confidence-report: unknown theme %q\n", *themeName)
+		return 2
+	}
+	sources := kmac.DefaultSources()
+	if *sourcesPath != "" {
+		file, err := os.Open(*sourcesPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "kmac confidence-report: %v\n", err)
+			return 2
+		}
+		err = sources.Load(file)
+		file.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "kmac confidence-report: %v\n", err)
+			return 2
+		}
+	}
+	statements, err := loadStatements(flags.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kmac confidence-report: %v\n", err)
+		return 2
+	}
+
+	disassembler := kmac.NewDisassembler(os.Stdout)
+	disassembler.SetTheme(theme)
+	disassembler.SetSources(sources)
+	switch *color {
+	case "always":
+		disassembler.SetColorEnabled(true)
+	case "never":
+		disassembler.SetColorEnabled(false)
+	case "auto":
+	default:
+		fmt.Fprintf(os.Stderr, "kmac confidence-report: invalid -color %q\n", *color)
+		return 2
+	}
+	disassembler.RegisterStatements(statements)
+	disassembler.DisassembleConfidence(*threshold)
+
+	if len(disassembler.ConfidenceReport(*threshold).LowConfidence()) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runServe loads KMAC files into a store and serves it over HTTP until the
+// server fails. As a mirror, it serves read-only and reloads the store from
+// the files, or from the -source server, every refresh interval.
+func runServe(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", ":8080", "address to listen on")
+	retentionPath := flags.String("retention", "", "purge expired assertions under the retention policy `file`")
+	purgeInterval := flags.Duration("purge-interval", time.Hour, "how often to purge expired assertions")
+	feedSize := flags.Int("change-feed", 0, "keep the last `n` changes at /changes for followers; 0 disables the feed")
+	queriesPath := flags.String("queries", "", "serve the saved queries in `file` (JSON) at /queries")
+	rate := flags.Float64("rate", 0, "allow each client `n` requests per second; 0 for no limit")
+	burst := flags.Int("burst", 10, "allow each client `n` requests at once above the rate")
+	maxBody := flags.Int64("max-body", 1<<20, "reject request bodies over `n` bytes; 0 for no limit")
+	mirror := flags.Bool("mirror", false, "serve read-only, reloading the store every -refresh")
+	source := flags.String("source", "", "mirror the server at `url` rather than the files")
+	refreshInterval := flags.Duration("refresh", 5*time.Minute, "how often a mirror reloads its store")
+	flags.Parse(args)
+
+	if *source != "" && !*mirror {
+		fmt.Fprintln(os.Stderr, "kmac serve: -source needs -mirror")
+		return 2
+	}
+	if *mirror && *retentionPath != "" {
+		fmt.Fprintln(os.Stderr, "kmac serve: a mirror is never purged; apply -retention where it is written")
+		return 2
+	}
+	if *mirror && *source == "" && flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "kmac serve: a mirror needs files or -source to reload from")
+		return 2
+	}
+	var client *replica.Client
+	if *source != "" {
+		var err error
+		if client, err = replica.NewClient(*source, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "kmac serve: %v\n", err)
+			return 2
+		}
+	}
+	load := func(ctx context.Context) (*semantic.SemanticStore, error) {
+		var store *semantic.SemanticStore
+		var err error
+		if client != nil {
+			store, err = client.Export(ctx)
+		} else {
+			store, err = loadStore(flags.Args())
+		}
+		if err == nil && *queriesPath != "" {
+			err = registerSavedQueries(store, *queriesPath)
+		}
+		return store, err
+	}
+
+	store, err := load(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kmac serve: %v\n", err)
+		return 2
+	}
+	if *feedSize > 0 {
+		store.EnableChangeFeed(*feedSize)
+	}
+	var srv *server.Server
+	if *mirror {
+		srv = server.NewMirror(store)
+		go srv.RunMirror(context.Background(), *refreshInterval, load, func(err error) {
+			fmt.Fprintf(os.Stderr, "kmac serve: refresh: %v\n", err)
+		})
+	} else {
+		srv = server.New(store)
+	}
+	srv.SetQuotas(server.QuotaOptions{Rate: *rate, Burst: *burst, MaxBodyBytes: *maxBody})
+	if *retentionPath != "" {
+		policy, err := loadRetentionPolicy(*retentionPath)
+		if err == nil {
+			err = store.SetRetentionPolicy(policy)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "kmac serve: %v\n", err)
+			return 2
+		}
+		go srv.RunRetention(context.Background(), *purgeInterval, func(report *semantic.RetentionReport, err error) {
+			for _, line := range report.Lines() {
+				fmt.Fprintf(os.Stderr, "kmac serve: retention: %s\n", line)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "kmac serve: retention: %v\n", err)
+			}
+		})
+	}
+	fmt.Fprintf(os.Stderr, "kmac serve: listening on %s\n", *addr)
+	if err := http.ListenAndServe(*addr, srv); err != nil {
+		fmt.Fprintf(os.Stderr, "kmac serve: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runRedact writes the statements of KMAC files to standard output with the
+// entities and assertions matching TOSID patterns or tags stripped or masked,
+// and lists what was left out on standard error
+func runRedact(args []string) int {
+	flags := flag.NewFlagSet("redact", flag.ExitOnError)
+	var patterns, tags, maskPatterns, maskTags stringList
+	flags.Var(&patterns, "pattern", "strip entities whose TOSID matches `pattern` (repeatable)")
+	flags.Var(&tags, "tag", "strip entities and assertions with `tag` (repeatable)")
+	flags.Var(&maskPatterns, "mask-pattern", "mask entities whose TOSID matches `pattern` (repeatable)")
+	flags.Var(&maskTags, "mask-tag", "mask entities with `tag` (repeatable)")
+	maskLabel := flags.String("mask-label", semantic.DefaultMaskLabel, "label of masked entities")
+	flags.Parse(args)
+
+	filter := &semantic.RedactionFilter{MaskLabel: *maskLabel}
+	for _, pattern := range patterns {
+		filter.Rules = append(filter.Rules, semantic.RedactionRule{TOSIDPattern: pattern, Action: semantic.RedactStrip})
+	}
+	for _, tag := range tags {
+		filter.Rules = append(filter.Rules, semantic.RedactionRule{Tag: tag, Action: semantic.RedactStrip})
+	}
+	for _, pattern := range maskPatterns {
+		filter.Rules = append(filter.Rules, semantic.RedactionRule{TOSIDPattern: pattern, Action: semantic.RedactMask})
+	}
+	for _, tag := range maskTags {
+		filter.Rules = append(filter.Rules, semantic.RedactionRule{Tag: tag, Action: semantic.RedactMask})
+	}
+	if len(filter.Rules) == 0 {
+		fmt.Fprintln(os.Stderr, "kmac redact: no -pattern, -tag, -mask-pattern, or -mask-tag given")
+		return 2
+	}
+
+	store, err := loadStore(flags.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kmac redact: %v\n", err)
+		return 2
+	}
+	report, err := store.WriteRedactedKMAC(os.Stdout, filter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kmac redact: %v\n", err)
+		return 1
+	}
+	for _, id := range report.Stripped {
+		fmt.Fprintf(os.Stderr, "stripped %s\n", id)
+	}
+	for _, id := range report.Masked {
+		fmt.Fprintf(os.Stderr, "masked %s\n", id)
+	}
+	return 0
+}
+
+// runMerge merges the changes made to a base KMAC file in two copies of it,
+// writing the result to standard output or the -o file. It exits with 1 if
+// conflicts are left to resolve, so it can serve as a git merge driver:
+//
+//	[merge "kmac"]
+//		driver = kmac merge -o %A %O %A %B
+func runMerge(args []string) int {
+	flags := flag.NewFlagSet("merge", flag.ExitOnError)
+	output := flags.String("o", "", "write the merge to `file` instead of standard output")
+	flags.Parse(args)
+	if flags.NArg() != 3 {
+		fmt.Fprintln(os.Stderr, "usage: kmac merge [-o file] base.kmac ours.kmac theirs.kmac")
+		return 2
+	}
+
+	var versions [3][]kmac.Statement
+	for i, path := range flags.Args() {
+		statements, err := loadStatements([]string{path})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "kmac merge: %v\n", err)
+			return 2
+		}
+		versions[i] = statements
+	}
+	result := kmac.Merge(versions[0], versions[1], versions[2])
+
+	// The output may be one of the inputs, which have all been read by now
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "kmac merge: %v\n", err)
+			return 2
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := result.Encode(w); err != nil {
+		fmt.Fprintf(os.Stderr, "kmac merge: %v\n", err)
+		return 2
+	}
+	for _, conflict := range result.Conflicts {
+		fmt.Fprintf(os.Stderr, "conflict %s: %s\n", conflict.Key, conflict.Reason)
+	}
+	if result.HasConflicts() {
+		return 1
+	}
+	return 0
+}
+
+// runFmt rewrites KMAC files in the canonical format, printing the result
+// to standard output unless -w or -l is given. With no files, standard input
+// is formatted. With -l it exits with 1 if any file is not canonical.
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write the result to the file instead of standard output")
+	list := flags.Bool("l", false, "list files whose formatting differs from the canonical format")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err == nil {
+			src, err = kmac.FormatCanonical(src)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "kmac fmt: %v\n", err)
+			return 2
+		}
+		os.Stdout.Write(src)
+		return 0
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "kmac fmt: %v\n", err)
+			return 2
+		}
+		formatted, err := kmac.FormatCanonical(src)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "kmac fmt: %s: %v\n", path, err)
+			return 2
+		}
+		changed := !bytes.Equal(src, formatted)
+		if *list && changed {
+			fmt.Println(path)
+			status = 1
+		}
+		if *write && changed {
+			if err := os.WriteFile(path, formatted, 0o644); err != nil {
+				fmt.Fprintf(os.Stderr, "kmac fmt: %v\n", err)
+				return 2
+			}
+		}
+		if !*write && !*list {
+			os.Stdout.Write(formatted)
+		}
+	}
+	return status
+}
+
+// runInit writes a starter KMAC file from a template, to standard output
+// unless -o is given. It will not overwrite an existing file.
+func runInit(args []string) int {
+	flags := flag.NewFlagSet("init", flag.ExitOnError)
+	name := flags.String("template", "", "domain template: "+strings.Join(templateNames(), ", "))
+	output := flags.String("o", "", "write the file here instead of standard output")
+	flags.Parse(args)
+
+	tmpl, ok := templates[*name]
+	if !ok {
+		if *name == "" {
+			fmt.Fprintln(os.Stderr, "kmac init: -template is required")
+		} else {
+			fmt.Fprintf(os.Stderr, "kmac init: unknown template %q\n", *name)
+		}
+		fmt.Fprintln(os.Stderr, "templates:")
+		for _, name := range templateNames() {
+			fmt.Fprintf(os.Stderr, "  %-18s %s\n", name, templates[name].summary)
+		}
+		return 2
+	}
+
+	if *output == "" {
+		fmt.Print(tmpl.text)
+		return 0
+	}
+	file, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kmac init: %v\n", err)
+		return 2
+	}
+	_, err = io.WriteString(file, tmpl.text)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kmac init: %v\n", err)
+		return 2
+	}
+	return 0
+}
+
+// templateNames returns the names of the init templates, in order
+func templateNames() []string {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runLint checks KMAC files against the lint rules, at the severities of
+// a lint config file if one is given. It exits with 1 if any issue is an
+// error.
+func runLint(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	configPath := flags.String("config", "", "lint config file (JSON) setting rule severities")
+	flags.Parse(args)
+
+	var config *lint.Config
+	if *configPath != "" {
+		configFile, err := os.Open(*configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "kmac lint: %v\n", err)
+			return 2
+		}
+		config, err = lint.LoadConfig(configFile)
+		configFile.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "kmac lint: %v\n", err)
+			return 2
+		}
+	}
+
+	statements, err := loadStatements(flags.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kmac lint: %v\n", err)
+		return 2
+	}
+	errors := 0
+	issues := lint.Lint(statements, config)
+	for _, issue := range issues {
+		fmt.Println(issue)
+		if issue.Severity == lint.SeverityError {
+			errors++
+		}
+	}
+	fmt.Printf("%d statements checked, %d issues, %d errors\n", len(statements), len(issues), errors)
+	if errors > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runGenerate writes Go source giving typed access to the entities of each
+// shape in a shapes file
+func runGenerate(args []string) int {
+	flags := flag.NewFlagSet("generate", flag.ExitOnError)
+	shapesPath := flags.String("shapes", "", "shapes file (JSON) declaring the entity classes")
+	packageName := flags.String("package", "model", "package of the generated code")
+	output := flags.String("o", "", "write the code here instead of standard output")
+	flags.Parse(args)
+
+	if *shapesPath == "" {
+		fmt.Fprintln(os.Stderr, "kmac generate: -shapes is required")
+		return 2
+	}
+	shapesFile, err := os.Open(*shapesPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kmac generate: %v\n", err)
+		return 2
+	}
+	set, err := shapes.Load(shapesFile)
+	shapesFile.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kmac generate: %v\n", err)
+		return 2
+	}
+
+	var code bytes.Buffer
+	if err := set.Generate(&code, *packageName); err != nil {
+		fmt.Fprintf(os.Stderr, "kmac generate: %v\n", err)
+		return 2
+	}
+	if *output == "" {
+		os.Stdout.Write(code.Bytes())
+		return 0
+	}
+	if err := os.WriteFile(*output, code.Bytes(), 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "kmac generate: %v\n", err)
+		return 2
+	}
+	return 0
+}
+
+// runConvert streams statements from files or standard input in one format
+// to standard output in another. Each statement is validated on the way
+// through unless -no-validate is given, and the first invalid one stops the
+// conversion with exit code 1. Compressed inputs are decompressed as they
+// are read, and -compress compresses the output.
+func runConvert(args []string) int {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	formats := strings.Join(convert.Formats(), ", ")
+	from := flags.String("from", convert.KMACText, "input format: "+formats)
+	to := flags.String("to", "", "output format: "+formats)
+	noValidate := flags.Bool("no-validate", false, "convert statements without validating them")
+	compress := flags.String("compress", "", "compress the output: gzip, or zstd if a codec is registered")
+	flags.Parse(args)
+
+	if *to == "" {
+		fmt.Fprintln(os.Stderr, "kmac convert: -to is required")
+		return 2
+	}
+	if _, err := convert.NewReader(*from, nil); err != nil {
+		fmt.Fprintf(os.Stderr, "kmac convert: %v\n", err)
+		return 2
+	}
+	output, err := kmac.NewCompressWriter(os.Stdout, kmac.Compression(*compress))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kmac convert: %v\n", err)
+		return 2
+	}
+	w, err := convert.NewWriter(*to, output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kmac convert: %v\n", err)
+		return 2
+	}
+
+	// One decoder across inputs, so statements may qualify those of earlier files
+	var decoder *kmac.LineDecoder
+	if !*noValidate {
+		decoder = kmac.NewTextSerializer().NewLineDecoder()
+	}
+	source := "stdin"
+	if flags.NArg() == 0 {
+		var input io.ReadCloser
+		if input, err = kmac.NewDecompressReader(os.Stdin); err == nil {
+			r, _ := convert.NewReader(*from, input)
+			_, err = convert.Convert(w, r, decoder)
+		}
+	}
+	for _, path := range flags.Args() {
+		source = path
+		file, openErr := os.Open(path)
+		if openErr != nil {
+			err = openErr
+			break
+		}
+		var input io.ReadCloser
+		if input, err = kmac.NewDecompressReader(file); err == nil {
+			r, _ := convert.NewReader(*from, input)
+			_, err = convert.Convert(w, r, decoder)
+		}
+		file.Close()
+		if err != nil {
+			break
+		}
+	}
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := output.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kmac convert: %s: %v\n", source, err)
+		var validationErr *convert.ValidationError
+		if errors.As(err, &validationErr) {
+			return 1
+		}
+		return 2
+	}
+	return 0
+}
+
+// runQuery runs a saved query from a queries file against KMAC files and
+// lists the entities it selects, a line each. With -list it lists the
+// queries instead.
+func runQuery(args []string) int {
+	flags := flag.NewFlagSet("query", flag.ExitOnError)
+	queriesPath := flags.String("queries", "", "saved queries file (JSON)")
+	list := flags.Bool("list", false, "list the saved queries instead of running one")
+	order := flags.String("order", "", "order entities by id, label, or tosid")
+	flags.Parse(args)
+
+	if *queriesPath == "" {
+		fmt.Fprintln(os.Stderr, "kmac query: -queries is required")
+		return 2
+	}
+	if *list {
+		store := semantic.NewSemanticStore()
+		if err := registerSavedQueries(store, *queriesPath); err != nil {
+			fmt.Fprintf(os.Stderr, "kmac query: %v\n", err)
+			return 2
+		}
+		for _, query := range store.SavedQueries() {
+			fmt.Printf("%s(%s)\t%s\n", query.Name, strings.Join(query.Params, ", "), query.Description)
+		}
+		return 0
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: kmac query -queries file 'name(args)' [file.kmac ...]")
+		return 2
+	}
+
+	store, err := loadStore(flags.Args()[1:])
+	if err == nil {
+		err = registerSavedQueries(store, *queriesPath)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kmac query: %v\n", err)
+		return 2
+	}
+	page, err := store.CallQuery(flags.Arg(0), semantic.PageRequest{Order: *order})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kmac query: %v\n", err)
+		return 2
+	}
+	for _, entityRef := range page.Items {
+		entity := entityRef.KMACEntity
+		fmt.Printf("%s\t%s\t%s\n", entity.ID(), entity.Label(), entity.TOSIDType())
+	}
+	return 0
+}
+
+// registerSavedQueries registers the queries of a saved queries file with a store
+func registerSavedQueries(store *semantic.SemanticStore, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	queries, err := semantic.LoadSavedQueries(file)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	for _, query := range queries {
+		if err := store.RegisterQuery(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stringList is a flag that may be given more than once
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// loadRetentionPolicy reads a retention policy file
+func loadRetentionPolicy(path string) (*semantic.RetentionPolicy, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return semantic.LoadRetentionPolicy(file)
+}
+
+// loadStatements decodes KMAC files, or standard input if none are given
+func loadStatements(paths []string) ([]kmac.Statement, error) {
+	serializer := kmac.NewTextSerializer()
+	if len(paths) == 0 {
+		return serializer.Decode(os.Stdin)
+	}
+
+	var statements []kmac.Statement
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		decoded, err := serializer.Decode(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		statements = append(statements, decoded...)
+	}
+	return statements, nil
+}
+
+// loadStore reads KMAC files, or standard input if none are given, into a new store
+func loadStore(paths []string) (*semantic.SemanticStore, error) {
+	store := semantic.NewSemanticStore()
+	if len(paths) == 0 {
+		return store, store.LoadKMAC(os.Stdin)
+	}
+
+	readers := make([]io.Reader, 0, len(paths))
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		// Each file is decompressed on its own, as they may differ
+		contents, err := kmac.NewDecompressReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		// Separate files so a missing final newline cannot join two lines
+		readers = append(readers, contents, strings.NewReader("\n"))
+	}
+	return store, store.LoadKMAC(io.MultiReader(readers...))
+}
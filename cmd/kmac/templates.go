@@ -0,0 +1,92 @@
+package main
+
+// template is a starter KMAC file for a domain, written by kmac init
+type template struct {
+	summary string
+	text    string
+}
+
+// templates are the domains kmac init can scaffold. Relation and property
+// domains and ranges are TOSID prefixes; the TOSID categories a domain
+// uses are listed in comments, with examples, so entities can be added
+// under them.
+var templates = map[string]template{
+	"disaster-response": {"resources, needs, and logistics for relief operations", disasterResponseTemplate},
+	"space-program":     {"spacecraft, subsystems, and program phases", spaceProgramTemplate},
+	"astronomy":         {"stars, planets, orbits, and observations", astronomyTemplate},
+}
+
+const disasterResponseTemplate = `# Disaster response knowledge base
+#
+# TOSID categories:
+#   10C5-MED-SUP  medical supplies      10C5-MED-SUP-ANB:PNC-AMP-500
+#   10B3-WAT-PUR  water purification    10B3-WAT-PUR-RO5:CAP-500-LTR
+#   10B3-TRN      transport             10B3-TRN-AIR-HEL:CAP-12P-S33
+#   10B2-INF      infrastructure        10B2-INF-RD-HWY:STA-P30-C12
+#   11B1-ORG      organizations         11B1-ORG-NGO-RCR:USA-DIS-RES
+#   11B1-POP      affected populations  11B1-POP-DIS-A13:SIZ-25K-URB
+#   11B1-NED      needs                 11B1-NED-WAT-DRK:VOL-50K-L24
+#   11B3-MED      medical situations    11B3-MED-INF-R08:CAS-120-P12
+
+DEF_RELATION #R1001 [REQUIRES] type=[NEED_RELATIONSHIP] domain=[11B] range=[1]
+DEF_RELATION #R1002 [PROVIDES] type=[RESOURCE_CAPABILITY] domain=[1] range=[11B1-NED]
+DEF_RELATION #R1003 [SUPPLIED_BY] type=[RESOURCE_OWNERSHIP] domain=[10] range=[11B1-ORG]
+DEF_RELATION #R1004 [TRANSPORTED_BY] type=[LOGISTICS_CAPABILITY] domain=[10] range=[10B3-TRN]
+DEF_RELATION #R1005 [CONSTRAINED_BY] type=[LOGISTICS_LIMITATION] domain=[10B3-TRN] range=[10B2-INF]
+DEF_RELATION #R1006 [LOCATED_AT] type=[SPATIAL_RELATIONSHIP] range=[1]
+
+DEF_PROPERTY #P1001 [quantity] type=[QUANTITY] domain=[10] functional=[true]
+DEF_PROPERTY #P1002 [capacity] type=[QUANTITY] domain=[10B] functional=[true]
+DEF_PROPERTY #P1003 [population] type=[QUANTITY] domain=[11B1-POP] functional=[true]
+DEF_PROPERTY #P1004 [status] type=[STATUS] domain=[10B2-INF] functional=[true]
+DEF_PROPERTY #P1005 [priority] type=[RANK] domain=[11B1-NED] functional=[true]
+`
+
+const spaceProgramTemplate = `# Space program knowledge base
+#
+# TOSID categories:
+#   10C1-ORG      agencies and contractors  10C1-ORG-GOV-USA:NASA
+#   10B2-SPC      spacecraft and missions   10B2-SPC-JOB-X47:FNC-ORB-S25
+#   10B2-PLT      platforms                 10B2-PLT-AER-J31:FNC-LFT-P45
+#   10B3-PRO      propulsion                10B3-PRO-ION-J09:THR-25K-EFF
+#   10B3-ENR      power and energy          10B3-ENR-KIN-J27:FNC-TRB-R35
+#   11D1-PRG      program phases            11D1-PRG-JPH-E01:TIM-Y01-Y05
+#   00B2-CEL      destinations              00B2-CEL-MON-SFC:000-000-000-001
+
+DEF_RELATION #R1001 [OPERATES] type=[AGENT_OPERATION] domain=[10C1-ORG] range=[10B]
+DEF_RELATION #R1002 [POWERS] type=[SUBSYSTEM_FUNCTION] domain=[10B3] range=[10B2]
+DEF_RELATION #R1003 [USES] type=[SUBSYSTEM_FUNCTION] domain=[10B2] range=[10B3]
+DEF_RELATION #R1004 [SUPPORTS] type=[SUBSYSTEM_FUNCTION] domain=[10B2] range=[10B]
+DEF_RELATION #R1005 [PRECEDES] type=[TEMPORAL_ORDER] domain=[11D1-PRG] range=[11D1-PRG]
+DEF_RELATION #R1006 [DEVELOPED_DURING] type=[TEMPORAL_CONTEXT] domain=[10B] range=[11D1-PRG]
+DEF_RELATION #R1007 [TARGETS] type=[MISSION_OBJECTIVE] domain=[10B2-SPC] range=[00B]
+
+DEF_PROPERTY #P1001 [mass] type=[QUANTITY] domain=[10B] functional=[true]
+DEF_PROPERTY #P1002 [thrust] type=[QUANTITY] domain=[10B3-PRO] functional=[true]
+DEF_PROPERTY #P1003 [power_output] type=[QUANTITY] domain=[10B3-ENR] functional=[true]
+DEF_PROPERTY #P1004 [launch_date] type=[DATE] domain=[10B2-SPC] functional=[true]
+DEF_PROPERTY #P1005 [budget] type=[QUANTITY] domain=[11D1-PRG] functional=[true]
+`
+
+const astronomyTemplate = `# Astronomy knowledge base
+#
+# TOSID categories:
+#   00B2-SOL      stars           00B2-SOL-STR-SGL:SPT-G2V-001
+#   00B3-EXO-TE   planets         00B3-EXO-TE-P01:RAD-1.0E-M1
+#   00B3-EXO-HZ   orbits          00B3-EXO-HZ-P01:ORB-1.0A-P365
+#   00B3-EXO-AT   atmospheres     00B3-EXO-AT-P01:ATM-N2O-H67
+#   00B2-CEL      moons           00B2-CEL-MON-SFC:000-000-000-001
+#   10C1-ORG      observatories   10C1-ORG-GOV-USA:NASA
+
+DEF_RELATION #R1001 [ORBITS] type=[CELESTIAL_MOTION] domain=[00B] range=[00B2]
+DEF_RELATION #R1002 [HAS_ORBIT] type=[ORBITAL_CHARACTERISTICS] domain=[00B3] range=[00B3-EXO-HZ]
+DEF_RELATION #R1003 [HAS_ATMOSPHERE] type=[ATMOSPHERIC_CHARACTERISTICS] domain=[00B3] range=[00B3-EXO-AT]
+DEF_RELATION #R1004 [HABITABILITY_POTENTIAL] type=[ASTROBIOLOGICAL_ASSESSMENT] domain=[00B3]
+DEF_RELATION #R1005 [OBSERVED_BY] type=[OBSERVATION] domain=[00B] range=[10C1-ORG]
+
+DEF_PROPERTY #P1001 [radius] type=[QUANTITY] domain=[00B] functional=[true]
+DEF_PROPERTY #P1002 [mass] type=[QUANTITY] domain=[00B] functional=[true]
+DEF_PROPERTY #P1003 [spectral_class] type=[CLASSIFICATION] domain=[00B2-SOL] functional=[true]
+DEF_PROPERTY #P1004 [orbital_period] type=[QUANTITY] domain=[00B3-EXO-HZ] functional=[true]
+DEF_PROPERTY #P1005 [distance] type=[QUANTITY] domain=[00B2] functional=[true]
+`
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+	"github.com/ha1tch/tosid-go/pkg/lint"
+)
+
+func TestTemplates(t *testing.T) {
+	for name, tmpl := range templates {
+		statements, err := kmac.NewTextSerializer().Decode(strings.NewReader(tmpl.text))
+		if err != nil {
+			t.Errorf("Template %s does not decode: %v", name, err)
+			continue
+		}
+		if len(statements) == 0 {
+			t.Errorf("Template %s is empty", name)
+		}
+		for _, issue := range lint.Lint(statements, nil) {
+			if issue.Rule != lint.RuleUnusedRelation {
+				t.Errorf("Template %s: %v", name, issue)
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// client calls each exported function and prints the results
+const client = `#include <stdio.h>
+#include "libtosid.h"
+
+int main(void) {
+	char* err = NULL;
+	tosid_components components;
+	printf("abi %d\n", tosid_abi_version());
+	if (tosid_parse("10B3TR-AIR-HEL", &components, &err) == 0) {
+		printf("parse %s %s %s %s\n", components.taxonomy_code, components.netmask, components.identifier, components.code);
+		tosid_free_components(&components);
+	}
+	if (tosid_parse("bad", &components, &err) != 0) {
+		printf("parse error %d\n", err != NULL);
+		tosid_free(err);
+	}
+	char* problems = NULL;
+	printf("valid %d\n", tosid_validate("10B3TR-AIR-HEL", &problems));
+	printf("invalid %d\n", tosid_validate("00F1NA-GAL-ART", &problems));
+	tosid_free(problems);
+	char* classification = tosid_classify("10B3TR-AIR-HEL", NULL);
+	printf("classify %d\n", classification != NULL);
+	tosid_free(classification);
+	printf("match %d %d %d\n", tosid_matches_pattern("10B3TR-AIR-HEL", "10B*", NULL),
+		tosid_matches_pattern("10B3TR-AIR-HEL", "00*", NULL), tosid_matches_pattern("bad", "*", NULL));
+	return 0;
+}
+`
+
+func TestCClient(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping shared library build in short mode")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("C compiler not found")
+	}
+
+	dir := t.TempDir()
+	run := func(name string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command(name, args...)
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s failed: %v\n%s", name, err, output)
+		}
+		return string(output)
+	}
+	source, _ := os.Getwd()
+	run(goTool, "build", "-C", source, "-buildmode=c-shared", "-o", filepath.Join(dir, "libtosid.so"), ".")
+	if err := os.WriteFile(filepath.Join(dir, "client.c"), []byte(client), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run(cc, "-o", "client", "client.c", "-L.", "-ltosid", "-Wl,-rpath,"+dir)
+
+	want := []string{
+		"abi 1",
+		"parse 10 B 3TR-AIR-HEL 10B-3TR-AIR-HEL",
+		"parse error 1",
+		"valid 0",
+		"invalid 2",
+		"classify 1",
+		"match 1 0 -1",
+	}
+	if got := strings.TrimSpace(run(filepath.Join(dir, "client"))); got != strings.Join(want, "\n") {
+		t.Errorf("Unexpected client output:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,156 @@
+//go:build cgo
+
+// Command tosid-capi exports the TOSID parser as a C shared library, so
+// Python, C++, and other pipelines can use the canonical implementation.
+// Build it with
+//
+//	go build -buildmode=c-shared -o libtosid.so ./cmd/tosid-capi
+//
+// which also writes libtosid.h. The ABI is versioned by tosid_abi_version;
+// functions and the tosid_components layout are only ever added to, never
+// changed, within a version.
+//
+// Strings passed in are NUL-terminated UTF-8 and are not retained. Strings
+// passed out are allocated with malloc and belong to the caller, who frees
+// them with tosid_free. Functions taking char** error set it, when it is not
+// NULL, to a description of the failure, or to NULL on success.
+//
+// From Python:
+//
+//	lib = ctypes.CDLL("./libtosid.so")
+//	lib.tosid_classify.restype = ctypes.c_void_p
+//	p = lib.tosid_classify(b"10B3TR-AIR-HEL", None)
+//	print(ctypes.string_at(p).decode())
+//	lib.tosid_free(ctypes.c_void_p(p))
+package main
+
+/*
+#include <stdlib.h>
+
+// tosid_components are the parts of a parsed code
+typedef struct {
+	char* taxonomy_code;
+	char* netmask;
+	char* identifier;
+	char* code; // Canonical form
+} tosid_components;
+*/
+import "C"
+
+import (
+	"strings"
+	"unsafe"
+
+	"github.com/ha1tch/tosid-go/pkg/tosid"
+)
+
+// abiVersion is the version of the exported functions
+const abiVersion = 1
+
+// setError reports a failure through an error out-parameter, if given
+func setError(out **C.char, message string) {
+	if out != nil {
+		*out = C.CString(message)
+	}
+}
+
+// clearError reports success through an error out-parameter, if given
+func clearError(out **C.char) {
+	if out != nil {
+		*out = nil
+	}
+}
+
+// tosid_abi_version returns the version of the ABI the library provides
+//
+//export tosid_abi_version
+func tosid_abi_version() C.int {
+	return abiVersion
+}
+
+// tosid_parse parses a code into out, whose strings the caller frees with
+// tosid_free_components. It returns 0 on success and -1 on failure.
+//
+//export tosid_parse
+func tosid_parse(code *C.char, out *C.tosid_components, err **C.char) C.int {
+	t, parseErr := tosid.Parse(C.GoString(code))
+	if parseErr != nil {
+		setError(err, parseErr.Error())
+		return -1
+	}
+	clearError(err)
+	out.taxonomy_code = C.CString(t.TaxonomyCode)
+	out.netmask = C.CString(t.NetmaskIndicator)
+	out.identifier = C.CString(t.Identifier)
+	out.code = C.CString(t.String())
+	return 0
+}
+
+// tosid_free_components frees the strings tosid_parse filled in
+//
+//export tosid_free_components
+func tosid_free_components(components *C.tosid_components) {
+	if components == nil {
+		return
+	}
+	for _, s := range []**C.char{&components.taxonomy_code, &components.netmask, &components.identifier, &components.code} {
+		C.free(unsafe.Pointer(*s))
+		*s = nil
+	}
+}
+
+// tosid_validate checks a code against every rule and returns the number of
+// problems found. When problems is not NULL, it is set to the problems, one
+// per line, or to NULL for a valid code.
+//
+//export tosid_validate
+func tosid_validate(code *C.char, problems **C.char) C.int {
+	found := tosid.Validate(C.GoString(code))
+	if problems != nil {
+		*problems = nil
+		if len(found) > 0 {
+			*problems = C.CString(strings.Join(found, "\n"))
+		}
+	}
+	return C.int(len(found))
+}
+
+// tosid_classify returns the classification a code's taxonomy and netmask
+// describe, or NULL if the code does not parse
+//
+//export tosid_classify
+func tosid_classify(code *C.char, err **C.char) *C.char {
+	t, parseErr := tosid.Parse(C.GoString(code))
+	if parseErr != nil {
+		setError(err, parseErr.Error())
+		return nil
+	}
+	clearError(err)
+	return C.CString(t.ClassificationDescription())
+}
+
+// tosid_matches_pattern returns 1 if a code matches a pattern with
+// wildcards, 0 if not, and -1 if the code does not parse
+//
+//export tosid_matches_pattern
+func tosid_matches_pattern(code *C.char, pattern *C.char, err **C.char) C.int {
+	t, parseErr := tosid.Parse(C.GoString(code))
+	if parseErr != nil {
+		setError(err, parseErr.Error())
+		return -1
+	}
+	clearError(err)
+	if t.MatchesPattern(C.GoString(pattern)) {
+		return 1
+	}
+	return 0
+}
+
+// tosid_free frees a string returned by the library
+//
+//export tosid_free
+func tosid_free(p unsafe.Pointer) {
+	C.free(p)
+}
+
+func main() {}
@@ -0,0 +1,15 @@
+//go:build !cgo
+
+// Command tosid-capi exports the TOSID parser as a C shared library. It
+// needs cgo; build it with CGO_ENABLED=1 and -buildmode=c-shared.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	fmt.Fprintln(os.Stderr, "tosid-capi: build with CGO_ENABLED=1 -buildmode=c-shared")
+	os.Exit(2)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"github.com/ha1tch/tosid-go/pkg/tosid"
+)
+
+// Each binding returns a plain object for JavaScript: the result under
+// "value", or the failure under "error", which the JavaScript wrapper throws
+
+// result wraps a binding's value
+func result(value interface{}) map[string]interface{} {
+	return map[string]interface{}{"value": value}
+}
+
+// failure wraps a binding's error
+func failure(err error) map[string]interface{} {
+	return map[string]interface{}{"error": err.Error()}
+}
+
+// parse parses a code into its components
+func parse(code string) map[string]interface{} {
+	t, err := tosid.Parse(code)
+	if err != nil {
+		return failure(err)
+	}
+	return result(map[string]interface{}{
+		"code":         t.String(),
+		"taxonomyCode": t.TaxonomyCode,
+		"netmask":      t.NetmaskIndicator,
+		"identifier":   t.Identifier,
+	})
+}
+
+// validate checks a code against every rule
+func validate(code string) map[string]interface{} {
+	problems := []interface{}{}
+	for _, problem := range tosid.Validate(code) {
+		problems = append(problems, problem)
+	}
+	return result(map[string]interface{}{"valid": len(problems) == 0, "problems": problems})
+}
+
+// describe explains a code: its classification, hierarchy, and segments
+func describe(code string) map[string]interface{} {
+	t, err := tosid.Parse(code)
+	if err != nil {
+		return failure(err)
+	}
+	hierarchy := []interface{}{}
+	for _, level := range t.GetHierarchy() {
+		hierarchy = append(hierarchy, level)
+	}
+	segments := []interface{}{}
+	for _, segment := range t.Segments() {
+		segments = append(segments, map[string]interface{}{"name": segment.Name, "value": segment.Value})
+	}
+	return result(map[string]interface{}{
+		"code":           t.String(),
+		"classification": t.ClassificationDescription(),
+		"hierarchy":      hierarchy,
+		"segments":       segments,
+	})
+}
+
+// matchesPattern reports whether a code matches a pattern with wildcards
+func matchesPattern(code string, pattern string) map[string]interface{} {
+	t, err := tosid.Parse(code)
+	if err != nil {
+		return failure(err)
+	}
+	return result(t.MatchesPattern(pattern))
+}
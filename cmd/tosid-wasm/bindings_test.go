@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/ha1tch/tosid-go/pkg/tosid"
+)
+
+func TestBindings(t *testing.T) {
+	parsed := parse("10B3TR-AIR-HEL")["value"].(map[string]interface{})
+	if parsed["taxonomyCode"] != "10" || parsed["netmask"] != "B" || parsed["identifier"] != "3TR-AIR-HEL" {
+		t.Errorf("Unexpected parse result %v", parsed)
+	}
+	if err, failed := parse("not a code")["error"]; !failed || err == "" {
+		t.Error("Expected parse of a malformed code to fail")
+	}
+
+	for _, code := range []string{"10B3TR-AIR-HEL", "not a code", "00F1NA-GAL-ART"} {
+		validated := validate(code)["value"].(map[string]interface{})
+		problems := tosid.Validate(code)
+		if validated["valid"] != (len(problems) == 0) || len(validated["problems"].([]interface{})) != len(problems) {
+			t.Errorf("validate(%q) = %v, but tosid.Validate gives %v", code, validated, problems)
+		}
+	}
+
+	described := describe("10B3TR-AIR-HEL")["value"].(map[string]interface{})
+	if !strings.Contains(described["classification"].(string), "Artificial") || len(described["segments"].([]interface{})) == 0 {
+		t.Errorf("Unexpected describe result %v", described)
+	}
+
+	if matchesPattern("10B3TR-AIR-HEL", "10B*")["value"] != true || matchesPattern("10B3TR-AIR-HEL", "00*")["value"] != false {
+		t.Error("Unexpected matchesPattern results")
+	}
+}
+
+func TestBuildsForWebAssembly(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping WebAssembly build in short mode")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+	cmd := exec.Command(goTool, "build", "-o", os.DevNull, ".")
+	cmd.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("WebAssembly build failed: %v\n%s", err, output)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !(js && wasm)
+
+// Command tosid-wasm exposes the TOSID parser to JavaScript. It only runs as
+// WebAssembly; build it with GOOS=js GOARCH=wasm.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	fmt.Fprintln(os.Stderr, "tosid-wasm: build with GOOS=js GOARCH=wasm")
+	os.Exit(2)
+}
@@ -0,0 +1,39 @@
+//go:build js && wasm
+
+// Command tosid-wasm exposes the TOSID parser to JavaScript, so web
+// interfaces can validate codes with the same rules as Go services. Built
+// with GOOS=js GOARCH=wasm, it sets a global tosid object with parse,
+// validate, describe, and matchesPattern functions, which tosid.js wraps.
+package main
+
+import (
+	"errors"
+	"syscall/js"
+)
+
+// binding adapts a function of string arguments to JavaScript
+func binding(arity int, fn func(args []string) map[string]interface{}) js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) != arity {
+			return failure(errors.New("wrong number of arguments"))
+		}
+		strings := make([]string, arity)
+		for i, arg := range args {
+			if arg.Type() != js.TypeString {
+				return failure(errors.New("arguments must be strings"))
+			}
+			strings[i] = arg.String()
+		}
+		return fn(strings)
+	})
+}
+
+func main() {
+	js.Global().Set("tosid", map[string]interface{}{
+		"parse":          binding(1, func(args []string) map[string]interface{} { return parse(args[0]) }),
+		"validate":       binding(1, func(args []string) map[string]interface{} { return validate(args[0]) }),
+		"describe":       binding(1, func(args []string) map[string]interface{} { return describe(args[0]) }),
+		"matchesPattern": binding(2, func(args []string) map[string]interface{} { return matchesPattern(args[0], args[1]) }),
+	})
+	select {}
+}
@@ -0,0 +1,45 @@
+// TOSID parser for the browser, backed by the Go implementation compiled to
+// WebAssembly, so codes are validated client-side by the same rules as the
+// Go backend.
+//
+// Build tosid.wasm with `make wasm`, and serve it with the wasm_exec.js it
+// copies from the Go distribution, loaded before this module:
+//
+//	<script src="wasm_exec.js"></script>
+//	<script type="module">
+//	  import { loadTOSID } from "./tosid.js";
+//	  const tosid = await loadTOSID("tosid.wasm");
+//	  tosid.validate("10B3TR-AIR-HEL"); // { valid: true, problems: [] }
+//	</script>
+
+// unwrap returns a binding's value, throwing its error
+function unwrap(result) {
+  if (result.error !== undefined) {
+    throw new Error(result.error);
+  }
+  return result.value;
+}
+
+// loadTOSID instantiates tosid.wasm and returns the parser's functions:
+//
+//   parse(code)                  { code, taxonomyCode, netmask, identifier }
+//   validate(code)               { valid, problems }
+//   describe(code)               { code, classification, hierarchy, segments }
+//   matchesPattern(code, pattern) boolean
+//
+// parse, describe, and matchesPattern throw for codes that do not parse;
+// validate reports them as problems instead.
+export async function loadTOSID(url = "tosid.wasm") {
+  if (globalThis.tosid === undefined) {
+    const go = new Go();
+    const { instance } = await WebAssembly.instantiateStreaming(fetch(url), go.importObject);
+    go.run(instance);
+  }
+  const bindings = globalThis.tosid;
+  return {
+    parse: (code) => unwrap(bindings.parse(code)),
+    validate: (code) => unwrap(bindings.validate(code)),
+    describe: (code) => unwrap(bindings.describe(code)),
+    matchesPattern: (code, pattern) => unwrap(bindings.matchesPattern(code, pattern)),
+  };
+}
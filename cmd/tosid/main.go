@@ -0,0 +1,108 @@
+// Command tosid works with TOSID codes.
+//
+// Usage:
+//
+//	tosid <command> [flags] [args]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ha1tch/tosid-go/pkg/tosid"
+	"github.com/ha1tch/tosid-go/pkg/tosid/suggest"
+)
+
+// command is a tosid subcommand. run returns the process exit code.
+type command struct {
+	summary string
+	run     func(args []string) int
+}
+
+var commands = map[string]command{
+	"suggest": {"propose TOSID codes for a free-text description", runSuggest},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "tosid: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	os.Exit(cmd.run(os.Args[2:]))
+}
+
+// usage lists the available commands
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: tosid <command> [flags] [args]")
+	fmt.Fprintln(os.Stderr, "commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", name, commands[name].summary)
+	}
+}
+
+// runSuggest asks a completion endpoint for codes matching a description.
+// The endpoint, model, and bearer token default to the TOSID_SUGGEST_ENDPOINT,
+// TOSID_SUGGEST_MODEL, and TOSID_SUGGEST_TOKEN environment variables.
+func runSuggest(args []string) int {
+	flags := flag.NewFlagSet("suggest", flag.ExitOnError)
+	endpoint := flags.String("endpoint", os.Getenv("TOSID_SUGGEST_ENDPOINT"), "completion endpoint URL")
+	model := flags.String("model", os.Getenv("TOSID_SUGGEST_MODEL"), "model name sent to the endpoint")
+	limit := flags.Int("n", 5, "maximum number of suggestions")
+	timeout := flags.Duration("timeout", 30*time.Second, "request timeout")
+	flags.Parse(args)
+
+	description := strings.Join(flags.Args(), " ")
+	if description == "" {
+		fmt.Fprintln(os.Stderr, "usage: tosid suggest [flags] \"description\"")
+		return 2
+	}
+	if *endpoint == "" {
+		fmt.Fprintln(os.Stderr, "tosid suggest: -endpoint or TOSID_SUGGEST_ENDPOINT is required")
+		return 2
+	}
+
+	suggester := suggest.NewHTTPSuggester(*endpoint)
+	suggester.Model = *model
+	suggester.MaxSuggestions = *limit
+	if token := os.Getenv("TOSID_SUGGEST_TOKEN"); token != "" {
+		suggester.Header = http.Header{"Authorization": {"Bearer " + token}}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	suggestions, err := suggester.Suggest(ctx, description)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tosid suggest: %v\n", err)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CODE\tCONFIDENCE\tCLASSIFICATION\tRATIONALE")
+	for _, s := range suggestions {
+		classification := ""
+		if t, err := tosid.Parse(s.Code); err == nil {
+			classification = t.ClassificationDescription()
+		}
+		fmt.Fprintf(w, "%s\t%.2f\t%s\t%s\n", s.Code, s.Confidence, classification, s.Rationale)
+	}
+	w.Flush()
+	return 0
+}
@@ -1,6 +1,7 @@
 package integration
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -54,12 +55,21 @@ func (c *KMACTOSIDConverter) FindEntitiesByTOSIDPattern(entities []*kmac.Entity,
 
 // GenerateKMACFromTOSIDHierarchy generates KMAC statements from a TOSID hierarchy
 func (c *KMACTOSIDConverter) GenerateKMACFromTOSIDHierarchy(tosidCodes []string, labels map[string]string) ([]kmac.Statement, error) {
+	return c.GenerateKMACFromTOSIDHierarchyContext(context.Background(), tosidCodes, labels)
+}
+
+// GenerateKMACFromTOSIDHierarchyContext generates KMAC statements from a TOSID hierarchy, stopping early if ctx is done
+func (c *KMACTOSIDConverter) GenerateKMACFromTOSIDHierarchyContext(ctx context.Context, tosidCodes []string, labels map[string]string) ([]kmac.Statement, error) {
 	var statements []kmac.Statement
 	entityMap := make(map[string]*kmac.Entity)
 	idCounter := 1
 
 	// First pass: create entities
 	for _, code := range tosidCodes {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		// Parse the TOSID code
 		tosidObj, err := tosid.Parse(code)
 		if err != nil {
@@ -91,8 +101,11 @@ func (c *KMACTOSIDConverter) GenerateKMACFromTOSIDHierarchy(tosidCodes []string,
 	}
 
 	// Second pass: create part-of relationships based on TOSID hierarchy
-	relationCounter := 1
 	for _, code := range tosidCodes {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		tosidObj, err := tosid.Parse(code)
 		if err != nil {
 			continue
@@ -0,0 +1,394 @@
+package kmac
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Precision is the granularity of an approximate time
+type Precision string
+
+const (
+	PrecisionInstant Precision = "INSTANT"
+	PrecisionDay     Precision = "DAY"
+	PrecisionMonth   Precision = "MONTH"
+	PrecisionYear    Precision = "YEAR"
+	PrecisionDecade  Precision = "DECADE"
+	PrecisionCentury Precision = "CENTURY"
+)
+
+// Bounds of an approximate time
+const (
+	BoundBefore = "BEFORE"
+	BoundAfter  = "AFTER"
+)
+
+// Comparison is the answer to an ordering question between uncertain times
+type Comparison int
+
+const (
+	Never      Comparison = iota // The relation holds for no reading of the times
+	Possibly                     // The relation holds for some readings
+	Definitely                   // The relation holds for every reading
+)
+
+// String returns the name of the comparison
+func (c Comparison) String() string {
+	switch c {
+	case Never:
+		return "NEVER"
+	case Possibly:
+		return "POSSIBLY"
+	case Definitely:
+		return "DEFINITELY"
+	}
+	return fmt.Sprintf("Comparison(%d)", int(c))
+}
+
+// ApproximateTime is a time known only to some precision, such as "1960s",
+// "circa 1950", "before 1970", or "4000 BCE". It stands for every instant
+// in the span it names; circa widens the span by a margin that grows with
+// the precision, and a bound leaves one side of the span open.
+type ApproximateTime struct {
+	Value     time.Time // Start of the named period
+	Precision Precision
+	Circa     bool
+	Bound     string // BoundBefore, BoundAfter, or empty
+}
+
+var (
+	centuryPattern = regexp.MustCompile(`^(\d+)(?:st|nd|rd|th) century$`)
+	decadePattern  = regexp.MustCompile(`^(\d*0)s$`)
+)
+
+// ParseApproximateTime parses approximate time text. It accepts RFC 3339
+// instants, dates (1969-07-20), months (1969-07), years (1969), decades
+// (1960s), and centuries (19th century); years may carry a BCE or CE era.
+// Any of these may be prefixed with circa (or c., ca., ~) and with before
+// or after.
+func ParseApproximateTime(text string) (*ApproximateTime, error) {
+	s := strings.ToLower(strings.TrimSpace(text))
+	approx := &ApproximateTime{}
+
+	for prefix, bound := range map[string]string{"before ": BoundBefore, "after ": BoundAfter} {
+		if strings.HasPrefix(s, prefix) {
+			approx.Bound = bound
+			s = strings.TrimSpace(strings.TrimPrefix(s, prefix))
+		}
+	}
+	for _, prefix := range []string{"circa ", "ca. ", "c. ", "~"} {
+		if strings.HasPrefix(s, prefix) {
+			approx.Circa = true
+			s = strings.TrimSpace(strings.TrimPrefix(s, prefix))
+			break
+		}
+	}
+	if s == "" {
+		return nil, errors.New("approximate time cannot be empty")
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, strings.ToUpper(s)); err == nil {
+		approx.Value, approx.Precision = t, PrecisionInstant
+		return approx, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		approx.Value, approx.Precision = t, PrecisionDay
+		return approx, nil
+	}
+	if t, err := time.Parse("2006-01", s); err == nil {
+		approx.Value, approx.Precision = t, PrecisionMonth
+		return approx, nil
+	}
+
+	bce := false
+	for _, era := range []string{" bce", " bc", " ce", " ad"} {
+		if strings.HasSuffix(s, era) {
+			bce = era == " bce" || era == " bc"
+			s = strings.TrimSpace(strings.TrimSuffix(s, era))
+			break
+		}
+	}
+
+	var year int
+	switch {
+	case centuryPattern.MatchString(s):
+		n, _ := strconv.Atoi(centuryPattern.FindStringSubmatch(s)[1])
+		if n < 1 {
+			return nil, fmt.Errorf("invalid century in %q", text)
+		}
+		approx.Precision = PrecisionCentury
+		if bce {
+			year = -n * 100
+		} else {
+			year = (n-1)*100 + 1
+		}
+	case decadePattern.MatchString(s):
+		n, _ := strconv.Atoi(decadePattern.FindStringSubmatch(s)[1])
+		approx.Precision = PrecisionDecade
+		year = n
+		if bce {
+			year = -n - 9
+		}
+	default:
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("unrecognized approximate time %q", text)
+		}
+		approx.Precision = PrecisionYear
+		year = n
+		if bce {
+			year = -n
+		}
+	}
+	if bce {
+		// There is no year 0 in the BCE/CE reckoning: 1 BCE is astronomical year 0
+		year++
+	} else if year == 0 && approx.Precision == PrecisionYear {
+		return nil, fmt.Errorf("there is no year 0 in %q", text)
+	}
+	approx.Value = time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	return approx, nil
+}
+
+// NewApproximateTime creates an approximate time for the period of the given
+// precision containing t
+func NewApproximateTime(t time.Time, precision Precision) *ApproximateTime {
+	approx := &ApproximateTime{Precision: precision}
+	switch precision {
+	case PrecisionDay:
+		approx.Value = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	case PrecisionMonth:
+		approx.Value = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	case PrecisionYear:
+		approx.Value = time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location())
+	case PrecisionDecade:
+		approx.Value = time.Date(floorDiv(t.Year(), 10)*10, time.January, 1, 0, 0, 0, 0, t.Location())
+	case PrecisionCentury:
+		approx.Value = time.Date(floorDiv(t.Year()-1, 100)*100+1, time.January, 1, 0, 0, 0, 0, t.Location())
+	default:
+		approx.Value, approx.Precision = t, PrecisionInstant
+	}
+	return approx
+}
+
+// Exact creates an approximate time that is a single known instant
+func Exact(t time.Time) *ApproximateTime {
+	return &ApproximateTime{Value: t, Precision: PrecisionInstant}
+}
+
+// span returns the end of the period starting at Value
+func (a *ApproximateTime) span() time.Time {
+	switch a.Precision {
+	case PrecisionDay:
+		return a.Value.AddDate(0, 0, 1)
+	case PrecisionMonth:
+		return a.Value.AddDate(0, 1, 0)
+	case PrecisionYear:
+		return a.Value.AddDate(1, 0, 0)
+	case PrecisionDecade:
+		return a.Value.AddDate(10, 0, 0)
+	case PrecisionCentury:
+		return a.Value.AddDate(100, 0, 0)
+	}
+	return a.Value.Add(time.Nanosecond)
+}
+
+// margin widens a circa time: three days around a day, a month around a
+// month, five years around a year, ten around a decade, and 25 around a
+// century
+func (a *ApproximateTime) margin(t time.Time, sign int) time.Time {
+	switch a.Precision {
+	case PrecisionInstant, PrecisionDay:
+		return t.AddDate(0, 0, 3*sign)
+	case PrecisionMonth:
+		return t.AddDate(0, sign, 0)
+	case PrecisionYear:
+		return t.AddDate(5*sign, 0, 0)
+	case PrecisionDecade:
+		return t.AddDate(10*sign, 0, 0)
+	}
+	return t.AddDate(25*sign, 0, 0)
+}
+
+// Earliest returns the earliest instant the time may be. It is false when
+// the time is unbounded in the past.
+func (a *ApproximateTime) Earliest() (time.Time, bool) {
+	switch a.Bound {
+	case BoundBefore:
+		return time.Time{}, false
+	case BoundAfter:
+		end := a.span()
+		if a.Circa {
+			end = a.margin(end, -1)
+		}
+		return end, true
+	}
+	if a.Circa {
+		return a.margin(a.Value, -1), true
+	}
+	return a.Value, true
+}
+
+// Latest returns the instant before which the time must be. It is false when
+// the time is unbounded in the future.
+func (a *ApproximateTime) Latest() (time.Time, bool) {
+	switch a.Bound {
+	case BoundAfter:
+		return time.Time{}, false
+	case BoundBefore:
+		start := a.Value
+		if a.Circa {
+			start = a.margin(start, 1)
+		}
+		return start, true
+	}
+	if a.Circa {
+		return a.margin(a.span(), 1), true
+	}
+	return a.span(), true
+}
+
+// Representative returns a single instant standing for the time: the middle
+// of a bounded span, or the bound of an open one
+func (a *ApproximateTime) Representative() time.Time {
+	earliest, hasEarliest := a.Earliest()
+	latest, hasLatest := a.Latest()
+	switch {
+	case hasEarliest && hasLatest:
+		if a.Precision == PrecisionInstant && !a.Circa {
+			return a.Value
+		}
+		return earliest.Add(latest.Sub(earliest) / 2)
+	case hasEarliest:
+		return earliest
+	}
+	return latest
+}
+
+// Contains reports whether an instant is a possible reading of the time
+func (a *ApproximateTime) Contains(t time.Time) bool {
+	if earliest, ok := a.Earliest(); ok && t.Before(earliest) {
+		return false
+	}
+	if latest, ok := a.Latest(); ok && !t.Before(latest) {
+		return false
+	}
+	return true
+}
+
+// Before reports whether the time precedes another
+func (a *ApproximateTime) Before(other *ApproximateTime) Comparison {
+	return a.bounds().before(other.bounds())
+}
+
+// After reports whether the time follows another
+func (a *ApproximateTime) After(other *ApproximateTime) Comparison {
+	return other.Before(a)
+}
+
+// Overlaps reports whether two times may be the same instant
+func (a *ApproximateTime) Overlaps(other *ApproximateTime) bool {
+	return a.Before(other) != Definitely && other.Before(a) != Definitely
+}
+
+// bounds returns the span of possible instants
+func (a *ApproximateTime) bounds() timeBounds {
+	var b timeBounds
+	b.earliest, b.hasEarliest = a.Earliest()
+	b.latest, b.hasLatest = a.Latest()
+	return b
+}
+
+// timeBounds is a span of possible instants from earliest up to latest; a
+// side without a bound is open
+type timeBounds struct {
+	earliest    time.Time
+	latest      time.Time
+	hasEarliest bool
+	hasLatest   bool
+}
+
+// before compares two spans: every instant of the first precedes every
+// instant of the second, none does, or some do
+func (b timeBounds) before(other timeBounds) Comparison {
+	if b.hasLatest && other.hasEarliest && !other.earliest.Before(b.latest) {
+		return Definitely
+	}
+	if b.hasEarliest && other.hasLatest && !b.earliest.Before(other.latest) {
+		return Never
+	}
+	return Possibly
+}
+
+// String renders the time in the form ParseApproximateTime accepts
+func (a *ApproximateTime) String() string {
+	var s string
+	year := a.Value.Year()
+	era := func(y int) string {
+		if y <= 0 {
+			return fmt.Sprintf("%d BCE", 1-y)
+		}
+		return strconv.Itoa(y)
+	}
+	switch a.Precision {
+	case PrecisionInstant:
+		s = a.Value.Format(time.RFC3339Nano)
+	case PrecisionDay:
+		s = a.Value.Format("2006-01-02")
+	case PrecisionMonth:
+		s = a.Value.Format("2006-01")
+	case PrecisionYear:
+		s = era(year)
+	case PrecisionDecade:
+		if year <= 0 {
+			s = fmt.Sprintf("%ds BCE", -year-8)
+		} else {
+			s = fmt.Sprintf("%ds", year)
+		}
+	case PrecisionCentury:
+		if year <= 0 {
+			s = ordinal((1-year)/100) + " century BCE"
+		} else {
+			s = ordinal((year-1)/100+1) + " century"
+		}
+	}
+	if a.Circa {
+		s = "circa " + s
+	}
+	switch a.Bound {
+	case BoundBefore:
+		s = "before " + s
+	case BoundAfter:
+		s = "after " + s
+	}
+	return s
+}
+
+// ordinal renders 1 as 1st, 2 as 2nd, and so on
+func ordinal(n int) string {
+	suffix := "th"
+	if n%100 < 11 || n%100 > 13 {
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return strconv.Itoa(n) + suffix
+}
+
+// floorDiv divides rounding towards negative infinity
+func floorDiv(a int, b int) int {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
@@ -0,0 +1,162 @@
+package kmac
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Record is the structured form of one statement in disassembly output. It
+// mirrors the statement's KMAC text: the keyword, the ID, the bracketed
+// label, and the named fields as written, with CONFIDENCE, PROPERTY, and
+// metadata lines folded in.
+type Record struct {
+	Keyword     string            `json:"keyword"`
+	ID          string            `json:"id,omitempty"`
+	Label       string            `json:"label,omitempty"`
+	Fields      map[string]string `json:"fields,omitempty"`
+	Confidence  *RecordConfidence `json:"confidence,omitempty"`
+	Properties  map[string]string `json:"properties,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Notes       []string          `json:"notes,omitempty"`
+}
+
+// RecordConfidence is the confidence qualifier of an assertion record
+type RecordConfidence struct {
+	Level  float64 `json:"level"`
+	Source string  `json:"source,omitempty"`
+}
+
+// labelledKeywords are the statements whose text carries a bracketed label
+var labelledKeywords = map[string]bool{
+	"DEF_ENTITY": true, "DEF_EVENT": true, "DEF_RELATION": true,
+	"DEF_PROPERTY": true, "DEF_PLAN": true, "DEF_TASK": true, "DEF_SITUATION": true,
+}
+
+// RecordsFor converts statements to records, in order
+func RecordsFor(statements []Statement) ([]Record, error) {
+	serializer := NewTextSerializer()
+	records := make([]Record, 0, len(statements))
+	for _, stmt := range statements {
+		record, err := recordFor(serializer, stmt)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// recordFor splits a statement's KMAC text back into its fields
+func recordFor(serializer *TextSerializer, stmt Statement) (Record, error) {
+	var record Record
+	for i, line := range serializer.FormatStatement(stmt) {
+		keyword, id, fields, err := splitLine(line)
+		if err != nil {
+			return Record{}, fmt.Errorf("statement %s: %v", stmt.ID(), err)
+		}
+		switch {
+		case i == 0:
+			record = Record{Keyword: keyword, ID: id, Label: fields.positional}
+			if len(fields.named) > 0 {
+				record.Fields = fields.named
+			}
+		case keyword == "CONFIDENCE":
+			level, err := strconv.ParseFloat(fields.named["level"], 64)
+			if err != nil {
+				return Record{}, fmt.Errorf("statement %s: invalid confidence level: %v", stmt.ID(), err)
+			}
+			record.Confidence = &RecordConfidence{Level: level, Source: fields.named["source"]}
+		case keyword == "PROPERTY":
+			if record.Properties == nil {
+				record.Properties = make(map[string]string)
+			}
+			record.Properties[fields.positional] = fields.named["value"]
+		case keyword == "TAG":
+			record.Tags = append(record.Tags, fields.positional)
+		case keyword == "ANNOTATE":
+			if record.Annotations == nil {
+				record.Annotations = make(map[string]string)
+			}
+			record.Annotations[fields.positional] = fields.named["value"]
+		case keyword == "NOTE":
+			record.Notes = append(record.Notes, fields.positional)
+		}
+	}
+	return record, nil
+}
+
+// lines renders a record as KMAC text lines
+func (r Record) lines() []string {
+	var sb strings.Builder
+	sb.WriteString(r.Keyword)
+	if r.ID != "" {
+		sb.WriteString(" #" + r.ID)
+	}
+	if labelledKeywords[r.Keyword] || r.Label != "" {
+		sb.WriteString(" [" + escapeValue(r.Label) + "]")
+	}
+	names := make([]string, 0, len(r.Fields))
+	for name := range r.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&sb, " %s=[%s]", name, escapeValue(r.Fields[name]))
+	}
+
+	lines := []string{sb.String()}
+	if r.Confidence != nil {
+		lines = append(lines, fmt.Sprintf("CONFIDENCE #%s level=[%s] source=[%s]",
+			r.ID, strconv.FormatFloat(r.Confidence.Level, 'f', -1, 64), escapeValue(r.Confidence.Source)))
+	}
+	lines = append(lines, formatProperties(r.ID, r.Properties)...)
+	return append(lines, formatMetadata(r.ID, sortedTags(r.Tags), r.Annotations, r.Notes)...)
+}
+
+// Assembler rebuilds statements from disassembly records, so that
+// disassembly output can be edited and ingested again
+type Assembler struct {
+	serializer *TextSerializer
+}
+
+// NewAssembler creates a new KMAC assembler
+func NewAssembler() *Assembler {
+	return &Assembler{serializer: NewTextSerializer()}
+}
+
+// Assemble converts records to statements, in order
+func (a *Assembler) Assemble(records []Record) ([]Statement, error) {
+	var statements []Statement
+	byID := make(map[string]Statement)
+	for i, record := range records {
+		if record.Keyword == "" {
+			return nil, fmt.Errorf("record %d has no keyword", i+1)
+		}
+		for _, line := range record.lines() {
+			stmt, err := a.serializer.parseLine(line, byID)
+			if err != nil {
+				return nil, fmt.Errorf("record %d (%s #%s): %v", i+1, record.Keyword, record.ID, err)
+			}
+			if stmt != nil {
+				statements = append(statements, stmt)
+				byID[stmt.ID()] = stmt
+			}
+		}
+	}
+	return statements, nil
+}
+
+// AssembleJSON reads a JSON array of records, as written by
+// Disassembler.DisassembleJSON, and converts it to statements
+func (a *Assembler) AssembleJSON(r io.Reader) ([]Statement, error) {
+	var records []Record
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		return nil, fmt.Errorf("failed to decode records: %v", err)
+	}
+	return a.Assemble(records)
+}
@@ -15,6 +15,8 @@ type Assertion struct {
 	confidenceSource string
 	properties       map[string]string
 	negated          bool
+	onChange         func()
+	Metadata
 }
 
 // NewAssertion creates a new KMAC assertion
@@ -31,13 +33,16 @@ func NewAssertion(id string, subject string, relation string, object string) (*A
 		return nil, errors.New("subject, relation, and object cannot be empty")
 	}
 
+	if subject == id || object == id {
+		return nil, fmt.Errorf("assertion %s cannot refer to itself", id)
+	}
+
 	return &Assertion{
 		id:         id,
 		subject:    subject,
 		relation:   relation,
 		object:     object,
 		confidence: 1.0, // Default to full confidence
-		properties: make(map[string]string),
 		negated:    false,
 	}, nil
 }
@@ -67,6 +72,30 @@ func (a *Assertion) Object() string {
 	return a.object
 }
 
+// IsAboutAssertion reports whether the subject or object is another assertion
+func (a *Assertion) IsAboutAssertion() bool {
+	return IsAssertionReference(a.subject) || IsAssertionReference(a.object)
+}
+
+// IsAssertionReference reports whether an ID refers to an assertion, allowing
+// assertions to be the subject or object of other assertions
+func IsAssertionReference(id string) bool {
+	return validateIdentifier(AssertionIDPrefix, id)
+}
+
+// OnChange sets a function called after each change made through the
+// assertion's setters, so a store that built the assertion can keep it
+func (a *Assertion) OnChange(fn func()) {
+	a.onChange = fn
+}
+
+// changed calls the change function, if one is set
+func (a *Assertion) changed() {
+	if a.onChange != nil {
+		a.onChange()
+	}
+}
+
 // SetConfidence sets the confidence level and source for this assertion
 func (a *Assertion) SetConfidence(level float64, source string) {
 	if level < 0.0 {
@@ -76,6 +105,7 @@ func (a *Assertion) SetConfidence(level float64, source string) {
 	}
 	a.confidence = level
 	a.confidenceSource = source
+	a.changed()
 }
 
 // GetConfidence returns the confidence level and source for this assertion
@@ -86,6 +116,7 @@ func (a *Assertion) GetConfidence() (float64, string) {
 // SetNegated sets whether this assertion is negated
 func (a *Assertion) SetNegated(negated bool) {
 	a.negated = negated
+	a.changed()
 }
 
 // IsNegated returns whether this assertion is negated
@@ -95,7 +126,35 @@ func (a *Assertion) IsNegated() bool {
 
 // SetProperty sets a property on the assertion
 func (a *Assertion) SetProperty(key, value string) {
+	if a.properties == nil {
+		a.properties = make(map[string]string)
+	}
 	a.properties[key] = value
+	a.changed()
+}
+
+// AddTag adds a tag, such as "unverified"
+func (a *Assertion) AddTag(tag string) {
+	a.Metadata.AddTag(tag)
+	a.changed()
+}
+
+// RemoveTag removes a tag
+func (a *Assertion) RemoveTag(tag string) {
+	a.Metadata.RemoveTag(tag)
+	a.changed()
+}
+
+// Annotate sets a key-value annotation
+func (a *Assertion) Annotate(key string, value string) {
+	a.Metadata.Annotate(key, value)
+	a.changed()
+}
+
+// AddNote appends a free-text note
+func (a *Assertion) AddNote(note string) {
+	a.Metadata.AddNote(note)
+	a.changed()
 }
 
 // GetProperty retrieves a property from the assertion
@@ -110,7 +169,7 @@ func (a *Assertion) String() string {
 	if a.negated {
 		prefix = "NEGATE"
 	}
-	return fmt.Sprintf("%s #%s subject=[#%s] relation=[#%s] object=[#%s]", 
+	return fmt.Sprintf("%s #%s subject=[#%s] relation=[#%s] object=[#%s]",
 		prefix, a.id, a.subject, a.relation, a.object)
 }
 
@@ -119,7 +178,7 @@ func (a *Assertion) ConfidenceString() string {
 	if a.confidenceSource == "" {
 		return ""
 	}
-	return fmt.Sprintf("CONFIDENCE #%s level=[%.4f] source=[%s]", 
+	return fmt.Sprintf("CONFIDENCE #%s level=[%.4f] source=[%s]",
 		a.id, a.confidence, a.confidenceSource)
 }
 
@@ -137,4 +196,4 @@ func (a *Assertion) Conflicts(other *Assertion) bool {
 		a.relation == other.relation &&
 		a.object == other.object &&
 		a.negated != other.negated
-}
\ No newline at end of file
+}
@@ -0,0 +1,180 @@
+package kmac
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+)
+
+// canonicalSections orders the statement types of the canonical format.
+// Definitions come before the statements that refer to them, so canonical
+// files load in order.
+var canonicalSections = []string{
+	"DEF_ENTITY", "DEF_EVENT", "DEF_RELATION", "DEF_PROPERTY", "DEF_TIME", "DEF_PLAN", "DEF_TASK", "DEF_SITUATION",
+	"ASSERT", "NARY_ASSERT", "PROPERTY_ASSERT", "STATE", "TEMPORAL",
+	"PART_OF", "PARTICIPANT", "CAUSATION", "DEPENDS_ON", "IN_SITUATION", "EVIDENCE",
+}
+
+// Canonical returns statements in the order of the canonical KMAC format:
+// grouped by type, definitions first, and ordered by ID within each group,
+// with numbers in IDs compared by value so E9 comes before E10. Assertions
+// about assertions follow the assertions they refer to. Statements of types
+// the format does not know come last, grouped by type.
+func Canonical(statements []Statement) []Statement {
+	rank := make(map[string]int, len(canonicalSections))
+	for i, section := range canonicalSections {
+		rank[section] = i
+	}
+	sectionOf := func(stmt Statement) (int, string) {
+		if r, known := rank[stmt.Type()]; known {
+			return r, ""
+		}
+		return len(canonicalSections), stmt.Type()
+	}
+
+	ordered := append([]Statement(nil), statements...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		ri, ti := sectionOf(ordered[i])
+		rj, tj := sectionOf(ordered[j])
+		if ri != rj {
+			return ri < rj
+		}
+		if ti != tj {
+			return ti < tj
+		}
+		return compareIDs(canonicalID(ordered[i]), canonicalID(ordered[j])) < 0
+	})
+	return orderAssertionReferences(ordered)
+}
+
+// EncodeCanonical writes statements to w in the canonical KMAC format: one
+// statement per line with its qualifiers after it, in the order Canonical
+// gives, with a blank line between groups. Encoding the same statements in
+// any order gives the same text, so canonical files diff cleanly.
+func (ts *TextSerializer) EncodeCanonical(w io.Writer, statements []Statement) error {
+	bw := bufio.NewWriter(w)
+	previous := ""
+	for i, stmt := range Canonical(statements) {
+		if i > 0 && stmt.Type() != previous {
+			bw.WriteString("\n")
+		}
+		previous = stmt.Type()
+		for _, line := range ts.FormatStatement(stmt) {
+			if _, err := bw.WriteString(line + "\n"); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// canonicalLines returns the line each statement starts on when the
+// statements are written by EncodeCanonical, counting from 1
+func (ts *TextSerializer) canonicalLines(statements []Statement) map[Statement]int {
+	lines := make(map[Statement]int, len(statements))
+	line, previous := 1, ""
+	for i, stmt := range Canonical(statements) {
+		if i > 0 && stmt.Type() != previous {
+			line++
+		}
+		previous = stmt.Type()
+		lines[stmt] = line
+		line += len(ts.FormatStatement(stmt))
+	}
+	return lines
+}
+
+// FormatCanonical rewrites KMAC text in the canonical format. Comments are
+// not kept.
+func FormatCanonical(src []byte) ([]byte, error) {
+	ts := NewTextSerializer()
+	statements, err := ts.Deserialize(src)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := ts.EncodeCanonical(&buf, statements); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// orderAssertionReferences moves assertions after the assertions their
+// subject or object refer to, keeping the order otherwise
+func orderAssertionReferences(statements []Statement) []Statement {
+	byID := make(map[string]*Assertion)
+	for _, stmt := range statements {
+		if assertion, isAssertion := stmt.(*Assertion); isAssertion {
+			byID[assertion.ID()] = assertion
+		}
+	}
+
+	ordered := make([]Statement, 0, len(statements))
+	placed := make(map[Statement]bool, len(statements))
+	var place func(stmt Statement)
+	place = func(stmt Statement) {
+		if placed[stmt] {
+			return
+		}
+		// Marked first, so a reference cycle cannot recurse forever
+		placed[stmt] = true
+		if assertion, isAssertion := stmt.(*Assertion); isAssertion {
+			for _, ref := range []string{assertion.Subject(), assertion.Object()} {
+				if referenced, exists := byID[ref]; exists && referenced != assertion {
+					place(referenced)
+				}
+			}
+		}
+		ordered = append(ordered, stmt)
+	}
+	for _, stmt := range statements {
+		place(stmt)
+	}
+	return ordered
+}
+
+// canonicalID returns the ID a statement is ordered by
+func canonicalID(stmt Statement) string {
+	if temporal, isTemporal := stmt.(*Temporal); isTemporal {
+		return temporal.AssertionID()
+	}
+	return stmt.ID()
+}
+
+// compareIDs compares IDs with runs of digits compared by value
+func compareIDs(a, b string) int {
+	for a != "" && b != "" {
+		aDigits, bDigits := isDigit(a[0]), isDigit(b[0])
+		if aDigits != bDigits {
+			return strings.Compare(a, b)
+		}
+		aRun, bRun := leadingRun(a, aDigits), leadingRun(b, bDigits)
+		if aDigits {
+			aNum, bNum := strings.TrimLeft(aRun, "0"), strings.TrimLeft(bRun, "0")
+			if len(aNum) != len(bNum) {
+				return len(aNum) - len(bNum)
+			}
+		}
+		if c := strings.Compare(aRun, bRun); c != 0 {
+			return c
+		}
+		a, b = a[len(aRun):], b[len(bRun):]
+	}
+	return len(a) - len(b)
+}
+
+// leadingRun returns the leading digits of s, or the leading non-digits
+func leadingRun(s string, digits bool) string {
+	i := 0
+	for i < len(s) && isDigit(s[i]) == digits {
+		i++
+	}
+	return s[:i]
+}
+
+// isDigit reports whether a byte is an ASCII digit
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,160 @@
+package kmac
+
+import (
+	"io"
+	"os"
+	"strings"
+)
+
+// ANSI escape sequences used by disassembler themes
+const (
+	ColorReset   = "\x1b[0m"
+	ColorBold    = "\x1b[1m"
+	ColorRed     = "\x1b[31m"
+	ColorGreen   = "\x1b[32m"
+	ColorYellow  = "\x1b[33m"
+	ColorBlue    = "\x1b[34m"
+	ColorMagenta = "\x1b[35m"
+	ColorCyan    = "\x1b[36m"
+	ColorGray    = "\x1b[90m"
+)
+
+// Theme chooses the colors of disassembler output. Entities and events are
+// colored by the taxonomy domain of their TOSID type, and assertions by the
+// band their confidence falls in. An empty color leaves text plain.
+type Theme struct {
+	Name       string
+	Heading    string
+	Domains    map[string]string // Keyed by TOSID taxonomy code, such as 00 or 10
+	Confidence []ConfidenceBand  // Checked in order; the first band reached applies
+}
+
+// ConfidenceBand colors confidence levels of at least Min
+type ConfidenceBand struct {
+	Min   float64
+	Color string
+}
+
+// Built-in themes
+var (
+	// DomainTheme colors entities and events by taxonomy domain
+	DomainTheme = &Theme{
+		Name:    "domain",
+		Heading: ColorBold,
+		Domains: map[string]string{
+			"00": ColorYellow,  // Natural, physical
+			"01": ColorMagenta, // Natural, conceptual
+			"10": ColorCyan,    // Artificial, physical
+			"11": ColorBlue,    // Artificial, conceptual
+		},
+	}
+
+	// ConfidenceTheme colors assertions by confidence band
+	ConfidenceTheme = &Theme{
+		Name:    "confidence",
+		Heading: ColorBold,
+		Confidence: []ConfidenceBand{
+			{Min: 0.9, Color: ColorGreen},
+			{Min: 0.6, Color: ColorYellow},
+			{Min: 0, Color: ColorRed},
+		},
+	}
+
+	// DefaultTheme combines the domain and confidence themes
+	DefaultTheme = &Theme{
+		Name:       "default",
+		Heading:    ColorBold,
+		Domains:    DomainTheme.Domains,
+		Confidence: ConfidenceTheme.Confidence,
+	}
+)
+
+// Themes lists the built-in themes by name
+var Themes = map[string]*Theme{
+	DefaultTheme.Name:    DefaultTheme,
+	DomainTheme.Name:     DomainTheme,
+	ConfidenceTheme.Name: ConfidenceTheme,
+}
+
+// DomainColor returns the color for a TOSID type
+func (t *Theme) DomainColor(tosidType string) string {
+	if len(tosidType) < 2 {
+		return ""
+	}
+	return t.Domains[tosidType[:2]]
+}
+
+// ConfidenceColor returns the color for a confidence level
+func (t *Theme) ConfidenceColor(confidence float64) string {
+	for _, band := range t.Confidence {
+		if confidence >= band.Min {
+			return band.Color
+		}
+	}
+	return ""
+}
+
+// ColorSupported reports whether ANSI colors should be written to w: it must
+// be a terminal, NO_COLOR must be unset or empty, and TERM must not be dumb
+func ColorSupported(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// paint wraps text in a color when color output is enabled
+func (d *Disassembler) paint(color string, text string) string {
+	if !d.colorEnabled || color == "" || text == "" {
+		return text
+	}
+	return color + text + ColorReset
+}
+
+// heading paints a heading in the theme's heading color
+func (d *Disassembler) heading(text string) string {
+	return d.paint(d.theme.Heading, text)
+}
+
+// paintDomain paints text in the color of a TOSID type's domain
+func (d *Disassembler) paintDomain(tosidType string, text string) string {
+	return d.paint(d.theme.DomainColor(tosidType), text)
+}
+
+// paintConfidence paints text in the color of a confidence band
+func (d *Disassembler) paintConfidence(confidence float64, text string) string {
+	return d.paint(d.theme.ConfidenceColor(confidence), text)
+}
+
+// paintNode paints an entity or event label by the domain of its TOSID type
+func (d *Disassembler) paintNode(id string, text string) string {
+	if entity, ok := d.entityMap[id]; ok {
+		return d.paintDomain(entity.TOSIDType(), text)
+	}
+	if event, ok := d.eventMap[id]; ok {
+		return d.paintDomain(event.TOSIDType(), text)
+	}
+	return text
+}
+
+// SetTheme sets the color theme; nil restores the default theme
+func (d *Disassembler) SetTheme(theme *Theme) {
+	if theme == nil {
+		theme = DefaultTheme
+	}
+	d.theme = theme
+}
+
+// LookupTheme returns a built-in theme by name, ignoring case
+func LookupTheme(name string) (*Theme, bool) {
+	theme, ok := Themes[strings.ToLower(name)]
+	return theme, ok
+}
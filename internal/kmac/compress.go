@@ -0,0 +1,135 @@
+package kmac
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Compression names a codec for KMAC streams
+type Compression string
+
+// Compressions
+const (
+	CompressionNone Compression = ""
+	CompressionGzip Compression = "gzip"
+	// CompressionZstd has no codec built in, to keep the module free of
+	// dependencies; register one, such as github.com/klauspost/compress/zstd,
+	// with RegisterCodec
+	CompressionZstd Compression = "zstd"
+)
+
+// Codec compresses and decompresses streams. Magic is the bytes every
+// compressed stream starts with, by which readers recognize it.
+type Codec struct {
+	Magic     []byte
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+// zstdMagic starts every zstd frame
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[Compression]Codec{
+		CompressionGzip: {
+			Magic: []byte{0x1f, 0x8b},
+			NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+				return gzip.NewWriter(w), nil
+			},
+			NewReader: func(r io.Reader) (io.ReadCloser, error) {
+				return gzip.NewReader(r)
+			},
+		},
+	}
+)
+
+// RegisterCodec adds or replaces the codec of a compression
+func RegisterCodec(compression Compression, codec Codec) error {
+	if compression == CompressionNone {
+		return fmt.Errorf("cannot register a codec for no compression")
+	}
+	if len(codec.Magic) == 0 || codec.NewWriter == nil || codec.NewReader == nil {
+		return fmt.Errorf("codec for %s needs magic bytes, a writer, and a reader", compression)
+	}
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[compression] = codec
+	return nil
+}
+
+// lookupCodec returns the codec of a compression
+func lookupCodec(compression Compression) (Codec, error) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	codec, exists := codecs[compression]
+	if !exists {
+		return Codec{}, fmt.Errorf("no codec registered for %s compression", compression)
+	}
+	return codec, nil
+}
+
+// NewCompressWriter returns a writer that compresses what is written to it
+// onto w. Close finishes the compressed stream without closing w. With no
+// compression, writes go straight to w.
+func NewCompressWriter(w io.Writer, compression Compression) (io.WriteCloser, error) {
+	if compression == CompressionNone {
+		return nopWriteCloser{w}, nil
+	}
+	codec, err := lookupCodec(compression)
+	if err != nil {
+		return nil, err
+	}
+	return codec.NewWriter(w)
+}
+
+// NewDecompressReader returns a reader that decompresses r as it is read,
+// recognizing the compression by the stream's first bytes. A stream that
+// starts with no registered codec's magic is read as it is.
+func NewDecompressReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	compression, err := sniffCompression(br)
+	if err != nil {
+		return nil, err
+	}
+	if compression == CompressionNone {
+		return io.NopCloser(br), nil
+	}
+	codec, err := lookupCodec(compression)
+	if err != nil {
+		return nil, fmt.Errorf("stream is %s-compressed: %v", compression, err)
+	}
+	return codec.NewReader(br)
+}
+
+// sniffCompression peeks at the start of a stream for a codec's magic
+func sniffCompression(br *bufio.Reader) (Compression, error) {
+	codecsMu.RLock()
+	magics := map[Compression][]byte{CompressionZstd: zstdMagic}
+	for compression, codec := range codecs {
+		magics[compression] = codec.Magic
+	}
+	codecsMu.RUnlock()
+
+	for compression, magic := range magics {
+		head, err := br.Peek(len(magic))
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			return CompressionNone, err
+		}
+		if bytes.Equal(head, magic) {
+			return compression, nil
+		}
+	}
+	return CompressionNone, nil
+}
+
+// nopWriteCloser is a writer whose Close does nothing
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
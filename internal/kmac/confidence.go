@@ -0,0 +1,158 @@
+package kmac
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// heatWidth is the number of cells in a confidence heat bar
+const heatWidth = 10
+
+// ConfidenceEntry is one assertion in a confidence report
+type ConfidenceEntry struct {
+	Assertion  *Assertion
+	Confidence float64
+	Source     string
+	Weighted   float64 // Confidence scaled by the source's reliability weight
+	Low        bool    // Confidence is under the report threshold
+}
+
+// SourceConfidence summarizes the assertions from one source
+type SourceConfidence struct {
+	Source     string
+	Weight     float64 // Reliability weight of the source
+	Registered bool    // The source is in the disassembler's registry
+	Count      int
+	Low        int // Assertions under the report threshold
+	Mean       float64
+	Min        float64
+}
+
+// ConfidenceReport lists assertions from least to most confident and
+// groups them by source, so analysts can target verification effort
+type ConfidenceReport struct {
+	Threshold float64
+	Entries   []ConfidenceEntry  // By ascending confidence, then ID
+	Sources   []SourceConfidence // Most low-confidence assertions first
+}
+
+// LowConfidence returns the entries under the threshold
+func (r *ConfidenceReport) LowConfidence() []ConfidenceEntry {
+	var low []ConfidenceEntry
+	for _, entry := range r.Entries {
+		if entry.Low {
+			low = append(low, entry)
+		}
+	}
+	return low
+}
+
+// SetSources sets the registry that weighs confidence sources in reports
+func (d *Disassembler) SetSources(sources *SourceRegistry) {
+	d.sources = sources
+}
+
+// ConfidenceReport builds a confidence report of the registered assertions,
+// flagging those with confidence under threshold
+func (d *Disassembler) ConfidenceReport(threshold float64) *ConfidenceReport {
+	report := &ConfidenceReport{Threshold: threshold}
+	bySource := make(map[string]*SourceConfidence)
+	for _, id := range sortedKeys(d.assertionMap) {
+		assertion := d.assertionMap[id]
+		confidence, source := assertion.GetConfidence()
+		entry := ConfidenceEntry{Assertion: assertion, Confidence: confidence, Source: source,
+			Weighted: d.sources.Weighted(assertion), Low: confidence < threshold}
+		report.Entries = append(report.Entries, entry)
+
+		summary, ok := bySource[source]
+		if !ok {
+			_, registered := d.sources.Lookup(source)
+			summary = &SourceConfidence{Source: source, Weight: d.sources.Weight(source), Registered: registered, Min: confidence}
+			bySource[source] = summary
+		}
+		summary.Count++
+		summary.Mean += confidence
+		if entry.Low {
+			summary.Low++
+		}
+		if confidence < summary.Min {
+			summary.Min = confidence
+		}
+	}
+	sort.SliceStable(report.Entries, func(i, j int) bool {
+		return report.Entries[i].Confidence < report.Entries[j].Confidence
+	})
+
+	for _, source := range sortedKeys(bySource) {
+		summary := bySource[source]
+		summary.Mean /= float64(summary.Count)
+		report.Sources = append(report.Sources, *summary)
+	}
+	sort.SliceStable(report.Sources, func(i, j int) bool {
+		a, b := report.Sources[i], report.Sources[j]
+		if a.Low != b.Low {
+			return a.Low > b.Low
+		}
+		return a.Mean < b.Mean
+	})
+	return report
+}
+
+// DisassembleConfidence writes a confidence heatmap of the registered
+// assertions, least confident first, flagging those under threshold, followed
+// by a summary of each source
+func (d *Disassembler) DisassembleConfidence(threshold float64) {
+	report := d.ConfidenceReport(threshold)
+
+	fmt.Fprintln(d.writer, d.heading("KMAC CONFIDENCE REPORT"))
+	fmt.Fprintln(d.writer, "======================")
+	fmt.Fprintf(d.writer, "Threshold: %.4f, %d of %d assertions below\n", threshold, len(report.LowConfidence()), len(report.Entries))
+
+	w := tabwriter.NewWriter(d.writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\n"+d.heading("ASSERTIONS BY CONFIDENCE:"))
+	fmt.Fprintln(w, "ID\tSOURCE\tASSERTION\tWEIGHTED\tCONFIDENCE")
+	fmt.Fprintln(w, "--\t------\t---------\t--------\t----------")
+	for _, entry := range report.Entries {
+		cell := fmt.Sprintf("%.4f %s", entry.Confidence, heatBar(entry.Confidence))
+		if entry.Low {
+			cell += " LOW"
+		}
+		fmt.Fprintf(w, "#%s\t%s\t%s\t%.4f\t%s\n", entry.Assertion.ID(), sourceName(entry.Source),
+			d.assertionSummary(entry.Assertion), entry.Weighted, d.paintConfidence(entry.Confidence, cell))
+	}
+
+	fmt.Fprintln(w, "\n"+d.heading("SOURCES:"))
+	fmt.Fprintln(w, "SOURCE\tWEIGHT\tASSERTIONS\tBELOW THRESHOLD\tMIN\tMEAN")
+	fmt.Fprintln(w, "------\t------\t----------\t---------------\t---\t----")
+	for _, summary := range report.Sources {
+		weight := fmt.Sprintf("%.2f", summary.Weight)
+		if !summary.Registered {
+			weight += " (unregistered)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%.4f\t%s\n", sourceName(summary.Source), weight, summary.Count, summary.Low,
+			summary.Min, d.paintConfidence(summary.Mean, fmt.Sprintf("%.4f", summary.Mean)))
+	}
+	w.Flush()
+	fmt.Fprintln(d.writer)
+}
+
+// heatBar renders a confidence level as a bar of filled and empty cells
+func heatBar(confidence float64) string {
+	filled := int(confidence*heatWidth + 0.5)
+	if filled < 0 {
+		filled = 0
+	} else if filled > heatWidth {
+		filled = heatWidth
+	}
+	return strings.Repeat("█", filled) + strings.Repeat("░", heatWidth-filled)
+}
+
+// sourceName names a confidence source, which may be empty
+func sourceName(source string) string {
+	if source == "" {
+		return "(none)"
+	}
+	return source
+}
@@ -0,0 +1,35 @@
+package kmac
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+// contentIDDigits is the number of decimal digits in a content-derived ID.
+// Sixteen digits keep the chance of two of a million statements colliding
+// below one in ten thousand.
+const contentIDDigits = 16
+
+// contentID derives an ID from a hash of a statement's content, so the same
+// content always gets the same ID. The ID is the prefix followed by digits,
+// like a numbered ID, so it passes the same checks.
+func contentID(prefix string, content ...string) string {
+	h := sha256.New()
+	for _, part := range content {
+		// Separate the parts so ("ab", "c") and ("a", "bc") hash differently
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	n := binary.BigEndian.Uint64(h.Sum(nil)) % 1e16
+	return fmt.Sprintf("%s%0*d", prefix, contentIDDigits, n)
+}
+
+// ContentAssertionID derives an assertion ID from what the assertion says:
+// its subject, relation, and object, and a context such as a situation or
+// source that tells otherwise identical assertions apart. Producers that
+// derive IDs this way give the same assertion the same ID, so imports are
+// idempotent and duplicates share an ID.
+func ContentAssertionID(subject string, relation string, object string, context string) string {
+	return contentID(AssertionIDPrefix, subject, relation, object, context)
+}
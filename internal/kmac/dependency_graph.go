@@ -0,0 +1,168 @@
+package kmac
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DependencyGraph is the directed graph of ID references between the
+// statements of a collection. An edge from A to B means A refers to B, so
+// removing B would leave A dangling. References to IDs outside the
+// collection, such as literal values, are not edges.
+type DependencyGraph struct {
+	references map[string][]string // Statement ID -> IDs it refers to
+	referrers  map[string][]string // Statement ID -> IDs of statements referring to it
+}
+
+// statementReferences returns the IDs a statement refers to
+func statementReferences(stmt Statement) []string {
+	switch s := stmt.(type) {
+	case *Assertion:
+		return []string{s.Subject(), s.Relation(), s.Object()}
+	case *NaryAssertion:
+		refs := []string{s.Relation()}
+		for _, arg := range s.Arguments() {
+			refs = append(refs, arg.Value)
+		}
+		return refs
+	case *PropertyAssertion:
+		return []string{s.Entity(), s.Property()}
+	case *Temporal:
+		return []string{s.AssertionID(), s.Timestamp()}
+	case *Causation:
+		return []string{s.SourceID(), s.TargetID()}
+	case *PartOf:
+		return []string{s.PartID(), s.WholeID()}
+	case *Participation:
+		return []string{s.EventID(), s.EntityID()}
+	case *StateAssertion:
+		return []string{s.EntityID()}
+	case *Task:
+		return []string{s.PlanID()}
+	case *Dependency:
+		return []string{s.TaskID(), s.DependsOnID()}
+	case *Situation:
+		return []string{s.ParentID()}
+	case *SituationMember:
+		return []string{s.AssertionID(), s.SituationID()}
+	case *Evidence:
+		return []string{s.AssertionID()}
+	}
+	return nil
+}
+
+// DependencyGraph builds the graph of references between the collection's
+// statements
+func (sc *StatementCollection) DependencyGraph() *DependencyGraph {
+	g := &DependencyGraph{
+		references: make(map[string][]string),
+		referrers:  make(map[string][]string),
+	}
+	for _, id := range sortedKeys(sc.statements) {
+		g.references[id] = nil
+	}
+	for _, id := range sortedKeys(sc.statements) {
+		seen := make(map[string]bool)
+		for _, ref := range statementReferences(sc.statements[id]) {
+			if _, exists := sc.statements[ref]; !exists || ref == id || seen[ref] {
+				continue
+			}
+			seen[ref] = true
+			g.references[id] = append(g.references[id], ref)
+			g.referrers[ref] = append(g.referrers[ref], id)
+		}
+		sort.Strings(g.references[id])
+	}
+	return g
+}
+
+// Nodes returns the IDs of the statements in the graph, in order
+func (g *DependencyGraph) Nodes() []string {
+	return sortedKeys(g.references)
+}
+
+// References returns the IDs a statement refers to directly, in order
+func (g *DependencyGraph) References(id string) []string {
+	return append([]string(nil), g.references[id]...)
+}
+
+// ReferencedBy returns the IDs of the statements referring to a statement
+// directly, in order
+func (g *DependencyGraph) ReferencedBy(id string) []string {
+	return append([]string(nil), g.referrers[id]...)
+}
+
+// Impact returns the IDs of every statement that depends on a statement,
+// directly or through others, in order: those left dangling if it were
+// removed alone
+func (g *DependencyGraph) Impact(id string) []string {
+	affected := make(map[string]bool)
+	queue := []string{id}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, referrer := range g.referrers[current] {
+			if !affected[referrer] && referrer != id {
+				affected[referrer] = true
+				queue = append(queue, referrer)
+			}
+		}
+	}
+	return sortedKeys(affected)
+}
+
+// DeletionOrder returns the statements to remove to delete the given ones
+// safely: each together with its impact, ordered so every statement comes
+// before the statements it refers to. Removing them in order never leaves a
+// reference dangling. It fails if the statements refer to each other in a
+// cycle, or an ID is not in the graph.
+func (g *DependencyGraph) DeletionOrder(ids ...string) ([]string, error) {
+	doomed := make(map[string]bool)
+	for _, id := range ids {
+		if _, exists := g.references[id]; !exists {
+			return nil, fmt.Errorf("statement %s not found", id)
+		}
+		doomed[id] = true
+		for _, affected := range g.Impact(id) {
+			doomed[affected] = true
+		}
+	}
+
+	// Kahn's algorithm over the doomed statements: a statement is ready once
+	// nothing else doomed still refers to it
+	waiting := make(map[string]int, len(doomed))
+	for id := range doomed {
+		for _, referrer := range g.referrers[id] {
+			if doomed[referrer] {
+				waiting[id]++
+			}
+		}
+	}
+	var ready []string
+	for _, id := range sortedKeys(doomed) {
+		if waiting[id] == 0 {
+			ready = append(ready, id)
+		}
+	}
+
+	var order []string
+	for len(ready) > 0 {
+		id := ready[0]
+		ready = ready[1:]
+		order = append(order, id)
+		for _, ref := range g.references[id] {
+			if !doomed[ref] {
+				continue
+			}
+			waiting[ref]--
+			if waiting[ref] == 0 {
+				ready = append(ready, ref)
+				sort.Strings(ready)
+			}
+		}
+	}
+	if len(order) != len(doomed) {
+		return nil, fmt.Errorf("statements refer to each other in a cycle")
+	}
+	return order, nil
+}
@@ -1,12 +1,14 @@
 package kmac
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"sort"
 	"strings"
 	"text/tabwriter"
+	"time"
 )
 
 // Disassembler is a tool for displaying and analyzing KMAC statements
@@ -14,13 +16,25 @@ type Disassembler struct {
 	writer        io.Writer
 	indentLevel   int
 	colorEnabled  bool
+	theme         *Theme
+	sources       *SourceRegistry
 	entityMap     map[string]*Entity
 	relationMap   map[string]*Relation
 	assertionMap  map[string]*Assertion
+	naryMap       map[string]*NaryAssertion
 	eventMap      map[string]*Event
 	timeMap       map[string]*TimeReference
 	partOfMap     map[string]*PartOf
 	temporalMap   map[string]*Temporal
+	participationMap map[string]*Participation
+	stateMap      map[string]*StateHistory
+	planMap       map[string]*Plan
+	taskMap       map[string]*Task
+	dependencyMap map[string]*Dependency
+	situationMap  map[string]*Situation
+	memberMap     map[string]*SituationMember
+	evidenceMap   map[string]*Evidence
+	causationMap  map[string]*Causation
 }
 
 // NewDisassembler creates a new KMAC disassembler
@@ -32,18 +46,44 @@ func NewDisassembler(writer io.Writer) *Disassembler {
 	return &Disassembler{
 		writer:       writer,
 		indentLevel:  0,
-		colorEnabled: true,
+		colorEnabled: ColorSupported(writer),
+		theme:        DefaultTheme,
+		sources:      DefaultSources(),
 		entityMap:    make(map[string]*Entity),
 		relationMap:  make(map[string]*Relation),
 		assertionMap: make(map[string]*Assertion),
+		naryMap:      make(map[string]*NaryAssertion),
 		eventMap:     make(map[string]*Event),
 		timeMap:      make(map[string]*TimeReference),
 		partOfMap:    make(map[string]*PartOf),
 		temporalMap:  make(map[string]*Temporal),
+		participationMap: make(map[string]*Participation),
+		stateMap:     make(map[string]*StateHistory),
+		planMap:      make(map[string]*Plan),
+		taskMap:      make(map[string]*Task),
+		dependencyMap: make(map[string]*Dependency),
+		situationMap:  make(map[string]*Situation),
+		memberMap:     make(map[string]*SituationMember),
+		evidenceMap:   make(map[string]*Evidence),
+		causationMap:  make(map[string]*Causation),
 	}
 }
 
-// SetColorEnabled enables or disables color output
+// StatementSource is anything holding statements, such as a semantic store
+type StatementSource interface {
+	Statements() []Statement
+}
+
+// NewDisassemblerFromStore creates a disassembler with every statement of a
+// store already registered
+func NewDisassemblerFromStore(store StatementSource, writer io.Writer) *Disassembler {
+	d := NewDisassembler(writer)
+	d.RegisterStatements(store.Statements())
+	return d
+}
+
+// SetColorEnabled enables or disables color output, overriding the
+// detection of terminals and NO_COLOR
 func (d *Disassembler) SetColorEnabled(enabled bool) {
 	d.colorEnabled = enabled
 }
@@ -63,6 +103,11 @@ func (d *Disassembler) RegisterAssertion(assertion *Assertion) {
 	d.assertionMap[assertion.ID()] = assertion
 }
 
+// RegisterNaryAssertion registers an n-ary assertion with the disassembler
+func (d *Disassembler) RegisterNaryAssertion(assertion *NaryAssertion) {
+	d.naryMap[assertion.ID()] = assertion
+}
+
 // RegisterEvent registers an event with the disassembler
 func (d *Disassembler) RegisterEvent(event *Event) {
 	d.eventMap[event.ID()] = event
@@ -83,6 +128,56 @@ func (d *Disassembler) RegisterTemporal(temporal *Temporal) {
 	d.temporalMap[temporal.AssertionID()] = temporal
 }
 
+// RegisterParticipation registers an event participation with the disassembler
+func (d *Disassembler) RegisterParticipation(participation *Participation) {
+	d.participationMap[participation.ID()] = participation
+}
+
+// RegisterStateAssertion registers an entity state assertion with the disassembler
+func (d *Disassembler) RegisterStateAssertion(state *StateAssertion) {
+	history, ok := d.stateMap[state.EntityID()]
+	if !ok {
+		history = NewStateHistory()
+		d.stateMap[state.EntityID()] = history
+	}
+	history.Add(state)
+}
+
+// RegisterPlan registers a plan with the disassembler
+func (d *Disassembler) RegisterPlan(plan *Plan) {
+	d.planMap[plan.ID()] = plan
+}
+
+// RegisterTask registers a task with the disassembler
+func (d *Disassembler) RegisterTask(task *Task) {
+	d.taskMap[task.ID()] = task
+}
+
+// RegisterDependency registers a task dependency with the disassembler
+func (d *Disassembler) RegisterDependency(dependency *Dependency) {
+	d.dependencyMap[dependency.ID()] = dependency
+}
+
+// RegisterSituation registers a situation with the disassembler
+func (d *Disassembler) RegisterSituation(situation *Situation) {
+	d.situationMap[situation.ID()] = situation
+}
+
+// RegisterSituationMember registers a situation membership with the disassembler
+func (d *Disassembler) RegisterSituationMember(member *SituationMember) {
+	d.memberMap[member.ID()] = member
+}
+
+// RegisterEvidence registers evidence with the disassembler
+func (d *Disassembler) RegisterEvidence(evidence *Evidence) {
+	d.evidenceMap[evidence.ID()] = evidence
+}
+
+// RegisterCausation registers a causal relationship with the disassembler
+func (d *Disassembler) RegisterCausation(causation *Causation) {
+	d.causationMap[causation.ID()] = causation
+}
+
 // RegisterStatement registers any KMAC statement with the disassembler
 func (d *Disassembler) RegisterStatement(stmt Statement) {
 	switch s := stmt.(type) {
@@ -92,6 +187,8 @@ func (d *Disassembler) RegisterStatement(stmt Statement) {
 		d.RegisterRelation(s)
 	case *Assertion:
 		d.RegisterAssertion(s)
+	case *NaryAssertion:
+		d.RegisterNaryAssertion(s)
 	case *Event:
 		d.RegisterEvent(s)
 	case *TimeReference:
@@ -100,6 +197,24 @@ func (d *Disassembler) RegisterStatement(stmt Statement) {
 		d.RegisterPartOf(s)
 	case *Temporal:
 		d.RegisterTemporal(s)
+	case *Participation:
+		d.RegisterParticipation(s)
+	case *StateAssertion:
+		d.RegisterStateAssertion(s)
+	case *Plan:
+		d.RegisterPlan(s)
+	case *Task:
+		d.RegisterTask(s)
+	case *Dependency:
+		d.RegisterDependency(s)
+	case *Situation:
+		d.RegisterSituation(s)
+	case *SituationMember:
+		d.RegisterSituationMember(s)
+	case *Evidence:
+		d.RegisterEvidence(s)
+	case *Causation:
+		d.RegisterCausation(s)
 	default:
 		fmt.Fprintf(d.writer, "Unknown statement type: %T\n", s)
 	}
@@ -121,18 +236,24 @@ func (d *Disassembler) DisassembleAssertion(assertionID string) {
 	}
 	
 	// Get subject entity
-	subject, subjectOk := d.entityMap[assertion.Subject()]
-	if !subjectOk {
-		subject, subjectOk = d.eventMap[assertion.Subject()]
+	var subject Statement
+	subjectE, subjectOk := d.entityMap[assertion.Subject()]
+	if subjectOk {
+		subject = subjectE
+	} else if ev, ok := d.eventMap[assertion.Subject()]; ok {
+		subject, subjectOk = ev, true
 	}
 	
 	// Get relation
 	relation, relationOk := d.relationMap[assertion.Relation()]
 	
 	// Get object entity
-	object, objectOk := d.entityMap[assertion.Object()]
-	if !objectOk {
-		object, objectOk = d.eventMap[assertion.Object()]
+	var object Statement
+	objectE, objectOk := d.entityMap[assertion.Object()]
+	if objectOk {
+		object = objectE
+	} else if ev, ok := d.eventMap[assertion.Object()]; ok {
+		object, objectOk = ev, true
 	}
 	
 	// Get confidence
@@ -142,16 +263,19 @@ func (d *Disassembler) DisassembleAssertion(assertionID string) {
 	temporal, temporalOk := d.temporalMap[assertion.ID()]
 	
 	// Print assertion header
-	fmt.Fprintf(d.writer, "ASSERTION #%s:\n", assertion.ID())
+	fmt.Fprintf(d.writer, "%s\n", d.heading(fmt.Sprintf("ASSERTION #%s:", assertion.ID())))
+	fmt.Fprintf(d.writer, "  DESCRIPTION: %s\n", assertion.Describe(d.Labeler()))
 	
 	// Print subject
 	fmt.Fprintf(d.writer, "  SUBJECT: ")
 	if subjectOk {
 		if subject.Type() == "DEF_ENTITY" {
-			fmt.Fprintf(d.writer, "#%s [%s] (Entity)\n", subject.ID(), subject.(*Entity).Label())
+			fmt.Fprintf(d.writer, "#%s [%s] (Entity)\n", subject.ID(), d.paintNode(subject.ID(), subject.(*Entity).Label()))
 		} else {
-			fmt.Fprintf(d.writer, "#%s [%s] (Event)\n", subject.ID(), subject.(*Event).Label())
+			fmt.Fprintf(d.writer, "#%s [%s] (Event)\n", subject.ID(), d.paintNode(subject.ID(), subject.(*Event).Label()))
 		}
+	} else if about, ok := d.assertionMap[assertion.Subject()]; ok {
+		fmt.Fprintf(d.writer, "#%s %s (Assertion)\n", about.ID(), d.assertionSummary(about))
 	} else {
 		fmt.Fprintf(d.writer, "#%s (Unknown)\n", assertion.Subject())
 	}
@@ -176,10 +300,12 @@ func (d *Disassembler) DisassembleAssertion(assertionID string) {
 	fmt.Fprintf(d.writer, "  OBJECT: ")
 	if objectOk {
 		if object.Type() == "DEF_ENTITY" {
-			fmt.Fprintf(d.writer, "#%s [%s] (Entity)\n", object.ID(), object.(*Entity).Label())
+			fmt.Fprintf(d.writer, "#%s [%s] (Entity)\n", object.ID(), d.paintNode(object.ID(), object.(*Entity).Label()))
 		} else {
-			fmt.Fprintf(d.writer, "#%s [%s] (Event)\n", object.ID(), object.(*Event).Label())
+			fmt.Fprintf(d.writer, "#%s [%s] (Event)\n", object.ID(), d.paintNode(object.ID(), object.(*Event).Label()))
 		}
+	} else if about, ok := d.assertionMap[assertion.Object()]; ok {
+		fmt.Fprintf(d.writer, "#%s %s (Assertion)\n", about.ID(), d.assertionSummary(about))
 	} else if strings.HasPrefix(assertion.Object(), "E") || strings.HasPrefix(assertion.Object(), "V") {
 		fmt.Fprintf(d.writer, "#%s (Unknown reference)\n", assertion.Object())
 	} else {
@@ -188,7 +314,7 @@ func (d *Disassembler) DisassembleAssertion(assertionID string) {
 	
 	// Print confidence if available
 	if confidence > 0 {
-		fmt.Fprintf(d.writer, "  CONFIDENCE: %.4f from [%s]\n", confidence, confidenceSource)
+		fmt.Fprintf(d.writer, "  CONFIDENCE: %s from [%s]\n", d.paintConfidence(confidence, fmt.Sprintf("%.4f", confidence)), confidenceSource)
 	}
 	
 	// Print temporal information if available
@@ -196,9 +322,152 @@ func (d *Disassembler) DisassembleAssertion(assertionID string) {
 		fmt.Fprintf(d.writer, "  TEMPORAL: %s timestamp=[%s]\n", temporal.State(), temporal.Timestamp())
 	}
 	
+	// Print assertions made about this assertion
+	if about := d.assertionsAbout(assertion.ID()); len(about) > 0 {
+		fmt.Fprintf(d.writer, "  STATEMENTS ABOUT THIS ASSERTION:\n")
+		for _, other := range about {
+			fmt.Fprintf(d.writer, "    #%s %s\n", other.ID(), d.assertionSummary(other))
+		}
+	}
+	
+	d.disassembleEvidence(assertion.ID())
+	d.disassembleMetadata(assertion)
 	fmt.Fprintln(d.writer)
 }
 
+// disassembleEvidence prints the evidence supporting an assertion, if any
+func (d *Disassembler) disassembleEvidence(assertionID string) {
+	var evidence []*Evidence
+	for _, id := range sortedKeys(d.evidenceMap) {
+		if d.evidenceMap[id].AssertionID() == assertionID {
+			evidence = append(evidence, d.evidenceMap[id])
+		}
+	}
+	if len(evidence) == 0 {
+		return
+	}
+	
+	fmt.Fprintf(d.writer, "  EVIDENCE:\n")
+	for _, e := range evidence {
+		line := "    #" + e.ID()
+		if citation := e.Citation(); citation != "" {
+			line += " " + citation
+		}
+		if e.Hash() != "" && e.Hash() != e.Citation() {
+			line += " (" + e.Hash() + ")"
+		}
+		if e.Excerpt() != "" {
+			line += fmt.Sprintf(" %q", e.Excerpt())
+		}
+		fmt.Fprintln(d.writer, line)
+	}
+}
+
+// DisassembleNaryAssertion disassembles a single n-ary assertion, resolving
+// the references of its arguments
+func (d *Disassembler) DisassembleNaryAssertion(assertionID string) {
+	assertion, ok := d.naryMap[assertionID]
+	if !ok {
+		fmt.Fprintf(d.writer, "Assertion %s not found\n", assertionID)
+		return
+	}
+	
+	fmt.Fprintf(d.writer, "%s\n", d.heading(fmt.Sprintf("N-ARY ASSERTION #%s:", assertion.ID())))
+	fmt.Fprintf(d.writer, "  DESCRIPTION: %s\n", assertion.Describe(d.Labeler()))
+	
+	fmt.Fprintf(d.writer, "  RELATION: ")
+	if relation, ok := d.relationMap[assertion.Relation()]; ok {
+		fmt.Fprintf(d.writer, "#%s [%s] type=[%s]\n", relation.ID(), relation.Label(), relation.RelationType())
+	} else {
+		fmt.Fprintf(d.writer, "#%s (Unknown)\n", assertion.Relation())
+	}
+	
+	fmt.Fprintf(d.writer, "  ARGUMENTS:\n")
+	for _, arg := range assertion.Arguments() {
+		fmt.Fprintf(d.writer, "    %s: %s\n", arg.Role, d.argumentDescription(arg.Value))
+	}
+	
+	if confidence, source := assertion.GetConfidence(); confidence > 0 {
+		fmt.Fprintf(d.writer, "  CONFIDENCE: %s from [%s]\n", d.paintConfidence(confidence, fmt.Sprintf("%.4f", confidence)), source)
+	}
+	
+	d.disassembleEvidence(assertion.ID())
+	d.disassembleMetadata(assertion)
+	fmt.Fprintln(d.writer)
+}
+
+// argumentDescription renders an n-ary argument as a resolved reference or a literal value
+func (d *Disassembler) argumentDescription(value string) string {
+	if entity, ok := d.entityMap[value]; ok {
+		return fmt.Sprintf("#%s [%s] (Entity)", value, d.paintNode(value, entity.Label()))
+	}
+	if event, ok := d.eventMap[value]; ok {
+		return fmt.Sprintf("#%s [%s] (Event)", value, d.paintNode(value, event.Label()))
+	}
+	if about, ok := d.assertionMap[value]; ok {
+		return fmt.Sprintf("#%s %s (Assertion)", value, d.assertionSummary(about))
+	}
+	if looksLikeNodeID(value) {
+		return fmt.Sprintf("#%s (Unknown reference)", value)
+	}
+	return fmt.Sprintf("%s (Literal value)", value)
+}
+
+// naryArgumentsOf returns the n-ary assertions with an argument filled by
+// the given ID, in ID order
+func (d *Disassembler) naryArgumentsOf(id string) []*NaryAssertion {
+	var results []*NaryAssertion
+	for _, assertionID := range sortedKeys(d.naryMap) {
+		assertion := d.naryMap[assertionID]
+		for _, arg := range assertion.Arguments() {
+			if arg.Value == id {
+				results = append(results, assertion)
+				break
+			}
+		}
+	}
+	return results
+}
+
+// assertionSummary renders an assertion as its subject, relation, and object labels
+func (d *Disassembler) assertionSummary(assertion *Assertion) string {
+	return fmt.Sprintf("[%s] [%s] [%s]", d.nodeLabel(assertion.Subject()), d.relationLabel(assertion.Relation()), d.nodeLabel(assertion.Object()))
+}
+
+// nodeLabel returns the label of an entity or event, or a reference to an assertion
+func (d *Disassembler) nodeLabel(id string) string {
+	if entity, ok := d.entityMap[id]; ok {
+		return entity.Label()
+	}
+	if event, ok := d.eventMap[id]; ok {
+		return event.Label()
+	}
+	if _, ok := d.assertionMap[id]; ok {
+		return "#" + id
+	}
+	return id
+}
+
+// relationLabel returns the label of a relation, or its ID if unknown
+func (d *Disassembler) relationLabel(id string) string {
+	if relation, ok := d.relationMap[id]; ok {
+		return relation.Label()
+	}
+	return id
+}
+
+// assertionsAbout returns the assertions whose subject or object is the given assertion, in ID order
+func (d *Disassembler) assertionsAbout(assertionID string) []*Assertion {
+	var about []*Assertion
+	for _, id := range sortedKeys(d.assertionMap) {
+		other := d.assertionMap[id]
+		if other.Subject() == assertionID || other.Object() == assertionID {
+			about = append(about, other)
+		}
+	}
+	return about
+}
+
 // DisassembleEntity disassembles a single entity, showing related assertions
 func (d *Disassembler) DisassembleEntity(entityID string) {
 	entity, ok := d.entityMap[entityID]
@@ -207,13 +476,14 @@ func (d *Disassembler) DisassembleEntity(entityID string) {
 		return
 	}
 	
-	fmt.Fprintf(d.writer, "ENTITY #%s [%s]\n", entity.ID(), entity.Label())
+	fmt.Fprintf(d.writer, "%s [%s]\n", d.heading("ENTITY #"+entity.ID()), d.paintDomain(entity.TOSIDType(), entity.Label()))
 	fmt.Fprintf(d.writer, "  TYPE: %s\n", entity.TOSIDType())
 	
 	// Find all assertions where this entity is the subject
 	fmt.Fprintf(d.writer, "  SUBJECT OF ASSERTIONS:\n")
 	foundSubject := false
-	for _, assertion := range d.assertionMap {
+	for _, id := range sortedKeys(d.assertionMap) {
+		assertion := d.assertionMap[id]
 		if assertion.Subject() == entityID {
 			foundSubject = true
 			relation, relationOk := d.relationMap[assertion.Relation()]
@@ -241,7 +511,8 @@ func (d *Disassembler) DisassembleEntity(entityID string) {
 	// Find all assertions where this entity is the object
 	fmt.Fprintf(d.writer, "  OBJECT OF ASSERTIONS:\n")
 	foundObject := false
-	for _, assertion := range d.assertionMap {
+	for _, id := range sortedKeys(d.assertionMap) {
+		assertion := d.assertionMap[id]
 		if assertion.Object() == entityID {
 			foundObject = true
 			relation, relationOk := d.relationMap[assertion.Relation()]
@@ -269,7 +540,8 @@ func (d *Disassembler) DisassembleEntity(entityID string) {
 	// Find part-of relationships
 	fmt.Fprintf(d.writer, "  PART-OF RELATIONSHIPS:\n")
 	foundPartOf := false
-	for _, partOf := range d.partOfMap {
+	for _, id := range sortedKeys(d.partOfMap) {
+		partOf := d.partOfMap[id]
 		if partOf.PartID() == entityID {
 			foundPartOf = true
 			wholeEntity, wholeOk := d.entityMap[partOf.WholeID()]
@@ -293,10 +565,40 @@ func (d *Disassembler) DisassembleEntity(entityID string) {
 		fmt.Fprintf(d.writer, "    None\n")
 	}
 	
+	// Print n-ary assertions the entity takes part in, if any
+	if assertions := d.naryArgumentsOf(entityID); len(assertions) > 0 {
+		fmt.Fprintf(d.writer, "  ARGUMENT OF N-ARY ASSERTIONS:\n")
+		for _, assertion := range assertions {
+			for _, arg := range assertion.Arguments() {
+				if arg.Value == entityID {
+					fmt.Fprintf(d.writer, "    #%s: %s as %s\n", assertion.ID(), d.relationLabel(assertion.Relation()), arg.Role)
+				}
+			}
+		}
+	}
+	
+	// Print event participations, if any
+	if participations := d.participationsWhere(func(p *Participation) bool { return p.EntityID() == entityID }); len(participations) > 0 {
+		fmt.Fprintf(d.writer, "  PARTICIPATES IN:\n")
+		for _, participation := range participations {
+			fmt.Fprintf(d.writer, "    #%s [%s] as %s\n", participation.EventID(), d.nodeLabel(participation.EventID()), participation.Role())
+		}
+	}
+	
+	// Print state history, if any
+	if history, ok := d.stateMap[entityID]; ok {
+		fmt.Fprintf(d.writer, "  STATE HISTORY:\n")
+		for _, attribute := range history.Attributes() {
+			for _, state := range history.History(attribute) {
+				fmt.Fprintf(d.writer, "    %s: %s at %s (#%s)\n", attribute, state.Value(), state.Timestamp().Format(time.RFC3339), state.ID())
+			}
+		}
+	}
+	
 	// Print properties
 	fmt.Fprintf(d.writer, "  PROPERTIES:\n")
 	foundProps := false
-	for key, _ := range entity.properties {
+	for _, key := range sortedKeys(entity.properties) {
 		foundProps = true
 		value, _ := entity.GetProperty(key)
 		fmt.Fprintf(d.writer, "    %s: %s\n", key, value)
@@ -305,9 +607,192 @@ func (d *Disassembler) DisassembleEntity(entityID string) {
 		fmt.Fprintf(d.writer, "    None\n")
 	}
 	
+	d.disassembleMetadata(entity)
+	fmt.Fprintln(d.writer)
+}
+
+// DisassembleEvent disassembles a single event, showing its participants by role
+func (d *Disassembler) DisassembleEvent(eventID string) {
+	event, ok := d.eventMap[eventID]
+	if !ok {
+		fmt.Fprintf(d.writer, "Event %s not found\n", eventID)
+		return
+	}
+	
+	fmt.Fprintf(d.writer, "%s [%s]\n", d.heading("EVENT #"+event.ID()), d.paintDomain(event.TOSIDType(), event.Label()))
+	fmt.Fprintf(d.writer, "  TOSID TYPE: %s\n", event.TOSIDType())
+	
+	// Group participants by role, built-in roles first
+	byRole := make(map[string][]*Participation)
+	for _, participation := range d.participationsWhere(func(p *Participation) bool { return p.EventID() == eventID }) {
+		byRole[participation.Role()] = append(byRole[participation.Role()], participation)
+	}
+	roles := append([]string{}, BuiltInRoles...)
+	for _, role := range sortedKeys(byRole) {
+		if !IsBuiltInRole(role) {
+			roles = append(roles, role)
+		}
+	}
+	
+	fmt.Fprintf(d.writer, "  PARTICIPANTS:\n")
+	if len(byRole) == 0 {
+		fmt.Fprintf(d.writer, "    None\n")
+	}
+	for _, role := range roles {
+		for _, participation := range byRole[role] {
+			fmt.Fprintf(d.writer, "    %s: #%s [%s]\n", role, participation.EntityID(), d.paintNode(participation.EntityID(), d.nodeLabel(participation.EntityID())))
+		}
+	}
+	
+	d.disassembleMetadata(event)
 	fmt.Fprintln(d.writer)
 }
 
+// DisassemblePlan disassembles a plan, showing its tasks in dependency order
+// with their earliest start times and the critical path
+func (d *Disassembler) DisassemblePlan(planID string) {
+	plan, ok := d.planMap[planID]
+	if !ok {
+		fmt.Fprintf(d.writer, "Plan %s not found\n", planID)
+		return
+	}
+	
+	fmt.Fprintf(d.writer, "%s [%s]\n", d.heading("PLAN #"+plan.ID()), plan.Label())
+	
+	var tasks []*Task
+	inPlan := make(map[string]bool)
+	for _, id := range sortedKeys(d.taskMap) {
+		if task := d.taskMap[id]; task.PlanID() == planID {
+			tasks = append(tasks, task)
+			inPlan[id] = true
+		}
+	}
+	var dependencies []*Dependency
+	for _, id := range sortedKeys(d.dependencyMap) {
+		if dep := d.dependencyMap[id]; inPlan[dep.TaskID()] {
+			dependencies = append(dependencies, dep)
+		}
+	}
+	
+	schedule, err := ScheduleTasks(tasks, dependencies)
+	if err != nil {
+		fmt.Fprintf(d.writer, "  ERROR: %v\n\n", err)
+		return
+	}
+	
+	fmt.Fprintf(d.writer, "  TASKS:\n")
+	if len(schedule.Tasks) == 0 {
+		fmt.Fprintf(d.writer, "    None\n")
+	}
+	for _, scheduled := range schedule.Tasks {
+		fmt.Fprintf(d.writer, "    #%s [%s] start=%s finish=%s\n",
+			scheduled.Task.ID(), scheduled.Task.Label(), scheduled.Start, scheduled.Finish)
+		for _, dep := range dependencies {
+			if dep.TaskID() == scheduled.Task.ID() {
+				fmt.Fprintf(d.writer, "      after #%s [%s]\n", dep.DependsOnID(), d.taskMap[dep.DependsOnID()].Label())
+			}
+		}
+	}
+	
+	fmt.Fprintf(d.writer, "  CRITICAL PATH (%s):\n", schedule.Duration)
+	for _, task := range schedule.CriticalPath {
+		fmt.Fprintf(d.writer, "    #%s [%s]\n", task.ID(), task.Label())
+	}
+	
+	d.disassembleMetadata(plan)
+	fmt.Fprintln(d.writer)
+}
+
+// DisassembleSituation disassembles a situation, showing the assertions
+// describing it and the situations within it, with theirs
+func (d *Disassembler) DisassembleSituation(situationID string) {
+	situation, ok := d.situationMap[situationID]
+	if !ok {
+		fmt.Fprintf(d.writer, "Situation %s not found\n", situationID)
+		return
+	}
+	
+	fmt.Fprintf(d.writer, "%s [%s]\n", d.heading("SITUATION #"+situation.ID()), situation.Label())
+	if parent, ok := d.situationMap[situation.ParentID()]; ok {
+		fmt.Fprintf(d.writer, "  WITHIN: #%s [%s]\n", parent.ID(), parent.Label())
+	} else if situation.ParentID() != "" {
+		fmt.Fprintf(d.writer, "  WITHIN: #%s (Unknown)\n", situation.ParentID())
+	}
+	
+	fmt.Fprintf(d.writer, "  CONTENTS:\n")
+	if !d.disassembleSituationContents(situationID, 2, make(map[string]bool)) {
+		fmt.Fprintf(d.writer, "    None\n")
+	}
+	
+	d.disassembleMetadata(situation)
+	fmt.Fprintln(d.writer)
+}
+
+// disassembleSituationContents prints the assertions of a situation, then
+// each situation within it with its own, indented. It reports whether it
+// printed anything.
+func (d *Disassembler) disassembleSituationContents(situationID string, depth int, seen map[string]bool) bool {
+	if seen[situationID] {
+		return false
+	}
+	seen[situationID] = true
+	
+	indent := strings.Repeat("  ", depth)
+	printed := false
+	for _, id := range sortedKeys(d.memberMap) {
+		member := d.memberMap[id]
+		if member.SituationID() != situationID {
+			continue
+		}
+		printed = true
+		if assertion, ok := d.assertionMap[member.AssertionID()]; ok {
+			fmt.Fprintf(d.writer, "%s#%s %s\n", indent, assertion.ID(), d.assertionSummary(assertion))
+		} else if assertion, ok := d.naryMap[member.AssertionID()]; ok {
+			fmt.Fprintf(d.writer, "%s#%s %s\n", indent, assertion.ID(), assertion.Describe(d.Labeler()))
+		} else {
+			fmt.Fprintf(d.writer, "%s#%s (Unknown)\n", indent, member.AssertionID())
+		}
+	}
+	for _, id := range sortedKeys(d.situationMap) {
+		if child := d.situationMap[id]; child.ParentID() == situationID {
+			printed = true
+			fmt.Fprintf(d.writer, "%sSITUATION #%s [%s]:\n", indent, child.ID(), child.Label())
+			d.disassembleSituationContents(child.ID(), depth+1, seen)
+		}
+	}
+	return printed
+}
+
+// disassembleMetadata prints a statement's tags, annotations, and notes, if it has any
+func (d *Disassembler) disassembleMetadata(stmt Annotated) {
+	if tags := stmt.Tags(); len(tags) > 0 {
+		fmt.Fprintf(d.writer, "  TAGS: %s\n", strings.Join(tags, ", "))
+	}
+	if annotations := stmt.Annotations(); len(annotations) > 0 {
+		fmt.Fprintf(d.writer, "  ANNOTATIONS:\n")
+		for _, key := range sortedKeys(annotations) {
+			fmt.Fprintf(d.writer, "    %s: %s\n", key, annotations[key])
+		}
+	}
+	if notes := stmt.Notes(); len(notes) > 0 {
+		fmt.Fprintf(d.writer, "  NOTES:\n")
+		for _, note := range notes {
+			fmt.Fprintf(d.writer, "    %s\n", note)
+		}
+	}
+}
+
+// participationsWhere returns the registered participations matching a predicate, in ID order
+func (d *Disassembler) participationsWhere(match func(*Participation) bool) []*Participation {
+	var results []*Participation
+	for _, id := range sortedKeys(d.participationMap) {
+		if participation := d.participationMap[id]; match(participation) {
+			results = append(results, participation)
+		}
+	}
+	return results
+}
+
 // DisassembleEntityHierarchy displays the part-of hierarchy for a given entity
 func (d *Disassembler) DisassembleEntityHierarchy(rootID string) {
 	entity, ok := d.entityMap[rootID]
@@ -331,10 +816,11 @@ func (d *Disassembler) disassembleEntityHierarchyRecursive(entityID string, dept
 	}
 	
 	indent := strings.Repeat("  ", depth)
-	fmt.Fprintf(d.writer, "%s#%s [%s] type=[%s]\n", indent, entity.ID(), entity.Label(), entity.TOSIDType())
+	fmt.Fprintf(d.writer, "%s#%s [%s] type=[%s]\n", indent, entity.ID(), d.paintDomain(entity.TOSIDType(), entity.Label()), entity.TOSIDType())
 	
 	// Find parts of this entity
-	for _, partOf := range d.partOfMap {
+	for _, id := range sortedKeys(d.partOfMap) {
+		partOf := d.partOfMap[id]
 		if partOf.WholeID() == entityID {
 			d.disassembleEntityHierarchyRecursive(partOf.PartID(), depth+1)
 		}
@@ -343,14 +829,14 @@ func (d *Disassembler) disassembleEntityHierarchyRecursive(entityID string, dept
 
 // DisassembleKnowledgeGraph disassembles knowledge statements as a graph
 func (d *Disassembler) DisassembleKnowledgeGraph() {
-	fmt.Fprintln(d.writer, "KMAC KNOWLEDGE GRAPH")
+	fmt.Fprintln(d.writer, d.heading("KMAC KNOWLEDGE GRAPH"))
 	fmt.Fprintln(d.writer, "==================")
 	
 	// Create table writer for formatted output
 	w := tabwriter.NewWriter(d.writer, 0, 0, 2, ' ', 0)
 	
 	// List all entities
-	fmt.Fprintln(w, "\nENTITIES:")
+	fmt.Fprintln(w, "\n"+d.heading("ENTITIES:"))
 	fmt.Fprintln(w, "ID\tLABEL\tTOSID TYPE")
 	fmt.Fprintln(w, "--\t-----\t---------")
 	var entityIDs []string
@@ -360,11 +846,11 @@ func (d *Disassembler) DisassembleKnowledgeGraph() {
 	sort.Strings(entityIDs)
 	for _, id := range entityIDs {
 		entity := d.entityMap[id]
-		fmt.Fprintf(w, "#%s\t%s\t%s\n", entity.ID(), entity.Label(), entity.TOSIDType())
+		fmt.Fprintf(w, "#%s\t%s\t%s\n", entity.ID(), entity.Label(), d.paintDomain(entity.TOSIDType(), entity.TOSIDType()))
 	}
 	
 	// List all events
-	fmt.Fprintln(w, "\nEVENTS:")
+	fmt.Fprintln(w, "\n"+d.heading("EVENTS:"))
 	fmt.Fprintln(w, "ID\tLABEL\tTOSID TYPE")
 	fmt.Fprintln(w, "--\t-----\t---------")
 	var eventIDs []string
@@ -374,11 +860,11 @@ func (d *Disassembler) DisassembleKnowledgeGraph() {
 	sort.Strings(eventIDs)
 	for _, id := range eventIDs {
 		event := d.eventMap[id]
-		fmt.Fprintf(w, "#%s\t%s\t%s\n", event.ID(), event.Label(), event.TOSIDType())
+		fmt.Fprintf(w, "#%s\t%s\t%s\n", event.ID(), event.Label(), d.paintDomain(event.TOSIDType(), event.TOSIDType()))
 	}
 	
 	// List all relations
-	fmt.Fprintln(w, "\nRELATIONS:")
+	fmt.Fprintln(w, "\n"+d.heading("RELATIONS:"))
 	fmt.Fprintln(w, "ID\tLABEL\tRELATION TYPE")
 	fmt.Fprintln(w, "--\t-----\t-------------")
 	var relationIDs []string
@@ -392,7 +878,7 @@ func (d *Disassembler) DisassembleKnowledgeGraph() {
 	}
 	
 	// List all assertions
-	fmt.Fprintln(w, "\nASSERTIONS:")
+	fmt.Fprintln(w, "\n"+d.heading("ASSERTIONS:"))
 	fmt.Fprintln(w, "ID\tSUBJECT\tRELATION\tOBJECT\tCONFIDENCE")
 	fmt.Fprintln(w, "--\t-------\t--------\t------\t----------")
 	var assertionIDs []string
@@ -408,6 +894,8 @@ func (d *Disassembler) DisassembleKnowledgeGraph() {
 			subjectLabel = subject.Label()
 		} else if subject, ok := d.eventMap[assertion.Subject()]; ok {
 			subjectLabel = subject.Label()
+		} else if _, ok := d.assertionMap[assertion.Subject()]; ok {
+			subjectLabel = "#" + assertion.Subject()
 		}
 		
 		relationLabel := assertion.Relation()
@@ -420,23 +908,46 @@ func (d *Disassembler) DisassembleKnowledgeGraph() {
 			objectLabel = object.Label()
 		} else if object, ok := d.eventMap[assertion.Object()]; ok {
 			objectLabel = object.Label()
+		} else if _, ok := d.assertionMap[assertion.Object()]; ok {
+			objectLabel = "#" + assertion.Object()
 		}
 		
 		confidence, source := assertion.GetConfidence()
 		confidenceStr := "-"
 		if confidence > 0 {
-			confidenceStr = fmt.Sprintf("%.4f (%s)", confidence, source)
+			confidenceStr = d.paintConfidence(confidence, fmt.Sprintf("%.4f (%s)", confidence, source))
 		}
 		
 		fmt.Fprintf(w, "#%s\t%s\t%s\t%s\t%s\n", 
 			assertion.ID(), subjectLabel, relationLabel, objectLabel, confidenceStr)
 	}
 	
+	// List all n-ary assertions, if any
+	if len(d.naryMap) > 0 {
+		fmt.Fprintln(w, "\n"+d.heading("N-ARY ASSERTIONS:"))
+		fmt.Fprintln(w, "ID\tRELATION\tARGUMENTS\tCONFIDENCE")
+		fmt.Fprintln(w, "--\t--------\t---------\t----------")
+		for _, id := range sortedKeys(d.naryMap) {
+			assertion := d.naryMap[id]
+			arguments := make([]string, 0, assertion.Arity())
+			for _, arg := range assertion.Arguments() {
+				arguments = append(arguments, arg.Role+"="+d.nodeLabel(arg.Value))
+			}
+			confidence, source := assertion.GetConfidence()
+			confidenceStr := "-"
+			if confidence > 0 {
+				confidenceStr = d.paintConfidence(confidence, fmt.Sprintf("%.4f (%s)", confidence, source))
+			}
+			fmt.Fprintf(w, "#%s\t%s\t%s\t%s\n", id, d.relationLabel(assertion.Relation()), strings.Join(arguments, ", "), confidenceStr)
+		}
+	}
+	
 	// List all part-of relationships
-	fmt.Fprintln(w, "\nPART-WHOLE RELATIONSHIPS:")
+	fmt.Fprintln(w, "\n"+d.heading("PART-WHOLE RELATIONSHIPS:"))
 	fmt.Fprintln(w, "PART\tWHOLE")
 	fmt.Fprintln(w, "----\t-----")
-	for _, partOf := range d.partOfMap {
+	for _, id := range sortedKeys(d.partOfMap) {
+		partOf := d.partOfMap[id]
 		partLabel := partOf.PartID()
 		if part, ok := d.entityMap[partOf.PartID()]; ok {
 			partLabel = part.Label()
@@ -471,6 +982,9 @@ func (d *Disassembler) DisassembleAll() {
 	for _, id := range assertionIDs {
 		d.DisassembleAssertion(id)
 	}
+	for _, id := range sortedKeys(d.naryMap) {
+		d.DisassembleNaryAssertion(id)
+	}
 	
 	// Then show detailed disassembly of each entity
 	fmt.Fprintln(d.writer, "DETAILED ENTITY DISASSEMBLY")
@@ -485,4 +999,141 @@ func (d *Disassembler) DisassembleAll() {
 	for _, id := range entityIDs {
 		d.DisassembleEntity(id)
 	}
+	
+	// Then show detailed disassembly of each event
+	if len(d.eventMap) > 0 {
+		fmt.Fprintln(d.writer, "DETAILED EVENT DISASSEMBLY")
+		fmt.Fprintln(d.writer, "=========================")
+		
+		for _, id := range sortedKeys(d.eventMap) {
+			d.DisassembleEvent(id)
+		}
+	}
+	
+	// Then show each top-level situation, with those within it
+	if len(d.situationMap) > 0 {
+		fmt.Fprintln(d.writer, "DETAILED SITUATION DISASSEMBLY")
+		fmt.Fprintln(d.writer, "=============================")
+		
+		for _, id := range sortedKeys(d.situationMap) {
+			if situation := d.situationMap[id]; d.situationMap[situation.ParentID()] == nil {
+				d.DisassembleSituation(id)
+			}
+		}
+	}
+	
+	// Then show each plan's schedule
+	if len(d.planMap) > 0 {
+		fmt.Fprintln(d.writer, "DETAILED PLAN DISASSEMBLY")
+		fmt.Fprintln(d.writer, "========================")
+		
+		for _, id := range sortedKeys(d.planMap) {
+			d.DisassemblePlan(id)
+		}
+	}
+	
+	// Finally, index where each entity and event is mentioned
+	d.DisassembleCrossReference()
+}
+
+// Statements returns all registered statements, definitions before the
+// statements that refer to them, each kind sorted by ID
+func (d *Disassembler) Statements() []Statement {
+	var statements []Statement
+	for _, id := range sortedKeys(d.relationMap) {
+		statements = append(statements, d.relationMap[id])
+	}
+	for _, id := range sortedKeys(d.entityMap) {
+		statements = append(statements, d.entityMap[id])
+	}
+	for _, id := range sortedKeys(d.eventMap) {
+		statements = append(statements, d.eventMap[id])
+	}
+	for _, id := range sortedKeys(d.timeMap) {
+		statements = append(statements, d.timeMap[id])
+	}
+	for _, id := range sortedKeys(d.assertionMap) {
+		statements = append(statements, d.assertionMap[id])
+	}
+	for _, id := range sortedKeys(d.naryMap) {
+		statements = append(statements, d.naryMap[id])
+	}
+	for _, id := range sortedKeys(d.temporalMap) {
+		statements = append(statements, d.temporalMap[id])
+	}
+	for _, id := range sortedKeys(d.partOfMap) {
+		statements = append(statements, d.partOfMap[id])
+	}
+	for _, id := range sortedKeys(d.participationMap) {
+		statements = append(statements, d.participationMap[id])
+	}
+	for _, id := range sortedKeys(d.stateMap) {
+		history := d.stateMap[id]
+		for _, attribute := range history.Attributes() {
+			for _, state := range history.History(attribute) {
+				statements = append(statements, state)
+			}
+		}
+	}
+	for _, id := range sortedKeys(d.planMap) {
+		statements = append(statements, d.planMap[id])
+	}
+	for _, id := range sortedKeys(d.taskMap) {
+		statements = append(statements, d.taskMap[id])
+	}
+	for _, id := range sortedKeys(d.dependencyMap) {
+		statements = append(statements, d.dependencyMap[id])
+	}
+	for _, situation := range d.situationsParentsFirst() {
+		statements = append(statements, situation)
+	}
+	for _, id := range sortedKeys(d.memberMap) {
+		statements = append(statements, d.memberMap[id])
+	}
+	for _, id := range sortedKeys(d.evidenceMap) {
+		statements = append(statements, d.evidenceMap[id])
+	}
+	for _, id := range sortedKeys(d.causationMap) {
+		statements = append(statements, d.causationMap[id])
+	}
+	return statements
+}
+
+// situationsParentsFirst returns the registered situations in ID order,
+// except that each follows the situation it is within
+func (d *Disassembler) situationsParentsFirst() []*Situation {
+	var ordered []*Situation
+	placed := make(map[string]bool)
+	var place func(situation *Situation)
+	place = func(situation *Situation) {
+		if placed[situation.ID()] {
+			return
+		}
+		placed[situation.ID()] = true
+		if parent, ok := d.situationMap[situation.ParentID()]; ok {
+			place(parent)
+		}
+		ordered = append(ordered, situation)
+	}
+	for _, id := range sortedKeys(d.situationMap) {
+		place(d.situationMap[id])
+	}
+	return ordered
+}
+
+// Records returns the structured form of all registered statements, which
+// an Assembler turns back into statements
+func (d *Disassembler) Records() ([]Record, error) {
+	return RecordsFor(d.Statements())
+}
+
+// DisassembleJSON writes all registered statements as a JSON array of records
+func (d *Disassembler) DisassembleJSON() error {
+	records, err := d.Records()
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(d.writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(records)
 }
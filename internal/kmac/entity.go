@@ -11,6 +11,7 @@ type Entity struct {
 	label      string
 	tosidType  string
 	properties map[string]string
+	Metadata
 }
 
 // NewEntity creates a new KMAC entity
@@ -27,7 +28,6 @@ func NewEntity(id string, label string, tosidType string) (*Entity, error) {
 		id:         id,
 		label:      label,
 		tosidType:  tosidType,
-		properties: make(map[string]string),
 	}, nil
 }
 
@@ -53,6 +53,9 @@ func (e *Entity) TOSIDType() string {
 
 // SetProperty sets a property on the entity
 func (e *Entity) SetProperty(key, value string) {
+	if e.properties == nil {
+		e.properties = make(map[string]string)
+	}
 	e.properties[key] = value
 }
 
@@ -94,11 +97,25 @@ func (e *Entity) PropertiesString() string {
 	}
 	
 	result := ""
-	for key, value := range e.properties {
+	for _, key := range sortedKeys(e.properties) {
+		value := e.properties[key]
 		if result != "" {
 			result += "\n"
 		}
 		result += fmt.Sprintf("PROPERTY #%s [%s] value=[%s]", e.id, key, value)
 	}
 	return result
-}
\ No newline at end of file
+}
+
+// Clone returns an independent copy of the entity
+func (e *Entity) Clone() *Entity {
+	c := *e
+	if e.properties != nil {
+		c.properties = make(map[string]string, len(e.properties))
+		for k, v := range e.properties {
+			c.properties[k] = v
+		}
+	}
+	c.Metadata = e.Metadata.clone()
+	return &c
+}
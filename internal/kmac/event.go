@@ -12,6 +12,7 @@ type Event struct {
 	label    string
 	tosidType string
 	properties map[string]string
+	Metadata
 }
 
 // NewEvent creates a new KMAC event
@@ -28,7 +29,6 @@ func NewEvent(id string, label string, tosidType string) (*Event, error) {
 		id:       id,
 		label:    label,
 		tosidType: tosidType,
-		properties: make(map[string]string),
 	}, nil
 }
 
@@ -54,6 +54,9 @@ func (e *Event) TOSIDType() string {
 
 // SetProperty sets a property on the event
 func (e *Event) SetProperty(key, value string) {
+	if e.properties == nil {
+		e.properties = make(map[string]string)
+	}
 	e.properties[key] = value
 }
 
@@ -68,11 +71,20 @@ func (e *Event) String() string {
 	return fmt.Sprintf("DEF_EVENT #%s [%s] type=[%s]", e.id, e.label, e.tosidType)
 }
 
-// TimeReference represents a KMAC time definition
+// TimeReference represents a KMAC time definition: an instant, an interval
+// from value to end (or lasting duration), or a recurring window when a
+// recurrence rule is set
 type TimeReference struct {
-	id       string
-	timeType string
-	value    time.Time
+	id         string
+	timeType   string
+	value      time.Time
+	end        *time.Time
+	duration   time.Duration
+	recurrence *Recurrence
+	approx     *ApproximateTime // Set when the time is only known approximately
+	scale      string           // Time scale the value is written in; empty for UTC
+	epoch      *time.Time       // Epoch of a mission elapsed time
+	Metadata
 }
 
 // NewTimeReference creates a new KMAC time reference
@@ -114,58 +126,29 @@ func (t *TimeReference) Value() time.Time {
 
 // String returns a string representation of the time reference in KMAC format
 func (t *TimeReference) String() string {
-	return fmt.Sprintf("DEF_TIME #%s type=[%s] value=[%s]", 
-		t.id, t.timeType, t.value.Format(time.RFC3339))
-}
-
-// Temporal represents a KMAC temporal qualification
-type Temporal struct {
-	assertionID string
-	state       string
-	timestamp   string
-}
-
-// NewTemporal creates a new KMAC temporal qualification
-func NewTemporal(assertionID string, state string, timestamp string) (*Temporal, error) {
-	if assertionID == "" {
-		return nil, errors.New("assertion ID cannot be empty")
+	value := t.value.Format(time.RFC3339)
+	if t.approx != nil {
+		value = t.approx.String()
+	} else if t.scale != "" {
+		value = t.ScaleValue()
 	}
-
-	if !validateIdentifier(AssertionIDPrefix, assertionID) {
-		return nil, fmt.Errorf("invalid assertion ID format: %s", assertionID)
+	base := fmt.Sprintf("DEF_TIME #%s type=[%s] value=[%s]", 
+		t.id, t.timeType, value)
+	if t.scale != "" {
+		base += fmt.Sprintf(" scale=[%s]", t.scale)
 	}
-
-	return &Temporal{
-		assertionID: assertionID,
-		state:       state,
-		timestamp:   timestamp,
-	}, nil
-}
-
-// AssertionID returns the associated assertion's identifier
-func (t *Temporal) AssertionID() string {
-	return t.assertionID
-}
-
-// Type returns the statement type
-func (t *Temporal) Type() string {
-	return "TEMPORAL"
-}
-
-// State returns the temporal state
-func (t *Temporal) State() string {
-	return t.state
-}
-
-// Timestamp returns the timestamp reference
-func (t *Temporal) Timestamp() string {
-	return t.timestamp
-}
-
-// String returns a string representation of the temporal qualification in KMAC format
-func (t *Temporal) String() string {
-	return fmt.Sprintf("TEMPORAL #%s state=[%s] timestamp=[%s]", 
-		t.assertionID, t.state, t.timestamp)
+	if t.epoch != nil {
+		base += fmt.Sprintf(" epoch=[%s]", t.epoch.Format(time.RFC3339))
+	}
+	if t.end != nil {
+		base += fmt.Sprintf(" end=[%s]", t.end.Format(time.RFC3339))
+	} else if t.duration != 0 {
+		base += fmt.Sprintf(" duration=[%s]", t.duration)
+	}
+	if t.recurrence != nil {
+		base += fmt.Sprintf(" rrule=[%s]", t.recurrence)
+	}
+	return base
 }
 
 // PartOf represents a KMAC part-whole relationship
@@ -210,3 +193,10 @@ func (p *PartOf) ID() string {
 func (p *PartOf) String() string {
 	return fmt.Sprintf("PART_OF #%s whole=[#%s]", p.partID, p.wholeID)
 }
+
+// Clone returns an independent copy of the time reference
+func (t *TimeReference) Clone() *TimeReference {
+	c := *t
+	c.Metadata = t.Metadata.clone()
+	return &c
+}
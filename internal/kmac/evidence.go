@@ -0,0 +1,146 @@
+package kmac
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Evidence links an assertion to an external artifact supporting it, such
+// as a publication, a web page, or an observation log, so the claim can be
+// traced to where it came from. The artifact is identified by any of a URL,
+// a DOI, and a content hash, and an excerpt may quote the relevant passage.
+type Evidence struct {
+	id          string
+	assertionID string
+	url         string
+	doi         string
+	hash        string
+	excerpt     string
+	Metadata
+}
+
+// NewEvidence creates a new KMAC evidence statement for an assertion. Set at
+// least one of the URL, DOI, hash, and excerpt before using it.
+func NewEvidence(id string, assertionID string) (*Evidence, error) {
+	if id == "" {
+		return nil, errors.New("evidence ID cannot be empty")
+	}
+
+	if !validateIdentifier(EvidenceIDPrefix, id) {
+		return nil, fmt.Errorf("invalid evidence ID format: %s", id)
+	}
+
+	if !validateIdentifier(AssertionIDPrefix, assertionID) {
+		return nil, fmt.Errorf("invalid assertion ID format: %s", assertionID)
+	}
+
+	return &Evidence{
+		id:          id,
+		assertionID: assertionID,
+	}, nil
+}
+
+// ID returns the evidence's identifier
+func (e *Evidence) ID() string {
+	return e.id
+}
+
+// Type returns the statement type
+func (e *Evidence) Type() string {
+	return "EVIDENCE"
+}
+
+// AssertionID returns the identifier of the assertion the evidence supports
+func (e *Evidence) AssertionID() string {
+	return e.assertionID
+}
+
+// URL returns the address of the artifact
+func (e *Evidence) URL() string {
+	return e.url
+}
+
+// SetURL sets the address of the artifact
+func (e *Evidence) SetURL(url string) {
+	e.url = url
+}
+
+// DOI returns the digital object identifier of the artifact
+func (e *Evidence) DOI() string {
+	return e.doi
+}
+
+// SetDOI sets the digital object identifier of the artifact, such as
+// 10.1038/nature12345. A "doi:" or https://doi.org/ prefix is dropped.
+func (e *Evidence) SetDOI(doi string) {
+	doi = strings.TrimPrefix(doi, "doi:")
+	doi = strings.TrimPrefix(doi, "https://doi.org/")
+	e.doi = doi
+}
+
+// Hash returns the content hash of the artifact
+func (e *Evidence) Hash() string {
+	return e.hash
+}
+
+// SetHash sets the content hash of the artifact, written as the algorithm
+// and the hex digest, e.g. sha256:9f86d08...
+func (e *Evidence) SetHash(hash string) {
+	e.hash = hash
+}
+
+// Excerpt returns the quoted passage of the artifact
+func (e *Evidence) Excerpt() string {
+	return e.excerpt
+}
+
+// SetExcerpt sets the quoted passage of the artifact
+func (e *Evidence) SetExcerpt(excerpt string) {
+	e.excerpt = excerpt
+}
+
+// Citation returns the most durable reference to the artifact: its DOI, URL,
+// or hash, in that order of preference, or "" if it has none
+func (e *Evidence) Citation() string {
+	switch {
+	case e.doi != "":
+		return "doi:" + e.doi
+	case e.url != "":
+		return e.url
+	default:
+		return e.hash
+	}
+}
+
+// validateArtifact checks that the DOI and hash, if given, are well formed
+func (e *Evidence) validateArtifact() error {
+	if e.doi != "" && !strings.HasPrefix(e.doi, "10.") {
+		return fmt.Errorf("invalid DOI %s", e.doi)
+	}
+	if algorithm, digest, ok := strings.Cut(e.hash, ":"); e.hash != "" && (!ok || algorithm == "" || digest == "") {
+		return fmt.Errorf("hash %s is not written as algorithm:digest", e.hash)
+	}
+	return nil
+}
+
+// String returns a string representation of the evidence in KMAC format
+func (e *Evidence) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "EVIDENCE #%s assertion=[#%s]", e.id, e.assertionID)
+	for _, field := range []struct{ name, value string }{
+		{"url", e.url}, {"doi", e.doi}, {"hash", e.hash}, {"excerpt", e.excerpt},
+	} {
+		if field.value != "" {
+			fmt.Fprintf(&sb, " %s=[%s]", field.name, escapeValue(field.value))
+		}
+	}
+	return sb.String()
+}
+
+// Clone returns an independent copy of the evidence
+func (e *Evidence) Clone() *Evidence {
+	c := *e
+	c.Metadata = e.Metadata.clone()
+	return &c
+}
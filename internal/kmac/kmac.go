@@ -1,5 +1,10 @@
 package kmac
 
+import (
+	"errors"
+	"fmt"
+)
+
 // Identifier types
 const (
 	EntityIDPrefix    = "E"
@@ -8,6 +13,10 @@ const (
 	PropertyIDPrefix  = "P"
 	TimeIDPrefix      = "T"
 	AssertionIDPrefix = "F"
+	PlanIDPrefix      = "L"
+	TaskIDPrefix      = "K"
+	SituationIDPrefix = "S"
+	EvidenceIDPrefix  = "D"
 )
 
 // Statement represents a KMAC statement
@@ -35,12 +44,34 @@ func ValidateKMACStatement(statement Statement) error {
 	switch stmt := statement.(type) {
 	case *Entity:
 		return validateEntity(stmt)
+	case *Event:
+		return validateEvent(stmt)
 	case *Relation:
 		return validateRelation(stmt)
 	case *Assertion:
 		return validateAssertion(stmt)
+	case *NaryAssertion:
+		return validateNaryAssertion(stmt)
 	case *Property:
 		return validateProperty(stmt)
+	case *Participation:
+		return validateParticipation(stmt)
+	case *Causation:
+		return validateCausation(stmt)
+	case *StateAssertion:
+		return validateStateAssertion(stmt)
+	case *Plan:
+		return validatePlan(stmt)
+	case *Task:
+		return validateTask(stmt)
+	case *Dependency:
+		return validateDependency(stmt)
+	case *Situation:
+		return validateSituation(stmt)
+	case *SituationMember:
+		return validateSituationMember(stmt)
+	case *Evidence:
+		return validateEvidence(stmt)
 	default:
 		return fmt.Errorf("unknown statement type: %T", statement)
 	}
@@ -56,6 +87,16 @@ func validateEntity(entity *Entity) error {
 	return nil
 }
 
+func validateEvent(event *Event) error {
+	if event.ID() == "" {
+		return errors.New("event ID cannot be empty")
+	}
+	if event.Label() == "" {
+		return errors.New("event label cannot be empty")
+	}
+	return nil
+}
+
 func validateRelation(relation *Relation) error {
 	if relation.ID() == "" {
 		return errors.New("relation ID cannot be empty")
@@ -85,6 +126,118 @@ func validateAssertion(assertion *Assertion) error {
 	return nil
 }
 
+func validateNaryAssertion(assertion *NaryAssertion) error {
+	if assertion.ID() == "" {
+		return errors.New("assertion ID cannot be empty")
+	}
+	if assertion.Relation() == "" {
+		return errors.New("assertion relation cannot be empty")
+	}
+	if assertion.Arity() < 2 {
+		return errors.New("n-ary assertion needs at least two arguments")
+	}
+	return nil
+}
+
+func validateSituation(situation *Situation) error {
+	if situation.ID() == "" {
+		return errors.New("situation ID cannot be empty")
+	}
+	if situation.Label() == "" {
+		return errors.New("situation label cannot be empty")
+	}
+	return nil
+}
+
+func validateSituationMember(member *SituationMember) error {
+	if member.AssertionID() == "" {
+		return errors.New("situation member assertion cannot be empty")
+	}
+	if member.SituationID() == "" {
+		return errors.New("situation member situation cannot be empty")
+	}
+	return nil
+}
+
+func validateEvidence(evidence *Evidence) error {
+	if evidence.AssertionID() == "" {
+		return errors.New("evidence assertion cannot be empty")
+	}
+	if evidence.URL() == "" && evidence.DOI() == "" && evidence.Hash() == "" && evidence.Excerpt() == "" {
+		return errors.New("evidence needs a URL, DOI, hash, or excerpt")
+	}
+	return evidence.validateArtifact()
+}
+
+func validateParticipation(participation *Participation) error {
+	if participation.EventID() == "" {
+		return errors.New("participation event cannot be empty")
+	}
+	if participation.Role() == "" {
+		return errors.New("participation role cannot be empty")
+	}
+	if participation.EntityID() == "" {
+		return errors.New("participation entity cannot be empty")
+	}
+	return nil
+}
+
+func validateCausation(causation *Causation) error {
+	if causation.SourceID() == "" {
+		return errors.New("causation source cannot be empty")
+	}
+	if causation.TargetID() == "" {
+		return errors.New("causation target cannot be empty")
+	}
+	return nil
+}
+
+func validateStateAssertion(state *StateAssertion) error {
+	if state.ID() == "" {
+		return errors.New("state assertion ID cannot be empty")
+	}
+	if state.EntityID() == "" {
+		return errors.New("state assertion entity cannot be empty")
+	}
+	if state.Attribute() == "" {
+		return errors.New("state assertion attribute cannot be empty")
+	}
+	return nil
+}
+
+func validatePlan(plan *Plan) error {
+	if plan.ID() == "" {
+		return errors.New("plan ID cannot be empty")
+	}
+	if plan.Label() == "" {
+		return errors.New("plan label cannot be empty")
+	}
+	return nil
+}
+
+func validateTask(task *Task) error {
+	if task.ID() == "" {
+		return errors.New("task ID cannot be empty")
+	}
+	if task.Label() == "" {
+		return errors.New("task label cannot be empty")
+	}
+	if task.PlanID() == "" {
+		return errors.New("task plan cannot be empty")
+	}
+	if _, err := task.Duration(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func validateDependency(dependency *Dependency) error {
+	if dependency.TaskID() == "" || dependency.DependsOnID() == "" {
+		return errors.New("dependency tasks cannot be empty")
+	}
+	return nil
+}
+
 func validateProperty(property *Property) error {
 	if property.ID() == "" {
 		return errors.New("property ID cannot be empty")
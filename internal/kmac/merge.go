@@ -0,0 +1,216 @@
+package kmac
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Conflict markers written around the two sides of a merge conflict. They
+// are not valid KMAC, so a file with unresolved conflicts fails to load.
+const (
+	ConflictOursMarker   = "<<<<<<< ours"
+	ConflictSeparator    = "======="
+	ConflictTheirsMarker = ">>>>>>> theirs"
+)
+
+// MergeConflict is a statement the two sides of a merge changed in ways that
+// cannot both be kept
+type MergeConflict struct {
+	Key    string    // Type and ID of the statement, such as "ASSERT #F1001"
+	Reason string    // Why the sides conflict
+	Base   Statement // nil where the statement is absent
+	Ours   Statement
+	Theirs Statement
+}
+
+// MergeResult is the outcome of a three-way merge. Statements holds the
+// merged statements, without those in conflict; Conflicts holds those, in
+// the order they appear in ours, then theirs.
+type MergeResult struct {
+	Statements []Statement
+	Conflicts  []MergeConflict
+
+	order []mergeItem // Statements and conflicts in output order
+}
+
+// mergeItem is one statement or conflict of a merge result
+type mergeItem struct {
+	stmt     Statement
+	conflict *MergeConflict
+}
+
+// Merge performs a three-way merge of two statement collections that were
+// both changed from a common base, such as two copies of a knowledge file
+// edited in parallel. Statements are matched by type and ID, and compared
+// with their properties, confidence, and metadata. A statement one side
+// changed, added, or removed takes that side's version; one both sides
+// changed the same way is kept once. It conflicts when the sides changed it
+// differently, or when one side asserts what the other newly negates.
+// Merged statements follow ours' order, with statements only theirs has
+// after them.
+func Merge(base, ours, theirs []Statement) *MergeResult {
+	baseByKey, _ := indexStatements(base)
+	oursByKey, oursKeys := indexStatements(ours)
+	theirsByKey, theirsKeys := indexStatements(theirs)
+
+	keys := append([]string(nil), oursKeys...)
+	for _, key := range theirsKeys {
+		if _, inOurs := oursByKey[key]; !inOurs {
+			keys = append(keys, key)
+		}
+	}
+	// Statements both sides removed are not in either, so they drop out
+
+	result := &MergeResult{}
+	for _, key := range keys {
+		b, o, t := baseByKey[key], oursByKey[key], theirsByKey[key]
+		bText, oText, tText := statementText(b), statementText(o), statementText(t)
+		var merged Statement
+		switch {
+		case oText == tText:
+			merged = o
+		case oText == bText:
+			merged = t
+		case tText == bText:
+			merged = o
+		default:
+			result.order = append(result.order, mergeItem{conflict: &MergeConflict{
+				Key: key, Reason: changeReason(b, o, t), Base: b, Ours: o, Theirs: t,
+			}})
+			continue
+		}
+		if merged != nil {
+			result.order = append(result.order, mergeItem{stmt: merged})
+		}
+	}
+
+	result.markContradictions(baseByKey, oursByKey, theirsByKey)
+	for _, item := range result.order {
+		if item.conflict != nil {
+			result.Conflicts = append(result.Conflicts, *item.conflict)
+		} else {
+			result.Statements = append(result.Statements, item.stmt)
+		}
+	}
+	return result
+}
+
+// markContradictions turns merged assertions that contradict each other,
+// where one side introduced each, into conflicts. The theirs assertion is
+// moved into the conflict placed at the ours one.
+func (r *MergeResult) markContradictions(baseByKey, oursByKey, theirsByKey map[string]Statement) {
+	introduced := func(byKey map[string]Statement, key string, stmt Statement) bool {
+		return byKey[key] == stmt && statementText(stmt) != statementText(baseByKey[key])
+	}
+
+	var kept []mergeItem
+	consumed := make(map[Statement]bool)
+	for i, item := range r.order {
+		ours, isAssertion := item.stmt.(*Assertion)
+		if !isAssertion || consumed[ours] || !introduced(oursByKey, statementKey(ours), ours) {
+			continue
+		}
+		for j, other := range r.order {
+			theirs, isAssertion := other.stmt.(*Assertion)
+			if i == j || !isAssertion || consumed[theirs] || !ours.Conflicts(theirs) ||
+				!introduced(theirsByKey, statementKey(theirs), theirs) {
+				continue
+			}
+			consumed[ours], consumed[theirs] = true, true
+			r.order[i] = mergeItem{conflict: &MergeConflict{
+				Key:    statementKey(ours),
+				Reason: fmt.Sprintf("contradicts %s", statementKey(theirs)),
+				Ours:   ours,
+				Theirs: theirs,
+			}}
+			break
+		}
+	}
+	for _, item := range r.order {
+		if item.stmt == nil || !consumed[item.stmt] {
+			kept = append(kept, item)
+		}
+	}
+	r.order = kept
+}
+
+// HasConflicts reports whether the merge left conflicts to resolve
+func (r *MergeResult) HasConflicts() bool {
+	return len(r.Conflicts) > 0
+}
+
+// Encode writes the merged statements to w in KMAC text form, with each
+// conflict in place between conflict markers: ours above the separator,
+// theirs below
+func (r *MergeResult) Encode(w io.Writer) error {
+	ts := NewTextSerializer()
+	bw := bufio.NewWriter(w)
+	write := func(lines ...string) {
+		for _, line := range lines {
+			bw.WriteString(line + "\n")
+		}
+	}
+	for _, item := range r.order {
+		if item.conflict == nil {
+			write(ts.FormatStatement(item.stmt)...)
+			continue
+		}
+		write(ConflictOursMarker + " " + item.conflict.Key + ": " + item.conflict.Reason)
+		if item.conflict.Ours != nil {
+			write(ts.FormatStatement(item.conflict.Ours)...)
+		}
+		write(ConflictSeparator)
+		if item.conflict.Theirs != nil {
+			write(ts.FormatStatement(item.conflict.Theirs)...)
+		}
+		write(ConflictTheirsMarker)
+	}
+	return bw.Flush()
+}
+
+// changeReason describes how two sides changed a statement differently
+func changeReason(base, ours, theirs Statement) string {
+	switch {
+	case ours == nil:
+		return "removed in ours, changed in theirs"
+	case theirs == nil:
+		return "changed in ours, removed in theirs"
+	case base == nil:
+		return "added differently on both sides"
+	default:
+		return "changed differently on both sides"
+	}
+}
+
+// indexStatements maps statements by key, and returns the keys in order of
+// first appearance. A later statement with the same key replaces an earlier one.
+func indexStatements(statements []Statement) (map[string]Statement, []string) {
+	byKey := make(map[string]Statement, len(statements))
+	var keys []string
+	for _, stmt := range statements {
+		key := statementKey(stmt)
+		if _, seen := byKey[key]; !seen {
+			keys = append(keys, key)
+		}
+		byKey[key] = stmt
+	}
+	return byKey, keys
+}
+
+// statementKey identifies a statement across versions of a collection
+func statementKey(stmt Statement) string {
+	if temporal, isTemporal := stmt.(*Temporal); isTemporal {
+		return temporal.Type() + " #" + temporal.AssertionID()
+	}
+	return stmt.Type() + " #" + stmt.ID()
+}
+
+// statementText renders a statement for comparison, or "" for none
+func statementText(stmt Statement) string {
+	if stmt == nil {
+		return ""
+	}
+	return strings.Join(NewTextSerializer().FormatStatement(stmt), "\n")
+}
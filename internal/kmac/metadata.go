@@ -0,0 +1,177 @@
+package kmac
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Metadata holds the tags, annotations, and notes attached to a statement.
+// They describe the statement itself, such as its review status, rather than
+// the thing it is about. Statements with their own ID embed it; the zero
+// value is empty and ready to use.
+type Metadata struct {
+	fields *metadataFields // Nil until the first tag, annotation, or note
+}
+
+// metadataFields holds a statement's metadata. Most statements have none,
+// so it is allocated on first use and Metadata stays a single pointer.
+type metadataFields struct {
+	tags        map[string]bool
+	annotations map[string]string
+	notes       []string
+}
+
+// noMetadata stands in for the fields of empty metadata; it is never written
+var noMetadata metadataFields
+
+// read returns the metadata fields for reading
+func (m *Metadata) read() *metadataFields {
+	if m.fields == nil {
+		return &noMetadata
+	}
+	return m.fields
+}
+
+// write returns the metadata fields for writing, allocating them if needed
+func (m *Metadata) write() *metadataFields {
+	if m.fields == nil {
+		m.fields = &metadataFields{}
+	}
+	return m.fields
+}
+
+// Annotated is a statement that carries metadata
+type Annotated interface {
+	Statement
+	AddTag(tag string)
+	RemoveTag(tag string)
+	HasTag(tag string) bool
+	Tags() []string
+	Annotate(key string, value string)
+	Annotation(key string) (string, bool)
+	Annotations() map[string]string
+	AddNote(note string)
+	Notes() []string
+}
+
+// AddTag adds a tag, such as "unverified"
+func (m *Metadata) AddTag(tag string) {
+	fields := m.write()
+	if fields.tags == nil {
+		fields.tags = make(map[string]bool)
+	}
+	fields.tags[tag] = true
+}
+
+// RemoveTag removes a tag
+func (m *Metadata) RemoveTag(tag string) {
+	delete(m.read().tags, tag)
+}
+
+// HasTag checks if a tag is present
+func (m *Metadata) HasTag(tag string) bool {
+	return m.read().tags[tag]
+}
+
+// Tags returns the tags in order
+func (m *Metadata) Tags() []string {
+	return sortedKeys(m.read().tags)
+}
+
+// Annotate sets a key-value annotation
+func (m *Metadata) Annotate(key string, value string) {
+	fields := m.write()
+	if fields.annotations == nil {
+		fields.annotations = make(map[string]string)
+	}
+	fields.annotations[key] = value
+}
+
+// Annotation retrieves an annotation
+func (m *Metadata) Annotation(key string) (string, bool) {
+	value, ok := m.read().annotations[key]
+	return value, ok
+}
+
+// Annotations returns all annotations
+func (m *Metadata) Annotations() map[string]string {
+	result := make(map[string]string)
+	for k, v := range m.read().annotations {
+		result[k] = v
+	}
+	return result
+}
+
+// AddNote appends a free-text note
+func (m *Metadata) AddNote(note string) {
+	fields := m.write()
+	fields.notes = append(fields.notes, note)
+}
+
+// Notes returns the notes in the order they were added
+func (m *Metadata) Notes() []string {
+	return append([]string(nil), m.read().notes...)
+}
+
+// IsEmpty checks if there are no tags, annotations, or notes
+func (m *Metadata) IsEmpty() bool {
+	fields := m.read()
+	return len(fields.tags) == 0 && len(fields.annotations) == 0 && len(fields.notes) == 0
+}
+
+// Merge adds the tags, annotations, and notes of other
+func (m *Metadata) Merge(other *Metadata) {
+	if other.IsEmpty() {
+		return
+	}
+	fields := other.read()
+	for tag := range fields.tags {
+		m.AddTag(tag)
+	}
+	for key, value := range fields.annotations {
+		m.Annotate(key, value)
+	}
+	if len(fields.notes) > 0 {
+		target := m.write()
+		target.notes = append(target.notes, fields.notes...)
+	}
+}
+
+// formatMetadata returns TAG, ANNOTATE, and NOTE lines for a statement's
+// metadata. Tags and annotations are ordered by key; notes keep their order.
+func formatMetadata(id string, tags []string, annotations map[string]string, notes []string) []string {
+	var lines []string
+	for _, tag := range tags {
+		lines = append(lines, fmt.Sprintf("TAG #%s [%s]", id, escapeValue(tag)))
+	}
+	for _, key := range sortedKeys(annotations) {
+		lines = append(lines, fmt.Sprintf("ANNOTATE #%s [%s] value=[%s]", id, escapeValue(key), escapeValue(annotations[key])))
+	}
+	for _, note := range notes {
+		lines = append(lines, fmt.Sprintf("NOTE #%s [%s]", id, escapeValue(note)))
+	}
+	return lines
+}
+
+// metadataLines returns the metadata lines of a statement, if it has any
+func metadataLines(stmt Statement) []string {
+	annotated, ok := stmt.(Annotated)
+	if !ok {
+		return nil
+	}
+	return formatMetadata(stmt.ID(), annotated.Tags(), annotated.Annotations(), annotated.Notes())
+}
+
+// sortedTags returns the tags of a set in order
+func sortedTags(tags []string) []string {
+	sorted := append([]string(nil), tags...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// clone returns an independent copy of the metadata
+func (m *Metadata) clone() Metadata {
+	var c Metadata
+	c.Merge(m)
+	return c
+}
@@ -0,0 +1,177 @@
+package kmac
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Argument is one role of an n-ary assertion and the entity, event,
+// assertion, or literal value that fills it
+type Argument struct {
+	Role  string
+	Value string
+}
+
+// NaryAssertion is an assertion relating any number of arguments, each in a
+// named role, such as TRANSFER(AGENT, RESOURCE, FROM, TO, TIME). It states
+// the whole relationship at once, where a binary assertion would need an
+// event and an assertion per role.
+type NaryAssertion struct {
+	id               string
+	relation         string
+	arguments        []Argument
+	confidence       float64
+	confidenceSource string
+	properties       map[string]string
+	Metadata
+}
+
+// NewNaryAssertion creates a new KMAC n-ary assertion. Arguments keep their
+// order. Roles must be distinct words of letters, digits, and underscores,
+// other than "relation".
+func NewNaryAssertion(id string, relation string, arguments []Argument) (*NaryAssertion, error) {
+	if id == "" {
+		return nil, errors.New("assertion ID cannot be empty")
+	}
+
+	if !validateIdentifier(AssertionIDPrefix, id) {
+		return nil, fmt.Errorf("invalid assertion ID format: %s", id)
+	}
+
+	if relation == "" {
+		return nil, errors.New("relation cannot be empty")
+	}
+
+	if len(arguments) < 2 {
+		return nil, fmt.Errorf("n-ary assertion %s needs at least two arguments", id)
+	}
+
+	seen := make(map[string]bool, len(arguments))
+	for _, arg := range arguments {
+		if !validRole(arg.Role) {
+			return nil, fmt.Errorf("invalid argument role %q", arg.Role)
+		}
+		if seen[arg.Role] {
+			return nil, fmt.Errorf("argument role %s is repeated", arg.Role)
+		}
+		seen[arg.Role] = true
+		if arg.Value == "" {
+			return nil, fmt.Errorf("argument %s cannot be empty", arg.Role)
+		}
+		if arg.Value == id {
+			return nil, fmt.Errorf("assertion %s cannot refer to itself", id)
+		}
+	}
+
+	return &NaryAssertion{
+		id:         id,
+		relation:   relation,
+		arguments:  append([]Argument(nil), arguments...),
+		confidence: 1.0, // Default to full confidence
+	}, nil
+}
+
+// validRole reports whether a role can be written as a KMAC field name
+func validRole(role string) bool {
+	if role == "" || role == "relation" || (role[0] >= '0' && role[0] <= '9') {
+		return false
+	}
+	for i := 0; i < len(role); i++ {
+		c := role[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// ID returns the assertion's identifier
+func (n *NaryAssertion) ID() string {
+	return n.id
+}
+
+// Type returns the statement type
+func (n *NaryAssertion) Type() string {
+	return "NARY_ASSERT"
+}
+
+// Relation returns the assertion's relation
+func (n *NaryAssertion) Relation() string {
+	return n.relation
+}
+
+// Arguments returns the assertion's arguments, in order
+func (n *NaryAssertion) Arguments() []Argument {
+	return append([]Argument(nil), n.arguments...)
+}
+
+// Argument returns the value filling a role
+func (n *NaryAssertion) Argument(role string) (string, bool) {
+	for _, arg := range n.arguments {
+		if arg.Role == role {
+			return arg.Value, true
+		}
+	}
+	return "", false
+}
+
+// Arity returns the number of arguments
+func (n *NaryAssertion) Arity() int {
+	return len(n.arguments)
+}
+
+// SetConfidence sets the confidence level and source for this assertion
+func (n *NaryAssertion) SetConfidence(level float64, source string) {
+	if level < 0.0 {
+		level = 0.0
+	} else if level > 1.0 {
+		level = 1.0
+	}
+	n.confidence = level
+	n.confidenceSource = source
+}
+
+// GetConfidence returns the confidence level and source for this assertion
+func (n *NaryAssertion) GetConfidence() (float64, string) {
+	return n.confidence, n.confidenceSource
+}
+
+// SetProperty sets a property on the assertion
+func (n *NaryAssertion) SetProperty(key, value string) {
+	if n.properties == nil {
+		n.properties = make(map[string]string)
+	}
+	n.properties[key] = value
+}
+
+// GetProperty retrieves a property from the assertion
+func (n *NaryAssertion) GetProperty(key string) (string, bool) {
+	val, ok := n.properties[key]
+	return val, ok
+}
+
+// String returns a string representation of the assertion in KMAC format
+func (n *NaryAssertion) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "NARY_ASSERT #%s relation=[#%s]", n.id, escapeValue(n.relation))
+	for _, arg := range n.arguments {
+		fmt.Fprintf(&sb, " %s=[#%s]", arg.Role, escapeValue(arg.Value))
+	}
+	return sb.String()
+}
+
+// Describe renders the assertion as a relation applied to its arguments,
+// e.g. "transfer(agent: Red Cross, resource: Penicillin)". Labels are
+// resolved through labels, which may be nil to show raw IDs.
+func (n *NaryAssertion) Describe(labels Labeler) string {
+	parts := make([]string, len(n.arguments))
+	for i, arg := range n.arguments {
+		parts[i] = strings.ToLower(arg.Role) + ": " + humanize(resolveLabel(labels, arg.Value))
+	}
+	sentence := strings.ToLower(humanize(resolveLabel(labels, n.relation))) + "(" + strings.Join(parts, ", ") + ")"
+	if qualifier := confidenceQualifier(n.confidence, n.confidenceSource); qualifier != "" {
+		sentence += " (" + qualifier + ")"
+	}
+	return sentence
+}
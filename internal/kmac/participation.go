@@ -0,0 +1,82 @@
+package kmac
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Built-in participant roles
+const (
+	RoleAgent       = "AGENT"
+	RolePatient     = "PATIENT"
+	RoleInstrument  = "INSTRUMENT"
+	RoleLocation    = "LOCATION"
+	RoleBeneficiary = "BENEFICIARY"
+)
+
+// BuiltInRoles lists the participant roles every KMAC implementation understands
+var BuiltInRoles = []string{RoleAgent, RolePatient, RoleInstrument, RoleLocation, RoleBeneficiary}
+
+// IsBuiltInRole reports whether a role is one of the built-in participant roles
+func IsBuiltInRole(role string) bool {
+	for _, builtin := range BuiltInRoles {
+		if role == builtin {
+			return true
+		}
+	}
+	return false
+}
+
+// Participation records that an entity takes part in an event in a given role
+type Participation struct {
+	eventID  string
+	role     string
+	entityID string
+}
+
+// NewParticipation creates a new KMAC event participation
+func NewParticipation(eventID string, role string, entityID string) (*Participation, error) {
+	if eventID == "" || role == "" || entityID == "" {
+		return nil, errors.New("event ID, role, and entity ID cannot be empty")
+	}
+
+	if !validateIdentifier(EventIDPrefix, eventID) {
+		return nil, fmt.Errorf("invalid event ID format: %s", eventID)
+	}
+
+	return &Participation{
+		eventID:  eventID,
+		role:     role,
+		entityID: entityID,
+	}, nil
+}
+
+// EventID returns the event's identifier
+func (p *Participation) EventID() string {
+	return p.eventID
+}
+
+// Role returns the role the entity plays in the event
+func (p *Participation) Role() string {
+	return p.role
+}
+
+// EntityID returns the participating entity's identifier
+func (p *Participation) EntityID() string {
+	return p.entityID
+}
+
+// Type returns the statement type
+func (p *Participation) Type() string {
+	return "PARTICIPANT"
+}
+
+// ID returns an identifier for the participation
+func (p *Participation) ID() string {
+	return fmt.Sprintf("PT_%s_%s_%s", p.eventID, p.role, p.entityID)
+}
+
+// String returns a string representation of the participation in KMAC format
+func (p *Participation) String() string {
+	return fmt.Sprintf("PARTICIPANT #%s role=[%s] entity=[#%s]", p.eventID, p.role, p.entityID)
+}
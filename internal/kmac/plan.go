@@ -0,0 +1,203 @@
+package kmac
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DurationProperty is the task property holding an estimated duration, in
+// time.ParseDuration syntax (e.g. "45m", "2h")
+const DurationProperty = "duration"
+
+// Plan represents a KMAC plan definition grouping related tasks
+type Plan struct {
+	id         string
+	label      string
+	properties map[string]string
+	Metadata
+}
+
+// NewPlan creates a new KMAC plan
+func NewPlan(id string, label string) (*Plan, error) {
+	if id == "" {
+		return nil, errors.New("plan ID cannot be empty")
+	}
+
+	if !validateIdentifier(PlanIDPrefix, id) {
+		return nil, fmt.Errorf("invalid plan ID format: %s", id)
+	}
+
+	return &Plan{
+		id:    id,
+		label: label,
+	}, nil
+}
+
+// ID returns the plan's identifier
+func (p *Plan) ID() string {
+	return p.id
+}
+
+// Type returns the statement type
+func (p *Plan) Type() string {
+	return "DEF_PLAN"
+}
+
+// Label returns the plan's label
+func (p *Plan) Label() string {
+	return p.label
+}
+
+// SetProperty sets a property on the plan
+func (p *Plan) SetProperty(key, value string) {
+	if p.properties == nil {
+		p.properties = make(map[string]string)
+	}
+	p.properties[key] = value
+}
+
+// GetProperty retrieves a property from the plan
+func (p *Plan) GetProperty(key string) (string, bool) {
+	val, ok := p.properties[key]
+	return val, ok
+}
+
+// String returns a string representation of the plan in KMAC format
+func (p *Plan) String() string {
+	return fmt.Sprintf("DEF_PLAN #%s [%s]", p.id, p.label)
+}
+
+// Task represents a KMAC task definition belonging to a plan
+type Task struct {
+	id         string
+	label      string
+	planID     string
+	properties map[string]string
+	Metadata
+}
+
+// NewTask creates a new KMAC task within a plan
+func NewTask(id string, label string, planID string) (*Task, error) {
+	if id == "" {
+		return nil, errors.New("task ID cannot be empty")
+	}
+
+	if !validateIdentifier(TaskIDPrefix, id) {
+		return nil, fmt.Errorf("invalid task ID format: %s", id)
+	}
+
+	if planID == "" {
+		return nil, errors.New("task plan cannot be empty")
+	}
+
+	return &Task{
+		id:     id,
+		label:  label,
+		planID: planID,
+	}, nil
+}
+
+// ID returns the task's identifier
+func (t *Task) ID() string {
+	return t.id
+}
+
+// Type returns the statement type
+func (t *Task) Type() string {
+	return "DEF_TASK"
+}
+
+// Label returns the task's label
+func (t *Task) Label() string {
+	return t.label
+}
+
+// PlanID returns the identifier of the plan the task belongs to
+func (t *Task) PlanID() string {
+	return t.planID
+}
+
+// SetProperty sets a property on the task
+func (t *Task) SetProperty(key, value string) {
+	if t.properties == nil {
+		t.properties = make(map[string]string)
+	}
+	t.properties[key] = value
+}
+
+// GetProperty retrieves a property from the task
+func (t *Task) GetProperty(key string) (string, bool) {
+	val, ok := t.properties[key]
+	return val, ok
+}
+
+// SetDuration sets the task's estimated duration
+func (t *Task) SetDuration(d time.Duration) {
+	t.SetProperty(DurationProperty, d.String())
+}
+
+// Duration returns the task's estimated duration, or zero if none is set
+func (t *Task) Duration() (time.Duration, error) {
+	value, ok := t.properties[DurationProperty]
+	if !ok {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("task %s has invalid duration %q: %v", t.id, value, err)
+	}
+	return d, nil
+}
+
+// String returns a string representation of the task in KMAC format
+func (t *Task) String() string {
+	return fmt.Sprintf("DEF_TASK #%s [%s] plan=[#%s]", t.id, t.label, t.planID)
+}
+
+// Dependency records that a task cannot start until another task finishes
+type Dependency struct {
+	taskID      string
+	dependsOnID string
+}
+
+// NewDependency creates a new KMAC task dependency
+func NewDependency(taskID string, dependsOnID string) (*Dependency, error) {
+	if taskID == "" || dependsOnID == "" {
+		return nil, errors.New("task ID and dependency ID cannot be empty")
+	}
+
+	if taskID == dependsOnID {
+		return nil, fmt.Errorf("task %s cannot depend on itself", taskID)
+	}
+
+	return &Dependency{
+		taskID:      taskID,
+		dependsOnID: dependsOnID,
+	}, nil
+}
+
+// TaskID returns the identifier of the dependent task
+func (d *Dependency) TaskID() string {
+	return d.taskID
+}
+
+// DependsOnID returns the identifier of the task that must finish first
+func (d *Dependency) DependsOnID() string {
+	return d.dependsOnID
+}
+
+// Type returns the statement type
+func (d *Dependency) Type() string {
+	return "DEPENDS_ON"
+}
+
+// ID returns an identifier for the dependency
+func (d *Dependency) ID() string {
+	return fmt.Sprintf("DO_%s_%s", d.taskID, d.dependsOnID)
+}
+
+// String returns a string representation of the dependency in KMAC format
+func (d *Dependency) String() string {
+	return fmt.Sprintf("DEPENDS_ON #%s on=[#%s]", d.taskID, d.dependsOnID)
+}
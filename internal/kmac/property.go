@@ -13,6 +13,7 @@ type Property struct {
 	domain       string // What entities can have this property
 	range_       string // What values this property can take
 	functional   bool   // Whether this property is functional (single-valued)
+	Metadata
 }
 
 // NewProperty creates a new KMAC property
@@ -96,6 +97,7 @@ type PropertyAssertion struct {
 	value      string
 	confidence float64
 	source     string
+	Metadata
 }
 
 // NewPropertyAssertion creates a new property assertion
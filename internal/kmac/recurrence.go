@@ -0,0 +1,366 @@
+package kmac
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recurrence frequencies
+const (
+	Hourly  = "HOURLY"
+	Daily   = "DAILY"
+	Weekly  = "WEEKLY"
+	Monthly = "MONTHLY"
+	Yearly  = "YEARLY"
+)
+
+// maxRecurrenceDays bounds the search for occurrences of a rule that never
+// matches, such as BYMONTHDAY=31 with BYMONTH=2
+const maxRecurrenceDays = 400 * 366
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// Recurrence is a subset of the iCalendar RRULE: FREQ, INTERVAL, COUNT,
+// UNTIL, BYMONTH, BYMONTHDAY, BYDAY (without ordinals), BYHOUR, and
+// BYMINUTE. Weeks start on Monday.
+type Recurrence struct {
+	Freq       string
+	Interval   int       // Defaults to 1
+	Count      int       // Number of occurrences; 0 means unbounded
+	Until      time.Time // Last possible occurrence; zero means unbounded
+	ByMonth    []int
+	ByMonthDay []int // Negative values count back from the end of the month
+	ByDay      []time.Weekday
+	ByHour     []int
+	ByMinute   []int
+}
+
+// ParseRecurrence parses an RRULE such as FREQ=WEEKLY;BYDAY=MO;BYHOUR=8
+func ParseRecurrence(rule string) (*Recurrence, error) {
+	r := &Recurrence{Interval: 1}
+	for _, part := range strings.Split(strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:"), ";") {
+		if part == "" {
+			continue
+		}
+		name, value, found := strings.Cut(part, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid recurrence part %q", part)
+		}
+		var err error
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			r.Freq = strings.ToUpper(value)
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err == nil && r.Interval < 1 {
+				err = errors.New("must be positive")
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+			if err == nil && r.Count < 1 {
+				err = errors.New("must be positive")
+			}
+		case "UNTIL":
+			r.Until, err = parseUntil(value)
+		case "BYMONTH":
+			r.ByMonth, err = parseInts(value, 1, 12, false)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseInts(value, 1, 31, true)
+		case "BYHOUR":
+			r.ByHour, err = parseInts(value, 0, 23, false)
+		case "BYMINUTE":
+			r.ByMinute, err = parseInts(value, 0, 59, false)
+		case "BYDAY":
+			for _, code := range strings.Split(value, ",") {
+				weekday, ok := weekdayCodes[strings.ToUpper(code)]
+				if !ok {
+					err = fmt.Errorf("unsupported day %q", code)
+					break
+				}
+				r.ByDay = append(r.ByDay, weekday)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported recurrence part %s", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", strings.ToUpper(name), err)
+		}
+	}
+
+	switch r.Freq {
+	case Hourly, Daily, Weekly, Monthly, Yearly:
+	case "":
+		return nil, errors.New("recurrence needs a FREQ")
+	default:
+		return nil, fmt.Errorf("unsupported frequency %s", r.Freq)
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, errors.New("recurrence cannot have both COUNT and UNTIL")
+	}
+	return r, nil
+}
+
+// parseUntil accepts iCalendar basic date-times and dates as well as RFC 3339
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102", time.RFC3339} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time %q", value)
+}
+
+// parseInts parses a comma-separated list of integers within bounds
+func parseInts(value string, min int, max int, allowNegative bool) ([]int, error) {
+	var values []int
+	for _, field := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, err
+		}
+		abs := n
+		if allowNegative && n < 0 {
+			abs = -n
+		}
+		if abs < min || abs > max {
+			return nil, fmt.Errorf("%d is out of range", n)
+		}
+		values = append(values, n)
+	}
+	return values, nil
+}
+
+// String renders the rule in RRULE form
+func (r *Recurrence) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if len(r.ByMonth) > 0 {
+		parts = append(parts, "BYMONTH="+joinInts(r.ByMonth))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByDay) > 0 {
+		codes := make([]string, len(r.ByDay))
+		for i, weekday := range r.ByDay {
+			codes[i] = strings.ToUpper(weekday.String()[:2])
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	if len(r.ByHour) > 0 {
+		parts = append(parts, "BYHOUR="+joinInts(r.ByHour))
+	}
+	if len(r.ByMinute) > 0 {
+		parts = append(parts, "BYMINUTE="+joinInts(r.ByMinute))
+	}
+	return strings.Join(parts, ";")
+}
+
+func joinInts(values []int) string {
+	fields := make([]string, len(values))
+	for i, v := range values {
+		fields[i] = strconv.Itoa(v)
+	}
+	return strings.Join(fields, ",")
+}
+
+// Occurrences returns the occurrences of the rule, starting at dtstart, that
+// fall in [from, to)
+func (r *Recurrence) Occurrences(dtstart time.Time, from time.Time, to time.Time) []time.Time {
+	var occurrences []time.Time
+	r.each(dtstart, to, func(t time.Time) bool {
+		if !t.Before(from) {
+			occurrences = append(occurrences, t)
+		}
+		return true
+	})
+	return occurrences
+}
+
+// Next returns the first occurrence after a time
+func (r *Recurrence) Next(dtstart time.Time, after time.Time) (time.Time, bool) {
+	var next time.Time
+	found := false
+	r.each(dtstart, time.Time{}, func(t time.Time) bool {
+		if t.After(after) {
+			next, found = t, true
+			return false
+		}
+		return true
+	})
+	return next, found
+}
+
+// each calls fn with every occurrence in order until fn returns false, the
+// rule ends, or an occurrence reaches limit (when limit is not zero)
+func (r *Recurrence) each(dtstart time.Time, limit time.Time, fn func(time.Time) bool) {
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	loc := dtstart.Location()
+	startDay := time.Date(dtstart.Year(), dtstart.Month(), dtstart.Day(), 0, 0, 0, 0, loc)
+
+	byMonth, byMonthDay, byDay := r.ByMonth, r.ByMonthDay, r.ByDay
+	if r.Freq == Yearly && len(byMonth) == 0 && len(byDay) == 0 && len(byMonthDay) == 0 {
+		byMonth = []int{int(dtstart.Month())}
+	}
+	if len(byMonthDay) == 0 && len(byDay) == 0 {
+		switch r.Freq {
+		case Monthly, Yearly:
+			byMonthDay = []int{dtstart.Day()}
+		case Weekly:
+			byDay = []time.Weekday{dtstart.Weekday()}
+		}
+	}
+	hours := r.ByHour
+	if len(hours) == 0 {
+		if r.Freq == Hourly {
+			hours = make([]int, 24)
+			for h := range hours {
+				hours[h] = h
+			}
+		} else {
+			hours = []int{dtstart.Hour()}
+		}
+	}
+	minutes := r.ByMinute
+	if len(minutes) == 0 {
+		minutes = []int{dtstart.Minute()}
+	}
+	hours, minutes = sortedCopy(hours), sortedCopy(minutes)
+
+	count := 0
+	for d := 0; d < maxRecurrenceDays; d++ {
+		day := startDay.AddDate(0, 0, d)
+		if !limit.IsZero() && !day.Before(limit) {
+			return
+		}
+		if !r.Until.IsZero() && day.After(r.Until) {
+			return
+		}
+		if !r.dayMatches(day, startDay, interval, byMonth, byMonthDay, byDay) {
+			continue
+		}
+		for _, hour := range hours {
+			for _, minute := range minutes {
+				t := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, dtstart.Second(), dtstart.Nanosecond(), loc)
+				if t.Before(dtstart) {
+					continue
+				}
+				if r.Freq == Hourly && hoursBetween(dtstart, t)%interval != 0 {
+					continue
+				}
+				if !r.Until.IsZero() && t.After(r.Until) {
+					return
+				}
+				if !limit.IsZero() && !t.Before(limit) {
+					return
+				}
+				if !fn(t) {
+					return
+				}
+				count++
+				if r.Count > 0 && count >= r.Count {
+					return
+				}
+			}
+		}
+	}
+}
+
+// dayMatches checks a day against the period interval and day filters
+func (r *Recurrence) dayMatches(day time.Time, startDay time.Time, interval int, byMonth []int, byMonthDay []int, byDay []time.Weekday) bool {
+	var period int
+	switch r.Freq {
+	case Daily:
+		period = daysBetween(startDay, day)
+	case Weekly:
+		period = daysBetween(mondayOf(startDay), mondayOf(day)) / 7
+	case Monthly:
+		period = (day.Year()-startDay.Year())*12 + int(day.Month()) - int(startDay.Month())
+	case Yearly:
+		period = day.Year() - startDay.Year()
+	}
+	if period%interval != 0 {
+		return false
+	}
+
+	if len(byMonth) > 0 && !containsInt(byMonth, int(day.Month())) {
+		return false
+	}
+	if len(byMonthDay) > 0 {
+		daysInMonth := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, day.Location()).Day()
+		matched := false
+		for _, monthDay := range byMonthDay {
+			if monthDay == day.Day() || (monthDay < 0 && daysInMonth+monthDay+1 == day.Day()) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(byDay) > 0 {
+		matched := false
+		for _, weekday := range byDay {
+			if weekday == day.Weekday() {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// daysBetween counts calendar days between two midnights, ignoring DST shifts
+func daysBetween(a time.Time, b time.Time) int {
+	ua := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	ub := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(ub.Sub(ua).Hours() / 24)
+}
+
+// hoursBetween counts whole wall-clock hours from a to b
+func hoursBetween(a time.Time, b time.Time) int {
+	return daysBetween(a, b)*24 + b.Hour() - a.Hour()
+}
+
+// mondayOf returns the Monday starting a day's week
+func mondayOf(day time.Time) time.Time {
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+func containsInt(values []int, v int) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedCopy(values []int) []int {
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	return sorted
+}
@@ -13,6 +13,7 @@ type Relation struct {
 	properties   map[string]string
 	domain       string // Subject domain constraint
 	range_       string // Object domain constraint
+	Metadata
 }
 
 // NewRelation creates a new KMAC relation
@@ -29,7 +30,6 @@ func NewRelation(id string, label string, relationType string) (*Relation, error
 		id:           id,
 		label:        label,
 		relationType: relationType,
-		properties:   make(map[string]string),
 	}, nil
 }
 
@@ -75,6 +75,9 @@ func (r *Relation) GetRange() string {
 
 // SetProperty sets a property on the relation
 func (r *Relation) SetProperty(key, value string) {
+	if r.properties == nil {
+		r.properties = make(map[string]string)
+	}
 	r.properties[key] = value
 }
 
@@ -102,7 +105,27 @@ func (r *Relation) IsReflexive() bool {
 	return exists && reflexive == "true"
 }
 
+// Inverse returns the ID of the relation that holds in the opposite
+// direction, such as SUPPLIED_BY for SUPPLIES, if one is declared
+func (r *Relation) Inverse() (string, bool) {
+	inverse, exists := r.properties["inverse"]
+	return inverse, exists && inverse != ""
+}
+
 // String returns a string representation of the relation in KMAC format
 func (r *Relation) String() string {
 	return fmt.Sprintf("DEF_RELATION #%s [%s] type=[%s]", r.id, r.label, r.relationType)
-}
\ No newline at end of file
+}
+
+// Clone returns an independent copy of the relation
+func (r *Relation) Clone() *Relation {
+	c := *r
+	if r.properties != nil {
+		c.properties = make(map[string]string, len(r.properties))
+		for k, v := range r.properties {
+			c.properties[k] = v
+		}
+	}
+	c.Metadata = r.Metadata.clone()
+	return &c
+}
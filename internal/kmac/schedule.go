@@ -0,0 +1,136 @@
+package kmac
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ScheduledTask is a task with its earliest start and finish, measured from the start of the plan
+type ScheduledTask struct {
+	Task   *Task
+	Start  time.Duration
+	Finish time.Duration
+}
+
+// Schedule is the earliest-start schedule of a set of tasks
+type Schedule struct {
+	Tasks        []ScheduledTask // In dependency order
+	CriticalPath []*Task         // Longest chain of dependent tasks, first to last
+	Duration     time.Duration   // Time until every task has finished
+}
+
+// TopologicalOrder orders tasks so each follows every task it depends on. Tasks
+// that are free to run at the same point are ordered by ID, so the result is
+// stable. It fails if the dependencies form a cycle or refer to unknown tasks.
+func TopologicalOrder(tasks []*Task, dependencies []*Dependency) ([]*Task, error) {
+	byID := make(map[string]*Task, len(tasks))
+	for _, task := range tasks {
+		byID[task.ID()] = task
+	}
+
+	waiting := make(map[string]int, len(tasks))
+	dependents := make(map[string][]string)
+	for _, dep := range dependencies {
+		if _, ok := byID[dep.TaskID()]; !ok {
+			return nil, fmt.Errorf("dependency refers to unknown task %s", dep.TaskID())
+		}
+		if _, ok := byID[dep.DependsOnID()]; !ok {
+			return nil, fmt.Errorf("task %s depends on unknown task %s", dep.TaskID(), dep.DependsOnID())
+		}
+		waiting[dep.TaskID()]++
+		dependents[dep.DependsOnID()] = append(dependents[dep.DependsOnID()], dep.TaskID())
+	}
+
+	var ready []string
+	for id := range byID {
+		if waiting[id] == 0 {
+			ready = append(ready, id)
+		}
+	}
+	sort.Strings(ready)
+
+	order := make([]*Task, 0, len(tasks))
+	for len(ready) > 0 {
+		id := ready[0]
+		ready = ready[1:]
+		order = append(order, byID[id])
+
+		for _, dependent := range dependents[id] {
+			waiting[dependent]--
+			if waiting[dependent] == 0 {
+				i := sort.SearchStrings(ready, dependent)
+				ready = append(ready, "")
+				copy(ready[i+1:], ready[i:])
+				ready[i] = dependent
+			}
+		}
+	}
+
+	if len(order) != len(byID) {
+		var cyclic []string
+		for id, count := range waiting {
+			if count > 0 {
+				cyclic = append(cyclic, id)
+			}
+		}
+		sort.Strings(cyclic)
+		return nil, fmt.Errorf("dependency cycle among tasks %v", cyclic)
+	}
+	return order, nil
+}
+
+// ScheduleTasks computes the earliest start of each task from the estimated
+// durations stored in task properties, along with the critical path
+func ScheduleTasks(tasks []*Task, dependencies []*Dependency) (*Schedule, error) {
+	order, err := TopologicalOrder(tasks, dependencies)
+	if err != nil {
+		return nil, err
+	}
+
+	prerequisites := make(map[string][]string)
+	for _, dep := range dependencies {
+		prerequisites[dep.TaskID()] = append(prerequisites[dep.TaskID()], dep.DependsOnID())
+	}
+
+	schedule := &Schedule{Tasks: make([]ScheduledTask, 0, len(order))}
+	finish := make(map[string]time.Duration, len(order))
+	// critical maps each task to the prerequisite that determines its start
+	critical := make(map[string]string)
+	var last string
+
+	for _, task := range order {
+		duration, err := task.Duration()
+		if err != nil {
+			return nil, err
+		}
+
+		var start time.Duration
+		prereqs := append([]string(nil), prerequisites[task.ID()]...)
+		sort.Strings(prereqs)
+		for _, prereq := range prereqs {
+			// Ties go to the lowest prerequisite ID
+			if critical[task.ID()] == "" || finish[prereq] > start {
+				start = finish[prereq]
+				critical[task.ID()] = prereq
+			}
+		}
+
+		finish[task.ID()] = start + duration
+		schedule.Tasks = append(schedule.Tasks, ScheduledTask{Task: task, Start: start, Finish: start + duration})
+
+		if last == "" || finish[task.ID()] > schedule.Duration {
+			schedule.Duration = finish[task.ID()]
+			last = task.ID()
+		}
+	}
+
+	byID := make(map[string]*Task, len(order))
+	for _, task := range order {
+		byID[task.ID()] = task
+	}
+	for id := last; id != ""; id = critical[id] {
+		schedule.CriticalPath = append([]*Task{byID[id]}, schedule.CriticalPath...)
+	}
+	return schedule, nil
+}
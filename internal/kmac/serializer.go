@@ -0,0 +1,591 @@
+package kmac
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TextSerializer reads and writes statements in KMAC text form, one statement per line.
+// Bracketed values escape backslashes, closing brackets, and newlines so that any
+// label or value survives a round trip. Compressed input is recognized and
+// decompressed as it is read, whatever the serializer's own compression.
+type TextSerializer struct {
+	compression Compression
+}
+
+// NewTextSerializer creates a new KMAC text serializer
+func NewTextSerializer() *TextSerializer {
+	return &TextSerializer{}
+}
+
+// NewCompressedTextSerializer creates a KMAC text serializer that compresses
+// what it writes
+func NewCompressedTextSerializer(compression Compression) *TextSerializer {
+	return &TextSerializer{compression: compression}
+}
+
+// Serialize converts statements to KMAC text
+func (ts *TextSerializer) Serialize(statements []Statement) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := ts.Encode(&buf, statements); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Deserialize converts KMAC text back to statements
+func (ts *TextSerializer) Deserialize(data []byte) ([]Statement, error) {
+	return ts.Decode(bytes.NewReader(data))
+}
+
+// SerializeToString converts statements to a KMAC text string
+func (ts *TextSerializer) SerializeToString(statements []Statement) (string, error) {
+	data, err := ts.Serialize(statements)
+	return string(data), err
+}
+
+// DeserializeFromString converts a KMAC text string back to statements
+func (ts *TextSerializer) DeserializeFromString(data string) ([]Statement, error) {
+	return ts.Decode(strings.NewReader(data))
+}
+
+// Encode writes statements to w in KMAC text form, compressed if the
+// serializer compresses
+func (ts *TextSerializer) Encode(w io.Writer, statements []Statement) error {
+	cw, err := NewCompressWriter(w, ts.compression)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(cw)
+	for _, stmt := range statements {
+		for _, line := range ts.FormatStatement(stmt) {
+			if _, err := bw.WriteString(line + "\n"); err != nil {
+				return err
+			}
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return cw.Close()
+}
+
+// FormatStatement returns the KMAC text lines for a single statement. Most
+// statements produce one line; qualifiers such as confidence levels,
+// properties, and metadata follow on their own lines.
+func (ts *TextSerializer) FormatStatement(stmt Statement) []string {
+	return append(ts.formatLines(stmt), metadataLines(stmt)...)
+}
+
+// formatLines returns the KMAC text lines for a statement without its metadata
+func (ts *TextSerializer) formatLines(stmt Statement) []string {
+	switch s := stmt.(type) {
+	case *Entity:
+		lines := []string{fmt.Sprintf("DEF_ENTITY #%s [%s] type=[%s]", s.id, escapeValue(s.label), escapeValue(s.tosidType))}
+		return append(lines, formatProperties(s.id, s.properties)...)
+	case *Event:
+		lines := []string{fmt.Sprintf("DEF_EVENT #%s [%s] type=[%s]", s.id, escapeValue(s.label), escapeValue(s.tosidType))}
+		return append(lines, formatProperties(s.id, s.properties)...)
+	case *Relation:
+		line := fmt.Sprintf("DEF_RELATION #%s [%s] type=[%s]", s.id, escapeValue(s.label), escapeValue(s.relationType))
+		if s.domain != "" {
+			line += fmt.Sprintf(" domain=[%s]", escapeValue(s.domain))
+		}
+		if s.range_ != "" {
+			line += fmt.Sprintf(" range=[%s]", escapeValue(s.range_))
+		}
+		return append([]string{line}, formatProperties(s.id, s.properties)...)
+	case *Property:
+		line := fmt.Sprintf("DEF_PROPERTY #%s [%s] type=[%s]", s.id, escapeValue(s.label), escapeValue(s.propertyType))
+		if s.domain != "" {
+			line += fmt.Sprintf(" domain=[%s]", escapeValue(s.domain))
+		}
+		if s.range_ != "" {
+			line += fmt.Sprintf(" range=[%s]", escapeValue(s.range_))
+		}
+		if s.functional {
+			line += " functional=[true]"
+		}
+		return []string{line}
+	case *Assertion:
+		prefix := "ASSERT"
+		if s.negated {
+			prefix = "NEGATE"
+		}
+		lines := []string{fmt.Sprintf("%s #%s subject=[#%s] relation=[#%s] object=[#%s]",
+			prefix, s.id, escapeValue(s.subject), escapeValue(s.relation), escapeValue(s.object))}
+		if s.confidence != 1.0 || s.confidenceSource != "" {
+			lines = append(lines, fmt.Sprintf("CONFIDENCE #%s level=[%s] source=[%s]",
+				s.id, strconv.FormatFloat(s.confidence, 'f', -1, 64), escapeValue(s.confidenceSource)))
+		}
+		return append(lines, formatProperties(s.id, s.properties)...)
+	case *NaryAssertion:
+		lines := []string{s.String()}
+		if s.confidence != 1.0 || s.confidenceSource != "" {
+			lines = append(lines, fmt.Sprintf("CONFIDENCE #%s level=[%s] source=[%s]",
+				s.id, strconv.FormatFloat(s.confidence, 'f', -1, 64), escapeValue(s.confidenceSource)))
+		}
+		return append(lines, formatProperties(s.id, s.properties)...)
+	case *PropertyAssertion:
+		line := fmt.Sprintf("ASSERT #%s subject=[#%s] property=[#%s] value=[%s]",
+			s.id, escapeValue(s.entity), escapeValue(s.property), escapeValue(s.value))
+		lines := []string{line}
+		if s.confidence != 1.0 || s.source != "" {
+			lines = append(lines, fmt.Sprintf("CONFIDENCE #%s level=[%s] source=[%s]",
+				s.id, strconv.FormatFloat(s.confidence, 'f', -1, 64), escapeValue(s.source)))
+		}
+		return lines
+	case *TimeReference:
+		value := s.value.Format(time.RFC3339Nano)
+		if s.approx != nil {
+			value = escapeValue(s.approx.String())
+		} else if s.scale != "" {
+			value = s.ScaleValue()
+		}
+		line := fmt.Sprintf("DEF_TIME #%s type=[%s] value=[%s]",
+			s.id, escapeValue(s.timeType), value)
+		if s.scale != "" {
+			line += fmt.Sprintf(" scale=[%s]", s.scale)
+		}
+		if s.epoch != nil {
+			line += fmt.Sprintf(" epoch=[%s]", s.epoch.Format(time.RFC3339Nano))
+		}
+		if s.end != nil {
+			line += fmt.Sprintf(" end=[%s]", s.end.Format(time.RFC3339Nano))
+		} else if s.duration != 0 {
+			line += fmt.Sprintf(" duration=[%s]", s.duration)
+		}
+		if s.recurrence != nil {
+			line += fmt.Sprintf(" rrule=[%s]", s.recurrence)
+		}
+		return []string{line}
+	case *Temporal:
+		line := fmt.Sprintf("TEMPORAL #%s state=[%s] timestamp=[%s]", s.assertionID, s.state, escapeValue(s.timestamp))
+		if s.startTime != nil && s.endTime != nil {
+			line += fmt.Sprintf(" start=[%s] end=[%s]",
+				s.startTime.Format(time.RFC3339Nano), s.endTime.Format(time.RFC3339Nano))
+		}
+		return []string{line}
+	case *PartOf:
+		return []string{fmt.Sprintf("PART_OF #%s whole=[#%s]", s.partID, escapeValue(s.wholeID))}
+	case *Plan:
+		lines := []string{fmt.Sprintf("DEF_PLAN #%s [%s]", s.id, escapeValue(s.label))}
+		return append(lines, formatProperties(s.id, s.properties)...)
+	case *Task:
+		lines := []string{fmt.Sprintf("DEF_TASK #%s [%s] plan=[#%s]", s.id, escapeValue(s.label), escapeValue(s.planID))}
+		return append(lines, formatProperties(s.id, s.properties)...)
+	case *Dependency:
+		return []string{fmt.Sprintf("DEPENDS_ON #%s on=[#%s]", s.taskID, escapeValue(s.dependsOnID))}
+	case *Situation:
+		line := fmt.Sprintf("DEF_SITUATION #%s [%s]", s.id, escapeValue(s.label))
+		if s.parentID != "" {
+			line += fmt.Sprintf(" within=[#%s]", escapeValue(s.parentID))
+		}
+		return []string{line}
+	case *SituationMember:
+		return []string{fmt.Sprintf("IN_SITUATION #%s situation=[#%s]", s.assertionID, escapeValue(s.situationID))}
+	case *Evidence:
+		return []string{s.String()}
+	case *StateAssertion:
+		return []string{fmt.Sprintf("STATE #%s entity=[#%s] attribute=[%s] value=[%s] at=[%s]",
+			s.id, escapeValue(s.entityID), escapeValue(s.attribute), escapeValue(s.value), s.timestamp.Format(time.RFC3339Nano))}
+	case *Participation:
+		return []string{fmt.Sprintf("PARTICIPANT #%s role=[%s] entity=[#%s]", s.eventID, escapeValue(s.role), escapeValue(s.entityID))}
+	case *Causation:
+		return []string{fmt.Sprintf("CAUSATION source=[#%s] target=[#%s] type=[%s]",
+			escapeValue(s.sourceID), escapeValue(s.targetID), s.causationType)}
+	default:
+		return []string{stmt.String()}
+	}
+}
+
+// formatProperties returns PROPERTY lines for a property map, ordered by key
+func formatProperties(id string, properties map[string]string) []string {
+	var lines []string
+	for _, key := range sortedKeys(properties) {
+		lines = append(lines, fmt.Sprintf("PROPERTY #%s [%s] value=[%s]", id, escapeValue(key), escapeValue(properties[key])))
+	}
+	return lines
+}
+
+// Decode reads KMAC text statements from r. Blank lines and lines starting
+// with '#' are ignored. CONFIDENCE, PROPERTY, TAG, ANNOTATE, and NOTE lines
+// qualify the most recent statement with the same ID. Compressed input is
+// decompressed as it is read.
+func (ts *TextSerializer) Decode(r io.Reader) ([]Statement, error) {
+	var statements []Statement
+	decoder := ts.NewLineDecoder()
+
+	dr, err := NewDecompressReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer dr.Close()
+	scanner := bufio.NewScanner(dr)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		stmt, err := decoder.Decode(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		if stmt != nil {
+			statements = append(statements, stmt)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return statements, nil
+}
+
+// ParseStatement parses a single KMAC text line into a statement. Qualifier
+// lines (CONFIDENCE, PROPERTY, TAG, ANNOTATE, NOTE) cannot be parsed on their own.
+func (ts *TextSerializer) ParseStatement(line string) (Statement, error) {
+	stmt, err := ts.parseLine(strings.TrimSpace(line), nil)
+	if err != nil {
+		return nil, err
+	}
+	if stmt == nil {
+		return nil, errors.New("qualifier lines require a preceding statement")
+	}
+	return stmt, nil
+}
+
+// parseLine parses one line. Qualifier lines are applied to statements in
+// byID and return a nil statement.
+func (ts *TextSerializer) parseLine(line string, byID map[string]Statement) (Statement, error) {
+	keyword, id, fields, err := splitLine(line)
+	if err != nil {
+		return nil, err
+	}
+
+	switch keyword {
+	case "DEF_ENTITY":
+		return NewEntity(id, fields.positional, fields.named["type"])
+	case "DEF_EVENT":
+		return NewEvent(id, fields.positional, fields.named["type"])
+	case "DEF_PLAN":
+		return NewPlan(id, fields.positional)
+	case "DEF_TASK":
+		return NewTask(id, fields.positional, fields.reference("plan"))
+	case "DEPENDS_ON":
+		return NewDependency(id, fields.reference("on"))
+	case "DEF_SITUATION":
+		return NewSituation(id, fields.positional, fields.reference("within"))
+	case "IN_SITUATION":
+		return NewSituationMember(id, fields.reference("situation"))
+	case "EVIDENCE":
+		evidence, err := NewEvidence(id, fields.reference("assertion"))
+		if err != nil {
+			return nil, err
+		}
+		evidence.SetURL(fields.named["url"])
+		evidence.SetDOI(fields.named["doi"])
+		evidence.SetHash(fields.named["hash"])
+		evidence.SetExcerpt(fields.named["excerpt"])
+		return evidence, nil
+	case "DEF_RELATION":
+		relation, err := NewRelation(id, fields.positional, fields.named["type"])
+		if err != nil {
+			return nil, err
+		}
+		relation.SetDomain(fields.named["domain"])
+		relation.SetRange(fields.named["range"])
+		return relation, nil
+	case "DEF_PROPERTY":
+		property, err := NewProperty(id, fields.positional, fields.named["type"])
+		if err != nil {
+			return nil, err
+		}
+		property.SetDomain(fields.named["domain"])
+		property.SetRange(fields.named["range"])
+		property.SetFunctional(fields.named["functional"] == "true")
+		return property, nil
+	case "ASSERT", "NEGATE":
+		if _, ok := fields.named["property"]; ok && keyword == "ASSERT" {
+			return NewPropertyAssertion(id, fields.reference("subject"), fields.reference("property"), fields.named["value"])
+		}
+		assertion, err := NewAssertion(id, fields.reference("subject"), fields.reference("relation"), fields.reference("object"))
+		if err != nil {
+			return nil, err
+		}
+		assertion.SetNegated(keyword == "NEGATE")
+		return assertion, nil
+	case "NARY_ASSERT":
+		var arguments []Argument
+		for _, name := range fields.order {
+			if name != "relation" {
+				arguments = append(arguments, Argument{Role: name, Value: fields.reference(name)})
+			}
+		}
+		return NewNaryAssertion(id, fields.reference("relation"), arguments)
+	case "DEF_TIME":
+		var timeRef *TimeReference
+		if scale, ok := fields.named["scale"]; ok {
+			var epoch time.Time
+			if text, ok := fields.named["epoch"]; ok {
+				var err error
+				if epoch, err = time.Parse(time.RFC3339Nano, text); err != nil {
+					return nil, fmt.Errorf("invalid epoch: %v", err)
+				}
+			} else if strings.EqualFold(scale, ScaleMET) {
+				return nil, errors.New("mission elapsed time needs an epoch")
+			}
+			value, err := ParseInScale(fields.named["value"], strings.ToUpper(scale), epoch)
+			if err != nil {
+				return nil, fmt.Errorf("invalid time value: %v", err)
+			}
+			if timeRef, err = NewTimeReference(id, fields.named["type"], value); err != nil {
+				return nil, err
+			}
+			if strings.EqualFold(scale, ScaleMET) {
+				timeRef.SetMissionEpoch(epoch)
+			} else if err := timeRef.SetScale(scale); err != nil {
+				return nil, err
+			}
+		} else if value, err := time.Parse(time.RFC3339Nano, fields.named["value"]); err == nil {
+			timeRef, err = NewTimeReference(id, fields.named["type"], value)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			approx, approxErr := ParseApproximateTime(fields.named["value"])
+			if approxErr != nil {
+				return nil, fmt.Errorf("invalid time value: %v", approxErr)
+			}
+			timeRef, err = NewApproximateTimeReference(id, fields.named["type"], approx)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if end, ok := fields.named["end"]; ok {
+			endTime, err := time.Parse(time.RFC3339Nano, end)
+			if err != nil {
+				return nil, fmt.Errorf("invalid end time: %v", err)
+			}
+			if err := timeRef.SetEnd(endTime); err != nil {
+				return nil, err
+			}
+		}
+		if duration, ok := fields.named["duration"]; ok {
+			d, err := time.ParseDuration(duration)
+			if err != nil {
+				return nil, fmt.Errorf("invalid duration: %v", err)
+			}
+			if err := timeRef.SetDuration(d); err != nil {
+				return nil, err
+			}
+		}
+		if rule, ok := fields.named["rrule"]; ok {
+			recurrence, err := ParseRecurrence(rule)
+			if err != nil {
+				return nil, fmt.Errorf("invalid recurrence: %v", err)
+			}
+			timeRef.SetRecurrence(recurrence)
+		}
+		return timeRef, nil
+	case "TEMPORAL":
+		temporal, err := NewTemporal(id, fields.named["state"], fields.named["timestamp"])
+		if err != nil {
+			return nil, err
+		}
+		if start, ok := fields.named["start"]; ok {
+			startTime, err := time.Parse(time.RFC3339Nano, start)
+			if err != nil {
+				return nil, fmt.Errorf("invalid start time: %v", err)
+			}
+			endTime, err := time.Parse(time.RFC3339Nano, fields.named["end"])
+			if err != nil {
+				return nil, fmt.Errorf("invalid end time: %v", err)
+			}
+			temporal.SetTimeRange(startTime, endTime)
+		}
+		return temporal, nil
+	case "PART_OF":
+		return NewPartOf(id, fields.reference("whole"))
+	case "STATE":
+		timestamp, err := time.Parse(time.RFC3339Nano, fields.named["at"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid state time: %v", err)
+		}
+		return NewStateAssertion(id, fields.reference("entity"), fields.named["attribute"], fields.named["value"], timestamp)
+	case "PARTICIPANT":
+		return NewParticipation(id, fields.named["role"], fields.reference("entity"))
+	case "CAUSATION":
+		return NewCausation(fields.reference("source"), fields.reference("target"), fields.named["type"])
+	case "CONFIDENCE":
+		level, err := strconv.ParseFloat(fields.named["level"], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid confidence level: %v", err)
+		}
+		switch target := byID[id].(type) {
+		case *Assertion:
+			target.SetConfidence(level, fields.named["source"])
+		case *PropertyAssertion:
+			target.SetConfidence(level, fields.named["source"])
+		case *NaryAssertion:
+			target.SetConfidence(level, fields.named["source"])
+		default:
+			return nil, fmt.Errorf("CONFIDENCE references unknown assertion %s", id)
+		}
+		return nil, nil
+	case "PROPERTY":
+		value := fields.named["value"]
+		switch target := byID[id].(type) {
+		case *Entity:
+			target.SetProperty(fields.positional, value)
+		case *Event:
+			target.SetProperty(fields.positional, value)
+		case *Relation:
+			target.SetProperty(fields.positional, value)
+		case *Assertion:
+			target.SetProperty(fields.positional, value)
+		case *NaryAssertion:
+			target.SetProperty(fields.positional, value)
+		case *Plan:
+			target.SetProperty(fields.positional, value)
+		case *Task:
+			target.SetProperty(fields.positional, value)
+		default:
+			return nil, fmt.Errorf("PROPERTY references unknown statement %s", id)
+		}
+		return nil, nil
+	case "TAG", "ANNOTATE", "NOTE":
+		target, ok := byID[id].(Annotated)
+		if !ok {
+			return nil, fmt.Errorf("%s references unknown statement %s", keyword, id)
+		}
+		switch keyword {
+		case "TAG":
+			target.AddTag(fields.positional)
+		case "ANNOTATE":
+			target.Annotate(fields.positional, fields.named["value"])
+		case "NOTE":
+			target.AddNote(fields.positional)
+		}
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown statement keyword: %s", keyword)
+	}
+}
+
+// lineFields holds the bracketed values of a KMAC text line
+type lineFields struct {
+	positional string
+	named      map[string]string
+	order      []string // Names of the named fields, as first written
+}
+
+// reference returns a named field with its leading '#' removed
+func (lf lineFields) reference(name string) string {
+	return strings.TrimPrefix(lf.named[name], "#")
+}
+
+// splitLine breaks a KMAC text line into its keyword, optional #ID, and bracketed fields
+func splitLine(line string) (keyword string, id string, fields lineFields, err error) {
+	fields.named = make(map[string]string)
+
+	end := strings.IndexByte(line, ' ')
+	if end < 0 {
+		return line, "", fields, fmt.Errorf("statement %s has no fields", line)
+	}
+	keyword = line[:end]
+	rest := line[end:]
+
+	for {
+		rest = strings.TrimLeft(rest, " ")
+		if rest == "" {
+			return keyword, id, fields, nil
+		}
+
+		switch {
+		case rest[0] == '#' && id == "":
+			end := strings.IndexByte(rest, ' ')
+			if end < 0 {
+				end = len(rest)
+			}
+			id = rest[1:end]
+			rest = rest[end:]
+		case rest[0] == '[':
+			value, remaining, err := readBracketed(rest)
+			if err != nil {
+				return "", "", fields, err
+			}
+			fields.positional = value
+			rest = remaining
+		default:
+			eq := strings.Index(rest, "=[")
+			if eq < 0 {
+				return "", "", fields, fmt.Errorf("malformed field near %q", rest)
+			}
+			name := rest[:eq]
+			value, remaining, err := readBracketed(rest[eq+1:])
+			if err != nil {
+				return "", "", fields, err
+			}
+			if _, repeated := fields.named[name]; !repeated {
+				fields.order = append(fields.order, name)
+			}
+			fields.named[name] = value
+			rest = remaining
+		}
+	}
+}
+
+// readBracketed reads an escaped "[value]" from the start of s
+func readBracketed(s string) (value string, rest string, err error) {
+	var sb strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 >= len(s) {
+				return "", "", errors.New("unterminated escape sequence")
+			}
+			i++
+			switch s[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			default:
+				sb.WriteByte(s[i])
+			}
+		case ']':
+			return sb.String(), s[i+1:], nil
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return "", "", errors.New("unterminated bracketed value")
+}
+
+// escapeValue escapes a value for use inside brackets
+func escapeValue(value string) string {
+	if !strings.ContainsAny(value, "\\]\n\r") {
+		return value
+	}
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\', ']':
+			sb.WriteByte('\\')
+			sb.WriteByte(value[i])
+		case '\n':
+			sb.WriteString("\\n")
+		case '\r':
+			sb.WriteString("\\r")
+		default:
+			sb.WriteByte(value[i])
+		}
+	}
+	return sb.String()
+}
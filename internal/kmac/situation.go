@@ -0,0 +1,131 @@
+package kmac
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Situation represents a KMAC situation definition: one coherent scenario,
+// such as a delivery or a landing, grouping the assertions that describe it.
+// A situation may be part of a larger one, such as a landing within a
+// mission.
+type Situation struct {
+	id       string
+	label    string
+	parentID string
+	Metadata
+}
+
+// NewSituation creates a new KMAC situation. parentID names the situation
+// it is part of, or is empty for a top-level situation.
+func NewSituation(id string, label string, parentID string) (*Situation, error) {
+	if id == "" {
+		return nil, errors.New("situation ID cannot be empty")
+	}
+
+	if !validateIdentifier(SituationIDPrefix, id) {
+		return nil, fmt.Errorf("invalid situation ID format: %s", id)
+	}
+
+	if parentID != "" && !validateIdentifier(SituationIDPrefix, parentID) {
+		return nil, fmt.Errorf("invalid situation ID format: %s", parentID)
+	}
+
+	if parentID == id {
+		return nil, fmt.Errorf("situation %s cannot be within itself", id)
+	}
+
+	return &Situation{
+		id:       id,
+		label:    label,
+		parentID: parentID,
+	}, nil
+}
+
+// ID returns the situation's identifier
+func (s *Situation) ID() string {
+	return s.id
+}
+
+// Type returns the statement type
+func (s *Situation) Type() string {
+	return "DEF_SITUATION"
+}
+
+// Label returns the situation's label
+func (s *Situation) Label() string {
+	return s.label
+}
+
+// ParentID returns the identifier of the situation this one is part of, or
+// "" for a top-level situation
+func (s *Situation) ParentID() string {
+	return s.parentID
+}
+
+// String returns a string representation of the situation in KMAC format
+func (s *Situation) String() string {
+	if s.parentID == "" {
+		return fmt.Sprintf("DEF_SITUATION #%s [%s]", s.id, s.label)
+	}
+	return fmt.Sprintf("DEF_SITUATION #%s [%s] within=[#%s]", s.id, s.label, s.parentID)
+}
+
+// Clone returns an independent copy of the situation
+func (s *Situation) Clone() *Situation {
+	c := *s
+	c.Metadata = s.Metadata.clone()
+	return &c
+}
+
+// SituationMember records that an assertion is part of the description of a
+// situation. An assertion may belong to several situations.
+type SituationMember struct {
+	assertionID string
+	situationID string
+}
+
+// NewSituationMember creates a new KMAC situation membership
+func NewSituationMember(assertionID string, situationID string) (*SituationMember, error) {
+	if assertionID == "" || situationID == "" {
+		return nil, errors.New("assertion ID and situation ID cannot be empty")
+	}
+
+	if !validateIdentifier(AssertionIDPrefix, assertionID) {
+		return nil, fmt.Errorf("invalid assertion ID format: %s", assertionID)
+	}
+
+	if !validateIdentifier(SituationIDPrefix, situationID) {
+		return nil, fmt.Errorf("invalid situation ID format: %s", situationID)
+	}
+
+	return &SituationMember{
+		assertionID: assertionID,
+		situationID: situationID,
+	}, nil
+}
+
+// AssertionID returns the member assertion's identifier
+func (m *SituationMember) AssertionID() string {
+	return m.assertionID
+}
+
+// SituationID returns the situation's identifier
+func (m *SituationMember) SituationID() string {
+	return m.situationID
+}
+
+// Type returns the statement type
+func (m *SituationMember) Type() string {
+	return "IN_SITUATION"
+}
+
+// ID returns an identifier for the membership
+func (m *SituationMember) ID() string {
+	return fmt.Sprintf("IS_%s_%s", m.assertionID, m.situationID)
+}
+
+// String returns a string representation of the membership in KMAC format
+func (m *SituationMember) String() string {
+	return fmt.Sprintf("IN_SITUATION #%s situation=[#%s]", m.assertionID, m.situationID)
+}
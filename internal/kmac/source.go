@@ -0,0 +1,143 @@
+package kmac
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// UnknownSourceWeight is the reliability weight of sources a registry does
+// not list, including an empty source
+const UnknownSourceWeight = 0.5
+
+// ConfidenceSource is a kind of evidence an assertion's confidence can come
+// from, with a reliability weight in [0, 1] saying how far evidence of that
+// kind is trusted
+type ConfidenceSource struct {
+	Name        string  `json:"-"`
+	Weight      float64 `json:"weight"`
+	Description string  `json:"description,omitempty"`
+}
+
+// SourceRegistry lists the confidence sources in use and their weights.
+// Source names are matched ignoring case.
+type SourceRegistry struct {
+	sources map[string]ConfidenceSource
+}
+
+// NewSourceRegistry creates an empty source registry
+func NewSourceRegistry() *SourceRegistry {
+	return &SourceRegistry{sources: make(map[string]ConfidenceSource)}
+}
+
+// DefaultSources returns a registry of the common kinds of evidence, from
+// direct observation down to unverified reports
+func DefaultSources() *SourceRegistry {
+	r := NewSourceRegistry()
+	for _, source := range []ConfidenceSource{
+		{"DIRECT_OBSERVATION", 1.0, "seen or measured first hand"},
+		{"INSTRUMENT_MEASUREMENT", 0.95, "read from a calibrated instrument"},
+		{"TRANSIT_OBSERVATIONS", 0.9, "inferred from repeated transits"},
+		{"DERIVED", 1.0, "inferred by the store; confidence already follows the premises"},
+		{"OFFICIAL_REPORT", 0.85, "published by a responsible authority"},
+		{"EXPERT_ASSESSMENT", 0.8, "judged by a domain expert"},
+		{"HISTORICAL_RECORD", 0.7, "taken from archives or past records"},
+		{"MODEL_PREDICTION", 0.6, "predicted by a model or simulation"},
+		{"UNVERIFIED_REPORT", 0.4, "reported but not confirmed"},
+	} {
+		r.sources[source.Name] = source
+	}
+	return r
+}
+
+// Register adds a source, or replaces the source of the same name
+func (r *SourceRegistry) Register(name string, weight float64, description string) error {
+	if name == "" {
+		return errors.New("source name cannot be empty")
+	}
+	if weight < 0.0 || weight > 1.0 {
+		return fmt.Errorf("weight %v of source %s is outside [0, 1]", weight, name)
+	}
+	key := strings.ToUpper(name)
+	r.sources[key] = ConfidenceSource{Name: key, Weight: weight, Description: description}
+	return nil
+}
+
+// Lookup returns a registered source
+func (r *SourceRegistry) Lookup(name string) (ConfidenceSource, bool) {
+	source, ok := r.sources[strings.ToUpper(name)]
+	return source, ok
+}
+
+// Weight returns the reliability weight of a source, or UnknownSourceWeight
+// if it is not registered
+func (r *SourceRegistry) Weight(name string) float64 {
+	if source, ok := r.Lookup(name); ok {
+		return source.Weight
+	}
+	return UnknownSourceWeight
+}
+
+// Sources returns the registered sources, most reliable first, then by name
+func (r *SourceRegistry) Sources() []ConfidenceSource {
+	sources := make([]ConfidenceSource, 0, len(r.sources))
+	for _, name := range sortedKeys(r.sources) {
+		sources = append(sources, r.sources[name])
+	}
+	sort.SliceStable(sources, func(i, j int) bool { return sources[i].Weight > sources[j].Weight })
+	return sources
+}
+
+// Load registers the sources in a JSON object mapping source names to their
+// weight and description, e.g. {"SURVEY": {"weight": 0.8}}
+func (r *SourceRegistry) Load(reader io.Reader) error {
+	var sources map[string]ConfidenceSource
+	if err := json.NewDecoder(reader).Decode(&sources); err != nil {
+		return fmt.Errorf("failed to decode sources: %v", err)
+	}
+	for _, name := range sortedKeys(sources) {
+		if err := r.Register(name, sources[name].Weight, sources[name].Description); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Weighted returns an assertion's confidence scaled by the weight of its
+// source
+func (r *SourceRegistry) Weighted(assertion *Assertion) float64 {
+	level, source := assertion.GetConfidence()
+	return level * r.Weight(source)
+}
+
+// Combine weighs assertions of the same claim, some of which may be negated,
+// and returns the confidence that the claim holds. Each assertion supports
+// its side by its weighted confidence; support on one side is pooled as
+// independent evidence, and the two sides are then combined by Dempster's
+// rule, so evidence against the claim discounts evidence for it.
+func (r *SourceRegistry) Combine(assertions []*Assertion) (float64, error) {
+	if len(assertions) == 0 {
+		return 0, errors.New("no assertions to combine")
+	}
+	first := assertions[0]
+	disbelieveFor, disbelieveAgainst := 1.0, 1.0
+	for _, assertion := range assertions {
+		if assertion.Subject() != first.Subject() || assertion.Relation() != first.Relation() || assertion.Object() != first.Object() {
+			return 0, fmt.Errorf("assertion %s is not about the same claim as %s", assertion.ID(), first.ID())
+		}
+		if assertion.IsNegated() {
+			disbelieveAgainst *= 1 - r.Weighted(assertion)
+		} else {
+			disbelieveFor *= 1 - r.Weighted(assertion)
+		}
+	}
+
+	support, against := 1-disbelieveFor, 1-disbelieveAgainst
+	if support*against == 1 {
+		return 0, fmt.Errorf("evidence about %s is certain on both sides", first.ID())
+	}
+	return support * (1 - against) / (1 - support*against), nil
+}
@@ -0,0 +1,166 @@
+package kmac
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// StateAssertion records the value of an entity attribute at a point in time,
+// so changing conditions can be queried instead of being encoded in TOSID strings
+type StateAssertion struct {
+	id        string
+	entityID  string
+	attribute string
+	value     string
+	timestamp time.Time
+	Metadata
+}
+
+// NewStateAssertion creates a new KMAC state assertion
+func NewStateAssertion(id string, entityID string, attribute string, value string, timestamp time.Time) (*StateAssertion, error) {
+	if id == "" {
+		return nil, errors.New("state assertion ID cannot be empty")
+	}
+
+	if !validateIdentifier(AssertionIDPrefix, id) {
+		return nil, fmt.Errorf("invalid state assertion ID format: %s", id)
+	}
+
+	if entityID == "" || attribute == "" {
+		return nil, errors.New("entity and attribute cannot be empty")
+	}
+
+	return &StateAssertion{
+		id:        id,
+		entityID:  entityID,
+		attribute: attribute,
+		value:     value,
+		timestamp: timestamp,
+	}, nil
+}
+
+// ID returns the state assertion's identifier
+func (s *StateAssertion) ID() string {
+	return s.id
+}
+
+// Type returns the statement type
+func (s *StateAssertion) Type() string {
+	return "STATE"
+}
+
+// EntityID returns the identifier of the entity whose state is recorded
+func (s *StateAssertion) EntityID() string {
+	return s.entityID
+}
+
+// Attribute returns the name of the recorded attribute
+func (s *StateAssertion) Attribute() string {
+	return s.attribute
+}
+
+// Value returns the attribute's value
+func (s *StateAssertion) Value() string {
+	return s.value
+}
+
+// Timestamp returns when the value was observed
+func (s *StateAssertion) Timestamp() time.Time {
+	return s.timestamp
+}
+
+// String returns a string representation of the state assertion in KMAC format
+func (s *StateAssertion) String() string {
+	return fmt.Sprintf("STATE #%s entity=[#%s] attribute=[%s] value=[%s] at=[%s]",
+		s.id, s.entityID, s.attribute, s.value, s.timestamp.Format(time.RFC3339))
+}
+
+// StateHistory keeps the state assertions of a single entity ordered by time
+type StateHistory struct {
+	byAttribute map[string][]*StateAssertion
+}
+
+// NewStateHistory creates an empty state history
+func NewStateHistory() *StateHistory {
+	return &StateHistory{
+		byAttribute: make(map[string][]*StateAssertion),
+	}
+}
+
+// Add records a state assertion, replacing any earlier assertion with the same ID
+func (h *StateHistory) Add(state *StateAssertion) {
+	h.Remove(state.id)
+
+	states := h.byAttribute[state.attribute]
+	// Insert after any states with the same timestamp so later additions win ties
+	i := sort.Search(len(states), func(i int) bool {
+		return states[i].timestamp.After(state.timestamp)
+	})
+	states = append(states, nil)
+	copy(states[i+1:], states[i:])
+	states[i] = state
+	h.byAttribute[state.attribute] = states
+}
+
+// Remove forgets the state assertion with the given ID, reporting whether it was present
+func (h *StateHistory) Remove(id string) bool {
+	for attribute, states := range h.byAttribute {
+		for i, state := range states {
+			if state.id == id {
+				states = append(states[:i], states[i+1:]...)
+				if len(states) == 0 {
+					delete(h.byAttribute, attribute)
+				} else {
+					h.byAttribute[attribute] = states
+				}
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Attributes returns the recorded attribute names in sorted order
+func (h *StateHistory) Attributes() []string {
+	return sortedKeys(h.byAttribute)
+}
+
+// History returns every recorded value of an attribute, oldest first
+func (h *StateHistory) History(attribute string) []*StateAssertion {
+	states := h.byAttribute[attribute]
+	return append([]*StateAssertion(nil), states...)
+}
+
+// Current returns the most recent value of an attribute
+func (h *StateHistory) Current(attribute string) (*StateAssertion, bool) {
+	states := h.byAttribute[attribute]
+	if len(states) == 0 {
+		return nil, false
+	}
+	return states[len(states)-1], true
+}
+
+// At returns the value of an attribute in effect at the given time, which is
+// the latest value recorded at or before it
+func (h *StateHistory) At(attribute string, t time.Time) (*StateAssertion, bool) {
+	states := h.byAttribute[attribute]
+	i := sort.Search(len(states), func(i int) bool {
+		return states[i].timestamp.After(t)
+	})
+	if i == 0 {
+		return nil, false
+	}
+	return states[i-1], true
+}
+
+// Clone returns a copy of the history that later additions and removals do
+// not affect. The state assertions themselves are shared.
+func (h *StateHistory) Clone() *StateHistory {
+	c := NewStateHistory()
+	for attribute, states := range h.byAttribute {
+		c.byAttribute[attribute] = append([]*StateAssertion(nil), states...)
+	}
+	return c
+}
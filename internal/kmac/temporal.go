@@ -82,6 +82,11 @@ func NewTemporalWithDuration(assertionID string, state string, startTime, endTim
 	return temporal, nil
 }
 
+// ID returns the identifier of the qualified assertion
+func (t *Temporal) ID() string {
+	return t.assertionID
+}
+
 // AssertionID returns the associated assertion's identifier
 func (t *Temporal) AssertionID() string {
 	return t.assertionID
@@ -238,4 +243,10 @@ func (c *Causation) ID() string {
 func (c *Causation) String() string {
 	return fmt.Sprintf("CAUSATION source=[#%s] target=[#%s] type=[%s]", 
 		c.sourceID, c.targetID, c.causationType)
-}
\ No newline at end of file
+}
+
+// Clone returns an independent copy of the temporal qualification
+func (t *Temporal) Clone() *Temporal {
+	c := *t
+	return &c
+}
@@ -0,0 +1,78 @@
+package kmac
+
+import (
+	"strings"
+)
+
+// TextField is a named bracketed value of a KMAC text line
+type TextField struct {
+	Name  string
+	Value string // Unescaped; references keep their leading '#'
+}
+
+// TextLine is one line of KMAC text broken into its parts, so statements can
+// be carried through other formats without knowing every statement type
+type TextLine struct {
+	Keyword string
+	ID      string // Without the leading '#'; empty if the line has none
+	Label   string // The unnamed bracketed value, if any
+	Fields  []TextField
+}
+
+// ParseTextLine breaks a line of KMAC text into its parts
+func ParseTextLine(line string) (*TextLine, error) {
+	keyword, id, fields, err := splitLine(strings.TrimSpace(line))
+	if err != nil {
+		return nil, err
+	}
+	textLine := &TextLine{Keyword: keyword, ID: id, Label: fields.positional}
+	for _, name := range fields.order {
+		textLine.Fields = append(textLine.Fields, TextField{Name: name, Value: fields.named[name]})
+	}
+	return textLine, nil
+}
+
+// String renders the line as KMAC text
+func (l *TextLine) String() string {
+	var sb strings.Builder
+	sb.WriteString(l.Keyword)
+	if l.ID != "" {
+		sb.WriteString(" #" + l.ID)
+	}
+	if l.Label != "" {
+		sb.WriteString(" [" + escapeValue(l.Label) + "]")
+	}
+	for _, field := range l.Fields {
+		sb.WriteString(" " + field.Name + "=[" + escapeValue(field.Value) + "]")
+	}
+	return sb.String()
+}
+
+// LineDecoder decodes KMAC text a line at a time, for reading streams too
+// large to hold. It keeps the statements decoded so far by ID, so qualifier
+// lines can be applied to them.
+type LineDecoder struct {
+	ts   *TextSerializer
+	byID map[string]Statement
+}
+
+// NewLineDecoder creates a decoder for KMAC text lines
+func (ts *TextSerializer) NewLineDecoder() *LineDecoder {
+	return &LineDecoder{ts: ts, byID: make(map[string]Statement)}
+}
+
+// Decode parses one line. Blank lines, comments, and qualifier lines, which
+// are applied to the most recent statement with the same ID, return a nil
+// statement.
+func (d *LineDecoder) Decode(line string) (Statement, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+	stmt, err := d.ts.parseLine(line, d.byID)
+	if err != nil || stmt == nil {
+		return nil, err
+	}
+	d.byID[stmt.ID()] = stmt
+	return stmt, nil
+}
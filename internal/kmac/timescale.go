@@ -0,0 +1,387 @@
+package kmac
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Time scales and calendars a time reference value may be written in. The
+// value itself is always held as a UTC instant; the scale only governs how
+// it is read and written.
+const (
+	ScaleUTC    = "UTC"    // Coordinated Universal Time, the default
+	ScaleTAI    = "TAI"    // International Atomic Time
+	ScaleTT     = "TT"     // Terrestrial Time, TAI + 32.184s
+	ScaleGPS    = "GPS"    // GPS time, TAI - 19s
+	ScaleMET    = "MET"    // Mission elapsed time from an epoch
+	ScaleJulian = "JULIAN" // Julian calendar date and UTC time of day
+	ScaleJD     = "JD"     // Julian Date in UTC days
+	ScaleMJD    = "MJD"    // Modified Julian Date, JD - 2400000.5
+)
+
+// scaleLayout writes atomic and Julian calendar readings without a zone,
+// since they are not UTC wall-clock times
+const scaleLayout = "2006-01-02T15:04:05.999999999"
+
+// leapSeconds lists when TAI - UTC changed since integer leap seconds began
+// in 1972. Earlier times use the 1972 offset of ten seconds.
+var leapSeconds = []struct {
+	from   time.Time
+	offset time.Duration
+}{
+	{time.Date(1972, 7, 1, 0, 0, 0, 0, time.UTC), 11 * time.Second},
+	{time.Date(1973, 1, 1, 0, 0, 0, 0, time.UTC), 12 * time.Second},
+	{time.Date(1974, 1, 1, 0, 0, 0, 0, time.UTC), 13 * time.Second},
+	{time.Date(1975, 1, 1, 0, 0, 0, 0, time.UTC), 14 * time.Second},
+	{time.Date(1976, 1, 1, 0, 0, 0, 0, time.UTC), 15 * time.Second},
+	{time.Date(1977, 1, 1, 0, 0, 0, 0, time.UTC), 16 * time.Second},
+	{time.Date(1978, 1, 1, 0, 0, 0, 0, time.UTC), 17 * time.Second},
+	{time.Date(1979, 1, 1, 0, 0, 0, 0, time.UTC), 18 * time.Second},
+	{time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC), 19 * time.Second},
+	{time.Date(1981, 7, 1, 0, 0, 0, 0, time.UTC), 20 * time.Second},
+	{time.Date(1982, 7, 1, 0, 0, 0, 0, time.UTC), 21 * time.Second},
+	{time.Date(1983, 7, 1, 0, 0, 0, 0, time.UTC), 22 * time.Second},
+	{time.Date(1985, 7, 1, 0, 0, 0, 0, time.UTC), 23 * time.Second},
+	{time.Date(1988, 1, 1, 0, 0, 0, 0, time.UTC), 24 * time.Second},
+	{time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC), 25 * time.Second},
+	{time.Date(1991, 1, 1, 0, 0, 0, 0, time.UTC), 26 * time.Second},
+	{time.Date(1992, 7, 1, 0, 0, 0, 0, time.UTC), 27 * time.Second},
+	{time.Date(1993, 7, 1, 0, 0, 0, 0, time.UTC), 28 * time.Second},
+	{time.Date(1994, 7, 1, 0, 0, 0, 0, time.UTC), 29 * time.Second},
+	{time.Date(1996, 1, 1, 0, 0, 0, 0, time.UTC), 30 * time.Second},
+	{time.Date(1997, 7, 1, 0, 0, 0, 0, time.UTC), 31 * time.Second},
+	{time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC), 32 * time.Second},
+	{time.Date(2006, 1, 1, 0, 0, 0, 0, time.UTC), 33 * time.Second},
+	{time.Date(2009, 1, 1, 0, 0, 0, 0, time.UTC), 34 * time.Second},
+	{time.Date(2012, 7, 1, 0, 0, 0, 0, time.UTC), 35 * time.Second},
+	{time.Date(2015, 7, 1, 0, 0, 0, 0, time.UTC), 36 * time.Second},
+	{time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC), 37 * time.Second},
+}
+
+// TAIMinusUTC returns the offset of TAI from UTC at a UTC instant
+func TAIMinusUTC(t time.Time) time.Duration {
+	offset := 10 * time.Second
+	for _, leap := range leapSeconds {
+		if t.Before(leap.from) {
+			break
+		}
+		offset = leap.offset
+	}
+	return offset
+}
+
+// atomicOffset returns how far an atomic scale reads ahead of UTC
+func atomicOffset(t time.Time, scale string) time.Duration {
+	offset := TAIMinusUTC(t)
+	switch scale {
+	case ScaleTT:
+		offset += 32184 * time.Millisecond
+	case ScaleGPS:
+		offset -= 19 * time.Second
+	}
+	return offset
+}
+
+// ToAtomic returns the reading of TAI, TT, or GPS time at a UTC instant. The
+// result carries the UTC location but is a reading of the other scale.
+func ToAtomic(t time.Time, scale string) (time.Time, error) {
+	if !isAtomic(scale) {
+		return time.Time{}, fmt.Errorf("%s is not an atomic time scale", scale)
+	}
+	t = t.UTC()
+	return t.Add(atomicOffset(t, scale)), nil
+}
+
+// FromAtomic returns the UTC instant of a TAI, TT, or GPS reading
+func FromAtomic(reading time.Time, scale string) (time.Time, error) {
+	if !isAtomic(scale) {
+		return time.Time{}, fmt.Errorf("%s is not an atomic time scale", scale)
+	}
+	reading = reading.UTC()
+	utc := reading.Add(-atomicOffset(reading, scale))
+	// The offset may have changed between the reading and the UTC instant
+	return reading.Add(-atomicOffset(utc, scale)), nil
+}
+
+func isAtomic(scale string) bool {
+	return scale == ScaleTAI || scale == ScaleTT || scale == ScaleGPS
+}
+
+// JulianDate returns the Julian Date of a UTC instant
+func JulianDate(t time.Time) float64 {
+	t = t.UTC()
+	return 2440587.5 + float64(t.Unix())/86400 + float64(t.Nanosecond())/86400e9
+}
+
+// FromJulianDate returns the UTC instant of a Julian Date, to the microsecond
+func FromJulianDate(jd float64) time.Time {
+	days := jd - 2440587.5
+	whole := math.Floor(days)
+	micros := math.Round((days - whole) * 86400e6)
+	return time.Unix(int64(whole)*86400, 0).Add(time.Duration(micros) * time.Microsecond).UTC()
+}
+
+// JulianCalendarDate returns the date of a UTC instant in the Julian calendar
+func JulianCalendarDate(t time.Time) (year int, month time.Month, day int) {
+	t = t.UTC()
+	c := dayNumber(t) + 32082
+	d := floorDiv(4*c+3, 1461)
+	e := c - floorDiv(1461*d, 4)
+	m := floorDiv(5*e+2, 153)
+	day = e - floorDiv(153*m+2, 5) + 1
+	month = time.Month(m + 3 - 12*(m/10))
+	year = d - 4800 + m/10
+	return year, month, day
+}
+
+// FromJulianCalendar returns the UTC midnight starting a Julian calendar date
+func FromJulianCalendar(year int, month time.Month, day int) time.Time {
+	a := (14 - int(month)) / 12
+	y := year + 4800 - a
+	m := int(month) + 12*a - 3
+	jdn := day + (153*m+2)/5 + 365*y + floorDiv(y, 4) - 32083
+	return time.Unix(int64(jdn-2440588)*86400, 0).UTC()
+}
+
+// dayNumber returns the Julian Day Number of the UTC day containing t
+func dayNumber(t time.Time) int {
+	return int(floorDiv64(t.Unix(), 86400)) + 2440588
+}
+
+func floorDiv64(a int64, b int64) int64 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+// FormatMET renders a mission elapsed time as T+DDD:HH:MM:SS, with a
+// fractional second when there is one and T- before the epoch
+func FormatMET(elapsed time.Duration) string {
+	sign := "+"
+	if elapsed < 0 {
+		sign, elapsed = "-", -elapsed
+	}
+	days := elapsed / (24 * time.Hour)
+	elapsed -= days * 24 * time.Hour
+	hours := elapsed / time.Hour
+	elapsed -= hours * time.Hour
+	minutes := elapsed / time.Minute
+	elapsed -= minutes * time.Minute
+	seconds := elapsed / time.Second
+	text := fmt.Sprintf("T%s%03d:%02d:%02d:%02d", sign, days, hours, minutes, seconds)
+	if fraction := elapsed - seconds*time.Second; fraction != 0 {
+		text += strings.TrimRight(fmt.Sprintf(".%09d", fraction), "0")
+	}
+	return text
+}
+
+// ParseMET parses a mission elapsed time in the form FormatMET writes. The
+// leading T is optional and leading fields may be omitted, so 102:45:40 is
+// 102 hours, 45 minutes, and 40 seconds.
+func ParseMET(text string) (time.Duration, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(text), "T")
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimLeft(s, "+-")
+	fields := strings.Split(s, ":")
+	if s == "" || len(fields) > 4 {
+		return 0, fmt.Errorf("invalid mission elapsed time %q", text)
+	}
+
+	units := []time.Duration{time.Second, time.Minute, time.Hour, 24 * time.Hour}
+	var elapsed time.Duration
+	for i, field := range fields {
+		unit := units[len(fields)-1-i]
+		if i == len(fields)-1 {
+			seconds, err := strconv.ParseFloat(field, 64)
+			if err != nil || seconds < 0 {
+				return 0, fmt.Errorf("invalid mission elapsed time %q", text)
+			}
+			elapsed += time.Duration(math.Round(seconds * float64(time.Second)))
+			continue
+		}
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid mission elapsed time %q", text)
+		}
+		elapsed += time.Duration(n) * unit
+	}
+	if negative {
+		elapsed = -elapsed
+	}
+	return elapsed, nil
+}
+
+// FormatInScale renders a UTC instant as a reading of a scale. Mission
+// elapsed time is measured from epoch.
+func FormatInScale(t time.Time, scale string, epoch time.Time) (string, error) {
+	switch scale {
+	case "", ScaleUTC:
+		return t.UTC().Format(time.RFC3339Nano), nil
+	case ScaleTAI, ScaleTT, ScaleGPS:
+		reading, _ := ToAtomic(t, scale)
+		return reading.Format(scaleLayout), nil
+	case ScaleMET:
+		return FormatMET(t.Sub(epoch)), nil
+	case ScaleJulian:
+		year, month, day := JulianCalendarDate(t)
+		text := fmt.Sprintf("%04d-%02d-%02d", year, month, day)
+		if clock := t.UTC().Format("15:04:05.999999999"); clock != "00:00:00" {
+			text += "T" + clock
+		}
+		return text, nil
+	case ScaleJD:
+		return strconv.FormatFloat(JulianDate(t), 'f', -1, 64), nil
+	case ScaleMJD:
+		return strconv.FormatFloat(JulianDate(t)-2400000.5, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("unknown time scale %s", scale)
+}
+
+// ParseInScale parses a reading of a scale, as FormatInScale writes it, into
+// a UTC instant
+func ParseInScale(text string, scale string, epoch time.Time) (time.Time, error) {
+	switch scale {
+	case "", ScaleUTC:
+		return time.Parse(time.RFC3339Nano, text)
+	case ScaleTAI, ScaleTT, ScaleGPS:
+		reading, err := time.Parse(scaleLayout, strings.TrimSuffix(text, "Z"))
+		if err != nil {
+			return time.Time{}, err
+		}
+		return FromAtomic(reading, scale)
+	case ScaleMET:
+		elapsed, err := ParseMET(text)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return epoch.Add(elapsed), nil
+	case ScaleJulian:
+		return parseJulianCalendar(text)
+	case ScaleJD, ScaleMJD:
+		days, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid %s %q", scale, text)
+		}
+		if scale == ScaleMJD {
+			days += 2400000.5
+		}
+		return FromJulianDate(days), nil
+	}
+	return time.Time{}, fmt.Errorf("unknown time scale %s", scale)
+}
+
+// parseJulianCalendar parses a Julian calendar date with an optional time
+// of day. It cannot use time.Parse, which rejects Julian leap days such as
+// 1700-02-29.
+func parseJulianCalendar(text string) (time.Time, error) {
+	date, clock, hasClock := strings.Cut(text, "T")
+	var year, month, day int
+	if n, err := fmt.Sscanf(date, "%d-%d-%d", &year, &month, &day); err != nil || n != 3 {
+		return time.Time{}, fmt.Errorf("invalid Julian calendar date %q", text)
+	}
+	if month < 1 || month > 12 || day < 1 || day > julianMonthDays(year, time.Month(month)) {
+		return time.Time{}, fmt.Errorf("invalid Julian calendar date %q", text)
+	}
+	t := FromJulianCalendar(year, time.Month(month), day)
+	if hasClock {
+		offset, err := time.Parse("15:04:05.999999999", clock)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time of day in %q", text)
+		}
+		t = t.Add(offset.Sub(time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)))
+	}
+	return t, nil
+}
+
+// julianMonthDays returns the length of a month in the Julian calendar,
+// where every fourth year is a leap year
+func julianMonthDays(year int, month time.Month) int {
+	switch month {
+	case time.February:
+		if floorDiv(year, 4)*4 == year {
+			return 29
+		}
+		return 28
+	case time.April, time.June, time.September, time.November:
+		return 30
+	}
+	return 31
+}
+
+// SetScale sets the time scale or calendar the value is written in. Mission
+// elapsed time needs an epoch, so use SetMissionEpoch for it.
+func (t *TimeReference) SetScale(scale string) error {
+	scale = strings.ToUpper(scale)
+	switch scale {
+	case "", ScaleUTC:
+		t.scale, t.epoch = "", nil
+	case ScaleTAI, ScaleTT, ScaleGPS, ScaleJulian, ScaleJD, ScaleMJD:
+		t.scale, t.epoch = scale, nil
+	case ScaleMET:
+		if t.epoch == nil {
+			return errors.New("mission elapsed time needs an epoch")
+		}
+	default:
+		return fmt.Errorf("unknown time scale %s", scale)
+	}
+	return nil
+}
+
+// SetMissionEpoch writes the value as mission elapsed time from an epoch
+func (t *TimeReference) SetMissionEpoch(epoch time.Time) {
+	t.scale, t.epoch = ScaleMET, &epoch
+}
+
+// Scale returns the time scale or calendar the value is written in
+func (t *TimeReference) Scale() string {
+	if t.scale == "" {
+		return ScaleUTC
+	}
+	return t.scale
+}
+
+// MissionEpoch returns the epoch of a mission elapsed time
+func (t *TimeReference) MissionEpoch() (time.Time, bool) {
+	if t.epoch == nil {
+		return time.Time{}, false
+	}
+	return *t.epoch, true
+}
+
+// MissionElapsed returns the time elapsed from the mission epoch to the value
+func (t *TimeReference) MissionElapsed() (time.Duration, bool) {
+	if t.epoch == nil {
+		return 0, false
+	}
+	return t.value.Sub(*t.epoch), true
+}
+
+// ScaleValue renders the value in its time scale
+func (t *TimeReference) ScaleValue() string {
+	var epoch time.Time
+	if t.epoch != nil {
+		epoch = *t.epoch
+	}
+	value, err := FormatInScale(t.value, t.scale, epoch)
+	if err != nil {
+		return t.value.Format(time.RFC3339Nano)
+	}
+	return value
+}
+
+// NewMissionElapsedTime creates a time reference at an elapsed time from a
+// mission epoch, written as mission elapsed time
+func NewMissionElapsedTime(id string, timeType string, epoch time.Time, elapsed time.Duration) (*TimeReference, error) {
+	timeRef, err := NewTimeReference(id, timeType, epoch.Add(elapsed))
+	if err != nil {
+		return nil, err
+	}
+	timeRef.SetMissionEpoch(epoch)
+	return timeRef, nil
+}
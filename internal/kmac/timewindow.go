@@ -0,0 +1,187 @@
+package kmac
+
+import (
+	"errors"
+	"time"
+)
+
+// TimeWindow is a span of time from Start up to, but not including, End. An
+// instant has equal Start and End.
+type TimeWindow struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Contains reports whether a time falls in the window
+func (w TimeWindow) Contains(t time.Time) bool {
+	if w.Start.Equal(w.End) {
+		return t.Equal(w.Start)
+	}
+	return !t.Before(w.Start) && t.Before(w.End)
+}
+
+// Overlaps reports whether the window shares any time with [from, to)
+func (w TimeWindow) Overlaps(from time.Time, to time.Time) bool {
+	if w.Start.Equal(w.End) {
+		return !w.Start.Before(from) && w.Start.Before(to)
+	}
+	return w.Start.Before(to) && w.End.After(from)
+}
+
+// NewTimeInterval creates a time reference spanning start to end
+func NewTimeInterval(id string, timeType string, start time.Time, end time.Time) (*TimeReference, error) {
+	timeRef, err := NewTimeReference(id, timeType, start)
+	if err != nil {
+		return nil, err
+	}
+	if err := timeRef.SetEnd(end); err != nil {
+		return nil, err
+	}
+	return timeRef, nil
+}
+
+// SetEnd makes the time reference an interval ending at end
+func (t *TimeReference) SetEnd(end time.Time) error {
+	if end.Before(t.value) {
+		return errors.New("interval end cannot precede its start")
+	}
+	t.end = &end
+	t.duration = 0
+	return nil
+}
+
+// End returns the end of an interval
+func (t *TimeReference) End() (time.Time, bool) {
+	if t.end == nil {
+		return time.Time{}, false
+	}
+	return *t.end, true
+}
+
+// SetDuration makes the time reference an interval of the given length,
+// which for recurring references is the length of each occurrence
+func (t *TimeReference) SetDuration(duration time.Duration) error {
+	if duration < 0 {
+		return errors.New("duration cannot be negative")
+	}
+	t.duration = duration
+	t.end = nil
+	return nil
+}
+
+// Duration returns the length of the interval, or of each occurrence; zero
+// for an instant
+func (t *TimeReference) Duration() time.Duration {
+	if t.end != nil {
+		return t.end.Sub(t.value)
+	}
+	return t.duration
+}
+
+// SetRecurrence makes the time reference repeat from its value by a rule.
+// A nil rule makes it a single occurrence again.
+func (t *TimeReference) SetRecurrence(recurrence *Recurrence) {
+	t.recurrence = recurrence
+}
+
+// Recurrence returns the recurrence rule, if any
+func (t *TimeReference) Recurrence() *Recurrence {
+	return t.recurrence
+}
+
+// Windows returns the occurrences of the time reference that overlap
+// [from, to), in order
+func (t *TimeReference) Windows(from time.Time, to time.Time) []TimeWindow {
+	duration := t.Duration()
+	if t.recurrence == nil {
+		window := TimeWindow{Start: t.value, End: t.value.Add(duration)}
+		if window.Overlaps(from, to) {
+			return []TimeWindow{window}
+		}
+		return nil
+	}
+
+	var windows []TimeWindow
+	for _, start := range t.recurrence.Occurrences(t.value, from.Add(-duration), to) {
+		window := TimeWindow{Start: start, End: start.Add(duration)}
+		if window.Overlaps(from, to) {
+			windows = append(windows, window)
+		}
+	}
+	return windows
+}
+
+// Contains reports whether a time falls in any occurrence of the reference
+func (t *TimeReference) Contains(at time.Time) bool {
+	for _, window := range t.Windows(at, at.Add(time.Nanosecond)) {
+		if window.Contains(at) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextWindow returns the occurrence in progress at a time, or else the next
+// one to start after it
+func (t *TimeReference) NextWindow(after time.Time) (TimeWindow, bool) {
+	duration := t.Duration()
+	if t.recurrence == nil {
+		window := TimeWindow{Start: t.value, End: t.value.Add(duration)}
+		return window, window.End.After(after) || (duration == 0 && !window.Start.Before(after))
+	}
+	for _, window := range t.Windows(after, after.Add(time.Nanosecond)) {
+		if window.Contains(after) {
+			return window, true
+		}
+	}
+	start, found := t.recurrence.Next(t.value, after)
+	if !found {
+		return TimeWindow{}, false
+	}
+	return TimeWindow{Start: start, End: start.Add(duration)}, true
+}
+
+// NewApproximateTimeReference creates a time reference known only to some
+// precision. Its value is the approximate time's representative instant.
+func NewApproximateTimeReference(id string, timeType string, approx *ApproximateTime) (*TimeReference, error) {
+	if approx == nil {
+		return nil, errors.New("approximate time cannot be nil")
+	}
+	timeRef, err := NewTimeReference(id, timeType, approx.Representative())
+	if err != nil {
+		return nil, err
+	}
+	timeRef.approx = approx
+	return timeRef, nil
+}
+
+// Approximate returns the approximate time of the reference, if it has one
+func (t *TimeReference) Approximate() (*ApproximateTime, bool) {
+	return t.approx, t.approx != nil
+}
+
+// bounds returns the span of instants the reference may stand for: its
+// approximate time, or else its value up to the end of its interval
+func (t *TimeReference) bounds() timeBounds {
+	if t.approx != nil {
+		return t.approx.bounds()
+	}
+	end := t.value.Add(t.Duration())
+	if t.Duration() == 0 {
+		end = t.value.Add(time.Nanosecond)
+	}
+	return timeBounds{earliest: t.value, latest: end, hasEarliest: true, hasLatest: true}
+}
+
+// Before reports whether the time precedes another, allowing for
+// approximate times. An interval precedes another time only if it ends
+// before the other begins.
+func (t *TimeReference) Before(other *TimeReference) Comparison {
+	return t.bounds().before(other.bounds())
+}
+
+// After reports whether the time follows another, allowing for approximate
+// times
+func (t *TimeReference) After(other *TimeReference) Comparison {
+	return other.Before(t)
+}
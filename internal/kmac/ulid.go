@@ -0,0 +1,125 @@
+package kmac
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ULIDSeparator separates the prefix of a ULID identifier from the ULID,
+// as in E-01HQ3K5Z8M2XWT6V9RBYDC4NJA
+const ULIDSeparator = "-"
+
+// ulidLength is the length of a ULID in Crockford base32
+const ulidLength = 26
+
+// crockford is the Crockford base32 alphabet ULIDs are written in; it leaves
+// out I, L, O, and U, and sorts in the same order as the values it encodes
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDGenerator issues prefixed ULID identifiers: a millisecond timestamp
+// followed by random bits, so producers can mint IDs independently without
+// coordinating a counter. IDs from one generator sort in the order they were
+// issued, even within a millisecond or if the clock steps back; IDs from
+// different generators sort by the millisecond they were issued in. A
+// generator is safe for concurrent use.
+type ULIDGenerator struct {
+	mu      sync.Mutex
+	entropy io.Reader
+	now     func() time.Time
+	last    [16]byte // The last ULID issued: 6 bytes of time, then 10 random
+}
+
+// NewULIDGenerator creates a ULID generator drawing on the system clock and
+// cryptographic randomness
+func NewULIDGenerator() *ULIDGenerator {
+	return &ULIDGenerator{entropy: rand.Reader, now: time.Now}
+}
+
+// New issues an identifier with the given prefix, such as
+// E-01HQ3K5Z8M2XWT6V9RBYDC4NJA
+func (g *ULIDGenerator) New(prefix string) (string, error) {
+	if prefix == "" {
+		return "", errors.New("ULID prefix cannot be empty")
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(g.now().UnixMilli())
+	var ulid [16]byte
+	for i := 0; i < 6; i++ {
+		ulid[i] = byte(ms >> (40 - 8*i))
+	}
+
+	// Within the millisecond of the last ID, or before it, count up from the
+	// last ID so the order holds
+	if string(ulid[:6]) <= string(g.last[:6]) {
+		ulid = g.last
+		i := 15
+		for ; i >= 6; i-- {
+			ulid[i]++
+			if ulid[i] != 0 {
+				break
+			}
+		}
+		if i < 6 {
+			return "", errors.New("ULIDs exhausted within a millisecond")
+		}
+	} else if _, err := io.ReadFull(g.entropy, ulid[6:]); err != nil {
+		return "", fmt.Errorf("failed to read ULID entropy: %v", err)
+	}
+
+	g.last = ulid
+	return prefix + ULIDSeparator + encodeULID(ulid), nil
+}
+
+// IsULIDIdentifier reports whether an ID is the prefix, the separator, and
+// a ULID in canonical upper-case Crockford base32
+func IsULIDIdentifier(prefix string, id string) bool {
+	ulid, found := strings.CutPrefix(id, prefix+ULIDSeparator)
+	if !found || len(ulid) != ulidLength || ulid[0] > '7' {
+		return false
+	}
+	for i := 0; i < len(ulid); i++ {
+		if strings.IndexByte(crockford, ulid[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ULIDTime returns the time a ULID identifier was issued, to the millisecond
+func ULIDTime(id string) (time.Time, error) {
+	prefix, ulid, found := strings.Cut(id, ULIDSeparator)
+	if !found || !IsULIDIdentifier(prefix, id) {
+		return time.Time{}, fmt.Errorf("%s is not a ULID identifier", id)
+	}
+	var ms int64
+	for i := 0; i < 10; i++ {
+		ms = ms<<5 | int64(strings.IndexByte(crockford, ulid[i]))
+	}
+	return time.UnixMilli(ms), nil
+}
+
+// encodeULID writes the 128 bits of a ULID as 26 base32 digits, the first
+// carrying the top 3 bits
+func encodeULID(ulid [16]byte) string {
+	var out [ulidLength]byte
+	for i := range out {
+		var digit byte
+		for b := 0; b < 5; b++ {
+			bit := i*5 + b - 2
+			digit <<= 1
+			if bit >= 0 && ulid[bit/8]&(0x80>>(bit%8)) != 0 {
+				digit |= 1
+			}
+		}
+		out[i] = crockford[digit]
+	}
+	return string(out[:])
+}
@@ -1,11 +1,24 @@
 package kmac
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"strings"
+	"sync"
 )
 
+// sortedKeys returns the keys of a map in sorted order, so that output built
+// from map contents is stable from run to run
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // StatementCollection represents a collection of KMAC statements
 type StatementCollection struct {
 	statements map[string]Statement
@@ -47,15 +60,25 @@ func (sc *StatementCollection) Remove(id string) bool {
 	return false
 }
 
-// GetAll returns all statements
+// GetAll returns all statements ordered by ID
 func (sc *StatementCollection) GetAll() []Statement {
 	statements := make([]Statement, 0, len(sc.statements))
-	for _, stmt := range sc.statements {
-		statements = append(statements, stmt)
+	for _, id := range sortedKeys(sc.statements) {
+		statements = append(statements, sc.statements[id])
 	}
 	return statements
 }
 
+// Range calls fn for each statement without building a result slice.
+// Iteration stops when fn returns false.
+func (sc *StatementCollection) Range(fn func(Statement) bool) {
+	for _, stmt := range sc.statements {
+		if !fn(stmt) {
+			return
+		}
+	}
+}
+
 // GetByType returns all statements of a specific type
 func (sc *StatementCollection) GetByType(statementType string) []Statement {
 	var statements []Statement
@@ -88,6 +111,83 @@ func (sc *StatementCollection) FilterByPrefix(prefix string) []Statement {
 	return statements
 }
 
+// FindParticipants returns the participations of an event, in ID order. An
+// empty role matches every role.
+func (sc *StatementCollection) FindParticipants(eventID string, role string) []*Participation {
+	return sc.findParticipations(func(p *Participation) bool {
+		return p.EventID() == eventID && (role == "" || p.Role() == role)
+	})
+}
+
+// FindParticipations returns the events an entity took part in, in ID order.
+// An empty role matches every role.
+func (sc *StatementCollection) FindParticipations(entityID string, role string) []*Participation {
+	return sc.findParticipations(func(p *Participation) bool {
+		return p.EntityID() == entityID && (role == "" || p.Role() == role)
+	})
+}
+
+// findParticipations returns the participations matching a predicate, in ID order
+func (sc *StatementCollection) findParticipations(match func(*Participation) bool) []*Participation {
+	var results []*Participation
+	for _, id := range sortedKeys(sc.statements) {
+		if p, ok := sc.statements[id].(*Participation); ok && match(p) {
+			results = append(results, p)
+		}
+	}
+	return results
+}
+
+// TasksForPlan returns the tasks belonging to a plan, in ID order
+func (sc *StatementCollection) TasksForPlan(planID string) []*Task {
+	var tasks []*Task
+	for _, id := range sortedKeys(sc.statements) {
+		if task, ok := sc.statements[id].(*Task); ok && task.PlanID() == planID {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks
+}
+
+// planTasks returns a plan's tasks and the dependencies between them
+func (sc *StatementCollection) planTasks(planID string) ([]*Task, []*Dependency, error) {
+	if _, ok := sc.statements[planID].(*Plan); !ok {
+		return nil, nil, fmt.Errorf("plan %s not found", planID)
+	}
+
+	tasks := sc.TasksForPlan(planID)
+	inPlan := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		inPlan[task.ID()] = true
+	}
+
+	var dependencies []*Dependency
+	for _, id := range sortedKeys(sc.statements) {
+		if dep, ok := sc.statements[id].(*Dependency); ok && inPlan[dep.TaskID()] {
+			dependencies = append(dependencies, dep)
+		}
+	}
+	return tasks, dependencies, nil
+}
+
+// PlanOrder returns a plan's tasks ordered so each follows the tasks it depends on
+func (sc *StatementCollection) PlanOrder(planID string) ([]*Task, error) {
+	tasks, dependencies, err := sc.planTasks(planID)
+	if err != nil {
+		return nil, err
+	}
+	return TopologicalOrder(tasks, dependencies)
+}
+
+// SchedulePlan computes the earliest-start schedule and critical path of a plan
+func (sc *StatementCollection) SchedulePlan(planID string) (*Schedule, error) {
+	tasks, dependencies, err := sc.planTasks(planID)
+	if err != nil {
+		return nil, err
+	}
+	return ScheduleTasks(tasks, dependencies)
+}
+
 // GetStatistics returns statistics about the collection
 func (sc *StatementCollection) GetStatistics() map[string]int {
 	stats := make(map[string]int)
@@ -103,6 +203,12 @@ func (sc *StatementCollection) GetStatistics() map[string]int {
 
 // ExportToStrings converts all statements to their string representations
 func (sc *StatementCollection) ExportToStrings() []string {
+	strings, _ := sc.ExportToStringsContext(context.Background())
+	return strings
+}
+
+// ExportToStringsContext converts all statements to their string representations, stopping early if ctx is done
+func (sc *StatementCollection) ExportToStringsContext(ctx context.Context) ([]string, error) {
 	var strings []string
 	
 	// Get all statements and sort by ID for consistent output
@@ -113,18 +219,23 @@ func (sc *StatementCollection) ExportToStrings() []string {
 	sort.Strings(ids)
 	
 	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		strings = append(strings, sc.statements[id].String())
 	}
 	
-	return strings
+	return strings, nil
 }
 
 // Validate checks all statements for consistency
 func (sc *StatementCollection) Validate() []string {
 	var warnings []string
 	
-	// Check each statement individually
-	for id, stmt := range sc.statements {
+	// Check each statement individually, in ID order so reports are stable
+	ids := sortedKeys(sc.statements)
+	for _, id := range ids {
+		stmt := sc.statements[id]
 		if err := ValidateKMACStatement(stmt); err != nil {
 			warnings = append(warnings, fmt.Sprintf("Statement %s: %v", id, err))
 		}
@@ -132,21 +243,38 @@ func (sc *StatementCollection) Validate() []string {
 	
 	// Check for reference consistency
 	entityIDs := make(map[string]bool)
+	eventIDs := make(map[string]bool)
 	relationIDs := make(map[string]bool)
+	planIDs := make(map[string]bool)
+	taskIDs := make(map[string]bool)
+	situationIDs := make(map[string]bool)
 	
-	// Collect all entity and relation IDs
+	// Collect all entity and relation IDs. Assertions may be the subject or
+	// object of other assertions, so they count as referenceable nodes too.
 	for _, stmt := range sc.statements {
 		switch s := stmt.(type) {
 		case *Entity:
 			entityIDs[s.ID()] = true
+		case *Assertion:
+			entityIDs[s.ID()] = true
+		case *NaryAssertion:
+			entityIDs[s.ID()] = true
+		case *Event:
+			eventIDs[s.ID()] = true
+		case *Plan:
+			planIDs[s.ID()] = true
+		case *Task:
+			taskIDs[s.ID()] = true
+		case *Situation:
+			situationIDs[s.ID()] = true
 		case *Relation:
 			relationIDs[s.ID()] = true
 		}
 	}
 	
 	// Check assertions for valid references
-	for _, stmt := range sc.statements {
-		if assertion, ok := stmt.(*Assertion); ok {
+	for _, id := range ids {
+		if assertion, ok := sc.statements[id].(*Assertion); ok {
 			if !entityIDs[assertion.Subject()] {
 				warnings = append(warnings, fmt.Sprintf("Assertion %s references unknown subject %s", assertion.ID(), assertion.Subject()))
 			}
@@ -170,87 +298,225 @@ func (sc *StatementCollection) Validate() []string {
 		}
 	}
 	
+	// Check n-ary assertions for valid references. Arguments may be literal
+	// values, so only those written like IDs are checked.
+	for _, id := range ids {
+		if assertion, ok := sc.statements[id].(*NaryAssertion); ok {
+			if !relationIDs[assertion.Relation()] {
+				warnings = append(warnings, fmt.Sprintf("Assertion %s references unknown relation %s", id, assertion.Relation()))
+			}
+			for _, arg := range assertion.Arguments() {
+				if looksLikeNodeID(arg.Value) && !entityIDs[arg.Value] && !eventIDs[arg.Value] {
+					warnings = append(warnings, fmt.Sprintf("Assertion %s references unknown %s %s", id, strings.ToLower(arg.Role), arg.Value))
+				}
+			}
+		}
+	}
+	
+	// Check participations for valid references
+	for _, id := range ids {
+		if participation, ok := sc.statements[id].(*Participation); ok {
+			if !eventIDs[participation.EventID()] {
+				warnings = append(warnings, fmt.Sprintf("Participation %s references unknown event %s", id, participation.EventID()))
+			}
+			if !entityIDs[participation.EntityID()] {
+				warnings = append(warnings, fmt.Sprintf("Participation %s references unknown entity %s", id, participation.EntityID()))
+			}
+		}
+	}
+	
+	// Check tasks and dependencies for valid references
+	for _, id := range ids {
+		switch s := sc.statements[id].(type) {
+		case *Task:
+			if !planIDs[s.PlanID()] {
+				warnings = append(warnings, fmt.Sprintf("Task %s references unknown plan %s", id, s.PlanID()))
+			}
+		case *Dependency:
+			if !taskIDs[s.TaskID()] {
+				warnings = append(warnings, fmt.Sprintf("Dependency %s references unknown task %s", id, s.TaskID()))
+			}
+			if !taskIDs[s.DependsOnID()] {
+				warnings = append(warnings, fmt.Sprintf("Dependency %s references unknown task %s", id, s.DependsOnID()))
+			}
+		}
+	}
+	
+	// Check situations and their members for valid references
+	for _, id := range ids {
+		switch s := sc.statements[id].(type) {
+		case *Situation:
+			if s.ParentID() != "" && !situationIDs[s.ParentID()] {
+				warnings = append(warnings, fmt.Sprintf("Situation %s is within unknown situation %s", id, s.ParentID()))
+			}
+		case *SituationMember:
+			if !situationIDs[s.SituationID()] {
+				warnings = append(warnings, fmt.Sprintf("Situation member %s references unknown situation %s", id, s.SituationID()))
+			}
+			if !entityIDs[s.AssertionID()] {
+				warnings = append(warnings, fmt.Sprintf("Situation member %s references unknown assertion %s", id, s.AssertionID()))
+			}
+		case *Evidence:
+			if !entityIDs[s.AssertionID()] {
+				warnings = append(warnings, fmt.Sprintf("Evidence %s references unknown assertion %s", id, s.AssertionID()))
+			}
+		}
+	}
+	
 	return warnings
 }
 
-// KMACBuilder helps build complex KMAC structures
+// KMACBuilder helps build complex KMAC structures. It is safe for concurrent
+// use; the collection returned by GetCollection is not, so finish adding
+// before using it.
 type KMACBuilder struct {
-	collection *StatementCollection
+	mu               sync.Mutex
+	collection       *StatementCollection
 	entityCounter    int
 	relationCounter  int
 	assertionCounter int
+	deterministic    bool
+	ulids            *ULIDGenerator // Nil unless SetULIDs was called
 }
 
 // NewKMACBuilder creates a new KMAC builder
 func NewKMACBuilder() *KMACBuilder {
 	return &KMACBuilder{
-		collection: NewStatementCollection(),
-		entityCounter: 1,
-		relationCounter: 1,
+		collection:       NewStatementCollection(),
+		entityCounter:    1,
+		relationCounter:  1,
 		assertionCounter: 1,
 	}
 }
 
+// SetDeterministicIDs sets whether IDs are derived from statement content
+// rather than numbered in order: entities from their label and TOSID,
+// relations from their label and type, and assertions from their subject,
+// relation, and object. Repeated builds of the same input then produce
+// identical files whatever order the statements are added in, and adding
+// the same content twice yields the same statement.
+func (kb *KMACBuilder) SetDeterministicIDs(deterministic bool) {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	kb.deterministic = deterministic
+}
+
+// SetULIDs sets a generator to issue prefixed ULID identifiers, such as
+// E-01HQ3K5Z8M2XWT6V9RBYDC4NJA, instead of numbering statements in order, so
+// builders in many producers can add statements without clashing. Nil goes
+// back to numbering. Deterministic IDs, if set, take precedence.
+func (kb *KMACBuilder) SetULIDs(generator *ULIDGenerator) {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	kb.ulids = generator
+}
+
+// nextID returns the ID for a new statement: derived from its content if IDs
+// are deterministic, a ULID if a generator is set, otherwise the counter's
+// value. Callers hold the lock.
+func (kb *KMACBuilder) nextID(prefix string, counter int, content ...string) (string, error) {
+	switch {
+	case kb.deterministic:
+		return contentID(prefix, content...), nil
+	case kb.ulids != nil:
+		return kb.ulids.New(prefix)
+	}
+	return fmt.Sprintf("%s%04d", prefix, counter), nil
+}
+
+// add adds a statement built with a new ID. Callers hold the lock.
+func (kb *KMACBuilder) add(stmt Statement) error {
+	if existing, exists := kb.collection.Get(stmt.ID()); exists && kb.deterministic && existing.String() != stmt.String() {
+		return fmt.Errorf("content ID %s of %s collides with %s", stmt.ID(), stmt, existing)
+	}
+	return kb.collection.Add(stmt)
+}
+
 // AddEntity adds an entity with auto-generated ID
 func (kb *KMACBuilder) AddEntity(label string, tosidType string) (*Entity, error) {
-	id := fmt.Sprintf("E%04d", kb.entityCounter)
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+
+	id, err := kb.nextID(EntityIDPrefix, kb.entityCounter, label, tosidType)
+	if err != nil {
+		return nil, err
+	}
 	entity, err := NewEntity(id, label, tosidType)
 	if err != nil {
 		return nil, err
 	}
-	
-	if err := kb.collection.Add(entity); err != nil {
+	if err := kb.add(entity); err != nil {
 		return nil, err
 	}
-	
-	kb.entityCounter++
+	if !kb.deterministic && kb.ulids == nil {
+		kb.entityCounter++
+	}
 	return entity, nil
 }
 
 // AddRelation adds a relation with auto-generated ID
 func (kb *KMACBuilder) AddRelation(label string, relationType string) (*Relation, error) {
-	id := fmt.Sprintf("R%04d", kb.relationCounter)
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+
+	id, err := kb.nextID(RelationIDPrefix, kb.relationCounter, label, relationType)
+	if err != nil {
+		return nil, err
+	}
 	relation, err := NewRelation(id, label, relationType)
 	if err != nil {
 		return nil, err
 	}
-	
-	if err := kb.collection.Add(relation); err != nil {
+	if err := kb.add(relation); err != nil {
 		return nil, err
 	}
-	
-	kb.relationCounter++
+	if !kb.deterministic && kb.ulids == nil {
+		kb.relationCounter++
+	}
 	return relation, nil
 }
 
 // AddAssertion adds an assertion with auto-generated ID
 func (kb *KMACBuilder) AddAssertion(subject string, relation string, object string) (*Assertion, error) {
-	id := fmt.Sprintf("F%04d", kb.assertionCounter)
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+
+	// With no context, the same ID as ContentAssertionID gives
+	id, err := kb.nextID(AssertionIDPrefix, kb.assertionCounter, subject, relation, object, "")
+	if err != nil {
+		return nil, err
+	}
 	assertion, err := NewAssertion(id, subject, relation, object)
 	if err != nil {
 		return nil, err
 	}
-	
-	if err := kb.collection.Add(assertion); err != nil {
+	if err := kb.add(assertion); err != nil {
 		return nil, err
 	}
-	
-	kb.assertionCounter++
+	if !kb.deterministic && kb.ulids == nil {
+		kb.assertionCounter++
+	}
 	return assertion, nil
 }
 
 // GetCollection returns the statement collection
 func (kb *KMACBuilder) GetCollection() *StatementCollection {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
 	return kb.collection
 }
 
 // Build returns all statements as a slice
 func (kb *KMACBuilder) Build() []Statement {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
 	return kb.collection.GetAll()
 }
 
 // Reset clears the builder
 func (kb *KMACBuilder) Reset() {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
 	kb.collection = NewStatementCollection()
 	kb.entityCounter = 1
 	kb.relationCounter = 1
@@ -259,5 +525,24 @@ func (kb *KMACBuilder) Reset() {
 
 // Validate validates the built structure
 func (kb *KMACBuilder) Validate() []string {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
 	return kb.collection.Validate()
-}
\ No newline at end of file
+}
+
+// looksLikeNodeID reports whether a value is written as an entity, event,
+// or assertion ID: the prefix followed by digits, or a ULID identifier
+func looksLikeNodeID(value string) bool {
+	if len(value) < 2 || !strings.ContainsRune(EntityIDPrefix+EventIDPrefix+AssertionIDPrefix, rune(value[0])) {
+		return false
+	}
+	if IsULIDIdentifier(value[:1], value) {
+		return true
+	}
+	for i := 1; i < len(value); i++ {
+		if value[i] < '0' || value[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
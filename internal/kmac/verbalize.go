@@ -0,0 +1,132 @@
+package kmac
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Labeler resolves a statement ID to a display label, reporting false if the
+// ID is unknown. Unknown IDs are shown as they are.
+type Labeler func(id string) (string, bool)
+
+// Describe renders the assertion as an English sentence, e.g.
+// "NASA operates Apollo 11 (confidence 0.9999, historical record)". Labels are
+// resolved through labels, which may be nil to show raw IDs.
+func (a *Assertion) Describe(labels Labeler) string {
+	sentence := a.clause(labels)
+	if a.negated {
+		sentence = "It is not the case that " + sentence
+	}
+	if qualifier := confidenceQualifier(a.confidence, a.confidenceSource); qualifier != "" {
+		sentence += " (" + qualifier + ")"
+	}
+	return sentence
+}
+
+// clause renders the subject, relation, and object of the assertion
+func (a *Assertion) clause(labels Labeler) string {
+	return humanize(resolveLabel(labels, a.subject)) + " " +
+		strings.ToLower(humanize(resolveLabel(labels, a.relation))) + " " +
+		humanize(resolveLabel(labels, a.object))
+}
+
+// confidenceQualifier describes a confidence other than the unsourced default
+func confidenceQualifier(level float64, source string) string {
+	if level == 1.0 && source == "" {
+		return ""
+	}
+	qualifier := "confidence " + strconv.FormatFloat(level, 'f', -1, 64)
+	if source != "" {
+		qualifier += ", " + strings.ToLower(humanize(source))
+	}
+	return qualifier
+}
+
+// AssertionLabeler extends a labeler so that references to the given
+// assertions read as "the statement that ..." clauses
+func AssertionLabeler(labels Labeler, assertions func(id string) (*Assertion, bool)) Labeler {
+	var resolve Labeler
+	seen := make(map[string]bool)
+	resolve = func(id string) (string, bool) {
+		if assertion, ok := assertions(id); ok && !seen[id] {
+			seen[id] = true
+			defer delete(seen, id)
+			return "the statement that " + assertion.clause(resolve), true
+		}
+		if labels == nil {
+			return "", false
+		}
+		return labels(id)
+	}
+	return resolve
+}
+
+// resolveLabel returns the label for an ID, or the ID itself
+func resolveLabel(labels Labeler, id string) string {
+	if labels != nil {
+		if label, ok := labels(id); ok {
+			return label
+		}
+	}
+	return id
+}
+
+// humanize turns an identifier-style label such as "Apollo_11" into words
+func humanize(label string) string {
+	return strings.ReplaceAll(label, "_", " ")
+}
+
+// Labeler resolves the labels of entities, events, relations, plans, and
+// tasks in the collection, and reads assertion references as clauses
+func (sc *StatementCollection) Labeler() Labeler {
+	labels := func(id string) (string, bool) {
+		switch stmt := sc.statements[id].(type) {
+		case *Entity:
+			return stmt.Label(), true
+		case *Event:
+			return stmt.Label(), true
+		case *Relation:
+			return stmt.Label(), true
+		case *Plan:
+			return stmt.Label(), true
+		case *Task:
+			return stmt.Label(), true
+		case *Situation:
+			return stmt.Label(), true
+		}
+		return "", false
+	}
+	return AssertionLabeler(labels, func(id string) (*Assertion, bool) {
+		assertion, ok := sc.statements[id].(*Assertion)
+		return assertion, ok
+	})
+}
+
+// Labeler resolves labels from the statements registered with the disassembler
+func (d *Disassembler) Labeler() Labeler {
+	labels := func(id string) (string, bool) {
+		if entity, ok := d.entityMap[id]; ok {
+			return entity.Label(), true
+		}
+		if event, ok := d.eventMap[id]; ok {
+			return event.Label(), true
+		}
+		if relation, ok := d.relationMap[id]; ok {
+			return relation.Label(), true
+		}
+		if plan, ok := d.planMap[id]; ok {
+			return plan.Label(), true
+		}
+		if task, ok := d.taskMap[id]; ok {
+			return task.Label(), true
+		}
+		if situation, ok := d.situationMap[id]; ok {
+			return situation.Label(), true
+		}
+		return "", false
+	}
+	return AssertionLabeler(labels, func(id string) (*Assertion, bool) {
+		assertion, ok := d.assertionMap[id]
+		return assertion, ok
+	})
+}
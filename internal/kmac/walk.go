@@ -0,0 +1,121 @@
+package kmac
+
+import (
+	"fmt"
+)
+
+// WalkFunc visits a statement and returns the statement to keep in its
+// place: the statement itself, a replacement, or nil to drop it.
+// Replacements should be new statements, such as clones, rather than the
+// visited statement modified, so a failed walk leaves its input unchanged.
+type WalkFunc func(Statement) (Statement, error)
+
+// Walk calls fn for each statement in order and returns the statements
+// kept. The first error stops the walk.
+func Walk(statements []Statement, fn WalkFunc) ([]Statement, error) {
+	kept := make([]Statement, 0, len(statements))
+	for _, stmt := range statements {
+		result, err := fn(stmt)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %v", stmt.Type(), stmt.ID(), err)
+		}
+		if result != nil {
+			kept = append(kept, result)
+		}
+	}
+	return kept, nil
+}
+
+// Chain returns a WalkFunc applying each of fns in turn, so transformations
+// can be combined into a pipeline. A statement dropped by one is not passed
+// to the rest.
+func Chain(fns ...WalkFunc) WalkFunc {
+	return func(stmt Statement) (Statement, error) {
+		for _, fn := range fns {
+			var err error
+			if stmt, err = fn(stmt); err != nil || stmt == nil {
+				return nil, err
+			}
+		}
+		return stmt, nil
+	}
+}
+
+// Walk calls fn for each statement in ID order and replaces the collection's
+// statements with those kept. Replacements are validated as Add validates
+// them, and may change IDs. The collection is only changed if the whole walk
+// succeeds.
+func (sc *StatementCollection) Walk(fn WalkFunc) error {
+	kept, err := Walk(sc.GetAll(), fn)
+	if err != nil {
+		return err
+	}
+	statements := make(map[string]Statement, len(kept))
+	for _, stmt := range kept {
+		if err := ValidateKMACStatement(stmt); err != nil {
+			return fmt.Errorf("invalid statement: %v", err)
+		}
+		if _, exists := statements[stmt.ID()]; exists {
+			return fmt.Errorf("walk produced statement %s more than once", stmt.ID())
+		}
+		statements[stmt.ID()] = stmt
+	}
+	sc.statements = statements
+	return nil
+}
+
+// RenameRelation returns a WalkFunc replacing relations labeled from with
+// copies labeled to. Assertions refer to relations by ID, so they need no
+// change.
+func RenameRelation(from string, to string) WalkFunc {
+	return func(stmt Statement) (Statement, error) {
+		relation, ok := stmt.(*Relation)
+		if !ok || relation.Label() != from {
+			return stmt, nil
+		}
+		renamed := relation.Clone()
+		renamed.label = to
+		return renamed, nil
+	}
+}
+
+// RemapTOSIDs returns a WalkFunc replacing entities whose TOSID is a key of
+// mapping with copies having the mapped TOSID, for migrating between
+// classification schemes
+func RemapTOSIDs(mapping map[string]string) WalkFunc {
+	return func(stmt Statement) (Statement, error) {
+		entity, ok := stmt.(*Entity)
+		if !ok {
+			return stmt, nil
+		}
+		code, remapped := mapping[entity.TOSIDType()]
+		if !remapped {
+			return stmt, nil
+		}
+		c := entity.Clone()
+		c.tosidType = code
+		return c, nil
+	}
+}
+
+// NormalizeLabels returns a WalkFunc replacing entities and relations whose
+// label normalize changes with copies having the normalized label
+func NormalizeLabels(normalize func(label string) string) WalkFunc {
+	return func(stmt Statement) (Statement, error) {
+		switch s := stmt.(type) {
+		case *Entity:
+			if label := normalize(s.Label()); label != s.Label() {
+				c := s.Clone()
+				c.label = label
+				return c, nil
+			}
+		case *Relation:
+			if label := normalize(s.Label()); label != s.Label() {
+				c := s.Clone()
+				c.label = label
+				return c, nil
+			}
+		}
+		return stmt, nil
+	}
+}
@@ -0,0 +1,93 @@
+package kmac
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CrossReference is one statement mentioning an entity or event, like a
+// symbol reference in a disassembly listing
+type CrossReference struct {
+	Line      int       // Line of the statement in the canonical KMAC listing
+	Statement Statement // The statement making the mention
+	Role      string    // How it is mentioned, e.g. "subject" or "whole"
+}
+
+// mention is an ID a statement refers to and the role it refers to it in
+type mention struct {
+	id   string
+	role string
+}
+
+// mentions returns the IDs a statement refers to, with their roles
+func mentions(stmt Statement) []mention {
+	switch s := stmt.(type) {
+	case *Assertion:
+		return []mention{{s.Subject(), "subject"}, {s.Object(), "object"}}
+	case *NaryAssertion:
+		var refs []mention
+		for _, arg := range s.Arguments() {
+			refs = append(refs, mention{arg.Value, strings.ToLower(arg.Role)})
+		}
+		return refs
+	case *PartOf:
+		return []mention{{s.PartID(), "part"}, {s.WholeID(), "whole"}}
+	case *Participation:
+		return []mention{{s.EventID(), "event"}, {s.EntityID(), strings.ToLower(s.Role())}}
+	case *Causation:
+		return []mention{{s.SourceID(), "cause"}, {s.TargetID(), "effect"}}
+	case *StateAssertion:
+		return []mention{{s.EntityID(), "state"}}
+	}
+	return nil
+}
+
+// CrossReferences indexes, for every registered entity and event, the
+// statements that mention it, in listing order. Lines are those of the
+// registered statements written in the canonical format, as kmac fmt writes
+// them, so they match files kept canonical.
+func (d *Disassembler) CrossReferences() map[string][]CrossReference {
+	statements := d.Statements()
+	lines := NewTextSerializer().canonicalLines(statements)
+
+	index := make(map[string][]CrossReference)
+	for _, id := range sortedKeys(d.entityMap) {
+		index[id] = nil
+	}
+	for _, id := range sortedKeys(d.eventMap) {
+		index[id] = nil
+	}
+	for _, stmt := range statements {
+		for _, m := range mentions(stmt) {
+			if refs, indexed := index[m.id]; indexed {
+				index[m.id] = append(refs, CrossReference{Line: lines[stmt], Statement: stmt, Role: m.role})
+			}
+		}
+	}
+	for id, refs := range index {
+		sort.SliceStable(refs, func(i, j int) bool { return refs[i].Line < refs[j].Line })
+		index[id] = refs
+	}
+	return index
+}
+
+// DisassembleCrossReference writes the cross-reference index: each entity
+// and event, followed by the line, type, and ID of every statement that
+// mentions it and the role it is mentioned in
+func (d *Disassembler) DisassembleCrossReference() {
+	index := d.CrossReferences()
+
+	fmt.Fprintln(d.writer, d.heading("CROSS-REFERENCE INDEX"))
+	fmt.Fprintln(d.writer, "=====================")
+	for _, id := range sortedKeys(index) {
+		fmt.Fprintf(d.writer, "#%s [%s]\n", id, d.paintNode(id, d.nodeLabel(id)))
+		if len(index[id]) == 0 {
+			fmt.Fprintf(d.writer, "  No references\n")
+		}
+		for _, ref := range index[id] {
+			fmt.Fprintf(d.writer, "  L%-5d %s #%s (%s)\n", ref.Line, ref.Statement.Type(), ref.Statement.ID(), ref.Role)
+		}
+	}
+	fmt.Fprintln(d.writer)
+}
@@ -0,0 +1,145 @@
+package tosid
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Interner deduplicates parsed TOSIDs so that repeated codes share a single instance.
+// TOSIDs returned by an Interner are shared and must be treated as read-only.
+// Each Intern holds the code until a matching Release, and a code no longer
+// held is dropped, so an interner whose callers release what they intern
+// stays as small as the set of codes in use.
+type Interner struct {
+	mu      sync.RWMutex
+	parser  *Parser
+	tosids  map[string]*internEntry
+	strings map[string]string
+}
+
+// internEntry is an interned TOSID and the number of holds on it. Holds
+// are added under the read lock, so they are counted atomically.
+type internEntry struct {
+	tosid *TOSID
+	refs  atomic.Int64
+}
+
+// newInternEntry creates an entry with refs holds on a TOSID
+func newInternEntry(tosid *TOSID, refs int64) *internEntry {
+	entry := &internEntry{tosid: tosid}
+	entry.refs.Store(refs)
+	return entry
+}
+
+// NewInterner creates a new TOSID interner
+func NewInterner() *Interner {
+	return &Interner{
+		parser:  NewParser(),
+		tosids:  make(map[string]*internEntry),
+		strings: make(map[string]string),
+	}
+}
+
+// Intern returns the shared TOSID for a code, parsing it on first use, and
+// holds the code until it is released
+func (in *Interner) Intern(code string) (*TOSID, error) {
+	in.mu.RLock()
+	if entry, exists := in.tosids[code]; exists {
+		entry.refs.Add(1)
+		in.mu.RUnlock()
+		return entry.tosid, nil
+	}
+	in.mu.RUnlock()
+
+	parsed, err := in.parser.Parse(code)
+	if err != nil {
+		return nil, err
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	// Another caller may have interned the same code while we were parsing
+	if entry, exists := in.tosids[code]; exists {
+		entry.refs.Add(1)
+		return entry.tosid, nil
+	}
+
+	// Taxonomy codes and netmasks repeat across almost every TOSID, so share them too
+	parsed.TaxonomyCode = in.internString(parsed.TaxonomyCode)
+	parsed.NetmaskIndicator = in.internString(parsed.NetmaskIndicator)
+
+	// Clone so the table does not pin a larger backing array from the caller
+	in.tosids[string([]byte(code))] = newInternEntry(parsed, 1)
+	return parsed, nil
+}
+
+// Release drops one hold on a code taken by Intern, forgetting the TOSID
+// once none remain. Releasing a code that is not held does nothing.
+func (in *Interner) Release(code string) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if entry, exists := in.tosids[code]; exists {
+		if entry.refs.Add(-1) <= 0 {
+			delete(in.tosids, code)
+		}
+	}
+}
+
+// internString returns the canonical copy of s; callers must hold the write
+// lock. Only taxonomy codes and netmasks are interned as strings, and there
+// are few of them, so they are kept for the interner's lifetime.
+func (in *Interner) internString(s string) string {
+	if shared, exists := in.strings[s]; exists {
+		return shared
+	}
+	// Clone so the table does not pin a larger backing array from the caller
+	shared := string([]byte(s))
+	in.strings[shared] = shared
+	return shared
+}
+
+// Count returns the number of distinct interned TOSIDs
+func (in *Interner) Count() int {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	return len(in.tosids)
+}
+
+// Clone returns an interner sharing this one's TOSIDs, with the same holds
+// on them, that later interning and releasing does not affect
+func (in *Interner) Clone() *Interner {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	c := &Interner{
+		parser:  NewParser(),
+		tosids:  make(map[string]*internEntry, len(in.tosids)),
+		strings: make(map[string]string, len(in.strings)),
+	}
+	for code, entry := range in.tosids {
+		c.tosids[code] = newInternEntry(entry.tosid, entry.refs.Load())
+	}
+	for s := range in.strings {
+		c.strings[s] = s
+	}
+	return c
+}
+
+// Clear drops all interned TOSIDs
+func (in *Interner) Clear() {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.tosids = make(map[string]*internEntry)
+	in.strings = make(map[string]string)
+}
+
+// defaultInterner backs the package-level Intern function. Nothing releases
+// what it holds, so it suits a bounded set of codes; stores interning
+// streams of codes should each use their own interner and release codes as
+// they drop them.
+var defaultInterner = NewInterner()
+
+// DefaultInterner returns the process-wide interner
+func DefaultInterner() *Interner {
+	return defaultInterner
+}
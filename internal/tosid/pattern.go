@@ -0,0 +1,63 @@
+package tosid
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// maxCachedPatterns bounds the pattern cache; it is emptied when full
+const maxCachedPatterns = 256
+
+// patterns caches compiled patterns, since a query matches the same pattern
+// against every entity it scans
+var patterns = struct {
+	mu      sync.RWMutex
+	regexps map[string]*regexp.Regexp
+}{regexps: make(map[string]*regexp.Regexp)}
+
+// patternRegexp returns the compiled form of a TOSID pattern. It matches a
+// code's string form from the start, with wildcards matching as little as
+// possible and each run of literal characters captured as a group.
+func patternRegexp(pattern string) *regexp.Regexp {
+	patterns.mu.RLock()
+	compiled, exists := patterns.regexps[pattern]
+	patterns.mu.RUnlock()
+	if exists {
+		return compiled
+	}
+
+	regexPattern := "^"
+	for i, run := range strings.Split(pattern, "*") {
+		if i > 0 {
+			regexPattern += ".*?"
+		}
+		regexPattern += "(" + regexp.QuoteMeta(run) + ")"
+	}
+	compiled = regexp.MustCompile(regexPattern)
+
+	patterns.mu.Lock()
+	defer patterns.mu.Unlock()
+	if len(patterns.regexps) >= maxCachedPatterns {
+		patterns.regexps = make(map[string]*regexp.Regexp)
+	}
+	patterns.regexps[pattern] = compiled
+	return compiled
+}
+
+// codeBuffers pools the buffers codes are written into for matching, so
+// scanning entities does not allocate a string for each
+var codeBuffers = sync.Pool{New: func() any { return new([]byte) }}
+
+// matches reports whether the TOSID's string form matches a compiled pattern
+func (t *TOSID) matches(compiled *regexp.Regexp) bool {
+	buf := codeBuffers.Get().(*[]byte)
+	code := append((*buf)[:0], t.TaxonomyCode...)
+	code = append(code, t.NetmaskIndicator...)
+	code = append(code, '-')
+	code = append(code, t.Identifier...)
+	matched := compiled.Match(code)
+	*buf = code
+	codeBuffers.Put(buf)
+	return matched
+}
@@ -0,0 +1,72 @@
+package tosid
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Segment is one component of a TOSID code: the taxonomy code, the netmask
+// indicator, one of the category parts, or one of the specific identifier
+// parts
+type Segment struct {
+	Name  string // e.g. "taxonomy", "netmask", "category 2", or "identifier 1"
+	Value string
+}
+
+// Segments returns the components of the TOSID, in order
+func (t *TOSID) Segments() []Segment {
+	segments, _ := t.segments()
+	return segments
+}
+
+// segments returns the components of the TOSID and their offsets in its
+// string form
+func (t *TOSID) segments() ([]Segment, []int) {
+	segments := []Segment{{Name: "taxonomy", Value: t.TaxonomyCode}, {Name: "netmask", Value: t.NetmaskIndicator}}
+	starts := []int{0, len(t.TaxonomyCode)}
+
+	offset := len(t.TaxonomyCode) + len(t.NetmaskIndicator) + 1
+	category, specific, hasSpecific := strings.Cut(t.Identifier, ":")
+	for i, part := range strings.Split(category, "-") {
+		segments = append(segments, Segment{Name: fmt.Sprintf("category %d", i+1), Value: part})
+		starts = append(starts, offset)
+		offset += len(part) + 1
+	}
+	if hasSpecific {
+		for i, part := range strings.Split(specific, "-") {
+			segments = append(segments, Segment{Name: fmt.Sprintf("identifier %d", i+1), Value: part})
+			starts = append(starts, offset)
+			offset += len(part) + 1
+		}
+	}
+	return segments, starts
+}
+
+// MatchedSegments returns the segments a pattern pins down when the TOSID
+// matches it: those the pattern's literal characters fall in, rather than
+// its wildcards. It returns nil if the TOSID does not match.
+func (t *TOSID) MatchedSegments(pattern string) []Segment {
+	tosidStr := t.String()
+	match := patternRegexp(pattern).FindStringSubmatchIndex(tosidStr)
+	if match == nil {
+		return nil
+	}
+
+	literal := make([]bool, len(tosidStr))
+	for group := 2; group < len(match); group += 2 {
+		for i := match[group]; i < match[group+1]; i++ {
+			literal[i] = true
+		}
+	}
+	matched := make([]Segment, 0)
+	segments, starts := t.segments()
+	for n, segment := range segments {
+		for i := starts[n]; i < starts[n]+len(segment.Value); i++ {
+			if literal[i] {
+				matched = append(matched, segment)
+				break
+			}
+		}
+	}
+	return matched
+}
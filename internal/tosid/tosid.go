@@ -1,8 +1,6 @@
 package tosid
 
 import (
-	"fmt"
-	"regexp"
 	"strings"
 )
 
@@ -15,7 +13,7 @@ type TOSID struct {
 
 // String returns the string representation of the TOSID
 func (t *TOSID) String() string {
-	return fmt.Sprintf("%s%s-%s", t.TaxonomyCode, t.NetmaskIndicator, t.Identifier)
+	return t.TaxonomyCode + t.NetmaskIndicator + "-" + t.Identifier
 }
 
 // ClassificationDescription returns a human-readable description of the TOSID classification
@@ -37,21 +35,7 @@ func (t *TOSID) MatchesPattern(pattern string) bool {
 		return true
 	}
 	
-	tosidStr := t.String()
-	
-	// Convert pattern to regex
-	regexPattern := "^"
-	for _, c := range pattern {
-		if c == '*' {
-			regexPattern += ".*"
-		} else {
-			regexPattern += regexp.QuoteMeta(string(c))
-		}
-	}
-	regexPattern += ".*$" // Match the rest of the string
-	
-	matched, _ := regexp.MatchString(regexPattern, tosidStr)
-	return matched
+	return t.matches(patternRegexp(pattern))
 }
 
 // GetHierarchy returns the hierarchical levels of this TOSID
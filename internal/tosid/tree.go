@@ -0,0 +1,111 @@
+package tosid
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// TreeNode is a level of a collection's taxonomy tree: a code prefix, with
+// how many of the collection's codes fall under it
+type TreeNode struct {
+	Prefix   string      `json:"prefix"`
+	Label    string      `json:"label,omitempty"` // Classification, at the taxonomy and netmask levels
+	Count    int         `json:"count"`
+	Children []*TreeNode `json:"children,omitempty"`
+}
+
+// Tree builds the taxonomy tree of the collection from the levels of each
+// code's hierarchy, under a root with an empty prefix counting every code.
+// maxDepth limits the levels below the root, 0 for all of them. Children are
+// sorted by prefix.
+func (tc *TOSIDCollection) Tree(maxDepth int) *TreeNode {
+	classifier := NewTaxonomyClassifier()
+	root := &TreeNode{Count: len(tc.tosids)}
+	nodes := map[string]*TreeNode{"": root}
+
+	for _, tosid := range tc.tosids {
+		parent := root
+		for depth, prefix := range tosid.GetHierarchy() {
+			if maxDepth > 0 && depth >= maxDepth {
+				break
+			}
+			node, exists := nodes[prefix]
+			if !exists {
+				node = &TreeNode{Prefix: prefix}
+				switch depth {
+				case 0:
+					node.Label = classifier.GetDomainDescription(tosid.TaxonomyCode) + " - " + classifier.GetTypeDescription(tosid.TaxonomyCode)
+				case 1:
+					node.Label = classifier.GetFullClassification(tosid.TaxonomyCode, tosid.NetmaskIndicator)
+				}
+				nodes[prefix] = node
+				parent.Children = append(parent.Children, node)
+			}
+			node.Count++
+			parent = node
+		}
+	}
+
+	for _, node := range nodes {
+		sort.Slice(node.Children, func(i, j int) bool { return node.Children[i].Prefix < node.Children[j].Prefix })
+	}
+	return root
+}
+
+// ExportTreeJSON writes the collection's taxonomy tree as indented JSON
+func (tc *TOSIDCollection) ExportTreeJSON(w io.Writer, maxDepth int) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(tc.Tree(maxDepth))
+}
+
+// ExportTreeDOT writes the collection's taxonomy tree as a Graphviz digraph,
+// each node labelled with its prefix, classification, and count
+func (tc *TOSIDCollection) ExportTreeDOT(w io.Writer, maxDepth int) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph tosid {")
+	fmt.Fprintln(bw, "  rankdir=LR;")
+	fmt.Fprintln(bw, "  node [shape=box];")
+
+	next := 0
+	var walk func(node *TreeNode) int
+	walk = func(node *TreeNode) int {
+		id := next
+		next++
+		label := node.Prefix
+		if label == "" {
+			label = "all"
+		}
+		if node.Label != "" {
+			label += "\n" + node.Label
+		}
+		fmt.Fprintf(bw, "  n%d [label=%s];\n", id, dotQuote(fmt.Sprintf("%s\n%d", label, node.Count)))
+		for _, child := range node.Children {
+			fmt.Fprintf(bw, "  n%d -> n%d;\n", id, walk(child))
+		}
+		return id
+	}
+	walk(tc.Tree(maxDepth))
+
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// dotQuote quotes a string as a DOT identifier, with line breaks kept as \n
+func dotQuote(s string) string {
+	quoted := []byte{'"'}
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\\':
+			quoted = append(quoted, '\\', s[i])
+		case '\n':
+			quoted = append(quoted, '\\', 'n')
+		default:
+			quoted = append(quoted, s[i])
+		}
+	}
+	return string(append(quoted, '"'))
+}
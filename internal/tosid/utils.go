@@ -56,6 +56,16 @@ func (tc *TOSIDCollection) GetAll() []*TOSID {
 	return tosids
 }
 
+// Range calls fn for each TOSID without building a result slice.
+// Iteration stops when fn returns false.
+func (tc *TOSIDCollection) Range(fn func(*TOSID) bool) {
+	for _, tosid := range tc.tosids {
+		if !fn(tosid) {
+			return
+		}
+	}
+}
+
 // FindByPattern finds TOSIDs matching a pattern
 func (tc *TOSIDCollection) FindByPattern(pattern string) []*TOSID {
 	var matches []*TOSID
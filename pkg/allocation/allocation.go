@@ -0,0 +1,244 @@
+// Package allocation assigns resource entities to need entities in a semantic
+// store and reports the result as KMAC assertions.
+package allocation
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+)
+
+// Options configures how needs and resources are read from the store
+type Options struct {
+	NeedIDs     []string // Entities to satisfy
+	ResourceIDs []string // Entities that can be allocated
+
+	DemandProperty   string // Need property holding the required quantity; defaults to "demand"
+	PriorityProperty string // Need property ordering needs, highest first; defaults to "priority"
+	CapacityProperty string // Resource property holding the available quantity; defaults to "capacity"
+
+	// ServesRelation marks which resources can serve which needs through
+	// resource->need assertions. If empty, any resource can serve any need.
+	ServesRelation string
+	// ExcludesRelation forbids a resource->need pairing, e.g. because of a road closure
+	ExcludesRelation string
+
+	AllocationRelation string // Relation of emitted assertions; defaults to "ALLOCATED_TO"
+	Source             string // Provenance recorded on emitted assertions; defaults to "ALLOCATION_SOLVER"
+	IDPrefix           string // Prefix for emitted assertion IDs; defaults to "FA"
+}
+
+// withDefaults fills in unset options
+func (o Options) withDefaults() Options {
+	if o.DemandProperty == "" {
+		o.DemandProperty = "demand"
+	}
+	if o.PriorityProperty == "" {
+		o.PriorityProperty = "priority"
+	}
+	if o.CapacityProperty == "" {
+		o.CapacityProperty = "capacity"
+	}
+	if o.AllocationRelation == "" {
+		o.AllocationRelation = "ALLOCATED_TO"
+	}
+	if o.Source == "" {
+		o.Source = "ALLOCATION_SOLVER"
+	}
+	if o.IDPrefix == "" {
+		o.IDPrefix = "FA"
+	}
+	return o
+}
+
+// Allocation assigns a quantity of a resource to a need
+type Allocation struct {
+	ResourceID string
+	NeedID     string
+	Quantity   float64
+}
+
+// Result holds the allocations chosen by the solver
+type Result struct {
+	Allocations []Allocation
+	Demand      map[string]float64 // Required quantity per need
+	Unmet       map[string]float64 // Quantity still missing per need, for needs not fully met
+	options     Options
+}
+
+// Coverage returns the fraction of a need's demand that was allocated
+func (r *Result) Coverage(needID string) float64 {
+	demand := r.Demand[needID]
+	if demand == 0 {
+		return 1.0
+	}
+	return (demand - r.Unmet[needID]) / demand
+}
+
+// Assertions returns the allocations as KMAC assertions. Each assertion's
+// confidence is the coverage of its need, its source is the configured
+// provenance, and its quantity is recorded as a property.
+func (r *Result) Assertions() ([]*kmac.Assertion, error) {
+	assertions := make([]*kmac.Assertion, 0, len(r.Allocations))
+	for i, alloc := range r.Allocations {
+		id := fmt.Sprintf("%s%d", r.options.IDPrefix, i+1)
+		assertion, err := kmac.NewAssertion(id, alloc.ResourceID, r.options.AllocationRelation, alloc.NeedID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create allocation assertion: %v", err)
+		}
+		assertion.SetConfidence(r.Coverage(alloc.NeedID), r.options.Source)
+		assertion.SetProperty("quantity", strconv.FormatFloat(alloc.Quantity, 'f', -1, 64))
+		assertions = append(assertions, assertion)
+	}
+	return assertions, nil
+}
+
+// need is a need entity read from the store
+type need struct {
+	id       string
+	demand   float64
+	priority float64
+}
+
+// Solve allocates resources to needs greedily. Needs are served in priority
+// order, highest first, and each draws from the compatible resource with the
+// most remaining capacity until its demand is met or no capacity is left.
+func Solve(store *semantic.SemanticStore, opts Options) (*Result, error) {
+	opts = opts.withDefaults()
+	if len(opts.NeedIDs) == 0 || len(opts.ResourceIDs) == 0 {
+		return nil, errors.New("at least one need and one resource are required")
+	}
+
+	needs := make([]need, 0, len(opts.NeedIDs))
+	for _, id := range opts.NeedIDs {
+		demand, err := numericProperty(store, id, opts.DemandProperty, nil)
+		if err != nil {
+			return nil, err
+		}
+		zero := 0.0
+		priority, err := numericProperty(store, id, opts.PriorityProperty, &zero)
+		if err != nil {
+			return nil, err
+		}
+		needs = append(needs, need{id: id, demand: demand, priority: priority})
+	}
+	sort.SliceStable(needs, func(i, j int) bool {
+		if needs[i].priority != needs[j].priority {
+			return needs[i].priority > needs[j].priority
+		}
+		return needs[i].id < needs[j].id
+	})
+
+	remaining := make(map[string]float64, len(opts.ResourceIDs))
+	for _, id := range opts.ResourceIDs {
+		capacity, err := numericProperty(store, id, opts.CapacityProperty, nil)
+		if err != nil {
+			return nil, err
+		}
+		remaining[id] = capacity
+	}
+
+	result := &Result{
+		Demand:  make(map[string]float64, len(needs)),
+		Unmet:   make(map[string]float64),
+		options: opts,
+	}
+	for _, n := range needs {
+		result.Demand[n.id] = n.demand
+		candidates := compatibleResources(store, opts, n.id)
+
+		missing := n.demand
+		for missing > 0 {
+			best := ""
+			for _, id := range candidates {
+				if remaining[id] > 0 && (best == "" || remaining[id] > remaining[best]) {
+					best = id
+				}
+			}
+			if best == "" {
+				break
+			}
+
+			quantity := missing
+			if remaining[best] < quantity {
+				quantity = remaining[best]
+			}
+			remaining[best] -= quantity
+			missing -= quantity
+			result.Allocations = append(result.Allocations, Allocation{ResourceID: best, NeedID: n.id, Quantity: quantity})
+		}
+		if missing > 0 {
+			result.Unmet[n.id] = missing
+		}
+	}
+
+	return result, nil
+}
+
+// compatibleResources returns the resources allowed to serve a need, in ID order
+func compatibleResources(store *semantic.SemanticStore, opts Options, needID string) []string {
+	serves := make(map[string]bool)
+	excluded := make(map[string]bool)
+	for _, assertion := range store.FindAssertionsByObject(needID) {
+		switch assertion.Relation() {
+		case opts.ServesRelation:
+			serves[assertion.Subject()] = true
+		case opts.ExcludesRelation:
+			excluded[assertion.Subject()] = true
+		}
+	}
+
+	var candidates []string
+	for _, id := range opts.ResourceIDs {
+		if excluded[id] || (opts.ServesRelation != "" && !serves[id]) {
+			continue
+		}
+		candidates = append(candidates, id)
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+// numericProperty reads a numeric entity property, using fallback when the
+// property is missing; a nil fallback makes the property required
+func numericProperty(store *semantic.SemanticStore, entityID string, key string, fallback *float64) (float64, error) {
+	entityRef, err := store.GetEntity(entityID)
+	if err != nil {
+		return 0, err
+	}
+
+	value, ok := entityRef.KMACEntity.GetProperty(key)
+	if !ok {
+		if fallback != nil {
+			return *fallback, nil
+		}
+		return 0, fmt.Errorf("entity %s has no %s property", entityID, key)
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("entity %s has invalid %s %q: %v", entityID, key, value, err)
+	}
+	if number < 0 {
+		return 0, fmt.Errorf("entity %s has negative %s %q", entityID, key, value)
+	}
+	return number, nil
+}
+
+// Apply adds the allocation assertions to a store
+func (r *Result) Apply(store *semantic.SemanticStore) error {
+	assertions, err := r.Assertions()
+	if err != nil {
+		return err
+	}
+	for _, assertion := range assertions {
+		if err := store.CreateAssertion(assertion.ID(), assertion.Subject(), assertion.Relation(), assertion.Object()); err != nil {
+			return fmt.Errorf("failed to store allocation %s: %v", assertion.ID(), err)
+		}
+	}
+	return nil
+}
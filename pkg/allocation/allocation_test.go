@@ -0,0 +1,127 @@
+package allocation
+
+import (
+	"testing"
+
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+)
+
+// newTestStore builds two needs and three resources with capacities
+func newTestStore(t *testing.T) *semantic.SemanticStore {
+	t.Helper()
+	store := semantic.NewSemanticStore()
+	entities := []struct {
+		id         string
+		label      string
+		properties map[string]string
+	}{
+		{"E2001", "Medical_Need", map[string]string{"demand": "100", "priority": "10"}},
+		{"E2002", "Water_Need", map[string]string{"demand": "500", "priority": "5"}},
+		{"E1001", "Antibiotic_Supply", map[string]string{"capacity": "80"}},
+		{"E1002", "Field_Hospital_Stock", map[string]string{"capacity": "50"}},
+		{"E1003", "Water_Purifier", map[string]string{"capacity": "300"}},
+	}
+	for _, e := range entities {
+		if err := store.AddEntity(e.id, e.label, ""); err != nil {
+			t.Fatalf("Failed to add entity: %v", err)
+		}
+		entityRef, _ := store.GetEntity(e.id)
+		for key, value := range e.properties {
+			entityRef.KMACEntity.SetProperty(key, value)
+		}
+	}
+
+	store.CreateAssertion("F1001", "E1001", "R1002", "E2001")
+	store.CreateAssertion("F1002", "E1002", "R1002", "E2001")
+	store.CreateAssertion("F1003", "E1003", "R1002", "E2002")
+	store.CreateAssertion("F1004", "E1002", "R1005", "E2001")
+	return store
+}
+
+func TestSolve(t *testing.T) {
+	store := newTestStore(t)
+	opts := Options{
+		NeedIDs:        []string{"E2002", "E2001"},
+		ResourceIDs:    []string{"E1001", "E1002", "E1003"},
+		ServesRelation: "R1002",
+	}
+
+	result, err := Solve(store, opts)
+	if err != nil {
+		t.Fatalf("Solve failed: %v", err)
+	}
+
+	// The medical need has priority and draws from the larger supply first
+	want := []Allocation{
+		{ResourceID: "E1001", NeedID: "E2001", Quantity: 80},
+		{ResourceID: "E1002", NeedID: "E2001", Quantity: 20},
+		{ResourceID: "E1003", NeedID: "E2002", Quantity: 300},
+	}
+	if len(result.Allocations) != len(want) {
+		t.Fatalf("Expected %d allocations, got %v", len(want), result.Allocations)
+	}
+	for i := range want {
+		if result.Allocations[i] != want[i] {
+			t.Errorf("Allocation %d: expected %v, got %v", i, want[i], result.Allocations[i])
+		}
+	}
+	if result.Unmet["E2002"] != 200 {
+		t.Errorf("Expected 200 unmet for E2002, got %v", result.Unmet["E2002"])
+	}
+	if _, unmet := result.Unmet["E2001"]; unmet {
+		t.Error("Expected E2001 to be fully met")
+	}
+
+	assertions, err := result.Assertions()
+	if err != nil {
+		t.Fatalf("Assertions failed: %v", err)
+	}
+	water := assertions[2]
+	if water.Subject() != "E1003" || water.Relation() != "ALLOCATED_TO" || water.Object() != "E2002" {
+		t.Errorf("Unexpected assertion: %s", water)
+	}
+	if level, source := water.GetConfidence(); level != 0.6 || source != "ALLOCATION_SOLVER" {
+		t.Errorf("Expected confidence 0.6 from ALLOCATION_SOLVER, got %v from %s", level, source)
+	}
+	if quantity, _ := water.GetProperty("quantity"); quantity != "300" {
+		t.Errorf("Expected quantity 300, got %s", quantity)
+	}
+
+	if err := result.Apply(store); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if allocated := store.FindAssertionsByRelation("ALLOCATED_TO"); len(allocated) != 3 {
+		t.Errorf("Expected 3 stored allocations, got %d", len(allocated))
+	}
+}
+
+func TestSolveRespectsExclusions(t *testing.T) {
+	store := newTestStore(t)
+	result, err := Solve(store, Options{
+		NeedIDs:          []string{"E2001"},
+		ResourceIDs:      []string{"E1001", "E1002"},
+		ServesRelation:   "R1002",
+		ExcludesRelation: "R1005",
+	})
+	if err != nil {
+		t.Fatalf("Solve failed: %v", err)
+	}
+	for _, alloc := range result.Allocations {
+		if alloc.ResourceID == "E1002" {
+			t.Errorf("Excluded resource was allocated: %v", alloc)
+		}
+	}
+	if result.Unmet["E2001"] != 20 {
+		t.Errorf("Expected 20 unmet, got %v", result.Unmet["E2001"])
+	}
+}
+
+func TestSolveRequiresQuantities(t *testing.T) {
+	store := newTestStore(t)
+	if _, err := Solve(store, Options{NeedIDs: []string{"E1001"}, ResourceIDs: []string{"E1002"}}); err == nil {
+		t.Error("Expected error for need without demand")
+	}
+	if _, err := Solve(store, Options{NeedIDs: []string{"E2001"}, ResourceIDs: []string{"E2002"}}); err == nil {
+		t.Error("Expected error for resource without capacity")
+	}
+}
@@ -0,0 +1,221 @@
+// Package backup pushes compressed store snapshots to object storage and
+// restores stores from them, for simple disaster recovery of knowledge bases.
+//
+// Each upload is stored under a new versioned key, so earlier snapshots are
+// kept until the bucket's own lifecycle rules expire them. The SHA-256 hash
+// of the stored snapshot travels with it and is checked on download.
+// Snapshots can also be encrypted before they leave the process.
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ha1tch/tosid-go/pkg/encrypt"
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+)
+
+// keyTimeFormat names snapshots by the time they were taken, so keys sort
+// oldest first
+const keyTimeFormat = "20060102T150405.000000000Z"
+
+// keySuffix ends the key of every snapshot
+const keySuffix = ".kmac.gz"
+
+// encryptedSuffix ends the key of every encrypted snapshot
+const encryptedSuffix = keySuffix + ".enc"
+
+// HashMetadata is the object metadata key holding a snapshot's hash
+const HashMetadata = "sha256"
+
+// ErrNotFound is returned when an object does not exist
+var ErrNotFound = errors.New("object not found")
+
+// ObjectStore is a bucket of objects, such as an S3-compatible bucket
+type ObjectStore interface {
+	// Put stores an object under a key along with metadata
+	Put(ctx context.Context, key string, data []byte, metadata map[string]string) error
+	// Get retrieves an object and its metadata, or returns ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, map[string]string, error)
+	// List returns the keys starting with a prefix, in order
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// SnapshotInfo describes an uploaded snapshot
+type SnapshotInfo struct {
+	Key     string
+	TakenAt time.Time
+	Size    int // Stored size in bytes
+	SHA256  string
+}
+
+// Upload compresses a snapshot and stores it under prefix with a key naming
+// the time it was taken
+func Upload(ctx context.Context, objects ObjectStore, prefix string, snapshot *semantic.Snapshot) (*SnapshotInfo, error) {
+	return upload(ctx, objects, prefix, snapshot, nil)
+}
+
+// UploadEncrypted compresses a snapshot, encrypts it with the provider's
+// current key, and stores it under prefix
+func UploadEncrypted(ctx context.Context, objects ObjectStore, prefix string, snapshot *semantic.Snapshot, keys encrypt.KeyProvider) (*SnapshotInfo, error) {
+	return upload(ctx, objects, prefix, snapshot, keys)
+}
+
+// upload stores a snapshot, encrypting it if keys are given
+func upload(ctx context.Context, objects ObjectStore, prefix string, snapshot *semantic.Snapshot, keys encrypt.KeyProvider) (*SnapshotInfo, error) {
+	var buf bytes.Buffer
+	var sink io.Writer = &buf
+	var sealer *encrypt.Writer
+	if keys != nil {
+		var err error
+		if sealer, err = encrypt.NewWriter(&buf, keys); err != nil {
+			return nil, fmt.Errorf("failed to encrypt snapshot: %v", err)
+		}
+		sink = sealer
+	}
+	zw := gzip.NewWriter(sink)
+	if err := snapshot.WriteKMAC(zw); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress snapshot: %v", err)
+	}
+	if sealer != nil {
+		if err := sealer.Close(); err != nil {
+			return nil, fmt.Errorf("failed to encrypt snapshot: %v", err)
+		}
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	info := &SnapshotInfo{
+		Key:     snapshotKey(prefix, snapshot.TakenAt(), keys != nil),
+		TakenAt: snapshot.TakenAt(),
+		Size:    buf.Len(),
+		SHA256:  hex.EncodeToString(sum[:]),
+	}
+	if err := objects.Put(ctx, info.Key, buf.Bytes(), map[string]string{HashMetadata: info.SHA256}); err != nil {
+		return nil, fmt.Errorf("failed to upload snapshot %s: %v", info.Key, err)
+	}
+	return info, nil
+}
+
+// Download retrieves the snapshot stored under a key, checks its hash, and
+// loads it into a new store. Encrypted snapshots need DownloadEncrypted.
+func Download(ctx context.Context, objects ObjectStore, key string) (*semantic.SemanticStore, error) {
+	return download(ctx, objects, key, nil)
+}
+
+// DownloadEncrypted retrieves a snapshot as Download does, decrypting it
+// with the provider's keys if it is encrypted
+func DownloadEncrypted(ctx context.Context, objects ObjectStore, key string, keys encrypt.KeyProvider) (*semantic.SemanticStore, error) {
+	return download(ctx, objects, key, keys)
+}
+
+// download retrieves and loads a snapshot, decrypting it if keys are given
+func download(ctx context.Context, objects ObjectStore, key string, keys encrypt.KeyProvider) (*semantic.SemanticStore, error) {
+	data, metadata, err := objects.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download snapshot %s: %v", key, err)
+	}
+	expected := metadata[HashMetadata]
+	if expected == "" {
+		return nil, fmt.Errorf("snapshot %s has no %s hash", key, HashMetadata)
+	}
+	sum := sha256.Sum256(data)
+	if actual := hex.EncodeToString(sum[:]); actual != expected {
+		return nil, fmt.Errorf("snapshot %s is corrupt: hash %s, expected %s", key, actual, expected)
+	}
+
+	var source io.Reader = bytes.NewReader(data)
+	if encrypt.IsEncrypted(data) {
+		if keys == nil {
+			return nil, fmt.Errorf("snapshot %s is encrypted", key)
+		}
+		if source, err = encrypt.NewReader(source, keys); err != nil {
+			return nil, fmt.Errorf("failed to decrypt snapshot %s: %v", key, err)
+		}
+	}
+	zr, err := gzip.NewReader(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress snapshot %s: %v", key, err)
+	}
+	defer zr.Close()
+	store := semantic.NewSemanticStore()
+	if err := store.LoadKMAC(zr); err != nil {
+		return nil, fmt.Errorf("failed to load snapshot %s: %v", key, err)
+	}
+	return store, nil
+}
+
+// Snapshots returns the keys of the snapshots stored under prefix, oldest first
+func Snapshots(ctx context.Context, objects ObjectStore, prefix string) ([]string, error) {
+	keys, err := objects.List(ctx, snapshotPrefix(prefix))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %v", err)
+	}
+	var snapshots []string
+	for _, key := range keys {
+		if strings.HasSuffix(key, keySuffix) || strings.HasSuffix(key, encryptedSuffix) {
+			snapshots = append(snapshots, key)
+		}
+	}
+	sort.Strings(snapshots)
+	return snapshots, nil
+}
+
+// Latest returns the key of the newest snapshot stored under prefix, or
+// ErrNotFound if there is none
+func Latest(ctx context.Context, objects ObjectStore, prefix string) (string, error) {
+	snapshots, err := Snapshots(ctx, objects, prefix)
+	if err != nil {
+		return "", err
+	}
+	if len(snapshots) == 0 {
+		return "", ErrNotFound
+	}
+	return snapshots[len(snapshots)-1], nil
+}
+
+// Restore loads the newest snapshot stored under prefix into a new store
+func Restore(ctx context.Context, objects ObjectStore, prefix string) (*semantic.SemanticStore, error) {
+	key, err := Latest(ctx, objects, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("no snapshot to restore under %q: %v", prefix, err)
+	}
+	return Download(ctx, objects, key)
+}
+
+// RestoreEncrypted loads the newest snapshot stored under prefix into a new
+// store, decrypting it if it is encrypted
+func RestoreEncrypted(ctx context.Context, objects ObjectStore, prefix string, keys encrypt.KeyProvider) (*semantic.SemanticStore, error) {
+	key, err := Latest(ctx, objects, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("no snapshot to restore under %q: %v", prefix, err)
+	}
+	return DownloadEncrypted(ctx, objects, key, keys)
+}
+
+// snapshotPrefix returns the key prefix of the snapshots under prefix
+func snapshotPrefix(prefix string) string {
+	if prefix == "" || strings.HasSuffix(prefix, "/") {
+		return prefix
+	}
+	return prefix + "/"
+}
+
+// snapshotKey returns the key of a snapshot taken at a time
+func snapshotKey(prefix string, takenAt time.Time, encrypted bool) string {
+	if encrypted {
+		return snapshotPrefix(prefix) + takenAt.UTC().Format(keyTimeFormat) + encryptedSuffix
+	}
+	return snapshotPrefix(prefix) + takenAt.UTC().Format(keyTimeFormat) + keySuffix
+}
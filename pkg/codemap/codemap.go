@@ -0,0 +1,226 @@
+// Package codemap converts between external classification codes, such as
+// GTIN and UNSPSC, and TOSID codes using mapping tables.
+//
+// A table maps codes of one scheme to TOSID codes. A lookup that finds no
+// exact entry falls back to the scheme's broader codes, so a table need only
+// list the categories it cares about.
+package codemap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+	"github.com/ha1tch/tosid-go/pkg/tosid"
+)
+
+// Scheme describes an external code system
+type Scheme struct {
+	Name string
+	// Normalize validates a code and returns its canonical form
+	Normalize func(code string) (string, error)
+	// Broader returns the next broader code, or "" at the top of the
+	// hierarchy; nil for flat schemes
+	Broader func(code string) string
+}
+
+var (
+	schemesMu sync.RWMutex
+	schemes   = make(map[string]Scheme)
+)
+
+// RegisterScheme makes a scheme available to mapping files by name
+func RegisterScheme(scheme Scheme) {
+	schemesMu.Lock()
+	defer schemesMu.Unlock()
+	schemes[strings.ToLower(scheme.Name)] = scheme
+}
+
+// LookupScheme returns a registered scheme
+func LookupScheme(name string) (Scheme, bool) {
+	schemesMu.RLock()
+	defer schemesMu.RUnlock()
+	scheme, exists := schemes[strings.ToLower(name)]
+	return scheme, exists
+}
+
+// Entry maps one external code to a TOSID code
+type Entry struct {
+	Code  string `json:"code"`
+	TOSID string `json:"tosid"`
+	Label string `json:"label,omitempty"`
+}
+
+// Table is a mapping table for one scheme
+type Table struct {
+	scheme  Scheme
+	entries []Entry
+	byCode  map[string]int
+	byTOSID map[string][]int // By canonical TOSID string
+}
+
+// tableFile is the JSON form of a table
+type tableFile struct {
+	Scheme  string  `json:"scheme"`
+	Entries []Entry `json:"entries"`
+}
+
+// NewTable creates an empty table for a scheme
+func NewTable(scheme Scheme) *Table {
+	return &Table{
+		scheme:  scheme,
+		byCode:  make(map[string]int),
+		byTOSID: make(map[string][]int),
+	}
+}
+
+// Load reads a mapping file naming a registered scheme
+func Load(r io.Reader) (*Table, error) {
+	var file tableFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to decode mapping table: %v", err)
+	}
+	scheme, exists := LookupScheme(file.Scheme)
+	if !exists {
+		return nil, fmt.Errorf("unknown code scheme %q", file.Scheme)
+	}
+	table := NewTable(scheme)
+	for _, entry := range file.Entries {
+		if err := table.Add(entry.Code, entry.TOSID, entry.Label); err != nil {
+			return nil, err
+		}
+	}
+	return table, nil
+}
+
+// Save writes the table as an indented mapping file
+func (t *Table) Save(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(tableFile{Scheme: t.scheme.Name, Entries: t.Entries()})
+}
+
+// Scheme returns the table's scheme
+func (t *Table) Scheme() Scheme {
+	return t.scheme
+}
+
+// Add maps an external code to a TOSID code, replacing any earlier mapping
+// for the code
+func (t *Table) Add(code string, tosidCode string, label string) error {
+	normalized, err := t.scheme.Normalize(code)
+	if err != nil {
+		return fmt.Errorf("invalid %s code %q: %v", t.scheme.Name, code, err)
+	}
+	tosidObj, err := tosid.Parse(tosidCode)
+	if err != nil {
+		return fmt.Errorf("invalid TOSID code %q for %s %s: %v", tosidCode, t.scheme.Name, normalized, err)
+	}
+
+	entry := Entry{Code: normalized, TOSID: tosidCode, Label: label}
+	if i, exists := t.byCode[normalized]; exists {
+		t.removeTOSIDIndex(i)
+		t.entries[i] = entry
+	} else {
+		t.byCode[normalized] = len(t.entries)
+		t.entries = append(t.entries, entry)
+	}
+	key := tosidObj.String()
+	t.byTOSID[key] = append(t.byTOSID[key], t.byCode[normalized])
+	return nil
+}
+
+// removeTOSIDIndex drops an entry from the reverse index
+func (t *Table) removeTOSIDIndex(i int) {
+	tosidObj, _ := tosid.Parse(t.entries[i].TOSID)
+	key := tosidObj.String()
+	indexes := t.byTOSID[key]
+	for j, index := range indexes {
+		if index == i {
+			t.byTOSID[key] = append(indexes[:j], indexes[j+1:]...)
+			break
+		}
+	}
+	if len(t.byTOSID[key]) == 0 {
+		delete(t.byTOSID, key)
+	}
+}
+
+// Entries returns every entry, sorted by code
+func (t *Table) Entries() []Entry {
+	entries := append([]Entry(nil), t.entries...)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Code < entries[j].Code })
+	return entries
+}
+
+// ToTOSID returns the entry for an external code, or for its nearest broader
+// code when the table has no exact entry
+func (t *Table) ToTOSID(code string) (Entry, error) {
+	normalized, err := t.scheme.Normalize(code)
+	if err != nil {
+		return Entry{}, fmt.Errorf("invalid %s code %q: %v", t.scheme.Name, code, err)
+	}
+	for current := normalized; current != ""; {
+		if i, exists := t.byCode[current]; exists {
+			return t.entries[i], nil
+		}
+		if t.scheme.Broader == nil {
+			break
+		}
+		current = t.scheme.Broader(current)
+	}
+	return Entry{}, fmt.Errorf("no TOSID mapping for %s %s", t.scheme.Name, normalized)
+}
+
+// FromTOSID returns the entries mapped to a TOSID code, sorted by code
+func (t *Table) FromTOSID(tosidCode string) ([]Entry, error) {
+	tosidObj, err := tosid.Parse(tosidCode)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOSID code %q: %v", tosidCode, err)
+	}
+	return t.collect(t.byTOSID[tosidObj.String()]), nil
+}
+
+// FromTOSIDPattern returns the entries whose TOSID code matches a pattern,
+// sorted by code
+func (t *Table) FromTOSIDPattern(pattern string) []Entry {
+	var indexes []int
+	for i, entry := range t.entries {
+		tosidObj, err := tosid.Parse(entry.TOSID)
+		if err == nil && tosidObj.MatchesPattern(pattern) {
+			indexes = append(indexes, i)
+		}
+	}
+	return t.collect(indexes)
+}
+
+// collect returns the entries at the given indexes, sorted by code
+func (t *Table) collect(indexes []int) []Entry {
+	entries := make([]Entry, 0, len(indexes))
+	for _, i := range indexes {
+		entries = append(entries, t.entries[i])
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Code < entries[j].Code })
+	return entries
+}
+
+// AddEntity adds an entity classified by an external code. The TOSID code
+// comes from the table, and the normalized external code is kept as a
+// property named after the scheme.
+func (t *Table) AddEntity(store *semantic.SemanticStore, id string, label string, code string) error {
+	entry, err := t.ToTOSID(code)
+	if err != nil {
+		return err
+	}
+	if err := store.AddEntity(id, label, entry.TOSID); err != nil {
+		return err
+	}
+	normalized, _ := t.scheme.Normalize(code)
+	entityRef, _ := store.GetEntity(id)
+	entityRef.KMACEntity.SetProperty(strings.ToLower(t.scheme.Name), normalized)
+	return nil
+}
@@ -0,0 +1,109 @@
+package codemap
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+)
+
+const supplyTable = `{
+  "scheme": "unspsc",
+  "entries": [
+    {"code": "51100000", "tosid": "10C5ME-DSU-PAN", "label": "Anti-infective drugs"},
+    {"code": "51201600", "tosid": "10C5ME-DSU-VCN", "label": "Vaccines"},
+    {"code": "42000000", "tosid": "10C5ME-DEQ-GEN", "label": "Medical equipment"}
+  ]
+}`
+
+func TestGTIN(t *testing.T) {
+	for input, want := range map[string]string{
+		"4006381333931":   "04006381333931",
+		"0 36000 29145 2": "00036000291452",
+		"96385074":        "00000096385074",
+	} {
+		got, err := GTIN.Normalize(input)
+		if err != nil || got != want {
+			t.Errorf("Normalize(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	for _, bad := range []string{"4006381333932", "12345", "40063813339A1"} {
+		if _, err := GTIN.Normalize(bad); err == nil {
+			t.Errorf("Expected error for GTIN %q", bad)
+		}
+	}
+
+	table := NewTable(GTIN)
+	if err := table.Add("4006381333931", "10C5ME-DSU-PAN", "Amoxicillin 500mg"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	entry, err := table.ToTOSID("04006381333931")
+	if err != nil || entry.TOSID != "10C5ME-DSU-PAN" {
+		t.Errorf("Unexpected GTIN lookup: %+v, %v", entry, err)
+	}
+	if _, err := table.ToTOSID("96385074"); err == nil {
+		t.Error("Expected flat GTIN scheme to have no broader fallback")
+	}
+}
+
+func TestUNSPSC(t *testing.T) {
+	table, err := Load(strings.NewReader(supplyTable))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	for code, want := range map[string]string{
+		"51201600":    "10C5ME-DSU-VCN",
+		"51-20-16-02": "10C5ME-DSU-VCN",
+		"51101500":    "10C5ME-DSU-PAN",
+		"42142100":    "10C5ME-DEQ-GEN",
+	} {
+		entry, err := table.ToTOSID(code)
+		if err != nil || entry.TOSID != want {
+			t.Errorf("ToTOSID(%q) = %+v, %v; want %s", code, entry, err, want)
+		}
+	}
+	if _, err := table.ToTOSID("43211500"); err == nil {
+		t.Error("Expected error for unmapped segment")
+	}
+
+	entries, err := table.FromTOSID("10C5ME-DSU-PAN")
+	if err != nil || len(entries) != 1 || entries[0].Code != "51100000" {
+		t.Errorf("Unexpected reverse lookup: %+v, %v", entries, err)
+	}
+	if entries := table.FromTOSIDPattern("10C-5ME-DSU"); len(entries) != 2 {
+		t.Errorf("Expected 2 drug entries, got %+v", entries)
+	}
+
+	var buf bytes.Buffer
+	if err := table.Save(&buf); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	reloaded, err := Load(&buf)
+	if err != nil || len(reloaded.Entries()) != 3 {
+		t.Errorf("Round trip failed: %v", err)
+	}
+
+	store := semantic.NewSemanticStore()
+	if err := table.AddEntity(store, "E1001", "Flu_Vaccine_Lot", "51201602"); err != nil {
+		t.Fatalf("AddEntity failed: %v", err)
+	}
+	entityRef, _ := store.GetEntity("E1001")
+	if code, _ := entityRef.KMACEntity.GetProperty("unspsc"); code != "51201602" || !entityRef.TOSIDObj.MatchesPattern("10C-5ME-DSU-VCN") {
+		t.Errorf("Unexpected entity: %q %v", code, entityRef.TOSIDObj)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, bad := range []string{
+		`{"scheme": "nsn", "entries": []}`,
+		`{"scheme": "unspsc", "entries": [{"code": "5110", "tosid": "10C5ME-DSU-PAN"}]}`,
+		`{"scheme": "unspsc", "entries": [{"code": "51100000", "tosid": "not a code"}]}`,
+		`[]`,
+	} {
+		if _, err := Load(strings.NewReader(bad)); err == nil {
+			t.Errorf("Expected error for %s", bad)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package codemap
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// GTIN is the GS1 Global Trade Item Number scheme. GTIN-8, GTIN-12 (UPC),
+// GTIN-13 (EAN), and GTIN-14 codes are accepted and normalized to 14 digits
+// after their check digit is verified.
+var GTIN = Scheme{
+	Name:      "GTIN",
+	Normalize: normalizeGTIN,
+}
+
+// UNSPSC is the United Nations Standard Products and Services Code scheme.
+// Codes have eight digits in segment, family, class, and commodity pairs;
+// lookups fall back from a commodity to its class, family, and segment.
+var UNSPSC = Scheme{
+	Name:      "UNSPSC",
+	Normalize: normalizeUNSPSC,
+	Broader:   broaderUNSPSC,
+}
+
+func init() {
+	RegisterScheme(GTIN)
+	RegisterScheme(UNSPSC)
+}
+
+// normalizeGTIN strips separators, checks the length and check digit, and
+// pads the code to 14 digits
+func normalizeGTIN(code string) (string, error) {
+	digits := stripSeparators(code)
+	switch len(digits) {
+	case 8, 12, 13, 14:
+	default:
+		return "", fmt.Errorf("GTIN must have 8, 12, 13, or 14 digits, got %d", len(digits))
+	}
+	if !isDigits(digits) {
+		return "", errors.New("GTIN must contain only digits")
+	}
+	if check := gtinCheckDigit(digits[:len(digits)-1]); digits[len(digits)-1] != check {
+		return "", fmt.Errorf("check digit should be %c", check)
+	}
+	return strings.Repeat("0", 14-len(digits)) + digits, nil
+}
+
+// gtinCheckDigit computes the GS1 mod-10 check digit for a code without it
+func gtinCheckDigit(body string) byte {
+	sum := 0
+	for i := 0; i < len(body); i++ {
+		digit := int(body[len(body)-1-i] - '0')
+		if i%2 == 0 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// normalizeUNSPSC strips separators and checks for eight digits
+func normalizeUNSPSC(code string) (string, error) {
+	digits := stripSeparators(code)
+	if len(digits) != 8 || !isDigits(digits) {
+		return "", errors.New("UNSPSC must have 8 digits")
+	}
+	if digits[:2] == "00" {
+		return "", errors.New("UNSPSC segment cannot be 00")
+	}
+	return digits, nil
+}
+
+// broaderUNSPSC zeroes the most specific non-zero pair of a code
+func broaderUNSPSC(code string) string {
+	for end := 8; end > 2; end -= 2 {
+		if code[end-2:end] != "00" {
+			return code[:end-2] + strings.Repeat("0", 10-end)
+		}
+	}
+	return ""
+}
+
+// stripSeparators removes spaces and hyphens from a code
+func stripSeparators(code string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code))
+}
+
+// isDigits reports whether a string is made of ASCII digits only
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,287 @@
+// Package convert translates KMAC statements between interchange formats:
+// KMAC text, JSON-LD, RDF as N-Triples, delimited protobuf messages, and
+// CSV. Statements travel as KMAC text lines broken into their parts, so
+// every statement type converts, and every format can be read back. Readers
+// and writers work a statement at a time, so inputs of any size stream
+// through.
+package convert
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+// Format names
+const (
+	KMACText = "kmac-text"
+	JSONLD   = "jsonld"
+	RDF      = "rdf" // N-Triples
+	Protobuf = "protobuf"
+	CSV      = "csv"
+)
+
+// Reader reads KMAC text lines from a format. Read returns io.EOF after the
+// last line.
+type Reader interface {
+	Read() (*kmac.TextLine, error)
+}
+
+// Writer writes KMAC text lines in a format. Close finishes the output
+// without closing the underlying writer.
+type Writer interface {
+	Write(line *kmac.TextLine) error
+	Close() error
+}
+
+// format creates the readers and writers of a format
+type format struct {
+	newReader func(r io.Reader) Reader
+	newWriter func(w io.Writer) Writer
+}
+
+var formats = map[string]format{
+	KMACText: {newTextReader, newTextWriter},
+	JSONLD:   {newJSONLDReader, newJSONLDWriter},
+	RDF:      {newRDFReader, newRDFWriter},
+	Protobuf: {newProtobufReader, newProtobufWriter},
+	CSV:      {newCSVReader, newCSVWriter},
+}
+
+// Formats returns the names of the formats, in order
+func Formats() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewReader creates a reader for a named format
+func NewReader(name string, r io.Reader) (Reader, error) {
+	f, exists := formats[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown format %q", name)
+	}
+	return f.newReader(r), nil
+}
+
+// NewWriter creates a writer for a named format
+func NewWriter(name string, w io.Writer) (Writer, error) {
+	f, exists := formats[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown format %q", name)
+	}
+	return f.newWriter(w), nil
+}
+
+// ValidationError is a line that does not decode to a valid statement
+type ValidationError struct {
+	Index int    // Of the line among those converted, from 1
+	Line  string // As KMAC text
+	Err   error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("statement %d (%s): %v", e.Index, e.Line, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Convert copies lines from r to w and returns the number copied. If decoder
+// is not nil, each line is decoded and its statement validated before it is
+// written, and the first that fails stops the conversion with a
+// *ValidationError; pass the same decoder when converting several inputs
+// whose lines refer to each other. The writer is left open.
+func Convert(w Writer, r Reader, decoder *kmac.LineDecoder) (int, error) {
+	return ConvertContext(context.Background(), w, r, decoder)
+}
+
+// ConvertContext copies lines from r to w as Convert does, stopping early if
+// ctx is done
+func ConvertContext(ctx context.Context, w Writer, r Reader, decoder *kmac.LineDecoder) (int, error) {
+	count := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+		line, err := r.Read()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		if decoder != nil {
+			stmt, err := decoder.Decode(line.String())
+			if err == nil && stmt != nil {
+				err = kmac.ValidateKMACStatement(stmt)
+			}
+			if err != nil {
+				return count, &ValidationError{Index: count + 1, Line: line.String(), Err: err}
+			}
+		}
+		if err := w.Write(line); err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+// textReader reads KMAC text, skipping blank lines and comments
+type textReader struct {
+	scanner *bufio.Scanner
+	lineNo  int
+}
+
+func newTextReader(r io.Reader) Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	return &textReader{scanner: scanner}
+}
+
+func (tr *textReader) Read() (*kmac.TextLine, error) {
+	for tr.scanner.Scan() {
+		tr.lineNo++
+		text := strings.TrimSpace(tr.scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		line, err := kmac.ParseTextLine(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", tr.lineNo, err)
+		}
+		return line, nil
+	}
+	if err := tr.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// textWriter writes KMAC text, a line per statement
+type textWriter struct {
+	w *bufio.Writer
+}
+
+func newTextWriter(w io.Writer) Writer {
+	return &textWriter{w: bufio.NewWriter(w)}
+}
+
+func (tw *textWriter) Write(line *kmac.TextLine) error {
+	_, err := tw.w.WriteString(line.String() + "\n")
+	return err
+}
+
+func (tw *textWriter) Close() error {
+	return tw.w.Flush()
+}
+
+// csvHeader names the columns of the CSV format: a row per field, with the
+// rows of a line sharing its sequence number, and a line without fields
+// written as a single row with the last two columns empty
+var csvHeader = []string{"line", "keyword", "id", "label", "field", "value"}
+
+// csvReader reads lines from CSV rows
+type csvReader struct {
+	r       *csv.Reader
+	started bool
+	pending []string // The first row of the next line, read ahead
+}
+
+func newCSVReader(r io.Reader) Reader {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(csvHeader)
+	return &csvReader{r: cr}
+}
+
+func (cr *csvReader) Read() (*kmac.TextLine, error) {
+	if !cr.started {
+		cr.started = true
+		header, err := cr.r.Read()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.Join(header, ",") != strings.Join(csvHeader, ",") {
+			return nil, fmt.Errorf("CSV header is %v, expected %v", header, csvHeader)
+		}
+	}
+
+	row := cr.pending
+	cr.pending = nil
+	if row == nil {
+		var err error
+		if row, err = cr.r.Read(); err != nil {
+			return nil, err
+		}
+	}
+	line := &kmac.TextLine{Keyword: row[1], ID: row[2], Label: row[3]}
+	for {
+		if row[4] != "" {
+			line.Fields = append(line.Fields, kmac.TextField{Name: row[4], Value: row[5]})
+		}
+		next, err := cr.r.Read()
+		if err == io.EOF {
+			return line, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if next[0] != row[0] {
+			cr.pending = next
+			return line, nil
+		}
+		row = next
+	}
+}
+
+// csvWriter writes lines as CSV rows
+type csvWriter struct {
+	w     *csv.Writer
+	lines int
+}
+
+func newCSVWriter(w io.Writer) Writer {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (cw *csvWriter) Write(line *kmac.TextLine) error {
+	if cw.lines == 0 {
+		if err := cw.w.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+	cw.lines++
+	n := strconv.Itoa(cw.lines)
+	if len(line.Fields) == 0 {
+		return cw.w.Write([]string{n, line.Keyword, line.ID, line.Label, "", ""})
+	}
+	for _, field := range line.Fields {
+		if field.Name == "" {
+			return errors.New("field without a name")
+		}
+		if err := cw.w.Write([]string{n, line.Keyword, line.ID, line.Label, field.Name, field.Value}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
@@ -0,0 +1,121 @@
+package convert
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+const sample = `DEF_ENTITY #E1001 [Earth] type=[00B3SO-LAR-ERT]
+DEF_ENTITY #E1002 [Moon \] the satellite] type=[]
+PROPERTY #E1002 [note] value=[line one\nline two]
+DEF_RELATION #R1001 [orbits] type=[SPATIAL]
+ASSERT #F1001 subject=[#E1002] relation=[#R1001] object=[#E1001]
+CONFIDENCE #F1001 level=[0.5] source=[survey]
+`
+
+// canonical decodes KMAC text and serializes it again, so conversions that
+// reorder fields compare equal
+func canonical(t *testing.T, text string) string {
+	t.Helper()
+	ts := kmac.NewTextSerializer()
+	statements, err := ts.Decode(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("Decode failed: %v\n%s", err, text)
+	}
+	out, err := ts.SerializeToString(statements)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	return out
+}
+
+// convert runs a conversion between named formats
+func convert(t *testing.T, from string, to string, input []byte) []byte {
+	t.Helper()
+	r, err := NewReader(from, bytes.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	w, err := NewWriter(to, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Convert(w, r, kmac.NewTextSerializer().NewLineDecoder()); err != nil {
+		t.Fatalf("Convert %s to %s failed: %v", from, to, err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	expected := canonical(t, sample)
+	for _, format := range Formats() {
+		t.Run(format, func(t *testing.T) {
+			converted := convert(t, KMACText, format, []byte(sample))
+			back := convert(t, format, KMACText, converted)
+			if got := canonical(t, string(back)); got != expected {
+				t.Errorf("Round trip through %s gave\n%s\nexpected\n%s\nconverted:\n%s", format, got, expected, converted)
+			}
+		})
+	}
+}
+
+func TestEmptyInput(t *testing.T) {
+	for _, format := range Formats() {
+		converted := convert(t, KMACText, format, nil)
+		if back := convert(t, format, KMACText, converted); len(back) != 0 {
+			t.Errorf("Empty input through %s gave %q", format, back)
+		}
+	}
+}
+
+func TestValidationFailure(t *testing.T) {
+	input := "DEF_ENTITY #E1001 [Earth] type=[00B3SO-LAR-ERT]\nCONFIDENCE #F9999 level=[0.5]\n"
+	r, _ := NewReader(KMACText, strings.NewReader(input))
+	var out bytes.Buffer
+	w, _ := NewWriter(JSONLD, &out)
+
+	count, err := Convert(w, r, kmac.NewTextSerializer().NewLineDecoder())
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+	if count != 1 || validationErr.Index != 2 {
+		t.Errorf("Expected failure at statement 2 after 1 converted, got %d after %d", validationErr.Index, count)
+	}
+
+	// Without a decoder, lines are converted unchecked
+	r, _ = NewReader(KMACText, strings.NewReader(input))
+	if count, err := Convert(w, r, nil); err != nil || count != 2 {
+		t.Errorf("Unvalidated conversion gave %d, %v", count, err)
+	}
+}
+
+func TestConvertCancelled(t *testing.T) {
+	r, _ := NewReader(KMACText, strings.NewReader(sample))
+	var out bytes.Buffer
+	w, _ := NewWriter(KMACText, &out)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if count, err := ConvertContext(ctx, w, r, nil); err != context.Canceled || count != 0 {
+		t.Errorf("Expected context.Canceled before anything was converted, got %d, %v", count, err)
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	if _, err := NewReader("yaml", strings.NewReader("")); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+	if _, err := NewWriter("yaml", &bytes.Buffer{}); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
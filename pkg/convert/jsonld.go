@@ -0,0 +1,178 @@
+package convert
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+// Vocabulary and base IRIs of the linked data formats: statement IDs are
+// IRIs under kmacBase, and keywords and field names under kmacVocab
+const (
+	kmacBase  = "urn:kmac:"
+	kmacVocab = "urn:kmac:terms:"
+)
+
+// jsonldContext maps the JSON-LD nodes' keys and types onto the vocabulary
+var jsonldContext = map[string]string{"@vocab": kmacVocab, "@base": kmacBase}
+
+// jsonldWriter writes lines as the nodes of a JSON-LD graph. Each node has
+// the keyword as its @type, and the ID and label as id and label keys, so
+// the qualifier lines of a statement stay separate nodes. Fields referring
+// to statements are written as node references.
+type jsonldWriter struct {
+	w     *bufio.Writer
+	nodes int
+}
+
+func newJSONLDWriter(w io.Writer) Writer {
+	return &jsonldWriter{w: bufio.NewWriter(w)}
+}
+
+// start writes what precedes the first node
+func (jw *jsonldWriter) start() error {
+	context, _ := json.Marshal(jsonldContext)
+	_, err := jw.w.WriteString(`{"@context":` + string(context) + `,"@graph":[`)
+	return err
+}
+
+func (jw *jsonldWriter) Write(line *kmac.TextLine) error {
+	node := map[string]interface{}{"@type": line.Keyword}
+	if line.ID != "" {
+		node["id"] = line.ID
+	}
+	if line.Label != "" {
+		node["label"] = line.Label
+	}
+	for _, field := range line.Fields {
+		if _, clashes := node[field.Name]; clashes || strings.HasPrefix(field.Name, "@") {
+			return fmt.Errorf("field %s of %s cannot be written as JSON-LD", field.Name, line.Keyword)
+		}
+		if id, isReference := strings.CutPrefix(field.Value, "#"); isReference {
+			node[field.Name] = map[string]string{"@id": id}
+		} else {
+			node[field.Name] = field.Value
+		}
+	}
+	data, err := json.Marshal(node)
+	if err != nil {
+		return err
+	}
+
+	separator := ","
+	if jw.nodes == 0 {
+		if err := jw.start(); err != nil {
+			return err
+		}
+		separator = ""
+	}
+	jw.nodes++
+	_, err = jw.w.WriteString(separator + "\n" + string(data))
+	return err
+}
+
+func (jw *jsonldWriter) Close() error {
+	if jw.nodes == 0 {
+		if err := jw.start(); err != nil {
+			return err
+		}
+	}
+	if _, err := jw.w.WriteString("\n]}\n"); err != nil {
+		return err
+	}
+	return jw.w.Flush()
+}
+
+// jsonldReader reads lines from the nodes of a JSON-LD graph as
+// jsonldWriter writes them, decoding one node at a time
+type jsonldReader struct {
+	decoder *json.Decoder
+	inGraph bool
+	done    bool
+}
+
+func newJSONLDReader(r io.Reader) Reader {
+	return &jsonldReader{decoder: json.NewDecoder(r)}
+}
+
+// findGraph advances the decoder into the document's @graph array
+func (jr *jsonldReader) findGraph() error {
+	if token, err := jr.decoder.Token(); err != nil {
+		return fmt.Errorf("failed to read JSON-LD: %v", err)
+	} else if token != json.Delim('{') {
+		return fmt.Errorf("JSON-LD document is not an object")
+	}
+	for jr.decoder.More() {
+		token, err := jr.decoder.Token()
+		if err != nil {
+			return fmt.Errorf("failed to read JSON-LD: %v", err)
+		}
+		if token == "@graph" {
+			if token, err := jr.decoder.Token(); err != nil || token != json.Delim('[') {
+				return fmt.Errorf("JSON-LD @graph is not an array")
+			}
+			return nil
+		}
+		var skipped json.RawMessage
+		if err := jr.decoder.Decode(&skipped); err != nil {
+			return fmt.Errorf("failed to read JSON-LD: %v", err)
+		}
+	}
+	return fmt.Errorf("JSON-LD document has no @graph")
+}
+
+func (jr *jsonldReader) Read() (*kmac.TextLine, error) {
+	if jr.done {
+		return nil, io.EOF
+	}
+	if !jr.inGraph {
+		if err := jr.findGraph(); err != nil {
+			return nil, err
+		}
+		jr.inGraph = true
+	}
+	if !jr.decoder.More() {
+		jr.done = true
+		return nil, io.EOF
+	}
+
+	var node map[string]interface{}
+	if err := jr.decoder.Decode(&node); err != nil {
+		return nil, fmt.Errorf("failed to read JSON-LD node: %v", err)
+	}
+	keyword, _ := node["@type"].(string)
+	if keyword == "" {
+		return nil, fmt.Errorf("JSON-LD node has no @type")
+	}
+	line := &kmac.TextLine{Keyword: keyword}
+	line.ID, _ = node["id"].(string)
+	line.Label, _ = node["label"].(string)
+
+	names := make([]string, 0, len(node))
+	for name := range node {
+		if name != "@type" && name != "id" && name != "label" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		switch value := node[name].(type) {
+		case string:
+			line.Fields = append(line.Fields, kmac.TextField{Name: name, Value: value})
+		case map[string]interface{}:
+			id, isReference := value["@id"].(string)
+			if !isReference {
+				return nil, fmt.Errorf("JSON-LD field %s of %s is neither a string nor a node reference", name, keyword)
+			}
+			line.Fields = append(line.Fields, kmac.TextField{Name: name, Value: "#" + strings.TrimPrefix(id, kmacBase)})
+		default:
+			return nil, fmt.Errorf("JSON-LD field %s of %s is neither a string nor a node reference", name, keyword)
+		}
+	}
+	return line, nil
+}
@@ -0,0 +1,188 @@
+package convert
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+// The protobuf format is a stream of Line messages, each preceded by its
+// length as a varint, as written by protobuf's writeDelimitedTo:
+//
+//	message Line {
+//	  string keyword = 1;
+//	  string id = 2;
+//	  string label = 3;
+//	  repeated Field fields = 4;
+//	}
+//
+//	message Field {
+//	  string name = 1;
+//	  string value = 2;
+//	}
+
+// maxMessageSize caps the length of a message read
+const maxMessageSize = 16 * 1024 * 1024
+
+// Protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// appendBytesField appends a length-delimited field, omitting empty ones as
+// proto3 does
+func appendBytesField(buf []byte, number int, value []byte) []byte {
+	if len(value) == 0 {
+		return buf
+	}
+	buf = binary.AppendUvarint(buf, uint64(number<<3|wireBytes))
+	buf = binary.AppendUvarint(buf, uint64(len(value)))
+	return append(buf, value...)
+}
+
+// protobufWriter writes lines as delimited Line messages
+type protobufWriter struct {
+	w *bufio.Writer
+}
+
+func newProtobufWriter(w io.Writer) Writer {
+	return &protobufWriter{w: bufio.NewWriter(w)}
+}
+
+func (pw *protobufWriter) Write(line *kmac.TextLine) error {
+	var message []byte
+	message = appendBytesField(message, 1, []byte(line.Keyword))
+	message = appendBytesField(message, 2, []byte(line.ID))
+	message = appendBytesField(message, 3, []byte(line.Label))
+	for _, field := range line.Fields {
+		var encoded []byte
+		encoded = appendBytesField(encoded, 1, []byte(field.Name))
+		encoded = appendBytesField(encoded, 2, []byte(field.Value))
+		// An empty field is still a repeated element, so it is written even
+		// though it has no content
+		message = binary.AppendUvarint(message, 4<<3|wireBytes)
+		message = binary.AppendUvarint(message, uint64(len(encoded)))
+		message = append(message, encoded...)
+	}
+
+	if _, err := pw.w.Write(binary.AppendUvarint(nil, uint64(len(message)))); err != nil {
+		return err
+	}
+	_, err := pw.w.Write(message)
+	return err
+}
+
+func (pw *protobufWriter) Close() error {
+	return pw.w.Flush()
+}
+
+// protobufReader reads lines from delimited Line messages
+type protobufReader struct {
+	r        *bufio.Reader
+	messages int
+}
+
+func newProtobufReader(r io.Reader) Reader {
+	return &protobufReader{r: bufio.NewReader(r)}
+}
+
+func (pr *protobufReader) Read() (*kmac.TextLine, error) {
+	size, err := binary.ReadUvarint(pr.r)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("message %d: failed to read length: %v", pr.messages+1, err)
+	}
+	pr.messages++
+	if size > maxMessageSize {
+		return nil, fmt.Errorf("message %d: length %d exceeds %d", pr.messages, size, maxMessageSize)
+	}
+	message := make([]byte, size)
+	if _, err := io.ReadFull(pr.r, message); err != nil {
+		return nil, fmt.Errorf("message %d: %v", pr.messages, err)
+	}
+
+	line := &kmac.TextLine{}
+	err = decodeFields(message, func(number uint64, value []byte) error {
+		switch number {
+		case 1:
+			line.Keyword = string(value)
+		case 2:
+			line.ID = string(value)
+		case 3:
+			line.Label = string(value)
+		case 4:
+			var field kmac.TextField
+			err := decodeFields(value, func(number uint64, value []byte) error {
+				switch number {
+				case 1:
+					field.Name = string(value)
+				case 2:
+					field.Value = string(value)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			line.Fields = append(line.Fields, field)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("message %d: %v", pr.messages, err)
+	}
+	if line.Keyword == "" {
+		return nil, fmt.Errorf("message %d: line has no keyword", pr.messages)
+	}
+	return line, nil
+}
+
+// decodeFields calls fn with the number and content of each length-delimited
+// field of a message, skipping fields of other wire types
+func decodeFields(message []byte, fn func(number uint64, value []byte) error) error {
+	for len(message) > 0 {
+		key, n := binary.Uvarint(message)
+		if n <= 0 {
+			return fmt.Errorf("malformed field key")
+		}
+		message = message[n:]
+
+		switch key & 7 {
+		case wireVarint:
+			if _, n = binary.Uvarint(message); n <= 0 {
+				return fmt.Errorf("malformed varint field %d", key>>3)
+			}
+			message = message[n:]
+		case wireFixed64, wireFixed32:
+			width := 8
+			if key&7 == wireFixed32 {
+				width = 4
+			}
+			if len(message) < width {
+				return fmt.Errorf("truncated field %d", key>>3)
+			}
+			message = message[width:]
+		case wireBytes:
+			length, n := binary.Uvarint(message)
+			if n <= 0 || length > uint64(len(message)-n) {
+				return fmt.Errorf("truncated field %d", key>>3)
+			}
+			value := message[n : n+int(length)]
+			message = message[n+int(length):]
+			if err := fn(key>>3, value); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("field %d has unsupported wire type %d", key>>3, key&7)
+		}
+	}
+	return nil
+}
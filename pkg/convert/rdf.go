@@ -0,0 +1,139 @@
+package convert
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+	"github.com/ha1tch/tosid-go/pkg/rdf"
+)
+
+// rdfWriter writes each line as a blank node with an rdf:type of its
+// keyword and a triple per part: the ID and label as literals, and fields
+// as literals or, for references, statement IRIs
+type rdfWriter struct {
+	w     *bufio.Writer
+	lines int
+}
+
+func newRDFWriter(w io.Writer) Writer {
+	return &rdfWriter{w: bufio.NewWriter(w)}
+}
+
+func (rw *rdfWriter) Write(line *kmac.TextLine) error {
+	rw.lines++
+	node := rdf.Term{Kind: rdf.Blank, Value: "l" + strconv.Itoa(rw.lines)}
+	triples := []rdf.Triple{{Subject: node, Predicate: vocabTerm(rdf.RDFType, ""), Object: vocabTerm(kmacVocab, line.Keyword)}}
+	literal := func(name string, value string) {
+		triples = append(triples, rdf.Triple{Subject: node, Predicate: vocabTerm(kmacVocab, name), Object: rdf.Term{Kind: rdf.Literal, Value: value}})
+	}
+	if line.ID != "" {
+		literal("id", line.ID)
+	}
+	if line.Label != "" {
+		literal("label", line.Label)
+	}
+	for _, field := range line.Fields {
+		if id, isReference := strings.CutPrefix(field.Value, "#"); isReference {
+			triples = append(triples, rdf.Triple{Subject: node, Predicate: vocabTerm(kmacVocab, field.Name), Object: vocabTerm(kmacBase, id)})
+		} else {
+			literal(field.Name, field.Value)
+		}
+	}
+
+	for _, triple := range triples {
+		if _, err := rw.w.WriteString(triple.String() + "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rw *rdfWriter) Close() error {
+	return rw.w.Flush()
+}
+
+// vocabTerm returns the IRI of a name under a prefix
+func vocabTerm(prefix string, name string) rdf.Term {
+	return rdf.Term{Kind: rdf.IRI, Value: prefix + name}
+}
+
+// rdfReader reads lines from N-Triples as rdfWriter writes them. The
+// triples of a line are consecutive, so they are read a line at a time.
+type rdfReader struct {
+	scanner *bufio.Scanner
+	lineNo  int
+	pending *rdf.Triple // The first triple of the next line, read ahead
+}
+
+func newRDFReader(r io.Reader) Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	return &rdfReader{scanner: scanner}
+}
+
+// next reads the next triple, or returns nil at the end of the input
+func (rr *rdfReader) next() (*rdf.Triple, error) {
+	if triple := rr.pending; triple != nil {
+		rr.pending = nil
+		return triple, nil
+	}
+	for rr.scanner.Scan() {
+		rr.lineNo++
+		text := strings.TrimSpace(rr.scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		triple, err := rdf.ParseTriple(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", rr.lineNo, err)
+		}
+		return &triple, nil
+	}
+	return nil, rr.scanner.Err()
+}
+
+func (rr *rdfReader) Read() (*kmac.TextLine, error) {
+	first, err := rr.next()
+	if err != nil {
+		return nil, err
+	}
+	if first == nil {
+		return nil, io.EOF
+	}
+
+	line := &kmac.TextLine{}
+	for triple := first; triple != nil; {
+		predicate := triple.Predicate.Value
+		name, inVocab := strings.CutPrefix(predicate, kmacVocab)
+		switch {
+		case predicate == rdf.RDFType:
+			line.Keyword = strings.TrimPrefix(triple.Object.Value, kmacVocab)
+		case !inVocab:
+			return nil, fmt.Errorf("line %d: predicate %s is not in the KMAC vocabulary", rr.lineNo, predicate)
+		case triple.Object.Kind == rdf.IRI:
+			line.Fields = append(line.Fields, kmac.TextField{Name: name, Value: "#" + strings.TrimPrefix(triple.Object.Value, kmacBase)})
+		case name == "id":
+			line.ID = triple.Object.Value
+		case name == "label":
+			line.Label = triple.Object.Value
+		default:
+			line.Fields = append(line.Fields, kmac.TextField{Name: name, Value: triple.Object.Value})
+		}
+
+		if triple, err = rr.next(); err != nil {
+			return nil, err
+		}
+		if triple != nil && triple.Subject != first.Subject {
+			rr.pending = triple
+			break
+		}
+	}
+	if line.Keyword == "" {
+		return nil, fmt.Errorf("node %s has no rdf:type", first.Subject)
+	}
+	return line, nil
+}
@@ -0,0 +1,256 @@
+// Package coverage compares the TOSIDs of a store against a reference
+// taxonomy, reporting the categories with no instances, the category codes
+// the reference does not know, and how unevenly instances are spread.
+//
+// Categories are TOSID prefixes at the levels of TOSID.GetHierarchy, such as
+// "10", "10B", "10B-3TR", or "10B-3TR-AIR". Prefixes may also be written
+// without the hyphen after the netmask, as in codes: "10B3TR-AIR".
+package coverage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+)
+
+// maxExamples is how many entities an unknown segment lists
+const maxExamples = 5
+
+// prefixPattern matches a category prefix in hierarchy form
+var prefixPattern = regexp.MustCompile(`^\d{2}([A-Z](-\d?[A-Z]{2}(-[A-Z]{3}){0,2})?)?$`)
+
+// Category is a category of a reference taxonomy
+type Category struct {
+	Prefix string `json:"prefix"`
+	Label  string `json:"label,omitempty"`
+}
+
+// Registry is a reference taxonomy: the categories a catalog is expected to
+// cover, and so the category codes its TOSIDs may use
+type Registry struct {
+	categories map[string]Category
+	children   map[string][]string // Registered child prefixes by parent prefix, "" for the top level
+}
+
+// registryFile is the JSON form of a registry
+type registryFile struct {
+	Categories []Category `json:"categories"`
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		categories: make(map[string]Category),
+		children:   make(map[string][]string),
+	}
+}
+
+// LoadRegistry reads a registry file listing categories
+func LoadRegistry(r io.Reader) (*Registry, error) {
+	var file registryFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to decode registry: %v", err)
+	}
+	registry := NewRegistry()
+	for _, category := range file.Categories {
+		if err := registry.Add(category.Prefix, category.Label); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// Add registers a category. Its parent levels need not be registered.
+func (r *Registry) Add(prefix string, label string) error {
+	prefix = normalize(prefix)
+	if !prefixPattern.MatchString(prefix) {
+		return fmt.Errorf("invalid category prefix %q", prefix)
+	}
+	if _, exists := r.categories[prefix]; !exists {
+		parent := parentOf(prefix)
+		r.children[parent] = append(r.children[parent], prefix)
+	}
+	r.categories[prefix] = Category{Prefix: prefix, Label: label}
+	return nil
+}
+
+// Categories returns the registered categories, sorted by prefix
+func (r *Registry) Categories() []Category {
+	categories := make([]Category, 0, len(r.categories))
+	for _, category := range r.categories {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i].Prefix < categories[j].Prefix })
+	return categories
+}
+
+// UnknownSegment is a category code used where the registry enumerates the
+// categories, but not among them
+type UnknownSegment struct {
+	Prefix   string   // The unregistered prefix
+	Parent   string   // The level it falls under
+	Count    int      // Entities using it
+	Examples []string // IDs of the first few of them
+}
+
+// Skew is how unevenly the instances of a category are spread over its
+// registered children
+type Skew struct {
+	Parent   string  // Category whose children are compared; "" for the top level
+	Evenness float64 // Normalized entropy of the children's counts: 1 when even, 0 when all in one
+	Largest  string  // The child with the most instances
+	Share    float64 // Its share of the children's instances
+}
+
+// Report is the outcome of a coverage analysis
+type Report struct {
+	Entities int              // Entities with a TOSID
+	Counts   map[string]int   // Instances under each registered category
+	Gaps     []Category       // Registered categories with no instances, leaving out those under a gap
+	Unknown  []UnknownSegment // Most used first
+	Skew     []Skew           // Of the categories with instances in two or more children, most skewed first
+}
+
+// Analyze compares the TOSIDs of a store's entities against a registry
+func Analyze(store *semantic.SemanticStore, registry *Registry) *Report {
+	report := &Report{Counts: make(map[string]int, len(registry.categories))}
+	for prefix := range registry.categories {
+		report.Counts[prefix] = 0
+	}
+
+	unknown := make(map[string]*UnknownSegment)
+	store.RangeEntities(func(entityRef *semantic.EntityReference) bool {
+		if entityRef.TOSIDObj == nil {
+			return true
+		}
+		report.Entities++
+		parent := ""
+		for _, prefix := range entityRef.TOSIDObj.GetHierarchy() {
+			// A segment is only unknown where the registry lists the
+			// segments of its level; other levels are left open
+			if _, registered := registry.categories[prefix]; registered {
+				report.Counts[prefix]++
+			} else if len(registry.children[parent]) > 0 && isSegment(prefix) {
+				segment, exists := unknown[prefix]
+				if !exists {
+					segment = &UnknownSegment{Prefix: prefix, Parent: parent}
+					unknown[prefix] = segment
+				}
+				segment.Count++
+				segment.Examples = append(segment.Examples, entityRef.KMACEntity.ID())
+				break
+			}
+			parent = prefix
+		}
+		return true
+	})
+
+	for _, category := range registry.Categories() {
+		if report.Counts[category.Prefix] == 0 && !underGap(registry, report.Counts, category.Prefix) {
+			report.Gaps = append(report.Gaps, category)
+		}
+	}
+
+	for _, segment := range unknown {
+		sort.Strings(segment.Examples)
+		if len(segment.Examples) > maxExamples {
+			segment.Examples = segment.Examples[:maxExamples]
+		}
+		report.Unknown = append(report.Unknown, *segment)
+	}
+	sort.Slice(report.Unknown, func(i, j int) bool {
+		if report.Unknown[i].Count != report.Unknown[j].Count {
+			return report.Unknown[i].Count > report.Unknown[j].Count
+		}
+		return report.Unknown[i].Prefix < report.Unknown[j].Prefix
+	})
+
+	for parent, children := range registry.children {
+		if skew, ok := skewOf(parent, children, report.Counts); ok {
+			report.Skew = append(report.Skew, skew)
+		}
+	}
+	sort.Slice(report.Skew, func(i, j int) bool {
+		if report.Skew[i].Evenness != report.Skew[j].Evenness {
+			return report.Skew[i].Evenness < report.Skew[j].Evenness
+		}
+		return report.Skew[i].Parent < report.Skew[j].Parent
+	})
+	return report
+}
+
+// skewOf measures the spread of instances over a category's children,
+// reporting false unless two or more of them have instances
+func skewOf(parent string, children []string, counts map[string]int) (Skew, bool) {
+	children = append([]string(nil), children...)
+	sort.Strings(children)
+	total, populated := 0, 0
+	skew := Skew{Parent: parent}
+	for _, child := range children {
+		count := counts[child]
+		total += count
+		if count > 0 {
+			populated++
+		}
+		if skew.Largest == "" || count > counts[skew.Largest] {
+			skew.Largest = child
+		}
+	}
+	if populated < 2 {
+		return Skew{}, false
+	}
+
+	entropy := 0.0
+	for _, child := range children {
+		if count := counts[child]; count > 0 {
+			p := float64(count) / float64(total)
+			entropy -= p * math.Log(p)
+		}
+	}
+	skew.Evenness = entropy / math.Log(float64(len(children)))
+	skew.Share = float64(counts[skew.Largest]) / float64(total)
+	return skew, true
+}
+
+// underGap reports whether a registered ancestor of a prefix has no
+// instances
+func underGap(registry *Registry, counts map[string]int, prefix string) bool {
+	for parent := parentOf(prefix); parent != ""; parent = parentOf(parent) {
+		if _, registered := registry.categories[parent]; registered && counts[parent] == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// isSegment reports whether a hierarchy level ends in a category segment,
+// rather than a taxonomy, netmask, or specific identifier
+func isSegment(prefix string) bool {
+	return strings.Contains(prefix, "-") && !strings.Contains(prefix, ":")
+}
+
+// parentOf returns the hierarchy level above a prefix, "" above a taxonomy
+func parentOf(prefix string) string {
+	switch {
+	case len(prefix) <= 2:
+		return ""
+	case len(prefix) == 3:
+		return prefix[:2]
+	}
+	return prefix[:strings.LastIndex(prefix, "-")]
+}
+
+// normalize puts a prefix in hierarchy form, with a hyphen after the netmask
+func normalize(prefix string) string {
+	prefix = strings.ToUpper(strings.TrimSpace(prefix))
+	if len(prefix) > 3 && prefix[3] != '-' {
+		prefix = prefix[:3] + "-" + prefix[3:]
+	}
+	return prefix
+}
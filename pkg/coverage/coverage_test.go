@@ -0,0 +1,78 @@
+package coverage
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ha1tch/tosid-go/pkg/datasets"
+)
+
+const testRegistry = `{
+  "categories": [
+    {"prefix": "10C5MD-SUP", "label": "Medical supplies"},
+    {"prefix": "10C-5MD-SUP-ANB", "label": "Antibiotics"},
+    {"prefix": "10C-5MD-SUP-VCN", "label": "Vaccines"},
+    {"prefix": "10C-5MD-SUP-BND", "label": "Bandages"},
+    {"prefix": "10B-3TR-AIR", "label": "Aircraft"},
+    {"prefix": "10B-3TR-SEA", "label": "Vessels"},
+    {"prefix": "11B-1ME-ORG-GOV", "label": "Government agencies"}
+  ]
+}`
+
+func TestAnalyze(t *testing.T) {
+	registry, err := LoadRegistry(strings.NewReader(testRegistry))
+	if err != nil {
+		t.Fatalf("Failed to load registry: %v", err)
+	}
+	store, err := datasets.LoadExample(datasets.DisasterResponse)
+	if err != nil {
+		t.Fatalf("Failed to load dataset: %v", err)
+	}
+
+	report := Analyze(store, registry)
+	if report.Entities != 10 {
+		t.Errorf("Expected 10 entities, got %d", report.Entities)
+	}
+	if report.Counts["10C-5MD-SUP"] != 2 || report.Counts["10B-3TR-AIR"] != 1 {
+		t.Errorf("Unexpected counts: %v", report.Counts)
+	}
+
+	var gaps []string
+	for _, gap := range report.Gaps {
+		gaps = append(gaps, gap.Prefix)
+	}
+	if want := []string{"10B-3TR-SEA", "10C-5MD-SUP-BND", "11B-1ME-ORG-GOV"}; !reflect.DeepEqual(gaps, want) {
+		t.Errorf("Expected gaps %v, got %v", want, gaps)
+	}
+
+	if len(report.Unknown) != 1 || report.Unknown[0].Prefix != "11B-1ME-ORG-NGO" || report.Unknown[0].Parent != "11B-1ME-ORG" ||
+		!reflect.DeepEqual(report.Unknown[0].Examples, []string{"E1005"}) {
+		t.Errorf("Expected the NGO segment to be unknown, got %+v", report.Unknown)
+	}
+
+	if len(report.Skew) != 1 {
+		t.Fatalf("Expected one skew, got %+v", report.Skew)
+	}
+	skew := report.Skew[0]
+	if skew.Parent != "10C-5MD-SUP" || skew.Largest != "10C-5MD-SUP-ANB" || skew.Share != 0.5 ||
+		math.Abs(skew.Evenness-math.Log(2)/math.Log(3)) > 1e-9 {
+		t.Errorf("Unexpected skew: %+v", skew)
+	}
+}
+
+func TestRegistryAdd(t *testing.T) {
+	registry := NewRegistry()
+	for _, bad := range []string{"1", "10B3", "10B-3TR-AIR-HEL-X"} {
+		if err := registry.Add(bad, ""); err == nil {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+	if err := registry.Add("10b3tr", "Transport"); err != nil {
+		t.Fatalf("Failed to add category: %v", err)
+	}
+	if categories := registry.Categories(); len(categories) != 1 || categories[0].Prefix != "10B-3TR" {
+		t.Errorf("Expected the prefix in hierarchy form, got %v", categories)
+	}
+}
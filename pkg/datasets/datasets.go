@@ -0,0 +1,62 @@
+// Package datasets bundles the example knowledge bases as KMAC text, so
+// tests and demos can load them through the real parser instead of building
+// them up statement by statement in Go.
+package datasets
+
+import (
+	"embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+)
+
+// Names of the bundled datasets
+const (
+	Apollo11         = "apollo11"          // The first crewed Moon landing
+	Exoplanets       = "exoplanets"        // Potentially habitable planets and their stars
+	DisasterResponse = "disaster_response" // Supplies, needs, and infrastructure state in a disaster
+	SpaceProgram     = "space_program"     // Vehicles and phases of a multi-decade program
+)
+
+//go:embed data/*.kmac
+var files embed.FS
+
+// Names returns the names of the bundled datasets, sorted
+func Names() []string {
+	entries, _ := fs.ReadDir(files, "data")
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".kmac"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Open returns the KMAC text of a dataset, to be closed by the caller
+func Open(name string) (io.ReadCloser, error) {
+	f, err := files.Open(path.Join("data", name+".kmac"))
+	if err != nil {
+		return nil, fmt.Errorf("unknown dataset %q", name)
+	}
+	return f, nil
+}
+
+// LoadExample loads a dataset into a new store
+func LoadExample(name string) (*semantic.SemanticStore, error) {
+	f, err := Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	store := semantic.NewSemanticStore()
+	if err := store.LoadKMAC(f); err != nil {
+		return nil, fmt.Errorf("dataset %s: %v", name, err)
+	}
+	return store, nil
+}
@@ -0,0 +1,77 @@
+package datasets
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLoadExample(t *testing.T) {
+	counts := map[string]struct{ entities, assertions int }{
+		Apollo11:         {7, 6},
+		Exoplanets:       {12, 9},
+		DisasterResponse: {10, 9},
+		SpaceProgram:     {9, 8},
+	}
+	names := Names()
+	if len(names) != len(counts) {
+		t.Fatalf("Expected %d datasets, got %v", len(counts), names)
+	}
+	for _, name := range names {
+		store, err := LoadExample(name)
+		if err != nil {
+			t.Fatalf("Failed to load %s: %v", name, err)
+		}
+		want, exists := counts[name]
+		if !exists {
+			t.Fatalf("Unexpected dataset %s", name)
+		}
+		stats := store.GetStatistics()
+		if stats["entities"] != want.entities || stats["assertions"] != want.assertions {
+			t.Errorf("Expected %s to hold %d entities and %d assertions, got %d and %d",
+				name, want.entities, want.assertions, stats["entities"], stats["assertions"])
+		}
+		if warnings := store.ValidateStore(); len(warnings) > 0 {
+			t.Errorf("Expected %s to validate, got %v", name, warnings)
+		}
+	}
+}
+
+func TestExampleContent(t *testing.T) {
+	store, err := LoadExample(DisasterResponse)
+	if err != nil {
+		t.Fatalf("Failed to load dataset: %v", err)
+	}
+	if transport := store.FindEntitiesByTOSIDPattern("10B-3TR"); len(transport) != 1 || transport[0].KMACEntity.Label() != "Helicopter" {
+		t.Errorf("Expected the helicopter to match 10B-3TR, got %v", transport)
+	}
+	if state, ok := store.CurrentState("E3001", "passable"); !ok || state.Value() != "60%" {
+		t.Errorf("Expected the highway to be 60%% passable, got %v", state)
+	}
+
+	store, err = LoadExample(Apollo11)
+	if err != nil {
+		t.Fatalf("Failed to load dataset: %v", err)
+	}
+	if description, err := store.DescribeAssertion("F1001"); err != nil || !strings.Contains(description, "NASA") {
+		t.Errorf("Expected F1001 to describe NASA, got %q, %v", description, err)
+	}
+	if _, exists := store.Temporal("F1004"); !exists {
+		t.Error("Expected the landing to be qualified in time")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	if _, err := LoadExample("atlantis"); err == nil {
+		t.Error("Expected an unknown dataset to fail")
+	}
+	f, err := Open(Exoplanets)
+	if err != nil {
+		t.Fatalf("Failed to open dataset: %v", err)
+	}
+	defer f.Close()
+	text, err := io.ReadAll(f)
+	if err != nil || !strings.Contains(string(text), "DEF_ENTITY #E2002 [Kepler-186f]") {
+		t.Errorf("Expected the KMAC text of the exoplanets, got %v", err)
+	}
+}
@@ -0,0 +1,321 @@
+// Package encrypt seals snapshots and serialized statement streams with
+// AES-GCM, since knowledge bases may hold sensitive operational data such
+// as casualty counts or infrastructure weaknesses.
+//
+// Data is split into chunks that are sealed one by one, so streams of any
+// size can be encrypted without holding them in memory. Each chunk is bound
+// to its position and the last is marked, so reordered, dropped, or
+// truncated chunks fail to decrypt. The header names the key used, so keys
+// can be rotated while older data stays readable.
+package encrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// magic starts every encrypted stream
+const magic = "TOSIDENC"
+
+// version is the format version written after the magic
+const version = 1
+
+// noncePrefixSize is the size of the random part of each chunk's nonce; the
+// rest holds the chunk counter and the last-chunk flag
+const noncePrefixSize = 7
+
+// chunkSize is the most plaintext sealed in one chunk
+var chunkSize = 64 << 10
+
+// Chunk flags
+const (
+	flagMore byte = 0
+	flagLast byte = 1
+)
+
+// KeyProvider supplies encryption keys, for integration with a key
+// management service. Keys are 16, 24, or 32 bytes, for AES-128, AES-192,
+// or AES-256.
+type KeyProvider interface {
+	// EncryptionKey returns the ID and key to encrypt new data with
+	EncryptionKey() (id string, key []byte, err error)
+	// DecryptionKey returns the key with an ID, to decrypt data sealed with it
+	DecryptionKey(id string) ([]byte, error)
+}
+
+// StaticKeys is a fixed set of keys, one of which encrypts new data
+type StaticKeys struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewStaticKeys creates a key set that encrypts with the key named current
+// and decrypts with any of the keys
+func NewStaticKeys(current string, keys map[string][]byte) (*StaticKeys, error) {
+	if _, exists := keys[current]; !exists {
+		return nil, fmt.Errorf("key %s not found", current)
+	}
+	copied := make(map[string][]byte, len(keys))
+	for id, key := range keys {
+		if len(id) == 0 || len(id) > 255 {
+			return nil, fmt.Errorf("key ID %q must be 1 to 255 bytes", id)
+		}
+		if _, err := aes.NewCipher(key); err != nil {
+			return nil, fmt.Errorf("key %s: %v", id, err)
+		}
+		copied[id] = append([]byte(nil), key...)
+	}
+	return &StaticKeys{current: current, keys: copied}, nil
+}
+
+// EncryptionKey returns the current key
+func (k *StaticKeys) EncryptionKey() (string, []byte, error) {
+	return k.current, k.keys[k.current], nil
+}
+
+// DecryptionKey returns the key with an ID
+func (k *StaticKeys) DecryptionKey(id string) ([]byte, error) {
+	key, exists := k.keys[id]
+	if !exists {
+		return nil, fmt.Errorf("key %s not found", id)
+	}
+	return key, nil
+}
+
+// IsEncrypted reports whether data starts like an encrypted stream
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(magic))
+}
+
+// Encrypt seals data with the provider's current key
+func Encrypt(data []byte, keys KeyProvider) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, keys)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decrypt opens data sealed by Encrypt or a Writer
+func Decrypt(data []byte, keys KeyProvider) ([]byte, error) {
+	r, err := NewReader(bytes.NewReader(data), keys)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// Writer encrypts what is written to it. Close must be called to seal the
+// last chunk; it does not close the underlying writer.
+type Writer struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	header []byte
+	nonce  []byte
+	count  uint32
+	buf    []byte
+	closed bool
+}
+
+// NewWriter starts an encrypted stream on w, sealed with the provider's
+// current key
+func NewWriter(w io.Writer, keys KeyProvider) (*Writer, error) {
+	id, key, err := keys.EncryptionKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get encryption key: %v", err)
+	}
+	if len(id) == 0 || len(id) > 255 {
+		return nil, fmt.Errorf("key ID %q must be 1 to 255 bytes", id)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %v", id, err)
+	}
+
+	header := append([]byte(magic), version, byte(len(id)))
+	header = append(header, id...)
+	prefix := make([]byte, noncePrefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	header = append(header, prefix...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &Writer{
+		w:      w,
+		aead:   aead,
+		header: header,
+		nonce:  prefix,
+		buf:    make([]byte, 0, chunkSize),
+	}, nil
+}
+
+// Write encrypts p, sealing a chunk whenever one fills
+func (ew *Writer) Write(p []byte) (int, error) {
+	if ew.closed {
+		return 0, errors.New("write to closed encrypted stream")
+	}
+	written := 0
+	for len(p) > 0 {
+		n := copy(ew.buf[len(ew.buf):cap(ew.buf)], p)
+		ew.buf = ew.buf[:len(ew.buf)+n]
+		p = p[n:]
+		written += n
+		// Hold a full chunk back until more follows, so the last chunk is never empty unless the stream is
+		if len(ew.buf) == cap(ew.buf) && len(p) > 0 {
+			if err := ew.seal(flagMore); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Close seals the last chunk
+func (ew *Writer) Close() error {
+	if ew.closed {
+		return nil
+	}
+	ew.closed = true
+	return ew.seal(flagLast)
+}
+
+// seal encrypts the buffered plaintext as the next chunk
+func (ew *Writer) seal(flag byte) error {
+	sealed := ew.aead.Seal(nil, chunkNonce(ew.nonce, ew.count, flag), ew.buf, ew.header)
+	ew.count++
+	ew.buf = ew.buf[:0]
+
+	var head [5]byte
+	head[0] = flag
+	binary.BigEndian.PutUint32(head[1:], uint32(len(sealed)))
+	if _, err := ew.w.Write(head[:]); err != nil {
+		return err
+	}
+	_, err := ew.w.Write(sealed)
+	return err
+}
+
+// Reader decrypts an encrypted stream
+type Reader struct {
+	r      io.Reader
+	aead   cipher.AEAD
+	header []byte
+	nonce  []byte
+	count  uint32
+	buf    []byte
+	done   bool
+}
+
+// NewReader reads the header of an encrypted stream and looks up its key
+func NewReader(r io.Reader, keys KeyProvider) (*Reader, error) {
+	fixed := make([]byte, len(magic)+2)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, fmt.Errorf("failed to read encryption header: %v", err)
+	}
+	if !IsEncrypted(fixed) {
+		return nil, errors.New("data is not encrypted")
+	}
+	if fixed[len(magic)] != version {
+		return nil, fmt.Errorf("unsupported encryption format version %d", fixed[len(magic)])
+	}
+	rest := make([]byte, int(fixed[len(magic)+1])+noncePrefixSize)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return nil, fmt.Errorf("failed to read encryption header: %v", err)
+	}
+	id := string(rest[:len(rest)-noncePrefixSize])
+
+	key, err := keys.DecryptionKey(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get decryption key: %v", err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %v", id, err)
+	}
+	return &Reader{
+		r:      r,
+		aead:   aead,
+		header: append(fixed, rest...),
+		nonce:  rest[len(rest)-noncePrefixSize:],
+	}, nil
+}
+
+// Read decrypts into p, opening chunks as needed
+func (er *Reader) Read(p []byte) (int, error) {
+	for len(er.buf) == 0 {
+		if er.done {
+			return 0, io.EOF
+		}
+		if err := er.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, er.buf)
+	er.buf = er.buf[n:]
+	return n, nil
+}
+
+// open decrypts the next chunk
+func (er *Reader) open() error {
+	var head [5]byte
+	if _, err := io.ReadFull(er.r, head[:]); err != nil {
+		if err == io.EOF {
+			return errors.New("encrypted stream is truncated")
+		}
+		return fmt.Errorf("failed to read encrypted chunk: %v", err)
+	}
+	flag, size := head[0], binary.BigEndian.Uint32(head[1:])
+	if flag != flagMore && flag != flagLast {
+		return fmt.Errorf("invalid encrypted chunk flag %d", flag)
+	}
+	if size > uint32(chunkSize+er.aead.Overhead()) {
+		return fmt.Errorf("encrypted chunk of %d bytes is too large", size)
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(er.r, sealed); err != nil {
+		return errors.New("encrypted stream is truncated")
+	}
+
+	plain, err := er.aead.Open(sealed[:0], chunkNonce(er.nonce, er.count, flag), sealed, er.header)
+	if err != nil {
+		return fmt.Errorf("encrypted chunk %d failed authentication", er.count)
+	}
+	er.count++
+	er.buf = plain
+	er.done = flag == flagLast
+	return nil
+}
+
+// newAEAD creates an AES-GCM cipher for a key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce builds a chunk's nonce from the stream's random prefix, the
+// chunk counter, and the last-chunk flag
+func chunkNonce(prefix []byte, count uint32, flag byte) []byte {
+	nonce := make([]byte, 0, noncePrefixSize+5)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, count)
+	return append(nonce, flag)
+}
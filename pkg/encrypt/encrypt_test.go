@@ -0,0 +1,105 @@
+package encrypt
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func testKeys(t *testing.T, current string) *StaticKeys {
+	t.Helper()
+	keys, err := NewStaticKeys(current, map[string][]byte{
+		"2025-q4": bytes.Repeat([]byte{1}, 32),
+		"2026-q1": bytes.Repeat([]byte{2}, 32),
+	})
+	if err != nil {
+		t.Fatalf("NewStaticKeys failed: %v", err)
+	}
+	return keys
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	defer func(size int) { chunkSize = size }(chunkSize)
+	chunkSize = 16
+
+	keys := testKeys(t, "2026-q1")
+	for _, size := range []int{0, 1, 16, 17, 100} {
+		plain := []byte(strings.Repeat("casualty count 7;", 10))[:size]
+		sealed, err := Encrypt(plain, keys)
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		if !IsEncrypted(sealed) || bytes.Contains(sealed, []byte("casualty")) {
+			t.Errorf("Expected %d bytes to be sealed", size)
+		}
+		opened, err := Decrypt(sealed, keys)
+		if err != nil {
+			t.Fatalf("Decrypt of %d bytes failed: %v", size, err)
+		}
+		if !bytes.Equal(opened, plain) {
+			t.Errorf("Expected %q, got %q", plain, opened)
+		}
+	}
+}
+
+func TestEncryptStreamWrites(t *testing.T) {
+	defer func(size int) { chunkSize = size }(chunkSize)
+	chunkSize = 8
+
+	keys := testKeys(t, "2026-q1")
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, keys)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	var expected bytes.Buffer
+	for _, line := range []string{"DEF_ENTITY #E1001 [Bridge]\n", "ASSERT #F1001\n", "x"} {
+		w.Write([]byte(line))
+		expected.WriteString(line)
+	}
+	w.Close()
+
+	r, err := NewReader(&buf, keys)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(got, expected.Bytes()) {
+		t.Errorf("Expected %q, got %q (%v)", expected.Bytes(), got, err)
+	}
+}
+
+func TestDecryptRejectsTampering(t *testing.T) {
+	defer func(size int) { chunkSize = size }(chunkSize)
+	chunkSize = 16
+
+	sealed, _ := Encrypt([]byte(strings.Repeat("weak point ", 5)), testKeys(t, "2025-q4"))
+
+	// Data sealed with an older key stays readable after rotation
+	if _, err := Decrypt(sealed, testKeys(t, "2026-q1")); err != nil {
+		t.Errorf("Expected rotated keys to decrypt older data, got %v", err)
+	}
+	other, _ := NewStaticKeys("2026-q1", map[string][]byte{"2026-q1": bytes.Repeat([]byte{2}, 32)})
+	if _, err := Decrypt(sealed, other); err == nil {
+		t.Error("Expected error without the key")
+	}
+
+	flipped := append([]byte(nil), sealed...)
+	flipped[len(flipped)-1] ^= 1
+	if _, err := Decrypt(flipped, testKeys(t, "2026-q1")); err == nil {
+		t.Error("Expected error decrypting tampered data")
+	}
+	// Dropping the last chunk must not pass for a shorter stream
+	headerSize := len(magic) + 2 + len("2025-q4") + noncePrefixSize
+	firstChunk := 5 + chunkSize + 16
+	if _, err := Decrypt(sealed[:headerSize+firstChunk], testKeys(t, "2026-q1")); err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Errorf("Expected truncation to be detected, got %v", err)
+	}
+	if _, err := Decrypt([]byte("DEF_ENTITY #E1001"), testKeys(t, "2026-q1")); err == nil {
+		t.Error("Expected error decrypting plain text")
+	}
+	if _, err := NewStaticKeys("2026-q1", map[string][]byte{"2026-q1": []byte("short")}); err == nil {
+		t.Error("Expected error for an invalid key size")
+	}
+}
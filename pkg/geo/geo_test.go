@@ -0,0 +1,101 @@
+package geo
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+)
+
+const testRegions = `{
+  "type": "FeatureCollection",
+  "features": [
+    {"type": "Feature", "id": "country", "properties": {"name": "Country", "population": 5000000},
+     "geometry": {"type": "Polygon", "coordinates": [[[0, 0], [10, 0], [10, 10], [0, 10], [0, 0]]]}},
+    {"type": "Feature", "id": "province", "properties": {"name": "Province", "tosid": "10C1OR-GOV-USA"},
+     "geometry": {"type": "Polygon", "coordinates": [
+       [[1, 1], [6, 1], [6, 6], [1, 6], [1, 1]],
+       [[4, 4], [5, 4], [5, 5], [4, 5], [4, 4]]
+     ]}},
+    {"type": "Feature", "properties": {"name": "Shelter A"},
+     "geometry": {"type": "Point", "coordinates": [2, 2, 120]}},
+    {"type": "Feature", "properties": {"name": "Shelter B"},
+     "geometry": {"type": "Point", "coordinates": [4.5, 4.5]}},
+    {"type": "Feature", "properties": {"name": "Offshore"},
+     "geometry": {"type": "Point", "coordinates": [20, 20]}}
+  ]
+}`
+
+func TestImport(t *testing.T) {
+	store := semantic.NewSemanticStore()
+	result, err := Import(store, strings.NewReader(testRegions), Options{TOSID: "10B3TR-AIR-JET", TOSIDProperty: "tosid"})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	want := []string{"Ecountry", "Eprovince", "E0003", "E0004", "E0005"}
+	if strings.Join(result.EntityIDs, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected entity IDs %v, got %v", want, result.EntityIDs)
+	}
+	if result.Assertions != 3 {
+		t.Errorf("Expected 3 LOCATED_IN assertions, got %d", result.Assertions)
+	}
+
+	parents := make(map[string]string)
+	for _, assertion := range store.FindAssertionsByRelation(result.RelationID) {
+		parents[assertion.Subject()] = assertion.Object()
+	}
+	// Shelter B sits in the province's hole, so it is only in the country
+	for child, parent := range map[string]string{"Eprovince": "Ecountry", "E0003": "Eprovince", "E0004": "Ecountry"} {
+		if parents[child] != parent {
+			t.Errorf("Expected %s LOCATED_IN %s, got %q", child, parent, parents[child])
+		}
+	}
+	if _, located := parents["E0005"]; located {
+		t.Error("Offshore point should not be located in any region")
+	}
+
+	province, _ := store.GetEntity("Eprovince")
+	if !province.TOSIDObj.MatchesPattern("10C-1OR") {
+		t.Errorf("Expected per-feature TOSID, got %v", province.TOSIDObj)
+	}
+	for key, want := range map[string]string{
+		BBoxProperty:         "1,1,6,6",
+		LatitudeProperty:     "3.5",
+		GeometryTypeProperty: "Polygon",
+		"name":               "Province",
+	} {
+		if got, _ := province.KMACEntity.GetProperty(key); got != want {
+			t.Errorf("Expected %s=%q, got %q", key, want, got)
+		}
+	}
+	country, _ := store.GetEntity("Ecountry")
+	if population, _ := country.KMACEntity.GetProperty("population"); population != "5000000" {
+		t.Errorf("Expected population property, got %q", population)
+	}
+	shelter, _ := store.GetEntity("E0003")
+	if geometry, _ := shelter.KMACEntity.GetProperty(GeometryProperty); geometry != `{"type":"Point","coordinates":[2,2,120]}` {
+		t.Errorf("Unexpected geometry property: %s", geometry)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ImportContext(ctx, semantic.NewSemanticStore(), strings.NewReader(testRegions), Options{}); err != context.Canceled {
+		t.Errorf("Expected context.Canceled from a cancelled import, got %v", err)
+	}
+}
+
+func TestParseFeaturesErrors(t *testing.T) {
+	for _, bad := range []string{
+		`{"type": "Topology"}`,
+		`{"type": "Feature", "geometry": null}`,
+		`{"type": "Feature", "geometry": {"type": "Circle", "coordinates": [0, 0]}}`,
+		`{"type": "Feature", "geometry": {"type": "Polygon", "coordinates": [[[0, 0], [1, 1]]]}}`,
+		`{"type": "Feature", "geometry": {"type": "Point", "coordinates": [1]}}`,
+	} {
+		if _, err := ParseFeatures([]byte(bad)); err == nil {
+			t.Errorf("Expected error for %s", bad)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+// Package geo imports GeoJSON features into a semantic store as location
+// entities. Each entity carries its geometry, bounding box, and centre as
+// properties, and regions are linked to the smallest region containing them
+// by LOCATED_IN assertions.
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Geometry is a GeoJSON geometry. Coordinates are [longitude, latitude].
+type Geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+
+	polygons [][][][2]float64 // Rings of each polygon; the first ring is the outer boundary
+	points   [][2]float64     // Every position, for point and line geometries
+}
+
+// Feature is a GeoJSON feature
+type Feature struct {
+	ID         interface{}            `json:"id,omitempty"`
+	Geometry   *Geometry              `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// featureCollection is a GeoJSON feature collection
+type featureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// ParseFeatures decodes a GeoJSON feature collection or a single feature
+func ParseFeatures(data []byte) ([]Feature, error) {
+	var collection featureCollection
+	if err := json.Unmarshal(data, &collection); err != nil {
+		return nil, fmt.Errorf("failed to decode GeoJSON: %v", err)
+	}
+
+	var features []Feature
+	switch collection.Type {
+	case "FeatureCollection":
+		features = collection.Features
+	case "Feature":
+		var feature Feature
+		if err := json.Unmarshal(data, &feature); err != nil {
+			return nil, fmt.Errorf("failed to decode GeoJSON feature: %v", err)
+		}
+		features = []Feature{feature}
+	default:
+		return nil, fmt.Errorf("unsupported GeoJSON type %q", collection.Type)
+	}
+
+	for i := range features {
+		if features[i].Geometry == nil {
+			return nil, fmt.Errorf("feature %d has no geometry", i+1)
+		}
+		if err := features[i].Geometry.decode(); err != nil {
+			return nil, fmt.Errorf("feature %d: %v", i+1, err)
+		}
+	}
+	return features, nil
+}
+
+// decode reads the coordinates of a geometry
+func (g *Geometry) decode() error {
+	var err error
+	switch g.Type {
+	case "Point":
+		var point [2]float64
+		err = decodePosition(g.Coordinates, &point)
+		g.points = [][2]float64{point}
+	case "MultiPoint", "LineString":
+		err = json.Unmarshal(g.Coordinates, &g.points)
+	case "MultiLineString":
+		var lines [][][2]float64
+		err = json.Unmarshal(g.Coordinates, &lines)
+		for _, line := range lines {
+			g.points = append(g.points, line...)
+		}
+	case "Polygon":
+		var polygon [][][2]float64
+		err = json.Unmarshal(g.Coordinates, &polygon)
+		g.polygons = [][][][2]float64{polygon}
+	case "MultiPolygon":
+		err = json.Unmarshal(g.Coordinates, &g.polygons)
+	default:
+		return fmt.Errorf("unsupported geometry type %q", g.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s coordinates: %v", g.Type, err)
+	}
+
+	for _, polygon := range g.polygons {
+		if len(polygon) == 0 || len(polygon[0]) < 4 {
+			return errors.New("polygon rings need at least four positions")
+		}
+		for _, ring := range polygon {
+			g.points = append(g.points, ring...)
+		}
+	}
+	if len(g.points) == 0 {
+		return errors.New("geometry has no positions")
+	}
+	return nil
+}
+
+// decodePosition reads a position, ignoring any altitude
+func decodePosition(data json.RawMessage, point *[2]float64) error {
+	var position []float64
+	if err := json.Unmarshal(data, &position); err != nil {
+		return err
+	}
+	if len(position) < 2 {
+		return errors.New("position needs a longitude and latitude")
+	}
+	point[0], point[1] = position[0], position[1]
+	return nil
+}
+
+// IsArea reports whether the geometry encloses an area
+func (g *Geometry) IsArea() bool {
+	return len(g.polygons) > 0
+}
+
+// BBox returns the bounding box as minimum longitude, minimum latitude,
+// maximum longitude, and maximum latitude
+func (g *Geometry) BBox() [4]float64 {
+	box := [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for _, p := range g.points {
+		box[0] = math.Min(box[0], p[0])
+		box[1] = math.Min(box[1], p[1])
+		box[2] = math.Max(box[2], p[0])
+		box[3] = math.Max(box[3], p[1])
+	}
+	return box
+}
+
+// Centre returns the position of a point, or the centre of the bounding box
+// of any other geometry
+func (g *Geometry) Centre() [2]float64 {
+	if g.Type == "Point" {
+		return g.points[0]
+	}
+	box := g.BBox()
+	return [2]float64{(box[0] + box[2]) / 2, (box[1] + box[3]) / 2}
+}
+
+// Area returns the planar area of the geometry in square degrees, less holes
+func (g *Geometry) Area() float64 {
+	area := 0.0
+	for _, polygon := range g.polygons {
+		for i, ring := range polygon {
+			if i == 0 {
+				area += math.Abs(ringArea(ring))
+			} else {
+				area -= math.Abs(ringArea(ring))
+			}
+		}
+	}
+	return area
+}
+
+// Contains reports whether a position lies inside the geometry's area
+func (g *Geometry) Contains(p [2]float64) bool {
+	for _, polygon := range g.polygons {
+		if !inRing(p, polygon[0]) {
+			continue
+		}
+		inHole := false
+		for _, hole := range polygon[1:] {
+			if inRing(p, hole) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// Within reports whether every position of the geometry lies inside another
+// geometry's area
+func (g *Geometry) Within(other *Geometry) bool {
+	if !other.IsArea() {
+		return false
+	}
+	for _, p := range g.points {
+		if !other.Contains(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// ringArea returns the signed area of a ring by the shoelace formula
+func ringArea(ring [][2]float64) float64 {
+	sum := 0.0
+	for i := 0; i < len(ring)-1; i++ {
+		sum += ring[i][0]*ring[i+1][1] - ring[i+1][0]*ring[i][1]
+	}
+	return sum / 2
+}
+
+// inRing tests a position against a ring by ray casting. Positions on the
+// boundary count as inside.
+func inRing(p [2]float64, ring [][2]float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if onSegment(p, a, b) {
+			return true
+		}
+		if (a[1] > p[1]) != (b[1] > p[1]) && p[0] < (b[0]-a[0])*(p[1]-a[1])/(b[1]-a[1])+a[0] {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// onSegment reports whether a position lies on the segment from a to b
+func onSegment(p, a, b [2]float64) bool {
+	cross := (b[0]-a[0])*(p[1]-a[1]) - (b[1]-a[1])*(p[0]-a[0])
+	if math.Abs(cross) > 1e-12 {
+		return false
+	}
+	return p[0] >= math.Min(a[0], b[0]) && p[0] <= math.Max(a[0], b[0]) &&
+		p[1] >= math.Min(a[1], b[1]) && p[1] <= math.Max(a[1], b[1])
+}
+
+// formatFloat renders a coordinate without trailing zeros
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,246 @@
+package geo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+)
+
+// Entity properties written by the importer
+const (
+	GeometryProperty     = "geometry"      // The GeoJSON geometry object
+	GeometryTypeProperty = "geometry_type" // Point, Polygon, and so on
+	BBoxProperty         = "bbox"          // min_lon,min_lat,max_lon,max_lat
+	LatitudeProperty     = "latitude"      // Latitude of the centre
+	LongitudeProperty    = "longitude"     // Longitude of the centre
+)
+
+// LocatedIn is the label of the part-of relation between locations
+const LocatedIn = "LOCATED_IN"
+
+// Options configures an import
+type Options struct {
+	TOSID         string // TOSID code of imported locations
+	TOSIDProperty string // Feature property overriding TOSID per feature, if any
+	LabelProperty string // Feature property holding the label; defaults to name
+	RelationID    string // ID of the LOCATED_IN relation; generated when empty
+}
+
+// Result summarizes an import
+type Result struct {
+	EntityIDs  []string // Entity IDs in feature order
+	Assertions int      // LOCATED_IN assertions added
+	RelationID string
+}
+
+// Import reads GeoJSON and adds its features to a store
+func Import(store *semantic.SemanticStore, r io.Reader, opts Options) (*Result, error) {
+	return ImportContext(context.Background(), store, r, opts)
+}
+
+// ImportContext reads GeoJSON and adds its features to a store, stopping
+// early if ctx is done
+func ImportContext(ctx context.Context, store *semantic.SemanticStore, r io.Reader, opts Options) (*Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GeoJSON: %v", err)
+	}
+	features, err := ParseFeatures(data)
+	if err != nil {
+		return nil, err
+	}
+	return ImportFeaturesContext(ctx, store, features, opts)
+}
+
+// ImportFeatures adds features to a store as location entities. Each feature
+// is placed LOCATED_IN the smallest area feature that wholly contains it.
+func ImportFeatures(store *semantic.SemanticStore, features []Feature, opts Options) (*Result, error) {
+	return ImportFeaturesContext(context.Background(), store, features, opts)
+}
+
+// ImportFeaturesContext adds features to a store as location entities,
+// stopping early if ctx is done. What was added before it stopped stays in
+// the store.
+func ImportFeaturesContext(ctx context.Context, store *semantic.SemanticStore, features []Feature, opts Options) (*Result, error) {
+	if opts.LabelProperty == "" {
+		opts.LabelProperty = "name"
+	}
+	taken := make(map[string]bool)
+	store.RangeEntities(func(entityRef *semantic.EntityReference) bool {
+		taken[entityRef.KMACEntity.ID()] = true
+		return true
+	})
+
+	result := &Result{}
+	for i, feature := range features {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if feature.Geometry.polygons == nil && feature.Geometry.points == nil {
+			if err := feature.Geometry.decode(); err != nil {
+				return nil, fmt.Errorf("feature %d: %v", i+1, err)
+			}
+		}
+		entityID := newEntityID(feature, i, taken)
+		if err := addLocation(store, entityID, feature, opts); err != nil {
+			return nil, fmt.Errorf("feature %d: %v", i+1, err)
+		}
+		result.EntityIDs = append(result.EntityIDs, entityID)
+	}
+
+	relationID := ""
+	for i := range features {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		parent := smallestContainer(features, i)
+		if parent < 0 {
+			continue
+		}
+		if relationID == "" {
+			var err error
+			if relationID, err = locatedInRelation(store, opts.RelationID); err != nil {
+				return nil, err
+			}
+			result.RelationID = relationID
+		}
+		assertionID := newAssertionID(store)
+		if err := store.CreateAssertion(assertionID, result.EntityIDs[i], relationID, result.EntityIDs[parent]); err != nil {
+			return nil, fmt.Errorf("feature %d: %v", i+1, err)
+		}
+		result.Assertions++
+	}
+	return result, nil
+}
+
+// addLocation adds one feature as an entity with its geometry and scalar
+// feature properties
+func addLocation(store *semantic.SemanticStore, entityID string, feature Feature, opts Options) error {
+	label := entityID
+	if value, ok := feature.Properties[opts.LabelProperty].(string); ok && value != "" {
+		label = value
+	}
+	tosidCode := opts.TOSID
+	if opts.TOSIDProperty != "" {
+		if value, ok := feature.Properties[opts.TOSIDProperty].(string); ok && value != "" {
+			tosidCode = value
+		}
+	}
+	if err := store.AddEntity(entityID, label, tosidCode); err != nil {
+		return err
+	}
+
+	entity, _ := store.GetEntity(entityID)
+	for key, value := range feature.Properties {
+		switch value := value.(type) {
+		case string:
+			entity.KMACEntity.SetProperty(key, value)
+		case float64:
+			entity.KMACEntity.SetProperty(key, formatFloat(value))
+		case bool:
+			entity.KMACEntity.SetProperty(key, fmt.Sprint(value))
+		}
+	}
+
+	geometry := feature.Geometry
+	box := geometry.BBox()
+	centre := geometry.Centre()
+	var coordinates bytes.Buffer
+	if err := json.Compact(&coordinates, geometry.Coordinates); err != nil {
+		return err
+	}
+	entity.KMACEntity.SetProperty(GeometryProperty, fmt.Sprintf(`{"type":%q,"coordinates":%s}`, geometry.Type, coordinates.String()))
+	entity.KMACEntity.SetProperty(GeometryTypeProperty, geometry.Type)
+	entity.KMACEntity.SetProperty(BBoxProperty, strings.Join([]string{
+		formatFloat(box[0]), formatFloat(box[1]), formatFloat(box[2]), formatFloat(box[3]),
+	}, ","))
+	entity.KMACEntity.SetProperty(LongitudeProperty, formatFloat(centre[0]))
+	entity.KMACEntity.SetProperty(LatitudeProperty, formatFloat(centre[1]))
+	return nil
+}
+
+// smallestContainer returns the index of the smallest area feature that
+// contains feature i, or -1
+func smallestContainer(features []Feature, i int) int {
+	geometry := features[i].Geometry
+	area := geometry.Area()
+	best, bestArea := -1, 0.0
+	for j, other := range features {
+		if j == i || !other.Geometry.IsArea() {
+			continue
+		}
+		otherArea := other.Geometry.Area()
+		// Identical regions would contain each other; only the larger, or
+		// the earlier of two equal ones, is the parent
+		if otherArea < area || (otherArea == area && j > i) {
+			continue
+		}
+		if !geometry.Within(other.Geometry) {
+			continue
+		}
+		if best < 0 || otherArea < bestArea {
+			best, bestArea = j, otherArea
+		}
+	}
+	return best
+}
+
+// locatedInRelation returns the LOCATED_IN relation, adding it if needed
+func locatedInRelation(store *semantic.SemanticStore, relationID string) (string, error) {
+	if relationID != "" {
+		if _, err := store.GetRelation(relationID); err == nil {
+			return relationID, nil
+		}
+	} else {
+		for n := 1; ; n++ {
+			relationID = fmt.Sprintf("R%04d", n)
+			if _, err := store.GetRelation(relationID); err != nil {
+				break
+			}
+		}
+	}
+	if err := store.AddRelation(relationID, LocatedIn, "SPATIAL_RELATIONSHIP"); err != nil {
+		return "", err
+	}
+	return relationID, nil
+}
+
+// newEntityID derives an unused entity ID from a feature's id member, or
+// numbers the feature when it has none
+func newEntityID(feature Feature, index int, taken map[string]bool) string {
+	base := fmt.Sprintf("E%04d", index+1)
+	if feature.ID != nil {
+		var sb strings.Builder
+		sb.WriteString("E")
+		for _, r := range fmt.Sprint(feature.ID) {
+			if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+				sb.WriteRune(r)
+			}
+		}
+		if sb.Len() > 1 {
+			base = sb.String()
+		}
+	}
+
+	entityID := base
+	for n := 2; taken[entityID]; n++ {
+		entityID = fmt.Sprintf("%s_%d", base, n)
+	}
+	taken[entityID] = true
+	return entityID
+}
+
+// newAssertionID returns the first numbered assertion ID not in the store
+func newAssertionID(store *semantic.SemanticStore) string {
+	for n := 1; ; n++ {
+		assertionID := fmt.Sprintf("F%04d", n)
+		if _, err := store.GetAssertion(assertionID); err != nil {
+			return assertionID
+		}
+	}
+}
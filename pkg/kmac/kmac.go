@@ -9,24 +9,143 @@ type Statement = internal_kmac.Statement
 type Entity = internal_kmac.Entity
 type Relation = internal_kmac.Relation
 type Assertion = internal_kmac.Assertion
+type NaryAssertion = internal_kmac.NaryAssertion
+type Argument = internal_kmac.Argument
 type Property = internal_kmac.Property
 type Event = internal_kmac.Event
 type TimeReference = internal_kmac.TimeReference
 type Temporal = internal_kmac.Temporal
 type PartOf = internal_kmac.PartOf
 type Causation = internal_kmac.Causation
+type StatementCollection = internal_kmac.StatementCollection
+type KMACBuilder = internal_kmac.KMACBuilder
+type ULIDGenerator = internal_kmac.ULIDGenerator
+type Disassembler = internal_kmac.Disassembler
+type StatementSource = internal_kmac.StatementSource
+type WalkFunc = internal_kmac.WalkFunc
+type TextSerializer = internal_kmac.TextSerializer
+type Participation = internal_kmac.Participation
+type StateAssertion = internal_kmac.StateAssertion
+type StateHistory = internal_kmac.StateHistory
+type Plan = internal_kmac.Plan
+type Task = internal_kmac.Task
+type Dependency = internal_kmac.Dependency
+type Situation = internal_kmac.Situation
+type SituationMember = internal_kmac.SituationMember
+type Evidence = internal_kmac.Evidence
+type CrossReference = internal_kmac.CrossReference
+type DependencyGraph = internal_kmac.DependencyGraph
+type ConfidenceSource = internal_kmac.ConfidenceSource
+type SourceRegistry = internal_kmac.SourceRegistry
+type Schedule = internal_kmac.Schedule
+type ScheduledTask = internal_kmac.ScheduledTask
+type Labeler = internal_kmac.Labeler
+type Recurrence = internal_kmac.Recurrence
+type TimeWindow = internal_kmac.TimeWindow
+type ApproximateTime = internal_kmac.ApproximateTime
+type Precision = internal_kmac.Precision
+type Comparison = internal_kmac.Comparison
+type Assembler = internal_kmac.Assembler
+type Record = internal_kmac.Record
+type TextLine = internal_kmac.TextLine
+type TextField = internal_kmac.TextField
+type LineDecoder = internal_kmac.LineDecoder
+type RecordConfidence = internal_kmac.RecordConfidence
+type Theme = internal_kmac.Theme
+type ConfidenceBand = internal_kmac.ConfidenceBand
+type ConfidenceReport = internal_kmac.ConfidenceReport
+type ConfidenceEntry = internal_kmac.ConfidenceEntry
+type SourceConfidence = internal_kmac.SourceConfidence
+type Compression = internal_kmac.Compression
+type Codec = internal_kmac.Codec
+type Metadata = internal_kmac.Metadata
+type Annotated = internal_kmac.Annotated
+type MergeResult = internal_kmac.MergeResult
+type MergeConflict = internal_kmac.MergeConflict
 
 // Re-export constructor functions
 var (
-	NewEntity        = internal_kmac.NewEntity
-	NewRelation      = internal_kmac.NewRelation
-	NewAssertion     = internal_kmac.NewAssertion
-	NewProperty      = internal_kmac.NewProperty
-	NewEvent         = internal_kmac.NewEvent
-	NewTimeReference = internal_kmac.NewTimeReference
-	NewTemporal      = internal_kmac.NewTemporal
-	NewPartOf        = internal_kmac.NewPartOf
-	NewCausation     = internal_kmac.NewCausation
+	NewEntity                = internal_kmac.NewEntity
+	NewRelation              = internal_kmac.NewRelation
+	NewAssertion             = internal_kmac.NewAssertion
+	NewNaryAssertion         = internal_kmac.NewNaryAssertion
+	NewProperty              = internal_kmac.NewProperty
+	NewEvent                 = internal_kmac.NewEvent
+	NewTimeReference         = internal_kmac.NewTimeReference
+	NewTemporal              = internal_kmac.NewTemporal
+	NewPartOf                = internal_kmac.NewPartOf
+	NewCausation             = internal_kmac.NewCausation
+	NewStatementCollection   = internal_kmac.NewStatementCollection
+	NewKMACBuilder           = internal_kmac.NewKMACBuilder
+	ContentAssertionID       = internal_kmac.ContentAssertionID
+	NewULIDGenerator         = internal_kmac.NewULIDGenerator
+	IsULIDIdentifier         = internal_kmac.IsULIDIdentifier
+	ULIDTime                 = internal_kmac.ULIDTime
+	NewDisassembler          = internal_kmac.NewDisassembler
+	NewDisassemblerFromStore = internal_kmac.NewDisassemblerFromStore
+	Walk                     = internal_kmac.Walk
+	Chain                    = internal_kmac.Chain
+	RenameRelation           = internal_kmac.RenameRelation
+	RemapTOSIDs              = internal_kmac.RemapTOSIDs
+	NormalizeLabels          = internal_kmac.NormalizeLabels
+	NewTextSerializer        = internal_kmac.NewTextSerializer
+	RegisterCodec            = internal_kmac.RegisterCodec
+	NewCompressWriter        = internal_kmac.NewCompressWriter
+	NewDecompressReader      = internal_kmac.NewDecompressReader
+	NewAssembler             = internal_kmac.NewAssembler
+	RecordsFor               = internal_kmac.RecordsFor
+	ColorSupported           = internal_kmac.ColorSupported
+	LookupTheme              = internal_kmac.LookupTheme
+	NewParticipation         = internal_kmac.NewParticipation
+	NewStateAssertion        = internal_kmac.NewStateAssertion
+	NewStateHistory          = internal_kmac.NewStateHistory
+	NewPlan                  = internal_kmac.NewPlan
+	NewTask                  = internal_kmac.NewTask
+	NewDependency            = internal_kmac.NewDependency
+	NewSituation             = internal_kmac.NewSituation
+	NewSituationMember       = internal_kmac.NewSituationMember
+	NewEvidence              = internal_kmac.NewEvidence
+	ValidateKMACStatement    = internal_kmac.ValidateKMACStatement
+	ParseTextLine            = internal_kmac.ParseTextLine
+	NewSourceRegistry        = internal_kmac.NewSourceRegistry
+	DefaultSources           = internal_kmac.DefaultSources
+	TopologicalOrder         = internal_kmac.TopologicalOrder
+	ScheduleTasks            = internal_kmac.ScheduleTasks
+	IsAssertionReference     = internal_kmac.IsAssertionReference
+	IsBuiltInRole            = internal_kmac.IsBuiltInRole
+	BuiltInRoles             = internal_kmac.BuiltInRoles
+	AssertionLabeler         = internal_kmac.AssertionLabeler
+	NewTimeInterval          = internal_kmac.NewTimeInterval
+	ParseRecurrence          = internal_kmac.ParseRecurrence
+	ParseApproximateTime     = internal_kmac.ParseApproximateTime
+	NewApproximateTime       = internal_kmac.NewApproximateTime
+	Exact                    = internal_kmac.Exact
+	Merge                    = internal_kmac.Merge
+	Canonical                = internal_kmac.Canonical
+	FormatCanonical          = internal_kmac.FormatCanonical
+
+	NewApproximateTimeReference = internal_kmac.NewApproximateTimeReference
+	NewCompressedTextSerializer = internal_kmac.NewCompressedTextSerializer
+	NewMissionElapsedTime       = internal_kmac.NewMissionElapsedTime
+
+	TAIMinusUTC        = internal_kmac.TAIMinusUTC
+	ToAtomic           = internal_kmac.ToAtomic
+	FromAtomic         = internal_kmac.FromAtomic
+	JulianDate         = internal_kmac.JulianDate
+	FromJulianDate     = internal_kmac.FromJulianDate
+	JulianCalendarDate = internal_kmac.JulianCalendarDate
+	FromJulianCalendar = internal_kmac.FromJulianCalendar
+	FormatMET          = internal_kmac.FormatMET
+	ParseMET           = internal_kmac.ParseMET
+	FormatInScale      = internal_kmac.FormatInScale
+	ParseInScale       = internal_kmac.ParseInScale
+)
+
+// Built-in disassembler themes
+var (
+	DefaultTheme    = internal_kmac.DefaultTheme
+	DomainTheme     = internal_kmac.DomainTheme
+	ConfidenceTheme = internal_kmac.ConfidenceTheme
 )
 
 // Re-export constants
@@ -37,4 +156,64 @@ const (
 	PropertyIDPrefix  = internal_kmac.PropertyIDPrefix
 	TimeIDPrefix      = internal_kmac.TimeIDPrefix
 	AssertionIDPrefix = internal_kmac.AssertionIDPrefix
-)
\ No newline at end of file
+	PlanIDPrefix      = internal_kmac.PlanIDPrefix
+	TaskIDPrefix      = internal_kmac.TaskIDPrefix
+	SituationIDPrefix = internal_kmac.SituationIDPrefix
+	EvidenceIDPrefix  = internal_kmac.EvidenceIDPrefix
+	DurationProperty  = internal_kmac.DurationProperty
+	ULIDSeparator     = internal_kmac.ULIDSeparator
+
+	UnknownSourceWeight = internal_kmac.UnknownSourceWeight
+
+	CompressionNone = internal_kmac.CompressionNone
+	CompressionGzip = internal_kmac.CompressionGzip
+	CompressionZstd = internal_kmac.CompressionZstd
+
+	ConflictOursMarker   = internal_kmac.ConflictOursMarker
+	ConflictSeparator    = internal_kmac.ConflictSeparator
+	ConflictTheirsMarker = internal_kmac.ConflictTheirsMarker
+
+	RoleAgent       = internal_kmac.RoleAgent
+	RolePatient     = internal_kmac.RolePatient
+	RoleInstrument  = internal_kmac.RoleInstrument
+	RoleLocation    = internal_kmac.RoleLocation
+	RoleBeneficiary = internal_kmac.RoleBeneficiary
+
+	Hourly  = internal_kmac.Hourly
+	Daily   = internal_kmac.Daily
+	Weekly  = internal_kmac.Weekly
+	Monthly = internal_kmac.Monthly
+	Yearly  = internal_kmac.Yearly
+
+	PrecisionInstant = internal_kmac.PrecisionInstant
+	PrecisionDay     = internal_kmac.PrecisionDay
+	PrecisionMonth   = internal_kmac.PrecisionMonth
+	PrecisionYear    = internal_kmac.PrecisionYear
+	PrecisionDecade  = internal_kmac.PrecisionDecade
+	PrecisionCentury = internal_kmac.PrecisionCentury
+	BoundBefore      = internal_kmac.BoundBefore
+	BoundAfter       = internal_kmac.BoundAfter
+
+	Never      = internal_kmac.Never
+	Possibly   = internal_kmac.Possibly
+	Definitely = internal_kmac.Definitely
+
+	ColorReset   = internal_kmac.ColorReset
+	ColorBold    = internal_kmac.ColorBold
+	ColorRed     = internal_kmac.ColorRed
+	ColorGreen   = internal_kmac.ColorGreen
+	ColorYellow  = internal_kmac.ColorYellow
+	ColorBlue    = internal_kmac.ColorBlue
+	ColorMagenta = internal_kmac.ColorMagenta
+	ColorCyan    = internal_kmac.ColorCyan
+	ColorGray    = internal_kmac.ColorGray
+
+	ScaleUTC    = internal_kmac.ScaleUTC
+	ScaleTAI    = internal_kmac.ScaleTAI
+	ScaleTT     = internal_kmac.ScaleTT
+	ScaleGPS    = internal_kmac.ScaleGPS
+	ScaleMET    = internal_kmac.ScaleMET
+	ScaleJulian = internal_kmac.ScaleJulian
+	ScaleJD     = internal_kmac.ScaleJD
+	ScaleMJD    = internal_kmac.ScaleMJD
+)
@@ -1,9 +1,316 @@
 package kmac
 
 import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
+var update = flag.Bool("update", false, "update golden files")
+
+// checkGolden compares output against a golden file in testdata, rewriting it when -update is set
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("Failed to update golden file %s: %v", path, err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read golden file %s: %v", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Output does not match %s\n--- got ---\n%s\n--- want ---\n%s", path, got, want)
+	}
+}
+
+// buildSolarSystem builds a small statement set with several part-of links and properties
+func buildSolarSystem(t *testing.T) []Statement {
+	t.Helper()
+	var statements []Statement
+	add := func(stmt Statement, err error) {
+		if err != nil {
+			t.Fatalf("Failed to build statement: %v", err)
+		}
+		statements = append(statements, stmt)
+	}
+
+	sun, err := NewEntity("E1001", "Sun", "00B2SO-LAR-SUN")
+	add(sun, err)
+	earth, err := NewEntity("E1002", "Earth", "00B3SO-LAR-ERT")
+	add(earth, err)
+	earth.SetProperty("radius_km", "6371")
+	earth.SetProperty("mass_kg", "5.972e24")
+	earth.SetProperty("atmosphere", "nitrogen-oxygen")
+	add(NewEntity("E1003", "Moon", "00B3SO-LAR-MON"))
+	add(NewEntity("E1004", "Mars", "00B3SO-LAR-MRS"))
+	add(NewEntity("E1005", "Solar System", "00B1SO-LAR-SYS"))
+	add(NewRelation("R1001", "orbits", "SPATIAL"))
+	add(NewAssertion("F1001", "E1002", "R1001", "E1001"))
+	add(NewAssertion("F1002", "E1003", "R1001", "E1002"))
+	add(NewAssertion("F1003", "E1004", "R1001", "E1001"))
+	add(NewPartOf("E1004", "E1005"))
+	add(NewPartOf("E1002", "E1005"))
+	add(NewPartOf("E1001", "E1005"))
+	add(NewPartOf("E1003", "E1005"))
+	return statements
+}
+
+func TestDisassemblyIsDeterministic(t *testing.T) {
+	statements := buildSolarSystem(t)
+
+	var first []byte
+	for i := 0; i < 5; i++ {
+		var buf bytes.Buffer
+		d := NewDisassembler(&buf)
+		d.RegisterStatements(statements)
+		d.DisassembleAll()
+		d.DisassembleEntityHierarchy("E1005")
+
+		if i == 0 {
+			first = buf.Bytes()
+			continue
+		}
+		if !bytes.Equal(first, buf.Bytes()) {
+			t.Fatalf("Disassembly output changed between runs")
+		}
+	}
+	checkGolden(t, "disassembly.golden", first)
+}
+
+func TestExportIsDeterministic(t *testing.T) {
+	collection := NewStatementCollection()
+	for _, stmt := range buildSolarSystem(t) {
+		if entity, ok := stmt.(*Entity); ok {
+			collection.Add(entity)
+		}
+	}
+
+	earth, _ := collection.Get("E1002")
+	lines := collection.ExportToStrings()
+	lines = append(lines, earth.(*Entity).PropertiesString())
+	checkGolden(t, "export.golden", []byte(strings.Join(lines, "\n")+"\n"))
+}
+
+func TestDisassembleAssertionAboutAssertion(t *testing.T) {
+	statements := buildSolarSystem(t)
+	society, _ := NewEntity("E3001", "Flat Earth Society", "")
+	disputed, _ := NewRelation("R3001", "DISPUTED_BY", "EPISTEMIC")
+	dispute, err := NewAssertion("F3001", "F1001", "R3001", "E3001")
+	if err != nil {
+		t.Fatalf("Failed to create assertion about an assertion: %v", err)
+	}
+	statements = append(statements, society, disputed, dispute)
+
+	collection := NewStatementCollection()
+	for _, stmt := range statements {
+		collection.Add(stmt)
+	}
+	if warnings := collection.Validate(); len(warnings) != 0 {
+		t.Errorf("Expected no warnings, got %v", warnings)
+	}
+
+	var buf bytes.Buffer
+	d := NewDisassembler(&buf)
+	d.RegisterStatements(statements)
+	d.DisassembleAssertion("F3001")
+	d.DisassembleAssertion("F1001")
+
+	output := buf.String()
+	for _, want := range []string{
+		"SUBJECT: #F1001 [Earth] [orbits] [Sun] (Assertion)",
+		"STATEMENTS ABOUT THIS ASSERTION:",
+		"#F3001 [#F1001] [DISPUTED_BY] [Flat Earth Society]",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected disassembly to contain %q, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestEventParticipants(t *testing.T) {
+	apollo, _ := NewEntity("E1002", "APOLLO_11", "")
+	surface, _ := NewEntity("E1003", "LUNAR_SURFACE", "")
+	landing, _ := NewEvent("V1001", "LANDING", "")
+	statements := []Statement{apollo, surface, landing}
+	for _, p := range []struct{ role, entity string }{
+		{RoleAgent, "E1002"},
+		{RoleLocation, "E1003"},
+		{"PILOTED_BY", "E1002"},
+	} {
+		participation, err := NewParticipation("V1001", p.role, p.entity)
+		if err != nil {
+			t.Fatalf("Failed to create participation: %v", err)
+		}
+		statements = append(statements, participation)
+	}
+	if _, err := NewParticipation("E1002", RoleAgent, "E1003"); err == nil {
+		t.Error("Expected error for participation in a non-event")
+	}
+
+	collection := NewStatementCollection()
+	for _, stmt := range statements {
+		collection.Add(stmt)
+	}
+	if warnings := collection.Validate(); len(warnings) != 0 {
+		t.Errorf("Expected no warnings, got %v", warnings)
+	}
+	if participants := collection.FindParticipants("V1001", ""); len(participants) != 3 {
+		t.Errorf("Expected 3 participants, got %d", len(participants))
+	}
+	agents := collection.FindParticipants("V1001", RoleAgent)
+	if len(agents) != 1 || agents[0].EntityID() != "E1002" {
+		t.Errorf("Expected E1002 as the only agent, got %v", agents)
+	}
+	if participations := collection.FindParticipations("E1002", ""); len(participations) != 2 {
+		t.Errorf("Expected E1002 in 2 roles, got %d", len(participations))
+	}
+	if participations := collection.FindParticipations("E1003", RoleAgent); len(participations) != 0 {
+		t.Errorf("Expected E1003 to have no agent roles, got %d", len(participations))
+	}
+
+	var buf bytes.Buffer
+	d := NewDisassembler(&buf)
+	d.RegisterStatements(statements)
+	d.DisassembleEvent("V1001")
+	d.DisassembleEntity("E1002")
+
+	output := buf.String()
+	for _, want := range []string{
+		"    AGENT: #E1002 [APOLLO_11]\n    LOCATION: #E1003 [LUNAR_SURFACE]\n    PILOTED_BY: #E1002 [APOLLO_11]\n",
+		"PARTICIPATES IN:\n    #V1001 [LANDING] as AGENT\n    #V1001 [LANDING] as PILOTED_BY\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected disassembly to contain %q, got:\n%s", want, output)
+		}
+	}
+
+	assertTextRoundTrip(t, statements)
+}
+
+func TestDisassembleStateHistory(t *testing.T) {
+	highway, _ := NewEntity("E3001", "Highway_Status", "")
+	t1 := time.Date(2025, 5, 19, 8, 0, 0, 0, time.UTC)
+	later, _ := NewStateAssertion("F3002", "E3001", "passable", "60%", t1.Add(6*time.Hour))
+	earlier, _ := NewStateAssertion("F3001", "E3001", "passable", "30%", t1)
+	statements := []Statement{highway, later, earlier}
+
+	var buf bytes.Buffer
+	d := NewDisassembler(&buf)
+	d.RegisterStatements(statements)
+	d.DisassembleEntity("E3001")
+
+	want := "  STATE HISTORY:\n    passable: 30% at 2025-05-19T08:00:00Z (#F3001)\n    passable: 60% at 2025-05-19T14:00:00Z (#F3002)\n"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("Expected disassembly to contain %q, got:\n%s", want, buf.String())
+	}
+
+	assertTextRoundTrip(t, statements)
+}
+
+func TestSchedulePlan(t *testing.T) {
+	plan, _ := NewPlan("L1001", "Response")
+	statements := []Statement{plan}
+	for _, tk := range []struct {
+		id       string
+		duration time.Duration
+	}{
+		{"K1001", 15 * time.Minute},
+		{"K1002", 45 * time.Minute},
+		{"K1003", time.Hour},
+		{"K1004", 2 * time.Hour},
+		{"K1005", 30 * time.Minute},
+	} {
+		task, err := NewTask(tk.id, "Task "+tk.id, "L1001")
+		if err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+		task.SetDuration(tk.duration)
+		statements = append(statements, task)
+	}
+	// K1001 -> K1002 -> K1005 and K1003 -> K1004 -> K1005
+	for _, dp := range [][2]string{{"K1002", "K1001"}, {"K1004", "K1003"}, {"K1005", "K1002"}, {"K1005", "K1004"}} {
+		dependency, err := NewDependency(dp[0], dp[1])
+		if err != nil {
+			t.Fatalf("Failed to create dependency: %v", err)
+		}
+		statements = append(statements, dependency)
+	}
+
+	collection := NewStatementCollection()
+	for _, stmt := range statements {
+		if err := collection.Add(stmt); err != nil {
+			t.Fatalf("Failed to add statement: %v", err)
+		}
+	}
+	if warnings := collection.Validate(); len(warnings) != 0 {
+		t.Errorf("Expected no warnings, got %v", warnings)
+	}
+
+	order, err := collection.PlanOrder("L1001")
+	if err != nil {
+		t.Fatalf("PlanOrder failed: %v", err)
+	}
+	var ids []string
+	for _, task := range order {
+		ids = append(ids, task.ID())
+	}
+	if got := strings.Join(ids, ","); got != "K1001,K1002,K1003,K1004,K1005" {
+		t.Errorf("Unexpected order: %s", got)
+	}
+
+	schedule, err := collection.SchedulePlan("L1001")
+	if err != nil {
+		t.Fatalf("SchedulePlan failed: %v", err)
+	}
+	if schedule.Duration != 3*time.Hour+30*time.Minute {
+		t.Errorf("Expected duration 3h30m, got %s", schedule.Duration)
+	}
+	var path []string
+	for _, task := range schedule.CriticalPath {
+		path = append(path, task.ID())
+	}
+	if got := strings.Join(path, ","); got != "K1003,K1004,K1005" {
+		t.Errorf("Unexpected critical path: %s", got)
+	}
+
+	var buf bytes.Buffer
+	d := NewDisassembler(&buf)
+	d.RegisterStatements(statements)
+	d.DisassemblePlan("L1001")
+	for _, want := range []string{
+		"#K1005 [Task K1005] start=3h0m0s finish=3h30m0s\n      after #K1002 [Task K1002]\n      after #K1004 [Task K1004]\n",
+		"CRITICAL PATH (3h30m0s):\n    #K1003 [Task K1003]\n    #K1004 [Task K1004]\n    #K1005 [Task K1005]\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected disassembly to contain %q, got:\n%s", want, buf.String())
+		}
+	}
+
+	assertTextRoundTrip(t, statements)
+
+	// A cycle makes the plan unschedulable
+	cycle, _ := NewDependency("K1001", "K1005")
+	collection.Add(cycle)
+	if _, err := collection.SchedulePlan("L1001"); err == nil {
+		t.Error("Expected error for cyclic dependencies")
+	}
+	if _, err := collection.SchedulePlan("L9999"); err == nil {
+		t.Error("Expected error for unknown plan")
+	}
+}
+
 func TestEntityCreation(t *testing.T) {
 	entity, err := NewEntity("E1001", "Test Entity", "00B2-SOL-STR-SUN:000-000-000-001")
 	if err != nil {
@@ -83,6 +390,252 @@ func TestConfidenceLevels(t *testing.T) {
 	}
 }
 
+// randomStatements builds a random but internally consistent set of statements
+func randomStatements(rng *rand.Rand, n int) []Statement {
+	labels := []string{"Sun", "Earth", "Kepler-186f", "Label with [brackets]", "back\\slash", "multi\nline", ""}
+	states := []string{"POINT_IN_TIME", "BEGAN_AT", "ENDED_AT", "DURING"}
+	causations := []string{"ENABLEMENT", "PREVENTION", "TRIGGERING"}
+
+	var statements []Statement
+	for i := 1; i <= n; i++ {
+		entity, _ := NewEntity(fmt.Sprintf("E%d", i), labels[rng.Intn(len(labels))], fmt.Sprintf("00B%dSO-LAR-X%02d", rng.Intn(10), i%100))
+		if rng.Intn(2) == 0 {
+			entity.SetProperty("mass", fmt.Sprintf("%d", rng.Intn(1000)))
+		}
+		statements = append(statements, entity)
+	}
+
+	relation, _ := NewRelation("R1", "orbits", "SPATIAL")
+	relation.SetDomain("00B*")
+	relation.SetProperty("transitive", "false")
+	statements = append(statements, relation)
+
+	event, _ := NewEvent("V1", "Launch", "11B3-EVT-HST-FST")
+	statements = append(statements, event)
+
+	timeRef, _ := NewTimeReference("T1", "ABSOLUTE", time.Date(1969, 7, 20, 20, 17, 40, 123, time.UTC))
+	statements = append(statements, timeRef)
+
+	window, _ := NewTimeReference("T2", "RESUPPLY_WINDOW", time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC))
+	window.SetDuration(2 * time.Hour)
+	recurrence, _ := ParseRecurrence("FREQ=WEEKLY;BYDAY=MO,TH;UNTIL=20241231T000000Z")
+	window.SetRecurrence(recurrence)
+	interval, _ := NewTimeInterval("T3", "MISSION", time.Date(1969, 7, 16, 13, 32, 0, 0, time.UTC), time.Date(1969, 7, 24, 16, 50, 35, 0, time.UTC))
+	circa, _ := ParseApproximateTime("circa 1960s")
+	approximate, _ := NewApproximateTimeReference("T4", "HISTORICAL", circa)
+	landing, _ := NewMissionElapsedTime("T5", "LANDING", time.Date(1969, 7, 16, 13, 32, 0, 0, time.UTC), 102*time.Hour+45*time.Minute+40*time.Second)
+	statements = append(statements, window, interval, approximate, landing)
+
+	for i := 1; i <= n; i++ {
+		assertion, _ := NewAssertion(fmt.Sprintf("F%d", i), fmt.Sprintf("E%d", rng.Intn(n)+1), "R1", fmt.Sprintf("E%d", rng.Intn(n)+1))
+		if rng.Intn(2) == 0 {
+			assertion.SetConfidence(rng.Float64(), "SOURCE_"+labels[rng.Intn(len(labels))])
+		}
+		assertion.SetNegated(rng.Intn(4) == 0)
+		statements = append(statements, assertion)
+
+		if rng.Intn(3) == 0 {
+			temporal, _ := NewTemporal(assertion.ID(), states[rng.Intn(len(states))], "#T1")
+			if rng.Intn(2) == 0 {
+				start := time.Unix(rng.Int63n(1e9), 0).UTC()
+				temporal.SetTimeRange(start, start.Add(time.Hour))
+			}
+			statements = append(statements, temporal)
+		}
+	}
+
+	for i := 2; i <= n; i++ {
+		partOf, _ := NewPartOf(fmt.Sprintf("E%d", i), "E1")
+		statements = append(statements, partOf)
+	}
+	for i := 1; i <= n; i++ {
+		if rng.Intn(3) == 0 {
+			at := time.Unix(rng.Int63n(1e9), rng.Int63n(1e9)).UTC()
+			state, _ := NewStateAssertion(fmt.Sprintf("F%d", n+i), fmt.Sprintf("E%d", i), "status", labels[rng.Intn(len(labels))], at)
+			statements = append(statements, state)
+		}
+		if rng.Intn(3) == 0 {
+			participation, _ := NewParticipation("V1", BuiltInRoles[rng.Intn(len(BuiltInRoles))], fmt.Sprintf("E%d", i))
+			statements = append(statements, participation)
+		}
+	}
+	if n >= 2 {
+		causation, _ := NewCausation("F1", "F2", causations[rng.Intn(len(causations))])
+		statements = append(statements, causation)
+	}
+
+	return statements
+}
+
+// assertTextRoundTrip serializes statements, parses them back, and checks that nothing changed
+func assertTextRoundTrip(t *testing.T, statements []Statement) {
+	t.Helper()
+	serializer := NewTextSerializer()
+
+	first, err := serializer.Serialize(statements)
+	if err != nil {
+		t.Fatalf("Failed to serialize: %v", err)
+	}
+	parsed, err := serializer.Deserialize(first)
+	if err != nil {
+		t.Fatalf("Failed to deserialize: %v\n%s", err, first)
+	}
+	if len(parsed) != len(statements) {
+		t.Fatalf("Expected %d statements after round trip, got %d", len(statements), len(parsed))
+	}
+	for i := range statements {
+		if parsed[i].String() != statements[i].String() {
+			t.Errorf("Statement %d changed: %q became %q", i, statements[i].String(), parsed[i].String())
+		}
+	}
+
+	second, err := serializer.Serialize(parsed)
+	if err != nil {
+		t.Fatalf("Failed to re-serialize: %v", err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Errorf("Serialized form changed after round trip\n--- first ---\n%s\n--- second ---\n%s", first, second)
+	}
+}
+
+func TestTextSerializerRoundTrip(t *testing.T) {
+	for seed := int64(1); seed <= 20; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		assertTextRoundTrip(t, randomStatements(rng, 1+rng.Intn(20)))
+	}
+}
+
+func TestTextSerializerParsesDocumentedSyntax(t *testing.T) {
+	input := `# Apollo 11
+DEF_ENTITY #E1001 [NASA] type=[10C1-ORG-GOV-USA:NASA]
+DEF_RELATION #R1001 [OPERATES] type=[AGENT_OPERATION]
+ASSERT #F1001 subject=[#E1001] relation=[#R1001] object=[#E1002]
+CONFIDENCE #F1001 level=[0.9999] source=[HISTORICAL_RECORD]
+TEMPORAL #F1001 state=[POINT_IN_TIME] timestamp=[#T1001]
+`
+	statements, err := NewTextSerializer().DeserializeFromString(input)
+	if err != nil {
+		t.Fatalf("Failed to parse documented syntax: %v", err)
+	}
+	if len(statements) != 4 {
+		t.Fatalf("Expected 4 statements, got %d", len(statements))
+	}
+	assertion, ok := statements[2].(*Assertion)
+	if !ok {
+		t.Fatalf("Expected an assertion, got %T", statements[2])
+	}
+	if level, source := assertion.GetConfidence(); level != 0.9999 || source != "HISTORICAL_RECORD" {
+		t.Errorf("Expected confidence 0.9999 from HISTORICAL_RECORD, got %f from %s", level, source)
+	}
+}
+
+func TestTextSerializerCompression(t *testing.T) {
+	statements := randomStatements(rand.New(rand.NewSource(1)), 50)
+	plain, _ := NewTextSerializer().Serialize(statements)
+
+	compressed, err := NewCompressedTextSerializer(CompressionGzip).Serialize(statements)
+	if err != nil {
+		t.Fatalf("Failed to serialize with gzip: %v", err)
+	}
+	if len(compressed) >= len(plain) || !bytes.HasPrefix(compressed, []byte{0x1f, 0x8b}) {
+		t.Errorf("Expected a smaller gzip stream, got %d bytes from %d", len(compressed), len(plain))
+	}
+	parsed, err := NewTextSerializer().Deserialize(compressed)
+	if err != nil {
+		t.Fatalf("Failed to read the gzip stream back: %v", err)
+	}
+	if len(parsed) != len(statements) {
+		t.Errorf("Expected %d statements from the gzip stream, got %d", len(statements), len(parsed))
+	}
+
+	if _, err := NewCompressedTextSerializer(CompressionZstd).Serialize(statements); err == nil {
+		t.Error("Expected zstd to need a registered codec")
+	}
+	if _, err := NewTextSerializer().Deserialize([]byte{0x28, 0xb5, 0x2f, 0xfd, 0}); err == nil || !strings.Contains(err.Error(), "zstd") {
+		t.Errorf("Expected a zstd stream to be recognized and refused, got %v", err)
+	}
+	if err := RegisterCodec(CompressionNone, Codec{}); err == nil {
+		t.Error("Expected a codec for no compression to be rejected")
+	}
+}
+
+func FuzzTextSerializerRoundTrip(f *testing.F) {
+	f.Add("Sun", "00B2SO-LAR-SUN", "mass", "1.989e30", 0.95, "OBSERVATION")
+	f.Add("with ] bracket", "", "key\\", "line\nbreak", 0.0, "")
+	f.Fuzz(func(t *testing.T, label, tosidType, key, value string, confidence float64, source string) {
+		if strings.ContainsAny(label+tosidType+key+value+source, "\x00") || math.IsNaN(confidence) {
+			t.Skip()
+		}
+		entity, err := NewEntity("E1", label, tosidType)
+		if err != nil {
+			t.Skip()
+		}
+		entity.SetProperty(key, value)
+		assertion, _ := NewAssertion("F1", "E1", "R1", "E1")
+		assertion.SetConfidence(confidence, source)
+		assertTextRoundTrip(t, []Statement{entity, assertion})
+	})
+}
+
+func TestAssertionDescribe(t *testing.T) {
+	collection := NewStatementCollection()
+	nasa, _ := NewEntity("E1001", "NASA", "10C1-ORG-GOV-USA:NASA")
+	apollo, _ := NewEntity("E1002", "Apollo_11", "10B2-SPC-CRF-APO:011-000-000-000")
+	operates, _ := NewRelation("R1001", "OPERATES", "ORGANIZATIONAL")
+	collection.Add(nasa)
+	collection.Add(apollo)
+	collection.Add(operates)
+
+	assertion, _ := NewAssertion("F1001", "E1001", "R1001", "E1002")
+	if got := assertion.Describe(collection.Labeler()); got != "NASA operates Apollo 11" {
+		t.Errorf("Unexpected description: %s", got)
+	}
+	if got := assertion.Describe(nil); got != "E1001 r1001 E1002" {
+		t.Errorf("Unexpected description without labels: %s", got)
+	}
+
+	assertion.SetConfidence(0.9999, "HISTORICAL_RECORD")
+	collection.Add(assertion)
+	if got := assertion.Describe(collection.Labeler()); got != "NASA operates Apollo 11 (confidence 0.9999, historical record)" {
+		t.Errorf("Unexpected description with confidence: %s", got)
+	}
+
+	assertion.SetNegated(true)
+	if got := assertion.Describe(collection.Labeler()); !strings.HasPrefix(got, "It is not the case that NASA operates Apollo 11") {
+		t.Errorf("Unexpected negated description: %s", got)
+	}
+	assertion.SetNegated(false)
+
+	reported, _ := NewAssertion("F1002", "E1001", "REPORTED", "F1001")
+	if got := reported.Describe(collection.Labeler()); got != "NASA reported the statement that NASA operates Apollo 11" {
+		t.Errorf("Unexpected description of a statement about a statement: %s", got)
+	}
+
+	var buf bytes.Buffer
+	disassembler := NewDisassembler(&buf)
+	disassembler.SetColorEnabled(false)
+	disassembler.RegisterStatements(collection.GetAll())
+	disassembler.DisassembleAssertion("F1001")
+	if !strings.Contains(buf.String(), "DESCRIPTION: NASA operates Apollo 11 (confidence 0.9999, historical record)") {
+		t.Errorf("Expected disassembly to describe the assertion, got:\n%s", buf.String())
+	}
+}
+
+func FuzzTextSerializerDecode(f *testing.F) {
+	f.Add("DEF_ENTITY #E1001 [NASA] type=[10C1-ORG-GOV-USA:NASA]\nPROPERTY #E1001 [founded] value=[1958]")
+	f.Add("ASSERT #F1 subject=[#E1] relation=[#R1] object=[#E2]\nCONFIDENCE #F1 level=[0.5] source=[X]")
+	f.Add("PART_OF #E2 whole=[#E1]\nCAUSATION source=[#F1] target=[#F2] type=[TRIGGERING]")
+	f.Fuzz(func(t *testing.T, input string) {
+		serializer := NewTextSerializer()
+		statements, err := serializer.DeserializeFromString(input)
+		if err != nil {
+			return
+		}
+		// Anything that parses must survive a further round trip unchanged
+		assertTextRoundTrip(t, statements)
+	})
+}
+
 func BenchmarkEntityCreation(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		_, err := NewEntity("E1001", "Test Entity", "00B2-SOL-STR-SUN:000-000-000-001")
@@ -99,4 +652,1140 @@ func BenchmarkAssertionCreation(b *testing.B) {
 			b.Fatal(err)
 		}
 	}
-}
\ No newline at end of file
+}
+
+// BenchmarkTextSerializerCompression measures the throughput of writing and
+// reading KMAC text, by uncompressed size, and reports the compressed size
+// as a share of it
+func BenchmarkTextSerializerCompression(b *testing.B) {
+	statements := randomStatements(rand.New(rand.NewSource(1)), 2000)
+	plain, _ := NewTextSerializer().Serialize(statements)
+	for _, compression := range []Compression{CompressionNone, CompressionGzip} {
+		name := string(compression)
+		if name == "" {
+			name = "none"
+		}
+		serializer := NewCompressedTextSerializer(compression)
+		encoded, err := serializer.Serialize(statements)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(name+"/encode", func(b *testing.B) {
+			b.SetBytes(int64(len(plain)))
+			b.ReportMetric(float64(len(encoded))/float64(len(plain)), "ratio")
+			for i := 0; i < b.N; i++ {
+				if _, err := serializer.Serialize(statements); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(name+"/decode", func(b *testing.B) {
+			b.SetBytes(int64(len(plain)))
+			for i := 0; i < b.N; i++ {
+				if _, err := serializer.Deserialize(encoded); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestRecurrence(t *testing.T) {
+	monday := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		rule string
+		want []string
+	}{
+		{"FREQ=WEEKLY;BYDAY=MO", []string{"2024-01-01T08:00", "2024-01-08T08:00", "2024-01-15T08:00"}},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE", []string{"2024-01-01T08:00", "2024-01-03T08:00", "2024-01-15T08:00", "2024-01-17T08:00"}},
+		{"FREQ=DAILY;COUNT=3;BYHOUR=8,20", []string{"2024-01-01T08:00", "2024-01-01T20:00", "2024-01-02T08:00"}},
+		{"FREQ=MONTHLY;BYMONTHDAY=-12,5", []string{"2024-01-05T08:00", "2024-01-20T08:00"}},
+		{"FREQ=HOURLY;INTERVAL=6;UNTIL=20240101T200000Z", []string{"2024-01-01T08:00", "2024-01-01T14:00", "2024-01-01T20:00"}},
+	}
+	for _, tc := range tests {
+		recurrence, err := ParseRecurrence(tc.rule)
+		if err != nil {
+			t.Fatalf("ParseRecurrence(%q) failed: %v", tc.rule, err)
+		}
+		var got []string
+		for _, occurrence := range recurrence.Occurrences(monday, monday, monday.AddDate(0, 0, 20)) {
+			got = append(got, occurrence.Format("2006-01-02T15:04"))
+		}
+		if strings.Join(got, " ") != strings.Join(tc.want, " ") {
+			t.Errorf("%s: expected %v, got %v", tc.rule, tc.want, got)
+		}
+		reparsed, err := ParseRecurrence(recurrence.String())
+		if err != nil || reparsed.String() != recurrence.String() {
+			t.Errorf("%s: String() %q did not round trip", tc.rule, recurrence.String())
+		}
+	}
+
+	for _, bad := range []string{"BYDAY=MO", "FREQ=SECONDLY", "FREQ=DAILY;BYHOUR=24", "FREQ=DAILY;COUNT=2;UNTIL=20240101", "FREQ=WEEKLY;BYDAY=1MO"} {
+		if _, err := ParseRecurrence(bad); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
+
+func TestTimeReferenceWindows(t *testing.T) {
+	input := `DEF_TIME #T2001 type=[RESUPPLY_WINDOW] value=[2024-01-01T08:00:00Z] duration=[2h0m0s] rrule=[FREQ=WEEKLY;BYDAY=MO]
+DEF_TIME #T2002 type=[MISSION] value=[2024-01-01T00:00:00Z] end=[2024-01-10T00:00:00Z]
+`
+	statements, err := NewTextSerializer().DeserializeFromString(input)
+	if err != nil {
+		t.Fatalf("Failed to parse time references: %v", err)
+	}
+	window, mission := statements[0].(*TimeReference), statements[1].(*TimeReference)
+
+	if !window.Contains(time.Date(2024, 1, 8, 9, 30, 0, 0, time.UTC)) {
+		t.Error("Expected Monday 09:30 to be in the resupply window")
+	}
+	if window.Contains(time.Date(2024, 1, 8, 10, 0, 0, 0, time.UTC)) || window.Contains(time.Date(2024, 1, 9, 9, 0, 0, 0, time.UTC)) {
+		t.Error("Expected window end and Tuesday to be outside the resupply window")
+	}
+	next, ok := window.NextWindow(time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC))
+	if !ok || !next.Start.Equal(time.Date(2024, 1, 8, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected next window on 8 January, got %v", next)
+	}
+	if windows := window.Windows(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC), time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)); len(windows) != 5 {
+		t.Errorf("Expected 5 windows in January, got %d", len(windows))
+	}
+
+	if mission.Duration() != 9*24*time.Hour || !mission.Contains(time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected interval: %s", mission)
+	}
+	if _, err := NewTimeInterval("T1", "BACKWARDS", time.Now(), time.Now().Add(-time.Hour)); err == nil {
+		t.Error("Expected error for interval ending before it starts")
+	}
+	if _, err := NewTextSerializer().DeserializeFromString("DEF_TIME #T1 type=[X] value=[2024-01-01T00:00:00Z] rrule=[FREQ=NEVER]\n"); err == nil {
+		t.Error("Expected error for invalid recurrence")
+	}
+}
+
+func TestApproximateTime(t *testing.T) {
+	for _, text := range []string{"1960s", "circa 1950", "before 1970", "after circa 1969-07", "4000 BCE", "19th century", "1960s BCE", "1st century BCE", "1969-07-20"} {
+		approx, err := ParseApproximateTime(text)
+		if err != nil {
+			t.Fatalf("ParseApproximateTime(%q) failed: %v", text, err)
+		}
+		if approx.String() != text {
+			t.Errorf("Expected %q to round trip, got %q", text, approx.String())
+		}
+	}
+	for _, bad := range []string{"", "circa", "0", "sometime", "0th century"} {
+		if _, err := ParseApproximateTime(bad); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+
+	parse := func(text string) *ApproximateTime {
+		approx, err := ParseApproximateTime(text)
+		if err != nil {
+			t.Fatalf("ParseApproximateTime(%q) failed: %v", text, err)
+		}
+		return approx
+	}
+	tests := []struct {
+		a, b string
+		want Comparison
+	}{
+		{"1960s", "1975", Definitely},
+		{"1960s", "1965", Possibly},
+		{"circa 1950", "1952", Possibly},
+		{"circa 1950", "1960", Definitely},
+		{"before 1970", "1969", Possibly},
+		{"before 1970", "1970", Definitely},
+		{"after 1970", "1960s", Never},
+		{"4000 BCE", "1st century BCE", Definitely},
+		{"19th century", "1900", Possibly},
+		{"19th century", "1901", Definitely},
+	}
+	for _, tc := range tests {
+		if got := parse(tc.a).Before(parse(tc.b)); got != tc.want {
+			t.Errorf("%s before %s: expected %s, got %s", tc.a, tc.b, tc.want, got)
+		}
+	}
+	if !parse("19th century").Contains(time.Date(1850, 6, 1, 0, 0, 0, 0, time.UTC)) || parse("19th century").Contains(time.Date(1901, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Expected the 19th century to run from 1801 to 1900")
+	}
+
+	statements, err := NewTextSerializer().DeserializeFromString("DEF_TIME #T1 type=[PREHISTORIC] value=[before 10000 BCE]\nDEF_TIME #T2 type=[DISCOVERY] value=[2014-04-17T00:00:00Z]\n")
+	if err != nil {
+		t.Fatalf("Failed to parse approximate time reference: %v", err)
+	}
+	prehistoric, discovery := statements[0].(*TimeReference), statements[1].(*TimeReference)
+	if approx, ok := prehistoric.Approximate(); !ok || approx.String() != "before 10000 BCE" {
+		t.Errorf("Expected approximate time, got %v", approx)
+	}
+	if prehistoric.Before(discovery) != Definitely || discovery.After(prehistoric) != Definitely {
+		t.Error("Expected prehistory to definitely precede 2014")
+	}
+	if _, ok := discovery.Approximate(); ok {
+		t.Error("Expected an exact time to have no approximate time")
+	}
+}
+
+func TestTimeScales(t *testing.T) {
+	utc := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	if TAIMinusUTC(utc) != 37*time.Second || TAIMinusUTC(time.Date(1980, 6, 1, 0, 0, 0, 0, time.UTC)) != 19*time.Second {
+		t.Errorf("Unexpected TAI - UTC: %v", TAIMinusUTC(utc))
+	}
+	for scale, offset := range map[string]time.Duration{ScaleTAI: 37 * time.Second, ScaleTT: 69184 * time.Millisecond, ScaleGPS: 18 * time.Second} {
+		reading, err := ToAtomic(utc, scale)
+		if err != nil || reading.Sub(utc) != offset {
+			t.Errorf("%s: expected offset %v, got %v (%v)", scale, offset, reading.Sub(utc), err)
+		}
+		back, err := FromAtomic(reading, scale)
+		if err != nil || !back.Equal(utc) {
+			t.Errorf("%s: expected %v back, got %v", scale, utc, back)
+		}
+	}
+
+	if jd := JulianDate(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC)); jd != 2451545 {
+		t.Errorf("Expected J2000 to be JD 2451545, got %v", jd)
+	}
+	if at := FromJulianDate(2440587.5); !at.Equal(time.Unix(0, 0)) {
+		t.Errorf("Expected JD 2440587.5 to be the Unix epoch, got %v", at)
+	}
+
+	// The Gregorian calendar began on 15 October 1582, the day after 4 October Julian
+	year, month, day := JulianCalendarDate(time.Date(1582, 10, 15, 0, 0, 0, 0, time.UTC))
+	if year != 1582 || month != time.October || day != 5 {
+		t.Errorf("Expected 1582-10-05 Julian, got %d-%d-%d", year, month, day)
+	}
+	if at := FromJulianCalendar(1700, time.February, 29); !at.Equal(time.Date(1700, 3, 11, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected Julian 1700-02-29 to be Gregorian 1700-03-11, got %v", at)
+	}
+
+	elapsed, err := ParseMET("T+004:06:45:40")
+	if err != nil || elapsed != 102*time.Hour+45*time.Minute+40*time.Second {
+		t.Errorf("Unexpected mission elapsed time %v (%v)", elapsed, err)
+	}
+	if text := FormatMET(-10*time.Second - 500*time.Millisecond); text != "T-000:00:00:10.5" {
+		t.Errorf("Unexpected countdown %q", text)
+	}
+
+	input := `DEF_TIME #T1 type=[LANDING] value=[T+004:06:45:40] scale=[MET] epoch=[1969-07-16T13:32:00Z]
+DEF_TIME #T2 type=[OBSERVATION] value=[2020-06-01T12:00:37] scale=[TAI]
+DEF_TIME #T3 type=[CORONATION] value=[1700-02-29] scale=[JULIAN]
+DEF_TIME #T4 type=[EPOCH] value=[2451545] scale=[JD]
+`
+	serializer := NewTextSerializer()
+	statements, err := serializer.DeserializeFromString(input)
+	if err != nil {
+		t.Fatalf("Failed to parse scaled time references: %v", err)
+	}
+	want := []time.Time{
+		time.Date(1969, 7, 20, 20, 17, 40, 0, time.UTC),
+		utc,
+		time.Date(1700, 3, 11, 0, 0, 0, 0, time.UTC),
+		time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+	for i, statement := range statements {
+		if value := statement.(*TimeReference).Value(); !value.Equal(want[i]) {
+			t.Errorf("%s: expected %v, got %v", statement.ID(), want[i], value)
+		}
+	}
+	output, err := serializer.SerializeToString(statements)
+	if err != nil || output != input {
+		t.Errorf("Expected scaled times to round trip, got:\n%s", output)
+	}
+
+	for _, bad := range []string{
+		"DEF_TIME #T1 type=[X] value=[T+001:00:00:00] scale=[MET]\n",
+		"DEF_TIME #T1 type=[X] value=[1701-02-29] scale=[JULIAN]\n",
+		"DEF_TIME #T1 type=[X] value=[2020-01-01] scale=[MARTIAN]\n",
+	} {
+		if _, err := serializer.DeserializeFromString(bad); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
+
+func TestAssemblerRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	for i := 0; i < 20; i++ {
+		statements := randomStatements(rng, 1+rng.Intn(20))
+		records, err := RecordsFor(statements)
+		if err != nil {
+			t.Fatalf("Failed to build records: %v", err)
+		}
+		data, err := json.Marshal(records)
+		if err != nil {
+			t.Fatalf("Failed to encode records: %v", err)
+		}
+		assembled, err := NewAssembler().AssembleJSON(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Failed to assemble: %v\n%s", err, data)
+		}
+		if len(assembled) != len(statements) {
+			t.Fatalf("Expected %d statements, got %d", len(statements), len(assembled))
+		}
+		for j := range statements {
+			if assembled[j].String() != statements[j].String() {
+				t.Errorf("Statement %d changed: %q became %q", j, statements[j].String(), assembled[j].String())
+			}
+		}
+	}
+}
+
+func TestDisassembleJSON(t *testing.T) {
+	var buf bytes.Buffer
+	disassembler := NewDisassembler(&buf)
+	statements := buildSolarSystem(t)
+	disassembler.RegisterStatements(statements)
+	if err := disassembler.DisassembleJSON(); err != nil {
+		t.Fatalf("Failed to disassemble: %v", err)
+	}
+
+	// Edit the disassembly and ingest it again
+	edited := strings.Replace(buf.String(), `"label": "Mars"`, `"label": "Red Planet"`, 1)
+	assembled, err := NewAssembler().AssembleJSON(strings.NewReader(edited))
+	if err != nil {
+		t.Fatalf("Failed to assemble: %v", err)
+	}
+	if len(assembled) != len(statements) {
+		t.Fatalf("Expected %d statements, got %d", len(statements), len(assembled))
+	}
+	for _, stmt := range assembled {
+		if entity, ok := stmt.(*Entity); ok && entity.ID() == "E1004" && entity.Label() != "Red Planet" {
+			t.Errorf("Expected edited label, got %q", entity.Label())
+		}
+		if entity, ok := stmt.(*Entity); ok && entity.ID() == "E1002" {
+			if radius, _ := entity.GetProperty("radius_km"); radius != "6371" {
+				t.Errorf("Expected Earth's properties to survive, got radius %q", radius)
+			}
+		}
+	}
+
+	if _, err := NewAssembler().Assemble([]Record{{Keyword: "DEF_ENTITY", ID: "X1", Label: "Bad"}}); err == nil {
+		t.Error("Expected error for invalid entity ID")
+	}
+	if _, err := NewAssembler().Assemble([]Record{{ID: "E1"}}); err == nil {
+		t.Error("Expected error for record without keyword")
+	}
+}
+
+func TestDisassemblerColor(t *testing.T) {
+	statements := buildSolarSystem(t)
+	low, _ := NewAssertion("F1004", "E1004", "R1001", "E1003")
+	low.SetConfidence(0.3, "RUMOUR")
+	statements = append(statements, low)
+
+	var plain bytes.Buffer
+	disassembler := NewDisassembler(&plain)
+	disassembler.RegisterStatements(statements)
+	disassembler.DisassembleAll()
+	if strings.Contains(plain.String(), "\x1b[") {
+		t.Error("Expected no color when writing to a buffer")
+	}
+
+	var colored bytes.Buffer
+	disassembler = NewDisassembler(&colored)
+	disassembler.RegisterStatements(statements)
+	disassembler.SetColorEnabled(true)
+	disassembler.SetTheme(ConfidenceTheme)
+	disassembler.DisassembleAssertion("F1004")
+	if !strings.Contains(colored.String(), ColorRed+"0.3000"+ColorReset) {
+		t.Errorf("Expected low confidence in red, got %q", colored.String())
+	}
+	if strings.Contains(colored.String(), ColorYellow) {
+		t.Error("Expected the confidence theme not to color domains")
+	}
+
+	colored.Reset()
+	theme, ok := LookupTheme("Domain")
+	if !ok {
+		t.Fatal("Expected the domain theme")
+	}
+	disassembler.SetTheme(theme)
+	disassembler.DisassembleEntity("E1002")
+	if !strings.Contains(colored.String(), ColorYellow+"Earth"+ColorReset) {
+		t.Errorf("Expected natural physical entity in yellow, got %q", colored.String())
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	if ColorSupported(os.Stdout) {
+		t.Error("Expected NO_COLOR to disable color")
+	}
+}
+
+func TestConfidenceReport(t *testing.T) {
+	statements := buildSolarSystem(t)
+	for i, level := range []float64{0.3, 0.95, 0.5} {
+		assertion := statements[6+i].(*Assertion)
+		source := "TELESCOPE"
+		if i == 1 {
+			source = "PROBE"
+		}
+		assertion.SetConfidence(level, source)
+	}
+
+	var buf bytes.Buffer
+	disassembler := NewDisassembler(&buf)
+	disassembler.RegisterStatements(statements)
+	report := disassembler.ConfidenceReport(0.7)
+
+	var order []string
+	for _, entry := range report.Entries {
+		order = append(order, entry.Assertion.ID())
+	}
+	if strings.Join(order, " ") != "F1001 F1003 F1002" {
+		t.Errorf("Expected assertions by ascending confidence, got %v", order)
+	}
+	if low := report.LowConfidence(); len(low) != 2 {
+		t.Errorf("Expected 2 low-confidence assertions, got %d", len(low))
+	}
+	if len(report.Sources) != 2 || report.Sources[0].Source != "TELESCOPE" || report.Sources[0].Low != 2 || math.Abs(report.Sources[0].Mean-0.4) > 1e-9 {
+		t.Errorf("Unexpected source summary: %+v", report.Sources)
+	}
+
+	disassembler.DisassembleConfidence(0.7)
+	output := buf.String()
+	for _, want := range []string{"2 of 3 assertions below", "0.3000 ███░░░░░░░ LOW", "0.9500 ██████████\n", "TELESCOPE"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in report:\n%s", want, output)
+		}
+	}
+}
+
+func TestCrossReference(t *testing.T) {
+	src := `DEF_ENTITY #E1001 [Armstrong] type=[]
+DEF_ENTITY #E1002 [Moon] type=[00B2-CEL-MON-SFC:000-000-000-001]
+DEF_EVENT #V1001 [Landing] type=[LANDING]
+DEF_EVENT #V1002 [Moonwalk] type=[EVA]
+DEF_RELATION #R1001 [WALKED_ON] type=[SPATIAL]
+ASSERT #F1001 subject=[#E1001] relation=[#R1001] object=[#E1002]
+PARTICIPANT #V1002 role=[AGENT] entity=[#E1001]
+CAUSATION source=[#V1001] target=[#V1002] type=[ENABLEMENT]
+`
+	statements, err := NewTextSerializer().Decode(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	d := NewDisassembler(&out)
+	d.RegisterStatements(statements)
+	index := d.CrossReferences()
+	if refs := index["E1001"]; len(refs) != 2 || refs[0].Role != "subject" || refs[1].Role != "agent" {
+		t.Errorf("Expected Armstrong referenced as subject and agent, got %+v", refs)
+	}
+
+	d.DisassembleCrossReference()
+	for _, want := range []string{
+		"#E1001 [Armstrong]\n  L9     ASSERT #F1001 (subject)\n  L11    PARTICIPANT #",
+		"#V1001 [Landing]\n  L13    CAUSATION #CAUS_V1001_V1002 (cause)\n",
+		"#V1002 [Moonwalk]\n  L11    PARTICIPANT #",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in index:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "Unknown statement type") {
+		t.Errorf("Expected causations registered, got:\n%s", out.String())
+	}
+}
+
+func TestEvidence(t *testing.T) {
+	src := `DEF_ENTITY #E1001 [Kepler-22b] type=[00B3-EXO-TE-P01:RAD-2.4E-M1]
+DEF_ENTITY #E1002 [Kepler-22] type=[00B2-SOL-STR-SGL:SPT-G5V-001]
+DEF_RELATION #R1001 [ORBITS] type=[CELESTIAL_MOTION]
+ASSERT #F1001 subject=[#E1001] relation=[#R1001] object=[#E1002]
+EVIDENCE #D1001 assertion=[#F1001] doi=[10.1088/0004-637X/745/2/120] excerpt=[a planet in the habitable zone of a Sun-like star]
+EVIDENCE #D1002 assertion=[#F1001] url=[https://exoplanetarchive.ipac.caltech.edu/] hash=[sha256:9f86d081884c7d65]
+`
+	statements, err := NewTextSerializer().Decode(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	paper, ok := statements[4].(*Evidence)
+	if !ok {
+		t.Fatalf("Expected evidence, got %T", statements[4])
+	}
+	if paper.AssertionID() != "F1001" || paper.Citation() != "doi:10.1088/0004-637X/745/2/120" {
+		t.Errorf("Unexpected evidence %v", paper)
+	}
+	for _, stmt := range statements {
+		if err := ValidateKMACStatement(stmt); err != nil {
+			t.Errorf("Expected %s valid, got %v", stmt.ID(), err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := NewTextSerializer().Encode(&buf, statements); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if buf.String() != src {
+		t.Errorf("Expected the text to round trip, got:\n%s", buf.String())
+	}
+
+	var out bytes.Buffer
+	d := NewDisassembler(&out)
+	d.RegisterStatements(statements)
+	d.DisassembleAssertion("F1001")
+	for _, want := range []string{
+		"  EVIDENCE:\n",
+		`    #D1001 doi:10.1088/0004-637X/745/2/120 "a planet in the habitable zone of a Sun-like star"`,
+		"    #D1002 https://exoplanetarchive.ipac.caltech.edu/ (sha256:9f86d081884c7d65)",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in disassembly:\n%s", want, out.String())
+		}
+	}
+
+	empty, _ := NewEvidence("D1003", "F1001")
+	if err := ValidateKMACStatement(empty); err == nil {
+		t.Error("Expected evidence without an artifact rejected")
+	}
+	empty.SetHash("9f86d081884c7d65")
+	if err := ValidateKMACStatement(empty); err == nil {
+		t.Error("Expected a hash without its algorithm rejected")
+	}
+	empty.SetDOI("doi:10.1000/182")
+	if empty.DOI() != "10.1000/182" {
+		t.Errorf("Expected the doi: prefix dropped, got %q", empty.DOI())
+	}
+}
+
+func TestConfidenceSources(t *testing.T) {
+	sources := DefaultSources()
+	if weight := sources.Weight("direct_observation"); weight != 1.0 {
+		t.Errorf("Expected DIRECT_OBSERVATION weighted 1.0 whatever the case, got %v", weight)
+	}
+	if weight := sources.Weight("rumour"); weight != UnknownSourceWeight {
+		t.Errorf("Expected an unknown source weighted %v, got %v", UnknownSourceWeight, weight)
+	}
+	if err := sources.Load(strings.NewReader(`{"survey": {"weight": 0.8, "description": "field survey"}}`)); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if source, ok := sources.Lookup("SURVEY"); !ok || source.Weight != 0.8 || source.Description != "field survey" {
+		t.Errorf("Expected the loaded source registered, got %+v", source)
+	}
+	if err := sources.Register("BROKEN", 1.5, ""); err == nil {
+		t.Error("Expected a weight over 1 rejected")
+	}
+	if first := sources.Sources()[0]; first.Weight != 1.0 {
+		t.Errorf("Expected the most reliable source first, got %+v", first)
+	}
+
+	observed, _ := NewAssertion("F1001", "E1001", "R1001", "E1002")
+	observed.SetConfidence(0.9, "DIRECT_OBSERVATION")
+	recorded, _ := NewAssertion("F1002", "E1001", "R1001", "E1002")
+	recorded.SetConfidence(0.8, "HISTORICAL_RECORD")
+	denied, _ := NewAssertion("F1003", "E1001", "R1001", "E1002")
+	denied.SetNegated(true)
+	denied.SetConfidence(0.5, "UNVERIFIED_REPORT")
+
+	if weighted := sources.Weighted(recorded); math.Abs(weighted-0.56) > 1e-9 {
+		t.Errorf("Expected 0.8 from a historical record weighted to 0.56, got %v", weighted)
+	}
+	// Support pools to 1 - 0.1*0.44 = 0.956, against is 0.2, and Dempster's
+	// rule gives 0.956*0.8 / (1 - 0.956*0.2)
+	level, err := sources.Combine([]*Assertion{observed, recorded, denied})
+	if err != nil {
+		t.Fatalf("Combine failed: %v", err)
+	}
+	if want := 0.956 * 0.8 / (1 - 0.956*0.2); math.Abs(level-want) > 1e-9 {
+		t.Errorf("Expected combined confidence %v, got %v", want, level)
+	}
+	other, _ := NewAssertion("F1004", "E1002", "R1001", "E1001")
+	if _, err := sources.Combine([]*Assertion{observed, other}); err == nil {
+		t.Error("Expected assertions of different claims rejected")
+	}
+
+	statements := buildSolarSystem(t)
+	statements[6].(*Assertion).SetConfidence(0.6, "MODEL_PREDICTION")
+	var buf bytes.Buffer
+	disassembler := NewDisassembler(&buf)
+	disassembler.RegisterStatements(statements)
+	report := disassembler.ConfidenceReport(0.7)
+	if entry := report.Entries[0]; math.Abs(entry.Weighted-0.36) > 1e-9 {
+		t.Errorf("Expected the prediction weighted to 0.36, got %+v", entry)
+	}
+	disassembler.DisassembleConfidence(0.7)
+	for _, want := range []string{"MODEL_PREDICTION  0.60 ", "(none)            0.50 (unregistered)"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in report:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestStatementMetadata(t *testing.T) {
+	statements := buildSolarSystem(t)
+	earth := statements[1].(*Entity)
+	earth.AddTag("reviewed")
+	assertion := statements[6].(*Assertion)
+	assertion.AddTag("unverified")
+	assertion.AddTag("disputed")
+	assertion.Annotate("reviewer", "alice")
+	assertion.AddNote("Needs a second source]\nsee log")
+
+	serializer := NewTextSerializer()
+	text, err := serializer.SerializeToString(statements)
+	if err != nil {
+		t.Fatalf("Failed to serialize: %v", err)
+	}
+	if !strings.Contains(text, "TAG #"+assertion.ID()+" [disputed]\nTAG #"+assertion.ID()+" [unverified]\n") {
+		t.Errorf("Expected ordered TAG lines, got:\n%s", text)
+	}
+
+	decoded, err := serializer.DeserializeFromString(text)
+	if err != nil {
+		t.Fatalf("Failed to deserialize: %v", err)
+	}
+	again, _ := serializer.SerializeToString(decoded)
+	if again != text {
+		t.Errorf("Metadata did not survive a round trip:\n%s\nbecame\n%s", text, again)
+	}
+	for _, stmt := range decoded {
+		if stmt.ID() != assertion.ID() {
+			continue
+		}
+		decodedAssertion := stmt.(*Assertion)
+		if !decodedAssertion.HasTag("unverified") {
+			t.Error("Expected decoded assertion to be tagged")
+		}
+		if reviewer, _ := decodedAssertion.Annotation("reviewer"); reviewer != "alice" {
+			t.Errorf("Expected reviewer annotation, got %q", reviewer)
+		}
+		if notes := decodedAssertion.Notes(); len(notes) != 1 || notes[0] != "Needs a second source]\nsee log" {
+			t.Errorf("Unexpected notes: %q", notes)
+		}
+	}
+
+	records, err := RecordsFor(statements)
+	if err != nil {
+		t.Fatalf("Failed to build records: %v", err)
+	}
+	assembled, err := NewAssembler().Assemble(records)
+	if err != nil {
+		t.Fatalf("Failed to assemble: %v", err)
+	}
+	if reassembled, _ := serializer.SerializeToString(assembled); reassembled != text {
+		t.Errorf("Metadata did not survive assembly:\n%s", reassembled)
+	}
+
+	if _, err := serializer.DeserializeFromString("TAG #F9999 [unverified]\n"); err == nil {
+		t.Error("Expected error for TAG without a statement")
+	}
+
+	var buf bytes.Buffer
+	disassembler := NewDisassembler(&buf)
+	disassembler.RegisterStatements(statements)
+	disassembler.DisassembleAssertion(assertion.ID())
+	disassembler.DisassembleEntity(earth.ID())
+	for _, want := range []string{"TAGS: disputed, unverified", "reviewer: alice", "NOTES:", "TAGS: reviewed"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected disassembly to contain %q, got:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	serializer := NewTextSerializer()
+	decode := func(text string) []Statement {
+		t.Helper()
+		statements, err := serializer.DeserializeFromString(text)
+		if err != nil {
+			t.Fatalf("Failed to deserialize: %v", err)
+		}
+		return statements
+	}
+	base := decode(`DEF_ENTITY #E1001 [Sun] type=[00B2SO-LAR-SUN]
+DEF_ENTITY #E1002 [Earth] type=[00B3SO-LAR-ERT]
+DEF_RELATION #R1001 [orbits] type=[SPATIAL]
+ASSERT #F1001 subject=[#E1002] relation=[#R1001] object=[#E1001]
+ASSERT #F1002 subject=[#E1001] relation=[#R1001] object=[#E1002]
+`)
+	ours := decode(`DEF_ENTITY #E1001 [The_Sun] type=[00B2SO-LAR-SUN]
+DEF_ENTITY #E1002 [Earth] type=[00B3SO-LAR-ERT]
+DEF_RELATION #R1001 [orbits] type=[SPATIAL]
+ASSERT #F1001 subject=[#E1002] relation=[#R1001] object=[#E1001]
+CONFIDENCE #F1001 level=[0.9] source=[survey]
+ASSERT #F1002 subject=[#E1001] relation=[#R1001] object=[#E1002]
+ASSERT #F1003 subject=[#E1002] relation=[#R1001] object=[#E1002]
+`)
+	theirs := decode(`DEF_ENTITY #E1001 [Sun] type=[00B2SO-LAR-SUN]
+DEF_ENTITY #E1002 [Earth] type=[00B3SO-LAR-ERT]
+TAG #E1002 [reviewed]
+DEF_RELATION #R1001 [orbits] type=[SPATIAL]
+ASSERT #F1001 subject=[#E1002] relation=[#R1001] object=[#E1001]
+CONFIDENCE #F1001 level=[0.5] source=[rumour]
+NEGATE #F1004 subject=[#E1002] relation=[#R1001] object=[#E1002]
+DEF_ENTITY #E1003 [Moon] type=[]
+`)
+
+	result := Merge(base, ours, theirs)
+	var keys []string
+	for _, conflict := range result.Conflicts {
+		keys = append(keys, conflict.Key+": "+conflict.Reason)
+	}
+	expected := []string{
+		"ASSERT #F1001: changed differently on both sides",
+		"ASSERT #F1003: contradicts ASSERT #F1004",
+	}
+	if strings.Join(keys, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected conflicts:\n%s", strings.Join(keys, "\n"))
+	}
+
+	var buf bytes.Buffer
+	if err := result.Encode(&buf); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	merged := buf.String()
+	for _, want := range []string{
+		"DEF_ENTITY #E1001 [The_Sun]", // Changed in ours only
+		"TAG #E1002 [reviewed]",       // Changed in theirs only
+		"DEF_ENTITY #E1003 [Moon]",    // Added in theirs
+		ConflictOursMarker + " ASSERT #F1001",
+		"CONFIDENCE #F1001 level=[0.9] source=[survey]\n" + ConflictSeparator + "\n" +
+			"ASSERT #F1001 subject=[#E1002] relation=[#R1001] object=[#E1001]\nCONFIDENCE #F1001 level=[0.5] source=[rumour]\n" +
+			ConflictTheirsMarker,
+	} {
+		if !strings.Contains(merged, want) {
+			t.Errorf("Expected merge to contain %q, got:\n%s", want, merged)
+		}
+	}
+	if strings.Contains(merged, "#F1002") {
+		t.Errorf("Expected the assertion removed in theirs to be gone, got:\n%s", merged)
+	}
+	if _, err := serializer.DeserializeFromString(merged); err == nil {
+		t.Error("Expected a merge with conflicts to fail to load")
+	}
+
+	clean := Merge(base, ours, base)
+	if clean.HasConflicts() || len(clean.Statements) != len(ours) {
+		t.Errorf("Expected merging an unchanged side to give ours, got %d statements and %v", len(clean.Statements), clean.Conflicts)
+	}
+}
+
+func TestFormatCanonical(t *testing.T) {
+	src := `# Scratch notes
+ASSERT #F1002 subject=[#F1010] relation=[#R1001] object=[#E10]
+DEF_RELATION #R1001 [orbits] type=[SPATIAL]
+TAG #R1001 [core]
+ASSERT #F1010 subject=[#E10] relation=[#R1001] object=[#E9]
+CONFIDENCE #F1010 level=[0.8] source=[survey]
+DEF_ENTITY #E10 [Earth] type=[00B3SO-LAR-ERT]
+PROPERTY #E10 [radius] value=[6371km]
+DEF_ENTITY #E9 [Sun] type=[00B2SO-LAR-SUN]
+`
+	expected := `DEF_ENTITY #E9 [Sun] type=[00B2SO-LAR-SUN]
+DEF_ENTITY #E10 [Earth] type=[00B3SO-LAR-ERT]
+PROPERTY #E10 [radius] value=[6371km]
+
+DEF_RELATION #R1001 [orbits] type=[SPATIAL]
+TAG #R1001 [core]
+
+ASSERT #F1010 subject=[#E10] relation=[#R1001] object=[#E9]
+CONFIDENCE #F1010 level=[0.8] source=[survey]
+ASSERT #F1002 subject=[#F1010] relation=[#R1001] object=[#E10]
+`
+	formatted, err := FormatCanonical([]byte(src))
+	if err != nil {
+		t.Fatalf("FormatCanonical failed: %v", err)
+	}
+	if string(formatted) != expected {
+		t.Errorf("Unexpected canonical form:\n%s", formatted)
+	}
+	again, err := FormatCanonical(formatted)
+	if err != nil || !bytes.Equal(again, formatted) {
+		t.Errorf("Expected formatting to be stable, got:\n%s", again)
+	}
+	if _, err := FormatCanonical([]byte("DEF_ENTITY E1001\n")); err == nil {
+		t.Error("Expected error formatting invalid KMAC")
+	}
+}
+
+func TestNaryAssertion(t *testing.T) {
+	src := `DEF_ENTITY #E1001 [Red_Cross] type=[11B1-ORG-NGO-RCR:USA-DIS-RES]
+DEF_ENTITY #E1002 [Penicillin] type=[10C5-MED-SUP-ANB:PNC-AMP-500]
+DEF_ENTITY #E1003 [Depot] type=[]
+DEF_ENTITY #E1004 [Field_Hospital] type=[]
+DEF_RELATION #R1001 [TRANSFER] type=[LOGISTICS]
+NARY_ASSERT #F2001 relation=[#R1001] AGENT=[#E1001] RESOURCE=[#E1002] FROM=[#E1003] TO=[#E1004] TIME=[#2024-03-01]
+CONFIDENCE #F2001 level=[0.9] source=[manifest]
+`
+	statements, err := NewTextSerializer().Decode(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	transfer, ok := statements[5].(*NaryAssertion)
+	if !ok {
+		t.Fatalf("Expected an n-ary assertion, got %T", statements[5])
+	}
+	var roles []string
+	for _, arg := range transfer.Arguments() {
+		roles = append(roles, arg.Role)
+	}
+	if strings.Join(roles, ",") != "AGENT,RESOURCE,FROM,TO,TIME" {
+		t.Errorf("Expected the arguments in written order, got %v", roles)
+	}
+	if to, _ := transfer.Argument("TO"); to != "E1004" {
+		t.Errorf("Expected E1004 in the TO role, got %q", to)
+	}
+	if level, source := transfer.GetConfidence(); level != 0.9 || source != "manifest" {
+		t.Errorf("Unexpected confidence %v from %q", level, source)
+	}
+
+	var buf bytes.Buffer
+	if err := NewTextSerializer().Encode(&buf, statements); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if buf.String() != src {
+		t.Errorf("Expected the text to round trip, got:\n%s", buf.String())
+	}
+
+	collection := NewStatementCollection()
+	for _, stmt := range statements {
+		collection.Add(stmt)
+	}
+	if warnings := collection.Validate(); len(warnings) != 0 {
+		t.Errorf("Expected no warnings, got %v", warnings)
+	}
+
+	var out bytes.Buffer
+	d := NewDisassembler(&out)
+	d.RegisterStatements(statements)
+	d.DisassembleNaryAssertion("F2001")
+	for _, want := range []string{
+		"transfer(agent: Red Cross, resource: Penicillin, from: Depot, to: Field Hospital, time: 2024-03-01) (confidence 0.9, manifest)",
+		"FROM: #E1003 [Depot] (Entity)",
+		"TIME: 2024-03-01 (Literal value)",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in disassembly:\n%s", want, out.String())
+		}
+	}
+
+	if _, err := NewNaryAssertion("F2002", "R1001", []Argument{{Role: "AGENT", Value: "E1001"}, {Role: "AGENT", Value: "E1002"}}); err == nil {
+		t.Error("Expected a repeated role rejected")
+	}
+	if _, err := NewNaryAssertion("F2002", "R1001", []Argument{{Role: "relation", Value: "E1001"}, {Role: "TO", Value: "E1002"}}); err == nil {
+		t.Error("Expected the relation field name rejected as a role")
+	}
+	if _, err := NewNaryAssertion("F2002", "R1001", []Argument{{Role: "AGENT", Value: "E1001"}}); err == nil {
+		t.Error("Expected a single argument rejected")
+	}
+}
+
+func TestSituations(t *testing.T) {
+	src := `DEF_ENTITY #E1001 [Apollo_11] type=[10B2-SPC-JOB-X47:FNC-ORB-S25]
+DEF_ENTITY #E1002 [Moon] type=[00B2-CEL-MON-SFC:000-000-000-001]
+DEF_ENTITY #E1003 [Armstrong] type=[]
+DEF_RELATION #R1001 [LANDED_ON] type=[SPATIAL]
+DEF_RELATION #R1002 [WALKED_ON] type=[SPATIAL]
+ASSERT #F1001 subject=[#E1001] relation=[#R1001] object=[#E1002]
+ASSERT #F1002 subject=[#E1003] relation=[#R1002] object=[#E1002]
+DEF_SITUATION #S1001 [Apollo_11_Mission]
+DEF_SITUATION #S1002 [Lunar_EVA] within=[#S1001]
+IN_SITUATION #F1001 situation=[#S1001]
+IN_SITUATION #F1002 situation=[#S1002]
+`
+	statements, err := NewTextSerializer().Decode(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	eva, ok := statements[8].(*Situation)
+	if !ok {
+		t.Fatalf("Expected a situation, got %T", statements[8])
+	}
+	if eva.Label() != "Lunar_EVA" || eva.ParentID() != "S1001" {
+		t.Errorf("Unexpected situation %v", eva)
+	}
+	member, ok := statements[10].(*SituationMember)
+	if !ok || member.AssertionID() != "F1002" || member.SituationID() != "S1002" {
+		t.Fatalf("Unexpected membership %v", statements[10])
+	}
+
+	var buf bytes.Buffer
+	if err := NewTextSerializer().Encode(&buf, statements); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if buf.String() != src {
+		t.Errorf("Expected the text to round trip, got:\n%s", buf.String())
+	}
+
+	collection := NewStatementCollection()
+	for _, stmt := range statements {
+		collection.Add(stmt)
+	}
+	if warnings := collection.Validate(); len(warnings) != 0 {
+		t.Errorf("Expected no warnings, got %v", warnings)
+	}
+
+	var out bytes.Buffer
+	d := NewDisassembler(&out)
+	d.RegisterStatements(statements)
+	d.DisassembleSituation("S1001")
+	for _, want := range []string{
+		"SITUATION #S1001 [Apollo_11_Mission]",
+		"    #F1001 [Apollo_11] [LANDED_ON] [Moon]",
+		"    SITUATION #S1002 [Lunar_EVA]:",
+		"      #F1002 [Armstrong] [WALKED_ON] [Moon]",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in disassembly:\n%s", want, out.String())
+		}
+	}
+
+	if _, err := NewSituation("S1001", "Loop", "S1001"); err == nil {
+		t.Error("Expected a situation within itself rejected")
+	}
+	if _, err := NewSituationMember("E1001", "S1001"); err == nil {
+		t.Error("Expected a non-assertion member rejected")
+	}
+}
+
+func TestDependencyGraph(t *testing.T) {
+	src := `DEF_ENTITY #E1001 [Armstrong] type=[]
+DEF_ENTITY #E1002 [Moon] type=[00B2-CEL-MON-SFC:000-000-000-001]
+DEF_EVENT #V1001 [Landing] type=[LANDING]
+DEF_EVENT #V1002 [Moonwalk] type=[EVA]
+DEF_RELATION #R1001 [WALKED_ON] type=[SPATIAL]
+ASSERT #F1001 subject=[#E1001] relation=[#R1001] object=[#E1002]
+EVIDENCE #D1001 assertion=[#F1001] url=[https://history.nasa.gov/alsj/]
+CAUSATION source=[#V1001] target=[#V1002] type=[ENABLEMENT]
+`
+	statements, err := NewTextSerializer().Decode(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	collection := NewStatementCollection()
+	for _, stmt := range statements {
+		if err := collection.Add(stmt); err != nil {
+			t.Fatalf("Add %s failed: %v", stmt.ID(), err)
+		}
+	}
+
+	graph := collection.DependencyGraph()
+	if got := graph.References("F1001"); strings.Join(got, ",") != "E1001,E1002,R1001" {
+		t.Errorf("Expected F1001 to refer to its subject, relation, and object, got %v", got)
+	}
+	if got := graph.ReferencedBy("V1001"); strings.Join(got, ",") != "CAUS_V1001_V1002" {
+		t.Errorf("Expected V1001 referenced by the causation, got %v", got)
+	}
+	if got := graph.Impact("E1001"); strings.Join(got, ",") != "D1001,F1001" {
+		t.Errorf("Expected removing E1001 to affect F1001 and its evidence, got %v", got)
+	}
+	if got := graph.Impact("D1001"); len(got) != 0 {
+		t.Errorf("Expected nothing to depend on D1001, got %v", got)
+	}
+
+	order, err := graph.DeletionOrder("E1001", "V1002")
+	if err != nil {
+		t.Fatalf("DeletionOrder failed: %v", err)
+	}
+	if got := strings.Join(order, " "); got != "CAUS_V1001_V1002 D1001 F1001 E1001 V1002" {
+		t.Errorf("Unexpected deletion order %s", got)
+	}
+	if _, err := graph.DeletionOrder("E9999"); err == nil {
+		t.Error("Expected an unknown statement rejected")
+	}
+}
+
+func TestLazyAllocation(t *testing.T) {
+	if allocs := testing.AllocsPerRun(100, func() { NewEntity("E1001", "Sun", "") }); allocs != 1 {
+		t.Errorf("Expected one allocation per entity, got %v", allocs)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { NewAssertion("F1001", "E1001", "R1001", "E1002") }); allocs != 1 {
+		t.Errorf("Expected one allocation per assertion, got %v", allocs)
+	}
+
+	entity, _ := NewEntity("E1001", "Sun", "")
+	clone := entity.Clone()
+	clone.SetProperty("mass", "1.989e30")
+	clone.AddTag("reviewed")
+	clone.AddNote("checked against the almanac")
+	if entity.HasProperty("mass") || entity.HasTag("reviewed") || len(entity.Notes()) != 0 {
+		t.Errorf("Expected the original unchanged by its clone, got %v %v", entity.GetAllProperties(), entity.Tags())
+	}
+	second := clone.Clone()
+	second.AddNote("second opinion")
+	if len(clone.Notes()) != 1 || len(second.Notes()) != 2 {
+		t.Errorf("Expected cloned notes independent, got %v and %v", clone.Notes(), second.Notes())
+	}
+}
+
+func TestKMACBuilderConcurrent(t *testing.T) {
+	builder := NewKMACBuilder()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := builder.AddEntity(fmt.Sprintf("Entity %d", i), ""); err != nil {
+				t.Errorf("AddEntity failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	statements := builder.Build()
+	if len(statements) != 50 || statements[0].ID() != "E0001" || statements[49].ID() != "E0050" {
+		t.Errorf("Expected entities E0001 to E0050, got %d from %s", len(statements), statements[0].ID())
+	}
+}
+
+func TestKMACBuilderDeterministicIDs(t *testing.T) {
+	build := func(reverse bool) string {
+		builder := NewKMACBuilder()
+		builder.SetDeterministicIDs(true)
+		labels := []string{"Sun", "Earth", "Moon"}
+		if reverse {
+			labels = []string{"Moon", "Earth", "Sun"}
+		}
+		ids := make(map[string]string)
+		for _, label := range labels {
+			entity, err := builder.AddEntity(label, "")
+			if err != nil {
+				t.Fatalf("AddEntity failed: %v", err)
+			}
+			ids[label] = entity.ID()
+		}
+		orbits, _ := builder.AddRelation("ORBITS", "SPATIAL")
+		builder.AddAssertion(ids["Earth"], orbits.ID(), ids["Sun"])
+		builder.AddAssertion(ids["Moon"], orbits.ID(), ids["Earth"])
+
+		var buf bytes.Buffer
+		if err := NewTextSerializer().Encode(&buf, builder.Build()); err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		return buf.String()
+	}
+
+	first, second := build(false), build(true)
+	if first != second {
+		t.Errorf("Expected identical builds, got:\n%s\nand:\n%s", first, second)
+	}
+
+	builder := NewKMACBuilder()
+	builder.SetDeterministicIDs(true)
+	sun, _ := builder.AddEntity("Sun", "")
+	again, err := builder.AddEntity("Sun", "")
+	if err != nil || again.ID() != sun.ID() || len(builder.Build()) != 1 {
+		t.Errorf("Expected the same content to yield the same entity, got %s and %s (%v)", sun.ID(), again.ID(), err)
+	}
+	if len(sun.ID()) != 17 || !strings.HasPrefix(sun.ID(), "E") {
+		t.Errorf("Unexpected content ID %s", sun.ID())
+	}
+}
+
+func TestULIDIdentifiers(t *testing.T) {
+	generator := NewULIDGenerator()
+	before := time.Now().Truncate(time.Millisecond)
+	var last string
+	for i := 0; i < 1000; i++ {
+		id, err := generator.New(EntityIDPrefix)
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		if !IsULIDIdentifier(EntityIDPrefix, id) || len(id) != 28 {
+			t.Fatalf("Unexpected ULID identifier %s", id)
+		}
+		if id <= last {
+			t.Fatalf("Expected %s to sort after %s", id, last)
+		}
+		last = id
+	}
+	after := time.Now()
+
+	issued, err := ULIDTime(last)
+	if err != nil {
+		t.Fatalf("ULIDTime failed: %v", err)
+	}
+	if issued.Before(before) || issued.After(after) {
+		t.Errorf("Expected %v to be between %v and %v", issued, before, after)
+	}
+
+	for _, id := range []string{
+		"E0001",
+		"E-01HQ3K5Z8M2XWT6V9RBYDC4NJ",   // Too short
+		"E-01hq3k5z8m2xwt6v9rbydc4nja",  // Not upper case
+		"E-01HQ3K5Z8M2XWT6V9RBYDC4NJU",  // Not Crockford base32
+		"E-81HQ3K5Z8M2XWT6V9RBYDC4NJA",  // Overflows 128 bits
+		"R-01HQ3K5Z8M2XWT6V9RBYDC4NJA",  // Wrong prefix
+		"E--01HQ3K5Z8M2XWT6V9RBYDC4NJA", // Extra separator
+	} {
+		if IsULIDIdentifier(EntityIDPrefix, id) {
+			t.Errorf("Expected %s to be rejected", id)
+		}
+	}
+
+	builder := NewKMACBuilder()
+	builder.SetULIDs(generator)
+	sun, _ := builder.AddEntity("Sun", "")
+	earth, _ := builder.AddEntity("Earth", "")
+	orbits, _ := builder.AddRelation("ORBITS", "SPATIAL")
+	assertion, err := builder.AddAssertion(earth.ID(), orbits.ID(), sun.ID())
+	if err != nil {
+		t.Fatalf("AddAssertion failed: %v", err)
+	}
+	if !IsULIDIdentifier(AssertionIDPrefix, assertion.ID()) || !IsULIDIdentifier(RelationIDPrefix, orbits.ID()) {
+		t.Errorf("Expected ULID identifiers, got %s and %s", assertion.ID(), orbits.ID())
+	}
+	if sun.ID() >= earth.ID() {
+		t.Errorf("Expected %s to sort before %s", sun.ID(), earth.ID())
+	}
+}
+
+func TestWalk(t *testing.T) {
+	collection := NewStatementCollection()
+	for _, stmt := range buildSolarSystem(t) {
+		collection.Add(stmt)
+	}
+	before := collection.Count()
+	earth, _ := collection.Get("E1002")
+	earth.(*Entity).SetProperty("mass", "5.97e24")
+
+	dropAssertions := func(stmt Statement) (Statement, error) {
+		if _, ok := stmt.(*Assertion); ok {
+			return nil, nil
+		}
+		return stmt, nil
+	}
+	err := collection.Walk(Chain(
+		RenameRelation("orbits", "ORBITS"),
+		RemapTOSIDs(map[string]string{earth.(*Entity).TOSIDType(): "00B3SO-LAR-TRR"}),
+		NormalizeLabels(strings.ToUpper),
+		dropAssertions,
+	))
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+
+	stmt, _ := collection.Get("E1002")
+	walked := stmt.(*Entity)
+	if walked == earth || walked.Label() != "EARTH" || walked.TOSIDType() != "00B3SO-LAR-TRR" {
+		t.Errorf("Expected a relabeled, remapped copy of Earth, got %v", walked)
+	}
+	if mass, _ := walked.GetProperty("mass"); mass != "5.97e24" {
+		t.Errorf("Expected properties kept, got mass %q", mass)
+	}
+	if earth.(*Entity).Label() != "Earth" {
+		t.Error("Expected the original entity unchanged")
+	}
+	for _, relation := range collection.GetByType("DEF_RELATION") {
+		if relation.(*Relation).Label() == "orbits" {
+			t.Errorf("Expected orbits renamed, got %v", relation)
+		}
+	}
+	if len(collection.GetByType("ASSERT")) != 0 || collection.Count() >= before {
+		t.Errorf("Expected assertions dropped, got %d statements", collection.Count())
+	}
+
+	failing := func(stmt Statement) (Statement, error) {
+		if stmt.ID() == "E1002" {
+			return nil, fmt.Errorf("cannot migrate")
+		}
+		return nil, nil
+	}
+	count := collection.Count()
+	if err := collection.Walk(failing); err == nil || !strings.Contains(err.Error(), "E1002") {
+		t.Errorf("Expected the failing statement named, got %v", err)
+	}
+	if collection.Count() != count {
+		t.Error("Expected a failed walk to leave the collection unchanged")
+	}
+}
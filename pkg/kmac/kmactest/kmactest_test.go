@@ -0,0 +1,70 @@
+package kmactest
+
+import (
+	"testing"
+)
+
+func TestNewFixtureStore(t *testing.T) {
+	store := NewFixtureStore(100, FixtureOptions{Seed: 1, Relations: 3, AssertionsPerEntity: 5})
+
+	stats := store.GetStatistics()
+	if stats["entities"] != 100 {
+		t.Errorf("Expected 100 entities, got %d", stats["entities"])
+	}
+	if stats["relations"] != 3 {
+		t.Errorf("Expected 3 relations, got %d", stats["relations"])
+	}
+	if stats["assertions"] != 500 {
+		t.Errorf("Expected 500 assertions, got %d", stats["assertions"])
+	}
+	if issues := store.ValidateStore(); len(issues) != 0 {
+		t.Errorf("Expected a valid store, got issues: %v", issues)
+	}
+}
+
+func TestFixtureCollectionIsValid(t *testing.T) {
+	fixture := NewFixture(50, FixtureOptions{Seed: 2})
+	for _, assertion := range fixture.Assertions {
+		if assertion.Subject() == assertion.Object() {
+			t.Errorf("Assertion %s refers to itself", assertion.ID())
+		}
+	}
+
+	collection := fixture.Collection()
+	if collection.Count() != len(fixture.Statements()) {
+		t.Errorf("Expected %d statements, got %d", len(fixture.Statements()), collection.Count())
+	}
+	if issues := collection.Validate(); len(issues) != 0 {
+		t.Errorf("Expected a valid collection, got issues: %v", issues)
+	}
+}
+
+func TestFixtureIsReproducible(t *testing.T) {
+	a := NewFixture(20, FixtureOptions{Seed: 3})
+	b := NewFixture(20, FixtureOptions{Seed: 3})
+	for i := range a.Assertions {
+		if a.Assertions[i].String() != b.Assertions[i].String() {
+			t.Fatalf("Assertion %d differs: %s vs %s", i, a.Assertions[i], b.Assertions[i])
+		}
+	}
+	for i := range a.Entities {
+		if a.Entities[i].TOSIDType() != b.Entities[i].TOSIDType() {
+			t.Fatalf("Entity %d differs: %s vs %s", i, a.Entities[i].TOSIDType(), b.Entities[i].TOSIDType())
+		}
+	}
+}
+
+func TestSmallFixtures(t *testing.T) {
+	for _, n := range []int{0, 1} {
+		fixture := NewFixture(n, FixtureOptions{})
+		if len(fixture.Entities) != n || len(fixture.Assertions) != 0 {
+			t.Errorf("NewFixture(%d): got %d entities and %d assertions", n, len(fixture.Entities), len(fixture.Assertions))
+		}
+	}
+}
+
+func BenchmarkNewFixtureStore(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		NewFixtureStore(10000, FixtureOptions{Seed: int64(i)})
+	}
+}
@@ -0,0 +1,208 @@
+// Package lint checks KMAC statements for style and semantic issues, such as
+// relations nothing uses or uncertain assertions with no stated source. Each
+// rule reports at a severity that a lint config file can change, or turn off.
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+// Severity is how serious a rule's issues are
+type Severity string
+
+// Severities, most serious first
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+	SeverityOff     Severity = "off" // The rule is not checked
+)
+
+// Rules
+const (
+	RuleUnusedRelation         = "unused-relation"          // A relation no assertion uses
+	RuleMissingTOSID           = "missing-tosid"            // An entity without a TOSID
+	RuleUnsourcedLowConfidence = "unsourced-low-confidence" // An assertion under the confidence threshold with no source
+	RuleLiteralObject          = "literal-object"           // An assertion whose relation has a range but whose object is not an entity
+	RuleNonconformingID        = "nonconforming-id"         // An ID other than its type's prefix followed by digits
+)
+
+// defaultSeverities are the severities of the rules unless configured
+var defaultSeverities = map[string]Severity{
+	RuleUnusedRelation:         SeverityWarning,
+	RuleMissingTOSID:           SeverityInfo,
+	RuleUnsourcedLowConfidence: SeverityWarning,
+	RuleLiteralObject:          SeverityError,
+	RuleNonconformingID:        SeverityWarning,
+}
+
+// DefaultConfidenceThreshold is the confidence under which an assertion
+// needs a source unless configured
+const DefaultConfidenceThreshold = 0.7
+
+// Config chooses the severity of each rule, stored as a JSON lint config file
+type Config struct {
+	Rules               map[string]Severity `json:"rules,omitempty"`                // Severity by rule; unlisted rules keep their default
+	ConfidenceThreshold float64             `json:"confidence_threshold,omitempty"` // 0 means DefaultConfidenceThreshold
+}
+
+// Issue is a problem found in a statement
+type Issue struct {
+	Rule     string
+	Severity Severity
+	ID       string // Of the statement at fault
+	Message  string
+}
+
+// String renders the issue as one line
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s: %s (%s)", i.Severity, i.ID, i.Message, i.Rule)
+}
+
+// LoadConfig reads a lint config file
+func LoadConfig(r io.Reader) (*Config, error) {
+	var config Config
+	if err := json.NewDecoder(r).Decode(&config); err != nil {
+		return nil, fmt.Errorf("failed to decode lint config: %v", err)
+	}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// validate checks the config names known rules and severities
+func (c *Config) validate() error {
+	for rule, severity := range c.Rules {
+		if _, known := defaultSeverities[rule]; !known {
+			return fmt.Errorf("unknown lint rule %s", rule)
+		}
+		switch severity {
+		case SeverityError, SeverityWarning, SeverityInfo, SeverityOff:
+		default:
+			return fmt.Errorf("unknown severity %q for lint rule %s", severity, rule)
+		}
+	}
+	if c.ConfidenceThreshold < 0 || c.ConfidenceThreshold > 1 {
+		return fmt.Errorf("confidence threshold %v must be between 0 and 1", c.ConfidenceThreshold)
+	}
+	return nil
+}
+
+// Rules returns the names of the rules, in order
+func Rules() []string {
+	rules := make([]string, 0, len(defaultSeverities))
+	for rule := range defaultSeverities {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	return rules
+}
+
+// severity returns the severity a rule reports at
+func (c *Config) severity(rule string) Severity {
+	if severity, set := c.Rules[rule]; set {
+		return severity
+	}
+	return defaultSeverities[rule]
+}
+
+// idPrefixes are the prefixes of the conforming IDs of each statement type
+var idPrefixes = map[string]string{
+	"DEF_ENTITY":    kmac.EntityIDPrefix,
+	"DEF_EVENT":     kmac.EventIDPrefix,
+	"DEF_RELATION":  kmac.RelationIDPrefix,
+	"DEF_PROPERTY":  kmac.PropertyIDPrefix,
+	"DEF_TIME":      kmac.TimeIDPrefix,
+	"DEF_PLAN":      kmac.PlanIDPrefix,
+	"DEF_TASK":      kmac.TaskIDPrefix,
+	"DEF_SITUATION": kmac.SituationIDPrefix,
+	"EVIDENCE":      kmac.EvidenceIDPrefix,
+	"ASSERT":        kmac.AssertionIDPrefix,
+	"NARY_ASSERT":   kmac.AssertionIDPrefix,
+	"STATE":         kmac.AssertionIDPrefix,
+}
+
+// conformingID reports whether an ID is the prefix followed by digits, or a
+// ULID identifier with the prefix
+func conformingID(prefix, id string) bool {
+	if kmac.IsULIDIdentifier(prefix, id) {
+		return true
+	}
+	digits := strings.TrimPrefix(id, prefix)
+	if digits == id || digits == "" {
+		return false
+	}
+	for i := 0; i < len(digits); i++ {
+		if digits[i] < '0' || digits[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Lint checks statements against the rules a config enables, or the
+// default rules if config is nil, and returns the issues found in the order
+// of the statements at fault
+func Lint(statements []kmac.Statement, config *Config) []Issue {
+	if config == nil {
+		config = &Config{}
+	}
+	threshold := config.ConfidenceThreshold
+	if threshold == 0 {
+		threshold = DefaultConfidenceThreshold
+	}
+
+	relations := make(map[string]*kmac.Relation)
+	nodes := make(map[string]bool) // IDs of the entities and events defined
+	used := make(map[string]bool)  // IDs of the relations used
+	for _, stmt := range statements {
+		switch stmt := stmt.(type) {
+		case *kmac.Relation:
+			relations[stmt.ID()] = stmt
+		case *kmac.Entity, *kmac.Event:
+			nodes[stmt.ID()] = true
+		case *kmac.Assertion:
+			used[stmt.Relation()] = true
+		case *kmac.NaryAssertion:
+			used[stmt.Relation()] = true
+		}
+	}
+
+	var issues []Issue
+	report := func(rule string, id string, format string, args ...interface{}) {
+		if severity := config.severity(rule); severity != SeverityOff {
+			issues = append(issues, Issue{Rule: rule, Severity: severity, ID: id, Message: fmt.Sprintf(format, args...)})
+		}
+	}
+	for _, stmt := range statements {
+		if prefix, hasPrefix := idPrefixes[stmt.Type()]; hasPrefix && !conformingID(prefix, stmt.ID()) {
+			report(RuleNonconformingID, stmt.ID(), "ID is not %s followed by digits", prefix)
+		}
+		switch stmt := stmt.(type) {
+		case *kmac.Entity:
+			if stmt.TOSIDType() == "" {
+				report(RuleMissingTOSID, stmt.ID(), "entity %q has no TOSID", stmt.Label())
+			}
+		case *kmac.Relation:
+			if !used[stmt.ID()] {
+				report(RuleUnusedRelation, stmt.ID(), "relation %q is not used by any assertion", stmt.Label())
+			}
+		case *kmac.Assertion:
+			if level, source := stmt.GetConfidence(); level < threshold && source == "" {
+				report(RuleUnsourcedLowConfidence, stmt.ID(), "confidence %v is under %v but no source is given", level, threshold)
+			}
+			if relation, exists := relations[stmt.Relation()]; exists && relation.GetRange() != "" && !nodes[stmt.Object()] && !kmac.IsAssertionReference(stmt.Object()) {
+				report(RuleLiteralObject, stmt.ID(), "object %q is not a defined entity, but relation %s ranges over entities matching %s",
+					stmt.Object(), relation.ID(), relation.GetRange())
+			}
+		}
+	}
+	return issues
+}
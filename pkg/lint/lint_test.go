@@ -0,0 +1,64 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+func TestLint(t *testing.T) {
+	src := `DEF_ENTITY #E1001 [Earth] type=[00B3SO-LAR-ERT]
+DEF_ENTITY #E1002 [Moon] type=[]
+DEF_ENTITY #Ehq [Headquarters] type=[11B3ME-DHO-SPT]
+DEF_RELATION #R1001 [orbits] type=[SPATIAL] range=[00B]
+DEF_RELATION #R1002 [color] type=[ATTRIBUTE]
+DEF_RELATION #R1003 [unused] type=[SPATIAL]
+ASSERT #F1001 subject=[#E1002] relation=[#R1001] object=[#E1001]
+CONFIDENCE #F1001 level=[0.5] source=[survey]
+ASSERT #F1002 subject=[#E1002] relation=[#R1002] object=[grey]
+CONFIDENCE #F1002 level=[0.4] source=[]
+ASSERT #F1003 subject=[#E1002] relation=[#R1001] object=[sun]
+`
+	statements, err := kmac.NewTextSerializer().Decode(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	expected := []string{
+		"info: E1002: entity \"Moon\" has no TOSID (missing-tosid)",
+		"warning: Ehq: ID is not E followed by digits (nonconforming-id)",
+		"warning: R1003: relation \"unused\" is not used by any assertion (unused-relation)",
+		"warning: F1002: confidence 0.4 is under 0.7 but no source is given (unsourced-low-confidence)",
+		"error: F1003: object \"sun\" is not a defined entity, but relation R1001 ranges over entities matching 00B (literal-object)",
+	}
+	issues := Lint(statements, nil)
+	if len(issues) != len(expected) {
+		t.Fatalf("Expected %d issues, got %v", len(expected), issues)
+	}
+	for i, issue := range issues {
+		if issue.String() != expected[i] {
+			t.Errorf("Expected %q, got %q", expected[i], issue.String())
+		}
+	}
+
+	config, err := LoadConfig(strings.NewReader(`{"rules": {"missing-tosid": "off", "literal-object": "warning"}, "confidence_threshold": 0.3}`))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	for _, issue := range Lint(statements, config) {
+		switch {
+		case issue.Rule == RuleMissingTOSID || issue.Rule == RuleUnsourcedLowConfidence:
+			t.Errorf("Expected %s not reported, got %v", issue.Rule, issue)
+		case issue.Rule == RuleLiteralObject && issue.Severity != SeverityWarning:
+			t.Errorf("Expected literal objects reported as warnings, got %v", issue)
+		}
+	}
+
+	if _, err := LoadConfig(strings.NewReader(`{"rules": {"no-such-rule": "error"}}`)); err == nil {
+		t.Error("Expected an unknown rule rejected")
+	}
+	if _, err := LoadConfig(strings.NewReader(`{"rules": {"unused-relation": "fatal"}}`)); err == nil {
+		t.Error("Expected an unknown severity rejected")
+	}
+}
@@ -0,0 +1,361 @@
+// Package mapper stores annotated Go structs in a semantic store as KMAC
+// entities, properties, and assertions, and loads them back, so applications
+// can persist their domain models without writing translation code.
+//
+// Struct tags say what each field maps to:
+//
+//	type Helicopter struct {
+//		ID       string    `kmac:"id"`
+//		Name     string    `kmac:"label" tosid:"10B3TR-AIR-HEL"`
+//		Capacity int       `kmac:"property"`
+//		Serial   string    `kmac:"property:serial_number"`
+//		Built    time.Time `kmac:"property"`
+//		Operator string    `kmac:"relation:R1001"`
+//		Missions []string  `kmac:"relation:R1002"`
+//	}
+//
+// A struct needs a string field tagged id. The label and tosid fields are
+// optional; a tosid tag on any field gives the TOSID code of entities whose
+// tosid field is empty or missing. Properties may be strings, booleans,
+// numbers, or times, and are named after the lower-cased field unless the
+// tag names them. Relation fields hold the IDs of the entities the relation
+// leads to: one in a string field, or any number in a []string field.
+// Untagged fields and fields tagged "-" are ignored.
+package mapper
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+)
+
+// fieldRole is what a struct field maps to
+type fieldRole int
+
+const (
+	idField fieldRole = iota
+	labelField
+	tosidField
+	propertyField
+	relationField
+)
+
+// field is a mapped struct field
+type field struct {
+	index  int
+	goName string
+	role   fieldRole
+	name   string // Property name, or relation ID
+}
+
+// mapping is how a struct type maps to an entity
+type mapping struct {
+	fields []field
+	tosid  string // From a tosid tag
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// mappingOf reads the tags of a struct type
+func mappingOf(t reflect.Type) (*mapping, error) {
+	m := &mapping{}
+	roles := make(map[fieldRole]string) // Field name by role, for the roles a struct has once
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if code, tagged := sf.Tag.Lookup("tosid"); tagged {
+			if m.tosid != "" && m.tosid != code {
+				return nil, fmt.Errorf("%s has tosid tags %s and %s", t, m.tosid, code)
+			}
+			m.tosid = code
+		}
+		tag, tagged := sf.Tag.Lookup("kmac")
+		if !tagged || tag == "-" {
+			continue
+		}
+		if !sf.IsExported() {
+			return nil, fmt.Errorf("%s.%s is tagged but not exported", t, sf.Name)
+		}
+
+		role, name, _ := strings.Cut(tag, ":")
+		f := field{index: i, goName: sf.Name, name: name}
+		switch role {
+		case "id":
+			f.role = idField
+		case "label":
+			f.role = labelField
+		case "tosid":
+			f.role = tosidField
+		case "property":
+			f.role = propertyField
+			if f.name == "" {
+				f.name = strings.ToLower(sf.Name)
+			}
+			if !propertyType(sf.Type) {
+				return nil, fmt.Errorf("%s.%s has unsupported property type %s", t, sf.Name, sf.Type)
+			}
+		case "relation":
+			f.role = relationField
+			if f.name == "" {
+				return nil, fmt.Errorf("%s.%s is tagged relation without a relation ID", t, sf.Name)
+			}
+			if sf.Type.Kind() != reflect.String && sf.Type != reflect.TypeOf([]string(nil)) {
+				return nil, fmt.Errorf("%s.%s holds relation objects but is %s, not string or []string", t, sf.Name, sf.Type)
+			}
+		default:
+			return nil, fmt.Errorf("%s.%s has unknown kmac tag %q", t, sf.Name, tag)
+		}
+
+		if f.role <= tosidField {
+			if sf.Type.Kind() != reflect.String {
+				return nil, fmt.Errorf("%s.%s is tagged %s but is %s, not string", t, sf.Name, role, sf.Type)
+			}
+			if other, exists := roles[f.role]; exists {
+				return nil, fmt.Errorf("%s has two %s fields, %s and %s", t, role, other, sf.Name)
+			}
+			roles[f.role] = sf.Name
+		}
+		m.fields = append(m.fields, f)
+	}
+	if _, exists := roles[idField]; !exists {
+		return nil, fmt.Errorf("%s has no field tagged id", t)
+	}
+	return m, nil
+}
+
+// propertyType reports whether a property can be of a type
+func propertyType(t reflect.Type) bool {
+	if t == timeType {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// structOf returns the struct v is or points to, and its mapping
+func structOf(v interface{}) (reflect.Value, *mapping, error) {
+	value := reflect.ValueOf(v)
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return reflect.Value{}, nil, errors.New("cannot map a nil pointer")
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return reflect.Value{}, nil, fmt.Errorf("cannot map %T, only structs", v)
+	}
+	m, err := mappingOf(value.Type())
+	if err != nil {
+		return reflect.Value{}, nil, err
+	}
+	return value, m, nil
+}
+
+// Store adds the struct v, or the struct it points to, to a store as an
+// entity and returns the entity's ID. An entity already stored under the ID
+// is replaced, and its assertions by mapped relations are brought in line
+// with the struct: those to objects no longer listed are removed, and the
+// rest are created as content-addressed assertions, so storing the same
+// struct again changes nothing.
+func Store(store *semantic.SemanticStore, v interface{}) (string, error) {
+	value, m, err := structOf(v)
+	if err != nil {
+		return "", err
+	}
+
+	var id, label string
+	code := m.tosid
+	for _, f := range m.fields {
+		switch f.role {
+		case idField:
+			id = value.Field(f.index).String()
+		case labelField:
+			label = value.Field(f.index).String()
+		case tosidField:
+			if instance := value.Field(f.index).String(); instance != "" {
+				code = instance
+			}
+		}
+	}
+	if id == "" {
+		return "", fmt.Errorf("%s has an empty ID", value.Type())
+	}
+	if err := store.AddEntity(id, label, code); err != nil {
+		return "", err
+	}
+
+	entityRef, _ := store.GetEntity(id)
+	for _, f := range m.fields {
+		fv := value.Field(f.index)
+		switch f.role {
+		case propertyField:
+			entityRef.KMACEntity.SetProperty(f.name, formatProperty(fv))
+		case relationField:
+			if err := storeRelation(store, id, f.name, relationObjects(fv)); err != nil {
+				return "", fmt.Errorf("failed to store %s.%s: %v", value.Type(), f.goName, err)
+			}
+		}
+	}
+	return id, nil
+}
+
+// storeRelation makes the objects a subject's assertions by a relation lead
+// to those given
+func storeRelation(store *semantic.SemanticStore, subjectID string, relationID string, objects []string) error {
+	listed := make(map[string]bool, len(objects))
+	for _, object := range objects {
+		listed[object] = true
+	}
+	for _, assertion := range store.FindAssertionsBySubject(subjectID) {
+		if assertion.Relation() == relationID && !listed[assertion.Object()] {
+			if err := store.RemoveAssertion(assertion.ID(), "no longer in the mapped struct"); err != nil {
+				return err
+			}
+		}
+	}
+	for _, object := range objects {
+		if _, err := store.CreateContentAssertion(subjectID, relationID, object, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// relationObjects returns the object IDs a relation field holds
+func relationObjects(fv reflect.Value) []string {
+	if fv.Kind() == reflect.String {
+		if fv.String() == "" {
+			return nil
+		}
+		return []string{fv.String()}
+	}
+	var objects []string
+	for _, object := range fv.Interface().([]string) {
+		if object != "" {
+			objects = append(objects, object)
+		}
+	}
+	return objects
+}
+
+// formatProperty writes a property field's value as a string
+func formatProperty(fv reflect.Value) string {
+	if fv.Type() == timeType {
+		return fv.Interface().(time.Time).Format(time.RFC3339Nano)
+	}
+	switch fv.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(fv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(fv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(fv.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(fv.Float(), 'g', -1, fv.Type().Bits())
+	}
+	return fv.String()
+}
+
+// Load fills the struct v points to from the entity stored under an ID.
+// Fields whose property is not set, or whose relation leads nowhere, are
+// left at their zero value. A string relation field fails to load if the
+// relation leads to more than one object.
+func Load(store *semantic.SemanticStore, id string, v interface{}) error {
+	if reflect.ValueOf(v).Kind() != reflect.Pointer {
+		return fmt.Errorf("cannot load into %T, only a pointer to a struct", v)
+	}
+	value, m, err := structOf(v)
+	if err != nil {
+		return err
+	}
+	entityRef, err := store.GetEntity(id)
+	if err != nil {
+		return err
+	}
+
+	entity := entityRef.KMACEntity
+	for _, f := range m.fields {
+		fv := value.Field(f.index)
+		fv.Set(reflect.Zero(fv.Type()))
+		switch f.role {
+		case idField:
+			fv.SetString(entity.ID())
+		case labelField:
+			fv.SetString(entity.Label())
+		case tosidField:
+			fv.SetString(entity.TOSIDType())
+		case propertyField:
+			if property, set := entity.GetProperty(f.name); set {
+				if err := parseProperty(fv, property); err != nil {
+					return fmt.Errorf("failed to load %s.%s: %v", value.Type(), f.goName, err)
+				}
+			}
+		case relationField:
+			var objects []string
+			for _, assertion := range store.FindAssertionsBySubject(id) {
+				if assertion.Relation() == f.name {
+					objects = append(objects, assertion.Object())
+				}
+			}
+			if fv.Kind() != reflect.String {
+				fv.Set(reflect.ValueOf(objects))
+			} else if len(objects) > 1 {
+				return fmt.Errorf("failed to load %s.%s: relation %s leads to %d objects", value.Type(), f.goName, f.name, len(objects))
+			} else if len(objects) == 1 {
+				fv.SetString(objects[0])
+			}
+		}
+	}
+	return nil
+}
+
+// parseProperty sets a property field from its string value
+func parseProperty(fv reflect.Value, property string) error {
+	if fv.Type() == timeType {
+		parsed, err := time.Parse(time.RFC3339Nano, property)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(parsed))
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(property)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(property, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(property, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(property, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(parsed)
+	default:
+		fv.SetString(property)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package mapper
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+)
+
+type helicopter struct {
+	ID       string    `kmac:"id"`
+	Name     string    `kmac:"label" tosid:"10B3TR-AIR-HEL"`
+	Code     string    `kmac:"tosid"`
+	Capacity int       `kmac:"property"`
+	Serial   string    `kmac:"property:serial_number"`
+	Range    float64   `kmac:"property"`
+	Armed    bool      `kmac:"property"`
+	Built    time.Time `kmac:"property"`
+	Operator string    `kmac:"relation:R1001"`
+	Missions []string  `kmac:"relation:R1002"`
+	Notes    string
+	Internal string `kmac:"-"`
+}
+
+func newStore(t *testing.T) *semantic.SemanticStore {
+	store := semantic.NewSemanticStore()
+	for _, id := range []string{"E2001", "E2002", "E3001", "E3002", "E3003"} {
+		if err := store.AddEntity(id, id, ""); err != nil {
+			t.Fatalf("AddEntity failed: %v", err)
+		}
+	}
+	return store
+}
+
+func TestStoreAndLoad(t *testing.T) {
+	store := newStore(t)
+	built := time.Date(2019, 4, 1, 12, 30, 0, 0, time.UTC)
+	original := &helicopter{
+		ID:       "E1001",
+		Name:     "Rescue One",
+		Capacity: 5,
+		Serial:   "AW-139-41",
+		Range:    1061.5,
+		Armed:    false,
+		Built:    built,
+		Operator: "E2001",
+		Missions: []string{"E3001", "E3002"},
+		Notes:    "not stored",
+	}
+	id, err := Store(store, original)
+	if err != nil || id != "E1001" {
+		t.Fatalf("Store failed: %s, %v", id, err)
+	}
+
+	entityRef, err := store.GetEntity("E1001")
+	if err != nil {
+		t.Fatalf("GetEntity failed: %v", err)
+	}
+	if entityRef.KMACEntity.Label() != "Rescue One" || entityRef.KMACEntity.TOSIDType() != "10B3TR-AIR-HEL" {
+		t.Errorf("Unexpected entity %s", entityRef.KMACEntity)
+	}
+	if serial, _ := entityRef.KMACEntity.GetProperty("serial_number"); serial != "AW-139-41" {
+		t.Errorf("Expected serial_number property, got %q", serial)
+	}
+	if capacity, _ := entityRef.KMACEntity.GetProperty("capacity"); capacity != "5" {
+		t.Errorf("Expected capacity property 5, got %q", capacity)
+	}
+	if assertions := store.FindAssertionsBySubject("E1001"); len(assertions) != 3 {
+		t.Errorf("Expected 3 assertions, got %d", len(assertions))
+	}
+
+	var loaded helicopter
+	loaded.Notes = "kept"
+	if err := Load(store, "E1001", &loaded); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.ID != "E1001" || loaded.Name != "Rescue One" || loaded.Code != "10B3TR-AIR-HEL" ||
+		loaded.Capacity != 5 || loaded.Serial != "AW-139-41" || loaded.Range != 1061.5 || loaded.Armed ||
+		!loaded.Built.Equal(built) || loaded.Operator != "E2001" || strings.Join(loaded.Missions, ",") != "E3001,E3002" {
+		t.Errorf("Unexpected loaded struct %+v", loaded)
+	}
+	if loaded.Notes != "kept" {
+		t.Errorf("Expected untagged field to be left alone, got %q", loaded.Notes)
+	}
+
+	// Storing again changes nothing; storing a change replaces what changed
+	if _, err := Store(store, original); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if assertions := store.FindAssertionsBySubject("E1001"); len(assertions) != 3 {
+		t.Errorf("Expected storing again to keep 3 assertions, got %d", len(assertions))
+	}
+	original.Operator = "E2002"
+	original.Missions = []string{"E3002", "E3003"}
+	original.Code = "10B3TR-AIR-MED"
+	if _, err := Store(store, *original); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if err := Load(store, "E1001", &loaded); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.Operator != "E2002" || strings.Join(loaded.Missions, ",") != "E3002,E3003" || loaded.Code != "10B3TR-AIR-MED" {
+		t.Errorf("Unexpected loaded struct after change %+v", loaded)
+	}
+}
+
+func TestMappingErrors(t *testing.T) {
+	store := newStore(t)
+	type noID struct {
+		Name string `kmac:"label"`
+	}
+	type badProperty struct {
+		ID   string   `kmac:"id"`
+		Crew []string `kmac:"property"`
+	}
+	type badRelation struct {
+		ID       string `kmac:"id"`
+		Operator int    `kmac:"relation:R1001"`
+	}
+	type unnamedRelation struct {
+		ID       string `kmac:"id"`
+		Operator string `kmac:"relation"`
+	}
+	type unknownTag struct {
+		ID string `kmac:"identifier"`
+	}
+	type twoIDs struct {
+		ID    string `kmac:"id"`
+		Other string `kmac:"id"`
+	}
+	for _, v := range []interface{}{noID{}, badProperty{ID: "E1001"}, badRelation{ID: "E1001"},
+		unnamedRelation{ID: "E1001"}, unknownTag{ID: "E1001"}, twoIDs{ID: "E1001"}, "E1001", &helicopter{}} {
+		if _, err := Store(store, v); err == nil {
+			t.Errorf("Expected Store(%T) to fail", v)
+		}
+	}
+
+	if err := Load(store, "E2001", helicopter{}); err == nil {
+		t.Error("Expected Load into a non-pointer to fail")
+	}
+	if err := Load(store, "E9999", &helicopter{}); err == nil {
+		t.Error("Expected Load of an unknown entity to fail")
+	}
+
+	type operated struct {
+		ID        string `kmac:"id"`
+		Operators string `kmac:"relation:R1002"`
+	}
+	if _, err := Store(store, &helicopter{ID: "E1001", Missions: []string{"E3001", "E3002"}}); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if err := Load(store, "E1001", &operated{}); err == nil {
+		t.Error("Expected Load of several objects into a string field to fail")
+	}
+}
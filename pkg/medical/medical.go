@@ -0,0 +1,116 @@
+// Package medical links TOSID medical-supply and condition categories to
+// ICD-10 and SNOMED CT identifiers, with lookups in both directions.
+//
+// No terminology content is bundled: deployments load the mapping they are
+// licensed for from a mapping file.
+package medical
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/ha1tch/tosid-go/pkg/codemap"
+)
+
+// Mapping links TOSID codes to ICD-10 and SNOMED CT identifiers
+type Mapping struct {
+	ICD10  *codemap.Table
+	SNOMED *codemap.Table
+}
+
+// Codes are the external identifiers of one TOSID code
+type Codes struct {
+	ICD10  []codemap.Entry
+	SNOMED []codemap.Entry
+}
+
+// mappingFile is the JSON form of a mapping
+type mappingFile struct {
+	ICD10  []codemap.Entry `json:"icd10,omitempty"`
+	SNOMED []codemap.Entry `json:"snomed,omitempty"`
+}
+
+// NewMapping creates an empty mapping
+func NewMapping() *Mapping {
+	return &Mapping{
+		ICD10:  codemap.NewTable(ICD10),
+		SNOMED: codemap.NewTable(SNOMED),
+	}
+}
+
+// Load reads a mapping file with icd10 and snomed entry lists
+func Load(r io.Reader) (*Mapping, error) {
+	var file mappingFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to decode medical mapping: %v", err)
+	}
+	mapping := NewMapping()
+	for _, entry := range file.ICD10 {
+		if err := mapping.ICD10.Add(entry.Code, entry.TOSID, entry.Label); err != nil {
+			return nil, err
+		}
+	}
+	for _, entry := range file.SNOMED {
+		if err := mapping.SNOMED.Add(entry.Code, entry.TOSID, entry.Label); err != nil {
+			return nil, err
+		}
+	}
+	return mapping, nil
+}
+
+// Save writes the mapping as an indented mapping file
+func (m *Mapping) Save(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(mappingFile{ICD10: m.ICD10.Entries(), SNOMED: m.SNOMED.Entries()})
+}
+
+// FromICD10 returns the TOSID mapping of an ICD-10 code or its category
+func (m *Mapping) FromICD10(code string) (codemap.Entry, error) {
+	return m.ICD10.ToTOSID(code)
+}
+
+// FromSNOMED returns the TOSID mapping of a SNOMED CT concept
+func (m *Mapping) FromSNOMED(code string) (codemap.Entry, error) {
+	return m.SNOMED.ToTOSID(code)
+}
+
+// CodesFor returns the ICD-10 and SNOMED CT identifiers mapped to a TOSID code
+func (m *Mapping) CodesFor(tosidCode string) (Codes, error) {
+	icd10, err := m.ICD10.FromTOSID(tosidCode)
+	if err != nil {
+		return Codes{}, err
+	}
+	snomed, err := m.SNOMED.FromTOSID(tosidCode)
+	if err != nil {
+		return Codes{}, err
+	}
+	return Codes{ICD10: icd10, SNOMED: snomed}, nil
+}
+
+// CodesForPattern returns the identifiers mapped to every TOSID code matching
+// a pattern, such as all medical supplies
+func (m *Mapping) CodesForPattern(pattern string) Codes {
+	return Codes{ICD10: m.ICD10.FromTOSIDPattern(pattern), SNOMED: m.SNOMED.FromTOSIDPattern(pattern)}
+}
+
+// SNOMEDForICD10 returns the SNOMED CT concepts sharing a TOSID code with an
+// ICD-10 code
+func (m *Mapping) SNOMEDForICD10(code string) ([]codemap.Entry, error) {
+	entry, err := m.ICD10.ToTOSID(code)
+	if err != nil {
+		return nil, err
+	}
+	return m.SNOMED.FromTOSID(entry.TOSID)
+}
+
+// ICD10ForSNOMED returns the ICD-10 codes sharing a TOSID code with a
+// SNOMED CT concept
+func (m *Mapping) ICD10ForSNOMED(code string) ([]codemap.Entry, error) {
+	entry, err := m.SNOMED.ToTOSID(code)
+	if err != nil {
+		return nil, err
+	}
+	return m.ICD10.FromTOSID(entry.TOSID)
+}
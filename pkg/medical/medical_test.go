@@ -0,0 +1,86 @@
+package medical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const testMapping = `{
+  "icd10": [
+    {"code": "J11", "tosid": "11B3ME-DIN-FLU", "label": "Influenza, virus not identified"},
+    {"code": "U07.1", "tosid": "11B3ME-DIN-COV", "label": "COVID-19"},
+    {"code": "A39", "tosid": "11B3ME-DIN-BAC", "label": "Meningococcal infection"}
+  ],
+  "snomed": [
+    {"code": "6142004", "tosid": "11B3ME-DIN-FLU", "label": "Influenza"},
+    {"code": "840539006", "tosid": "11B3ME-DIN-COV", "label": "COVID-19"}
+  ]
+}`
+
+func TestSchemes(t *testing.T) {
+	for input, want := range map[string]string{"j11.1": "J11.1", "U071": "U07.1", "A39": "A39"} {
+		if got, err := ICD10.Normalize(input); err != nil || got != want {
+			t.Errorf("ICD10 Normalize(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	for _, bad := range []string{"11J", "J1", "J11.12345"} {
+		if _, err := ICD10.Normalize(bad); err == nil {
+			t.Errorf("Expected ICD-10 error for %q", bad)
+		}
+	}
+	for _, code := range []string{"6142004", "840539006", "38341003", "73211009"} {
+		if _, err := SNOMED.Normalize(code); err != nil {
+			t.Errorf("Expected SNOMED %s to be valid: %v", code, err)
+		}
+	}
+	for _, bad := range []string{"6142005", "12345", "0614200"} {
+		if _, err := SNOMED.Normalize(bad); err == nil {
+			t.Errorf("Expected SNOMED error for %q", bad)
+		}
+	}
+}
+
+func TestMapping(t *testing.T) {
+	mapping, err := Load(strings.NewReader(testMapping))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	entry, err := mapping.FromICD10("J11.1")
+	if err != nil || entry.TOSID != "11B3ME-DIN-FLU" || entry.Code != "J11" {
+		t.Errorf("Expected J11.1 to fall back to J11, got %+v, %v", entry, err)
+	}
+	if entry, err := mapping.FromSNOMED("840539006"); err != nil || entry.TOSID != "11B3ME-DIN-COV" {
+		t.Errorf("Unexpected SNOMED lookup: %+v, %v", entry, err)
+	}
+
+	codes, err := mapping.CodesFor("11B3ME-DIN-FLU")
+	if err != nil || len(codes.ICD10) != 1 || len(codes.SNOMED) != 1 || codes.SNOMED[0].Code != "6142004" {
+		t.Errorf("Unexpected codes: %+v, %v", codes, err)
+	}
+	if codes := mapping.CodesForPattern("11B-3ME-DIN"); len(codes.ICD10) != 3 || len(codes.SNOMED) != 2 {
+		t.Errorf("Unexpected pattern codes: %+v", codes)
+	}
+
+	snomed, err := mapping.SNOMEDForICD10("U07.1")
+	if err != nil || len(snomed) != 1 || snomed[0].Code != "840539006" {
+		t.Errorf("Unexpected cross lookup: %+v, %v", snomed, err)
+	}
+	icd10, err := mapping.ICD10ForSNOMED("6142004")
+	if err != nil || len(icd10) != 1 || icd10[0].Code != "J11" {
+		t.Errorf("Unexpected cross lookup: %+v, %v", icd10, err)
+	}
+	if _, err := mapping.FromICD10("Z99"); err == nil {
+		t.Error("Expected error for unmapped ICD-10 code")
+	}
+
+	var buf bytes.Buffer
+	if err := mapping.Save(&buf); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	reloaded, err := Load(&buf)
+	if err != nil || len(reloaded.ICD10.Entries()) != 3 || len(reloaded.SNOMED.Entries()) != 2 {
+		t.Errorf("Round trip failed: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package medical
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/ha1tch/tosid-go/pkg/codemap"
+)
+
+// ICD10 is the WHO International Classification of Diseases, 10th revision.
+// Codes are normalized to upper case with a dot after the category, and
+// lookups fall back from a subcategory to its category.
+var ICD10 = codemap.Scheme{
+	Name:      "ICD10",
+	Normalize: normalizeICD10,
+	Broader:   broaderICD10,
+}
+
+// SNOMED is SNOMED CT. Concept identifiers are checked against their
+// Verhoeff check digit.
+var SNOMED = codemap.Scheme{
+	Name:      "SNOMED",
+	Normalize: normalizeSNOMED,
+}
+
+func init() {
+	codemap.RegisterScheme(ICD10)
+	codemap.RegisterScheme(SNOMED)
+}
+
+var icd10Pattern = regexp.MustCompile(`^[A-Z][0-9][0-9A-Z](\.[0-9A-Z]{1,4})?$`)
+
+// normalizeICD10 upper-cases a code and inserts the dot when it is missing
+func normalizeICD10(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) > 3 && !strings.Contains(code, ".") {
+		code = code[:3] + "." + code[3:]
+	}
+	if !icd10Pattern.MatchString(code) {
+		return "", errors.New("ICD-10 codes look like A00 or A00.0")
+	}
+	return code, nil
+}
+
+// broaderICD10 drops the last character of a subcategory, then the dot
+func broaderICD10(code string) string {
+	if len(code) <= 3 {
+		return ""
+	}
+	code = code[:len(code)-1]
+	return strings.TrimSuffix(code, ".")
+}
+
+// normalizeSNOMED checks that a concept identifier is 6 to 18 digits with a
+// valid Verhoeff check digit
+func normalizeSNOMED(code string) (string, error) {
+	code = strings.TrimSpace(code)
+	if len(code) < 6 || len(code) > 18 || code[0] == '0' {
+		return "", errors.New("SNOMED CT identifiers have 6 to 18 digits and no leading zero")
+	}
+	for i := 0; i < len(code); i++ {
+		if code[i] < '0' || code[i] > '9' {
+			return "", errors.New("SNOMED CT identifiers contain only digits")
+		}
+	}
+	if !verhoeffValid(code) {
+		return "", errors.New("invalid check digit")
+	}
+	return code, nil
+}
+
+// Verhoeff dihedral group tables
+var (
+	verhoeffD = [10][10]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 2, 3, 4, 0, 6, 7, 8, 9, 5},
+		{2, 3, 4, 0, 1, 7, 8, 9, 5, 6},
+		{3, 4, 0, 1, 2, 8, 9, 5, 6, 7},
+		{4, 0, 1, 2, 3, 9, 5, 6, 7, 8},
+		{5, 9, 8, 7, 6, 0, 4, 3, 2, 1},
+		{6, 5, 9, 8, 7, 1, 0, 4, 3, 2},
+		{7, 6, 5, 9, 8, 2, 1, 0, 4, 3},
+		{8, 7, 6, 5, 9, 3, 2, 1, 0, 4},
+		{9, 8, 7, 6, 5, 4, 3, 2, 1, 0},
+	}
+	verhoeffP = [8][10]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 5, 7, 6, 2, 8, 3, 0, 9, 4},
+		{5, 8, 0, 3, 7, 9, 6, 1, 4, 2},
+		{8, 9, 1, 6, 0, 4, 3, 5, 2, 7},
+		{9, 4, 5, 3, 1, 2, 6, 8, 7, 0},
+		{4, 2, 8, 6, 5, 7, 3, 9, 0, 1},
+		{2, 7, 9, 3, 8, 0, 6, 4, 1, 5},
+		{7, 0, 4, 6, 9, 1, 3, 2, 5, 8},
+	}
+)
+
+// verhoeffValid reports whether a digit string ends in its Verhoeff check digit
+func verhoeffValid(digits string) bool {
+	c := 0
+	for i := 0; i < len(digits); i++ {
+		digit := int(digits[len(digits)-1-i] - '0')
+		c = verhoeffD[c][verhoeffP[i%8][digit]]
+	}
+	return c == 0
+}
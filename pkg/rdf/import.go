@@ -0,0 +1,322 @@
+package rdf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+)
+
+// IRIProperty is the entity property holding the IRI an entity was imported from
+const IRIProperty = "iri"
+
+// Result summarizes an import
+type Result struct {
+	Entities   int               // Entities added
+	Relations  int               // Relations added
+	Assertions int               // Assertions added
+	Properties int               // Property values set
+	EntityIDs  map[string]string // Entity ID of every imported resource, by IRI or _:label
+	Unmapped   map[string]int    // Triples skipped for lack of a mapping, by predicate
+}
+
+// Import reads N-Triples and adds them to a store
+func Import(store *semantic.SemanticStore, r io.Reader, mapping *Mapping) (*Result, error) {
+	return ImportContext(context.Background(), store, r, mapping)
+}
+
+// ImportContext reads N-Triples and adds them to a store, stopping early if
+// ctx is done
+func ImportContext(ctx context.Context, store *semantic.SemanticStore, r io.Reader, mapping *Mapping) (*Result, error) {
+	triples, err := ParseNTriples(r)
+	if err != nil {
+		return nil, err
+	}
+	return ImportTriplesContext(ctx, store, triples, mapping)
+}
+
+// ImportTriples adds triples to a store. Every subject becomes an entity, as
+// does every object of a mapped relation; its TOSID comes from the first
+// matching rule and its label from the preferred label predicate, falling
+// back to the IRI's local name. Resources already imported into the store,
+// recognized by their iri property, are reused rather than duplicated.
+func ImportTriples(store *semantic.SemanticStore, triples []Triple, mapping *Mapping) (*Result, error) {
+	return ImportTriplesContext(context.Background(), store, triples, mapping)
+}
+
+// ImportTriplesContext adds triples to a store, stopping early if ctx is
+// done. What was added before it stopped stays in the store.
+func ImportTriplesContext(ctx context.Context, store *semantic.SemanticStore, triples []Triple, mapping *Mapping) (*Result, error) {
+	if mapping.relations == nil {
+		if err := mapping.compile(); err != nil {
+			return nil, err
+		}
+	}
+	imp := &importer{
+		store:   store,
+		mapping: mapping,
+		result:  &Result{EntityIDs: make(map[string]string), Unmapped: make(map[string]int)},
+		known:   make(map[string]string),
+		taken:   make(map[string]bool),
+	}
+	store.RangeEntities(func(entityRef *semantic.EntityReference) bool {
+		imp.taken[entityRef.KMACEntity.ID()] = true
+		if iri, ok := entityRef.KMACEntity.GetProperty(IRIProperty); ok {
+			imp.known[iri] = entityRef.KMACEntity.ID()
+		}
+		return true
+	})
+	return imp.run(ctx, triples)
+}
+
+// importer carries the state of one import
+type importer struct {
+	store   *semantic.SemanticStore
+	mapping *Mapping
+	result  *Result
+
+	known           map[string]string // Entity ID by resource key, including earlier imports
+	taken           map[string]bool   // Entity IDs in use
+	relationIDs     map[string]string // Relation ID by predicate
+	nextRelation    int
+	nextAssertionID int
+}
+
+// resource describes a node gathered from the triples
+type resource struct {
+	key    string // IRI, or _:label for blank nodes
+	iri    string // Empty for blank nodes
+	types  map[string]bool
+	labels map[string][]Term // Label literals by predicate
+}
+
+func (imp *importer) run(ctx context.Context, triples []Triple) (*Result, error) {
+	resources := make(map[string]*resource)
+	var order []string
+	gather := func(term Term) *resource {
+		key := resourceKey(term)
+		res, exists := resources[key]
+		if !exists {
+			res = &resource{key: key, types: make(map[string]bool), labels: make(map[string][]Term)}
+			if term.Kind == IRI {
+				res.iri = term.Value
+			}
+			resources[key] = res
+			order = append(order, key)
+		}
+		return res
+	}
+
+	labelPredicates := make(map[string]bool)
+	for _, predicate := range imp.mapping.labels {
+		labelPredicates[predicate] = true
+	}
+	for _, triple := range triples {
+		subject := gather(triple.Subject)
+		predicate := triple.Predicate.Value
+		switch {
+		case predicate == RDFType && triple.Object.Kind == IRI:
+			subject.types[triple.Object.Value] = true
+		case labelPredicates[predicate] && triple.Object.Kind == Literal:
+			subject.labels[predicate] = append(subject.labels[predicate], triple.Object)
+		case triple.Object.IsResource():
+			if _, mapped := imp.mapping.relations[predicate]; mapped {
+				gather(triple.Object)
+			}
+		}
+	}
+
+	for _, key := range order {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := imp.addEntity(resources[key]); err != nil {
+			return nil, err
+		}
+	}
+
+	set := make(map[string]bool) // Entity and key pairs already given a property value
+	for _, triple := range triples {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		predicate := triple.Predicate.Value
+		if predicate == RDFType || labelPredicates[predicate] {
+			continue
+		}
+		subjectID := imp.result.EntityIDs[resourceKey(triple.Subject)]
+
+		if triple.Object.Kind == Literal {
+			key, mapped := imp.mapping.properties[predicate]
+			if !mapped {
+				imp.result.Unmapped[predicate]++
+				continue
+			}
+			if set[subjectID+"\x00"+key] {
+				continue
+			}
+			set[subjectID+"\x00"+key] = true
+			entityRef, _ := imp.store.GetEntity(subjectID)
+			entityRef.KMACEntity.SetProperty(key, triple.Object.Value)
+			imp.result.Properties++
+			continue
+		}
+
+		relation, mapped := imp.mapping.relations[predicate]
+		if !mapped {
+			imp.result.Unmapped[predicate]++
+			continue
+		}
+		relationID, err := imp.relationFor(predicate, relation)
+		if err != nil {
+			return nil, err
+		}
+		objectID := imp.result.EntityIDs[resourceKey(triple.Object)]
+		assertionID := imp.newAssertionID()
+		if err := imp.store.CreateAssertion(assertionID, subjectID, relationID, objectID); err != nil {
+			return nil, fmt.Errorf("triple %s: %v", triple, err)
+		}
+		imp.result.Assertions++
+	}
+	return imp.result, nil
+}
+
+// addEntity adds a gathered resource to the store, or reuses the entity an
+// earlier import created for it
+func (imp *importer) addEntity(res *resource) error {
+	if res.iri != "" {
+		if entityID, exists := imp.known[res.iri]; exists {
+			imp.result.EntityIDs[res.key] = entityID
+			return nil
+		}
+	}
+
+	entityID := imp.newEntityID(res.key)
+	label := imp.label(res)
+	tosidCode := imp.mapping.tosidFor(res.iri, res.types)
+	if err := imp.store.AddEntity(entityID, label, tosidCode); err != nil {
+		return fmt.Errorf("resource %s: %v", res.key, err)
+	}
+	if res.iri != "" {
+		entityRef, _ := imp.store.GetEntity(entityID)
+		entityRef.KMACEntity.SetProperty(IRIProperty, res.iri)
+		imp.known[res.iri] = entityID
+	}
+	imp.result.EntityIDs[res.key] = entityID
+	imp.result.Entities++
+	return nil
+}
+
+// label picks the first label in predicate order, preferring the mapping's
+// language, and falls back to the resource's local name
+func (imp *importer) label(res *resource) string {
+	for _, predicate := range imp.mapping.labels {
+		values := res.labels[predicate]
+		if len(values) == 0 {
+			continue
+		}
+		if imp.mapping.Language != "" {
+			for _, value := range values {
+				if strings.EqualFold(value.Language, imp.mapping.Language) {
+					return value.Value
+				}
+			}
+		}
+		return values[0].Value
+	}
+	return localName(strings.TrimPrefix(res.key, "_:"))
+}
+
+// newEntityID derives an unused entity ID from a resource's local name
+func (imp *importer) newEntityID(key string) string {
+	var sb strings.Builder
+	sb.WriteString("E")
+	for _, r := range localName(strings.TrimPrefix(key, "_:")) {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			sb.WriteRune(r)
+		}
+	}
+	base := sb.String()
+	if base == "E" {
+		base = "E_"
+	}
+
+	entityID := base
+	for n := 2; imp.taken[entityID]; n++ {
+		entityID = fmt.Sprintf("%s_%d", base, n)
+	}
+	imp.taken[entityID] = true
+	return entityID
+}
+
+// relationFor returns the relation of a mapped predicate, adding it to the
+// store on first use
+func (imp *importer) relationFor(predicate string, relation RelationMapping) (string, error) {
+	if relationID, exists := imp.relationIDs[predicate]; exists {
+		return relationID, nil
+	}
+	if imp.relationIDs == nil {
+		imp.relationIDs = make(map[string]string)
+	}
+
+	relationID := relation.Relation
+	if relationID != "" {
+		if _, err := imp.store.GetRelation(relationID); err == nil {
+			imp.relationIDs[predicate] = relationID
+			return relationID, nil
+		}
+	} else {
+		for {
+			imp.nextRelation++
+			relationID = fmt.Sprintf("R%04d", imp.nextRelation)
+			if _, err := imp.store.GetRelation(relationID); err != nil {
+				break
+			}
+		}
+	}
+
+	if err := imp.store.AddRelation(relationID, relation.Label, relation.Type); err != nil {
+		return "", fmt.Errorf("predicate %s: %v", predicate, err)
+	}
+	imp.relationIDs[predicate] = relationID
+	imp.result.Relations++
+	return relationID, nil
+}
+
+// newAssertionID returns the next assertion ID not already in the store
+func (imp *importer) newAssertionID() string {
+	for {
+		imp.nextAssertionID++
+		assertionID := fmt.Sprintf("F%04d", imp.nextAssertionID)
+		if _, err := imp.store.GetAssertion(assertionID); err != nil {
+			return assertionID
+		}
+	}
+}
+
+// resourceKey identifies a resource term across triples
+func resourceKey(term Term) string {
+	if term.Kind == Blank {
+		return "_:" + term.Value
+	}
+	return term.Value
+}
+
+// UnmappedPredicates lists the predicates skipped by an import, most frequent
+// first, as a starting point for extending the mapping
+func (r *Result) UnmappedPredicates() []string {
+	predicates := make([]string, 0, len(r.Unmapped))
+	for predicate := range r.Unmapped {
+		predicates = append(predicates, predicate)
+	}
+	sort.Slice(predicates, func(i, j int) bool {
+		if r.Unmapped[predicates[i]] != r.Unmapped[predicates[j]] {
+			return r.Unmapped[predicates[i]] > r.Unmapped[predicates[j]]
+		}
+		return predicates[i] < predicates[j]
+	})
+	return predicates
+}
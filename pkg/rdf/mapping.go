@@ -0,0 +1,199 @@
+package rdf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Well-known vocabularies
+const (
+	RDFNamespace    = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	RDFSNamespace   = "http://www.w3.org/2000/01/rdf-schema#"
+	SKOSNamespace   = "http://www.w3.org/2004/02/skos/core#"
+	SchemaNamespace = "http://schema.org/"
+	OWLNamespace    = "http://www.w3.org/2002/07/owl#"
+	XSDNamespace    = "http://www.w3.org/2001/XMLSchema#"
+
+	RDFType = RDFNamespace + "type"
+)
+
+// defaultPrefixes are available in every mapping without being declared
+var defaultPrefixes = map[string]string{
+	"rdf":    RDFNamespace,
+	"rdfs":   RDFSNamespace,
+	"skos":   SKOSNamespace,
+	"schema": SchemaNamespace,
+	"owl":    OWLNamespace,
+	"xsd":    XSDNamespace,
+}
+
+// defaultLabels are the label predicates used when a mapping declares none
+var defaultLabels = []string{"rdfs:label", "skos:prefLabel", "schema:name"}
+
+// TOSIDRule assigns a TOSID code to resources of a class or under an IRI prefix
+type TOSIDRule struct {
+	Class     string `json:"class,omitempty"`      // rdf:type the resource must have
+	IRIPrefix string `json:"iri_prefix,omitempty"` // Prefix the resource IRI must start with
+	TOSID     string `json:"tosid"`
+}
+
+// RelationMapping turns triples with a resource object into assertions
+type RelationMapping struct {
+	Predicate string `json:"predicate"`
+	Relation  string `json:"relation,omitempty"` // Relation ID; generated when empty
+	Label     string `json:"label,omitempty"`    // Defaults to the predicate's local name in upper snake case
+	Type      string `json:"type,omitempty"`     // Defaults to IMPORTED
+}
+
+// PropertyMapping turns triples with a literal object into entity properties
+type PropertyMapping struct {
+	Predicate string `json:"predicate"`
+	Key       string `json:"key,omitempty"` // Defaults to the predicate's local name
+}
+
+// Mapping describes how triples become store statements. IRIs in a mapping
+// may be written in full or compacted with a declared or well-known prefix,
+// such as schema:Person.
+type Mapping struct {
+	Prefixes   map[string]string `json:"prefixes,omitempty"`
+	TOSIDs     []TOSIDRule       `json:"tosids,omitempty"` // First matching rule wins
+	Relations  []RelationMapping `json:"relations,omitempty"`
+	Properties []PropertyMapping `json:"properties,omitempty"`
+	Labels     []string          `json:"labels,omitempty"`   // Label predicates in order of preference
+	Language   string            `json:"language,omitempty"` // Preferred language of labels
+
+	relations  map[string]RelationMapping
+	properties map[string]string
+	labels     []string
+}
+
+// LoadMapping reads a mapping file
+func LoadMapping(r io.Reader) (*Mapping, error) {
+	var mapping Mapping
+	if err := json.NewDecoder(r).Decode(&mapping); err != nil {
+		return nil, fmt.Errorf("failed to decode mapping: %v", err)
+	}
+	if err := mapping.compile(); err != nil {
+		return nil, err
+	}
+	return &mapping, nil
+}
+
+// Save writes the mapping as an indented mapping file
+func (m *Mapping) Save(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(m)
+}
+
+// Expand resolves a compact IRI against the mapping's prefixes. IRIs without
+// a known prefix are returned unchanged.
+func (m *Mapping) Expand(iri string) string {
+	prefix, local, found := strings.Cut(iri, ":")
+	if !found || strings.HasPrefix(local, "//") {
+		return iri
+	}
+	if namespace, ok := m.Prefixes[prefix]; ok {
+		return namespace + local
+	}
+	if namespace, ok := defaultPrefixes[prefix]; ok {
+		return namespace + local
+	}
+	return iri
+}
+
+// compile expands every IRI in the mapping and indexes the predicates
+func (m *Mapping) compile() error {
+	for i, rule := range m.TOSIDs {
+		if rule.TOSID == "" {
+			return fmt.Errorf("tosid rule %d has no TOSID code", i+1)
+		}
+		if rule.Class == "" && rule.IRIPrefix == "" {
+			return fmt.Errorf("tosid rule %d needs a class or an IRI prefix", i+1)
+		}
+	}
+
+	m.relations = make(map[string]RelationMapping)
+	for _, relation := range m.Relations {
+		predicate := m.Expand(relation.Predicate)
+		if predicate == "" {
+			return fmt.Errorf("relation mapping has no predicate")
+		}
+		if _, exists := m.relations[predicate]; exists {
+			return fmt.Errorf("predicate %s is mapped more than once", predicate)
+		}
+		if relation.Label == "" {
+			relation.Label = upperSnake(localName(predicate))
+		}
+		if relation.Type == "" {
+			relation.Type = "IMPORTED"
+		}
+		m.relations[predicate] = relation
+	}
+
+	m.properties = make(map[string]string)
+	for _, property := range m.Properties {
+		predicate := m.Expand(property.Predicate)
+		if predicate == "" {
+			return fmt.Errorf("property mapping has no predicate")
+		}
+		if _, exists := m.relations[predicate]; exists {
+			return fmt.Errorf("predicate %s is mapped as both a relation and a property", predicate)
+		}
+		key := property.Key
+		if key == "" {
+			key = localName(predicate)
+		}
+		m.properties[predicate] = key
+	}
+
+	labels := m.Labels
+	if len(labels) == 0 {
+		labels = defaultLabels
+	}
+	m.labels = make([]string, len(labels))
+	for i, label := range labels {
+		m.labels[i] = m.Expand(label)
+	}
+	return nil
+}
+
+// tosidFor returns the TOSID code of the first rule matching a resource
+func (m *Mapping) tosidFor(iri string, types map[string]bool) string {
+	for _, rule := range m.TOSIDs {
+		if rule.Class != "" && !types[m.Expand(rule.Class)] {
+			continue
+		}
+		if rule.IRIPrefix != "" && !strings.HasPrefix(iri, m.Expand(rule.IRIPrefix)) {
+			continue
+		}
+		return rule.TOSID
+	}
+	return ""
+}
+
+// localName returns the part of an IRI after its last '#', '/', or ':'
+func localName(iri string) string {
+	if i := strings.LastIndexAny(iri, "#/:"); i >= 0 && i < len(iri)-1 {
+		return iri[i+1:]
+	}
+	return iri
+}
+
+// upperSnake converts a camelCase or hyphenated name to UPPER_SNAKE_CASE
+func upperSnake(name string) string {
+	var sb strings.Builder
+	for i, r := range name {
+		switch {
+		case r == '-' || r == ' ' || r == '.':
+			sb.WriteByte('_')
+			continue
+		case r >= 'A' && r <= 'Z' && i > 0 && name[i-1] >= 'a' && name[i-1] <= 'z':
+			sb.WriteByte('_')
+		}
+		sb.WriteString(strings.ToUpper(string(r)))
+	}
+	return sb.String()
+}
@@ -0,0 +1,271 @@
+// Package rdf imports linked data into a semantic store. Triples are read as
+// N-Triples and mapped onto KMAC entities, relations, and assertions by a
+// mapping file that assigns TOSID codes to classes and IRIs.
+package rdf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Term kinds
+const (
+	IRI     = "iri"
+	Blank   = "blank"
+	Literal = "literal"
+)
+
+// Term is a node in a triple
+type Term struct {
+	Kind     string
+	Value    string // IRI, blank node label, or lexical form
+	Language string // Language tag of a literal, if any
+	Datatype string // Datatype IRI of a literal, if any
+}
+
+// IsResource reports whether the term names a node rather than a value
+func (t Term) IsResource() bool {
+	return t.Kind == IRI || t.Kind == Blank
+}
+
+// String renders the term in N-Triples syntax
+func (t Term) String() string {
+	switch t.Kind {
+	case IRI:
+		return "<" + t.Value + ">"
+	case Blank:
+		return "_:" + t.Value
+	}
+	s := strconv.Quote(t.Value)
+	if t.Language != "" {
+		return s + "@" + t.Language
+	}
+	if t.Datatype != "" {
+		return s + "^^<" + t.Datatype + ">"
+	}
+	return s
+}
+
+// Triple is a single RDF statement
+type Triple struct {
+	Subject   Term
+	Predicate Term
+	Object    Term
+}
+
+// String renders the triple as an N-Triples line
+func (t Triple) String() string {
+	return t.Subject.String() + " " + t.Predicate.String() + " " + t.Object.String() + " ."
+}
+
+// ParseNTriples reads N-Triples, one triple per line
+func ParseNTriples(r io.Reader) ([]Triple, error) {
+	var triples []Triple
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		triple, err := parseTriple(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		triples = append(triples, triple)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read N-Triples: %v", err)
+	}
+	return triples, nil
+}
+
+// ParseTriple parses one N-Triples line, for reading triples as a stream
+func ParseTriple(line string) (Triple, error) {
+	return parseTriple(strings.TrimSpace(line))
+}
+
+// parseTriple parses one N-Triples statement
+func parseTriple(line string) (Triple, error) {
+	p := &termParser{input: line}
+	var triple Triple
+	var err error
+
+	if triple.Subject, err = p.term(); err != nil {
+		return triple, err
+	}
+	if !triple.Subject.IsResource() {
+		return triple, errors.New("subject must be an IRI or blank node")
+	}
+	if triple.Predicate, err = p.term(); err != nil {
+		return triple, err
+	}
+	if triple.Predicate.Kind != IRI {
+		return triple, errors.New("predicate must be an IRI")
+	}
+	if triple.Object, err = p.term(); err != nil {
+		return triple, err
+	}
+
+	p.skipSpace()
+	if !strings.HasPrefix(p.rest(), ".") {
+		return triple, errors.New("expected '.' after object")
+	}
+	p.pos++
+	p.skipSpace()
+	if rest := p.rest(); rest != "" && !strings.HasPrefix(rest, "#") {
+		return triple, fmt.Errorf("unexpected text after '.': %q", rest)
+	}
+	return triple, nil
+}
+
+// termParser reads terms from a line
+type termParser struct {
+	input string
+	pos   int
+}
+
+func (p *termParser) rest() string { return p.input[p.pos:] }
+
+func (p *termParser) skipSpace() {
+	for p.pos < len(p.input) && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// term reads the next IRI, blank node, or literal
+func (p *termParser) term() (Term, error) {
+	p.skipSpace()
+	rest := p.rest()
+	switch {
+	case strings.HasPrefix(rest, "<"):
+		end := strings.IndexByte(rest, '>')
+		if end < 0 {
+			return Term{}, errors.New("unterminated IRI")
+		}
+		p.pos += end + 1
+		value, err := unescape(rest[1:end])
+		if err != nil {
+			return Term{}, err
+		}
+		return Term{Kind: IRI, Value: value}, nil
+
+	case strings.HasPrefix(rest, "_:"):
+		end := strings.IndexAny(rest, " \t")
+		if end < 0 {
+			return Term{}, errors.New("blank node must be followed by whitespace")
+		}
+		p.pos += end
+		if end == 2 {
+			return Term{}, errors.New("empty blank node label")
+		}
+		return Term{Kind: Blank, Value: rest[2:end]}, nil
+
+	case strings.HasPrefix(rest, `"`):
+		return p.literal()
+	}
+	return Term{}, fmt.Errorf("unexpected term at %q", rest)
+}
+
+// literal reads a quoted literal with its optional language tag or datatype
+func (p *termParser) literal() (Term, error) {
+	rest := p.rest()
+	end := -1
+	for i := 1; i < len(rest); i++ {
+		if rest[i] == '\\' {
+			i++
+			continue
+		}
+		if rest[i] == '"' {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return Term{}, errors.New("unterminated literal")
+	}
+	value, err := unescape(rest[1:end])
+	if err != nil {
+		return Term{}, err
+	}
+	p.pos += end + 1
+	term := Term{Kind: Literal, Value: value}
+
+	rest = p.rest()
+	switch {
+	case strings.HasPrefix(rest, "@"):
+		n := 1
+		for n < len(rest) && rest[n] != ' ' && rest[n] != '\t' && rest[n] != '.' {
+			n++
+		}
+		if n == 1 {
+			return Term{}, errors.New("empty language tag")
+		}
+		term.Language = rest[1:n]
+		p.pos += n
+	case strings.HasPrefix(rest, "^^"):
+		p.pos += 2
+		datatype, err := p.term()
+		if err != nil {
+			return Term{}, err
+		}
+		if datatype.Kind != IRI {
+			return Term{}, errors.New("datatype must be an IRI")
+		}
+		term.Datatype = datatype.Value
+	}
+	return term, nil
+}
+
+// unescape resolves N-Triples string and IRI escapes
+func unescape(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			sb.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i >= len(s) {
+			return "", errors.New("trailing backslash")
+		}
+		switch s[i] {
+		case 't':
+			sb.WriteByte('\t')
+		case 'b':
+			sb.WriteByte('\b')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'f':
+			sb.WriteByte('\f')
+		case '"', '\'', '\\':
+			sb.WriteByte(s[i])
+		case 'u', 'U':
+			size := 4
+			if s[i] == 'U' {
+				size = 8
+			}
+			if i+1+size > len(s) {
+				return "", fmt.Errorf("short \\%c escape", s[i])
+			}
+			code, err := strconv.ParseUint(s[i+1:i+1+size], 16, 32)
+			if err != nil {
+				return "", fmt.Errorf("invalid \\%c escape: %v", s[i], err)
+			}
+			sb.WriteRune(rune(code))
+			i += size
+		default:
+			return "", fmt.Errorf("unknown escape \\%c", s[i])
+		}
+	}
+	return sb.String(), nil
+}
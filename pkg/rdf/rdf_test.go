@@ -0,0 +1,150 @@
+package rdf
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+)
+
+const testTriples = `# Space programme sample
+<http://example.org/nasa> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://schema.org/GovernmentOrganization> .
+<http://example.org/nasa> <http://www.w3.org/2000/01/rdf-schema#label> "NASA"@en .
+<http://example.org/nasa> <http://www.w3.org/2000/01/rdf-schema#label> "Agence spatiale"@fr .
+<http://example.org/apollo11> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://schema.org/Vehicle> .
+<http://example.org/apollo11> <http://schema.org/name> "Apollo \"11\"" .
+<http://example.org/apollo11> <http://schema.org/operator> <http://example.org/nasa> .
+<http://example.org/apollo11> <http://schema.org/launchDate> "1969-07-16"^^<http://www.w3.org/2001/XMLSchema#date> .
+<http://example.org/apollo11> <http://schema.org/crew> _:armstrong .
+_:armstrong <http://schema.org/name> "Neil Armstrong" .
+<http://example.org/apollo11> <http://schema.org/color> "white" .
+`
+
+const testMapping = `{
+  "prefixes": {"ex": "http://example.org/"},
+  "tosids": [
+    {"class": "schema:GovernmentOrganization", "tosid": "10C1OR-GOV-USA"},
+    {"iri_prefix": "ex:apollo", "tosid": "10B3TR-AIR-JET"}
+  ],
+  "relations": [
+    {"predicate": "schema:operator", "relation": "R1001", "label": "OPERATED_BY"},
+    {"predicate": "schema:crew"}
+  ],
+  "properties": [
+    {"predicate": "schema:launchDate", "key": "launch_date"}
+  ],
+  "language": "en"
+}`
+
+func TestParseNTriples(t *testing.T) {
+	triples, err := ParseNTriples(strings.NewReader(testTriples))
+	if err != nil {
+		t.Fatalf("ParseNTriples failed: %v", err)
+	}
+	if len(triples) != 10 {
+		t.Fatalf("Expected 10 triples, got %d", len(triples))
+	}
+	if triples[2].Object.Language != "fr" || triples[2].Object.Value != "Agence spatiale" {
+		t.Errorf("Unexpected language literal: %+v", triples[2].Object)
+	}
+	if triples[4].Object.Value != `Apollo "11"` {
+		t.Errorf("Unexpected escaped literal: %q", triples[4].Object.Value)
+	}
+	if triples[6].Object.Datatype != XSDNamespace+"date" {
+		t.Errorf("Unexpected datatype: %+v", triples[6].Object)
+	}
+	if triples[7].Object.Kind != Blank || triples[7].Object.Value != "armstrong" {
+		t.Errorf("Unexpected blank node: %+v", triples[7].Object)
+	}
+
+	for _, bad := range []string{
+		`<http://a> <http://b> <http://c>`,
+		`"literal" <http://b> <http://c> .`,
+		`<http://a> _:b <http://c> .`,
+		`<http://a> <http://b> "unterminated .`,
+		`<http://a> <http://b> "\u00" .`,
+	} {
+		if _, err := ParseNTriples(strings.NewReader(bad)); err == nil {
+			t.Errorf("Expected error for %s", bad)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	mapping, err := LoadMapping(strings.NewReader(testMapping))
+	if err != nil {
+		t.Fatalf("LoadMapping failed: %v", err)
+	}
+	store := semantic.NewSemanticStore()
+	result, err := Import(store, strings.NewReader(testTriples), mapping)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	if result.Entities != 3 || result.Relations != 2 || result.Assertions != 2 || result.Properties != 1 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if result.Unmapped[SchemaNamespace+"color"] != 1 {
+		t.Errorf("Expected schema:color to be unmapped, got %v", result.UnmappedPredicates())
+	}
+
+	nasa, err := store.GetEntity(result.EntityIDs["http://example.org/nasa"])
+	if err != nil {
+		t.Fatalf("NASA not imported: %v", err)
+	}
+	if nasa.KMACEntity.Label() != "NASA" || nasa.TOSIDObj == nil || !nasa.TOSIDObj.MatchesPattern("10C-1OR") {
+		t.Errorf("Unexpected NASA entity: %s %v", nasa.KMACEntity.Label(), nasa.TOSIDObj)
+	}
+
+	apollo, _ := store.GetEntity(result.EntityIDs["http://example.org/apollo11"])
+	if apollo.KMACEntity.ID() != "Eapollo11" || apollo.KMACEntity.Label() != `Apollo "11"` {
+		t.Errorf("Unexpected Apollo entity: %s %s", apollo.KMACEntity.ID(), apollo.KMACEntity.Label())
+	}
+	if date, _ := apollo.KMACEntity.GetProperty("launch_date"); date != "1969-07-16" {
+		t.Errorf("Expected launch date property, got %q", date)
+	}
+
+	crew, _ := store.GetEntity(result.EntityIDs["_:armstrong"])
+	if crew.KMACEntity.Label() != "Neil Armstrong" || crew.TOSIDObj != nil {
+		t.Errorf("Unexpected crew entity: %s %v", crew.KMACEntity.Label(), crew.TOSIDObj)
+	}
+
+	operated := store.FindAssertionsByRelation("R1001")
+	if len(operated) != 1 || operated[0].Subject() != apollo.KMACEntity.ID() || operated[0].Object() != nasa.KMACEntity.ID() {
+		t.Errorf("Unexpected operator assertions: %v", operated)
+	}
+	relation, err := store.GetRelation("R0001")
+	if err != nil || relation.Label() != "CREW" || relation.RelationType() != "IMPORTED" {
+		t.Errorf("Expected generated CREW relation, got %v (%v)", relation, err)
+	}
+
+	// Importing again reuses the entities and relations already in the store
+	again, err := Import(store, strings.NewReader(testTriples), mapping)
+	if err != nil {
+		t.Fatalf("Second import failed: %v", err)
+	}
+	if again.Entities != 1 || again.Relations != 1 || again.EntityIDs["http://example.org/nasa"] != nasa.KMACEntity.ID() {
+		t.Errorf("Expected only the blank node and generated relation to be added again, got %+v", again)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ImportContext(ctx, semantic.NewSemanticStore(), strings.NewReader(testTriples), mapping); err != context.Canceled {
+		t.Errorf("Expected context.Canceled from a cancelled import, got %v", err)
+	}
+}
+
+func TestMappingErrors(t *testing.T) {
+	for _, bad := range []string{
+		`{"tosids": [{"class": "schema:Thing"}]}`,
+		`{"tosids": [{"tosid": "10C1OR-GOV-USA"}]}`,
+		`{"relations": [{"predicate": "schema:a"}, {"predicate": "http://schema.org/a"}]}`,
+		`{"relations": [{"predicate": "schema:a"}], "properties": [{"predicate": "schema:a"}]}`,
+		`not json`,
+	} {
+		if _, err := LoadMapping(strings.NewReader(bad)); err == nil {
+			t.Errorf("Expected error for %s", bad)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Package replica keeps follower stores up to date with a store served over
+// HTTP, for hub-and-spoke replication between headquarters and field stores.
+//
+// A follower pulls the changes the source recorded after the last one it
+// applied, from the source's /changes endpoint, and applies them in order.
+// Pulls are idempotent, so one that fails part way can simply be repeated.
+// A new follower starts from a snapshot of the source loaded along with the
+// snapshot's cursor, or from nothing if the source's feed is complete.
+package replica
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+	"github.com/ha1tch/tosid-go/pkg/server"
+)
+
+// Client pulls changes from a store served by server.Server
+type Client struct {
+	baseURL string
+	http    *http.Client
+	limit   int
+}
+
+// NewClient creates a client for the server at baseURL, such as
+// "https://hq.example.org:8080". A nil httpClient uses http.DefaultClient.
+func NewClient(baseURL string, httpClient *http.Client) (*Client, error) {
+	parsed, err := url.Parse(baseURL)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid source URL %q", baseURL)
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{baseURL: strings.TrimSuffix(baseURL, "/"), http: httpClient}, nil
+}
+
+// SetPageSize sets how many changes each request asks for; 0 leaves it to
+// the server
+func (c *Client) SetPageSize(limit int) {
+	c.limit = limit
+}
+
+// Source returns the name a follower records the source's cursor under
+func (c *Client) Source() string {
+	return c.baseURL
+}
+
+// Fetch requests the changes made after a cursor, one page at a time. It
+// returns semantic.ErrCursorExpired if the source no longer has them.
+func (c *Client) Fetch(ctx context.Context, cursor string) (*server.ChangesResponse, error) {
+	query := url.Values{}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	if c.limit > 0 {
+		query.Set("limit", strconv.Itoa(c.limit))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/changes?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch changes: %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusGone:
+		return nil, semantic.ErrCursorExpired
+	default:
+		return nil, fmt.Errorf("failed to fetch changes: %v", responseError(resp))
+	}
+
+	var page server.ChangesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("failed to decode changes: %v", err)
+	}
+	return &page, nil
+}
+
+// Pull applies the changes the source has made since the follower last
+// pulled, until it has caught up, and returns how many it applied. The
+// follower must not be used by anything else meanwhile; a follower that is
+// being served should Fetch pages and apply them within server.Update.
+func (c *Client) Pull(ctx context.Context, follower *semantic.SemanticStore) (int, error) {
+	applied := 0
+	for {
+		page, err := c.Fetch(ctx, follower.SyncCursor(c.Source()))
+		if err != nil {
+			return applied, err
+		}
+		if err := follower.ApplyChanges(c.Source(), page.Changes); err != nil {
+			return applied, err
+		}
+		applied += len(page.Changes)
+		if !page.More {
+			return applied, nil
+		}
+	}
+}
+
+// Export loads everything the source holds into a new store, from its
+// /export endpoint, such as to refresh a read-only mirror
+func (c *Client) Export(ctx context.Context) (*semantic.SemanticStore, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/export", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/x-kmac")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch export: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch export: %v", responseError(resp))
+	}
+
+	store := semantic.NewSemanticStore()
+	if err := store.LoadKMAC(resp.Body); err != nil {
+		return nil, fmt.Errorf("failed to load export: %v", err)
+	}
+	return store, nil
+}
+
+// responseError describes a failed response by its status and the error
+// the server gave
+func responseError(resp *http.Response) error {
+	var failure struct {
+		Error string `json:"error"`
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if json.Unmarshal(body, &failure) != nil || failure.Error == "" {
+		failure.Error = strings.TrimSpace(string(body))
+	}
+	return fmt.Errorf("%s: %s", resp.Status, failure.Error)
+}
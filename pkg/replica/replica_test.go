@@ -0,0 +1,95 @@
+package replica
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+	"github.com/ha1tch/tosid-go/pkg/server"
+)
+
+func TestPull(t *testing.T) {
+	hub := semantic.NewSemanticStore()
+	hub.AddEntity("E1001", "Headquarters", "")
+	if err := hub.EnableChangeFeed(3); err != nil {
+		t.Fatalf("EnableChangeFeed failed: %v", err)
+	}
+	snapshot := hub.ReadSnapshot()
+	hub.AddEntity("E1002", "Field_Post", "")
+	hub.AddRelation("R1001", "reports_to", "HIERARCHICAL")
+	hub.CreateAssertion("F1001", "E1002", "R1001", "E1001")
+	srv := httptest.NewServer(server.New(hub))
+	defer srv.Close()
+
+	client, err := NewClient(srv.URL, nil)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	client.SetPageSize(2)
+	ctx := context.Background()
+
+	// The follower starts from the snapshot taken before the changes
+	follower := semantic.NewSemanticStore()
+	var buf bytes.Buffer
+	snapshot.WriteKMAC(&buf)
+	follower.LoadKMAC(&buf)
+	follower.SetSyncCursor(client.Source(), snapshot.Cursor())
+
+	applied, err := client.Pull(ctx, follower)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if applied != 3 {
+		t.Errorf("Expected 3 changes applied, got %d", applied)
+	}
+	if stats := follower.GetStatistics(); stats["entities"] != 2 || stats["assertions"] != 1 {
+		t.Errorf("Expected the follower caught up, got %v", stats)
+	}
+	if applied, err := client.Pull(ctx, follower); err != nil || applied != 0 {
+		t.Errorf("Expected nothing to pull once caught up, got %d, %v", applied, err)
+	}
+
+	// Changes already applied are skipped
+	changes, _, _ := hub.ChangesSince(snapshot.Cursor(), 0)
+	if err := follower.ApplyChanges(client.Source(), changes); err != nil {
+		t.Errorf("Expected reapplying changes to be harmless, got %v", err)
+	}
+
+	hub.RemoveAssertion("F1001", "post closed")
+	if _, err := client.Pull(ctx, follower); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if _, removed := follower.GetTombstone("F1001"); !removed {
+		t.Error("Expected the removal replicated")
+	}
+
+	// The feed keeps 3 changes, so a follower from the start has fallen behind
+	if _, err := client.Pull(ctx, semantic.NewSemanticStore()); !errors.Is(err, semantic.ErrCursorExpired) {
+		t.Errorf("Expected an expired cursor, got %v", err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	hub := semantic.NewSemanticStore()
+	hub.AddEntity("E1001", "Headquarters", "")
+	hub.AddEntity("E1002", "Field_Post", "")
+	hub.AddRelation("R1001", "reports_to", "HIERARCHICAL")
+	hub.CreateAssertion("F1001", "E1002", "R1001", "E1001")
+	srv := httptest.NewServer(server.New(hub))
+	defer srv.Close()
+
+	client, err := NewClient(srv.URL, nil)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	store, err := client.Export(context.Background())
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if stats := store.GetStatistics(); stats["entities"] != 2 || stats["assertions"] != 1 {
+		t.Errorf("Expected the whole store exported, got %v", stats)
+	}
+}
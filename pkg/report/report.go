@@ -0,0 +1,154 @@
+// Package report renders semantic stores through text/template templates, so
+// teams can generate domain-specific briefings such as situation reports.
+//
+// Templates can call these helpers, all of which return results in ID order:
+//
+//	entitiesByPattern "10C5-MED"  entities whose TOSID matches a pattern
+//	entity "E1001"                a single entity, or nil
+//	assertionsFor "E1001"         live assertions with the entity as subject or object
+//	assertionsBySubject "E1001"   live assertions with the entity as subject
+//	assertionsByObject "E1001"    live assertions with the entity as object
+//	hierarchyOf "E1001"           the TOSID hierarchy levels of an entity
+//	classification "E1001"        the human-readable TOSID classification of an entity
+//	label "E1001"                 the label of an entity or relation, or the ID
+//	describe $assertion           an assertion as an English sentence
+//	weighted $assertion           an assertion's confidence scaled by its source's weight
+//	sourceWeight "SURVEY"         the reliability weight of a confidence source
+//	property "E1001" "capacity"   an entity property, or ""
+//	state "E3001" "passable"      the current value of an entity attribute, or ""
+//	stats                         the store statistics
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/template"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+)
+
+// Report is a parsed template bound to a store
+type Report struct {
+	store    *semantic.SemanticStore
+	template *template.Template
+}
+
+// New parses a report template, binding its helper functions to the store
+func New(name string, text string, store *semantic.SemanticStore) (*Report, error) {
+	tmpl, err := template.New(name).Funcs(Funcs(store)).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse report template: %v", err)
+	}
+	return &Report{store: store, template: tmpl}, nil
+}
+
+// Execute renders the report. data becomes the template's dot, e.g. the date
+// or region a briefing covers.
+func (r *Report) Execute(w io.Writer, data interface{}) error {
+	if err := r.template.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to render report: %v", err)
+	}
+	return nil
+}
+
+// Render parses and renders a report template in one step
+func Render(w io.Writer, text string, store *semantic.SemanticStore, data interface{}) error {
+	report, err := New("report", text, store)
+	if err != nil {
+		return err
+	}
+	return report.Execute(w, data)
+}
+
+// Funcs returns the report helper functions bound to a store, for use with
+// templates built by hand
+func Funcs(store *semantic.SemanticStore) template.FuncMap {
+	labels := store.Labeler()
+
+	return template.FuncMap{
+		"entitiesByPattern": func(pattern string) []*semantic.EntityReference {
+			return sortEntities(store.FindEntitiesByTOSIDPattern(pattern))
+		},
+		"entity": func(id string) *semantic.EntityReference {
+			entityRef, err := store.GetEntity(id)
+			if err != nil {
+				return nil
+			}
+			return entityRef
+		},
+		"assertionsFor": func(id string) []*kmac.Assertion {
+			return sortAssertions(store.FindAssertionsForEntity(id))
+		},
+		"assertionsBySubject": func(id string) []*kmac.Assertion {
+			return sortAssertions(store.FindAssertionsBySubject(id))
+		},
+		"assertionsByObject": func(id string) []*kmac.Assertion {
+			return sortAssertions(store.FindAssertionsByObject(id))
+		},
+		"hierarchyOf": func(id string) []string {
+			entityRef, err := store.GetEntity(id)
+			if err != nil || entityRef.TOSIDObj == nil {
+				return nil
+			}
+			return entityRef.TOSIDObj.GetHierarchy()
+		},
+		"classification": func(id string) string {
+			entityRef, err := store.GetEntity(id)
+			if err != nil || entityRef.TOSIDObj == nil {
+				return ""
+			}
+			return entityRef.TOSIDObj.ClassificationDescription()
+		},
+		"label": func(id string) string {
+			if label, ok := labels(id); ok {
+				return label
+			}
+			return id
+		},
+		"describe": func(assertion *kmac.Assertion) string {
+			return assertion.Describe(labels)
+		},
+		"weighted": func(assertion *kmac.Assertion) float64 {
+			return store.Sources().Weighted(assertion)
+		},
+		"sourceWeight": func(source string) float64 {
+			return store.Sources().Weight(source)
+		},
+		"property": func(id string, key string) string {
+			entityRef, err := store.GetEntity(id)
+			if err != nil {
+				return ""
+			}
+			value, _ := entityRef.KMACEntity.GetProperty(key)
+			return value
+		},
+		"state": func(id string, attribute string) string {
+			state, ok := store.CurrentState(id, attribute)
+			if !ok {
+				return ""
+			}
+			return state.Value()
+		},
+		"stats": func() map[string]int {
+			return store.GetStatistics()
+		},
+	}
+}
+
+// sortEntities orders entities by ID
+func sortEntities(entities []*semantic.EntityReference) []*semantic.EntityReference {
+	sort.Slice(entities, func(i, j int) bool {
+		return entities[i].KMACEntity.ID() < entities[j].KMACEntity.ID()
+	})
+	return entities
+}
+
+// sortAssertions orders assertions by ID
+func sortAssertions(assertions []*kmac.Assertion) []*kmac.Assertion {
+	sort.Slice(assertions, func(i, j int) bool {
+		return assertions[i].ID() < assertions[j].ID()
+	})
+	return assertions
+}
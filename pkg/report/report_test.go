@@ -0,0 +1,75 @@
+package report
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+)
+
+const situationReport = `SITUATION REPORT {{.Date}}
+{{range entitiesByPattern "10C"}}{{$id := .KMACEntity.ID}}- {{.KMACEntity.Label}} ({{property $id "stock"}})
+{{range assertionsBySubject $id}}  * {{describe .}}
+{{end}}{{end}}Highway passable: {{state "E3001" "passable"}}
+Levels: {{range $i, $level := hierarchyOf "E1001"}}{{if $i}} > {{end}}{{$level}}{{end}}
+Entities: {{(stats).entities}}
+`
+
+func newTestStore(t *testing.T) *semantic.SemanticStore {
+	t.Helper()
+	store := semantic.NewSemanticStore()
+	store.AddEntity("E1001", "Antibiotic_Supply", "10C5ME-DSU-PAN")
+	store.AddEntity("E1002", "Vaccine_Supply", "10C5ME-DSU-VCN")
+	store.AddEntity("E1004", "Helicopter", "10B3TR-AIR-HEL")
+	store.AddEntity("E3001", "Highway", "")
+	store.AddRelation("R1004", "TRANSPORTED_BY", "LOGISTICS_CAPABILITY")
+
+	antibiotics, _ := store.GetEntity("E1001")
+	antibiotics.KMACEntity.SetProperty("stock", "500")
+	if err := store.CreateAssertion("F1004", "E1001", "R1004", "E1004"); err != nil {
+		t.Fatalf("Failed to create assertion: %v", err)
+	}
+
+	passable, _ := kmac.NewStateAssertion("F2001", "E3001", "passable", "partial", time.Date(2025, 5, 19, 8, 0, 0, 0, time.UTC))
+	if err := store.AddStateAssertion(passable); err != nil {
+		t.Fatalf("Failed to add state: %v", err)
+	}
+	return store
+}
+
+func TestRenderSituationReport(t *testing.T) {
+	var buf bytes.Buffer
+	err := Render(&buf, situationReport, newTestStore(t), map[string]string{"Date": "2025-05-19"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	want := `SITUATION REPORT 2025-05-19
+- Antibiotic_Supply (500)
+  * Antibiotic Supply transported by Helicopter
+- Vaccine_Supply ()
+Highway passable: partial
+Levels: 10 > 10C > 10C-5ME > 10C-5ME-DSU > 10C-5ME-DSU-PAN
+Entities: 4
+`
+	if buf.String() != want {
+		t.Errorf("Unexpected report:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestReportErrors(t *testing.T) {
+	store := newTestStore(t)
+	if _, err := New("broken", "{{range}}", store); err == nil {
+		t.Error("Expected parse error")
+	}
+
+	report, err := New("missing", "{{.Missing.Field}}", store)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := report.Execute(&bytes.Buffer{}, struct{}{}); err == nil {
+		t.Error("Expected execution error")
+	}
+}
@@ -0,0 +1,126 @@
+package semantic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+// batchCheckInterval is how many batch items are applied between checks of
+// the context
+const batchCheckInterval = 256
+
+// AssertionSpec describes one assertion of a batch. An empty ID is derived
+// from the content, as CreateContentAssertion derives it.
+type AssertionSpec struct {
+	ID       string
+	Subject  string
+	Relation string
+	Object   string
+	Context  string // Tells apart otherwise identical assertions with derived IDs
+}
+
+// BatchError reports why one assertion of a batch was rejected
+type BatchError struct {
+	Index int // Position in the batch
+	ID    string
+	Err   error
+}
+
+// Error describes the rejected assertion
+func (e BatchError) Error() string {
+	return fmt.Sprintf("batch item %d (%s): %v", e.Index, e.ID, e.Err)
+}
+
+// BatchResult reports the outcome of a batch of assertions
+type BatchResult struct {
+	Processed  int // Items applied or rejected; less than the batch size if the batch was stopped
+	Created    int
+	Duplicates int // Items with derived IDs already in the store or the batch, which are skipped
+	Errors     []BatchError
+}
+
+// Err summarizes the rejected items, or returns nil if there were none
+func (r *BatchResult) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	messages := make([]string, len(r.Errors))
+	for i, batchErr := range r.Errors {
+		messages[i] = batchErr.Error()
+	}
+	return fmt.Errorf("%d of %d assertions rejected: %s", len(r.Errors), r.Processed, strings.Join(messages, "; "))
+}
+
+// CreateAssertions creates a batch of assertions. Each item is checked as
+// CreateAssertion would check it and may refer to earlier items; rejected
+// items are reported without stopping the batch. Items without an ID get
+// content-derived IDs, so importing the same batch twice creates nothing the
+// second time. Table space is reserved once and store limits are enforced
+// once at the end, so on a bounded store a batch is much faster than calling
+// CreateAssertion in a loop.
+func (s *SemanticStore) CreateAssertions(batch []AssertionSpec) *BatchResult {
+	result, _ := s.CreateAssertionsContext(context.Background(), batch)
+	return result
+}
+
+// CreateAssertionsContext creates a batch of assertions, stopping early if
+// ctx is done so that callers can shed load. Items applied before it stopped
+// are kept; the result's Processed count says where to resume.
+func (s *SemanticStore) CreateAssertionsContext(ctx context.Context, batch []AssertionSpec) (*BatchResult, error) {
+	s.own()
+	result := &BatchResult{}
+	defer s.enforceLimits("")
+
+	s.assertions.grow(len(batch))
+	seen := make(map[string]bool, len(batch))
+	var created []string // Fields of the created items, for the write-ahead log
+	var stopped error
+	for i, spec := range batch {
+		if i%batchCheckInterval == 0 {
+			if stopped = ctx.Err(); stopped != nil {
+				break
+			}
+		}
+		result.Processed++
+
+		if spec.ID == "" {
+			spec.ID = kmac.ContentAssertionID(spec.Subject, spec.Relation, spec.Object, spec.Context)
+			if seen[spec.ID] || s.hasContentAssertion(spec.ID) {
+				result.Duplicates++
+				continue
+			}
+		}
+		if seen[spec.ID] {
+			result.Errors = append(result.Errors, BatchError{Index: i, ID: spec.ID, Err: fmt.Errorf("assertion %s repeated in batch", spec.ID)})
+			continue
+		}
+		missing, err := s.checkReferences(spec.Subject, spec.Relation, spec.Object)
+		if err != nil {
+			result.Errors = append(result.Errors, BatchError{Index: i, ID: spec.ID, Err: err})
+			continue
+		}
+		assertion, err := kmac.NewAssertion(spec.ID, spec.Subject, spec.Relation, spec.Object)
+		if err != nil {
+			result.Errors = append(result.Errors, BatchError{Index: i, ID: spec.ID, Err: fmt.Errorf("failed to create assertion: %v", err)})
+			continue
+		}
+
+		if err := s.insertAssertion(assertion, missing); err != nil {
+			result.Errors = append(result.Errors, BatchError{Index: i, ID: spec.ID, Err: err})
+			continue
+		}
+		seen[spec.ID] = true
+		result.Created++
+		created = append(created, spec.ID, spec.Subject, spec.Relation, spec.Object)
+	}
+
+	if len(created) > 0 {
+		if err := s.journal(walBatch, created...); err != nil && stopped == nil {
+			stopped = err
+		}
+	}
+	return result, stopped
+}
@@ -0,0 +1,277 @@
+package semantic
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+	"github.com/ha1tch/tosid-go/pkg/tosid"
+)
+
+// Branch is a copy-on-write overlay on a store for exploring what-if scenarios.
+// Reads see the base store plus the branch's own additions; writes only touch
+// the branch until it is merged.
+type Branch struct {
+	name    string
+	base    *SemanticStore
+	overlay *SemanticStore
+	// shadowed records the base version of each assertion the branch replaced,
+	// so merges can detect assertions changed in the base since
+	shadowed map[string][3]string
+}
+
+// BranchDiff lists what a branch adds to or changes in its base store
+type BranchDiff struct {
+	AddedEntities     []string
+	AddedRelations    []string
+	AddedAssertions   []string
+	ChangedAssertions []string
+}
+
+// IsEmpty reports whether the branch has no changes
+func (d *BranchDiff) IsEmpty() bool {
+	return len(d.AddedEntities) == 0 && len(d.AddedRelations) == 0 &&
+		len(d.AddedAssertions) == 0 && len(d.ChangedAssertions) == 0
+}
+
+// Branch creates a named what-if overlay on the store
+func (s *SemanticStore) Branch(name string) *Branch {
+	return &Branch{
+		name:     name,
+		base:     s,
+		overlay:  NewSemanticStore(),
+		shadowed: make(map[string][3]string),
+	}
+}
+
+// Name returns the branch's name
+func (b *Branch) Name() string {
+	return b.name
+}
+
+// Base returns the store the branch overlays
+func (b *Branch) Base() *SemanticStore {
+	return b.base
+}
+
+// AddEntity adds a hypothetical entity to the branch. The base store's naming
+// policy applies to entities in either the branch or the base.
+func (b *Branch) AddEntity(id string, label string, tosidCode string) error {
+	if b.base.naming != nil {
+		var tosidObj *tosid.TOSID
+		if tosidCode != "" {
+			var err error
+			if tosidObj, err = tosid.Parse(tosidCode); err != nil {
+				return fmt.Errorf("failed to parse TOSID code: %v", err)
+			}
+		}
+		if err := b.base.checkNaming(id, label, tosidObj, b.overlay.entities); err != nil {
+			return err
+		}
+	}
+	return b.overlay.AddEntity(id, label, tosidCode)
+}
+
+// AddRelation adds a hypothetical relation to the branch
+func (b *Branch) AddRelation(id string, label string, relationType string) error {
+	return b.overlay.AddRelation(id, label, relationType)
+}
+
+// CreateAssertion adds a hypothetical assertion to the branch. Subjects and
+// objects may come from either the branch or the base store. Reusing the ID of
+// a base assertion replaces it within the branch.
+func (b *Branch) CreateAssertion(id string, subjectID string, relationID string, objectID string) error {
+	if err := b.checkNode(subjectID); err != nil {
+		return fmt.Errorf("subject not found: %v", err)
+	}
+	if err := b.checkNode(objectID); err != nil {
+		return fmt.Errorf("object not found: %v", err)
+	}
+
+	assertion, err := kmac.NewAssertion(id, subjectID, relationID, objectID)
+	if err != nil {
+		return fmt.Errorf("failed to create assertion: %v", err)
+	}
+
+	if row, exists := b.base.assertions.row(id); exists {
+		if _, recorded := b.shadowed[id]; !recorded {
+			b.shadowed[id] = assertionTriple(b.base.assertions, row)
+		}
+	}
+	b.overlay.assertions.put(assertion.ID(), assertion.Subject(), assertion.Relation(), assertion.Object())
+	return nil
+}
+
+// checkNode verifies that an ID refers to an entity or assertion visible in the branch
+func (b *Branch) checkNode(id string) error {
+	if b.overlay.hasNode(id) {
+		return nil
+	}
+	return b.base.checkNode(id)
+}
+
+// GetEntity retrieves an entity from the branch or its base
+func (b *Branch) GetEntity(id string) (*EntityReference, error) {
+	if entityRef, exists := b.overlay.entities[id]; exists {
+		return entityRef, nil
+	}
+	return b.base.GetEntity(id)
+}
+
+// GetRelation retrieves a relation from the branch or its base
+func (b *Branch) GetRelation(id string) (*kmac.Relation, error) {
+	if relation, exists := b.overlay.relations[id]; exists {
+		return relation, nil
+	}
+	return b.base.GetRelation(id)
+}
+
+// GetAssertion retrieves an assertion from the branch or its base
+func (b *Branch) GetAssertion(id string) (*kmac.Assertion, error) {
+	if assertion, err := b.overlay.GetAssertion(id); err == nil {
+		return assertion, nil
+	}
+	return b.base.GetAssertion(id)
+}
+
+// FindAssertionsForEntity finds all assertions in the branch where the given entity is either subject or object
+func (b *Branch) FindAssertionsForEntity(entityID string) []*kmac.Assertion {
+	return b.merge(b.base.FindAssertionsForEntity(entityID), b.overlay.FindAssertionsForEntity(entityID))
+}
+
+// FindAssertionsBySubject finds all assertions in the branch with the given subject
+func (b *Branch) FindAssertionsBySubject(subjectID string) []*kmac.Assertion {
+	return b.merge(b.base.FindAssertionsBySubject(subjectID), b.overlay.FindAssertionsBySubject(subjectID))
+}
+
+// FindAssertionsByRelation finds all assertions in the branch using the given relation
+func (b *Branch) FindAssertionsByRelation(relationID string) []*kmac.Assertion {
+	return b.merge(b.base.FindAssertionsByRelation(relationID), b.overlay.FindAssertionsByRelation(relationID))
+}
+
+// FindAssertionsByObject finds all assertions in the branch with the given object
+func (b *Branch) FindAssertionsByObject(objectID string) []*kmac.Assertion {
+	return b.merge(b.base.FindAssertionsByObject(objectID), b.overlay.FindAssertionsByObject(objectID))
+}
+
+// merge combines base and overlay results, dropping base assertions the branch replaced
+func (b *Branch) merge(base []*kmac.Assertion, overlay []*kmac.Assertion) []*kmac.Assertion {
+	var results []*kmac.Assertion
+	for _, assertion := range base {
+		if _, replaced := b.overlay.assertions.row(assertion.ID()); !replaced {
+			results = append(results, assertion)
+		}
+	}
+	return append(results, overlay...)
+}
+
+// Diff reports what the branch adds to or changes in its base store
+func (b *Branch) Diff() *BranchDiff {
+	diff := &BranchDiff{}
+	for id := range b.overlay.entities {
+		diff.AddedEntities = append(diff.AddedEntities, id)
+	}
+	for id := range b.overlay.relations {
+		diff.AddedRelations = append(diff.AddedRelations, id)
+	}
+	for row := 0; row < b.overlay.assertions.span(); row++ {
+		if !b.overlay.assertions.holds(row) {
+			continue
+		}
+		id := b.overlay.assertions.id(row)
+		if baseRow, exists := b.base.assertions.row(id); exists {
+			if assertionTriple(b.base.assertions, baseRow) != assertionTriple(b.overlay.assertions, row) {
+				diff.ChangedAssertions = append(diff.ChangedAssertions, id)
+			}
+		} else {
+			diff.AddedAssertions = append(diff.AddedAssertions, id)
+		}
+	}
+	sort.Strings(diff.AddedEntities)
+	sort.Strings(diff.AddedRelations)
+	sort.Strings(diff.AddedAssertions)
+	sort.Strings(diff.ChangedAssertions)
+	return diff
+}
+
+// Merge applies the branch's changes to its base store and empties the branch.
+// It fails without changing anything if the base has since gained a different
+// entity or assertion with the same ID, or changed an assertion the branch replaced.
+func (b *Branch) Merge() error {
+	// The base's statements the overlay replaces are read first, so the
+	// conflict checks see them
+	for id := range b.overlay.entities {
+		if err := b.base.thawStatement(id); err != nil {
+			return err
+		}
+	}
+	for row := 0; row < b.overlay.assertions.span(); row++ {
+		if b.overlay.assertions.holds(row) {
+			if err := b.base.thawStatement(b.overlay.assertions.id(row)); err != nil {
+				return err
+			}
+		}
+	}
+	for id, entityRef := range b.overlay.entities {
+		if existing, exists := b.base.entities[id]; exists {
+			if existing.KMACEntity.Label() != entityRef.KMACEntity.Label() ||
+				existing.KMACEntity.TOSIDType() != entityRef.KMACEntity.TOSIDType() {
+				return fmt.Errorf("merge conflict: entity %s was added to the base with different content", id)
+			}
+		}
+		if err := b.base.checkNaming(id, entityRef.KMACEntity.Label(), entityRef.TOSIDObj, b.overlay.entities); err != nil {
+			return fmt.Errorf("merge conflict: %v", err)
+		}
+	}
+	for row := 0; row < b.overlay.assertions.span(); row++ {
+		if !b.overlay.assertions.holds(row) {
+			continue
+		}
+		id := b.overlay.assertions.id(row)
+		baseRow, exists := b.base.assertions.row(id)
+		before, replaced := b.shadowed[id]
+		switch {
+		case replaced && (!exists || assertionTriple(b.base.assertions, baseRow) != before):
+			return fmt.Errorf("merge conflict: assertion %s changed in the base since it was replaced", id)
+		case !replaced && exists && assertionTriple(b.base.assertions, baseRow) != assertionTriple(b.overlay.assertions, row):
+			return fmt.Errorf("merge conflict: assertion %s was added to the base with different content", id)
+		}
+	}
+
+	b.base.own()
+	for id, entityRef := range b.overlay.entities {
+		if replaced, exists := b.base.entities[id]; exists {
+			b.base.releaseTOSID(replaced.KMACEntity.TOSIDType())
+		}
+		if code := entityRef.KMACEntity.TOSIDType(); code != "" {
+			// The overlay's entities were checked as they were added
+			entityRef.TOSIDObj, _ = b.base.tosids.Intern(code)
+		}
+		b.base.entities[id] = entityRef
+		b.base.recordChanged(RecordEntity, id)
+	}
+	for id, relation := range b.overlay.relations {
+		b.base.relations[id] = relation
+		b.base.recordChanged(RecordRelation, id)
+	}
+	for row := 0; row < b.overlay.assertions.span(); row++ {
+		if !b.overlay.assertions.holds(row) {
+			continue
+		}
+		subject, relation, object := b.overlay.assertions.subject(row), b.overlay.assertions.relation(row), b.overlay.assertions.object(row)
+		id := b.overlay.assertions.id(row)
+		b.base.assertions.put(id, subject, relation, object)
+		b.base.recordChanged(RecordAssertion, id)
+		delete(b.base.retractions, id)
+		b.base.forgetDerivation(id)
+	}
+
+	b.overlay = NewSemanticStore()
+	b.shadowed = make(map[string][3]string)
+	return nil
+}
+
+// assertionTriple returns the subject, relation, and object stored at a row
+func assertionTriple(t *assertionTable, row int) [3]string {
+	return [3]string{t.subject(row), t.relation(row), t.object(row)}
+}
@@ -0,0 +1,195 @@
+package semantic
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrNoChangeFeed is returned when changes are requested from a store that
+// does not record them
+var ErrNoChangeFeed = errors.New("change feed not enabled")
+
+// ErrCursorExpired is returned when the changes after a cursor are no
+// longer in the feed, because they were dropped or the feed was restarted.
+// The follower must start over from a snapshot.
+var ErrCursorExpired = errors.New("cursor has expired")
+
+// Change is a mutation recorded in a store's change feed, in the form the
+// write-ahead log records it
+type Change struct {
+	Cursor string   `json:"cursor"` // Position of the change in the feed
+	Op     string   `json:"op"`
+	Args   []string `json:"args,omitempty"`
+}
+
+// changeFeed records the mutations of a store in order
+type changeFeed struct {
+	id       string // Random, so cursors from an earlier feed are recognized
+	changes  []Change
+	first    uint64 // Sequence number of changes[0]
+	next     uint64 // Sequence number of the next change
+	capacity int    // Most changes kept; 0 keeps them all
+}
+
+// EnableChangeFeed starts recording the store's mutations, so followers can
+// pull the changes made since a cursor with ChangesSince and apply them
+// with ApplyChanges. Only the newest capacity changes are kept, or all of
+// them if capacity is 0. Mutations made before the feed was enabled are not
+// in it; followers start from a snapshot taken since, at its cursor.
+func (s *SemanticStore) EnableChangeFeed(capacity int) error {
+	if s.feed != nil {
+		return errors.New("change feed already enabled")
+	}
+	if capacity < 0 {
+		return errors.New("change feed capacity cannot be negative")
+	}
+	id, err := newFeedID()
+	if err != nil {
+		return err
+	}
+	s.feed = &changeFeed{id: id, first: 1, next: 1, capacity: capacity}
+	return nil
+}
+
+// newFeedID generates the random ID of a change feed
+func newFeedID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate change feed ID: %v", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// ChangeCursor returns the cursor after the newest change in the feed, or
+// "" if the feed is not enabled
+func (s *SemanticStore) ChangeCursor() string {
+	if s.feed == nil {
+		return ""
+	}
+	return s.feed.cursor(s.feed.next - 1)
+}
+
+// ChangesSince returns the changes made after a cursor, oldest first, up to
+// limit of them or all of them if limit is 0, and the cursor to ask from
+// next. The empty cursor asks from the start of the feed.
+func (s *SemanticStore) ChangesSince(cursor string, limit int) ([]Change, string, error) {
+	if s.feed == nil {
+		return nil, "", ErrNoChangeFeed
+	}
+	after := uint64(0)
+	if cursor != "" {
+		id, seq, err := parseCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		if id != s.feed.id {
+			return nil, "", ErrCursorExpired
+		}
+		if seq >= s.feed.next {
+			return nil, "", fmt.Errorf("cursor %s is ahead of the feed", cursor)
+		}
+		after = seq
+	}
+	if after+1 < s.feed.first {
+		return nil, "", ErrCursorExpired
+	}
+
+	pending := s.feed.changes[after+1-s.feed.first:]
+	if limit > 0 && len(pending) > limit {
+		pending = pending[:limit]
+	}
+	changes := make([]Change, len(pending))
+	for i, change := range pending {
+		changes[i] = Change{Cursor: change.Cursor, Op: change.Op, Args: append([]string(nil), change.Args...)}
+	}
+	next := cursor
+	if len(changes) > 0 {
+		next = changes[len(changes)-1].Cursor
+	}
+	return changes, next, nil
+}
+
+// ApplyChanges redoes changes pulled from another store's feed, in order,
+// and records the cursor of each as the last applied from source. Changes
+// at or before that cursor are skipped, so applying the same changes twice
+// is harmless. Applied changes enter this store's own feed and write-ahead
+// log, so replicas can be chained; the cursors are logged too.
+func (s *SemanticStore) ApplyChanges(source string, changes []Change) error {
+	s.own()
+	for _, change := range changes {
+		id, seq, err := parseCursor(change.Cursor)
+		if err != nil {
+			return err
+		}
+		if lastID, lastSeq, err := parseCursor(s.syncCursors[source]); err == nil && lastID == id && seq <= lastSeq {
+			continue
+		}
+		if err := s.applyWALRecord(change.Op, change.Args); err != nil {
+			return fmt.Errorf("change %s: %v", change.Cursor, err)
+		}
+		s.syncCursors[source] = change.Cursor
+		if err := s.journal(walSync, source, change.Cursor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SyncCursor returns the cursor of the last change applied from a source,
+// or "" if none has been
+func (s *SemanticStore) SyncCursor(source string) string {
+	return s.syncCursors[source]
+}
+
+// SetSyncCursor records the cursor a follower has caught up with a source
+// to, such as the cursor of the snapshot it was loaded from
+func (s *SemanticStore) SetSyncCursor(source string, cursor string) error {
+	s.own()
+	if _, _, err := parseCursor(cursor); err != nil {
+		return err
+	}
+	s.syncCursors[source] = cursor
+	return s.journal(walSync, source, cursor)
+}
+
+// append records a change, dropping the oldest if the feed is full. The
+// arguments are copied, since callers may reuse them.
+func (f *changeFeed) append(op string, args []string) {
+	f.changes = append(f.changes, Change{Cursor: f.cursor(f.next), Op: op, Args: append([]string(nil), args...)})
+	f.next++
+	if f.capacity > 0 && len(f.changes) > f.capacity {
+		dropped := len(f.changes) - f.capacity
+		f.changes = f.changes[dropped:]
+		f.first += uint64(dropped)
+	}
+}
+
+// restart empties the feed under a new ID, so every cursor into it expires
+// and followers start over from a snapshot
+func (f *changeFeed) restart() error {
+	id, err := newFeedID()
+	if err != nil {
+		return err
+	}
+	f.id, f.changes, f.first, f.next = id, nil, 1, 1
+	return nil
+}
+
+// cursor formats the cursor of a sequence number
+func (f *changeFeed) cursor(seq uint64) string {
+	return f.id + ":" + strconv.FormatUint(seq, 10)
+}
+
+// parseCursor splits a cursor into its feed ID and sequence number
+func parseCursor(cursor string) (string, uint64, error) {
+	id, seqText, found := strings.Cut(cursor, ":")
+	seq, err := strconv.ParseUint(seqText, 10, 64)
+	if !found || id == "" || err != nil {
+		return "", 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return id, seq, nil
+}
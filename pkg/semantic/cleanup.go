@@ -0,0 +1,260 @@
+package semantic
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Actions a cleanup can take on an assertion with a dangling reference
+const (
+	CleanupKeep       = "KEEP"       // Report it only
+	CleanupQuarantine = "QUARANTINE" // Retract it, keeping it for provenance
+	CleanupRemove     = "REMOVE"     // Delete it from the store
+)
+
+// CleanupPolicy says how Cleanup remediates the problems ValidateStore finds
+type CleanupPolicy struct {
+	Dangling     string // Action for live assertions referencing missing nodes; empty means CleanupKeep
+	PruneOrphans bool   // Delete entities that no assertion references once the cleanup is done
+	DryRun       bool   // Report what would change without changing anything
+}
+
+// DanglingAssertion is an assertion whose subject or object does not exist
+type DanglingAssertion struct {
+	AssertionID string
+	Missing     []string // IDs of the missing subject and object
+	Cascade     bool     // Dangling only because an assertion it refers to is being removed
+}
+
+// CleanupReport lists what a cleanup found and did, or would do on a dry run
+type CleanupReport struct {
+	DryRun         bool
+	Dangling       []DanglingAssertion
+	Quarantined    []string // Assertion IDs
+	Removed        []string // Assertion IDs
+	PrunedEntities []string
+}
+
+// Lines renders the report one change per line, in the style of ValidateStore
+func (r *CleanupReport) Lines() []string {
+	verb := func(done string) string {
+		if r.DryRun {
+			return "would be " + done
+		}
+		return done
+	}
+	var lines []string
+	for _, dangling := range r.Dangling {
+		reason := "references non-existent " + strings.Join(dangling.Missing, ", ")
+		if dangling.Cascade {
+			reason = "references removed assertion " + strings.Join(dangling.Missing, ", ")
+		}
+		lines = append(lines, fmt.Sprintf("assertion %s %s", dangling.AssertionID, reason))
+	}
+	for _, id := range r.Quarantined {
+		lines = append(lines, fmt.Sprintf("assertion %s %s", id, verb("quarantined")))
+	}
+	for _, id := range r.Removed {
+		lines = append(lines, fmt.Sprintf("assertion %s %s", id, verb("removed")))
+	}
+	for _, id := range r.PrunedEntities {
+		lines = append(lines, fmt.Sprintf("entity %s %s", id, verb("pruned")))
+	}
+	return lines
+}
+
+// Cleanup remediates live assertions that reference missing entities or
+// assertions, and optionally prunes orphan entities. Quarantined assertions
+// are retracted, so they stay in the store for provenance but are hidden
+// from queries. Removing an assertion also removes the assertions about it.
+// An orphan is an entity no remaining assertion, live or retracted, refers
+// to; its properties, state history, and vector go with it. Statements in
+// cold storage or only in a storage engine are loaded into memory first.
+func (s *SemanticStore) Cleanup(policy CleanupPolicy) (*CleanupReport, error) {
+	s.own()
+	if err := s.ThawAll(); err != nil {
+		return nil, err
+	}
+	if err := s.fetchAll(context.Background()); err != nil {
+		return nil, err
+	}
+	action := policy.Dangling
+	if action == "" {
+		action = CleanupKeep
+	}
+	switch action {
+	case CleanupKeep, CleanupQuarantine, CleanupRemove:
+	default:
+		return nil, fmt.Errorf("unknown cleanup action %s", policy.Dangling)
+	}
+
+	report := &CleanupReport{DryRun: policy.DryRun}
+	removing := make(map[string]bool)
+	for row := 0; row < s.assertions.span(); row++ {
+		if !s.assertions.holds(row) {
+			continue
+		}
+		if s.assertions.isRetracted(row) {
+			continue
+		}
+		var missing []string
+		for _, id := range []string{s.assertions.subject(row), s.assertions.object(row)} {
+			if !s.hasNode(id) {
+				missing = append(missing, id)
+			}
+		}
+		if len(missing) > 0 {
+			id := s.assertions.id(row)
+			report.Dangling = append(report.Dangling, DanglingAssertion{AssertionID: id, Missing: missing})
+			if action == CleanupRemove {
+				removing[id] = true
+			}
+		}
+	}
+
+	// Removing an assertion leaves the assertions about it dangling in turn
+	for changed := action == CleanupRemove; changed; {
+		changed = false
+		for row := 0; row < s.assertions.span(); row++ {
+			if !s.assertions.holds(row) {
+				continue
+			}
+			id := s.assertions.id(row)
+			if removing[id] || s.assertions.isRetracted(row) {
+				continue
+			}
+			var missing []string
+			for _, ref := range []string{s.assertions.subject(row), s.assertions.object(row)} {
+				if removing[ref] {
+					missing = append(missing, ref)
+				}
+			}
+			if len(missing) > 0 {
+				report.Dangling = append(report.Dangling, DanglingAssertion{AssertionID: id, Missing: missing, Cascade: true})
+				removing[id] = true
+				changed = true
+			}
+		}
+	}
+	sort.SliceStable(report.Dangling, func(i, j int) bool {
+		return report.Dangling[i].AssertionID < report.Dangling[j].AssertionID
+	})
+
+	for _, dangling := range report.Dangling {
+		switch action {
+		case CleanupQuarantine:
+			report.Quarantined = append(report.Quarantined, dangling.AssertionID)
+		case CleanupRemove:
+			report.Removed = append(report.Removed, dangling.AssertionID)
+		}
+	}
+
+	if policy.PruneOrphans {
+		for _, entityID := range s.sortedEntityIDs() {
+			orphan := true
+			for _, row := range s.assertions.rowsReferencing(entityID) {
+				if !removing[s.assertions.id(row)] {
+					orphan = false
+					break
+				}
+			}
+			if orphan {
+				report.PrunedEntities = append(report.PrunedEntities, entityID)
+			}
+		}
+	}
+
+	if policy.DryRun {
+		return report, nil
+	}
+
+	done := s.nest()
+	for _, id := range report.Quarantined {
+		// Assertions derived from an earlier one are retracted along with it
+		if row, _ := s.assertions.row(id); !s.assertions.isRetracted(row) {
+			s.Retract(id, "quarantined by cleanup: dangling reference")
+		}
+	}
+	if len(report.Removed) > 0 {
+		s.removeAssertions(report.Removed)
+	}
+	for _, entityID := range report.PrunedEntities {
+		s.removeEntity(entityID)
+	}
+	done()
+	if err := s.journal(walCleanup, action, strconv.FormatBool(policy.PruneOrphans)); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// removeAssertions deletes assertions and everything recorded about them.
+// Assertions derived from them are retracted first, since their premises
+// are going away.
+func (s *SemanticStore) removeAssertions(ids []string) {
+	for _, id := range ids {
+		if row, exists := s.assertions.row(id); exists && !s.assertions.isRetracted(row) && len(s.dependents[id]) > 0 {
+			s.Retract(id, "premise removed by cleanup")
+		}
+	}
+
+	s.dropAssertions(ids)
+	for _, id := range ids {
+		s.recordChanged(RecordAssertion, id)
+	}
+}
+
+// dropAssertions deletes assertions and everything recorded about them from
+// memory
+func (s *SemanticStore) dropAssertions(ids []string) {
+	rows := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if row, exists := s.assertions.row(id); exists {
+			rows = append(rows, row)
+		}
+	}
+	s.assertions.removeRows(rows)
+
+	for _, id := range ids {
+		s.forgetDerivation(id)
+		delete(s.dependents, id)
+		delete(s.retractions, id)
+		delete(s.temporals, id)
+		s.forgetPending(id)
+		delete(s.assertionMeta, id)
+		s.assertionAccess.forget(id)
+		delete(s.assertedAt, id)
+		s.forgetSituationMember(id)
+		s.forgetEvidence(id)
+	}
+}
+
+// removeEntity deletes an entity and the data attached to it
+func (s *SemanticStore) removeEntity(id string) {
+	s.dropEntity(id)
+	s.recordChanged(RecordEntity, id)
+}
+
+// dropEntity deletes an entity and the data attached to it from memory
+func (s *SemanticStore) dropEntity(id string) {
+	if entityRef, exists := s.entities[id]; exists {
+		s.releaseTOSID(entityRef.KMACEntity.TOSIDType())
+	}
+	delete(s.entities, id)
+	delete(s.states, id)
+	s.forgetVector(id)
+	s.entityAccess.forget(id)
+}
+
+// sortedEntityIDs returns the IDs of the stored entities in order
+func (s *SemanticStore) sortedEntityIDs() []string {
+	ids := make([]string, 0, len(s.entities))
+	for id := range s.entities {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
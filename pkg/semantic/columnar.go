@@ -0,0 +1,520 @@
+package semantic
+
+import (
+	"sort"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+// symbolTable interns strings as compact numeric symbols. Each symbol counts
+// the column cells that hold it and is freed for reuse when the last goes.
+type symbolTable struct {
+	symbols map[string]uint32
+	names   []string
+	refs    []int
+	free    []uint32
+	shared  bool // Shared with a snapshot, so copied before the next write
+}
+
+// newSymbolTable creates a new symbol table
+func newSymbolTable() *symbolTable {
+	return &symbolTable{
+		symbols: make(map[string]uint32),
+	}
+}
+
+// intern returns the symbol for a string, allocating one if needed, and
+// counts one more reference to it
+func (st *symbolTable) intern(name string) uint32 {
+	st.own()
+	if sym, exists := st.symbols[name]; exists {
+		st.refs[sym]++
+		return sym
+	}
+	var sym uint32
+	if n := len(st.free); n > 0 {
+		sym = st.free[n-1]
+		st.free = st.free[:n-1]
+		st.names[sym] = name
+		st.refs[sym] = 1
+	} else {
+		sym = uint32(len(st.names))
+		st.names = append(st.names, name)
+		st.refs = append(st.refs, 1)
+	}
+	st.symbols[name] = sym
+	return sym
+}
+
+// release drops a reference to a symbol, freeing it after the last one
+func (st *symbolTable) release(sym uint32) {
+	st.own()
+	st.refs[sym]--
+	if st.refs[sym] > 0 {
+		return
+	}
+	delete(st.symbols, st.names[sym])
+	st.names[sym] = ""
+	st.free = append(st.free, sym)
+}
+
+// len returns the number of symbols in use
+func (st *symbolTable) len() int {
+	return len(st.symbols)
+}
+
+// lookup returns the symbol for a string without allocating one
+func (st *symbolTable) lookup(name string) (uint32, bool) {
+	sym, exists := st.symbols[name]
+	return sym, exists
+}
+
+// name returns the string for a symbol
+func (st *symbolTable) name(sym uint32) string {
+	return st.names[sym]
+}
+
+// clone returns a copy of the table that later interning does not affect
+func (st *symbolTable) clone() *symbolTable {
+	c := &symbolTable{
+		symbols: make(map[string]uint32, len(st.symbols)),
+		names:   append([]string(nil), st.names...),
+		refs:    append([]int(nil), st.refs...),
+		free:    append([]uint32(nil), st.free...),
+	}
+	for name, sym := range st.symbols {
+		c.symbols[name] = sym
+	}
+	return c
+}
+
+// share returns a table holding the same symbols without copying them.
+// Each copies them before it next writes, so neither sees the other's
+// changes.
+func (st *symbolTable) share() *symbolTable {
+	c := *st
+	c.shared = true
+	if !st.shared {
+		st.shared = true
+	}
+	return &c
+}
+
+// own copies the symbols the table shares before it writes to them
+func (st *symbolTable) own() {
+	if st.shared {
+		*st = *st.clone()
+	}
+}
+
+// rowIndex maps a symbol to the rows that hold it in an indexed column
+type rowIndex map[uint32][]int
+
+// add records that a row holds a symbol
+func (ix rowIndex) add(sym uint32, row int) {
+	ix[sym] = append(ix[sym], row)
+}
+
+// remove forgets that a row holds a symbol
+func (ix rowIndex) remove(sym uint32, row int) {
+	rows := ix[sym]
+	for i, r := range rows {
+		if r == row {
+			rows = append(rows[:i], rows[i+1:]...)
+			break
+		}
+	}
+	if len(rows) == 0 {
+		delete(ix, sym)
+	} else {
+		ix[sym] = rows
+	}
+}
+
+// clone returns an independent copy of the index
+func (ix rowIndex) clone() rowIndex {
+	c := make(rowIndex, len(ix))
+	for sym, rows := range ix {
+		c[sym] = append([]int(nil), rows...)
+	}
+	return c
+}
+
+// assertionTable stores assertions in struct-of-arrays form. Each column holds
+// one field for every assertion, so scans over a single field touch contiguous memory.
+// The subject, relation, and object columns are indexed for direct lookup.
+// Removed rows are left in place as holes until enough accumulate to be
+// worth compacting, so rows stay in the order their assertions were made.
+type assertionTable struct {
+	symbols     *symbolTable
+	rows        map[uint32]int // assertion ID symbol -> row index
+	ids         []uint32
+	subjects    []uint32
+	relations   []uint32
+	objects     []uint32
+	retracted   []bool
+	removed     []bool
+	hidden      int // Retracted rows
+	holes       int // Removed rows not yet compacted away
+	confidences []float64
+	sources     []uint32
+	bySubject   rowIndex
+	byRelation  rowIndex
+	byObject    rowIndex
+	shared      bool // Shared with a snapshot, so copied before the next write
+}
+
+// newAssertionTable creates an empty assertion table
+func newAssertionTable(symbols *symbolTable) *assertionTable {
+	return &assertionTable{
+		symbols:    symbols,
+		rows:       make(map[uint32]int),
+		bySubject:  make(rowIndex),
+		byRelation: make(rowIndex),
+		byObject:   make(rowIndex),
+	}
+}
+
+// clone returns an independent copy of the table over a copy of its symbols
+func (t *assertionTable) clone(symbols *symbolTable) *assertionTable {
+	c := &assertionTable{
+		symbols:     symbols,
+		rows:        make(map[uint32]int, len(t.rows)),
+		ids:         append([]uint32(nil), t.ids...),
+		subjects:    append([]uint32(nil), t.subjects...),
+		relations:   append([]uint32(nil), t.relations...),
+		objects:     append([]uint32(nil), t.objects...),
+		retracted:   append([]bool(nil), t.retracted...),
+		removed:     append([]bool(nil), t.removed...),
+		hidden:      t.hidden,
+		holes:       t.holes,
+		confidences: append([]float64(nil), t.confidences...),
+		sources:     append([]uint32(nil), t.sources...),
+		bySubject:   t.bySubject.clone(),
+		byRelation:  t.byRelation.clone(),
+		byObject:    t.byObject.clone(),
+	}
+	for sym, row := range t.rows {
+		c.rows[sym] = row
+	}
+	return c
+}
+
+// share returns a table over symbols holding the same rows without copying
+// them, the copy-on-write counterpart of clone
+func (t *assertionTable) share(symbols *symbolTable) *assertionTable {
+	c := *t
+	c.symbols = symbols
+	c.shared = true
+	if !t.shared {
+		t.shared = true
+	}
+	return &c
+}
+
+// own copies the rows the table shares before it writes to them
+func (t *assertionTable) own() {
+	if t.shared {
+		*t = *t.clone(t.symbols)
+	}
+}
+
+// put inserts or replaces an assertion row and returns its row index
+func (t *assertionTable) put(id, subject, relation, object string) int {
+	t.own()
+	idSym := t.symbols.intern(id)
+	subjectSym := t.symbols.intern(subject)
+	relationSym := t.symbols.intern(relation)
+	objectSym := t.symbols.intern(object)
+
+	if row, exists := t.rows[idSym]; exists {
+		t.unindex(row)
+		t.symbols.release(idSym)
+		t.releaseRow(row)
+		t.ids[row] = idSym
+		t.subjects[row] = subjectSym
+		t.relations[row] = relationSym
+		t.objects[row] = objectSym
+		t.setRetracted(row, false)
+		t.confidences[row] = 1.0
+		t.sources[row] = t.symbols.intern("")
+		t.index(row)
+		return row
+	}
+
+	row := len(t.ids)
+	t.rows[idSym] = row
+	t.ids = append(t.ids, idSym)
+	t.subjects = append(t.subjects, subjectSym)
+	t.relations = append(t.relations, relationSym)
+	t.objects = append(t.objects, objectSym)
+	t.retracted = append(t.retracted, false)
+	t.removed = append(t.removed, false)
+	t.confidences = append(t.confidences, 1.0)
+	t.sources = append(t.sources, t.symbols.intern(""))
+	t.index(row)
+	return row
+}
+
+// setEndpoints changes the subject and object of a row, keeping its
+// confidence, source, and retraction
+func (t *assertionTable) setEndpoints(row int, subject, object string) {
+	t.own()
+	t.unindex(row)
+	subjectSym, objectSym := t.symbols.intern(subject), t.symbols.intern(object)
+	t.symbols.release(t.subjects[row])
+	t.symbols.release(t.objects[row])
+	t.subjects[row], t.objects[row] = subjectSym, objectSym
+	t.index(row)
+}
+
+// grow reserves room for n more rows
+func (t *assertionTable) grow(n int) {
+	t.own()
+	if free := cap(t.ids) - len(t.ids); free >= n {
+		return
+	}
+	size := len(t.ids) + n
+	t.ids = append(make([]uint32, 0, size), t.ids...)
+	t.subjects = append(make([]uint32, 0, size), t.subjects...)
+	t.relations = append(make([]uint32, 0, size), t.relations...)
+	t.objects = append(make([]uint32, 0, size), t.objects...)
+	t.retracted = append(make([]bool, 0, size), t.retracted...)
+	t.removed = append(make([]bool, 0, size), t.removed...)
+	t.confidences = append(make([]float64, 0, size), t.confidences...)
+	t.sources = append(make([]uint32, 0, size), t.sources...)
+}
+
+// removeRows deletes rows. They are left as holes, skipped by lookups, until
+// holes make up half the table and it is compacted, so removing a row costs
+// amortized constant time however many rows share its subject, relation, or
+// object.
+func (t *assertionTable) removeRows(rows []int) {
+	t.own()
+	for _, row := range rows {
+		if t.removed[row] {
+			continue
+		}
+		delete(t.rows, t.ids[row])
+		t.setRetracted(row, false)
+		t.removed[row] = true
+		t.holes++
+	}
+	if t.holes > 0 && 2*t.holes >= len(t.ids) {
+		t.compact()
+	}
+}
+
+// compact closes the holes left by removed rows, keeping the remaining rows
+// in order, releases the symbols the holes held, and rebuilds the secondary
+// indexes
+func (t *assertionTable) compact() {
+	kept := 0
+	for row := range t.ids {
+		if t.removed[row] {
+			t.symbols.release(t.ids[row])
+			t.releaseRow(row)
+			continue
+		}
+		t.ids[kept] = t.ids[row]
+		t.subjects[kept] = t.subjects[row]
+		t.relations[kept] = t.relations[row]
+		t.objects[kept] = t.objects[row]
+		t.retracted[kept] = t.retracted[row]
+		t.removed[kept] = false
+		t.confidences[kept] = t.confidences[row]
+		t.sources[kept] = t.sources[row]
+		t.rows[t.ids[kept]] = kept
+		kept++
+	}
+	t.ids = t.ids[:kept]
+	t.subjects = t.subjects[:kept]
+	t.relations = t.relations[:kept]
+	t.objects = t.objects[:kept]
+	t.retracted = t.retracted[:kept]
+	t.removed = t.removed[:kept]
+	t.confidences = t.confidences[:kept]
+	t.sources = t.sources[:kept]
+	t.holes = 0
+
+	t.bySubject, t.byRelation, t.byObject = make(rowIndex), make(rowIndex), make(rowIndex)
+	for row := 0; row < kept; row++ {
+		t.index(row)
+	}
+}
+
+// releaseRow drops the references a row holds to its subject, relation,
+// object, and source symbols
+func (t *assertionTable) releaseRow(row int) {
+	t.symbols.release(t.subjects[row])
+	t.symbols.release(t.relations[row])
+	t.symbols.release(t.objects[row])
+	t.symbols.release(t.sources[row])
+}
+
+// index adds a row to the secondary indexes
+func (t *assertionTable) index(row int) {
+	t.bySubject.add(t.subjects[row], row)
+	t.byRelation.add(t.relations[row], row)
+	t.byObject.add(t.objects[row], row)
+}
+
+// unindex removes a row from the secondary indexes
+func (t *assertionTable) unindex(row int) {
+	t.bySubject.remove(t.subjects[row], row)
+	t.byRelation.remove(t.relations[row], row)
+	t.byObject.remove(t.objects[row], row)
+}
+
+// lookupRows returns the rows an index holds for a string, less holes
+func (t *assertionTable) lookupRows(ix rowIndex, name string) []int {
+	sym, exists := t.symbols.lookup(name)
+	if !exists {
+		return nil
+	}
+	if t.holes == 0 {
+		return ix[sym]
+	}
+	var rows []int
+	for _, row := range ix[sym] {
+		if !t.removed[row] {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// row returns the row index for an assertion ID
+func (t *assertionTable) row(id string) (int, bool) {
+	idSym, exists := t.symbols.lookup(id)
+	if !exists {
+		return 0, false
+	}
+	row, exists := t.rows[idSym]
+	return row, exists
+}
+
+// len returns the number of stored assertions
+func (t *assertionTable) len() int {
+	return len(t.ids) - t.holes
+}
+
+// span returns the number of rows, holes left by removed rows included.
+// Scans run over rows below it that the table holds.
+func (t *assertionTable) span() int {
+	return len(t.ids)
+}
+
+// holds reports whether a row below span holds an assertion rather than a
+// hole left by a removed one
+func (t *assertionTable) holds(row int) bool {
+	return !t.removed[row]
+}
+
+// id returns the assertion ID stored at a row
+func (t *assertionTable) id(row int) string {
+	return t.symbols.name(t.ids[row])
+}
+
+// subject returns the subject ID stored at a row
+func (t *assertionTable) subject(row int) string {
+	return t.symbols.name(t.subjects[row])
+}
+
+// relation returns the relation ID stored at a row
+func (t *assertionTable) relation(row int) string {
+	return t.symbols.name(t.relations[row])
+}
+
+// object returns the object ID stored at a row
+func (t *assertionTable) object(row int) string {
+	return t.symbols.name(t.objects[row])
+}
+
+// isRetracted reports whether the assertion at a row has been retracted
+func (t *assertionTable) isRetracted(row int) bool {
+	return t.retracted[row]
+}
+
+// setRetracted marks or unmarks the assertion at a row as retracted
+func (t *assertionTable) setRetracted(row int, retracted bool) {
+	t.own()
+	if t.retracted[row] != retracted {
+		if retracted {
+			t.hidden++
+		} else {
+			t.hidden--
+		}
+	}
+	t.retracted[row] = retracted
+}
+
+// retractedRows returns the number of retracted rows
+func (t *assertionTable) retractedRows() int {
+	return t.hidden
+}
+
+// confidence returns the confidence level and source of the assertion at a row
+func (t *assertionTable) confidence(row int) (float64, string) {
+	return t.confidences[row], t.symbols.name(t.sources[row])
+}
+
+// setConfidence sets the confidence level and source of the assertion at a row
+func (t *assertionTable) setConfidence(row int, level float64, source string) {
+	t.own()
+	t.confidences[row] = level
+	sourceSym := t.symbols.intern(source)
+	t.symbols.release(t.sources[row])
+	t.sources[row] = sourceSym
+}
+
+// live returns the rows that have not been retracted
+func (t *assertionTable) live(rows []int) []int {
+	var result []int
+	for _, row := range rows {
+		if !t.retracted[row] {
+			result = append(result, row)
+		}
+	}
+	return result
+}
+
+// assertion materializes the assertion stored at a row
+func (t *assertionTable) assertion(row int) *kmac.Assertion {
+	// Rows are only written from validated assertions, so this cannot fail
+	assertion, _ := kmac.NewAssertion(t.id(row), t.subject(row), t.relation(row), t.object(row))
+	assertion.SetConfidence(t.confidence(row))
+	return assertion
+}
+
+// rowsWithSubject returns the rows whose subject is the given ID
+func (t *assertionTable) rowsWithSubject(id string) []int {
+	return t.lookupRows(t.bySubject, id)
+}
+
+// rowsWithRelation returns the rows whose relation is the given ID
+func (t *assertionTable) rowsWithRelation(id string) []int {
+	return t.lookupRows(t.byRelation, id)
+}
+
+// rowsWithObject returns the rows whose object is the given ID
+func (t *assertionTable) rowsWithObject(id string) []int {
+	return t.lookupRows(t.byObject, id)
+}
+
+// rowsReferencing returns the rows whose subject or object is the given ID, in row order
+func (t *assertionTable) rowsReferencing(id string) []int {
+	subjectRows := t.rowsWithSubject(id)
+	objectRows := t.rowsWithObject(id)
+
+	rows := make([]int, 0, len(subjectRows)+len(objectRows))
+	rows = append(rows, subjectRows...)
+	for _, row := range objectRows {
+		// Self-referencing assertions appear in both indexes
+		if t.subjects[row] != t.objects[row] {
+			rows = append(rows, row)
+		}
+	}
+	sort.Ints(rows)
+	return rows
+}
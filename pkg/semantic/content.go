@@ -0,0 +1,30 @@
+package semantic
+
+import (
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+// CreateContentAssertion creates an assertion whose ID is derived from its
+// subject, relation, object, and context by kmac.ContentAssertionID, and
+// returns the ID. Creating the same assertion again, from this store or any
+// producer deriving IDs the same way, changes nothing: the assertion keeps
+// its confidence, metadata, and any retraction. Only a removed assertion is
+// created anew.
+func (s *SemanticStore) CreateContentAssertion(subjectID string, relationID string, objectID string, context string) (string, error) {
+	s.own()
+	id := kmac.ContentAssertionID(subjectID, relationID, objectID, context)
+	if s.hasContentAssertion(id) {
+		return id, nil
+	}
+	if err := s.CreateAssertion(id, subjectID, relationID, objectID); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// hasContentAssertion reports whether an assertion with a content-derived ID
+// is already in the store and has not been removed
+func (s *SemanticStore) hasContentAssertion(id string) bool {
+	_, exists := s.assertions.row(id)
+	return exists && !s.isRemoved(id)
+}
@@ -0,0 +1,33 @@
+package semantic
+
+import (
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+// Labeler resolves entity and relation labels in the store, and reads
+// references to live assertions as clauses
+func (s *SemanticStore) Labeler() kmac.Labeler {
+	labels := func(id string) (string, bool) {
+		if entityRef, exists := s.entities[id]; exists {
+			return entityRef.KMACEntity.Label(), true
+		}
+		if relation, exists := s.relations[id]; exists {
+			return relation.Label(), true
+		}
+		return "", false
+	}
+	return kmac.AssertionLabeler(labels, func(id string) (*kmac.Assertion, bool) {
+		assertion, err := s.GetAssertion(id)
+		return assertion, err == nil
+	})
+}
+
+// DescribeAssertion renders an assertion as an English sentence using the
+// labels of its entities and relation
+func (s *SemanticStore) DescribeAssertion(id string) (string, error) {
+	assertion, err := s.GetAssertion(id)
+	if err != nil {
+		return "", err
+	}
+	return assertion.Describe(s.Labeler()), nil
+}
@@ -0,0 +1,224 @@
+package semantic
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultDuplicateThreshold is the least label similarity at which
+// FindLikelyDuplicates takes two entities to be the same
+const DefaultDuplicateThreshold = 0.95
+
+// Reasons entities are taken to be duplicates
+const (
+	DuplicateSameTOSID     = "SAME_TOSID"     // They share a TOSID with a specific identifier
+	DuplicateSimilarLabels = "SIMILAR_LABELS" // Their labels are near-identical and their TOSIDs do not conflict
+)
+
+// DuplicateOptions tune duplicate detection
+type DuplicateOptions struct {
+	Threshold float64 // Least label similarity for a match, from 0 to 1; 0 means DefaultDuplicateThreshold
+}
+
+// DuplicateMatch is a pair of entities found to be likely duplicates
+type DuplicateMatch struct {
+	EntityA    string
+	EntityB    string
+	Reason     string  // DuplicateSameTOSID or DuplicateSimilarLabels
+	Similarity float64 // Of their labels, normalized as for FindEntitiesByLabelFuzzy
+}
+
+// MergeSuggestion is a cluster of entities that likely describe the same
+// thing, with the one suggested to survive a merge of the others into it.
+// Once reviewed, pass its Survivor and Duplicates to MergeEntities.
+type MergeSuggestion struct {
+	Survivor   string           // The entity in the most live assertions, then with a specific TOSID, then the lowest ID
+	Duplicates []string         // The other entities of the cluster, in ID order
+	Matches    []DuplicateMatch // The pairs that linked the cluster
+	Confidence float64          // The weakest match's: 1 for a shared TOSID, else the label similarity
+}
+
+// String renders the suggestion as one line
+func (m MergeSuggestion) String() string {
+	return fmt.Sprintf("merge %s into %s (confidence %.2f)", strings.Join(m.Duplicates, ", "), m.Survivor, m.Confidence)
+}
+
+// FindLikelyDuplicates clusters entities that share a specific TOSID or
+// have near-identical labels, suggesting how to merge each cluster. See
+// FindLikelyDuplicatesWith.
+func (s *SemanticStore) FindLikelyDuplicates() []MergeSuggestion {
+	return s.FindLikelyDuplicatesWith(DuplicateOptions{})
+}
+
+// FindLikelyDuplicatesWith clusters entities that likely describe the same
+// thing: those sharing a TOSID with a specific identifier, the part after
+// the colon, and those whose labels are at least opts.Threshold similar
+// unless their TOSIDs conflict. TOSIDs conflict when their categories differ
+// or both have different specific identifiers; an entity without a TOSID
+// conflicts with none. Entities are linked transitively, so a cluster may
+// hold entities that only match through another. Suggestions come most
+// confident first.
+func (s *SemanticStore) FindLikelyDuplicatesWith(opts DuplicateOptions) []MergeSuggestion {
+	suggestions, _ := s.FindLikelyDuplicatesWithContext(context.Background(), opts)
+	return suggestions
+}
+
+// FindLikelyDuplicatesWithContext clusters entities as
+// FindLikelyDuplicatesWith does, returning an error if ctx is done or a
+// frozen entity cannot be read first
+func (s *SemanticStore) FindLikelyDuplicatesWithContext(ctx context.Context, opts DuplicateOptions) ([]MergeSuggestion, error) {
+	view, err := s.readView(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return view.likelyDuplicates(opts), nil
+}
+
+// likelyDuplicates clusters the entities in memory
+func (s *SemanticStore) likelyDuplicates(opts DuplicateOptions) []MergeSuggestion {
+	threshold := opts.Threshold
+	if threshold <= 0 {
+		threshold = DefaultDuplicateThreshold
+	}
+
+	// Labels are only compared within a TOSID category, and against the
+	// entities without a TOSID, so large stores are not compared pairwise
+	ids := sortedIDs(s.entities)
+	byCategory := make(map[string][]string)
+	var typed, untyped []string
+	labels := make(map[string][]rune, len(ids))
+	for _, id := range ids {
+		entityRef := s.entities[id]
+		labels[id] = normalizeLabel(entityRef.KMACEntity.Label())
+		if entityRef.TOSIDObj == nil {
+			untyped = append(untyped, id)
+			continue
+		}
+		category, _, _ := strings.Cut(entityRef.KMACEntity.TOSIDType(), ":")
+		byCategory[category] = append(byCategory[category], id)
+		typed = append(typed, id)
+	}
+
+	var matches []DuplicateMatch
+	compare := func(a string, b string) {
+		if a > b {
+			a, b = b, a
+		}
+		similarity := 0.0
+		if len(labels[a]) > 0 && len(labels[b]) > 0 {
+			similarity = jaroWinkler(labels[a], labels[b])
+		}
+		codeA, codeB := s.entities[a].KMACEntity.TOSIDType(), s.entities[b].KMACEntity.TOSIDType()
+		switch {
+		case codeA != "" && codeA == codeB && strings.Contains(codeA, ":"):
+			matches = append(matches, DuplicateMatch{EntityA: a, EntityB: b, Reason: DuplicateSameTOSID, Similarity: similarity})
+		case similarity >= threshold && !tosidsConflict(codeA, codeB):
+			matches = append(matches, DuplicateMatch{EntityA: a, EntityB: b, Reason: DuplicateSimilarLabels, Similarity: similarity})
+		}
+	}
+	for _, category := range sortedIDs(byCategory) {
+		members := byCategory[category]
+		for i := range members {
+			for j := i + 1; j < len(members); j++ {
+				compare(members[i], members[j])
+			}
+		}
+	}
+	for i, a := range untyped {
+		for _, b := range untyped[i+1:] {
+			compare(a, b)
+		}
+		for _, b := range typed {
+			compare(a, b)
+		}
+	}
+
+	return s.suggestMerges(matches)
+}
+
+// suggestMerges clusters matched entities and picks a survivor for each
+// cluster
+func (s *SemanticStore) suggestMerges(matches []DuplicateMatch) []MergeSuggestion {
+	parent := make(map[string]string)
+	var find func(id string) string
+	find = func(id string) string {
+		if p, exists := parent[id]; exists && p != id {
+			parent[id] = find(p)
+			return parent[id]
+		}
+		parent[id] = id
+		return id
+	}
+	for _, match := range matches {
+		rootA, rootB := find(match.EntityA), find(match.EntityB)
+		if rootA != rootB {
+			parent[rootB] = rootA
+		}
+	}
+
+	clusters := make(map[string]*MergeSuggestion)
+	members := make(map[string][]string)
+	for _, match := range matches {
+		root := find(match.EntityA)
+		suggestion, exists := clusters[root]
+		if !exists {
+			suggestion = &MergeSuggestion{Confidence: 1}
+			clusters[root] = suggestion
+		}
+		suggestion.Matches = append(suggestion.Matches, match)
+		if match.Reason == DuplicateSimilarLabels && match.Similarity < suggestion.Confidence {
+			suggestion.Confidence = match.Similarity
+		}
+	}
+	for id := range parent {
+		root := find(id)
+		members[root] = append(members[root], id)
+	}
+
+	suggestions := make([]MergeSuggestion, 0, len(clusters))
+	for root, suggestion := range clusters {
+		ids := members[root]
+		sort.Strings(ids)
+		suggestion.Survivor = s.pickSurvivor(ids)
+		for _, id := range ids {
+			if id != suggestion.Survivor {
+				suggestion.Duplicates = append(suggestion.Duplicates, id)
+			}
+		}
+		suggestions = append(suggestions, *suggestion)
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Confidence != suggestions[j].Confidence {
+			return suggestions[i].Confidence > suggestions[j].Confidence
+		}
+		return suggestions[i].Survivor < suggestions[j].Survivor
+	})
+	return suggestions
+}
+
+// pickSurvivor chooses which of a cluster of entities, in ID order, the
+// others should be merged into
+func (s *SemanticStore) pickSurvivor(ids []string) string {
+	best, bestAssertions, bestSpecific := "", -1, false
+	for _, id := range ids {
+		assertions := len(s.assertions.live(s.assertions.rowsReferencing(id)))
+		specific := strings.Contains(s.entities[id].KMACEntity.TOSIDType(), ":")
+		if assertions > bestAssertions || (assertions == bestAssertions && specific && !bestSpecific) {
+			best, bestAssertions, bestSpecific = id, assertions, specific
+		}
+	}
+	return best
+}
+
+// tosidsConflict reports whether two TOSID codes describe different things:
+// their categories differ, or both have specific identifiers that differ
+func tosidsConflict(a string, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	categoryA, specificA, hasA := strings.Cut(a, ":")
+	categoryB, specificB, hasB := strings.Cut(b, ":")
+	return categoryA != categoryB || (hasA && hasB && specificA != specificB)
+}
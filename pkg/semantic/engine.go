@@ -0,0 +1,1047 @@
+package semantic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+// Kinds of record a store keeps in a storage engine
+const (
+	RecordEntity    = "entity"    // Fields label and tosid, a field per property, and any states and vector
+	RecordRelation  = "relation"  // Fields label, type, and inverse when declared
+	RecordAssertion = "assertion" // Fields subject, relation, object, confidence, source, asserted_at, and retracted and retracted_at when retracted
+	RecordTombstone = "tombstone" // Fields statement, reason, removed_at, and cause, for a removed statement
+)
+
+// recordKinds are the kinds of record, in the order they are written, so
+// entities and relations go before the assertions on them
+var recordKinds = []string{RecordEntity, RecordRelation, RecordAssertion, RecordTombstone}
+
+// PropertyFieldPrefix starts the fields of an entity record that hold its
+// properties, as in "property:capacity"
+const PropertyFieldPrefix = "property:"
+
+// Prefixes of the fields that hold a statement's metadata, as in
+// "tag:unverified", "annotation:owner", and "note:0", and an entity's
+// states, as in "state:0:value"; notes and states are numbered in order
+const (
+	tagFieldPrefix        = "tag:"
+	annotationFieldPrefix = "annotation:"
+	noteFieldPrefix       = "note:"
+	stateFieldPrefix      = "state:"
+)
+
+// IndexedFields are the fields engines are expected to index, and so the
+// fields an IndexHint usually names: the parts of an assertion and the TOSID
+// of an entity
+var IndexedFields = []string{"subject", "relation", "object", "tosid"}
+
+// Record is a statement as a storage engine keeps it: a kind, an ID unique
+// within the kind, and named string fields
+type Record struct {
+	Kind   string
+	ID     string
+	Fields map[string]string
+}
+
+// IndexHint narrows a scan to the records with a field of a given value
+type IndexHint struct {
+	Field string
+	Value string
+}
+
+// StorageEngine persists the records of a store. Engines may keep them in
+// memory, in a key-value file such as Bolt, or in a SQL database such as
+// SQLite or Postgres. The engine is the store of record: the store writes
+// its changes through to it and reads from it the statements memory does not
+// hold, so its queries and validation run the same over any of them.
+type StorageEngine interface {
+	// Put stores a record, replacing any of the same kind and ID
+	Put(ctx context.Context, record Record) error
+	// Get returns the record of a kind and ID, reporting false if there is none
+	Get(ctx context.Context, kind string, id string) (Record, bool, error)
+	// Scan calls fn with each record of a kind, in ID order, until it
+	// returns false. A hint limits the scan to the matching records; engines
+	// use an index for it where they have one, and filter otherwise.
+	Scan(ctx context.Context, kind string, hint *IndexHint, fn func(Record) bool) error
+	// Delete removes the record of a kind and ID, if there is one
+	Delete(ctx context.Context, kind string, id string) error
+}
+
+// BatchEngine is a storage engine that can apply many writes at once, such
+// as in one database transaction. A store writes each batch of changes
+// through WriteBatch when its engine has it.
+type BatchEngine interface {
+	StorageEngine
+	// WriteBatch stores the records in puts and removes those in deletes,
+	// of which only the kinds and IDs are read
+	WriteBatch(ctx context.Context, puts []Record, deletes []Record) error
+}
+
+// MemoryEngine is a storage engine that keeps records in memory, for tests
+// and for stores that need no persistence. It is safe for concurrent use,
+// as a batch policy's timer writes to it from its own goroutine.
+type MemoryEngine struct {
+	mu      sync.RWMutex
+	records map[string]map[string]Record // By kind, then ID
+}
+
+var _ StorageEngine = (*MemoryEngine)(nil)
+
+// NewMemoryEngine creates an empty in-memory storage engine
+func NewMemoryEngine() *MemoryEngine {
+	return &MemoryEngine{records: make(map[string]map[string]Record)}
+}
+
+// Put stores a copy of a record
+func (e *MemoryEngine) Put(ctx context.Context, record Record) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	byID, exists := e.records[record.Kind]
+	if !exists {
+		byID = make(map[string]Record)
+		e.records[record.Kind] = byID
+	}
+	byID[record.ID] = copyRecord(record)
+	return nil
+}
+
+// Get returns a copy of a record
+func (e *MemoryEngine) Get(ctx context.Context, kind string, id string) (Record, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return Record{}, false, err
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	record, exists := e.records[kind][id]
+	if !exists {
+		return Record{}, false, nil
+	}
+	return copyRecord(record), true, nil
+}
+
+// Scan calls fn with a copy of each record of a kind matching the hint
+func (e *MemoryEngine) Scan(ctx context.Context, kind string, hint *IndexHint, fn func(Record) bool) error {
+	// The matches are copied out first, so fn may write to the engine
+	e.mu.RLock()
+	byID := e.records[kind]
+	var matches []Record
+	for _, id := range sortedIDs(byID) {
+		record := byID[id]
+		if hint == nil || record.Fields[hint.Field] == hint.Value {
+			matches = append(matches, copyRecord(record))
+		}
+	}
+	e.mu.RUnlock()
+
+	for _, record := range matches {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !fn(record) {
+			break
+		}
+	}
+	return nil
+}
+
+// Delete removes a record
+func (e *MemoryEngine) Delete(ctx context.Context, kind string, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.records[kind], id)
+	return nil
+}
+
+// copyRecord returns a record with its own copy of the fields
+func copyRecord(record Record) Record {
+	fields := make(map[string]string, len(record.Fields))
+	for key, value := range record.Fields {
+		fields[key] = value
+	}
+	record.Fields = fields
+	return record
+}
+
+// engineLink is a store's connection to its storage engine. Each mutation
+// resolves the records it changed into writes held for the engine, which
+// the store or the batch timer then makes.
+type engineLink struct {
+	engine    StorageEngine
+	batch     BatchPolicy
+	changed   map[string][2]string // Kind and ID of the records the current mutation changed, by recordKey
+	mutations int                  // Mutations since the last sync
+	since     time.Time            // When the first of them was made
+	partial   bool                 // Whether the engine may hold entities or assertions memory does not
+
+	mu    sync.Mutex           // Guards the fields below against the batch timer
+	held  map[string]heldWrite // Writes not yet made, by recordKey
+	timer *time.Timer          // Writes the held records MaxDelay after the first mutation
+	err   error                // Failure of the last write, retried by the next mutation
+}
+
+// heldWrite is a write to a storage engine waiting for its batch: a record
+// to put, or the kind and ID of one to delete
+type heldWrite struct {
+	record Record
+	delete bool
+}
+
+// AttachEngine puts the store on a storage engine, which from then on is
+// the store of record: memory holds the statements the store has read or
+// written since, and the rest are read from the engine as they are needed.
+// Relations and tombstones, which most changes consult, and entities with
+// vectors, which similarity searches need, are read when the engine is
+// attached. The store's own statements are then written to it, replacing
+// any with the same IDs, and from then on every mutation writes the records
+// it changed through to the engine.
+//
+// Entities and assertions are read from the engine as frozen statements
+// are thawed under tiering: when read or changed by ID, or found through
+// the FindAssertions lookups. Reads over the whole store scan the engine
+// and leave what they find there; see EnableTiering for which reads are
+// which. Under limits, evicted statements are dropped from memory and left
+// in the engine, and the statements read back count toward the limits from
+// the next addition. Since reads by ID may load statements, a store on an
+// engine must be read by ID under an exclusive lock while ThawsOnRead
+// reports true.
+//
+// The engine keeps each statement with its metadata, an entity with its
+// state history and vector, and an assertion with its confidence, when it
+// was made, and any retraction. Removed statements are deleted from it,
+// leaving their tombstones. Derivations, temporal bounds, situations,
+// evidence, pending references, time references, and the data of removed
+// statements are kept in memory only. If a write fails the mutation returns
+// the error and the change stays in memory; the records it left unwritten
+// are written by the next sync. SetEngineBatch groups the writes of many
+// mutations into one.
+func (s *SemanticStore) AttachEngine(engine StorageEngine) error {
+	return s.AttachEngineContext(context.Background(), engine)
+}
+
+// AttachEngineContext attaches a storage engine as AttachEngine does,
+// stopping early if ctx is done
+func (s *SemanticStore) AttachEngineContext(ctx context.Context, engine StorageEngine) error {
+	s.own()
+	if s.engine != nil {
+		return errors.New("storage engine already attached")
+	}
+	if s.tiering != nil {
+		return errors.New("a storage engine cannot be attached with tiering enabled")
+	}
+	s.engine = &engineLink{engine: engine, changed: make(map[string][2]string), held: make(map[string]heldWrite)}
+	if err := s.openEngine(ctx); err != nil {
+		s.engine = nil
+		return fmt.Errorf("failed to load from storage engine: %v", err)
+	}
+	s.eachRecordKey(s.recordChanged)
+	return s.syncEngine(ctx)
+}
+
+// openEngine reads what the store keeps in memory from the storage engine
+// being attached: every relation and tombstone, and the entities with
+// vectors. Records of statements the store already holds are noted as
+// changed, so the store's replace them.
+func (s *SemanticStore) openEngine(ctx context.Context) error {
+	link := s.engine
+	var err error
+	open := func(kind string, restore func(Record) error) error {
+		scanErr := link.engine.Scan(ctx, kind, nil, func(record Record) bool {
+			if s.inMemory(record.ID) {
+				s.recordChanged(kind, record.ID)
+				return true
+			}
+			if err = restore(record); err != nil {
+				err = fmt.Errorf("%s %s: %v", kind, record.ID, err)
+				return false
+			}
+			return true
+		})
+		if scanErr != nil {
+			return scanErr
+		}
+		return err
+	}
+
+	if err := open(RecordRelation, func(record Record) error {
+		relation, err := kmac.NewRelation(record.ID, record.Fields["label"], record.Fields["type"])
+		if err != nil {
+			return err
+		}
+		if inverse, declared := record.Fields["inverse"]; declared {
+			relation.SetProperty("inverse", inverse)
+		}
+		if meta := recordMetadata(record.Fields); meta != nil {
+			relation.Metadata.Merge(meta)
+		}
+		s.relations[record.ID] = relation
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := open(RecordTombstone, func(record Record) error {
+		s.tombstones[record.ID] = &Tombstone{
+			ID:        record.ID,
+			Kind:      record.Fields["statement"],
+			Reason:    record.Fields["reason"],
+			RemovedAt: recordTime(record.Fields["removed_at"]),
+			Cause:     record.Fields["cause"],
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := open(RecordEntity, func(record Record) error {
+		if _, has := record.Fields["vector"]; has {
+			return s.restoreEntity(record, true)
+		}
+		link.partial = true
+		return nil
+	}); err != nil {
+		return err
+	}
+	return open(RecordAssertion, func(record Record) error {
+		link.partial = true
+		return nil
+	})
+}
+
+// DetachEngine reads the statements only the storage engine holds back
+// into memory, writes any changes a batch policy holds to the engine, then
+// stops writing mutations through to it
+func (s *SemanticStore) DetachEngine() error {
+	if s.engine == nil {
+		return nil
+	}
+	if err := s.fetchAll(context.Background()); err != nil {
+		return err
+	}
+	err := s.FlushEngine()
+	s.engine = nil
+	return err
+}
+
+// SetEngineBatch sets how the storage engine's writes are batched, writing
+// any changes the previous policy held. See BatchPolicy for what a crash
+// loses; an engine that is a BatchEngine writes each batch in one call.
+func (s *SemanticStore) SetEngineBatch(policy BatchPolicy) error {
+	if s.engine == nil {
+		return errors.New("no storage engine attached")
+	}
+	if policy.MaxRecords < 0 || policy.MaxDelay < 0 {
+		return fmt.Errorf("invalid batch policy of %d records and %v", policy.MaxRecords, policy.MaxDelay)
+	}
+	s.engine.batch = policy
+	return s.FlushEngine()
+}
+
+// FlushEngine writes the changes a batch policy holds to the storage engine
+func (s *SemanticStore) FlushEngine() error {
+	return s.FlushEngineContext(context.Background())
+}
+
+// FlushEngineContext writes held changes as FlushEngine does, stopping early
+// if ctx is done
+func (s *SemanticStore) FlushEngineContext(ctx context.Context) error {
+	if s.engine == nil {
+		return nil
+	}
+	return s.syncEngine(ctx)
+}
+
+// fetches reports whether the storage engine may hold entities or
+// assertions memory does not, so reads must look for them there
+func (s *SemanticStore) fetches() bool {
+	return s.engine != nil && s.engine.partial
+}
+
+// inMemory reports whether memory holds a statement with an ID, or its
+// tombstone
+func (s *SemanticStore) inMemory(id string) bool {
+	if _, exists := s.entities[id]; exists {
+		return true
+	}
+	if _, exists := s.relations[id]; exists {
+		return true
+	}
+	if _, exists := s.assertions.row(id); exists {
+		return true
+	}
+	if _, exists := s.tombstones[id]; exists {
+		return true
+	}
+	_, exists := s.times[id]
+	return exists
+}
+
+// engineRecord returns the record of a kind and ID the storage engine
+// holds, as it will hold it once the held writes are made
+func (s *SemanticStore) engineRecord(ctx context.Context, kind string, id string) (Record, bool, error) {
+	link := s.engine
+	key := recordKey(kind, id)
+	if _, changed := link.changed[key]; changed {
+		// Changed and no longer in memory, so about to be deleted
+		return Record{}, false, nil
+	}
+	link.mu.Lock()
+	write, held := link.held[key]
+	link.mu.Unlock()
+	if held {
+		return write.record, !write.delete, nil
+	}
+	return link.engine.Get(ctx, kind, id)
+}
+
+// scanEngine calls fn with each record of a kind matching a hint that the
+// storage engine holds and memory does not, as the engine will hold it
+// once the held writes are made, until fn returns false. The records are
+// read before fn is called, so fn may read the engine too.
+func (s *SemanticStore) scanEngine(ctx context.Context, kind string, hint *IndexHint, fn func(Record) bool) error {
+	link := s.engine
+	link.mu.Lock()
+	held := maps.Clone(link.held)
+	link.mu.Unlock()
+	var records []Record
+	err := link.engine.Scan(ctx, kind, hint, func(record Record) bool {
+		key := recordKey(kind, record.ID)
+		_, changed := link.changed[key]
+		_, replaced := held[key]
+		if !changed && !replaced && !s.inMemory(record.ID) {
+			records = append(records, record)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range sortedIDs(held) {
+		write := held[key]
+		if _, changed := link.changed[key]; changed || write.delete || write.record.Kind != kind || s.inMemory(write.record.ID) {
+			continue
+		}
+		if hint == nil || write.record.Fields[hint.Field] == hint.Value {
+			records = append(records, write.record)
+		}
+	}
+
+	for _, record := range records {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !fn(record) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// fetchStatement reads the entity or assertion with an ID from the storage
+// engine into memory, if memory does not hold it, along with the
+// statements an assertion refers to
+func (s *SemanticStore) fetchStatement(id string) error {
+	if !s.fetches() || id == "" || s.inMemory(id) {
+		return nil
+	}
+	kind := RecordEntity
+	if kmac.IsAssertionReference(id) {
+		kind = RecordAssertion
+	}
+	record, exists, err := s.engineRecord(context.Background(), kind, id)
+	if err != nil {
+		return fmt.Errorf("failed to read %s from storage engine: %v", id, err)
+	}
+	if !exists {
+		return nil
+	}
+	return s.restoreRecord(record, true)
+}
+
+// fetchAbout reads a statement from the storage engine into memory along
+// with the assertions that refer to it, directly or through one another,
+// and those using it if it is a relation
+func (s *SemanticStore) fetchAbout(id string) error {
+	if !s.fetches() {
+		return nil
+	}
+	if err := s.fetchStatement(id); err != nil {
+		return err
+	}
+	fields := []string{"subject", "object"}
+	if _, exists := s.relations[id]; exists {
+		fields = append(fields, "relation")
+	}
+	ctx := context.Background()
+	queue := []string{id}
+	for len(queue) > 0 {
+		ref := queue[0]
+		queue = queue[1:]
+		var found []Record
+		for _, field := range fields {
+			if err := s.scanEngine(ctx, RecordAssertion, &IndexHint{Field: field, Value: ref}, func(record Record) bool {
+				found = append(found, record)
+				return true
+			}); err != nil {
+				return fmt.Errorf("failed to read assertions on %s from storage engine: %v", ref, err)
+			}
+		}
+		for _, record := range found {
+			if s.inMemory(record.ID) {
+				continue
+			}
+			if err := s.restoreRecord(record, true); err != nil {
+				return err
+			}
+			queue = append(queue, record.ID)
+		}
+		fields = fields[:2]
+	}
+	return nil
+}
+
+// fetchAll reads every statement the storage engine holds and memory does
+// not into memory, so that memory again holds them all
+func (s *SemanticStore) fetchAll(ctx context.Context) error {
+	if !s.fetches() {
+		return nil
+	}
+	for _, kind := range []string{RecordEntity, RecordAssertion} {
+		var records []Record
+		if err := s.scanEngine(ctx, kind, nil, func(record Record) bool {
+			records = append(records, record)
+			return true
+		}); err != nil {
+			return fmt.Errorf("failed to read from storage engine: %v", err)
+		}
+		for _, record := range records {
+			if s.inMemory(record.ID) {
+				continue
+			}
+			if err := s.restoreRecord(record, true); err != nil {
+				return err
+			}
+		}
+	}
+	s.engine.partial = false
+	return nil
+}
+
+// copyEngineInto copies every statement the storage engine holds and memory
+// does not into another store as reading them would load them, vectors
+// aside, stopping early if ctx is done
+func (s *SemanticStore) copyEngineInto(ctx context.Context, c *SemanticStore) error {
+	if !s.fetches() {
+		return nil
+	}
+	for _, kind := range []string{RecordEntity, RecordAssertion} {
+		var err error
+		if scanErr := s.scanEngine(ctx, kind, nil, func(record Record) bool {
+			err = c.restoreRecord(record, false)
+			return err == nil
+		}); scanErr != nil {
+			return fmt.Errorf("failed to read from storage engine: %v", scanErr)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreRecord puts a statement read from the storage engine in memory as
+// it was written, its vector too if vectors is set, and reads the
+// statements an assertion refers to
+func (s *SemanticStore) restoreRecord(record Record, vectors bool) error {
+	s.own()
+	if record.Kind == RecordEntity {
+		return s.restoreEntity(record, vectors)
+	}
+	if err := s.restoreAssertion(record); err != nil {
+		return err
+	}
+	for _, ref := range []string{record.Fields["subject"], record.Fields["object"]} {
+		if err := s.fetchStatement(ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreEntity puts an entity read from the storage engine in memory, with
+// its metadata, states, and, if vectors is set, its vector
+func (s *SemanticStore) restoreEntity(record Record, vectors bool) error {
+	entityRef, err := recordEntity(record)
+	if err != nil {
+		return fmt.Errorf("entity %s: %v", record.ID, err)
+	}
+	history, err := recordStates(record)
+	if err != nil {
+		return fmt.Errorf("entity %s: %v", record.ID, err)
+	}
+	var vector []float32
+	if value, has := record.Fields["vector"]; has && vectors {
+		if vector, err = parseVector(value); err != nil {
+			return fmt.Errorf("entity %s: %v", record.ID, err)
+		}
+	}
+
+	if code := entityRef.KMACEntity.TOSIDType(); code != "" {
+		entityRef.TOSIDObj, _ = s.tosids.Intern(code)
+	}
+	s.entities[record.ID] = entityRef
+	if history != nil {
+		s.states[record.ID] = history
+	}
+	if vector != nil {
+		if err := s.putVector(record.ID, vector); err != nil {
+			return fmt.Errorf("entity %s: %v", record.ID, err)
+		}
+	}
+	s.touchEntity(record.ID)
+	return nil
+}
+
+// restoreAssertion puts an assertion read from the storage engine in
+// memory, with its confidence, metadata, and any retraction
+func (s *SemanticStore) restoreAssertion(record Record) error {
+	fields := record.Fields
+	if _, err := kmac.NewAssertion(record.ID, fields["subject"], fields["relation"], fields["object"]); err != nil {
+		return fmt.Errorf("assertion %s: %v", record.ID, err)
+	}
+	level := 1.0
+	if value, set := fields["confidence"]; set {
+		var err error
+		if level, err = strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("assertion %s: invalid confidence: %v", record.ID, err)
+		}
+	}
+
+	id := record.ID
+	row := s.assertions.put(id, fields["subject"], fields["relation"], fields["object"])
+	s.assertions.setConfidence(row, level, fields["source"])
+	if reason, retracted := fields["retracted"]; retracted {
+		s.assertions.setRetracted(row, true)
+		s.retractions[id] = &Retraction{AssertionID: id, Reason: reason, RetractedAt: recordTime(fields["retracted_at"])}
+	}
+	if meta := recordMetadata(fields); meta != nil {
+		s.assertionMeta[id] = meta
+	}
+	if at := recordTime(fields["asserted_at"]); !at.IsZero() {
+		s.assertedAt[id] = at
+	}
+	s.touchAssertion(id)
+	return nil
+}
+
+// unload drops evicted entities and assertions from memory, leaving them in
+// the storage engine to be read back when next needed. Their changes are
+// held for the engine first, as memory will no longer hold their records.
+func (s *SemanticStore) unload(entityIDs []string, assertionIDs []string) {
+	link := s.engine
+	link.mu.Lock()
+	for _, id := range assertionIDs {
+		s.holdChange(recordKey(RecordAssertion, id))
+	}
+	for _, id := range entityIDs {
+		s.holdChange(recordKey(RecordEntity, id))
+	}
+	link.mu.Unlock()
+
+	if len(assertionIDs) > 0 {
+		s.dropAssertions(assertionIDs)
+	}
+	for _, id := range entityIDs {
+		s.dropEntity(id)
+	}
+	link.partial = true
+}
+
+// recordChanged notes that the record of a statement changed, so the next
+// sync writes it to the storage engine, or deletes it if the store no
+// longer holds the statement
+func (s *SemanticStore) recordChanged(kind string, id string) {
+	if s.engine != nil {
+		s.engine.changed[recordKey(kind, id)] = [2]string{kind, id}
+	}
+}
+
+// statementChanged notes that the record of the entity, relation, or
+// assertion with an ID changed, as when its metadata does
+func (s *SemanticStore) statementChanged(id string) {
+	if s.engine == nil {
+		return
+	}
+	if _, exists := s.entities[id]; exists {
+		s.recordChanged(RecordEntity, id)
+	} else if _, exists := s.relations[id]; exists {
+		s.recordChanged(RecordRelation, id)
+	} else if _, exists := s.assertions.row(id); exists {
+		s.recordChanged(RecordAssertion, id)
+	}
+}
+
+// recordsChanged notes that the records of every statement the store
+// holds, in memory or only in its storage engine, changed, as when they are
+// about to be dropped all at once
+func (s *SemanticStore) recordsChanged() {
+	if s.engine == nil {
+		return
+	}
+	s.eachRecordKey(s.recordChanged)
+	if s.engine.partial {
+		for _, kind := range []string{RecordEntity, RecordAssertion} {
+			s.scanEngine(context.Background(), kind, nil, func(record Record) bool {
+				s.recordChanged(kind, record.ID)
+				return true
+			})
+		}
+		s.engine.partial = false
+	}
+}
+
+// writeThrough counts a mutation, holds the records it changed, and syncs
+// the engine when the batch is due or the last write failed. The first
+// mutation of a batch with a MaxDelay starts the timer that writes it.
+func (s *SemanticStore) writeThrough(ctx context.Context) error {
+	link := s.engine
+	s.holdChanges()
+	if link.mutations == 0 {
+		link.since = time.Now()
+	}
+	link.mutations++
+	link.mu.Lock()
+	failed := link.err != nil
+	if link.timer == nil && link.batch.MaxDelay > 0 && len(link.held) > 0 {
+		link.timer = time.AfterFunc(link.batch.MaxDelay, func() { link.flush(context.Background()) })
+	}
+	link.mu.Unlock()
+	if !failed && !link.batch.due(link.mutations, link.since) {
+		return nil
+	}
+	return s.syncEngine(ctx)
+}
+
+// holdChanges resolves the records noted as changed into held writes: puts
+// of those the store still holds and deletes of the rest
+func (s *SemanticStore) holdChanges() {
+	link := s.engine
+	if len(link.changed) == 0 {
+		return
+	}
+	link.mu.Lock()
+	defer link.mu.Unlock()
+	for key := range link.changed {
+		s.holdChange(key)
+	}
+}
+
+// holdChange resolves a record noted as changed into a held write, with the
+// link locked
+func (s *SemanticStore) holdChange(key string) {
+	link := s.engine
+	changed, noted := link.changed[key]
+	if !noted {
+		return
+	}
+	record, exists := s.record(changed[0], changed[1])
+	if !exists {
+		record = Record{Kind: changed[0], ID: changed[1]}
+	}
+	link.held[key] = heldWrite{record: record, delete: !exists}
+	delete(link.changed, key)
+}
+
+// syncEngine writes the records changed since the last sync to the engine
+func (s *SemanticStore) syncEngine(ctx context.Context) error {
+	s.holdChanges()
+	s.engine.mutations = 0
+	return s.engine.flush(ctx)
+}
+
+// flush makes the held writes. Those a failed write leaves unmade stay held
+// for the next sync; writes replace whole records, so making one again is
+// harmless.
+func (link *engineLink) flush(ctx context.Context) error {
+	link.mu.Lock()
+	defer link.mu.Unlock()
+	if link.timer != nil {
+		link.timer.Stop()
+		link.timer = nil
+	}
+	link.err = link.write(ctx)
+	return link.err
+}
+
+// write makes the held writes, with the link locked
+func (link *engineLink) write(ctx context.Context) error {
+	if len(link.held) == 0 {
+		return nil
+	}
+
+	var puts, deletes []Record
+	for _, kind := range recordKinds {
+		for _, key := range sortedIDs(link.held) {
+			write := link.held[key]
+			if write.record.Kind != kind {
+				continue
+			}
+			if write.delete {
+				deletes = append(deletes, write.record)
+			} else {
+				puts = append(puts, write.record)
+			}
+		}
+	}
+
+	if batch, ok := link.engine.(BatchEngine); ok {
+		if err := batch.WriteBatch(ctx, puts, deletes); err != nil {
+			return fmt.Errorf("failed to write %d records to storage engine: %v", len(puts)+len(deletes), err)
+		}
+		link.held = make(map[string]heldWrite)
+		return nil
+	}
+	for _, record := range puts {
+		if err := link.engine.Put(ctx, record); err != nil {
+			return fmt.Errorf("failed to write %s %s to storage engine: %v", record.Kind, record.ID, err)
+		}
+		delete(link.held, recordKey(record.Kind, record.ID))
+	}
+	for _, record := range deletes {
+		if err := link.engine.Delete(ctx, record.Kind, record.ID); err != nil {
+			return fmt.Errorf("failed to write %s %s to storage engine: %v", record.Kind, record.ID, err)
+		}
+		delete(link.held, recordKey(record.Kind, record.ID))
+	}
+	return nil
+}
+
+// eachRecordKey calls fn with the kind and ID of every record the store
+// holds in memory
+func (s *SemanticStore) eachRecordKey(fn func(kind string, id string)) {
+	for id := range s.entities {
+		fn(RecordEntity, id)
+	}
+	for id := range s.relations {
+		fn(RecordRelation, id)
+	}
+	for row := 0; row < s.assertions.span(); row++ {
+		if !s.assertions.holds(row) {
+			continue
+		}
+		if id := s.assertions.id(row); !s.isRemoved(id) {
+			fn(RecordAssertion, id)
+		}
+	}
+	for id := range s.tombstones {
+		fn(RecordTombstone, id)
+	}
+}
+
+// record returns the record of a statement the store holds in memory
+func (s *SemanticStore) record(kind string, id string) (Record, bool) {
+	fields := make(map[string]string)
+	switch kind {
+	case RecordEntity:
+		entityRef, exists := s.entities[id]
+		if !exists {
+			return Record{}, false
+		}
+		fields["label"] = entityRef.KMACEntity.Label()
+		fields["tosid"] = entityRef.KMACEntity.TOSIDType()
+		for key, value := range entityRef.KMACEntity.GetAllProperties() {
+			fields[PropertyFieldPrefix+key] = value
+		}
+		putMetadata(fields, &entityRef.KMACEntity.Metadata)
+		if history, has := s.states[id]; has {
+			putStates(fields, history)
+		}
+		if vector, has := s.vectors[id]; has {
+			fields["vector"] = formatVector(vector.raw)
+		}
+	case RecordRelation:
+		relation, exists := s.relations[id]
+		if !exists {
+			return Record{}, false
+		}
+		fields["label"] = relation.Label()
+		fields["type"] = relation.RelationType()
+		if inverse, declared := relation.Inverse(); declared {
+			fields["inverse"] = inverse
+		}
+		putMetadata(fields, &relation.Metadata)
+	case RecordAssertion:
+		row, exists := s.assertions.row(id)
+		if !exists || s.isRemoved(id) {
+			return Record{}, false
+		}
+		level, source := s.assertions.confidence(row)
+		fields["subject"] = s.assertions.subject(row)
+		fields["relation"] = s.assertions.relation(row)
+		fields["object"] = s.assertions.object(row)
+		fields["confidence"] = strconv.FormatFloat(level, 'g', -1, 64)
+		fields["source"] = source
+		if at, known := s.assertedAt[id]; known {
+			fields["asserted_at"] = at.Format(time.RFC3339Nano)
+		}
+		if retraction, retracted := s.retractions[id]; retracted {
+			fields["retracted"] = retraction.Reason
+			fields["retracted_at"] = retraction.RetractedAt.Format(time.RFC3339Nano)
+		}
+		putMetadata(fields, s.assertionMeta[id])
+	case RecordTombstone:
+		tombstone, exists := s.tombstones[id]
+		if !exists {
+			return Record{}, false
+		}
+		fields["statement"] = tombstone.Kind
+		fields["reason"] = tombstone.Reason
+		fields["removed_at"] = tombstone.RemovedAt.Format(time.RFC3339Nano)
+		fields["cause"] = tombstone.Cause
+	default:
+		return Record{}, false
+	}
+	return Record{Kind: kind, ID: id, Fields: fields}, true
+}
+
+// recordKey joins a record's kind and ID
+func recordKey(kind string, id string) string {
+	return kind + "\x00" + id
+}
+
+// putMetadata adds a statement's metadata to the fields of its record
+func putMetadata(fields map[string]string, meta *kmac.Metadata) {
+	if meta == nil {
+		return
+	}
+	for _, tag := range meta.Tags() {
+		fields[tagFieldPrefix+tag] = ""
+	}
+	for key, value := range meta.Annotations() {
+		fields[annotationFieldPrefix+key] = value
+	}
+	for i, note := range meta.Notes() {
+		fields[noteFieldPrefix+strconv.Itoa(i)] = note
+	}
+}
+
+// recordMetadata rebuilds a statement's metadata from the fields of its
+// record, nil if it has none
+func recordMetadata(fields map[string]string) *kmac.Metadata {
+	meta := &kmac.Metadata{}
+	notes := make(map[int]string)
+	for key, value := range fields {
+		if tag, ok := strings.CutPrefix(key, tagFieldPrefix); ok {
+			meta.AddTag(tag)
+		} else if annotation, ok := strings.CutPrefix(key, annotationFieldPrefix); ok {
+			meta.Annotate(annotation, value)
+		} else if n, ok := strings.CutPrefix(key, noteFieldPrefix); ok {
+			if i, err := strconv.Atoi(n); err == nil {
+				notes[i] = value
+			}
+		}
+	}
+	order := make([]int, 0, len(notes))
+	for i := range notes {
+		order = append(order, i)
+	}
+	sort.Ints(order)
+	for _, i := range order {
+		meta.AddNote(notes[i])
+	}
+	if meta.IsEmpty() {
+		return nil
+	}
+	return meta
+}
+
+// putStates adds an entity's state history to the fields of its record,
+// numbered in the order adding them again rebuilds it
+func putStates(fields map[string]string, history *kmac.StateHistory) {
+	n := 0
+	for _, attribute := range history.Attributes() {
+		for _, state := range history.History(attribute) {
+			prefix := stateFieldPrefix + strconv.Itoa(n) + ":"
+			fields[prefix+"id"] = state.ID()
+			fields[prefix+"attribute"] = attribute
+			fields[prefix+"value"] = state.Value()
+			fields[prefix+"at"] = state.Timestamp().Format(time.RFC3339Nano)
+			n++
+		}
+	}
+}
+
+// recordStates rebuilds an entity's state history from the fields of its
+// record, nil if it has none
+func recordStates(record Record) (*kmac.StateHistory, error) {
+	var history *kmac.StateHistory
+	for n := 0; ; n++ {
+		prefix := stateFieldPrefix + strconv.Itoa(n) + ":"
+		id, has := record.Fields[prefix+"id"]
+		if !has {
+			return history, nil
+		}
+		state, err := kmac.NewStateAssertion(id, record.ID, record.Fields[prefix+"attribute"], record.Fields[prefix+"value"], recordTime(record.Fields[prefix+"at"]))
+		if err != nil {
+			return nil, fmt.Errorf("state %s: %v", id, err)
+		}
+		if history == nil {
+			history = kmac.NewStateHistory()
+		}
+		history.Add(state)
+	}
+}
+
+// recordEntity rebuilds an entity, with its metadata, from its record
+func recordEntity(record Record) (*EntityReference, error) {
+	entityRef, err := thawedEntity(coldStatement{Record: record})
+	if err != nil {
+		return nil, err
+	}
+	if meta := recordMetadata(record.Fields); meta != nil {
+		entityRef.KMACEntity.Metadata.Merge(meta)
+	}
+	return entityRef, nil
+}
+
+// formatVector writes a vector as its comma-separated components
+func formatVector(vector []float32) string {
+	parts := make([]string, len(vector))
+	for i, v := range vector {
+		parts[i] = strconv.FormatFloat(float64(v), 'g', -1, 32)
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseVector reads a vector written by formatVector
+func parseVector(value string) ([]float32, error) {
+	parts := strings.Split(value, ",")
+	vector := make([]float32, len(parts))
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(part, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vector: %v", err)
+		}
+		vector[i] = float32(v)
+	}
+	return vector, nil
+}
+
+// recordTime reads a time a field holds, the zero time if it holds none
+func recordTime(value string) time.Time {
+	at, _ := time.Parse(time.RFC3339Nano, value)
+	return at
+}
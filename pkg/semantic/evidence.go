@@ -0,0 +1,75 @@
+package semantic
+
+import (
+	"fmt"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+// Provenance is where an assertion came from: the source of its confidence,
+// the premises it was derived from, the evidence supporting it, and its
+// retraction if it was withdrawn
+type Provenance struct {
+	AssertionID string
+	Confidence  float64
+	Source      string
+	Premises    []string         // Assertions it was derived from; empty unless derived
+	Evidence    []*kmac.Evidence // In ID order
+	Retraction  *Retraction      // Nil unless retracted
+}
+
+// AddEvidence links an assertion to an external artifact supporting it. The
+// assertion must be in the store. Evidence with the ID of earlier evidence
+// replaces it.
+func (s *SemanticStore) AddEvidence(evidence *kmac.Evidence) error {
+	s.own()
+	if err := kmac.ValidateKMACStatement(evidence); err != nil {
+		return fmt.Errorf("invalid evidence %s: %v", evidence.ID(), err)
+	}
+	if _, exists := s.assertions.row(evidence.AssertionID()); !exists || s.isRemoved(evidence.AssertionID()) {
+		return fmt.Errorf("assertion %s not found", evidence.AssertionID())
+	}
+	s.evidence[evidence.ID()] = evidence
+	return s.journalStatements(evidence)
+}
+
+// EvidenceFor returns the evidence supporting an assertion, in ID order
+func (s *SemanticStore) EvidenceFor(assertionID string) []*kmac.Evidence {
+	var found []*kmac.Evidence
+	for _, id := range sortedIDs(s.evidence) {
+		if evidence := s.evidence[id]; evidence.AssertionID() == assertionID {
+			found = append(found, evidence)
+		}
+	}
+	return found
+}
+
+// Provenance returns where an assertion came from. Retracted assertions keep
+// their provenance; removed ones do not.
+func (s *SemanticStore) Provenance(assertionID string) (*Provenance, error) {
+	row, exists := s.assertions.row(assertionID)
+	if !exists || s.isRemoved(assertionID) {
+		return nil, fmt.Errorf("assertion %s not found", assertionID)
+	}
+	level, source := s.assertions.confidence(row)
+	provenance := &Provenance{
+		AssertionID: assertionID,
+		Confidence:  level,
+		Source:      source,
+		Premises:    s.Premises(assertionID),
+		Evidence:    s.EvidenceFor(assertionID),
+	}
+	if retraction, retracted := s.GetRetraction(assertionID); retracted {
+		provenance.Retraction = retraction
+	}
+	return provenance, nil
+}
+
+// forgetEvidence drops the evidence supporting an assertion
+func (s *SemanticStore) forgetEvidence(assertionID string) {
+	for id, evidence := range s.evidence {
+		if evidence.AssertionID() == assertionID {
+			delete(s.evidence, id)
+		}
+	}
+}
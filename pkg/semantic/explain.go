@@ -0,0 +1,88 @@
+package semantic
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ha1tch/tosid-go/pkg/tosid"
+)
+
+// Explanation says why a result matched a query: the TOSID segments a
+// pattern pinned down, the assertions traversed to reach it, and the
+// inference rules applied along the way
+type Explanation struct {
+	ID         string          // The matching entity, or the related ID
+	Segments   []tosid.Segment // Segments of its TOSID the pattern's literals fall in
+	Assertions []string        // IDs of the assertions traversed, in the order traversed
+	Rules      []string        // Inference rules applied, e.g. "inverse R1002 of R1001"
+}
+
+// ExplainTOSIDPattern finds entities matching a TOSID pattern, as
+// FindEntitiesByTOSIDPattern does, and explains each match by the segments
+// the pattern pinned down. Results are ordered by entity ID.
+func (s *SemanticStore) ExplainTOSIDPattern(pattern string) []*Explanation {
+	explanations, _ := s.ExplainTOSIDPatternContext(context.Background(), pattern)
+	return explanations
+}
+
+// ExplainTOSIDPatternContext explains the entities matching a TOSID pattern
+// as ExplainTOSIDPattern does, stopping early if ctx is done or a frozen
+// entity cannot be read
+func (s *SemanticStore) ExplainTOSIDPatternContext(ctx context.Context, pattern string) ([]*Explanation, error) {
+	var explanations []*Explanation
+	err := s.rangeAllEntities(ctx, func(entityRef *EntityReference) bool {
+		if entityRef.TOSIDObj == nil {
+			return true
+		}
+		if segments := entityRef.TOSIDObj.MatchedSegments(pattern); segments != nil {
+			explanations = append(explanations, &Explanation{ID: entityRef.KMACEntity.ID(), Segments: segments})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(explanations, func(i, j int) bool { return explanations[i].ID < explanations[j].ID })
+	return explanations, nil
+}
+
+// ExplainObjects finds the IDs related to a subject by a relation, as
+// FindObjects does, and explains each by the assertions that relate them,
+// including those of the relation's inverse, and the rules that produced
+// them. Results are ordered by ID.
+func (s *SemanticStore) ExplainObjects(subjectID string, relationID string) []*Explanation {
+	found := make(map[string]*Explanation)
+	explain := func(id string, assertionID string, rules ...string) {
+		explanation, exists := found[id]
+		if !exists {
+			explanation = &Explanation{ID: id}
+			found[id] = explanation
+		}
+		explanation.Assertions = append(explanation.Assertions, assertionID)
+		if premises := s.derivations[assertionID]; len(premises) > 0 {
+			rules = append(rules, fmt.Sprintf("derived %s from %s", assertionID, strings.Join(premises, ", ")))
+		}
+		explanation.Rules = append(explanation.Rules, rules...)
+	}
+
+	for _, assertion := range s.FindAssertionsBySubject(subjectID) {
+		if assertion.Relation() == relationID {
+			explain(assertion.Object(), assertion.ID())
+		}
+	}
+	if inverseID, has := s.Inverse(relationID); has {
+		for _, assertion := range s.FindAssertionsByObject(subjectID) {
+			if assertion.Relation() == inverseID {
+				explain(assertion.Subject(), assertion.ID(), fmt.Sprintf("inverse %s of %s", inverseID, relationID))
+			}
+		}
+	}
+
+	explanations := make([]*Explanation, 0, len(found))
+	for _, id := range sortedIDs(found) {
+		explanations = append(explanations, found[id])
+	}
+	return explanations
+}
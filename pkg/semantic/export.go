@@ -0,0 +1,160 @@
+package semantic
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+// Statements returns the store's contents as KMAC statements, in an order
+// LoadStatements accepts: entities, relations, times, and situations first,
+// then live assertions in the order they were made, then states, temporal
+// qualifications, situation memberships, and evidence. Tags, annotations,
+// notes, and confidence levels are kept; retracted and removed assertions,
+// tombstones, and derivations are not.
+// Entities, relations, and times are the store's own and must not be
+// modified.
+func (s *SemanticStore) Statements() []kmac.Statement {
+	statements, _ := s.StatementsContext(context.Background())
+	return statements
+}
+
+// StatementsContext returns the store's contents as Statements does,
+// returning an error if ctx is done or a frozen statement cannot be read
+// first
+func (s *SemanticStore) StatementsContext(ctx context.Context) ([]kmac.Statement, error) {
+	view, err := s.readView(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return view.exportStatements(), nil
+}
+
+// exportStatements returns the statements in memory, as Statements
+// describes
+func (s *SemanticStore) exportStatements() []kmac.Statement {
+	var statements []kmac.Statement
+	for _, id := range s.sortedEntityIDs() {
+		statements = append(statements, s.entities[id].KMACEntity)
+	}
+	for _, id := range sortedIDs(s.relations) {
+		statements = append(statements, s.relations[id])
+	}
+	for _, id := range sortedIDs(s.times) {
+		statements = append(statements, s.times[id])
+	}
+	for _, situation := range s.sortedSituations() {
+		statements = append(statements, situation)
+	}
+	for row := 0; row < s.assertions.span(); row++ {
+		if !s.assertions.holds(row) {
+			continue
+		}
+		if !s.assertions.isRetracted(row) {
+			statements = append(statements, s.materialize(row))
+		}
+	}
+	for _, id := range sortedIDs(s.states) {
+		history := s.states[id]
+		for _, attribute := range history.Attributes() {
+			for _, state := range history.History(attribute) {
+				statements = append(statements, state)
+			}
+		}
+	}
+	for _, id := range sortedIDs(s.temporals) {
+		if row, exists := s.assertions.row(id); exists && !s.assertions.isRetracted(row) {
+			statements = append(statements, s.temporals[id])
+		}
+	}
+	for _, situationID := range sortedIDs(s.situationMembers) {
+		for _, assertion := range s.FindAssertionsInSituation(situationID, false) {
+			member, _ := kmac.NewSituationMember(assertion.ID(), situationID)
+			statements = append(statements, member)
+		}
+	}
+	for _, id := range sortedIDs(s.evidence) {
+		evidence := s.evidence[id]
+		if row, exists := s.assertions.row(evidence.AssertionID()); exists && !s.assertions.isRetracted(row) {
+			statements = append(statements, evidence)
+		}
+	}
+	return statements
+}
+
+// WriteKMAC writes the store's statements to w as KMAC text, which LoadKMAC
+// reads back
+func (s *SemanticStore) WriteKMAC(w io.Writer) error {
+	return s.WriteCompressedKMACContext(context.Background(), w, kmac.CompressionNone)
+}
+
+// WriteKMACContext writes the store's statements to w as KMAC text,
+// stopping early if ctx is done
+func (s *SemanticStore) WriteKMACContext(ctx context.Context, w io.Writer) error {
+	return s.WriteCompressedKMACContext(ctx, w, kmac.CompressionNone)
+}
+
+// WriteCompressedKMAC writes the store's statements to w as compressed KMAC
+// text. LoadKMAC recognizes the compression and reads it back.
+func (s *SemanticStore) WriteCompressedKMAC(w io.Writer, compression kmac.Compression) error {
+	return s.WriteCompressedKMACContext(context.Background(), w, compression)
+}
+
+// WriteCompressedKMACContext writes the store's statements to w as
+// compressed KMAC text, stopping early if ctx is done. What was written
+// before it stopped is left in w.
+func (s *SemanticStore) WriteCompressedKMACContext(ctx context.Context, w io.Writer, compression kmac.Compression) error {
+	statements, err := s.StatementsContext(ctx)
+	if err != nil {
+		return err
+	}
+	if err := kmac.NewCompressedTextSerializer(compression).Encode(contextWriter{ctx: ctx, w: w}, statements); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return fmt.Errorf("failed to encode KMAC: %v", err)
+	}
+	return nil
+}
+
+// contextWriter writes to w until ctx is done
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (cw contextWriter) Write(p []byte) (int, error) {
+	if err := cw.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cw.w.Write(p)
+}
+
+// Statements returns the snapshot's contents as KMAC statements
+func (sn *Snapshot) Statements() []kmac.Statement {
+	return sn.store.Statements()
+}
+
+// WriteKMAC writes the snapshot's statements to w as KMAC text
+func (sn *Snapshot) WriteKMAC(w io.Writer) error {
+	return sn.store.WriteKMAC(w)
+}
+
+// WriteCompressedKMAC writes the snapshot's statements to w as compressed
+// KMAC text
+func (sn *Snapshot) WriteCompressedKMAC(w io.Writer, compression kmac.Compression) error {
+	return sn.store.WriteCompressedKMAC(w, compression)
+}
+
+// sortedIDs returns the keys of a map, in order
+func sortedIDs[V any](m map[string]V) []string {
+	ids := make([]string, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,130 @@
+package semantic
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// LabelMatch is an entity found by approximate label matching
+type LabelMatch struct {
+	Entity     *EntityReference
+	Label      string  // The label that matched best: the entity's own, one in another language, or a synonym
+	Similarity float64 // From 0 to 1, 1 for labels that normalize the same
+}
+
+// FindEntitiesByLabelFuzzy finds entities with a label similar to a query,
+// most similar first, so misspellings and variant punctuation still find
+// them. Labels are compared normalized, lowercased with everything but
+// letters and digits removed, so "Kepler 186-f" matches "Kepler-186f"
+// exactly; similarity is the Jaro-Winkler similarity of the normalized
+// labels. Every label of an entity is tried, including those added by
+// AddLabel, and entities whose best label is less similar than threshold
+// are left out.
+func (s *SemanticStore) FindEntitiesByLabelFuzzy(query string, threshold float64) []LabelMatch {
+	results, _ := s.FindEntitiesByLabelFuzzyContext(context.Background(), query, threshold)
+	return results
+}
+
+// FindEntitiesByLabelFuzzyContext finds entities with a label similar to a
+// query as FindEntitiesByLabelFuzzy does, stopping early if ctx is done or
+// a frozen entity cannot be read
+func (s *SemanticStore) FindEntitiesByLabelFuzzyContext(ctx context.Context, query string, threshold float64) ([]LabelMatch, error) {
+	normalized := normalizeLabel(query)
+	var results []LabelMatch
+	err := s.rangeAllEntities(ctx, func(entityRef *EntityReference) bool {
+		best := LabelMatch{Entity: entityRef, Similarity: -1}
+		labels := []string{entityRef.KMACEntity.Label()}
+		for _, localized := range localizedLabels(&entityRef.KMACEntity.Metadata) {
+			labels = append(labels, localized.Text)
+		}
+		for _, label := range labels {
+			if similarity := jaroWinkler(normalized, normalizeLabel(label)); similarity > best.Similarity {
+				best.Label, best.Similarity = label, similarity
+			}
+		}
+		if best.Similarity >= threshold {
+			results = append(results, best)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Similarity != results[j].Similarity {
+			return results[i].Similarity > results[j].Similarity
+		}
+		return results[i].Entity.KMACEntity.ID() < results[j].Entity.KMACEntity.ID()
+	})
+	return results, nil
+}
+
+// normalizeLabel lowercases a label and keeps only its letters and digits
+func normalizeLabel(label string) []rune {
+	var runes []rune
+	for _, r := range strings.ToLower(label) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			runes = append(runes, r)
+		}
+	}
+	return runes
+}
+
+// jaroWinkler returns the Jaro-Winkler similarity of two strings, from 0 for
+// nothing in common to 1 for equal strings
+func jaroWinkler(a []rune, b []rune) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	// Characters match if equal and no further apart than the window
+	window := max(len(a), len(b))/2 - 1
+	if window < 0 {
+		window = 0
+	}
+	matchedA := make([]bool, len(a))
+	matchedB := make([]bool, len(b))
+	matches := 0
+	for i := range a {
+		for j := max(0, i-window); j < min(len(b), i+window+1); j++ {
+			if !matchedB[j] && a[i] == b[j] {
+				matchedA[i], matchedB[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	// Half the matched characters out of order are transpositions
+	transpositions := 0
+	j := 0
+	for i := range a {
+		if !matchedA[i] {
+			continue
+		}
+		for !matchedB[j] {
+			j++
+		}
+		if a[i] != b[j] {
+			transpositions++
+		}
+		j++
+	}
+	m := float64(matches)
+	jaro := (m/float64(len(a)) + m/float64(len(b)) + (m-float64(transpositions)/2)/m) / 3
+
+	// Winkler's boost for a common prefix of up to four characters
+	prefix := 0
+	for prefix < min(4, len(a), len(b)) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
@@ -0,0 +1,291 @@
+package semantic
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// HNSWOptions configures the approximate nearest-neighbour index used by
+// FindSimilarEntities
+type HNSWOptions struct {
+	M              int   // Neighbours kept per node and layer; defaults to 16
+	EfConstruction int   // Candidate list size while inserting; defaults to 200
+	EfSearch       int   // Candidate list size while searching; defaults to 64
+	Seed           int64 // Seed for level assignment, so builds are reproducible
+}
+
+// withDefaults fills in unset options
+func (o HNSWOptions) withDefaults() HNSWOptions {
+	if o.M <= 0 {
+		o.M = 16
+	}
+	if o.EfConstruction <= 0 {
+		o.EfConstruction = 200
+	}
+	if o.EfSearch <= 0 {
+		o.EfSearch = 64
+	}
+	return o
+}
+
+// hnswNode is one vector in the graph. Replaced and removed vectors stay in
+// the graph as deleted nodes so the links through them keep working.
+type hnswNode struct {
+	id        string
+	vector    []float32 // Unit length
+	neighbors [][]int   // Per layer, from 0 up to the node's level
+	deleted   bool
+}
+
+// hnswIndex is a hierarchical navigable small world graph over unit vectors,
+// using cosine distance
+type hnswIndex struct {
+	opts     HNSWOptions
+	nodes    []*hnswNode
+	byID     map[string]int
+	deleted  int // Deleted nodes still in the graph
+	entry    int
+	maxLevel int
+	levelMul float64
+	rng      *rand.Rand
+}
+
+// newHNSWIndex creates an empty index
+func newHNSWIndex(opts HNSWOptions) *hnswIndex {
+	opts = opts.withDefaults()
+	return &hnswIndex{
+		opts:     opts,
+		byID:     make(map[string]int),
+		entry:    -1,
+		levelMul: 1 / math.Log(float64(opts.M)),
+		rng:      rand.New(rand.NewSource(opts.Seed)),
+	}
+}
+
+// insert adds a unit vector, replacing any earlier vector for the same ID
+func (h *hnswIndex) insert(id string, vector []float32) {
+	if _, exists := h.byID[id]; exists {
+		h.remove(id)
+	}
+
+	level := int(-math.Log(1-h.rng.Float64()) * h.levelMul)
+	node := &hnswNode{id: id, vector: vector, neighbors: make([][]int, level+1)}
+	index := len(h.nodes)
+	h.nodes = append(h.nodes, node)
+	h.byID[id] = index
+
+	if h.entry < 0 {
+		h.entry, h.maxLevel = index, level
+		return
+	}
+
+	current := h.entry
+	for layer := h.maxLevel; layer > level; layer-- {
+		current = h.searchLayer(vector, current, 1, layer)[0].node
+	}
+	for layer := minInt(level, h.maxLevel); layer >= 0; layer-- {
+		candidates := h.searchLayer(vector, current, h.opts.EfConstruction, layer)
+		limit := h.maxNeighbors(layer)
+		for i := 0; i < len(candidates) && i < limit; i++ {
+			neighbor := candidates[i].node
+			node.neighbors[layer] = append(node.neighbors[layer], neighbor)
+			h.link(neighbor, index, layer)
+		}
+		current = candidates[0].node
+	}
+
+	if level > h.maxLevel {
+		h.entry, h.maxLevel = index, level
+	}
+}
+
+// remove deletes an ID's vector. Once deleted nodes outnumber live ones,
+// the graph is rebuilt without them.
+func (h *hnswIndex) remove(id string) {
+	index, exists := h.byID[id]
+	if !exists {
+		return
+	}
+	h.nodes[index].deleted = true
+	delete(h.byID, id)
+	h.deleted++
+	if h.deleted > len(h.byID) {
+		h.rebuild()
+	}
+}
+
+// rebuild builds the graph again from its live nodes, in the order they
+// were inserted
+func (h *hnswIndex) rebuild() {
+	nodes := h.nodes
+	*h = *newHNSWIndex(h.opts)
+	for _, node := range nodes {
+		if !node.deleted {
+			h.insert(node.id, node.vector)
+		}
+	}
+}
+
+// link adds an edge from one node to another, keeping only the closest
+// neighbours when the node has too many
+func (h *hnswIndex) link(from int, to int, layer int) {
+	node := h.nodes[from]
+	node.neighbors[layer] = append(node.neighbors[layer], to)
+	limit := h.maxNeighbors(layer)
+	if len(node.neighbors[layer]) <= limit {
+		return
+	}
+
+	ranked := make([]hnswCandidate, len(node.neighbors[layer]))
+	for i, neighbor := range node.neighbors[layer] {
+		ranked[i] = hnswCandidate{neighbor, cosineDistance(node.vector, h.nodes[neighbor].vector)}
+	}
+	sortCandidates(ranked)
+	kept := node.neighbors[layer][:0]
+	for _, c := range ranked[:limit] {
+		kept = append(kept, c.node)
+	}
+	node.neighbors[layer] = kept
+}
+
+// maxNeighbors returns the neighbour limit of a layer; layer 0 is denser
+func (h *hnswIndex) maxNeighbors(layer int) int {
+	if layer == 0 {
+		return 2 * h.opts.M
+	}
+	return h.opts.M
+}
+
+// search returns up to k live nodes closest to the query that accept allows,
+// widening the search until enough are found or the graph is exhausted
+func (h *hnswIndex) search(query []float32, k int, accept func(id string) bool) []hnswCandidate {
+	if h.entry < 0 || k <= 0 {
+		return nil
+	}
+
+	current := h.entry
+	for layer := h.maxLevel; layer > 0; layer-- {
+		current = h.searchLayer(query, current, 1, layer)[0].node
+	}
+
+	ef := maxInt(h.opts.EfSearch, k)
+	for {
+		var results []hnswCandidate
+		candidates := h.searchLayer(query, current, ef, 0)
+		for _, c := range candidates {
+			node := h.nodes[c.node]
+			if node.deleted || !accept(node.id) {
+				continue
+			}
+			results = append(results, c)
+			if len(results) == k {
+				return results
+			}
+		}
+		if ef >= len(h.nodes) {
+			return results
+		}
+		ef *= 2
+	}
+}
+
+// searchLayer finds the ef nodes closest to the query within one layer,
+// starting from an entry node, sorted by distance
+func (h *hnswIndex) searchLayer(query []float32, entry int, ef int, layer int) []hnswCandidate {
+	start := hnswCandidate{entry, cosineDistance(query, h.nodes[entry].vector)}
+	visited := map[int]bool{entry: true}
+	candidates := &candidateHeap{items: []hnswCandidate{start}}
+	results := &candidateHeap{items: []hnswCandidate{start}, farthestFirst: true}
+
+	for candidates.Len() > 0 {
+		closest := heap.Pop(candidates).(hnswCandidate)
+		if closest.distance > results.items[0].distance && results.Len() >= ef {
+			break
+		}
+		for _, neighbor := range h.nodes[closest.node].neighbors[layer] {
+			if visited[neighbor] {
+				continue
+			}
+			visited[neighbor] = true
+			c := hnswCandidate{neighbor, cosineDistance(query, h.nodes[neighbor].vector)}
+			if results.Len() < ef || c.distance < results.items[0].distance {
+				heap.Push(candidates, c)
+				heap.Push(results, c)
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	sorted := append([]hnswCandidate(nil), results.items...)
+	sortCandidates(sorted)
+	return sorted
+}
+
+// hnswCandidate is a node and its distance from a query
+type hnswCandidate struct {
+	node     int
+	distance float64
+}
+
+// candidateHeap is a heap of candidates, nearest first unless farthestFirst is set
+type candidateHeap struct {
+	items         []hnswCandidate
+	farthestFirst bool
+}
+
+func (c *candidateHeap) Len() int { return len(c.items) }
+func (c *candidateHeap) Less(i, j int) bool {
+	if c.farthestFirst {
+		return c.items[i].distance > c.items[j].distance
+	}
+	return c.items[i].distance < c.items[j].distance
+}
+func (c *candidateHeap) Swap(i, j int)      { c.items[i], c.items[j] = c.items[j], c.items[i] }
+func (c *candidateHeap) Push(x interface{}) { c.items = append(c.items, x.(hnswCandidate)) }
+func (c *candidateHeap) Pop() interface{} {
+	last := c.items[len(c.items)-1]
+	c.items = c.items[:len(c.items)-1]
+	return last
+}
+
+// sortCandidates orders candidates nearest first
+func sortCandidates(candidates []hnswCandidate) {
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].node < candidates[j].node
+	})
+}
+
+// cosineDistance returns 1 minus the cosine similarity of two unit vectors
+func cosineDistance(a []float32, b []float32) float64 {
+	return 1 - dot(a, b)
+}
+
+// dot returns the dot product of two equal-length vectors
+func dot(a []float32, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,126 @@
+package semantic
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Impact lists what removing an entity would invalidate, so the
+// consequences can be assessed before a removal or cleanup
+type Impact struct {
+	EntityID   string
+	Removed    []string // Live assertions removed with it: those referring to it, and those about them
+	Retracted  []string // Assertions derived from removed ones, retracted since a premise is gone
+	States     []string // IDs of the state assertions in its history
+	Temporals  []string // Removed and retracted assertions with a temporal qualification
+	Evidence   []string // IDs of the evidence supporting removed and retracted assertions
+	Situations []string // Situations that would lose members
+	Vector     bool     // Whether it has an embedding vector, which goes with it
+}
+
+// Lines renders the impact one consequence per line, in the style of
+// CleanupReport
+func (i *Impact) Lines() []string {
+	var lines []string
+	for _, id := range i.Removed {
+		lines = append(lines, fmt.Sprintf("assertion %s would be removed", id))
+	}
+	for _, id := range i.Retracted {
+		lines = append(lines, fmt.Sprintf("assertion %s would be retracted", id))
+	}
+	for _, id := range i.States {
+		lines = append(lines, fmt.Sprintf("state %s would be dropped", id))
+	}
+	for _, id := range i.Temporals {
+		lines = append(lines, fmt.Sprintf("temporal qualification of %s would be invalidated", id))
+	}
+	for _, id := range i.Evidence {
+		lines = append(lines, fmt.Sprintf("evidence %s would be invalidated", id))
+	}
+	for _, id := range i.Situations {
+		lines = append(lines, fmt.Sprintf("situation %s would lose members", id))
+	}
+	if i.Vector {
+		lines = append(lines, fmt.Sprintf("vector of %s would be dropped", i.EntityID))
+	}
+	return lines
+}
+
+// ImpactOf reports what RemoveEntity would invalidate without changing the
+// store: the assertions removed and retracted along with the entity, and the
+// state, temporal qualifications, evidence, situation memberships, and
+// vector attached to them
+func (s *SemanticStore) ImpactOf(entityID string) (*Impact, error) {
+	if err := s.thawAbout(entityID); err != nil {
+		return nil, err
+	}
+	if _, exists := s.entities[entityID]; !exists {
+		return nil, fmt.Errorf("entity %s not found", entityID)
+	}
+	impact := &Impact{EntityID: entityID}
+
+	// The same cascade as removeReferencing, then retractDependents
+	removed := make(map[string]bool)
+	queue := []string{entityID}
+	for len(queue) > 0 {
+		causeID := queue[0]
+		queue = queue[1:]
+		for _, row := range s.assertions.live(s.assertions.rowsReferencing(causeID)) {
+			if id := s.assertions.id(row); !removed[id] {
+				removed[id] = true
+				queue = append(queue, id)
+			}
+		}
+	}
+	retracted := make(map[string]bool)
+	queue = sortedIDs(removed)
+	for len(queue) > 0 {
+		premiseID := queue[0]
+		queue = queue[1:]
+		for _, derivedID := range s.dependents[premiseID] {
+			if removed[derivedID] || retracted[derivedID] {
+				continue
+			}
+			if existing, isRetracted := s.retractions[derivedID]; isRetracted && !existing.LowConfidence {
+				continue
+			}
+			retracted[derivedID] = true
+			queue = append(queue, derivedID)
+		}
+	}
+	impact.Removed = sortedIDs(removed)
+	impact.Retracted = sortedIDs(retracted)
+
+	if history, exists := s.states[entityID]; exists {
+		for _, attribute := range history.Attributes() {
+			for _, state := range history.History(attribute) {
+				impact.States = append(impact.States, state.ID())
+			}
+		}
+		sort.Strings(impact.States)
+	}
+
+	invalidated := func(assertionID string) bool {
+		return removed[assertionID] || retracted[assertionID]
+	}
+	for _, id := range sortedIDs(s.temporals) {
+		if invalidated(id) {
+			impact.Temporals = append(impact.Temporals, id)
+		}
+	}
+	for _, id := range sortedIDs(s.evidence) {
+		if invalidated(s.evidence[id].AssertionID()) {
+			impact.Evidence = append(impact.Evidence, id)
+		}
+	}
+	for _, id := range sortedIDs(s.situationMembers) {
+		for _, member := range s.situationMembers[id] {
+			if invalidated(member) {
+				impact.Situations = append(impact.Situations, id)
+				break
+			}
+		}
+	}
+	_, impact.Vector = s.vectors[entityID]
+	return impact, nil
+}
@@ -0,0 +1,150 @@
+package semantic
+
+import (
+	"fmt"
+	"sort"
+)
+
+// IntegrityMode is how a store treats assertions that reference unknown IDs
+type IntegrityMode string
+
+const (
+	// IntegrityLax requires an assertion's subject and object to exist but
+	// not its relation. It is the default.
+	IntegrityLax IntegrityMode = "LAX"
+	// IntegrityStrict also requires the relation to be defined or built in
+	IntegrityStrict IntegrityMode = "STRICT"
+	// IntegrityDeferred accepts assertions whose subject or object is unknown
+	// and tracks them as pending until the entity or assertion arrives, for
+	// bulk imports that deliver assertions before their entities
+	IntegrityDeferred IntegrityMode = "DEFERRED"
+)
+
+// builtInRelations may be used without being defined
+var builtInRelations = map[string]bool{"AGENT": true, "LOCATION": true, "OCCURRED_AT": true, "INSTANCE_OF": true}
+
+// PendingReference is an unknown ID that assertions are waiting for
+type PendingReference struct {
+	ID           string
+	AssertionIDs []string
+}
+
+// SetIntegrityMode sets how assertions referencing unknown IDs are treated.
+// Leaving deferred mode fails while references are still pending.
+func (s *SemanticStore) SetIntegrityMode(mode IntegrityMode) error {
+	s.own()
+	switch mode {
+	case IntegrityLax, IntegrityStrict, IntegrityDeferred:
+	default:
+		return fmt.Errorf("unknown integrity mode %s", mode)
+	}
+	if mode != IntegrityDeferred && len(s.pending) > 0 {
+		return fmt.Errorf("cannot leave deferred mode with %d pending references", len(s.pending))
+	}
+	s.integrity = mode
+	return nil
+}
+
+// IntegrityMode returns how assertions referencing unknown IDs are treated
+func (s *SemanticStore) IntegrityMode() IntegrityMode {
+	if s.integrity == "" {
+		return IntegrityLax
+	}
+	return s.integrity
+}
+
+// PendingReferences returns the unknown IDs that assertions are waiting for,
+// sorted by ID
+func (s *SemanticStore) PendingReferences() []PendingReference {
+	ids := make([]string, 0, len(s.pending))
+	for id := range s.pending {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	references := make([]PendingReference, 0, len(ids))
+	for _, id := range ids {
+		assertionIDs := append([]string(nil), s.pending[id]...)
+		sort.Strings(assertionIDs)
+		references = append(references, PendingReference{ID: id, AssertionIDs: assertionIDs})
+	}
+	return references
+}
+
+// hasRelation reports whether a relation is defined or built in
+func (s *SemanticStore) hasRelation(id string) bool {
+	_, exists := s.relations[id]
+	return exists || builtInRelations[id]
+}
+
+// checkReferences applies the integrity mode to a new assertion, returning
+// the unknown IDs it must wait for in deferred mode
+func (s *SemanticStore) checkReferences(subjectID string, relationID string, objectID string) ([]string, error) {
+	for _, id := range []string{subjectID, objectID} {
+		if err := s.thawStatement(id); err != nil {
+			return nil, err
+		}
+	}
+	if s.IntegrityMode() == IntegrityDeferred {
+		var missing []string
+		for _, id := range []string{subjectID, objectID} {
+			if !s.hasNode(id) {
+				missing = append(missing, id)
+			}
+		}
+		return missing, nil
+	}
+
+	// Verify that subject and object exist
+	if err := s.checkNode(subjectID); err != nil {
+		return nil, fmt.Errorf("subject not found: %v", err)
+	}
+	if err := s.checkNode(objectID); err != nil {
+		return nil, fmt.Errorf("object not found: %v", err)
+	}
+	if s.IntegrityMode() == IntegrityStrict && !s.hasRelation(relationID) {
+		return nil, fmt.Errorf("relation not found: relation %s not found", relationID)
+	}
+	return nil, nil
+}
+
+// addPending records that an assertion waits for unknown IDs
+func (s *SemanticStore) addPending(assertionID string, missing []string) {
+	for _, id := range missing {
+		if !containsString(s.pending[id], assertionID) {
+			s.pending[id] = append(s.pending[id], assertionID)
+		}
+	}
+}
+
+// forgetPending drops an assertion from the pending references, as when it
+// is replaced or removed
+func (s *SemanticStore) forgetPending(assertionID string) {
+	for id, assertionIDs := range s.pending {
+		for i, pendingID := range assertionIDs {
+			if pendingID == assertionID {
+				assertionIDs = append(assertionIDs[:i], assertionIDs[i+1:]...)
+				break
+			}
+		}
+		if len(assertionIDs) == 0 {
+			delete(s.pending, id)
+		} else {
+			s.pending[id] = assertionIDs
+		}
+	}
+}
+
+// resolvePending marks an ID as arrived
+func (s *SemanticStore) resolvePending(id string) {
+	delete(s.pending, id)
+}
+
+func containsString(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package semantic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Invariant is a rule of an application's own that its store must keep, such
+// as every depot having a location. Check returns a description of each
+// violation, none when the store keeps the rule, and must only read the
+// store.
+type Invariant struct {
+	Name       string
+	Check      func(s *SemanticStore) []string
+	OnMutation bool // Also check after every mutation, not only when the store is validated
+}
+
+// InvariantError reports the invariants a mutation broke. The mutation is
+// kept, and logged, so the caller can undo or correct it.
+type InvariantError struct {
+	Violations []string // Each as "invariant <name>: <violation>"
+}
+
+// Error lists the violations
+func (e *InvariantError) Error() string {
+	return strings.Join(e.Violations, "; ")
+}
+
+// RegisterInvariant adds an invariant to the store, replacing any of the
+// same name. ValidateStore reports its violations; one checked on mutation
+// also makes every mutation that leaves it violated return an
+// *InvariantError.
+func (s *SemanticStore) RegisterInvariant(invariant Invariant) error {
+	if !queryNamePattern.MatchString(invariant.Name) {
+		return fmt.Errorf("invalid invariant name %q", invariant.Name)
+	}
+	if invariant.Check == nil {
+		return fmt.Errorf("invariant %s has no check", invariant.Name)
+	}
+	if s.invariants == nil {
+		s.invariants = make(map[string]Invariant)
+	}
+	s.invariants[invariant.Name] = invariant
+	return nil
+}
+
+// UnregisterInvariant removes an invariant, reporting whether it was registered
+func (s *SemanticStore) UnregisterInvariant(name string) bool {
+	_, exists := s.invariants[name]
+	delete(s.invariants, name)
+	return exists
+}
+
+// Invariants returns the registered invariants, ordered by name
+func (s *SemanticStore) Invariants() []Invariant {
+	invariants := make([]Invariant, 0, len(s.invariants))
+	for _, name := range sortedIDs(s.invariants) {
+		invariants = append(invariants, s.invariants[name])
+	}
+	return invariants
+}
+
+// CheckInvariants runs every registered invariant and returns their
+// violations, by invariant name
+func (s *SemanticStore) CheckInvariants() []string {
+	violations, _ := s.runInvariants(context.Background(), false)
+	return violations
+}
+
+// checkMutationInvariants runs the invariants checked on mutation
+func (s *SemanticStore) checkMutationInvariants() error {
+	if len(s.invariants) == 0 {
+		return nil
+	}
+	if violations, _ := s.runInvariants(context.Background(), true); len(violations) > 0 {
+		return &InvariantError{Violations: violations}
+	}
+	return nil
+}
+
+// runInvariants runs the registered invariants, or only those checked on
+// mutation, stopping early if ctx is done
+func (s *SemanticStore) runInvariants(ctx context.Context, onMutation bool) ([]string, error) {
+	// A check that writes to the store anyway must not set off the checks again
+	done := s.nest()
+	defer done()
+
+	var violations []string
+	for _, name := range sortedIDs(s.invariants) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		invariant := s.invariants[name]
+		if onMutation && !invariant.OnMutation {
+			continue
+		}
+		for _, violation := range invariant.Check(s) {
+			violations = append(violations, fmt.Sprintf("invariant %s: %s", name, violation))
+		}
+	}
+	return violations, nil
+}
@@ -0,0 +1,123 @@
+package semantic
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+// DeclareInverse declares that two relations hold in opposite directions,
+// such as SUPPLIES and SUPPLIED_BY, so that A SUPPLIES B means B SUPPLIED_BY
+// A. A relation declared its own inverse is symmetric. The declaration is
+// recorded as an "inverse" property on both relations, so it is kept in
+// KMAC exports.
+func (s *SemanticStore) DeclareInverse(relationID string, inverseID string) error {
+	s.own()
+	relation, exists := s.relations[relationID]
+	if !exists {
+		return fmt.Errorf("relation %s not found", relationID)
+	}
+	inverse, exists := s.relations[inverseID]
+	if !exists {
+		return fmt.Errorf("relation %s not found", inverseID)
+	}
+	if declared, has := relation.Inverse(); has && declared != inverseID {
+		return fmt.Errorf("relation %s already has inverse %s", relationID, declared)
+	}
+	if declared, has := inverse.Inverse(); has && declared != relationID {
+		return fmt.Errorf("relation %s already has inverse %s", inverseID, declared)
+	}
+
+	relation.SetProperty("inverse", inverseID)
+	inverse.SetProperty("inverse", relationID)
+	s.recordChanged(RecordRelation, relationID)
+	s.recordChanged(RecordRelation, inverseID)
+	return s.journal(walInverse, relationID, inverseID)
+}
+
+// Inverse returns the relation declared the inverse of a relation
+func (s *SemanticStore) Inverse(relationID string) (string, bool) {
+	relation, exists := s.relations[relationID]
+	if !exists {
+		return "", false
+	}
+	return relation.Inverse()
+}
+
+// FindObjects returns the IDs related to a subject by a relation, ordered by
+// ID. Assertions of the relation's inverse count in the opposite direction,
+// so the same answer comes whichever of the two relations was asserted.
+func (s *SemanticStore) FindObjects(subjectID string, relationID string) []string {
+	explanations := s.ExplainObjects(subjectID, relationID)
+	ids := make([]string, 0, len(explanations))
+	for _, explanation := range explanations {
+		ids = append(ids, explanation.ID)
+	}
+	return ids
+}
+
+// MaterializeInverses creates the inverse of every assertion whose relation
+// has a declared inverse and whose inverse is not already asserted. Each is
+// a derived assertion with the original as its premise, so it follows the
+// original's confidence and is retracted with it. It returns the IDs of the
+// assertions created, which are the first unused assertion IDs.
+func (s *SemanticStore) MaterializeInverses() ([]string, error) {
+	s.own()
+	var candidates []*kmac.Assertion
+	for _, relationID := range s.sortedRelationIDs() {
+		if _, has := s.Inverse(relationID); has {
+			candidates = append(candidates, s.FindAssertionsByRelation(relationID)...)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].ID() < candidates[j].ID() })
+
+	var created []string
+	next := 1
+	for _, assertion := range candidates {
+		inverseID, _ := s.Inverse(assertion.Relation())
+		if s.asserts(assertion.Object(), inverseID, assertion.Subject()) {
+			continue
+		}
+		var id string
+		id, next = s.unusedAssertionID(next)
+		if err := s.CreateDerivedAssertion(id, assertion.Object(), inverseID, assertion.Subject(), []string{assertion.ID()}); err != nil {
+			return created, fmt.Errorf("inverse of %s: %v", assertion.ID(), err)
+		}
+		created = append(created, id)
+	}
+	return created, nil
+}
+
+// asserts reports whether a live assertion relates a subject to an object
+func (s *SemanticStore) asserts(subjectID string, relationID string, objectID string) bool {
+	for _, assertion := range s.FindAssertionsBySubject(subjectID) {
+		if assertion.Relation() == relationID && assertion.Object() == objectID {
+			return true
+		}
+	}
+	return false
+}
+
+// unusedAssertionID returns the first assertion ID numbered n or above that
+// no assertion, live or removed, has taken, and the number to continue from
+func (s *SemanticStore) unusedAssertionID(n int) (string, int) {
+	for {
+		id := kmac.AssertionIDPrefix + strconv.Itoa(n)
+		n++
+		if _, taken := s.assertions.row(id); !taken && !s.isRemoved(id) {
+			return id, n
+		}
+	}
+}
+
+// sortedRelationIDs returns the IDs of the stored relations in order
+func (s *SemanticStore) sortedRelationIDs() []string {
+	ids := make([]string, 0, len(s.relations))
+	for id := range s.relations {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,164 @@
+package semantic
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+// labelAnnotationPrefix starts the annotation keys holding the labels of an
+// entity or relation in other languages. The key ends with the language
+// code, empty for synonyms in no particular language, and the value is the
+// labels one per line, so they travel with the statement's other metadata.
+const labelAnnotationPrefix = "label@"
+
+// LocalizedLabel is a label of an entity or relation besides its own
+type LocalizedLabel struct {
+	Lang string // Language code, such as "fr"; empty for a synonym
+	Text string
+}
+
+// labeledMetadata returns the metadata of an entity or relation
+func (s *SemanticStore) labeledMetadata(id string) (*kmac.Metadata, error) {
+	if err := s.thawStatement(id); err != nil {
+		return nil, err
+	}
+	if entityRef, exists := s.entities[id]; exists {
+		return &entityRef.KMACEntity.Metadata, nil
+	}
+	if relation, exists := s.relations[id]; exists {
+		return &relation.Metadata, nil
+	}
+	return nil, fmt.Errorf("entity or relation %s not found", id)
+}
+
+// AddLabel adds a label in a language to an entity or relation, so label
+// queries find it by what other organizations and countries call it.
+// Adding a label it already has does nothing.
+func (s *SemanticStore) AddLabel(id string, lang string, text string) error {
+	s.own()
+	meta, err := s.labeledMetadata(id)
+	if err != nil {
+		return err
+	}
+	text = strings.TrimSpace(text)
+	if text == "" || strings.ContainsAny(text, "\r\n") {
+		return fmt.Errorf("invalid label %q", text)
+	}
+	if strings.ContainsAny(lang, "\r\n") {
+		return fmt.Errorf("invalid language %q", lang)
+	}
+
+	key := labelAnnotationPrefix + lang
+	existing, _ := meta.Annotation(key)
+	labels := splitLabels(existing)
+	for _, label := range labels {
+		if label == text {
+			return nil
+		}
+	}
+	return s.Annotate(id, key, strings.Join(append(labels, text), "\n"))
+}
+
+// AddSynonym adds a label in no particular language to an entity or relation
+func (s *SemanticStore) AddSynonym(id string, text string) error {
+	s.own()
+	return s.AddLabel(id, "", text)
+}
+
+// Labels returns the labels added to an entity or relation, ordered by
+// language and then as added
+func (s *SemanticStore) Labels(id string) ([]LocalizedLabel, error) {
+	meta, err := s.labeledMetadata(id)
+	if err != nil {
+		return nil, err
+	}
+	return localizedLabels(meta), nil
+}
+
+// LabelIn returns the first label of an entity or relation in a language,
+// or its own label if it has none in that language
+func (s *SemanticStore) LabelIn(id string, lang string) (string, error) {
+	if err := s.thawStatement(id); err != nil {
+		return "", err
+	}
+	if entityRef, exists := s.entities[id]; exists {
+		return labelIn(entityRef.KMACEntity.Label(), &entityRef.KMACEntity.Metadata, lang), nil
+	}
+	if relation, exists := s.relations[id]; exists {
+		return labelIn(relation.Label(), &relation.Metadata, lang), nil
+	}
+	return "", fmt.Errorf("entity or relation %s not found", id)
+}
+
+// FindRelationsByLabel finds relations whose label, in any language, or
+// synonym contains a pattern, case-insensitively, ordered by ID
+func (s *SemanticStore) FindRelationsByLabel(labelPattern string) []*kmac.Relation {
+	pattern := strings.ToLower(labelPattern)
+	var results []*kmac.Relation
+	for _, id := range sortedIDs(s.relations) {
+		relation := s.relations[id]
+		if labelMatches(relation.Label(), &relation.Metadata, pattern) {
+			results = append(results, relation)
+		}
+	}
+	return results
+}
+
+// labelIn returns the first label in a language, falling back to the
+// statement's own label
+func labelIn(label string, meta *kmac.Metadata, lang string) string {
+	if labels, exists := meta.Annotation(labelAnnotationPrefix + lang); exists {
+		if split := splitLabels(labels); len(split) > 0 {
+			return split[0]
+		}
+	}
+	return label
+}
+
+// labelMatches reports whether a statement's own label or any label added to
+// it contains a lowercase pattern
+func labelMatches(label string, meta *kmac.Metadata, pattern string) bool {
+	if strings.Contains(strings.ToLower(label), pattern) {
+		return true
+	}
+	for _, localized := range localizedLabels(meta) {
+		if strings.Contains(strings.ToLower(localized.Text), pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// localizedLabels returns the labels held in a statement's annotations
+func localizedLabels(meta *kmac.Metadata) []LocalizedLabel {
+	annotations := meta.Annotations()
+	var langs []string
+	for key := range annotations {
+		if lang, isLabel := strings.CutPrefix(key, labelAnnotationPrefix); isLabel {
+			langs = append(langs, lang)
+		}
+	}
+	sort.Strings(langs)
+
+	var labels []LocalizedLabel
+	for _, lang := range langs {
+		for _, text := range splitLabels(annotations[labelAnnotationPrefix+lang]) {
+			labels = append(labels, LocalizedLabel{Lang: lang, Text: text})
+		}
+	}
+	return labels
+}
+
+// splitLabels splits an annotation value into its labels
+func splitLabels(value string) []string {
+	var labels []string
+	for _, label := range strings.Split(value, "\n") {
+		if label != "" {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
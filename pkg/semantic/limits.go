@@ -0,0 +1,375 @@
+package semantic
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// EvictionPolicy chooses what a bounded store drops when it is full
+type EvictionPolicy string
+
+const (
+	// EvictLRU drops whatever was least recently added or read
+	EvictLRU EvictionPolicy = "LRU"
+	// EvictLowestConfidence drops the least confident assertions first. An
+	// entity is as confident as the most confident assertion about it, so
+	// entities nothing is asserted about go first.
+	EvictLowestConfidence EvictionPolicy = "LOWEST_CONFIDENCE"
+)
+
+// StoreLimits bounds the size of a store, for edge deployments ingesting
+// streams of observations into limited memory
+type StoreLimits struct {
+	MaxEntities   int            // 0 means unbounded
+	MaxAssertions int            // 0 means unbounded; retracted and removed assertions count
+	Policy        EvictionPolicy // Empty means EvictLRU
+}
+
+// SetLimits bounds the store, evicting at once if it is over the limits;
+// nil removes the bounds. Evicted entities and assertions are dropped
+// outright, without tombstones, along with the assertions that refer to
+// them; on a storage engine they are only dropped from memory, and read
+// back when next needed, though what the engine does not keep is lost. Retracted and removed assertions are evicted before live ones.
+// While bounded, reads record access times for LRU eviction; they may
+// still run from several goroutines at once.
+func (s *SemanticStore) SetLimits(limits *StoreLimits) error {
+	s.own()
+	if limits == nil {
+		s.limits = nil
+		if s.tiering == nil {
+			s.entityAccess.reset()
+			s.assertionAccess.reset()
+		}
+		return nil
+	}
+	if limits.MaxEntities < 0 || limits.MaxAssertions < 0 {
+		return errors.New("store limits cannot be negative")
+	}
+	bounded := *limits
+	if bounded.Policy == "" {
+		bounded.Policy = EvictLRU
+	}
+	switch bounded.Policy {
+	case EvictLRU, EvictLowestConfidence:
+	default:
+		return fmt.Errorf("unknown eviction policy %s", limits.Policy)
+	}
+	s.trackAccess()
+	s.limits = &bounded
+	s.enforceLimits("")
+	return nil
+}
+
+// Limits returns the store's bounds, or nil if it is unbounded
+func (s *SemanticStore) Limits() *StoreLimits {
+	if s.limits == nil {
+		return nil
+	}
+	limits := *s.limits
+	return &limits
+}
+
+// accessOrder is the order statements of one kind were last added or read
+// in, for LRU eviction and tiering. Reads record accesses, and may run from
+// several goroutines at once, so the order has its own lock.
+type accessOrder struct {
+	mu    sync.Mutex
+	clock uint64
+	order *list.List // Of *accessEntry, least recently accessed first
+	elems map[string]*list.Element
+}
+
+// accessEntry is a statement's place in an accessOrder
+type accessEntry struct {
+	id    string
+	clock uint64 // Clock at its last access
+}
+
+// newAccessOrder creates an empty access order
+func newAccessOrder() *accessOrder {
+	return &accessOrder{order: list.New(), elems: make(map[string]*list.Element)}
+}
+
+// touch records an access, making id the most recently accessed
+func (a *accessOrder) touch(id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.clock++
+	if elem, exists := a.elems[id]; exists {
+		elem.Value.(*accessEntry).clock = a.clock
+		a.order.MoveToBack(elem)
+		return
+	}
+	a.elems[id] = a.order.PushBack(&accessEntry{id: id, clock: a.clock})
+}
+
+// forget drops id from the order
+func (a *accessOrder) forget(id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if elem, exists := a.elems[id]; exists {
+		a.order.Remove(elem)
+		delete(a.elems, id)
+	}
+}
+
+// last returns the clock at id's last access, 0 if it was never accessed
+func (a *accessOrder) last(id string) uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if elem, exists := a.elems[id]; exists {
+		return elem.Value.(*accessEntry).clock
+	}
+	return 0
+}
+
+// tracks reports whether id has been accessed
+func (a *accessOrder) tracks(id string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, exists := a.elems[id]
+	return exists
+}
+
+// each calls fn with the accessed IDs, least recently accessed first, until
+// fn returns false. fn must not record or forget accesses.
+func (a *accessOrder) each(fn func(id string) bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for elem := a.order.Front(); elem != nil; elem = elem.Next() {
+		if !fn(elem.Value.(*accessEntry).id) {
+			return
+		}
+	}
+}
+
+// reset forgets every access
+func (a *accessOrder) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.order.Init()
+	a.elems = make(map[string]*list.Element)
+}
+
+// touchEntity records an access to an entity for LRU eviction and tiering
+func (s *SemanticStore) touchEntity(id string) {
+	if s.limits != nil || s.tiering != nil {
+		s.entityAccess.touch(id)
+	}
+}
+
+// touchAssertion records an access to an assertion for LRU eviction and
+// tiering
+func (s *SemanticStore) touchAssertion(id string) {
+	if s.limits != nil || s.tiering != nil {
+		s.assertionAccess.touch(id)
+	}
+}
+
+// trackAccess starts recording accesses, if they are not already recorded.
+// The statements already stored count as accessed before any later ones:
+// entities in ID order, assertions in the order they were made.
+func (s *SemanticStore) trackAccess() {
+	if s.limits != nil || s.tiering != nil {
+		return
+	}
+	for _, id := range s.sortedEntityIDs() {
+		s.entityAccess.touch(id)
+	}
+	for row := 0; row < s.assertions.span(); row++ {
+		if !s.assertions.holds(row) {
+			continue
+		}
+		s.assertionAccess.touch(s.assertions.id(row))
+	}
+}
+
+// enforceLimits evicts until the store is within its limits, sparing the
+// statement just added. Victims are chosen together and dropped together,
+// so a batch that overflows the store costs a single pass. Under EvictLRU
+// they are taken from the front of the access order, so a store kept at its
+// limit by a stream of additions does not rank everything it holds for each
+// one; retracted assertions, which go first, and statements whose accesses
+// were never recorded are ranked when there are any.
+func (s *SemanticStore) enforceLimits(spare string) {
+	if s.limits == nil {
+		return
+	}
+	if max := s.limits.MaxAssertions; max > 0 && s.assertions.len() > max {
+		dropping := make(map[string]bool)
+		over := func() bool { return s.assertions.len()-len(dropping) > max }
+		if s.limits.Policy == EvictLRU && s.assertions.retractedRows() == 0 {
+			s.assertionAccess.each(func(id string) bool {
+				if !over() {
+					return false
+				}
+				if id != spare && !dropping[id] {
+					s.closeOver(id, dropping)
+				}
+				return true
+			})
+		}
+		if over() {
+			for _, rank := range s.assertionRanks(spare) {
+				if !over() {
+					break
+				}
+				if !dropping[rank.id] {
+					s.closeOver(rank.id, dropping)
+				}
+			}
+		}
+		s.evict(nil, dropping)
+	}
+	if max := s.limits.MaxEntities; max > 0 && len(s.entities) > max {
+		var entityIDs []string
+		dropping := make(map[string]bool)
+		chosen := make(map[string]bool)
+		choose := func(id string) {
+			// Entities with vectors stay in memory for similarity searches
+			// while a storage engine holds the rest
+			if _, hasVector := s.vectors[id]; hasVector && s.engine != nil {
+				return
+			}
+			if id != spare && !chosen[id] {
+				if _, exists := s.entities[id]; exists {
+					chosen[id] = true
+					entityIDs = append(entityIDs, id)
+					s.closeOver(id, dropping)
+				}
+			}
+		}
+		over := func() bool { return len(s.entities)-len(entityIDs) > max }
+		if s.limits.Policy == EvictLRU {
+			s.entityAccess.each(func(id string) bool {
+				if !over() {
+					return false
+				}
+				choose(id)
+				return true
+			})
+		}
+		if over() {
+			for _, rank := range s.entityRanks(spare) {
+				if !over() {
+					break
+				}
+				choose(rank.id)
+			}
+		}
+		s.evict(entityIDs, dropping)
+	}
+}
+
+// evictionRank orders eviction candidates: hidden statements first, then by
+// confidence under EvictLowestConfidence, then least recently accessed
+type evictionRank struct {
+	id         string
+	hidden     bool
+	confidence float64
+	access     uint64
+}
+
+// before reports whether r should be evicted before other
+func (r evictionRank) before(other evictionRank) bool {
+	if r.hidden != other.hidden {
+		return r.hidden
+	}
+	if r.confidence != other.confidence {
+		return r.confidence < other.confidence
+	}
+	if r.access != other.access {
+		return r.access < other.access
+	}
+	return r.id < other.id
+}
+
+// sortRanks orders ranks from first to last evicted
+func sortRanks(ranks []evictionRank) []evictionRank {
+	sort.Slice(ranks, func(i, j int) bool { return ranks[i].before(ranks[j]) })
+	return ranks
+}
+
+// assertionRanks returns the assertions in eviction order
+func (s *SemanticStore) assertionRanks(spare string) []evictionRank {
+	ranks := make([]evictionRank, 0, s.assertions.len())
+	for row := 0; row < s.assertions.span(); row++ {
+		if !s.assertions.holds(row) {
+			continue
+		}
+		id := s.assertions.id(row)
+		if id == spare {
+			continue
+		}
+		rank := evictionRank{id: id, hidden: s.assertions.isRetracted(row), access: s.assertionAccess.last(id)}
+		if s.limits.Policy == EvictLowestConfidence {
+			rank.confidence, _ = s.assertions.confidence(row)
+		}
+		ranks = append(ranks, rank)
+	}
+	return sortRanks(ranks)
+}
+
+// entityRanks returns the entities in eviction order
+func (s *SemanticStore) entityRanks(spare string) []evictionRank {
+	ranks := make([]evictionRank, 0, len(s.entities))
+	for id := range s.entities {
+		if id == spare {
+			continue
+		}
+		rank := evictionRank{id: id, access: s.entityAccess.last(id)}
+		if s.limits.Policy == EvictLowestConfidence {
+			for _, row := range s.assertions.live(s.assertions.rowsReferencing(id)) {
+				if level, _ := s.assertions.confidence(row); level > rank.confidence {
+					rank.confidence = level
+				}
+			}
+		}
+		ranks = append(ranks, rank)
+	}
+	return sortRanks(ranks)
+}
+
+// closeOver adds an assertion, and every assertion that refers to an ID
+// directly or through other assertions, to a set
+func (s *SemanticStore) closeOver(id string, assertionIDs map[string]bool) {
+	if _, exists := s.assertions.row(id); exists {
+		assertionIDs[id] = true
+	}
+	queue := []string{id}
+	for len(queue) > 0 {
+		ref := queue[0]
+		queue = queue[1:]
+		for _, row := range s.assertions.rowsReferencing(ref) {
+			if assertionID := s.assertions.id(row); !assertionIDs[assertionID] {
+				assertionIDs[assertionID] = true
+				queue = append(queue, assertionID)
+			}
+		}
+	}
+}
+
+// evict drops entities and assertions outright, or only from memory when
+// a storage engine holds them
+func (s *SemanticStore) evict(entityIDs []string, assertionIDs map[string]bool) {
+	ids := make([]string, 0, len(assertionIDs))
+	for id := range assertionIDs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	s.evictedAssertions += len(ids)
+	s.evictedEntities += len(entityIDs)
+	if s.engine != nil {
+		s.unload(entityIDs, ids)
+		return
+	}
+	if len(ids) > 0 {
+		s.removeAssertions(ids)
+	}
+	for _, id := range entityIDs {
+		s.removeEntity(id)
+	}
+}
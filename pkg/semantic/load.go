@@ -0,0 +1,159 @@
+package semantic
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+// LoadStatements adds decoded KMAC statements to the store. Entities,
+// relations, and times are added before assertions and states, and temporal
+// qualifications after them, so statements may appear in any order;
+// assertions about assertions must follow the assertions they reference
+// unless the store's integrity mode is deferred.
+// Situations are added parents first, and assertions are added to
+// situations and linked to evidence once all assertions are in.
+// Tags, annotations, and notes are kept. Statement kinds the store does not
+// hold are skipped.
+func (s *SemanticStore) LoadStatements(statements []kmac.Statement) error {
+	return s.LoadStatementsContext(context.Background(), statements)
+}
+
+// LoadStatementsContext adds decoded KMAC statements to the store, stopping
+// early if ctx is done. The statements added before it stopped stay in the
+// store, as they do when a statement fails to load.
+func (s *SemanticStore) LoadStatementsContext(ctx context.Context, statements []kmac.Statement) error {
+	s.own()
+	done := s.nest()
+	err := s.loadStatements(ctx, statements)
+	done()
+	if err != nil {
+		return err
+	}
+	return s.journalStatements(statements...)
+}
+
+// loadStatements adds decoded KMAC statements to the store, stopping early
+// if ctx is done
+func (s *SemanticStore) loadStatements(ctx context.Context, statements []kmac.Statement) error {
+	for _, stmt := range statements {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		switch stmt := stmt.(type) {
+		case *kmac.Entity:
+			if err := s.AddEntity(stmt.ID(), stmt.Label(), stmt.TOSIDType()); err != nil {
+				return fmt.Errorf("entity %s: %v", stmt.ID(), err)
+			}
+			entityRef := s.entities[stmt.ID()]
+			for key, value := range stmt.GetAllProperties() {
+				entityRef.KMACEntity.SetProperty(key, value)
+			}
+			entityRef.KMACEntity.Merge(&stmt.Metadata)
+		case *kmac.Relation:
+			s.forgetTombstone(stmt.ID())
+			s.relations[stmt.ID()] = stmt
+			s.recordChanged(RecordRelation, stmt.ID())
+		case *kmac.TimeReference:
+			s.AddTimeReference(stmt)
+		}
+	}
+
+	var situations []*kmac.Situation
+	for _, stmt := range statements {
+		if situation, ok := stmt.(*kmac.Situation); ok {
+			situations = append(situations, situation)
+		}
+	}
+	if err := s.loadSituations(situations); err != nil {
+		return err
+	}
+
+	for _, stmt := range statements {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		switch stmt := stmt.(type) {
+		case *kmac.Assertion:
+			if err := s.CreateAssertion(stmt.ID(), stmt.Subject(), stmt.Relation(), stmt.Object()); err != nil {
+				return fmt.Errorf("assertion %s: %v", stmt.ID(), err)
+			}
+			if level, source := stmt.GetConfidence(); level != 1.0 || source != "" {
+				if err := s.SetAssertionConfidence(stmt.ID(), level, source); err != nil {
+					return fmt.Errorf("assertion %s: %v", stmt.ID(), err)
+				}
+			}
+			if !stmt.IsEmpty() {
+				meta, _ := s.metadataFor(stmt.ID(), true)
+				meta.Merge(&stmt.Metadata)
+			}
+		case *kmac.StateAssertion:
+			if err := s.AddStateAssertion(stmt); err != nil {
+				return fmt.Errorf("state %s: %v", stmt.ID(), err)
+			}
+		}
+	}
+
+	for _, stmt := range statements {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if temporal, ok := stmt.(*kmac.Temporal); ok {
+			if err := s.SetTemporal(temporal); err != nil {
+				return fmt.Errorf("temporal %s: %v", temporal.AssertionID(), err)
+			}
+		}
+	}
+
+	for _, stmt := range statements {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if member, ok := stmt.(*kmac.SituationMember); ok {
+			if err := s.AddToSituation(member.SituationID(), member.AssertionID()); err != nil {
+				return fmt.Errorf("situation %s: %v", member.SituationID(), err)
+			}
+		}
+		if evidence, ok := stmt.(*kmac.Evidence); ok {
+			if err := s.AddEvidence(evidence); err != nil {
+				return fmt.Errorf("evidence %s: %v", evidence.ID(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// LoadKMAC decodes KMAC text, decompressing it as it is read if it is
+// compressed, and adds its statements to the store
+func (s *SemanticStore) LoadKMAC(r io.Reader) error {
+	return s.LoadKMACContext(context.Background(), r)
+}
+
+// LoadKMACContext decodes KMAC text and adds its statements to the store,
+// stopping early if ctx is done
+func (s *SemanticStore) LoadKMACContext(ctx context.Context, r io.Reader) error {
+	s.own()
+	statements, err := kmac.NewTextSerializer().Decode(contextReader{ctx: ctx, r: r})
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return fmt.Errorf("failed to decode KMAC: %v", err)
+	}
+	return s.LoadStatementsContext(ctx, statements)
+}
+
+// contextReader reads from r until ctx is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
@@ -0,0 +1,190 @@
+package semantic
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+// sameAsAnnotation is the annotation key listing, comma-separated, the IDs of
+// the entities merged into an entity
+const sameAsAnnotation = "same_as"
+
+// MergeReport describes what MergeEntities changed
+type MergeReport struct {
+	Survivor   string
+	Merged     []string // The entities merged into it, now removed
+	Rewritten  []string // Assertions now referring to the survivor, in creation order
+	Properties []string // Properties the survivor took from a merged entity
+	Conflicts  []string // Properties set differently on a merged entity, where the survivor's value was kept
+	Warnings   []string // Problems with the merged result
+}
+
+// MergeEntities merges duplicate entities into the one to keep. Assertions
+// referring to the duplicates, retracted or not, are rewritten to refer to
+// the survivor with their confidence, source, and metadata intact. The
+// survivor takes the properties and annotations it lacks, the duplicates'
+// tags, notes, labels, and state histories, and their own labels as
+// synonyms. The duplicates are removed, leaving tombstones whose reason is
+// SAME_AS the survivor, and the survivor is annotated same_as with their IDs.
+// Warnings report conflicting TOSIDs and assertions that now relate the
+// survivor to itself or repeat another.
+func (s *SemanticStore) MergeEntities(keepID string, dropIDs ...string) (*MergeReport, error) {
+	s.own()
+	for _, id := range append([]string{keepID}, dropIDs...) {
+		if err := s.thawAbout(id); err != nil {
+			return nil, err
+		}
+	}
+	keep, exists := s.entities[keepID]
+	if !exists {
+		return nil, fmt.Errorf("entity %s not found", keepID)
+	}
+	if len(dropIDs) == 0 {
+		return nil, fmt.Errorf("no entities to merge into %s", keepID)
+	}
+	merging := map[string]bool{keepID: true}
+	for _, id := range dropIDs {
+		if merging[id] {
+			return nil, fmt.Errorf("entity %s is listed more than once", id)
+		}
+		if _, exists := s.entities[id]; !exists {
+			return nil, fmt.Errorf("entity %s not found", id)
+		}
+		merging[id] = true
+	}
+
+	report := &MergeReport{Survivor: keepID, Merged: append([]string(nil), dropIDs...)}
+	done := s.nest()
+
+	var rows []int
+	for _, id := range dropIDs {
+		rows = append(rows, s.assertions.rowsReferencing(id)...)
+	}
+	sort.Ints(rows)
+	for i, row := range rows {
+		if i > 0 && row == rows[i-1] {
+			continue
+		}
+		subject, object := s.assertions.subject(row), s.assertions.object(row)
+		if merging[subject] {
+			subject = keepID
+		}
+		if merging[object] {
+			object = keepID
+		}
+		s.assertions.setEndpoints(row, subject, object)
+		s.recordChanged(RecordAssertion, s.assertions.id(row))
+		report.Rewritten = append(report.Rewritten, s.assertions.id(row))
+	}
+
+	sameAs := splitSameAs(&keep.KMACEntity.Metadata)
+	now := time.Now()
+	for _, id := range dropIDs {
+		dropRef := s.entities[id]
+		drop := dropRef.KMACEntity
+		if tosidsConflict(keep.KMACEntity.TOSIDType(), drop.TOSIDType()) {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("TOSID %s of %s conflicts with %s of %s",
+				drop.TOSIDType(), id, keep.KMACEntity.TOSIDType(), keepID))
+		}
+
+		properties := drop.GetAllProperties()
+		for _, key := range sortedIDs(properties) {
+			if existing, has := keep.KMACEntity.GetProperty(key); !has {
+				keep.KMACEntity.SetProperty(key, properties[key])
+				report.Properties = append(report.Properties, key)
+			} else if existing != properties[key] {
+				report.Conflicts = append(report.Conflicts, fmt.Sprintf("%s: kept %q over %q from %s", key, existing, properties[key], id))
+			}
+		}
+
+		for _, tag := range drop.Tags() {
+			keep.KMACEntity.AddTag(tag)
+		}
+		for _, note := range drop.Notes() {
+			keep.KMACEntity.AddNote(note)
+		}
+		annotations := drop.Annotations()
+		for _, key := range sortedIDs(annotations) {
+			if strings.HasPrefix(key, labelAnnotationPrefix) || key == sameAsAnnotation {
+				continue
+			}
+			if _, has := keep.KMACEntity.Annotation(key); !has {
+				keep.KMACEntity.Annotate(key, annotations[key])
+			}
+		}
+		for _, label := range localizedLabels(&drop.Metadata) {
+			s.AddLabel(keepID, label.Lang, label.Text)
+		}
+		if drop.Label() != "" && drop.Label() != keep.KMACEntity.Label() {
+			s.AddSynonym(keepID, drop.Label())
+		}
+		sameAs = append(sameAs, id)
+		sameAs = append(sameAs, splitSameAs(&drop.Metadata)...)
+
+		if history, exists := s.states[id]; exists {
+			target, exists := s.states[keepID]
+			if !exists {
+				target = kmac.NewStateHistory()
+				s.states[keepID] = target
+			}
+			for _, attribute := range history.Attributes() {
+				for _, state := range history.History(attribute) {
+					if moved, err := kmac.NewStateAssertion(state.ID(), keepID, attribute, state.Value(), state.Timestamp()); err == nil {
+						target.Add(moved)
+					}
+				}
+			}
+			delete(s.states, id)
+		}
+
+		delete(s.entities, id)
+		s.recordChanged(RecordEntity, id)
+		s.entityAccess.forget(id)
+		s.removedEntities[id] = dropRef
+		s.tombstones[id] = &Tombstone{ID: id, Kind: drop.Type(), Reason: "SAME_AS " + keepID, RemovedAt: now}
+		s.recordChanged(RecordTombstone, id)
+	}
+	s.recordChanged(RecordEntity, keepID)
+	sort.Strings(sameAs)
+	keep.KMACEntity.Annotate(sameAsAnnotation, strings.Join(sameAs, ","))
+
+	report.Warnings = append(report.Warnings, s.mergeWarnings(keepID)...)
+	done()
+	if err := s.journal(walMerge, append([]string{keepID}, dropIDs...)...); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// mergeWarnings checks the live assertions about a merged entity for ones
+// that relate it to itself or repeat an earlier one
+func (s *SemanticStore) mergeWarnings(id string) []string {
+	var warnings []string
+	seen := make(map[[3]string]string)
+	for _, row := range s.assertions.live(s.assertions.rowsReferencing(id)) {
+		assertionID := s.assertions.id(row)
+		key := [3]string{s.assertions.subject(row), s.assertions.relation(row), s.assertions.object(row)}
+		if key[0] == key[2] {
+			warnings = append(warnings, fmt.Sprintf("assertion %s now relates %s to itself", assertionID, id))
+		}
+		if earlier, exists := seen[key]; exists {
+			warnings = append(warnings, fmt.Sprintf("assertion %s now repeats %s", assertionID, earlier))
+		} else {
+			seen[key] = assertionID
+		}
+	}
+	return warnings
+}
+
+// splitSameAs returns the IDs of the entities merged into an entity
+func splitSameAs(meta *kmac.Metadata) []string {
+	value, _ := meta.Annotation(sameAsAnnotation)
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
@@ -0,0 +1,206 @@
+package semantic
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+// metadataFor returns the metadata of an entity, relation, time reference,
+// or assertion. Assertion metadata is held by the store, since assertions
+// are rebuilt from the assertion table on each query; an assertion without
+// any is given some to write to, or empty metadata to read.
+func (s *SemanticStore) metadataFor(id string, write bool) (*kmac.Metadata, error) {
+	if err := s.thawStatement(id); err != nil {
+		return nil, err
+	}
+	if entityRef, exists := s.entities[id]; exists {
+		return &entityRef.KMACEntity.Metadata, nil
+	}
+	if relation, exists := s.relations[id]; exists {
+		return &relation.Metadata, nil
+	}
+	if timeRef, exists := s.times[id]; exists {
+		return &timeRef.Metadata, nil
+	}
+	if _, exists := s.assertions.row(id); exists {
+		meta, exists := s.assertionMeta[id]
+		if !exists {
+			meta = &kmac.Metadata{}
+			if write {
+				s.assertionMeta[id] = meta
+			}
+		}
+		return meta, nil
+	}
+	return nil, fmt.Errorf("statement %s not found", id)
+}
+
+// Tag adds tags, such as "unverified", to an entity, relation, time
+// reference, or assertion
+func (s *SemanticStore) Tag(id string, tags ...string) error {
+	s.own()
+	meta, err := s.metadataFor(id, true)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		meta.AddTag(tag)
+	}
+	s.statementChanged(id)
+	return s.journal(walTag, append([]string{id}, tags...)...)
+}
+
+// Untag removes a tag from a statement
+func (s *SemanticStore) Untag(id string, tag string) error {
+	s.own()
+	meta, err := s.metadataFor(id, true)
+	if err != nil {
+		return err
+	}
+	meta.RemoveTag(tag)
+	s.statementChanged(id)
+	return s.journal(walUntag, id, tag)
+}
+
+// Annotate sets a key-value annotation on a statement
+func (s *SemanticStore) Annotate(id string, key string, value string) error {
+	s.own()
+	meta, err := s.metadataFor(id, true)
+	if err != nil {
+		return err
+	}
+	meta.Annotate(key, value)
+	s.statementChanged(id)
+	return s.journal(walAnnotate, id, key, value)
+}
+
+// AddNote appends a free-text note to a statement
+func (s *SemanticStore) AddNote(id string, note string) error {
+	s.own()
+	meta, err := s.metadataFor(id, true)
+	if err != nil {
+		return err
+	}
+	meta.AddNote(note)
+	s.statementChanged(id)
+	return s.journal(walNote, id, note)
+}
+
+// GetMetadata returns a copy of a statement's tags, annotations, and notes
+func (s *SemanticStore) GetMetadata(id string) (*kmac.Metadata, error) {
+	meta, err := s.metadataFor(id, false)
+	if err != nil {
+		return nil, err
+	}
+	result := &kmac.Metadata{}
+	result.Merge(meta)
+	return result, nil
+}
+
+// FindByTag returns the IDs of the entities, relations, time references,
+// and live assertions carrying a tag, in order
+func (s *SemanticStore) FindByTag(tag string) []string {
+	ids, _ := s.FindByTagContext(context.Background(), tag)
+	return ids
+}
+
+// FindByTagContext finds the IDs carrying a tag as FindByTag does, returning
+// an error if ctx is done or a frozen statement cannot be read first
+func (s *SemanticStore) FindByTagContext(ctx context.Context, tag string) ([]string, error) {
+	view, err := s.readView(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return view.findByTag(tag), nil
+}
+
+// findByTag finds the IDs carrying a tag among the statements in memory
+func (s *SemanticStore) findByTag(tag string) []string {
+	var ids []string
+	for id, entityRef := range s.entities {
+		if entityRef.KMACEntity.HasTag(tag) {
+			ids = append(ids, id)
+		}
+	}
+	for id, relation := range s.relations {
+		if relation.HasTag(tag) {
+			ids = append(ids, id)
+		}
+	}
+	for id, timeRef := range s.times {
+		if timeRef.HasTag(tag) {
+			ids = append(ids, id)
+		}
+	}
+	for id, meta := range s.assertionMeta {
+		if row, exists := s.assertions.row(id); exists && !s.assertions.isRetracted(row) && meta.HasTag(tag) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// materialize builds the assertion in a table row along with its metadata.
+// Changes made to it through its setters are written back to the store,
+// unless the store is a snapshot's.
+func (s *SemanticStore) materialize(row int) *kmac.Assertion {
+	assertion := s.assertions.assertion(row)
+	s.touchAssertion(assertion.ID())
+	if meta, exists := s.assertionMeta[assertion.ID()]; exists {
+		assertion.Merge(meta)
+	}
+	if !s.readOnly {
+		assertion.OnChange(func() { s.writeBack(assertion) })
+	}
+	return assertion
+}
+
+// writeBack stores the confidence and metadata of an assertion read from
+// the store, through the mutations that would have made them, so they are
+// logged and written through as those are. Negation and properties are not
+// kept for stored assertions. The setters that call it return nothing, so
+// a failed write is left for the next mutation to report.
+func (s *SemanticStore) writeBack(assertion *kmac.Assertion) {
+	id := assertion.ID()
+	if err := s.thawStatement(id); err != nil {
+		return
+	}
+	row, exists := s.assertions.row(id)
+	if !exists || s.isRemoved(id) {
+		return
+	}
+	level, source := assertion.GetConfidence()
+	if storedLevel, storedSource := s.assertions.confidence(row); level != storedLevel || source != storedSource {
+		s.SetAssertionConfidence(id, level, source)
+	}
+
+	meta, exists := s.assertionMeta[id]
+	if !exists {
+		meta = &kmac.Metadata{}
+	}
+	for _, tag := range assertion.Tags() {
+		if !meta.HasTag(tag) {
+			s.Tag(id, tag)
+		}
+	}
+	for _, tag := range meta.Tags() {
+		if !assertion.HasTag(tag) {
+			s.Untag(id, tag)
+		}
+	}
+	annotations := assertion.Annotations()
+	for _, key := range sortedIDs(annotations) {
+		if value, set := meta.Annotation(key); !set || value != annotations[key] {
+			s.Annotate(id, key, annotations[key])
+		}
+	}
+	if notes, stored := assertion.Notes(), len(meta.Notes()); len(notes) > stored {
+		for _, note := range notes[stored:] {
+			s.AddNote(id, note)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package semantic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/ha1tch/tosid-go/pkg/tosid"
+)
+
+// NamingRule constrains the labels of entities matching a TOSID pattern
+type NamingRule struct {
+	Pattern      string `json:"pattern,omitempty"`       // TOSID pattern; empty matches every entity
+	LabelPattern string `json:"label_pattern,omitempty"` // Regular expression labels must match
+	UniqueLabels bool   `json:"unique_labels,omitempty"` // No two matching entities may share a label
+}
+
+// NamingPolicy is a set of label rules enforced by AddEntity
+type NamingPolicy struct {
+	ForbiddenCharacters string       `json:"forbidden_characters,omitempty"` // Characters no label may contain
+	Rules               []NamingRule `json:"rules,omitempty"`
+
+	labelPatterns []*regexp.Regexp // Parallel to Rules; nil when unset
+}
+
+// LoadNamingPolicy reads a naming policy file
+func LoadNamingPolicy(r io.Reader) (*NamingPolicy, error) {
+	var policy NamingPolicy
+	if err := json.NewDecoder(r).Decode(&policy); err != nil {
+		return nil, fmt.Errorf("failed to decode naming policy: %v", err)
+	}
+	if err := policy.compile(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Save writes the policy as an indented naming policy file
+func (p *NamingPolicy) Save(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(p)
+}
+
+// compile compiles the label patterns of every rule
+func (p *NamingPolicy) compile() error {
+	p.labelPatterns = make([]*regexp.Regexp, len(p.Rules))
+	for i, rule := range p.Rules {
+		if rule.LabelPattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(rule.LabelPattern)
+		if err != nil {
+			return fmt.Errorf("invalid label pattern %q: %v", rule.LabelPattern, err)
+		}
+		p.labelPatterns[i] = re
+	}
+	return nil
+}
+
+// violations checks a label against the policy. Uniqueness is checked against
+// each of the given entity maps, skipping entities with the same ID.
+func (p *NamingPolicy) violations(id string, label string, tosidObj *tosid.TOSID, scopes ...map[string]*EntityReference) []string {
+	var violations []string
+
+	for _, r := range label {
+		if strings.ContainsRune(p.ForbiddenCharacters, r) {
+			violations = append(violations, fmt.Sprintf("label %q contains forbidden character %q", label, r))
+			break
+		}
+	}
+
+	for i, rule := range p.Rules {
+		if !matchesRule(tosidObj, rule.Pattern) {
+			continue
+		}
+		if p.labelPatterns[i] != nil && !p.labelPatterns[i].MatchString(label) {
+			violations = append(violations, fmt.Sprintf("label %q does not match %s", label, rule.LabelPattern))
+		}
+		if rule.UniqueLabels {
+			if duplicate := findLabel(label, rule.Pattern, id, scopes); duplicate != "" {
+				violations = append(violations, fmt.Sprintf("label %q is already used by %s%s", label, duplicate, ruleScope(rule)))
+			}
+		}
+	}
+	return violations
+}
+
+// SetNamingPolicy enforces a naming policy on subsequent AddEntity calls.
+// Existing entities are not checked; use CheckNaming to report on them. A nil
+// policy removes enforcement.
+func (s *SemanticStore) SetNamingPolicy(policy *NamingPolicy) error {
+	if policy != nil {
+		if err := policy.compile(); err != nil {
+			return err
+		}
+	}
+	s.naming = policy
+	return nil
+}
+
+// NamingPolicy returns the enforced naming policy, or nil
+func (s *SemanticStore) NamingPolicy() *NamingPolicy {
+	return s.naming
+}
+
+// checkNaming rejects an entity label that breaks the store's naming policy
+func (s *SemanticStore) checkNaming(id string, label string, tosidObj *tosid.TOSID, scopes ...map[string]*EntityReference) error {
+	if s.naming == nil {
+		return nil
+	}
+	scopes = append(scopes, s.entities)
+	if violations := s.naming.violations(id, label, tosidObj, scopes...); len(violations) > 0 {
+		return fmt.Errorf("naming policy violation for %s: %s", id, strings.Join(violations, "; "))
+	}
+	return nil
+}
+
+// CheckNaming reports every existing entity that breaks a naming policy, in
+// entity ID order. A duplicated label is reported on each entity after the
+// first in ID order.
+func (s *SemanticStore) CheckNaming(policy *NamingPolicy) ([]string, error) {
+	if err := policy.compile(); err != nil {
+		return nil, err
+	}
+
+	entities := make(map[string]*EntityReference, len(s.entities))
+	err := s.rangeAllEntities(context.Background(), func(entityRef *EntityReference) bool {
+		entities[entityRef.KMACEntity.ID()] = entityRef
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var report []string
+	checked := make(map[string]*EntityReference, len(entities))
+	for _, entityID := range sortedIDs(entities) {
+		entityRef := entities[entityID]
+		for _, violation := range policy.violations(entityID, entityRef.KMACEntity.Label(), entityRef.TOSIDObj, checked) {
+			report = append(report, fmt.Sprintf("entity %s: %s", entityID, violation))
+		}
+		checked[entityID] = entityRef
+	}
+	return report, nil
+}
+
+// matchesRule reports whether a TOSID falls under a rule's pattern
+func matchesRule(tosidObj *tosid.TOSID, pattern string) bool {
+	if pattern == "" {
+		return true
+	}
+	return tosidObj != nil && tosidObj.MatchesPattern(pattern)
+}
+
+// findLabel returns the lowest ID of another entity under the pattern with the label
+func findLabel(label string, pattern string, id string, scopes []map[string]*EntityReference) string {
+	duplicate := ""
+	for _, entities := range scopes {
+		for entityID, entityRef := range entities {
+			if entityID == id || entityRef.KMACEntity.Label() != label || !matchesRule(entityRef.TOSIDObj, pattern) {
+				continue
+			}
+			if duplicate == "" || entityID < duplicate {
+				duplicate = entityID
+			}
+		}
+	}
+	return duplicate
+}
+
+// ruleScope describes where a uniqueness rule applies
+func ruleScope(rule NamingRule) string {
+	if rule.Pattern == "" {
+		return ""
+	}
+	return " under " + rule.Pattern
+}
@@ -0,0 +1,288 @@
+package semantic
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+// Orders of query results. Entities may be ordered by ID, label, or TOSID,
+// and assertions by ID, confidence, or recency; ties are broken by ID.
+const (
+	OrderByID         = "id"
+	OrderByLabel      = "label"
+	OrderByTOSID      = "tosid"
+	OrderByConfidence = "confidence" // Most confident first
+	OrderByRecency    = "recency"    // Most recently asserted first
+)
+
+// PageRequest selects a page of query results, in an order that does not
+// change from run to run, so pages do not overlap. To page through results,
+// pass the Next cursor of each page as the Cursor of the request for the
+// next; cursors stay valid as the store changes. Offset skips results
+// instead, for jumping ahead.
+type PageRequest struct {
+	Limit  int    // Most results in the page; 0 for no limit
+	Offset int    // Results to skip after the cursor
+	Cursor string // Next of the previous page; empty to start from the first result
+	Order  string // One of the Order constants; empty orders by ID
+}
+
+// Page is a page of query results
+type Page[T any] struct {
+	Items []T
+	Next  string // Cursor of the following page; empty on the last page
+}
+
+// order is how an order compares results, and what it applies to
+type order struct {
+	numeric    bool // Compares by num rather than key
+	descending bool
+	entities   bool
+	assertions bool
+}
+
+var orders = map[string]order{
+	OrderByID:         {entities: true, assertions: true},
+	OrderByLabel:      {entities: true},
+	OrderByTOSID:      {entities: true},
+	OrderByConfidence: {numeric: true, descending: true, assertions: true},
+	OrderByRecency:    {descending: true, assertions: true},
+}
+
+// pageKey is what a result is ordered by
+type pageKey struct {
+	key string
+	num float64
+	id  string
+}
+
+// less reports whether a result with key a comes before one with key b
+func (o order) less(a pageKey, b pageKey) bool {
+	switch {
+	case o.numeric && a.num != b.num:
+		return (a.num < b.num) != o.descending
+	case !o.numeric && a.key != b.key:
+		return (a.key < b.key) != o.descending
+	}
+	return a.id < b.id
+}
+
+// lookupOrder returns the order a request names, checking it applies
+func lookupOrder(name string, assertions bool) (order, error) {
+	if name == "" {
+		name = OrderByID
+	}
+	o, exists := orders[name]
+	if !exists || (assertions && !o.assertions) || (!assertions && !o.entities) {
+		kind := "entities"
+		if assertions {
+			kind = "assertions"
+		}
+		return o, fmt.Errorf("cannot order %s by %q", kind, name)
+	}
+	return o, nil
+}
+
+// encodeCursor returns the cursor for resuming after a result. Results in
+// ID order resume after the ID itself; others also carry the sort key, so
+// the cursor stays valid if the result is removed.
+func encodeCursor(name string, o order, key pageKey) string {
+	if name == "" || name == OrderByID {
+		return key.id
+	}
+	sortKey := key.key
+	if o.numeric {
+		sortKey = strconv.FormatFloat(key.num, 'g', -1, 64)
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(sortKey + "\x00" + key.id))
+}
+
+// decodeCursor returns the key of the result a cursor resumes after
+func decodeCursor(name string, o order, cursor string) (pageKey, error) {
+	if name == "" || name == OrderByID {
+		return pageKey{id: cursor}, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	sortKey, id, found := strings.Cut(string(data), "\x00")
+	if err != nil || !found {
+		return pageKey{}, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	if !o.numeric {
+		return pageKey{key: sortKey, id: id}, nil
+	}
+	num, err := strconv.ParseFloat(sortKey, 64)
+	if err != nil {
+		return pageKey{}, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return pageKey{num: num, id: id}, nil
+}
+
+// paginate sorts the keys of the results and returns the IDs of the page a
+// request selects, along with the cursor of the following page
+func paginate(keys []pageKey, req PageRequest, assertions bool) ([]string, string, error) {
+	if req.Limit < 0 || req.Offset < 0 {
+		return nil, "", fmt.Errorf("invalid page limit %d or offset %d", req.Limit, req.Offset)
+	}
+	o, err := lookupOrder(req.Order, assertions)
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Slice(keys, func(i, j int) bool { return o.less(keys[i], keys[j]) })
+
+	start := 0
+	if req.Cursor != "" {
+		after, err := decodeCursor(req.Order, o, req.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		start = sort.Search(len(keys), func(i int) bool { return o.less(after, keys[i]) })
+	}
+	start = min(start+req.Offset, len(keys))
+	end := len(keys)
+	if req.Limit > 0 {
+		end = min(start+req.Limit, len(keys))
+	}
+
+	ids := make([]string, 0, end-start)
+	for _, key := range keys[start:end] {
+		ids = append(ids, key.id)
+	}
+	next := ""
+	if end < len(keys) {
+		next = encodeCursor(req.Order, o, keys[end-1])
+	}
+	return ids, next, nil
+}
+
+// entityPage returns a page of the entities satisfying match, reading
+// frozen entities from their segments
+func (s *SemanticStore) entityPage(req PageRequest, match func(*EntityReference) bool) (Page[*EntityReference], error) {
+	var keys []pageKey
+	matched := make(map[string]*EntityReference)
+	err := s.rangeAllEntities(context.Background(), func(entityRef *EntityReference) bool {
+		if !match(entityRef) {
+			return true
+		}
+		id := entityRef.KMACEntity.ID()
+		matched[id] = entityRef
+		key := pageKey{id: id}
+		switch req.Order {
+		case OrderByLabel:
+			key.key = entityRef.KMACEntity.Label()
+		case OrderByTOSID:
+			key.key = entityRef.KMACEntity.TOSIDType()
+		}
+		keys = append(keys, key)
+		return true
+	})
+	if err != nil {
+		return Page[*EntityReference]{}, err
+	}
+	pageIDs, next, err := paginate(keys, req, false)
+	if err != nil {
+		return Page[*EntityReference]{}, err
+	}
+	page := Page[*EntityReference]{Items: make([]*EntityReference, 0, len(pageIDs)), Next: next}
+	for _, id := range pageIDs {
+		page.Items = append(page.Items, matched[id])
+	}
+	return page, nil
+}
+
+// assertionPage returns a page of the live assertions in table rows. Only
+// the assertions in the page are materialized.
+func (s *SemanticStore) assertionPage(rows []int, req PageRequest) (Page[*kmac.Assertion], error) {
+	rowsByID := make(map[string]int)
+	var keys []pageKey
+	for _, row := range s.assertions.live(rows) {
+		id := s.assertions.id(row)
+		if _, seen := rowsByID[id]; seen {
+			continue
+		}
+		rowsByID[id] = row
+		key := pageKey{id: id}
+		switch req.Order {
+		case OrderByConfidence:
+			key.num, _ = s.assertions.confidence(row)
+		case OrderByRecency:
+			key.key = s.assertedAt[id].UTC().Format(recencyLayout)
+		}
+		keys = append(keys, key)
+	}
+	pageIDs, next, err := paginate(keys, req, true)
+	if err != nil {
+		return Page[*kmac.Assertion]{}, err
+	}
+	page := Page[*kmac.Assertion]{Items: make([]*kmac.Assertion, 0, len(pageIDs)), Next: next}
+	for _, id := range pageIDs {
+		page.Items = append(page.Items, s.materialize(rowsByID[id]))
+	}
+	return page, nil
+}
+
+// recencyLayout formats assertion times so they sort as strings
+const recencyLayout = "2006-01-02T15:04:05.000000000Z"
+
+// FindEntitiesByTOSIDPatternPage returns a page of the entities matching a
+// TOSID pattern
+func (s *SemanticStore) FindEntitiesByTOSIDPatternPage(pattern string, req PageRequest) (Page[*EntityReference], error) {
+	return s.entityPage(req, func(entityRef *EntityReference) bool {
+		return entityRef.TOSIDObj != nil && entityRef.TOSIDObj.MatchesPattern(pattern)
+	})
+}
+
+// FindEntitiesByLabelPage returns a page of the entities a label pattern
+// finds, as FindEntitiesByLabel matches them
+func (s *SemanticStore) FindEntitiesByLabelPage(labelPattern string, req PageRequest) (Page[*EntityReference], error) {
+	pattern := strings.ToLower(labelPattern)
+	return s.entityPage(req, func(entityRef *EntityReference) bool {
+		return labelMatches(entityRef.KMACEntity.Label(), &entityRef.KMACEntity.Metadata, pattern)
+	})
+}
+
+// FindAssertionsForEntityPage returns a page of the assertions involving an
+// entity
+func (s *SemanticStore) FindAssertionsForEntityPage(entityID string, req PageRequest) (Page[*kmac.Assertion], error) {
+	if err := s.thawAbout(entityID); err != nil {
+		return Page[*kmac.Assertion]{}, err
+	}
+	return s.assertionPage(s.assertions.rowsReferencing(entityID), req)
+}
+
+// FindAssertionsBySubjectPage returns a page of the assertions with a subject
+func (s *SemanticStore) FindAssertionsBySubjectPage(subjectID string, req PageRequest) (Page[*kmac.Assertion], error) {
+	if err := s.thawAbout(subjectID); err != nil {
+		return Page[*kmac.Assertion]{}, err
+	}
+	return s.assertionPage(s.assertions.rowsWithSubject(subjectID), req)
+}
+
+// FindAssertionsByRelationPage returns a page of the assertions using a
+// relation
+func (s *SemanticStore) FindAssertionsByRelationPage(relationID string, req PageRequest) (Page[*kmac.Assertion], error) {
+	if err := s.thawAbout(relationID); err != nil {
+		return Page[*kmac.Assertion]{}, err
+	}
+	return s.assertionPage(s.assertions.rowsWithRelation(relationID), req)
+}
+
+// FindAssertionsByObjectPage returns a page of the assertions with an object
+func (s *SemanticStore) FindAssertionsByObjectPage(objectID string, req PageRequest) (Page[*kmac.Assertion], error) {
+	if err := s.thawAbout(objectID); err != nil {
+		return Page[*kmac.Assertion]{}, err
+	}
+	return s.assertionPage(s.assertions.rowsWithObject(objectID), req)
+}
+
+// sortEntities orders entities by ID
+func sortEntities(entities []*EntityReference) {
+	sort.Slice(entities, func(i, j int) bool {
+		return entities[i].KMACEntity.ID() < entities[j].KMACEntity.ID()
+	})
+}
@@ -0,0 +1,204 @@
+package semantic
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// ParallelQueryOptions configures how bulk queries are spread across goroutines
+type ParallelQueryOptions struct {
+	Workers   int // Number of worker goroutines; defaults to GOMAXPROCS
+	ShardSize int // Number of items per unit of work; defaults to 1024
+}
+
+// withDefaults fills in unset options
+func (o ParallelQueryOptions) withDefaults() ParallelQueryOptions {
+	if o.Workers <= 0 {
+		o.Workers = runtime.GOMAXPROCS(0)
+	}
+	if o.ShardSize <= 0 {
+		o.ShardSize = 1024
+	}
+	return o
+}
+
+// runSharded splits n items into shards and runs work on each shard using a
+// worker pool. It stops handing out shards once ctx is done.
+func runSharded(ctx context.Context, n int, opts ParallelQueryOptions, work func(shard, start, end int)) error {
+	opts = opts.withDefaults()
+
+	shards := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < opts.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for shard := range shards {
+				start := shard * opts.ShardSize
+				end := start + opts.ShardSize
+				if end > n {
+					end = n
+				}
+				work(shard, start, end)
+			}
+		}()
+	}
+
+	var err error
+dispatch:
+	for shard := 0; shard*opts.ShardSize < n; shard++ {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			break dispatch
+		case shards <- shard:
+		}
+	}
+	close(shards)
+	wg.Wait()
+
+	if err == nil {
+		err = ctx.Err()
+	}
+	return err
+}
+
+// shardCount returns the number of shards runSharded will produce
+func shardCount(n int, opts ParallelQueryOptions) int {
+	opts = opts.withDefaults()
+	return (n + opts.ShardSize - 1) / opts.ShardSize
+}
+
+// QueryParallel evaluates match against every entity using a worker pool and
+// returns the matching entities ordered by ID. The store must not be modified
+// while the query runs. Frozen entities are read from their segments first.
+func (s *SemanticStore) QueryParallel(ctx context.Context, match func(*EntityReference) bool, opts ParallelQueryOptions) ([]*EntityReference, error) {
+	entities := make(map[string]*EntityReference, len(s.entities))
+	err := s.rangeAllEntities(ctx, func(entityRef *EntityReference) bool {
+		entities[entityRef.KMACEntity.ID()] = entityRef
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	ids := sortedIDs(entities)
+
+	// Each shard writes only to its own slot, so no locking is needed
+	partials := make([][]*EntityReference, shardCount(len(ids), opts))
+	err = runSharded(ctx, len(ids), opts, func(shard, start, end int) {
+		var matches []*EntityReference
+		for _, id := range ids[start:end] {
+			if ctx.Err() != nil {
+				return
+			}
+			if entityRef := entities[id]; match(entityRef) {
+				matches = append(matches, entityRef)
+			}
+		}
+		partials[shard] = matches
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*EntityReference
+	for _, matches := range partials {
+		results = append(results, matches...)
+	}
+	return results, nil
+}
+
+// FindEntitiesByTOSIDPatternParallel is a parallel variant of FindEntitiesByTOSIDPattern
+func (s *SemanticStore) FindEntitiesByTOSIDPatternParallel(ctx context.Context, pattern string, opts ParallelQueryOptions) ([]*EntityReference, error) {
+	return s.QueryParallel(ctx, func(entityRef *EntityReference) bool {
+		return entityRef.TOSIDObj != nil && entityRef.TOSIDObj.MatchesPattern(pattern)
+	}, opts)
+}
+
+// TraverseParallel finds every entity reachable from each start entity within
+// maxDepth assertion hops, following assertions in both directions. Start
+// entities are expanded concurrently, a hop at a time; the result maps each
+// start ID to the sorted IDs reachable from it. Frozen statements each hop
+// reaches are loaded back before it is expanded, as reads by ID load them.
+func (s *SemanticStore) TraverseParallel(ctx context.Context, startIDs []string, maxDepth int, opts ParallelQueryOptions) (map[string][]string, error) {
+	// Traversals are much heavier than entity matches, so shard them finely
+	if opts.ShardSize <= 0 {
+		opts.ShardSize = 1
+	}
+
+	traversals := make([]*traversal, len(startIDs))
+	for i, startID := range startIDs {
+		traversals[i] = &traversal{visited: map[string]bool{startID: true}, frontier: []string{startID}}
+	}
+	for depth := 0; depth < maxDepth; depth++ {
+		// The workers only read the store, so anything frozen is loaded
+		// back before they start
+		expanding := false
+		for _, t := range traversals {
+			for _, id := range t.frontier {
+				if err := s.thawAbout(id); err != nil {
+					return nil, err
+				}
+				expanding = true
+			}
+		}
+		if !expanding {
+			break
+		}
+		err := runSharded(ctx, len(traversals), opts, func(shard, start, end int) {
+			for i := start; i < end; i++ {
+				if ctx.Err() != nil {
+					return
+				}
+				traversals[i].expand(s)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	results := make(map[string][]string, len(startIDs))
+	for i, startID := range startIDs {
+		results[startID] = traversals[i].reached(startID)
+	}
+	return results, nil
+}
+
+// traversal is a breadth-first search from a single entity
+type traversal struct {
+	visited  map[string]bool
+	frontier []string // IDs reached by the last hop
+}
+
+// expand takes the search one hop further
+func (t *traversal) expand(s *SemanticStore) {
+	var next []string
+	for _, id := range t.frontier {
+		for _, row := range s.assertions.live(s.assertions.rowsReferencing(id)) {
+			neighbour := s.assertions.object(row)
+			if neighbour == id {
+				neighbour = s.assertions.subject(row)
+			}
+			if !t.visited[neighbour] {
+				t.visited[neighbour] = true
+				next = append(next, neighbour)
+			}
+		}
+	}
+	t.frontier = next
+}
+
+// reached returns the IDs the search visited besides the start, in order
+func (t *traversal) reached(startID string) []string {
+	reached := make([]string, 0, len(t.visited)-1)
+	for id := range t.visited {
+		if id != startID {
+			reached = append(reached, id)
+		}
+	}
+	sort.Strings(reached)
+	return reached
+}
@@ -0,0 +1,173 @@
+package semantic
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/ha1tch/tosid-go/pkg/tosid"
+)
+
+// lowConfidence is the mean confidence under which an entity's assertions are
+// reported as uncertain
+const lowConfidence = 0.7
+
+// QualityWeights weigh the parts of an entity's quality score. They need not
+// sum to 1; the score is their weighted mean.
+type QualityWeights struct {
+	Completeness float64 // Having a TOSID, a label, and assertions
+	Confidence   float64 // Mean confidence of its assertions
+	Provenance   float64 // Share of its assertions with a source, evidence, or premises
+	Validity     float64 // Freedom from TOSID and naming warnings
+}
+
+// DefaultQualityWeights are the weights used when all are zero
+var DefaultQualityWeights = QualityWeights{Completeness: 0.4, Confidence: 0.25, Provenance: 0.2, Validity: 0.15}
+
+// QualityScore is how trustworthy and complete an entity's data is. Each
+// part and the score run from 0, worst, to 1.
+type QualityScore struct {
+	EntityID     string
+	Score        float64
+	Completeness float64
+	Confidence   float64
+	Provenance   float64
+	Validity     float64
+	Problems     []string // What lowered the score
+}
+
+// QualityReport scores every entity in a store
+type QualityReport struct {
+	Scores []QualityScore // Worst first, ties in ID order
+	Mean   float64
+}
+
+// Worst returns up to n of the lowest-scoring entities
+func (r *QualityReport) Worst(n int) []QualityScore {
+	if n > len(r.Scores) {
+		n = len(r.Scores)
+	}
+	return r.Scores[:n]
+}
+
+// ScoreQuality scores every entity on the completeness, confidence, and
+// provenance of its data and the validation warnings it raises, ranking the
+// worst first so they can be fixed first
+func (s *SemanticStore) ScoreQuality(weights QualityWeights) *QualityReport {
+	report, _ := s.ScoreQualityContext(context.Background(), weights)
+	return report
+}
+
+// ScoreQualityContext scores every entity as ScoreQuality does, returning an
+// error if ctx is done or a frozen statement cannot be read first
+func (s *SemanticStore) ScoreQualityContext(ctx context.Context, weights QualityWeights) (*QualityReport, error) {
+	view, err := s.readView(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return view.scoreQuality(weights), nil
+}
+
+// scoreQuality scores the entities in memory
+func (s *SemanticStore) scoreQuality(weights QualityWeights) *QualityReport {
+	supported := s.supportedAssertions()
+	report := &QualityReport{Scores: make([]QualityScore, 0, len(s.entities))}
+	total := 0.0
+	for _, id := range sortedIDs(s.entities) {
+		score := s.scoreEntity(s.entities[id], weights, supported)
+		report.Scores = append(report.Scores, score)
+		total += score.Score
+	}
+	if len(report.Scores) > 0 {
+		report.Mean = total / float64(len(report.Scores))
+	}
+	sort.SliceStable(report.Scores, func(i, j int) bool { return report.Scores[i].Score < report.Scores[j].Score })
+	return report
+}
+
+// EntityQuality scores a single entity as ScoreQuality does
+func (s *SemanticStore) EntityQuality(entityID string, weights QualityWeights) (QualityScore, error) {
+	entityRef, exists := s.entities[entityID]
+	if !exists {
+		return QualityScore{}, fmt.Errorf("entity %s not found", entityID)
+	}
+	return s.scoreEntity(entityRef, weights, s.supportedAssertions()), nil
+}
+
+// scoreEntity scores an entity, given the IDs of the assertions with
+// evidence or premises
+func (s *SemanticStore) scoreEntity(entityRef *EntityReference, weights QualityWeights, supported map[string]bool) QualityScore {
+	entity := entityRef.KMACEntity
+	score := QualityScore{EntityID: entity.ID()}
+	rows := s.assertions.live(s.assertions.rowsReferencing(entity.ID()))
+
+	complete := 0
+	if entityRef.TOSIDObj != nil {
+		complete++
+	} else {
+		score.Problems = append(score.Problems, "no TOSID")
+	}
+	if entity.Label() != "" {
+		complete++
+	} else {
+		score.Problems = append(score.Problems, "no label")
+	}
+	if len(rows) > 0 {
+		complete++
+	} else {
+		score.Problems = append(score.Problems, "no assertions")
+	}
+	score.Completeness = float64(complete) / 3
+
+	if len(rows) > 0 {
+		confidence, sourced := 0.0, 0
+		for _, row := range rows {
+			level, source := s.assertions.confidence(row)
+			confidence += level
+			if source != "" || supported[s.assertions.id(row)] {
+				sourced++
+			}
+		}
+		score.Confidence = confidence / float64(len(rows))
+		score.Provenance = float64(sourced) / float64(len(rows))
+		if score.Confidence < lowConfidence {
+			score.Problems = append(score.Problems, fmt.Sprintf("mean confidence %.2f", score.Confidence))
+		}
+		if sourced < len(rows) {
+			score.Problems = append(score.Problems, fmt.Sprintf("%d of %d assertions without a source or evidence", len(rows)-sourced, len(rows)))
+		}
+	}
+
+	var warnings []string
+	if entityRef.TOSIDObj != nil {
+		warnings = append(warnings, tosid.Validate(entity.TOSIDType())...)
+	}
+	if s.naming != nil {
+		warnings = append(warnings, s.naming.violations(entity.ID(), entity.Label(), entityRef.TOSIDObj, s.entities)...)
+	}
+	score.Validity = 1 / float64(1+len(warnings))
+	score.Problems = append(score.Problems, warnings...)
+
+	if weights == (QualityWeights{}) {
+		weights = DefaultQualityWeights
+	}
+	sum := weights.Completeness + weights.Confidence + weights.Provenance + weights.Validity
+	if sum > 0 {
+		score.Score = (weights.Completeness*score.Completeness + weights.Confidence*score.Confidence +
+			weights.Provenance*score.Provenance + weights.Validity*score.Validity) / sum
+	}
+	return score
+}
+
+// supportedAssertions returns the IDs of the assertions with evidence or
+// premises
+func (s *SemanticStore) supportedAssertions() map[string]bool {
+	supported := make(map[string]bool, len(s.evidence)+len(s.derivations))
+	for _, evidence := range s.evidence {
+		supported[evidence.AssertionID()] = true
+	}
+	for id := range s.derivations {
+		supported[id] = true
+	}
+	return supported
+}
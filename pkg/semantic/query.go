@@ -0,0 +1,245 @@
+package semantic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// EntityQuery selects entities. Every field given must match; empty fields match
+// every entity.
+type EntityQuery struct {
+	Pattern  string `json:"pattern,omitempty"`  // TOSID pattern
+	Label    string `json:"label,omitempty"`    // Matched as FindEntitiesByLabel matches
+	Tag      string `json:"tag,omitempty"`      // Tag the entity carries
+	Relation string `json:"relation,omitempty"` // ID or label of a relation the entity is the subject of
+	Object   string `json:"object,omitempty"`   // ID or label of the object of Relation
+}
+
+// SavedQuery is a named query that teams share, so everyone runs the same
+// lookup. Its fields may refer to parameters as {name}, filled in from the
+// arguments it is run with.
+type SavedQuery struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Params      []string    `json:"params,omitempty"`
+	Query       EntityQuery `json:"query"`
+}
+
+var (
+	queryNamePattern  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	queryParamPattern = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+)
+
+// check verifies the query's name and that it declares every parameter it uses
+func (q *SavedQuery) check() error {
+	if !queryNamePattern.MatchString(q.Name) {
+		return fmt.Errorf("invalid query name %q", q.Name)
+	}
+	declared := make(map[string]bool)
+	for _, param := range q.Params {
+		if !queryNamePattern.MatchString(param) || declared[param] {
+			return fmt.Errorf("query %s: invalid or repeated parameter %q", q.Name, param)
+		}
+		declared[param] = true
+	}
+	for _, field := range q.Query.fields() {
+		for _, match := range queryParamPattern.FindAllStringSubmatch(*field, -1) {
+			if !declared[match[1]] {
+				return fmt.Errorf("query %s uses undeclared parameter %s", q.Name, match[1])
+			}
+		}
+	}
+	return nil
+}
+
+// fields returns pointers to the query's fields, for substituting parameters
+func (q *EntityQuery) fields() []*string {
+	return []*string{&q.Pattern, &q.Label, &q.Tag, &q.Relation, &q.Object}
+}
+
+// Bind returns the query with its parameters filled in from arguments,
+// which must give every parameter and no others
+func (q *SavedQuery) Bind(args map[string]string) (EntityQuery, error) {
+	for _, param := range q.Params {
+		if _, given := args[param]; !given {
+			return EntityQuery{}, fmt.Errorf("query %s: missing argument %s", q.Name, param)
+		}
+	}
+	if len(args) != len(q.Params) {
+		for name := range args {
+			if !containsString(q.Params, name) {
+				return EntityQuery{}, fmt.Errorf("query %s has no parameter %s", q.Name, name)
+			}
+		}
+	}
+
+	bound := q.Query
+	for _, field := range bound.fields() {
+		*field = queryParamPattern.ReplaceAllStringFunc(*field, func(ref string) string {
+			return args[ref[1:len(ref)-1]]
+		})
+	}
+	return bound, nil
+}
+
+// LoadSavedQueries reads a saved queries file, a JSON array of queries
+func LoadSavedQueries(r io.Reader) ([]SavedQuery, error) {
+	var queries []SavedQuery
+	if err := json.NewDecoder(r).Decode(&queries); err != nil {
+		return nil, fmt.Errorf("failed to decode saved queries: %v", err)
+	}
+	for i := range queries {
+		if err := queries[i].check(); err != nil {
+			return nil, err
+		}
+	}
+	return queries, nil
+}
+
+// ParseQueryCall parses a call of a saved query, such as
+// "medical_needs_in(E2001)", into the query's name and its positional
+// arguments. Arguments are separated by commas and trimmed of spaces.
+func ParseQueryCall(call string) (string, []string, error) {
+	call = strings.TrimSpace(call)
+	open := strings.IndexByte(call, '(')
+	if open < 0 {
+		if !queryNamePattern.MatchString(call) {
+			return "", nil, fmt.Errorf("invalid query call %q", call)
+		}
+		return call, nil, nil
+	}
+	name := strings.TrimSpace(call[:open])
+	if !queryNamePattern.MatchString(name) || !strings.HasSuffix(call, ")") {
+		return "", nil, fmt.Errorf("invalid query call %q", call)
+	}
+	inner := strings.TrimSpace(call[open+1 : len(call)-1])
+	if inner == "" {
+		return name, nil, nil
+	}
+	var args []string
+	for _, arg := range strings.Split(inner, ",") {
+		args = append(args, strings.TrimSpace(arg))
+	}
+	return name, args, nil
+}
+
+// RegisterQuery adds a saved query to the store, replacing any of the same
+// name
+func (s *SemanticStore) RegisterQuery(query SavedQuery) error {
+	if err := query.check(); err != nil {
+		return err
+	}
+	if s.queries == nil {
+		s.queries = make(map[string]SavedQuery)
+	}
+	query.Params = append([]string(nil), query.Params...)
+	s.queries[query.Name] = query
+	return nil
+}
+
+// SavedQuery returns a registered query by name
+func (s *SemanticStore) SavedQuery(name string) (SavedQuery, bool) {
+	query, exists := s.queries[name]
+	return query, exists
+}
+
+// SavedQueries returns the registered queries, ordered by name
+func (s *SemanticStore) SavedQueries() []SavedQuery {
+	queries := make([]SavedQuery, 0, len(s.queries))
+	for _, name := range sortedIDs(s.queries) {
+		queries = append(queries, s.queries[name])
+	}
+	return queries
+}
+
+// RunQuery runs a registered query with arguments for its parameters and
+// returns a page of the entities it selects
+func (s *SemanticStore) RunQuery(name string, args map[string]string, req PageRequest) (Page[*EntityReference], error) {
+	saved, exists := s.queries[name]
+	if !exists {
+		return Page[*EntityReference]{}, fmt.Errorf("no saved query %s", name)
+	}
+	query, err := saved.Bind(args)
+	if err != nil {
+		return Page[*EntityReference]{}, err
+	}
+	return s.FindEntitiesPage(query, req)
+}
+
+// CallQuery runs a registered query from a call such as
+// "medical_needs_in(E2001)", binding the arguments to the query's
+// parameters in order
+func (s *SemanticStore) CallQuery(call string, req PageRequest) (Page[*EntityReference], error) {
+	name, values, err := ParseQueryCall(call)
+	if err != nil {
+		return Page[*EntityReference]{}, err
+	}
+	saved, exists := s.queries[name]
+	if !exists {
+		return Page[*EntityReference]{}, fmt.Errorf("no saved query %s", name)
+	}
+	if len(values) != len(saved.Params) {
+		return Page[*EntityReference]{}, fmt.Errorf("query %s takes %d arguments (%s), got %d", name, len(saved.Params), strings.Join(saved.Params, ", "), len(values))
+	}
+	args := make(map[string]string, len(values))
+	for i, value := range values {
+		args[saved.Params[i]] = value
+	}
+	return s.RunQuery(name, args, req)
+}
+
+// FindEntitiesPage returns a page of the entities a query selects
+func (s *SemanticStore) FindEntitiesPage(query EntityQuery, req PageRequest) (Page[*EntityReference], error) {
+	store := s
+	if query.Relation != "" || query.Object != "" {
+		// Matching reads the assertions on each entity, frozen ones included
+		view, err := s.readView(context.Background())
+		if err != nil {
+			return Page[*EntityReference]{}, err
+		}
+		store = view
+	}
+	label := strings.ToLower(query.Label)
+	return store.entityPage(req, func(entityRef *EntityReference) bool {
+		entity := entityRef.KMACEntity
+		switch {
+		case query.Pattern != "" && (entityRef.TOSIDObj == nil || !entityRef.TOSIDObj.MatchesPattern(query.Pattern)):
+			return false
+		case query.Label != "" && !labelMatches(entity.Label(), &entity.Metadata, label):
+			return false
+		case query.Tag != "" && !entity.HasTag(query.Tag):
+			return false
+		case query.Relation == "" && query.Object == "":
+			return true
+		}
+		for _, row := range store.assertions.live(store.assertions.rowsWithSubject(entity.ID())) {
+			if query.Relation != "" && !store.namedBy(store.assertions.relation(row), query.Relation) {
+				continue
+			}
+			if query.Object != "" && !store.namedBy(store.assertions.object(row), query.Object) {
+				continue
+			}
+			return true
+		}
+		return false
+	})
+}
+
+// namedBy reports whether a name is a statement's ID or, ignoring case, the
+// label of the entity or relation with that ID
+func (s *SemanticStore) namedBy(id string, name string) bool {
+	if id == name {
+		return true
+	}
+	if entityRef, exists := s.entities[id]; exists {
+		return strings.EqualFold(entityRef.KMACEntity.Label(), name)
+	}
+	if relation, exists := s.relations[id]; exists {
+		return strings.EqualFold(relation.Label(), name)
+	}
+	return false
+}
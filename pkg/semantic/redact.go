@@ -0,0 +1,214 @@
+package semantic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+// What a redaction rule does to the statements it matches
+const (
+	RedactStrip = "STRIP" // Leave the statement out, with the assertions about it
+	RedactMask  = "MASK"  // Keep an entity's ID but hide everything else about it
+)
+
+// DefaultMaskLabel replaces the label of masked entities
+const DefaultMaskLabel = "REDACTED"
+
+// RedactionRule selects entities and assertions to strip or mask
+type RedactionRule struct {
+	TOSIDPattern string // Entities whose TOSID matches, such as "11B-3ME-*"; empty matches none
+	Tag          string // Entities and assertions carrying the tag; empty matches none
+	Action       string // RedactStrip or RedactMask; empty means RedactStrip
+}
+
+// RedactionFilter says what to leave out of a shareable export
+type RedactionFilter struct {
+	Rules     []RedactionRule
+	MaskLabel string // Label of masked entities; empty means DefaultMaskLabel
+}
+
+// RedactionReport lists what a filtered export left out or masked, for audit
+type RedactionReport struct {
+	Stripped []string // IDs of entities and assertions left out
+	Masked   []string // IDs of entities masked
+}
+
+// validate checks a filter's rules
+func (f *RedactionFilter) validate() error {
+	for i, rule := range f.Rules {
+		if rule.TOSIDPattern == "" && rule.Tag == "" {
+			return fmt.Errorf("redaction rule %d matches nothing", i)
+		}
+		switch rule.Action {
+		case "", RedactStrip, RedactMask:
+		default:
+			return fmt.Errorf("unknown redaction action %s", rule.Action)
+		}
+	}
+	return nil
+}
+
+// entityAction returns what the filter does to an entity, or "" to keep it.
+// Stripping wins over masking when several rules match.
+func (f *RedactionFilter) entityAction(entityRef *EntityReference) string {
+	action := ""
+	for _, rule := range f.Rules {
+		matches := (rule.TOSIDPattern != "" && entityRef.TOSIDObj != nil && entityRef.TOSIDObj.MatchesPattern(rule.TOSIDPattern)) ||
+			(rule.Tag != "" && entityRef.KMACEntity.HasTag(rule.Tag))
+		if !matches {
+			continue
+		}
+		if rule.Action == RedactMask {
+			action = RedactMask
+		} else {
+			return RedactStrip
+		}
+	}
+	return action
+}
+
+// stripsAssertion reports whether the filter leaves an assertion out. Rules
+// only match assertions by tag, and masking an assertion strips it, since
+// there is nothing to keep once its parts are hidden.
+func (f *RedactionFilter) stripsAssertion(meta *kmac.Metadata) bool {
+	if meta == nil {
+		return false
+	}
+	for _, rule := range f.Rules {
+		if rule.Tag != "" && meta.HasTag(rule.Tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactedStatements returns the store's statements as Statements does, with
+// a filter applied for export to partners. Stripped entities and assertions
+// are left out along with the assertions that refer to them, directly or
+// through other assertions, and their states and temporal qualifications.
+// Masked entities keep their ID, so assertions about them still connect,
+// but lose their label, TOSID, properties, metadata, and states.
+func (s *SemanticStore) RedactedStatements(filter *RedactionFilter) ([]kmac.Statement, *RedactionReport, error) {
+	view, err := s.readView(context.Background())
+	if err != nil {
+		return nil, nil, err
+	}
+	return view.redactedStatements(filter)
+}
+
+// redactedStatements applies a redaction filter to the statements in memory
+func (s *SemanticStore) redactedStatements(filter *RedactionFilter) ([]kmac.Statement, *RedactionReport, error) {
+	if filter == nil {
+		return nil, nil, errors.New("redaction filter cannot be nil")
+	}
+	if err := filter.validate(); err != nil {
+		return nil, nil, err
+	}
+	maskLabel := filter.MaskLabel
+	if maskLabel == "" {
+		maskLabel = DefaultMaskLabel
+	}
+
+	report := &RedactionReport{}
+	stripped := make(map[string]bool)
+	masked := make(map[string]bool)
+	for _, id := range s.sortedEntityIDs() {
+		switch filter.entityAction(s.entities[id]) {
+		case RedactStrip:
+			stripped[id] = true
+		case RedactMask:
+			masked[id] = true
+			report.Masked = append(report.Masked, id)
+		}
+	}
+	for row := 0; row < s.assertions.span(); row++ {
+		if !s.assertions.holds(row) {
+			continue
+		}
+		if id := s.assertions.id(row); !s.assertions.isRetracted(row) && filter.stripsAssertion(s.assertionMeta[id]) {
+			stripped[id] = true
+		}
+	}
+	// Assertions about stripped statements go with them
+	for _, id := range sortedIDs(stripped) {
+		s.closeOver(id, stripped)
+	}
+	for _, id := range sortedIDs(stripped) {
+		if _, isEntity := s.entities[id]; isEntity {
+			report.Stripped = append(report.Stripped, id)
+		} else if row, exists := s.assertions.row(id); exists && !s.assertions.isRetracted(row) {
+			report.Stripped = append(report.Stripped, id)
+		}
+	}
+
+	var statements []kmac.Statement
+	for _, stmt := range s.exportStatements() {
+		switch stmt := stmt.(type) {
+		case *kmac.Entity:
+			if stripped[stmt.ID()] {
+				continue
+			}
+			if masked[stmt.ID()] {
+				// Rebuilt rather than copied, so nothing about the original leaks
+				maskedEntity, err := kmac.NewEntity(stmt.ID(), maskLabel, "")
+				if err != nil {
+					return nil, nil, fmt.Errorf("failed to mask entity %s: %v", stmt.ID(), err)
+				}
+				statements = append(statements, maskedEntity)
+				continue
+			}
+		case *kmac.Assertion:
+			if stripped[stmt.ID()] {
+				continue
+			}
+		case *kmac.StateAssertion:
+			if stripped[stmt.EntityID()] || masked[stmt.EntityID()] {
+				continue
+			}
+		case *kmac.Temporal:
+			if stripped[stmt.AssertionID()] {
+				continue
+			}
+		case *kmac.SituationMember:
+			if stripped[stmt.AssertionID()] {
+				continue
+			}
+		case *kmac.Evidence:
+			if stripped[stmt.AssertionID()] {
+				continue
+			}
+		}
+		statements = append(statements, stmt)
+	}
+	sort.Strings(report.Stripped)
+	return statements, report, nil
+}
+
+// WriteRedactedKMAC writes the store's statements to w as KMAC text with a
+// filter applied, and reports what was left out or masked
+func (s *SemanticStore) WriteRedactedKMAC(w io.Writer, filter *RedactionFilter) (*RedactionReport, error) {
+	statements, report, err := s.RedactedStatements(filter)
+	if err != nil {
+		return nil, err
+	}
+	if err := kmac.NewTextSerializer().Encode(w, statements); err != nil {
+		return nil, fmt.Errorf("failed to encode KMAC: %v", err)
+	}
+	return report, nil
+}
+
+// RedactedStatements returns the snapshot's statements with a filter applied
+func (sn *Snapshot) RedactedStatements(filter *RedactionFilter) ([]kmac.Statement, *RedactionReport, error) {
+	return sn.store.RedactedStatements(filter)
+}
+
+// WriteRedactedKMAC writes the snapshot's statements to w as KMAC text with
+// a filter applied
+func (sn *Snapshot) WriteRedactedKMAC(w io.Writer, filter *RedactionFilter) (*RedactionReport, error) {
+	return sn.store.WriteRedactedKMAC(w, filter)
+}
@@ -0,0 +1,125 @@
+package semantic
+
+import (
+	"context"
+	"regexp"
+	"sort"
+)
+
+// relationTypePattern is the naming convention for relation types: upper
+// snake case, such as SPATIAL_RELATIONSHIP
+var relationTypePattern = regexp.MustCompile(`^[A-Z][A-Z0-9]*(_[A-Z0-9]+)*$`)
+
+// RelationUsage is how much a defined relation is used
+type RelationUsage struct {
+	RelationID   string
+	Label        string
+	RelationType string
+	Assertions   int // Live assertions using it
+	Retracted    int // Retracted or removed assertions using it
+	Subjects     int // Distinct subjects of its live assertions
+	Objects      int // Distinct objects of its live assertions
+}
+
+// RelationUsage reports how many assertions use each defined relation, most
+// used first, ties in ID order
+func (s *SemanticStore) RelationUsage() []RelationUsage {
+	usage, _ := s.RelationUsageContext(context.Background())
+	return usage
+}
+
+// RelationUsageContext reports relation usage as RelationUsage does,
+// returning an error if ctx is done or a frozen assertion cannot be read
+// first
+func (s *SemanticStore) RelationUsageContext(ctx context.Context) ([]RelationUsage, error) {
+	view, err := s.readView(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return view.relationUsage(), nil
+}
+
+// relationUsage reports the usage of each relation by the assertions in
+// memory
+func (s *SemanticStore) relationUsage() []RelationUsage {
+	usage := make([]RelationUsage, 0, len(s.relations))
+	for _, id := range sortedIDs(s.relations) {
+		relation := s.relations[id]
+		entry := RelationUsage{RelationID: id, Label: relation.Label(), RelationType: relation.RelationType()}
+		subjects, objects := make(map[string]bool), make(map[string]bool)
+		for _, row := range s.assertions.rowsWithRelation(id) {
+			if s.assertions.isRetracted(row) {
+				entry.Retracted++
+				continue
+			}
+			entry.Assertions++
+			subjects[s.assertions.subject(row)] = true
+			objects[s.assertions.object(row)] = true
+		}
+		entry.Subjects, entry.Objects = len(subjects), len(objects)
+		usage = append(usage, entry)
+	}
+	sort.SliceStable(usage, func(i, j int) bool { return usage[i].Assertions > usage[j].Assertions })
+	return usage
+}
+
+// UnusedRelations returns the IDs of the relations with no live assertions,
+// in order. A relation declared the inverse of a used one is not unused,
+// since queries through it find the other's assertions.
+func (s *SemanticStore) UnusedRelations() []string {
+	unused, _ := s.UnusedRelationsContext(context.Background())
+	return unused
+}
+
+// UnusedRelationsContext finds unused relations as UnusedRelations does,
+// returning an error if ctx is done or a frozen assertion cannot be read
+// first
+func (s *SemanticStore) UnusedRelationsContext(ctx context.Context) ([]string, error) {
+	view, err := s.readView(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return view.unusedRelations(), nil
+}
+
+// unusedRelations finds the relations no live assertion in memory uses
+func (s *SemanticStore) unusedRelations() []string {
+	var unused []string
+	for _, id := range sortedIDs(s.relations) {
+		if s.relationUsed(id) {
+			continue
+		}
+		if inverseID, has := s.relations[id].Inverse(); has && s.relationUsed(inverseID) {
+			continue
+		}
+		unused = append(unused, id)
+	}
+	return unused
+}
+
+// PruneRelations removes the relations UnusedRelations reports, leaving
+// tombstones, and returns their IDs
+func (s *SemanticStore) PruneRelations(reason string) ([]string, error) {
+	s.own()
+	unused, err := s.UnusedRelationsContext(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range unused {
+		if err := s.RemoveRelation(id, reason); err != nil {
+			return nil, err
+		}
+	}
+	return unused, nil
+}
+
+// relationUsed reports whether any live assertion uses a relation
+func (s *SemanticStore) relationUsed(id string) bool {
+	return len(s.assertions.live(s.assertions.rowsWithRelation(id))) > 0
+}
+
+// conventionalRelationType reports whether a relation type follows the
+// naming convention
+func conventionalRelationType(relationType string) bool {
+	return relationTypePattern.MatchString(relationType)
+}
@@ -0,0 +1,202 @@
+package semantic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// RetentionRule keeps the assertions about entities matching a TOSID
+// pattern for a time
+type RetentionRule struct {
+	Pattern string `json:"pattern,omitempty"` // TOSID pattern of the subject entity; empty matches every entity
+	TTL     string `json:"ttl,omitempty"`     // How long assertions are kept, such as "24h"; empty or "never" keeps them
+}
+
+// RetentionPolicy says how long assertions are kept before PurgeExpired
+// removes them. The first rule matching an assertion's subject applies, so
+// narrow rules go before broad ones; assertions about statements other
+// than entities, or about entities no rule matches, are kept.
+type RetentionPolicy struct {
+	Rules []RetentionRule `json:"rules,omitempty"`
+
+	ttls []time.Duration // Parallel to Rules; 0 keeps forever
+}
+
+// ExpiredAssertion is an assertion kept past its rule's TTL
+type ExpiredAssertion struct {
+	AssertionID string
+	Pattern     string // Of the rule that expired it
+	TTL         time.Duration
+	AssertedAt  time.Time
+}
+
+// RetentionReport lists what a purge removed
+type RetentionReport struct {
+	PurgedAt time.Time
+	Expired  []ExpiredAssertion
+	Removed  []string // IDs of every assertion removed, including those about expired ones
+}
+
+// Lines renders the report one removal per line, for audit logs
+func (r *RetentionReport) Lines() []string {
+	expired := make(map[string]bool, len(r.Expired))
+	var lines []string
+	for _, assertion := range r.Expired {
+		expired[assertion.AssertionID] = true
+		lines = append(lines, fmt.Sprintf("assertion %s asserted at %s expired after %s under %q, removed",
+			assertion.AssertionID, assertion.AssertedAt.Format(time.RFC3339), assertion.TTL, assertion.Pattern))
+	}
+	for _, id := range r.Removed {
+		if !expired[id] {
+			lines = append(lines, fmt.Sprintf("assertion %s removed with an expired assertion", id))
+		}
+	}
+	return lines
+}
+
+// LoadRetentionPolicy reads a retention policy file
+func LoadRetentionPolicy(r io.Reader) (*RetentionPolicy, error) {
+	var policy RetentionPolicy
+	if err := json.NewDecoder(r).Decode(&policy); err != nil {
+		return nil, fmt.Errorf("failed to decode retention policy: %v", err)
+	}
+	if err := policy.compile(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Save writes the policy as an indented retention policy file
+func (p *RetentionPolicy) Save(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(p)
+}
+
+// compile parses the TTL of every rule
+func (p *RetentionPolicy) compile() error {
+	p.ttls = make([]time.Duration, len(p.Rules))
+	for i, rule := range p.Rules {
+		if rule.TTL == "" || rule.TTL == "never" {
+			continue
+		}
+		ttl, err := time.ParseDuration(rule.TTL)
+		if err != nil {
+			return fmt.Errorf("invalid TTL %q: %v", rule.TTL, err)
+		}
+		if ttl <= 0 {
+			return fmt.Errorf("TTL %q must be positive", rule.TTL)
+		}
+		p.ttls[i] = ttl
+	}
+	return nil
+}
+
+// SetRetentionPolicy sets how long assertions are kept; nil keeps them
+// forever. Nothing is removed until PurgeExpired is called.
+func (s *SemanticStore) SetRetentionPolicy(policy *RetentionPolicy) error {
+	s.own()
+	if policy != nil {
+		if err := policy.compile(); err != nil {
+			return err
+		}
+	}
+	s.retention = policy
+	return nil
+}
+
+// RetentionPolicy returns the store's retention policy, or nil
+func (s *SemanticStore) RetentionPolicy() *RetentionPolicy {
+	return s.retention
+}
+
+// ExpiredAssertions returns the assertions the retention policy has
+// expired by a time, ordered by ID. An assertion's age runs from when it
+// was created in the store; retracted assertions expire too.
+func (s *SemanticStore) ExpiredAssertions(now time.Time) []ExpiredAssertion {
+	expired, _ := s.ExpiredAssertionsContext(context.Background(), now)
+	return expired
+}
+
+// ExpiredAssertionsContext finds expired assertions as ExpiredAssertions
+// does, returning an error if ctx is done or a frozen assertion cannot be
+// read first
+func (s *SemanticStore) ExpiredAssertionsContext(ctx context.Context, now time.Time) ([]ExpiredAssertion, error) {
+	view, err := s.readView(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return view.expiredAssertions(now), nil
+}
+
+// expiredAssertions finds the expired assertions in memory
+func (s *SemanticStore) expiredAssertions(now time.Time) []ExpiredAssertion {
+	if s.retention == nil {
+		return nil
+	}
+	var expired []ExpiredAssertion
+	for row := 0; row < s.assertions.span(); row++ {
+		if !s.assertions.holds(row) {
+			continue
+		}
+		id := s.assertions.id(row)
+		if s.isRemoved(id) {
+			continue
+		}
+		subject, exists := s.entities[s.assertions.subject(row)]
+		if !exists {
+			continue
+		}
+		for i, rule := range s.retention.Rules {
+			if !matchesRule(subject.TOSIDObj, rule.Pattern) {
+				continue
+			}
+			ttl := s.retention.ttls[i]
+			if assertedAt := s.assertedAt[id]; ttl > 0 && !now.Before(assertedAt.Add(ttl)) {
+				expired = append(expired, ExpiredAssertion{AssertionID: id, Pattern: rule.Pattern, TTL: ttl, AssertedAt: assertedAt})
+			}
+			break
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].AssertionID < expired[j].AssertionID })
+	return expired
+}
+
+// PurgeExpired removes the assertions the retention policy has expired by
+// a time, along with the assertions about them, and reports what it
+// removed. Each removal leaves a tombstone giving the rule as its reason,
+// as an audit trail; Compact drops the removed data for good.
+func (s *SemanticStore) PurgeExpired(now time.Time) (*RetentionReport, error) {
+	s.own()
+	expired, err := s.ExpiredAssertionsContext(context.Background(), now)
+	if err != nil {
+		return nil, err
+	}
+	report := &RetentionReport{PurgedAt: now, Expired: expired}
+	before := make(map[string]bool, len(s.tombstones))
+	for id := range s.tombstones {
+		before[id] = true
+	}
+
+	for _, expired := range report.Expired {
+		// An earlier removal may have taken this one with it
+		if s.isRemoved(expired.AssertionID) {
+			continue
+		}
+		reason := fmt.Sprintf("retention: expired after %s under %q", expired.TTL, expired.Pattern)
+		if removeErr := s.RemoveAssertion(expired.AssertionID, reason); removeErr != nil && err == nil {
+			err = removeErr
+		}
+	}
+
+	for _, tombstone := range s.Tombstones() {
+		if tombstone.Kind == "ASSERT" && !before[tombstone.ID] {
+			report.Removed = append(report.Removed, tombstone.ID)
+		}
+	}
+	return report, err
+}
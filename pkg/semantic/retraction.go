@@ -0,0 +1,172 @@
+package semantic
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Retraction records why and when an assertion was withdrawn. Retracted
+// assertions stay in the store for provenance but are hidden from queries.
+type Retraction struct {
+	AssertionID string
+	Reason      string
+	RetractedAt time.Time
+	Cause       string // ID of the retracted premise this follows from; empty if retracted directly
+	// LowConfidence marks retractions made because a derived assertion's
+	// confidence fell below the store's threshold; these are undone if it recovers
+	LowConfidence bool
+}
+
+// CreateDerivedAssertion creates an assertion inferred from premise assertions.
+// Its confidence is the product of the premises' confidences and is kept up to
+// date as they change. Retracting any premise also retracts the derived assertion.
+func (s *SemanticStore) CreateDerivedAssertion(id string, subjectID string, relationID string, objectID string, premiseIDs []string) error {
+	s.own()
+	for _, premiseID := range premiseIDs {
+		if premiseID == id {
+			return fmt.Errorf("assertion %s cannot be derived from itself", id)
+		}
+		row, exists := s.assertions.row(premiseID)
+		if !exists {
+			return fmt.Errorf("premise assertion %s not found", premiseID)
+		}
+		if s.assertions.isRetracted(row) {
+			return fmt.Errorf("premise assertion %s has been retracted", premiseID)
+		}
+	}
+
+	done := s.nest()
+	err := s.CreateAssertion(id, subjectID, relationID, objectID)
+	done()
+	if err != nil {
+		return err
+	}
+
+	s.derivations[id] = append([]string(nil), premiseIDs...)
+	for _, premiseID := range premiseIDs {
+		s.dependents[premiseID] = append(s.dependents[premiseID], id)
+	}
+	s.reevaluate(id)
+	return s.journal(walDerive, append([]string{id, subjectID, relationID, objectID}, premiseIDs...)...)
+}
+
+// Premises returns the assertions a derived assertion was inferred from
+func (s *SemanticStore) Premises(assertionID string) []string {
+	return append([]string(nil), s.derivations[assertionID]...)
+}
+
+// forgetDerivation drops the premise links of an assertion
+func (s *SemanticStore) forgetDerivation(id string) {
+	for _, premiseID := range s.derivations[id] {
+		dependents := s.dependents[premiseID]
+		for i, dependent := range dependents {
+			if dependent == id {
+				dependents = append(dependents[:i], dependents[i+1:]...)
+				break
+			}
+		}
+		if len(dependents) == 0 {
+			delete(s.dependents, premiseID)
+		} else {
+			s.dependents[premiseID] = dependents
+		}
+	}
+	delete(s.derivations, id)
+}
+
+// Retract withdraws an assertion without deleting it, recording the reason.
+// Assertions derived from it are retracted too. Re-creating an assertion with
+// the same ID reinstates it.
+func (s *SemanticStore) Retract(assertionID string, reason string) error {
+	s.own()
+	if err := s.thawStatement(assertionID); err != nil {
+		return err
+	}
+	row, exists := s.assertions.row(assertionID)
+	if !exists {
+		return fmt.Errorf("assertion %s not found", assertionID)
+	}
+	if s.isRemoved(assertionID) {
+		return fmt.Errorf("assertion %s has been removed", assertionID)
+	}
+	if s.assertions.isRetracted(row) {
+		return fmt.Errorf("assertion %s has already been retracted", assertionID)
+	}
+
+	now := time.Now()
+	s.retract(row, &Retraction{AssertionID: assertionID, Reason: reason, RetractedAt: now})
+	s.retractDependents(assertionID, "retracted: "+reason, now)
+	return s.journal(walRetract, assertionID, reason)
+}
+
+// retractDependents invalidates everything inferred from a withdrawn
+// premise, describing the premise's fate in each retraction
+func (s *SemanticStore) retractDependents(assertionID string, fate string, now time.Time) {
+	queue := []string{assertionID}
+	for len(queue) > 0 {
+		premiseID := queue[0]
+		queue = queue[1:]
+		for _, derivedID := range s.dependents[premiseID] {
+			derivedRow, _ := s.assertions.row(derivedID)
+			// Low-confidence retractions are upgraded so the assertion cannot be reinstated
+			if existing, retracted := s.retractions[derivedID]; retracted && !existing.LowConfidence {
+				continue
+			}
+			s.retract(derivedRow, &Retraction{
+				AssertionID: derivedID,
+				Reason:      fmt.Sprintf("premise %s %s", premiseID, fate),
+				RetractedAt: now,
+				Cause:       premiseID,
+			})
+			queue = append(queue, derivedID)
+		}
+	}
+}
+
+// retract marks a row as retracted and records its provenance
+func (s *SemanticStore) retract(row int, retraction *Retraction) {
+	s.assertions.setRetracted(row, true)
+	s.retractions[retraction.AssertionID] = retraction
+	s.recordChanged(RecordAssertion, retraction.AssertionID)
+}
+
+// GetRetraction returns the retraction record of an assertion, if it has been retracted
+func (s *SemanticStore) GetRetraction(assertionID string) (*Retraction, bool) {
+	s.thawStatement(assertionID)
+	retraction, exists := s.retractions[assertionID]
+	return retraction, exists
+}
+
+// FindRetractions returns every retraction record, ordered by assertion ID
+func (s *SemanticStore) FindRetractions() []*Retraction {
+	retractions, _ := s.FindRetractionsContext(context.Background())
+	return retractions
+}
+
+// FindRetractionsContext returns every retraction record as FindRetractions
+// does, returning an error if ctx is done or a frozen assertion cannot be
+// read first
+func (s *SemanticStore) FindRetractionsContext(ctx context.Context) ([]*Retraction, error) {
+	view, err := s.readView(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return view.findRetractions(), nil
+}
+
+// findRetractions returns the retraction records in memory
+func (s *SemanticStore) findRetractions() []*Retraction {
+	ids := make([]string, 0, len(s.retractions))
+	for id := range s.retractions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	results := make([]*Retraction, 0, len(ids))
+	for _, id := range ids {
+		results = append(results, s.retractions[id])
+	}
+	return results
+}
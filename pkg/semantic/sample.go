@@ -0,0 +1,130 @@
+package semantic
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+// SetSampleSeed makes the samples the store draws reproducible, so a
+// spot-check can be repeated. Until it is called, samples differ every time.
+func (s *SemanticStore) SetSampleSeed(seed int64) {
+	s.sampler = rand.New(rand.NewSource(seed))
+}
+
+// SampleEntities returns up to n entities chosen uniformly at random, from
+// those whose TOSID matches one of the patterns if any are given. Entities
+// are read in a single pass by reservoir sampling, in ID order so a seeded
+// sample repeats. The sample is ordered by ID.
+func (s *SemanticStore) SampleEntities(n int, patterns ...string) []*EntityReference {
+	sample, _ := s.SampleEntitiesContext(context.Background(), n, patterns...)
+	return sample
+}
+
+// SampleEntitiesContext samples entities as SampleEntities does, stopping
+// early if ctx is done or a frozen entity cannot be read
+func (s *SemanticStore) SampleEntitiesContext(ctx context.Context, n int, patterns ...string) ([]*EntityReference, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	entities := make(map[string]*EntityReference)
+	err := s.rangeAllEntities(ctx, func(entityRef *EntityReference) bool {
+		if len(patterns) == 0 || matchesAnyPattern(entityRef, patterns) {
+			entities[entityRef.KMACEntity.ID()] = entityRef
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	sample := make([]*EntityReference, 0, n)
+	seen := 0
+	for _, id := range sortedIDs(entities) {
+		entityRef := entities[id]
+		if slot := s.reservoirSlot(seen, n); slot == len(sample) {
+			sample = append(sample, entityRef)
+		} else if slot >= 0 {
+			sample[slot] = entityRef
+		}
+		seen++
+	}
+	sortEntities(sample)
+	return sample, nil
+}
+
+// SampleAssertions returns up to n live assertions chosen uniformly at
+// random by reservoir sampling, in the order they were made
+func (s *SemanticStore) SampleAssertions(n int) []*kmac.Assertion {
+	sample, _ := s.SampleAssertionsContext(context.Background(), n)
+	return sample
+}
+
+// SampleAssertionsContext samples live assertions as SampleAssertions does,
+// returning an error if ctx is done or a frozen assertion cannot be read
+// first
+func (s *SemanticStore) SampleAssertionsContext(ctx context.Context, n int) ([]*kmac.Assertion, error) {
+	view, err := s.readView(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return view.sampleAssertions(n), nil
+}
+
+// sampleAssertions samples the live assertions in memory
+func (s *SemanticStore) sampleAssertions(n int) []*kmac.Assertion {
+	if n <= 0 {
+		return nil
+	}
+	rows := make([]int, 0, n)
+	seen := 0
+	for row := 0; row < s.assertions.span(); row++ {
+		if !s.assertions.holds(row) {
+			continue
+		}
+		if s.assertions.isRetracted(row) {
+			continue
+		}
+		if slot := s.reservoirSlot(seen, n); slot == len(rows) {
+			rows = append(rows, row)
+		} else if slot >= 0 {
+			rows[slot] = row
+		}
+		seen++
+	}
+	sort.Ints(rows)
+	return s.materializeRows(rows)
+}
+
+// reservoirSlot returns where in a reservoir of size n the item after seen
+// others goes, or -1 if it is left out
+func (s *SemanticStore) reservoirSlot(seen int, n int) int {
+	if seen < n {
+		return seen
+	}
+	var j int
+	if s.sampler != nil {
+		j = s.sampler.Intn(seen + 1)
+	} else {
+		j = rand.Intn(seen + 1)
+	}
+	if j < n {
+		return j
+	}
+	return -1
+}
+
+// matchesAnyPattern reports whether an entity's TOSID matches one of the
+// patterns
+func matchesAnyPattern(entityRef *EntityReference, patterns []string) bool {
+	if entityRef.TOSIDObj == nil {
+		return false
+	}
+	for _, pattern := range patterns {
+		if entityRef.TOSIDObj.MatchesPattern(pattern) {
+			return true
+		}
+	}
+	return false
+}
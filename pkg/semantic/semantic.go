@@ -1,9 +1,13 @@
 package semantic
 
 import (
-	"errors"
+	"context"
 	"fmt"
+	"maps"
+	"math/rand"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/ha1tch/tosid-go/pkg/kmac"
 	"github.com/ha1tch/tosid-go/pkg/tosid"
@@ -17,24 +21,90 @@ type EntityReference struct {
 
 // SemanticStore represents a store for semantic entities and relationships
 type SemanticStore struct {
-	entities    map[string]*EntityReference
-	relations   map[string]*kmac.Relation
-	assertions  map[string]*kmac.Assertion
-	properties  map[string]*kmac.Property
+	entities         map[string]*EntityReference
+	relations        map[string]*kmac.Relation
+	assertions       *assertionTable
+	properties       map[string]*kmac.Property
+	symbols          *symbolTable
+	tosids           *tosid.Interner // TOSIDs of held entities, released as they are dropped
+	states           map[string]*kmac.StateHistory
+	retractions      map[string]*Retraction
+	derivations      map[string][]string // derived assertion ID -> premise IDs
+	dependents       map[string][]string // premise ID -> derived assertion IDs
+	naming           *NamingPolicy
+	vectors          map[string]entityVector
+	vectorDims       int
+	vectorIndex      *hnswIndex // nil unless EnableVectorIndex was called
+	times            map[string]*kmac.TimeReference
+	temporals        map[string]*kmac.Temporal // By assertion ID
+	integrity        IntegrityMode
+	pending          map[string][]string // unknown ID -> assertion IDs waiting for it
+	assertionMeta    map[string]*kmac.Metadata
+	tombstones       map[string]*Tombstone
+	removedEntities  map[string]*EntityReference // Kept until Compact
+	removedRelations map[string]*kmac.Relation   // Kept until Compact
+	limits           *StoreLimits                // nil unless SetLimits was called
+	entityAccess     *accessOrder                // Recorded while bounded or tiered
+	assertionAccess  *accessOrder                // Recorded while bounded or tiered
+	wal              *writeAheadLog              // nil unless OpenWAL was called
+	engine           *engineLink                 // nil unless AttachEngine was called
+	tiering          *tierLink                   // nil unless EnableTiering was called
+	assertedAt       map[string]time.Time        // Assertion ID -> when it was created, for retention
+	retention        *RetentionPolicy            // nil unless SetRetentionPolicy was called
+	feed             *changeFeed                 // nil unless EnableChangeFeed was called
+	syncCursors      map[string]string           // Source -> cursor of the last change applied from it
+	nested           int                         // Mutations in progress that are journaled as a whole
+	situations       map[string]*kmac.Situation
+	situationMembers map[string][]string // Situation ID -> assertion IDs, in the order added
+	sources          *kmac.SourceRegistry
+	evidence         map[string]*kmac.Evidence
+	queries          map[string]SavedQuery // nil until RegisterQuery is called
+	invariants       map[string]Invariant  // nil until RegisterInvariant is called
+	sampler          *rand.Rand            // nil until SetSampleSeed is called
+	shared           bool                  // Statements shared with a snapshot, copied before the next write
+	readOnly         bool                  // A snapshot's store, whose assertions are not written back when changed
+
+	confidenceThreshold float64
+	evictedEntities     int
+	evictedAssertions   int
 }
 
 // NewSemanticStore creates a new semantic store
 func NewSemanticStore() *SemanticStore {
+	symbols := newSymbolTable()
 	return &SemanticStore{
-		entities:   make(map[string]*EntityReference),
-		relations:  make(map[string]*kmac.Relation),
-		assertions: make(map[string]*kmac.Assertion),
-		properties: make(map[string]*kmac.Property),
+		entities:         make(map[string]*EntityReference),
+		relations:        make(map[string]*kmac.Relation),
+		assertions:       newAssertionTable(symbols),
+		properties:       make(map[string]*kmac.Property),
+		symbols:          symbols,
+		tosids:           tosid.NewInterner(),
+		states:           make(map[string]*kmac.StateHistory),
+		retractions:      make(map[string]*Retraction),
+		derivations:      make(map[string][]string),
+		dependents:       make(map[string][]string),
+		vectors:          make(map[string]entityVector),
+		times:            make(map[string]*kmac.TimeReference),
+		temporals:        make(map[string]*kmac.Temporal),
+		pending:          make(map[string][]string),
+		assertionMeta:    make(map[string]*kmac.Metadata),
+		tombstones:       make(map[string]*Tombstone),
+		removedEntities:  make(map[string]*EntityReference),
+		removedRelations: make(map[string]*kmac.Relation),
+		entityAccess:     newAccessOrder(),
+		assertionAccess:  newAccessOrder(),
+		assertedAt:       make(map[string]time.Time),
+		syncCursors:      make(map[string]string),
+		situations:       make(map[string]*kmac.Situation),
+		situationMembers: make(map[string][]string),
+		sources:          kmac.DefaultSources(),
+		evidence:         make(map[string]*kmac.Evidence),
 	}
 }
 
 // AddEntity adds a new entity to the store
 func (s *SemanticStore) AddEntity(id string, label string, tosidCode string) error {
+	s.own()
 	// Create KMAC entity
 	entity, err := kmac.NewEntity(id, label, tosidCode)
 	if err != nil {
@@ -44,40 +114,72 @@ func (s *SemanticStore) AddEntity(id string, label string, tosidCode string) err
 	// Parse TOSID code if provided
 	var tosidObj *tosid.TOSID
 	if tosidCode != "" {
-		tosidObj, err = tosid.Parse(tosidCode)
+		tosidObj, err = s.tosids.Intern(tosidCode)
 		if err != nil {
 			return fmt.Errorf("failed to parse TOSID code: %v", err)
 		}
 	}
 
+	if err := s.checkNaming(id, label, tosidObj); err != nil {
+		s.releaseTOSID(tosidCode)
+		return err
+	}
+	if err := s.thawStatement(id); err != nil {
+		s.releaseTOSID(tosidCode)
+		return err
+	}
+	if replaced, exists := s.entities[id]; exists {
+		s.releaseTOSID(replaced.KMACEntity.TOSIDType())
+	}
+
 	// Create entity reference
 	entityRef := &EntityReference{
 		KMACEntity: entity,
 		TOSIDObj:   tosidObj,
 	}
 
+	s.forgetTombstone(id)
 	s.entities[id] = entityRef
-	return nil
+	s.recordChanged(RecordEntity, id)
+	s.resolvePending(id)
+	s.touchEntity(id)
+	s.enforceLimits(id)
+	return s.journal(walEntity, id, label, tosidCode)
+}
+
+// releaseTOSID drops the store's hold on the TOSID of an entity it no
+// longer holds, so the interner forgets codes no entity uses
+func (s *SemanticStore) releaseTOSID(code string) {
+	if code != "" {
+		s.tosids.Release(code)
+	}
 }
 
 // GetEntity retrieves an entity from the store
 func (s *SemanticStore) GetEntity(id string) (*EntityReference, error) {
+	if err := s.thawStatement(id); err != nil {
+		return nil, err
+	}
 	entity, exists := s.entities[id]
 	if !exists {
 		return nil, fmt.Errorf("entity %s not found", id)
 	}
+	s.touchEntity(id)
 	return entity, nil
 }
 
 // AddRelation adds a new relation to the store
 func (s *SemanticStore) AddRelation(id string, label string, relationType string) error {
+	s.own()
 	relation, err := kmac.NewRelation(id, label, relationType)
 	if err != nil {
 		return fmt.Errorf("failed to create relation: %v", err)
 	}
 
+	s.forgetTombstone(id)
 	s.relations[id] = relation
-	return nil
+	s.recordChanged(RecordRelation, id)
+	return s.journal(walRelation, id, label, relationType)
 }
 
 // GetRelation retrieves a relation from the store
@@ -89,15 +191,14 @@ func (s *SemanticStore) GetRelation(id string) (*kmac.Relation, error) {
 	return relation, nil
 }
 
-// CreateAssertion creates a new assertion between entities
+// CreateAssertion creates a new assertion between entities. The subject or
+// object may also be an existing assertion, to make statements about statements.
+// References to unknown IDs are treated according to the integrity mode.
 func (s *SemanticStore) CreateAssertion(id string, subjectID string, relationID string, objectID string) error {
-	// Verify that subject and object entities exist
-	if _, err := s.GetEntity(subjectID); err != nil {
-		return fmt.Errorf("subject entity not found: %v", err)
-	}
-
-	if _, err := s.GetEntity(objectID); err != nil {
-		return fmt.Errorf("object entity not found: %v", err)
+	s.own()
+	missing, err := s.checkReferences(subjectID, relationID, objectID)
+	if err != nil {
+		return err
 	}
 
 	// Create assertion
@@ -106,73 +207,292 @@ func (s *SemanticStore) CreateAssertion(id string, subjectID string, relationID
 		return fmt.Errorf("failed to create assertion: %v", err)
 	}
 
-	s.assertions[id] = assertion
+	if err := s.insertAssertion(assertion, missing); err != nil {
+		return err
+	}
+	s.enforceLimits(assertion.ID())
+	return s.journal(walAssert, id, subjectID, relationID, objectID)
+}
+
+// insertAssertion stores a validated assertion that waits for the given
+// unknown IDs. Re-creating an assertion reinstates it as a direct,
+// unretracted statement. It fails only if a frozen assertion it replaces
+// cannot be loaded back.
+func (s *SemanticStore) insertAssertion(assertion *kmac.Assertion, missing []string) error {
+	if err := s.thawStatement(assertion.ID()); err != nil {
+		return err
+	}
+	_, replacing := s.assertions.row(assertion.ID())
+	s.assertions.put(assertion.ID(), assertion.Subject(), assertion.Relation(), assertion.Object())
+	s.recordChanged(RecordAssertion, assertion.ID())
+	if replacing {
+		delete(s.retractions, assertion.ID())
+		s.forgetDerivation(assertion.ID())
+		s.forgetPending(assertion.ID())
+	}
+	// A tombstone read from a storage engine has no row to replace
+	s.forgetTombstone(assertion.ID())
+	s.addPending(assertion.ID(), missing)
+	s.resolvePending(assertion.ID())
+	s.touchAssertion(assertion.ID())
+	s.assertedAt[assertion.ID()] = time.Now()
 	return nil
 }
 
-// GetAssertion retrieves an assertion from the store
+// checkNode verifies that an ID refers to a stored entity, or to a stored
+// assertion when it carries the assertion prefix
+func (s *SemanticStore) checkNode(id string) error {
+	if kmac.IsAssertionReference(id) {
+		if _, exists := s.assertions.row(id); !exists {
+			return fmt.Errorf("assertion %s not found", id)
+		}
+		if s.isRemoved(id) {
+			return fmt.Errorf("assertion %s has been removed", id)
+		}
+		return nil
+	}
+	_, err := s.GetEntity(id)
+	return err
+}
+
+// hasNode reports whether an ID refers to a stored entity or assertion
+func (s *SemanticStore) hasNode(id string) bool {
+	if _, exists := s.entities[id]; exists {
+		return true
+	}
+	_, exists := s.assertions.row(id)
+	return exists && !s.isRemoved(id)
+}
+
+// GetAssertion retrieves an assertion from the store. Assertions are stored
+// in columns and built afresh on each read, here and in the FindAssertions
+// and RangeAssertions lookups, but the assertion returned is a handle on the
+// stored one: confidence, tags, annotations, and notes set on it are written
+// back to the store, as SetAssertionConfidence, Tag, Untag, Annotate, and
+// AddNote would write them. Each read reflects the store as it was then.
 func (s *SemanticStore) GetAssertion(id string) (*kmac.Assertion, error) {
-	assertion, exists := s.assertions[id]
+	if err := s.thawStatement(id); err != nil {
+		return nil, err
+	}
+	row, exists := s.assertions.row(id)
 	if !exists {
 		return nil, fmt.Errorf("assertion %s not found", id)
 	}
-	return assertion, nil
+	if s.isRemoved(id) {
+		return nil, fmt.Errorf("assertion %s has been removed", id)
+	}
+	if s.assertions.isRetracted(row) {
+		return nil, fmt.Errorf("assertion %s has been retracted", id)
+	}
+	return s.materialize(row), nil
 }
 
 // FindEntitiesByTOSIDPattern finds entities matching a TOSID pattern
 func (s *SemanticStore) FindEntitiesByTOSIDPattern(pattern string) []*EntityReference {
+	results, _ := s.FindEntitiesByTOSIDPatternContext(context.Background(), pattern)
+	return results
+}
+
+// FindEntitiesByTOSIDPatternContext finds entities matching a TOSID pattern, ordered by ID, stopping early if ctx is done
+func (s *SemanticStore) FindEntitiesByTOSIDPatternContext(ctx context.Context, pattern string) ([]*EntityReference, error) {
 	var results []*EntityReference
+	err := s.RangeEntitiesByTOSIDPatternContext(ctx, pattern, func(entityRef *EntityReference) bool {
+		results = append(results, entityRef)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	for _, entityRef := range s.entities {
+	sortEntities(results)
+	return results, nil
+}
+
+// RangeEntitiesByTOSIDPattern calls fn for each entity matching a TOSID pattern
+// without building a result slice. Iteration stops when fn returns false.
+func (s *SemanticStore) RangeEntitiesByTOSIDPattern(pattern string, fn func(*EntityReference) bool) {
+	s.RangeEntitiesByTOSIDPatternContext(context.Background(), pattern, fn)
+}
+
+// RangeEntitiesByTOSIDPatternContext calls fn for each entity matching a
+// TOSID pattern as RangeEntitiesByTOSIDPattern does, stopping early if ctx
+// is done or a frozen entity cannot be read
+func (s *SemanticStore) RangeEntitiesByTOSIDPatternContext(ctx context.Context, pattern string, fn func(*EntityReference) bool) error {
+	return s.rangeAllEntities(ctx, func(entityRef *EntityReference) bool {
 		if entityRef.TOSIDObj != nil && entityRef.TOSIDObj.MatchesPattern(pattern) {
-			results = append(results, entityRef)
+			return fn(entityRef)
 		}
-	}
+		return true
+	})
+}
 
-	return results
+// RangeEntities calls fn for each entity in the store. Iteration stops when fn returns false.
+func (s *SemanticStore) RangeEntities(fn func(*EntityReference) bool) {
+	s.RangeEntitiesContext(context.Background(), fn)
+}
+
+// RangeEntitiesContext calls fn for each entity as RangeEntities does,
+// stopping early if ctx is done or a frozen entity cannot be read
+func (s *SemanticStore) RangeEntitiesContext(ctx context.Context, fn func(*EntityReference) bool) error {
+	return s.rangeAllEntities(ctx, fn)
+}
+
+// RangeAssertionsForEntity calls fn for each assertion where the given entity is
+// either subject or object. Iteration stops when fn returns false.
+func (s *SemanticStore) RangeAssertionsForEntity(entityID string, fn func(*kmac.Assertion) bool) {
+	s.RangeAssertionsForEntityContext(context.Background(), entityID, fn)
+}
+
+// RangeAssertionsForEntityContext calls fn for each assertion about an
+// entity as RangeAssertionsForEntity does, stopping early if ctx is done or
+// frozen assertions cannot be read
+func (s *SemanticStore) RangeAssertionsForEntityContext(ctx context.Context, entityID string, fn func(*kmac.Assertion) bool) error {
+	if err := s.thawAbout(entityID); err != nil {
+		return err
+	}
+	for _, row := range s.assertions.live(s.assertions.rowsReferencing(entityID)) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !fn(s.materialize(row)) {
+			return nil
+		}
+	}
+	return nil
 }
 
 // FindAssertionsForEntity finds all assertions where the given entity is either subject or object
 func (s *SemanticStore) FindAssertionsForEntity(entityID string) []*kmac.Assertion {
-	var results []*kmac.Assertion
+	results, _ := s.FindAssertionsForEntityContext(context.Background(), entityID)
+	return results
+}
+
+// FindAssertionsForEntityContext finds all assertions where the given entity is either subject or object, stopping early if ctx is done
+func (s *SemanticStore) FindAssertionsForEntityContext(ctx context.Context, entityID string) ([]*kmac.Assertion, error) {
+	return s.findAssertions(ctx, entityID, s.assertions.rowsReferencing)
+}
+
+// FindAssertionsBySubject finds all assertions with the given subject
+func (s *SemanticStore) FindAssertionsBySubject(subjectID string) []*kmac.Assertion {
+	results, _ := s.FindAssertionsBySubjectContext(context.Background(), subjectID)
+	return results
+}
+
+// FindAssertionsBySubjectContext finds all assertions with the given subject, stopping early if ctx is done
+func (s *SemanticStore) FindAssertionsBySubjectContext(ctx context.Context, subjectID string) ([]*kmac.Assertion, error) {
+	return s.findAssertions(ctx, subjectID, s.assertions.rowsWithSubject)
+}
+
+// FindAssertionsByRelation finds all assertions using the given relation
+func (s *SemanticStore) FindAssertionsByRelation(relationID string) []*kmac.Assertion {
+	results, _ := s.FindAssertionsByRelationContext(context.Background(), relationID)
+	return results
+}
 
-	for _, assertion := range s.assertions {
-		if assertion.Subject() == entityID || assertion.Object() == entityID {
-			results = append(results, assertion)
+// FindAssertionsByRelationContext finds all assertions using the given relation, stopping early if ctx is done
+func (s *SemanticStore) FindAssertionsByRelationContext(ctx context.Context, relationID string) ([]*kmac.Assertion, error) {
+	return s.findAssertions(ctx, relationID, s.assertions.rowsWithRelation)
+}
+
+// FindAssertionsByObject finds all assertions with the given object
+func (s *SemanticStore) FindAssertionsByObject(objectID string) []*kmac.Assertion {
+	results, _ := s.FindAssertionsByObjectContext(context.Background(), objectID)
+	return results
+}
+
+// FindAssertionsByObjectContext finds all assertions with the given object, stopping early if ctx is done
+func (s *SemanticStore) FindAssertionsByObjectContext(ctx context.Context, objectID string) ([]*kmac.Assertion, error) {
+	return s.findAssertions(ctx, objectID, s.assertions.rowsWithObject)
+}
+
+// FindAssertionsAbout finds all assertions whose subject or object is the given assertion
+func (s *SemanticStore) FindAssertionsAbout(assertionID string) []*kmac.Assertion {
+	results, _ := s.FindAssertionsAboutContext(context.Background(), assertionID)
+	return results
+}
+
+// FindAssertionsAboutContext finds all assertions whose subject or object is the given assertion, stopping early if ctx is done
+func (s *SemanticStore) FindAssertionsAboutContext(ctx context.Context, assertionID string) ([]*kmac.Assertion, error) {
+	return s.findAssertions(ctx, assertionID, s.assertions.rowsReferencing)
+}
+
+// findAssertions loads the frozen statements about an ID back into memory,
+// reporting a failed read, and builds the live assertions in the rows an
+// index gives for it
+func (s *SemanticStore) findAssertions(ctx context.Context, id string, rows func(string) []int) ([]*kmac.Assertion, error) {
+	if err := s.thawAbout(id); err != nil {
+		return nil, err
+	}
+	var results []*kmac.Assertion
+	for _, row := range s.assertions.live(rows(id)) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
 		}
+		results = append(results, s.materialize(row))
 	}
+	return results, nil
+}
 
+// materializeRows builds assertions for a set of table rows, skipping retracted ones
+func (s *SemanticStore) materializeRows(rows []int) []*kmac.Assertion {
+	var results []*kmac.Assertion
+	for _, row := range s.assertions.live(rows) {
+		results = append(results, s.materialize(row))
+	}
 	return results
 }
 
-// FindEntitiesByLabel finds entities by label (case-insensitive partial match)
+// FindEntitiesByLabel finds entities by label (case-insensitive partial match),
+// including the labels in other languages and synonyms added by AddLabel
 func (s *SemanticStore) FindEntitiesByLabel(labelPattern string) []*EntityReference {
+	results, _ := s.FindEntitiesByLabelContext(context.Background(), labelPattern)
+	return results
+}
+
+// FindEntitiesByLabelContext finds entities by label, ordered by ID, stopping early if ctx is done
+func (s *SemanticStore) FindEntitiesByLabelContext(ctx context.Context, labelPattern string) ([]*EntityReference, error) {
 	var results []*EntityReference
 	pattern := strings.ToLower(labelPattern)
 
-	for _, entityRef := range s.entities {
-		entityLabel := strings.ToLower(entityRef.KMACEntity.Label())
-		if strings.Contains(entityLabel, pattern) {
+	err := s.rangeAllEntities(ctx, func(entityRef *EntityReference) bool {
+		if labelMatches(entityRef.KMACEntity.Label(), &entityRef.KMACEntity.Metadata, pattern) {
 			results = append(results, entityRef)
 		}
+		return true
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return results
+	sortEntities(results)
+	return results, nil
 }
 
 // FindRelatedEntities finds entities related to a given entity through assertions
 func (s *SemanticStore) FindRelatedEntities(entityID string) map[string][]*EntityReference {
+	results, _ := s.FindRelatedEntitiesContext(context.Background(), entityID)
+	return results
+}
+
+// FindRelatedEntitiesContext finds entities related to a given entity through assertions, stopping early if ctx is done
+func (s *SemanticStore) FindRelatedEntitiesContext(ctx context.Context, entityID string) (map[string][]*EntityReference, error) {
+	if err := s.thawAbout(entityID); err != nil {
+		return nil, err
+	}
 	results := make(map[string][]*EntityReference)
 
-	for _, assertion := range s.assertions {
+	for _, row := range s.assertions.live(s.assertions.rowsReferencing(entityID)) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		var relatedID string
 		var direction string
 
-		if assertion.Subject() == entityID {
-			relatedID = assertion.Object()
+		if s.assertions.subject(row) == entityID {
+			relatedID = s.assertions.object(row)
 			direction = "outbound"
-		} else if assertion.Object() == entityID {
-			relatedID = assertion.Subject()
+		} else {
+			relatedID = s.assertions.subject(row)
 			direction = "inbound"
 		}
 
@@ -186,7 +506,7 @@ func (s *SemanticStore) FindRelatedEntities(entityID string) map[string][]*Entit
 		}
 	}
 
-	return results
+	return results, nil
 }
 
 // GetStatistics returns statistics about the semantic store
@@ -194,7 +514,17 @@ func (s *SemanticStore) GetStatistics() map[string]int {
 	stats := make(map[string]int)
 	stats["entities"] = len(s.entities)
 	stats["relations"] = len(s.relations)
-	stats["assertions"] = len(s.assertions)
+	stats["assertions"] = s.assertions.len() - len(s.retractions)
+	stats["retracted_assertions"] = len(s.retractions)
+	stats["tombstones"] = len(s.tombstones)
+	stats["evicted_entities"] = s.evictedEntities
+	stats["evicted_assertions"] = s.evictedAssertions
+	for id, tombstone := range s.tombstones {
+		_, held := s.assertions.row(id)
+		if _, retracted := s.retractions[id]; tombstone.Kind == "ASSERT" && held && !retracted {
+			stats["assertions"]--
+		}
+	}
 	stats["properties"] = len(s.properties)
 
 	// Count entities by taxonomy
@@ -206,6 +536,40 @@ func (s *SemanticStore) GetStatistics() map[string]int {
 		}
 	}
 
+	// Frozen statements are counted without loading them
+	if s.tiering != nil {
+		for _, ref := range s.tiering.groups {
+			stats["entities"]++
+			stats["assertions"] += len(ref.assertions) - ref.retracted
+			stats["retracted_assertions"] += ref.retracted
+			if ref.taxonomy != "" {
+				taxonomyCount[ref.taxonomy]++
+			}
+		}
+	}
+
+	// Statements only a storage engine holds are counted from their records
+	if s.fetches() {
+		ctx := context.Background()
+		s.scanEngine(ctx, RecordEntity, nil, func(record Record) bool {
+			stats["entities"]++
+			if code := record.Fields["tosid"]; code != "" {
+				if tosidObj, err := tosid.Parse(code); err == nil {
+					taxonomyCount[tosidObj.TaxonomyCode]++
+				}
+			}
+			return true
+		})
+		s.scanEngine(ctx, RecordAssertion, nil, func(record Record) bool {
+			if _, retracted := record.Fields["retracted"]; retracted {
+				stats["retracted_assertions"]++
+			} else {
+				stats["assertions"]++
+			}
+			return true
+		})
+	}
+
 	for taxonomy, count := range taxonomyCount {
 		stats["taxonomy_"+taxonomy] = count
 	}
@@ -215,39 +579,118 @@ func (s *SemanticStore) GetStatistics() map[string]int {
 
 // ValidateStore performs consistency checks on the semantic store
 func (s *SemanticStore) ValidateStore() []string {
+	warnings, _ := s.ValidateStoreContext(context.Background())
+	return warnings
+}
+
+// ValidateStoreContext performs consistency checks on the semantic store, stopping early if ctx is done
+func (s *SemanticStore) ValidateStoreContext(ctx context.Context) ([]string, error) {
+	view, err := s.readView(ctx)
+	if err != nil {
+		return nil, err
+	}
+	warnings, err := view.validateStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Invariants run on the store itself, as they may write to it
+	violations, err := s.runInvariants(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	return append(warnings, violations...), nil
+}
+
+// validateStore runs the structural consistency checks, stopping early if
+// ctx is done
+func (s *SemanticStore) validateStore(ctx context.Context) ([]string, error) {
 	var warnings []string
 
 	// Check for assertions with missing entities
-	for assertionID, assertion := range s.assertions {
-		if _, exists := s.entities[assertion.Subject()]; !exists {
-			warnings = append(warnings, fmt.Sprintf("assertion %s references non-existent subject %s", assertionID, assertion.Subject()))
+	for row := 0; row < s.assertions.span(); row++ {
+		if !s.assertions.holds(row) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if s.assertions.isRetracted(row) {
+			continue
 		}
-		if _, exists := s.entities[assertion.Object()]; !exists {
-			warnings = append(warnings, fmt.Sprintf("assertion %s references non-existent object %s", assertionID, assertion.Object()))
+		assertionID := s.assertions.id(row)
+		if !s.hasNode(s.assertions.subject(row)) {
+			warnings = append(warnings, fmt.Sprintf("assertion %s references non-existent subject %s", assertionID, s.assertions.subject(row)))
+		}
+		if !s.hasNode(s.assertions.object(row)) {
+			warnings = append(warnings, fmt.Sprintf("assertion %s references non-existent object %s", assertionID, s.assertions.object(row)))
+		}
+		if relation, exists := s.relations[s.assertions.relation(row)]; exists && !conventionalRelationType(relation.RelationType()) {
+			warnings = append(warnings, fmt.Sprintf("assertion %s uses relation %s of type %q, which is not upper snake case", assertionID, relation.ID(), relation.RelationType()))
 		}
 	}
 
-	// Check for orphaned entities (entities with no assertions)
+	// Check for orphaned entities (entities with no assertions), in ID order so reports are stable
+	entityIDs := make([]string, 0, len(s.entities))
 	for entityID := range s.entities {
-		hasAssertions := false
-		for _, assertion := range s.assertions {
-			if assertion.Subject() == entityID || assertion.Object() == entityID {
-				hasAssertions = true
-				break
-			}
+		entityIDs = append(entityIDs, entityID)
+	}
+	sort.Strings(entityIDs)
+
+	for _, entityID := range entityIDs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
 		}
-		if !hasAssertions {
+		if len(s.assertions.live(s.assertions.rowsReferencing(entityID))) == 0 {
 			warnings = append(warnings, fmt.Sprintf("entity %s has no assertions", entityID))
 		}
 	}
-
-	return warnings
+	return warnings, nil
 }
 
 // Clear removes all data from the semantic store
 func (s *SemanticStore) Clear() {
+	s.recordsChanged()
 	s.entities = make(map[string]*EntityReference)
 	s.relations = make(map[string]*kmac.Relation)
-	s.assertions = make(map[string]*kmac.Assertion)
+	s.symbols = newSymbolTable()
+	s.tosids = tosid.NewInterner()
+	s.assertions = newAssertionTable(s.symbols)
 	s.properties = make(map[string]*kmac.Property)
-}
\ No newline at end of file
+	s.states = make(map[string]*kmac.StateHistory)
+	s.retractions = make(map[string]*Retraction)
+	s.derivations = make(map[string][]string)
+	s.dependents = make(map[string][]string)
+	s.vectors = make(map[string]entityVector)
+	s.vectorDims = 0
+	if s.vectorIndex != nil {
+		s.vectorIndex = newHNSWIndex(s.vectorIndex.opts)
+	}
+	s.times = make(map[string]*kmac.TimeReference)
+	s.temporals = make(map[string]*kmac.Temporal)
+	s.pending = make(map[string][]string)
+	s.assertionMeta = make(map[string]*kmac.Metadata)
+	s.tombstones = make(map[string]*Tombstone)
+	s.removedEntities = make(map[string]*EntityReference)
+	s.removedRelations = make(map[string]*kmac.Relation)
+	s.entityAccess.reset()
+	s.assertionAccess.reset()
+	s.assertedAt = make(map[string]time.Time)
+	if s.tiering != nil {
+		s.dropTiering()
+	}
+	s.situations = make(map[string]*kmac.Situation)
+	s.situationMembers = make(map[string][]string)
+	s.evidence = make(map[string]*kmac.Evidence)
+	s.evictedEntities, s.evictedAssertions = 0, 0
+	if s.shared {
+		// Everything a snapshot shares has been replaced but the sync cursors
+		s.syncCursors = maps.Clone(s.syncCursors)
+		s.shared = false
+	}
+	if s.feed != nil {
+		// Cursors into the old feed refer to statements that are gone
+		s.feed.restart()
+	}
+	s.journal(walClear)
+}
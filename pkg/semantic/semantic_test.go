@@ -1,8 +1,22 @@
 package semantic
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
 )
 
 func TestSemanticStoreBasicOperations(t *testing.T) {
@@ -82,6 +96,193 @@ func TestSemanticStoreValidation(t *testing.T) {
 	}
 }
 
+func TestSemanticStoreAssertionStorage(t *testing.T) {
+	store := NewSemanticStore()
+
+	store.AddEntity("E1001", "Sun", "")
+	store.AddEntity("E1002", "Earth", "")
+	store.AddEntity("E1003", "Moon", "")
+
+	if err := store.CreateAssertion("F1001", "E1002", "R1001", "E1001"); err != nil {
+		t.Fatalf("Failed to create assertion: %v", err)
+	}
+	if err := store.CreateAssertion("F1002", "E1003", "R1001", "E1002"); err != nil {
+		t.Fatalf("Failed to create assertion: %v", err)
+	}
+
+	assertion, err := store.GetAssertion("F1002")
+	if err != nil {
+		t.Fatalf("Failed to get assertion: %v", err)
+	}
+	if assertion.Subject() != "E1003" || assertion.Relation() != "R1001" || assertion.Object() != "E1002" {
+		t.Errorf("Unexpected assertion contents: %s", assertion.String())
+	}
+
+	// Re-creating an assertion with the same ID replaces it
+	if err := store.CreateAssertion("F1002", "E1003", "R1001", "E1001"); err != nil {
+		t.Fatalf("Failed to replace assertion: %v", err)
+	}
+	if stats := store.GetStatistics(); stats["assertions"] != 2 {
+		t.Errorf("Expected 2 assertions, got %d", stats["assertions"])
+	}
+	if assertions := store.FindAssertionsForEntity("E1001"); len(assertions) != 2 {
+		t.Errorf("Expected 2 assertions for E1001, got %d", len(assertions))
+	}
+
+	if _, err := store.GetAssertion("F9999"); err == nil {
+		t.Error("Expected error for unknown assertion")
+	}
+}
+
+func TestSemanticStoreIndexedLookups(t *testing.T) {
+	store := NewSemanticStore()
+
+	store.AddEntity("E1001", "Sun", "")
+	store.AddEntity("E1002", "Earth", "")
+	store.AddEntity("E1003", "Moon", "")
+
+	store.CreateAssertion("F1001", "E1002", "R1001", "E1001")
+	store.CreateAssertion("F1002", "E1003", "R1001", "E1002")
+	store.CreateAssertion("F1003", "E1003", "R1002", "E1003")
+
+	if results := store.FindAssertionsBySubject("E1003"); len(results) != 2 {
+		t.Errorf("Expected 2 assertions with subject E1003, got %d", len(results))
+	}
+	if results := store.FindAssertionsByRelation("R1001"); len(results) != 2 {
+		t.Errorf("Expected 2 assertions with relation R1001, got %d", len(results))
+	}
+	if results := store.FindAssertionsByObject("E1001"); len(results) != 1 {
+		t.Errorf("Expected 1 assertion with object E1001, got %d", len(results))
+	}
+
+	// A self-referencing assertion is only reported once
+	if results := store.FindAssertionsForEntity("E1003"); len(results) != 2 {
+		t.Errorf("Expected 2 assertions for E1003, got %d", len(results))
+	}
+
+	// Replacing an assertion moves it between index entries
+	store.CreateAssertion("F1001", "E1002", "R1002", "E1003")
+	if results := store.FindAssertionsByRelation("R1001"); len(results) != 1 {
+		t.Errorf("Expected 1 assertion with relation R1001 after replace, got %d", len(results))
+	}
+	if results := store.FindAssertionsByObject("E1001"); len(results) != 0 {
+		t.Errorf("Expected no assertions with object E1001 after replace, got %d", len(results))
+	}
+}
+
+func TestSemanticStoreQueryParallel(t *testing.T) {
+	store := NewSemanticStore()
+	for i := 0; i < 100; i++ {
+		store.AddEntity(fmt.Sprintf("E%03d", i), fmt.Sprintf("Entity_%d", i), "")
+	}
+	for i := 0; i < 99; i++ {
+		store.CreateAssertion(fmt.Sprintf("F%03d", i), fmt.Sprintf("E%03d", i), "R1001", fmt.Sprintf("E%03d", i+1))
+	}
+
+	opts := ParallelQueryOptions{Workers: 4, ShardSize: 8}
+	results, err := store.QueryParallel(context.Background(), func(entityRef *EntityReference) bool {
+		return strings.HasSuffix(entityRef.KMACEntity.Label(), "0")
+	}, opts)
+	if err != nil {
+		t.Fatalf("QueryParallel failed: %v", err)
+	}
+	if len(results) != 10 {
+		t.Fatalf("Expected 10 matches, got %d", len(results))
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i-1].KMACEntity.ID() >= results[i].KMACEntity.ID() {
+			t.Errorf("Expected results ordered by ID, got %s before %s", results[i-1].KMACEntity.ID(), results[i].KMACEntity.ID())
+		}
+	}
+
+	reached, err := store.TraverseParallel(context.Background(), []string{"E000", "E050"}, 2, opts)
+	if err != nil {
+		t.Fatalf("TraverseParallel failed: %v", err)
+	}
+	if got := strings.Join(reached["E000"], ","); got != "E001,E002" {
+		t.Errorf("Expected E000 to reach E001,E002, got %s", got)
+	}
+	if got := strings.Join(reached["E050"], ","); got != "E048,E049,E051,E052" {
+		t.Errorf("Expected E050 to reach E048,E049,E051,E052, got %s", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := store.QueryParallel(ctx, func(*EntityReference) bool { return true }, opts); err == nil {
+		t.Error("Expected cancelled query to return an error")
+	}
+}
+
+func TestSemanticStoreContextCancellation(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Sun", "")
+	store.AddEntity("E1002", "Earth", "")
+
+	if results, err := store.FindEntitiesByLabelContext(context.Background(), "ear"); err != nil || len(results) != 1 {
+		t.Errorf("Expected 1 label match without error, got %d (%v)", len(results), err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := store.FindEntitiesByLabelContext(ctx, "ear"); err != context.Canceled {
+		t.Errorf("Expected context.Canceled from label search, got %v", err)
+	}
+	if _, err := store.FindEntitiesByTOSIDPatternContext(ctx, "00"); err != context.Canceled {
+		t.Errorf("Expected context.Canceled from pattern search, got %v", err)
+	}
+	if _, err := store.ValidateStoreContext(ctx); err != context.Canceled {
+		t.Errorf("Expected context.Canceled from validation, got %v", err)
+	}
+
+	store.CreateAssertion("F1001", "E1002", "R1001", "E1001")
+	if _, err := store.FindAssertionsForEntityContext(ctx, "E1001"); err != context.Canceled {
+		t.Errorf("Expected context.Canceled from assertion lookup, got %v", err)
+	}
+	if _, err := store.FindRelatedEntitiesContext(ctx, "E1001"); err != context.Canceled {
+		t.Errorf("Expected context.Canceled from related entities, got %v", err)
+	}
+	var buf bytes.Buffer
+	if err := store.WriteKMACContext(ctx, &buf); err != context.Canceled || buf.Len() != 0 {
+		t.Errorf("Expected context.Canceled from export before anything was written, got %v", err)
+	}
+	store.WriteKMAC(&buf)
+	loaded := NewSemanticStore()
+	if err := loaded.LoadKMACContext(ctx, &buf); err != context.Canceled {
+		t.Errorf("Expected context.Canceled from load, got %v", err)
+	}
+	if stats := loaded.GetStatistics(); stats["entities"] != 0 {
+		t.Errorf("Expected a cancelled load to add nothing, got %v", stats)
+	}
+}
+
+func TestSemanticStoreRange(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Sun", "")
+	store.AddEntity("E1002", "Earth", "")
+	store.AddEntity("E1003", "Moon", "")
+	store.CreateAssertion("F1001", "E1002", "R1001", "E1001")
+	store.CreateAssertion("F1002", "E1003", "R1001", "E1002")
+
+	count := 0
+	store.RangeEntities(func(*EntityReference) bool {
+		count++
+		return true
+	})
+	if count != 3 {
+		t.Errorf("Expected to visit 3 entities, visited %d", count)
+	}
+
+	count = 0
+	store.RangeAssertionsForEntity("E1002", func(*kmac.Assertion) bool {
+		count++
+		return false
+	})
+	if count != 1 {
+		t.Errorf("Expected iteration to stop after the first assertion, visited %d", count)
+	}
+}
+
 func BenchmarkSemanticStore(b *testing.B) {
 	store := NewSemanticStore()
 
@@ -111,4 +312,3204 @@ func BenchmarkSemanticStore(b *testing.B) {
 			store.GetEntity("E500")
 		}
 	})
-}
\ No newline at end of file
+}
+
+func BenchmarkIndexedLookups(b *testing.B) {
+	const entityCount = 10000
+	const assertionCount = 1000000
+
+	store := NewSemanticStore()
+	for i := 0; i < entityCount; i++ {
+		store.AddEntity(fmt.Sprintf("E%d", i), fmt.Sprintf("Entity_%d", i), "")
+	}
+	for i := 0; i < assertionCount; i++ {
+		subject := fmt.Sprintf("E%d", i%entityCount)
+		object := fmt.Sprintf("E%d", (i*7+1)%entityCount)
+		relation := fmt.Sprintf("R%d", i%16)
+		store.CreateAssertion(fmt.Sprintf("F%d", i), subject, relation, object)
+	}
+	b.ResetTimer()
+
+	b.Run("FindAssertionsForEntity", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			store.FindAssertionsForEntity("E500")
+		}
+	})
+
+	b.Run("FindRelatedEntities", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			store.FindRelatedEntities("E500")
+		}
+	})
+
+	b.Run("FindAssertionsByObject", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			store.FindAssertionsByObject("E500")
+		}
+	})
+}
+
+// BenchmarkStoreMemory reports the heap retained per entity by a store of a
+// million entities, the allocations made per entity adding them, and the
+// garbage per entity of a pattern query over them
+func BenchmarkStoreMemory(b *testing.B) {
+	const entityCount = 1000000
+	codes := []string{"00B2SO-LAR-SUN:000-000-000-001", "00B2SO-LAR-PLA:000-000-000-003", "10B3TR-AIR-JET"}
+
+	for i := 0; i < b.N; i++ {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+
+		store := NewSemanticStore()
+		for j := 0; j < entityCount; j++ {
+			if err := store.AddEntity(fmt.Sprintf("E%d", j), fmt.Sprintf("Entity_%d", j), codes[j%len(codes)]); err != nil {
+				b.Fatal(err)
+			}
+		}
+
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/entityCount, "heap-bytes/entity")
+		b.ReportMetric(float64(after.Mallocs-before.Mallocs)/entityCount, "allocs/entity")
+
+		// Temporary garbage of a pattern query over every entity
+		runtime.ReadMemStats(&before)
+		store.FindEntitiesByTOSIDPattern("00B*LAR")
+		runtime.ReadMemStats(&after)
+		b.ReportMetric(float64(after.TotalAlloc-before.TotalAlloc)/entityCount, "query-bytes/entity")
+	}
+}
+
+func TestSemanticStoreReification(t *testing.T) {
+	store := NewSemanticStore()
+
+	store.AddEntity("E1001", "Sun", "")
+	store.AddEntity("E1002", "Earth", "")
+	store.AddEntity("E3001", "Flat Earth Society", "")
+
+	store.CreateAssertion("F1001", "E1002", "R1001", "E1001")
+	if err := store.CreateAssertion("F2001", "F1001", "DISPUTED_BY", "E3001"); err != nil {
+		t.Fatalf("Failed to create assertion about an assertion: %v", err)
+	}
+	if err := store.CreateAssertion("F2002", "F9999", "DISPUTED_BY", "E3001"); err == nil {
+		t.Error("Expected error for assertion about a missing assertion")
+	}
+	if err := store.CreateAssertion("F2003", "E1002", "R1001", "F2003"); err == nil {
+		t.Error("Expected error for assertion about itself")
+	}
+
+	about := store.FindAssertionsAbout("F1001")
+	if len(about) != 1 || about[0].ID() != "F2001" {
+		t.Errorf("Expected F2001 to be about F1001, got %v", about)
+	}
+	if results := store.FindAssertionsBySubject("F1001"); len(results) != 1 {
+		t.Errorf("Expected 1 assertion with subject F1001, got %d", len(results))
+	}
+
+	for _, warning := range store.ValidateStore() {
+		if strings.Contains(warning, "F1001") {
+			t.Errorf("Unexpected warning for reified assertion: %s", warning)
+		}
+	}
+}
+
+func TestSemanticStoreStateAssertions(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E3001", "Highway_Status", "")
+
+	t1 := time.Date(2025, 5, 19, 8, 0, 0, 0, time.UTC)
+	t2 := t1.Add(6 * time.Hour)
+	add := func(id string, value string, at time.Time) {
+		state, err := kmac.NewStateAssertion(id, "E3001", "passable", value, at)
+		if err != nil {
+			t.Fatalf("Failed to create state assertion: %v", err)
+		}
+		if err := store.AddStateAssertion(state); err != nil {
+			t.Fatalf("Failed to add state assertion: %v", err)
+		}
+	}
+	// Added out of order to check that history is kept sorted by time
+	add("F3002", "60%", t2)
+	add("F3001", "30%", t1)
+
+	if current, ok := store.CurrentState("E3001", "passable"); !ok || current.Value() != "60%" {
+		t.Errorf("Expected current state 60%%, got %v", current)
+	}
+	if state, ok := store.StateAt("E3001", "passable", t1.Add(time.Hour)); !ok || state.Value() != "30%" {
+		t.Errorf("Expected state 30%% one hour after T1, got %v", state)
+	}
+	if _, ok := store.StateAt("E3001", "passable", t1.Add(-time.Hour)); ok {
+		t.Error("Expected no state before the first observation")
+	}
+
+	history := store.StateHistory("E3001", "passable")
+	if len(history) != 2 || history[0].ID() != "F3001" || history[1].ID() != "F3002" {
+		t.Errorf("Expected history F3001, F3002, got %v", history)
+	}
+
+	// Re-adding an assertion replaces it rather than duplicating it
+	add("F3002", "70%", t2)
+	if history := store.StateHistory("E3001", "passable"); len(history) != 2 || history[1].Value() != "70%" {
+		t.Errorf("Expected replaced state 70%%, got %v", history)
+	}
+
+	state, _ := kmac.NewStateAssertion("F3003", "E9999", "passable", "0%", t1)
+	if err := store.AddStateAssertion(state); err == nil {
+		t.Error("Expected error for state of a missing entity")
+	}
+}
+
+func TestSemanticStoreBranch(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Helicopter", "")
+	store.AddEntity("E2001", "Urban_Center", "")
+	store.CreateAssertion("F1001", "E1001", "R1004", "E2001")
+
+	branch := store.Branch("road-reopens")
+	if err := branch.AddEntity("E1002", "Truck", ""); err != nil {
+		t.Fatalf("Failed to add entity to branch: %v", err)
+	}
+	if err := branch.CreateAssertion("F2001", "E1002", "R1004", "E2001"); err != nil {
+		t.Fatalf("Failed to add assertion to branch: %v", err)
+	}
+	if err := branch.CreateAssertion("F1001", "E1001", "R1005", "E2001"); err != nil {
+		t.Fatalf("Failed to replace assertion in branch: %v", err)
+	}
+
+	// The base store is untouched
+	if _, err := store.GetEntity("E1002"); err == nil {
+		t.Error("Branch entity leaked into the base store")
+	}
+	if assertion, _ := store.GetAssertion("F1001"); assertion.Relation() != "R1004" {
+		t.Errorf("Base assertion changed to %s", assertion)
+	}
+
+	// The branch sees base and hypothetical data together
+	if results := branch.FindAssertionsByObject("E2001"); len(results) != 2 {
+		t.Errorf("Expected 2 assertions in branch, got %d", len(results))
+	}
+	if assertion, _ := branch.GetAssertion("F1001"); assertion.Relation() != "R1005" {
+		t.Errorf("Expected branch to see replaced assertion, got %s", assertion)
+	}
+
+	diff := branch.Diff()
+	if fmt.Sprint(diff.AddedEntities, diff.AddedAssertions, diff.ChangedAssertions) != "[E1002] [F2001] [F1001]" {
+		t.Errorf("Unexpected diff: %+v", diff)
+	}
+
+	if err := branch.Merge(); err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if results := store.FindAssertionsByObject("E2001"); len(results) != 2 {
+		t.Errorf("Expected 2 assertions after merge, got %d", len(results))
+	}
+	if assertion, _ := store.GetAssertion("F1001"); assertion.Relation() != "R1005" {
+		t.Errorf("Expected merged assertion, got %s", assertion)
+	}
+	if !branch.Diff().IsEmpty() {
+		t.Error("Expected empty branch after merge")
+	}
+
+	// Changing the base under a replaced assertion is a conflict
+	conflicting := store.Branch("conflict")
+	conflicting.CreateAssertion("F1001", "E1002", "R1004", "E2001")
+	store.CreateAssertion("F1001", "E1001", "R1006", "E2001")
+	if err := conflicting.Merge(); err == nil {
+		t.Error("Expected merge conflict")
+	}
+	if assertion, _ := store.GetAssertion("F1001"); assertion.Relation() != "R1006" {
+		t.Errorf("Failed merge changed the base: %s", assertion)
+	}
+}
+
+func TestSemanticStoreRetraction(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Highway", "")
+	store.AddEntity("E1002", "Truck", "")
+	store.AddEntity("E2001", "Urban_Center", "")
+
+	store.CreateAssertion("F1001", "E1001", "R1001", "E2001") // Highway reaches the city
+	store.CreateAssertion("F1002", "E1002", "R1002", "E1001") // Truck uses the highway
+	if err := store.CreateDerivedAssertion("F2001", "E1002", "R1003", "E2001", []string{"F1001", "F1002"}); err != nil {
+		t.Fatalf("Failed to create derived assertion: %v", err)
+	}
+	if err := store.CreateDerivedAssertion("F2002", "E1002", "R1004", "E2001", []string{"F2001"}); err != nil {
+		t.Fatalf("Failed to create derived assertion: %v", err)
+	}
+
+	if err := store.Retract("F1001", "bridge collapsed"); err != nil {
+		t.Fatalf("Retract failed: %v", err)
+	}
+	if err := store.Retract("F1001", "again"); err == nil {
+		t.Error("Expected error retracting twice")
+	}
+
+	// Retracted and transitively derived assertions are hidden from queries
+	if _, err := store.GetAssertion("F1001"); err == nil {
+		t.Error("Expected retracted assertion to be hidden")
+	}
+	if results := store.FindAssertionsForEntity("E2001"); len(results) != 0 {
+		t.Errorf("Expected no live assertions for E2001, got %v", results)
+	}
+	if results := store.FindAssertionsForEntity("E1002"); len(results) != 1 || results[0].ID() != "F1002" {
+		t.Errorf("Expected only F1002 for E1002, got %v", results)
+	}
+
+	retraction, ok := store.GetRetraction("F2002")
+	if !ok || retraction.Cause != "F2001" || !strings.Contains(retraction.Reason, "bridge collapsed") {
+		t.Errorf("Unexpected retraction record: %+v", retraction)
+	}
+	if len(store.FindRetractions()) != 3 {
+		t.Errorf("Expected 3 retractions, got %d", len(store.FindRetractions()))
+	}
+	if stats := store.GetStatistics(); stats["assertions"] != 1 || stats["retracted_assertions"] != 3 {
+		t.Errorf("Unexpected statistics: %v", stats)
+	}
+
+	if err := store.CreateDerivedAssertion("F2003", "E1002", "R1003", "E2001", []string{"F1001"}); err == nil {
+		t.Error("Expected error deriving from a retracted premise")
+	}
+
+	// Re-asserting reinstates the assertion
+	store.CreateAssertion("F1001", "E1001", "R1001", "E2001")
+	if _, err := store.GetAssertion("F1001"); err != nil {
+		t.Errorf("Expected reinstated assertion: %v", err)
+	}
+}
+
+func TestSemanticStoreTruthMaintenance(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Sensor_A", "")
+	store.AddEntity("E1002", "Sensor_B", "")
+	store.AddEntity("E2001", "Flood_Zone", "")
+
+	store.CreateAssertion("F1001", "E1001", "R1001", "E2001")
+	store.CreateAssertion("F1002", "E1002", "R1001", "E2001")
+	store.SetAssertionConfidence("F1001", 0.9, "SENSOR")
+	store.SetAssertionConfidence("F1002", 0.8, "SENSOR")
+	store.CreateDerivedAssertion("F2001", "E2001", "R1002", "E1001", []string{"F1001", "F1002"})
+	store.CreateDerivedAssertion("F2002", "E2001", "R1003", "E1002", []string{"F2001"})
+
+	derived, err := store.GetAssertion("F2002")
+	if err != nil {
+		t.Fatalf("Failed to get derived assertion: %v", err)
+	}
+	if level, source := derived.GetConfidence(); math.Abs(level-0.72) > 1e-9 || source != "DERIVED" {
+		t.Errorf("Expected derived confidence 0.72, got %v from %s", level, source)
+	}
+
+	store.SetConfidenceThreshold(0.5)
+	if _, err := store.GetAssertion("F2002"); err != nil {
+		t.Errorf("Expected F2002 to stay above the threshold: %v", err)
+	}
+
+	// A premise losing confidence drags its derived assertions below the threshold
+	store.SetAssertionConfidence("F1002", 0.4, "SENSOR")
+	for _, id := range []string{"F2001", "F2002"} {
+		if _, err := store.GetAssertion(id); err == nil {
+			t.Errorf("Expected %s to be retracted", id)
+		}
+		if retraction, ok := store.GetRetraction(id); !ok || !retraction.LowConfidence {
+			t.Errorf("Expected low-confidence retraction for %s, got %+v", id, retraction)
+		}
+	}
+
+	// Recovering confidence reinstates them with recomputed levels
+	store.SetAssertionConfidence("F1002", 1.0, "SENSOR")
+	derived, err = store.GetAssertion("F2002")
+	if err != nil {
+		t.Fatalf("Expected F2002 to be reinstated: %v", err)
+	}
+	if level, _ := derived.GetConfidence(); math.Abs(level-0.9) > 1e-9 {
+		t.Errorf("Expected recomputed confidence 0.9, got %v", level)
+	}
+
+	// Retracting a premise is final even if confidence would allow the assertion
+	store.Retract("F1001", "sensor faulty")
+	store.SetConfidenceThreshold(0)
+	if _, err := store.GetAssertion("F2002"); err == nil {
+		t.Error("Expected F2002 to stay retracted after its premise was retracted")
+	}
+}
+
+func TestSemanticStoreNamingPolicy(t *testing.T) {
+	policy, err := LoadNamingPolicy(strings.NewReader(`{
+		"forbidden_characters": " /",
+		"rules": [
+			{"pattern": "10B", "label_pattern": "^[A-Z][A-Za-z0-9_]*$", "unique_labels": true}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("Failed to load naming policy: %v", err)
+	}
+
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "apollo 11", "10B3TR-SPC-CRF")
+	store.AddEntity("E1002", "Saturn_V", "10B3TR-SPC-RKT")
+	store.AddEntity("E1003", "Saturn_V", "10B3TR-SPC-RKT")
+
+	report, err := store.CheckNaming(policy)
+	if err != nil {
+		t.Fatalf("CheckNaming failed: %v", err)
+	}
+	want := []string{
+		`entity E1001: label "apollo 11" contains forbidden character ' '`,
+		`entity E1001: label "apollo 11" does not match ^[A-Z][A-Za-z0-9_]*$`,
+		`entity E1003: label "Saturn_V" is already used by E1002 under 10B`,
+	}
+	if strings.Join(report, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected naming report:\n%s", strings.Join(report, "\n"))
+	}
+
+	store = NewSemanticStore()
+	if err := store.SetNamingPolicy(policy); err != nil {
+		t.Fatalf("Failed to set naming policy: %v", err)
+	}
+	if err := store.AddEntity("E1002", "Saturn_V", "10B3TR-SPC-RKT"); err != nil {
+		t.Fatalf("Failed to add entity: %v", err)
+	}
+	if err := store.AddEntity("E1002", "Saturn_V", "10B3TR-SPC-RKT"); err != nil {
+		t.Errorf("Re-adding an entity should not conflict with itself: %v", err)
+	}
+	if err := store.AddEntity("E1003", "Saturn_V", "10B3TR-SPC-RKT"); err == nil {
+		t.Error("Expected duplicate label to be rejected")
+	}
+	if err := store.AddEntity("E1004", "Saturn_V", "10C1OR-GOV-USA"); err != nil {
+		t.Errorf("Labels outside the rule's pattern may repeat: %v", err)
+	}
+	if err := store.AddEntity("E1005", "NASA/JPL", ""); err == nil {
+		t.Error("Expected forbidden character to be rejected")
+	}
+	if _, err := store.GetEntity("E1003"); err == nil {
+		t.Error("Rejected entity should not be stored")
+	}
+
+	branch := store.Branch("rename")
+	if err := branch.AddEntity("E1006", "Saturn_V", "10B3TR-SPC-RKT"); err == nil {
+		t.Error("Expected branch to enforce the base naming policy")
+	}
+	if err := branch.AddEntity("E1006", "Saturn_IB", "10B3TR-SPC-RKT"); err != nil {
+		t.Fatalf("Failed to add branch entity: %v", err)
+	}
+	store.AddEntity("E1007", "Saturn_IB", "10B3TR-SPC-RKT")
+	if err := branch.Merge(); err == nil {
+		t.Error("Expected merge to fail on a label added to the base since branching")
+	}
+
+	if _, err := LoadNamingPolicy(strings.NewReader(`{"rules": [{"label_pattern": "("}]}`)); err == nil {
+		t.Error("Expected invalid label pattern to be rejected")
+	}
+}
+
+func TestSemanticStoreLoadKMAC(t *testing.T) {
+	input := `ASSERT #F1001 subject=[#E1001] relation=[#R1001] object=[#E1002]
+CONFIDENCE #F1001 level=[0.9] source=[HISTORICAL_RECORD]
+DEF_ENTITY #E1001 [NASA] type=[10C1OR-GOV-USA]
+PROPERTY #E1001 [founded] value=[1958]
+DEF_ENTITY #E1002 [Apollo_11] type=[10B3TR-SPC-CRF]
+DEF_RELATION #R1001 [OPERATES] type=[ORGANIZATIONAL]
+`
+	store := NewSemanticStore()
+	if err := store.LoadKMAC(strings.NewReader(input)); err != nil {
+		t.Fatalf("LoadKMAC failed: %v", err)
+	}
+
+	nasa, err := store.GetEntity("E1001")
+	if err != nil {
+		t.Fatalf("Failed to get entity: %v", err)
+	}
+	if founded, _ := nasa.KMACEntity.GetProperty("founded"); founded != "1958" || nasa.TOSIDObj == nil {
+		t.Errorf("Unexpected loaded entity: %s %v", nasa.KMACEntity, nasa.TOSIDObj)
+	}
+	if _, err := store.GetRelation("R1001"); err != nil {
+		t.Errorf("Failed to get relation: %v", err)
+	}
+	assertion, err := store.GetAssertion("F1001")
+	if err != nil {
+		t.Fatalf("Failed to get assertion: %v", err)
+	}
+	if level, source := assertion.GetConfidence(); level != 0.9 || source != "HISTORICAL_RECORD" {
+		t.Errorf("Expected confidence 0.9 from HISTORICAL_RECORD, got %v from %s", level, source)
+	}
+
+	if err := NewSemanticStore().LoadKMAC(strings.NewReader("ASSERT #F1001 subject=[#E9] relation=[#R1] object=[#E8]\n")); err == nil {
+		t.Error("Expected dangling assertion to be rejected")
+	}
+}
+
+func TestSemanticStoreDescribeAssertion(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Water_Purifier", "")
+	store.AddEntity("E1002", "Drinking_Water_Need", "")
+	store.AddRelation("R1002", "PROVIDES", "RESOURCE_CAPABILITY")
+	store.CreateAssertion("F1001", "E1001", "R1002", "E1002")
+	store.SetAssertionConfidence("F1001", 0.8, "FIELD_REPORT")
+
+	description, err := store.DescribeAssertion("F1001")
+	if err != nil {
+		t.Fatalf("DescribeAssertion failed: %v", err)
+	}
+	if description != "Water Purifier provides Drinking Water Need (confidence 0.8, field report)" {
+		t.Errorf("Unexpected description: %s", description)
+	}
+
+	store.Retract("F1001", "superseded")
+	if _, err := store.DescribeAssertion("F1001"); err == nil {
+		t.Error("Expected retracted assertion not to be described")
+	}
+}
+
+func TestSemanticStoreFindSimilarEntities(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Water_Purifier", "10B3WA-TER-PUR")
+	store.AddEntity("E1002", "Water_Filter", "10B3WA-TER-FLT")
+	store.AddEntity("E1003", "Water_Tanker", "10B3TR-GND-TNK")
+	store.AddEntity("E1004", "Antibiotics", "10C5ME-DSU-PAN")
+
+	vectors := map[string][]float32{
+		"E1001": {1, 0, 0},
+		"E1002": {0.9, 0.1, 0},
+		"E1003": {0.6, 0.6, 0},
+		"E1004": {0, 0, 1},
+	}
+	for id, vector := range vectors {
+		if err := store.SetEntityVector(id, vector); err != nil {
+			t.Fatalf("Failed to set vector: %v", err)
+		}
+	}
+
+	similar, err := store.FindSimilarEntities("E1001", 2)
+	if err != nil {
+		t.Fatalf("FindSimilarEntities failed: %v", err)
+	}
+	if len(similar) != 2 || similar[0].Entity.KMACEntity.ID() != "E1002" || similar[1].Entity.KMACEntity.ID() != "E1003" {
+		t.Errorf("Unexpected similar entities: %v", similar)
+	}
+	if math.Abs(similar[0].Similarity-0.9/math.Sqrt(0.82)) > 1e-6 {
+		t.Errorf("Unexpected similarity %v", similar[0].Similarity)
+	}
+
+	similar, _ = store.FindSimilarEntitiesByTOSIDPattern("E1001", 5, "10B-3TR")
+	if len(similar) != 1 || similar[0].Entity.KMACEntity.ID() != "E1003" {
+		t.Errorf("Expected only the tanker under 10B-3TR, got %v", similar)
+	}
+
+	if err := store.SetEntityVector("E1001", []float32{1, 0}); err == nil {
+		t.Error("Expected dimension mismatch to be rejected")
+	}
+	if err := store.SetEntityVector("E1001", []float32{0, 0, 0}); err == nil {
+		t.Error("Expected zero vector to be rejected")
+	}
+	if err := store.SetEntityVector("E9999", []float32{1, 0, 0}); err == nil {
+		t.Error("Expected unknown entity to be rejected")
+	}
+	if _, err := store.FindSimilarEntities("E9999", 1); err == nil {
+		t.Error("Expected entity without vector to be rejected")
+	}
+
+	vector, _ := store.EntityVector("E1003")
+	vector[0] = 42
+	if stored, _ := store.EntityVector("E1003"); stored[0] != 0.6 {
+		t.Error("EntityVector should return a copy")
+	}
+}
+
+func TestSemanticStoreVectorIndexRecall(t *testing.T) {
+	const n, dims, k = 600, 16, 10
+	rng := rand.New(rand.NewSource(7))
+
+	store := NewSemanticStore()
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("E%d", 1000+i)
+		store.AddEntity(id, id, "")
+		vector := make([]float32, dims)
+		for d := range vector {
+			vector[d] = float32(rng.NormFloat64())
+		}
+		// Index half the vectors at build time and half incrementally
+		if i == n/2 {
+			store.EnableVectorIndex(HNSWOptions{M: 8, EfConstruction: 64, Seed: 1})
+		}
+		if err := store.SetEntityVector(id, vector); err != nil {
+			t.Fatalf("Failed to set vector: %v", err)
+		}
+	}
+
+	queries := []string{"E1000", "E1100", "E1250", "E1400", "E1599"}
+	found, total := 0, 0
+	for _, id := range queries {
+		approximate, _ := store.FindSimilarEntities(id, k)
+		store.DisableVectorIndex()
+		exact, _ := store.FindSimilarEntities(id, k)
+		store.EnableVectorIndex(HNSWOptions{M: 8, EfConstruction: 64, Seed: 1})
+
+		want := make(map[string]bool)
+		for _, e := range exact {
+			want[e.Entity.KMACEntity.ID()] = true
+		}
+		for _, a := range approximate {
+			if want[a.Entity.KMACEntity.ID()] {
+				found++
+			}
+		}
+		total += len(exact)
+	}
+	if recall := float64(found) / float64(total); recall < 0.9 {
+		t.Errorf("Expected recall of at least 0.9, got %.2f", recall)
+	}
+
+	// Replacing a vector must drop the old one from indexed results
+	store.SetEntityVector("E1000", []float32{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	similar, _ := store.FindEntitiesNearVector([]float32{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, n, "")
+	seen := 0
+	for _, s := range similar {
+		if s.Entity.KMACEntity.ID() == "E1000" {
+			seen++
+		}
+	}
+	if seen != 1 || similar[0].Entity.KMACEntity.ID() != "E1000" {
+		t.Errorf("Expected the replaced vector exactly once and first, saw it %d times", seen)
+	}
+}
+
+func TestSemanticStoreVectorIndexRemoval(t *testing.T) {
+	store := NewSemanticStore()
+	store.EnableVectorIndex(HNSWOptions{Seed: 1})
+	store.AddEntity("E1001", "Tanker", "")
+	store.AddEntity("E1002", "Tanker", "")
+	store.AddEntity("E1003", "Barge", "")
+	store.SetEntityVector("E1001", []float32{1, 0})
+	store.SetEntityVector("E1002", []float32{0.9, 0.1})
+	store.SetEntityVector("E1003", []float32{0, 1})
+
+	// Re-added without a vector, an entity must not be found by its old one
+	store.RemoveEntity("E1002", "scrapped")
+	store.AddEntity("E1002", "Tanker", "")
+	indexed, _ := store.FindSimilarEntities("E1001", 5)
+	store.DisableVectorIndex()
+	exact, _ := store.FindSimilarEntities("E1001", 5)
+	if len(indexed) != 1 || len(exact) != 1 || indexed[0].Entity.KMACEntity.ID() != "E1003" {
+		t.Errorf("Expected only E1003 found with and without the index, got %v and %v", indexed, exact)
+	}
+
+	// Churn leaves no more deleted nodes in the graph than live ones
+	store.EnableVectorIndex(HNSWOptions{Seed: 1})
+	store.SetLimits(&StoreLimits{MaxEntities: 10})
+	for i := 0; i < 200; i++ {
+		id := fmt.Sprintf("E2%03d", i)
+		store.AddEntity(id, "Reading", "")
+		store.SetEntityVector(id, []float32{float32(i), 1})
+	}
+	if live, nodes := len(store.vectorIndex.byID), len(store.vectorIndex.nodes); live != 10 || nodes > 2*live+1 {
+		t.Errorf("Expected evicted vectors dropped from the index, got %d live of %d nodes", live, nodes)
+	}
+}
+
+func TestSemanticStoreTemporalQueries(t *testing.T) {
+	input := `DEF_ENTITY #E1001 [Depot] type=[10C1OR-GOV-USA]
+DEF_ENTITY #E1002 [Field_Hospital] type=[10C5ME-DSU-PAN]
+DEF_RELATION #R1001 [RESUPPLIES] type=[LOGISTICS_CAPABILITY]
+ASSERT #F1001 subject=[#E1001] relation=[#R1001] object=[#E1002]
+TEMPORAL #F1001 state=[DURING] timestamp=[#T2001]
+ASSERT #F1002 subject=[#E1002] relation=[#R1001] object=[#E1001]
+TEMPORAL #F1002 state=[BEGAN_AT] timestamp=[2024-01-03T00:00:00Z]
+ASSERT #F1003 subject=[#E1001] relation=[#R1001] object=[#E1001]
+DEF_TIME #T2001 type=[RESUPPLY_WINDOW] value=[2024-01-01T08:00:00Z] duration=[2h0m0s] rrule=[FREQ=WEEKLY;BYDAY=MO]
+`
+	store := NewSemanticStore()
+	if err := store.LoadKMAC(strings.NewReader(input)); err != nil {
+		t.Fatalf("LoadKMAC failed: %v", err)
+	}
+
+	holding := func(at time.Time) string {
+		var ids []string
+		for _, assertion := range store.FindAssertionsHoldingAt(at) {
+			ids = append(ids, assertion.ID())
+		}
+		return strings.Join(ids, " ")
+	}
+	if got := holding(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)); got != "F1001" {
+		t.Errorf("Expected only the resupply on Monday morning, got %q", got)
+	}
+	if got := holding(time.Date(2024, 1, 4, 9, 0, 0, 0, time.UTC)); got != "F1002" {
+		t.Errorf("Expected only the began-at assertion on Thursday, got %q", got)
+	}
+	if got := holding(time.Date(2024, 1, 8, 8, 30, 0, 0, time.UTC)); got != "F1001 F1002" {
+		t.Errorf("Expected both assertions the next Monday, got %q", got)
+	}
+
+	if refs := store.FindTimeReferencesAt(time.Date(2024, 1, 15, 8, 0, 0, 0, time.UTC)); len(refs) != 1 || refs[0].ID() != "T2001" {
+		t.Errorf("Expected the resupply window to be open, got %v", refs)
+	}
+	if temporal, ok := store.Temporal("F1001"); !ok || temporal.State() != "DURING" {
+		t.Errorf("Expected F1001 to be qualified DURING, got %v", temporal)
+	}
+	if err := store.LoadKMAC(strings.NewReader("TEMPORAL #F9999 state=[DURING] timestamp=[#T2001]\n")); err == nil {
+		t.Error("Expected temporal qualification of a missing assertion to be rejected")
+	}
+}
+
+func TestSemanticStoreCleanup(t *testing.T) {
+	build := func() *SemanticStore {
+		store := NewSemanticStore()
+		store.AddEntity("E1001", "Highway", "")
+		store.AddEntity("E1002", "Truck", "")
+		store.AddEntity("E1003", "Depot", "")
+		store.AddEntity("E1004", "Bridge", "")
+		store.CreateAssertion("F1001", "E1002", "R1001", "E1001")
+		store.CreateAssertion("F1002", "E1004", "R1002", "E1001")
+		// Dangling references arise from imports and merges that bypass checks
+		store.assertions.put("F1003", "E1004", "R1003", "E9999")
+		store.CreateAssertion("F1004", "F1003", "R1004", "E1002")
+		store.CreateDerivedAssertion("F2001", "E1002", "R1005", "E1001", []string{"F1003"})
+		return store
+	}
+
+	store := build()
+	report, err := store.Cleanup(CleanupPolicy{Dangling: CleanupRemove, PruneOrphans: true, DryRun: true})
+	if err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	if strings.Join(report.Removed, " ") != "F1003 F1004" || strings.Join(report.PrunedEntities, " ") != "E1003" {
+		t.Errorf("Unexpected dry run report: %+v", report)
+	}
+	if lines := report.Lines(); len(lines) != 5 || lines[0] != "assertion F1003 references non-existent E9999" || lines[4] != "entity E1003 would be pruned" {
+		t.Errorf("Unexpected report lines: %v", lines)
+	}
+	if len(store.ValidateStore()) == 0 || store.GetStatistics()["entities"] != 4 {
+		t.Error("Expected a dry run to change nothing")
+	}
+
+	if _, err := store.Cleanup(CleanupPolicy{Dangling: CleanupRemove, PruneOrphans: true}); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	if warnings := store.ValidateStore(); len(warnings) != 0 {
+		t.Errorf("Expected a clean store, got %v", warnings)
+	}
+	if _, err := store.GetEntity("E1003"); err == nil {
+		t.Error("Expected orphan E1003 to be pruned")
+	}
+	if _, exists := store.assertions.row("F1003"); exists {
+		t.Error("Expected F1003 to be removed")
+	}
+	if retraction, ok := store.GetRetraction("F2001"); !ok || retraction.Cause != "F1003" {
+		t.Errorf("Expected F2001 to be retracted with its removed premise, got %+v", retraction)
+	}
+	if results := store.FindAssertionsForEntity("E1001"); len(results) != 2 || results[0].ID() != "F1001" || results[1].ID() != "F1002" {
+		t.Errorf("Expected indexes to survive removal, got %v", results)
+	}
+
+	store = build()
+	report, err = store.Cleanup(CleanupPolicy{Dangling: CleanupQuarantine})
+	if err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	if strings.Join(report.Quarantined, " ") != "F1003" {
+		t.Errorf("Expected only F1003 quarantined, got %v", report.Quarantined)
+	}
+	if retraction, ok := store.GetRetraction("F1003"); !ok || !strings.Contains(retraction.Reason, "quarantined") {
+		t.Errorf("Expected F1003 to be quarantined, got %+v", retraction)
+	}
+	if _, err := store.GetAssertion("F1004"); err != nil {
+		t.Errorf("Expected F1004 to stay, since its subject is kept for provenance: %v", err)
+	}
+
+	if _, err := store.Cleanup(CleanupPolicy{Dangling: "SHRED"}); err == nil {
+		t.Error("Expected error for unknown action")
+	}
+}
+
+func TestSemanticStoreIntegrityModes(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Highway", "")
+	store.AddEntity("E1002", "Truck", "")
+	if store.IntegrityMode() != IntegrityLax {
+		t.Errorf("Expected lax mode by default, got %s", store.IntegrityMode())
+	}
+	if err := store.CreateAssertion("F1001", "E1002", "R1001", "E1001"); err != nil {
+		t.Errorf("Expected lax mode to allow an undefined relation: %v", err)
+	}
+	if err := store.CreateAssertion("F1002", "E1002", "R1001", "E9999"); err == nil {
+		t.Error("Expected lax mode to reject an unknown object")
+	}
+
+	store.SetIntegrityMode(IntegrityStrict)
+	if err := store.CreateAssertion("F1003", "E1002", "R1001", "E1001"); err == nil {
+		t.Error("Expected strict mode to reject an undefined relation")
+	}
+	store.AddRelation("R1001", "uses", "FUNCTIONAL")
+	if err := store.CreateAssertion("F1003", "E1002", "R1001", "E1001"); err != nil {
+		t.Errorf("Expected strict mode to accept a defined relation: %v", err)
+	}
+	if err := store.CreateAssertion("F1004", "E1002", "INSTANCE_OF", "E1001"); err != nil {
+		t.Errorf("Expected strict mode to accept a built-in relation: %v", err)
+	}
+
+	// Bulk imports may deliver assertions before their entities
+	if err := store.SetIntegrityMode(IntegrityDeferred); err != nil {
+		t.Fatalf("SetIntegrityMode failed: %v", err)
+	}
+	if err := store.CreateAssertion("F2001", "E3001", "R1001", "E3002"); err != nil {
+		t.Fatalf("Expected deferred mode to accept unknown references: %v", err)
+	}
+	if err := store.CreateAssertion("F2002", "F2003", "R1001", "E3001"); err != nil {
+		t.Fatalf("Expected deferred mode to accept a forward assertion reference: %v", err)
+	}
+	pending := store.PendingReferences()
+	if len(pending) != 3 || pending[0].ID != "E3001" || strings.Join(pending[0].AssertionIDs, " ") != "F2001 F2002" {
+		t.Errorf("Unexpected pending references: %+v", pending)
+	}
+	if err := store.SetIntegrityMode(IntegrityLax); err == nil {
+		t.Error("Expected error leaving deferred mode with pending references")
+	}
+
+	store.AddEntity("E3001", "Depot", "")
+	store.AddEntity("E3002", "Port", "")
+	store.CreateAssertion("F2003", "E3001", "R1001", "E3002")
+	if pending := store.PendingReferences(); len(pending) != 0 {
+		t.Errorf("Expected all references resolved, got %+v", pending)
+	}
+	if warnings := store.ValidateStore(); len(warnings) != 0 {
+		t.Errorf("Expected a consistent store, got %v", warnings)
+	}
+	if err := store.SetIntegrityMode(IntegrityStrict); err != nil {
+		t.Errorf("Expected to leave deferred mode once resolved: %v", err)
+	}
+	if err := store.SetIntegrityMode("PARANOID"); err == nil {
+		t.Error("Expected error for unknown mode")
+	}
+}
+
+func TestSemanticStoreTags(t *testing.T) {
+	store := NewSemanticStore()
+	input := `DEF_ENTITY #E1001 [Highway] type=[]
+DEF_ENTITY #E1002 [Truck] type=[]
+TAG #E1002 [unverified]
+DEF_RELATION #R1001 [uses] type=[FUNCTIONAL]
+ASSERT #F1001 subject=[#E1002] relation=[#R1001] object=[#E1001]
+TAG #F1001 [unverified]
+ANNOTATE #F1001 [reviewer] value=[alice]
+NOTE #F1001 [Seen once on camera]
+ASSERT #F1002 subject=[#E1001] relation=[#R1001] object=[#E1002]
+`
+	if err := store.LoadKMAC(strings.NewReader(input)); err != nil {
+		t.Fatalf("LoadKMAC failed: %v", err)
+	}
+
+	if found := store.FindByTag("unverified"); strings.Join(found, " ") != "E1002 F1001" {
+		t.Errorf("Expected E1002 and F1001 to be unverified, got %v", found)
+	}
+	assertion, err := store.GetAssertion("F1001")
+	if err != nil {
+		t.Fatalf("GetAssertion failed: %v", err)
+	}
+	if reviewer, _ := assertion.Annotation("reviewer"); reviewer != "alice" || len(assertion.Notes()) != 1 {
+		t.Errorf("Expected loaded metadata on the assertion, got %q %v", reviewer, assertion.Notes())
+	}
+
+	if err := store.Tag("F1002", "unverified", "disputed"); err != nil {
+		t.Fatalf("Tag failed: %v", err)
+	}
+	if err := store.Untag("F1001", "unverified"); err != nil {
+		t.Fatalf("Untag failed: %v", err)
+	}
+	if found := store.FindByTag("unverified"); strings.Join(found, " ") != "E1002 F1002" {
+		t.Errorf("Expected E1002 and F1002 to be unverified, got %v", found)
+	}
+	store.Retract("F1002", "wrong camera")
+	if found := store.FindByTag("disputed"); len(found) != 0 {
+		t.Errorf("Expected retracted assertions to be hidden, got %v", found)
+	}
+
+	store.Annotate("R1001", "source", "ontology v2")
+	meta, err := store.GetMetadata("R1001")
+	if err != nil {
+		t.Fatalf("GetMetadata failed: %v", err)
+	}
+	if source, _ := meta.Annotation("source"); source != "ontology v2" {
+		t.Errorf("Expected relation annotation, got %q", source)
+	}
+	if err := store.AddNote("E9999", "missing"); err == nil {
+		t.Error("Expected error for unknown statement")
+	}
+}
+
+func TestSemanticStoreRemoveAndCompact(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Highway", "")
+	store.AddEntity("E1002", "Truck", "")
+	store.AddEntity("E1003", "Depot", "")
+	store.AddRelation("R1001", "uses", "FUNCTIONAL")
+	store.CreateAssertion("F1001", "E1002", "R1001", "E1001")
+	store.CreateAssertion("F1002", "E1002", "R1001", "E1003")
+	store.CreateAssertion("F1003", "F1001", "R1001", "E1003")
+	store.CreateDerivedAssertion("F1004", "E1003", "R1001", "E1002", []string{"F1001"})
+
+	if err := store.RemoveEntity("E1001", "duplicate"); err != nil {
+		t.Fatalf("RemoveEntity failed: %v", err)
+	}
+	if _, err := store.GetEntity("E1001"); err == nil {
+		t.Error("Expected removed entity to be hidden")
+	}
+	if _, err := store.GetAssertion("F1001"); err == nil || !strings.Contains(err.Error(), "removed") {
+		t.Errorf("Expected assertions about the entity to be removed, got %v", err)
+	}
+	tombstone, ok := store.GetTombstone("F1003")
+	if !ok || tombstone.Cause != "F1001" || tombstone.Kind != "ASSERT" {
+		t.Errorf("Expected F1003 removed because of F1001, got %+v", tombstone)
+	}
+	if retraction, ok := store.GetRetraction("F1004"); !ok || retraction.Cause != "F1001" {
+		t.Errorf("Expected derived assertion to be retracted, got %+v", retraction)
+	}
+	if got := len(store.FindAssertionsForEntity("E1002")); got != 1 {
+		t.Errorf("Expected 1 remaining assertion for E1002, got %d", got)
+	}
+	if err := store.CreateAssertion("F1005", "F1001", "R1001", "E1002"); err == nil {
+		t.Error("Expected error referencing a removed assertion")
+	}
+	if err := store.RemoveEntity("E1001", "again"); err == nil {
+		t.Error("Expected error removing an entity twice")
+	}
+
+	if err := store.RemoveRelation("R1001", "renamed"); err != nil {
+		t.Fatalf("RemoveRelation failed: %v", err)
+	}
+	if _, err := store.GetAssertion("F1002"); err != nil {
+		t.Errorf("Expected assertions to survive relation removal: %v", err)
+	}
+	if err := store.RemoveAssertion("F1002", "wrong"); err != nil {
+		t.Fatalf("RemoveAssertion failed: %v", err)
+	}
+
+	var ids []string
+	for _, tombstone := range store.Tombstones() {
+		ids = append(ids, tombstone.ID)
+	}
+	if strings.Join(ids, " ") != "E1001 F1001 F1002 F1003 R1001" {
+		t.Errorf("Unexpected tombstones: %v", ids)
+	}
+	if stats := store.GetStatistics(); stats["assertions"] != 0 || stats["tombstones"] != 5 {
+		t.Errorf("Unexpected statistics: %v", stats)
+	}
+
+	compacted := store.Compact()
+	if strings.Join(compacted, " ") != "E1001 F1001 F1002 F1003 R1001" {
+		t.Errorf("Unexpected compacted IDs: %v", compacted)
+	}
+	if len(store.Tombstones()) != 0 {
+		t.Error("Expected tombstones to be dropped")
+	}
+	if _, err := store.GetAssertion("F1001"); err == nil || strings.Contains(err.Error(), "removed") {
+		t.Errorf("Expected compacted assertion to be gone, got %v", err)
+	}
+	if stats := store.GetStatistics(); stats["assertions"] != 0 || stats["retracted_assertions"] != 1 {
+		t.Errorf("Unexpected statistics after compaction: %v", stats)
+	}
+
+	// Removed IDs may be reused
+	if err := store.AddEntity("E1001", "Motorway", ""); err != nil {
+		t.Fatalf("AddEntity failed: %v", err)
+	}
+	store.RemoveEntity("E1003", "closed")
+	store.AddEntity("E1003", "New depot", "")
+	if _, removed := store.GetTombstone("E1003"); removed {
+		t.Error("Expected re-adding an entity to clear its tombstone")
+	}
+}
+
+func TestSemanticStoreInternedTOSIDs(t *testing.T) {
+	store := NewSemanticStore()
+	store.SetLimits(&StoreLimits{MaxEntities: 10})
+	for i := 0; i < 100; i++ {
+		store.AddEntity(fmt.Sprintf("E%d", 1000+i), "Reading", fmt.Sprintf("10B3TR-AIR-JET:000-000-000-%03d", i))
+	}
+	if count := store.tosids.Count(); count != 10 {
+		t.Errorf("Expected only the TOSIDs of the 10 entities held to stay interned, got %d", count)
+	}
+
+	// Replaced, removed, and compacted entities release their TOSIDs
+	store.SetLimits(nil)
+	store.AddEntity("E1099", "Reading", "10B3TR-AIR-JET:000-000-000-000")
+	store.RemoveEntity("E1098", "decommissioned")
+	if count := store.tosids.Count(); count != 10 {
+		t.Errorf("Expected a removed entity to hold its TOSID until compaction, got %d", count)
+	}
+	store.Compact()
+	if count := store.tosids.Count(); count != 9 {
+		t.Errorf("Expected replaced and compacted entities to release their TOSIDs, got %d", count)
+	}
+	store.Clear()
+	if count := store.tosids.Count(); count != 0 {
+		t.Errorf("Expected a cleared store to hold no TOSIDs, got %d", count)
+	}
+}
+
+func TestSemanticStoreClearResets(t *testing.T) {
+	store := NewSemanticStore()
+	store.EnableChangeFeed(0)
+	store.SetLimits(&StoreLimits{MaxEntities: 1})
+	store.AddEntity("E1001", "Sensor", "")
+	store.AddEntity("E1002", "Reading", "")
+	cursor := store.ChangeCursor()
+
+	store.Clear()
+	if stats := store.GetStatistics(); stats["evicted_entities"] != 0 || stats["evicted_assertions"] != 0 {
+		t.Errorf("Expected a cleared store to have evicted nothing, got %v", stats)
+	}
+	if _, _, err := store.ChangesSince(cursor, 0); !errors.Is(err, ErrCursorExpired) {
+		t.Errorf("Expected cursors from before the clear to expire, got %v", err)
+	}
+	if changes, _, err := store.ChangesSince("", 0); err != nil || len(changes) != 1 || changes[0].Op != walClear {
+		t.Errorf("Expected the restarted feed to hold only the clear, got %v %v", changes, err)
+	}
+}
+
+func TestSemanticStoreAssertionCopies(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Sensor", "")
+	store.AddEntity("E1002", "Reading", "")
+	store.CreateAssertion("F1001", "E1001", "R1001", "E1002")
+
+	// Changes to an assertion read from the store are written back to it
+	store.EnableChangeFeed(0)
+	assertion, _ := store.GetAssertion("F1001")
+	assertion.SetConfidence(0.3, "OPERATOR")
+	assertion.AddTag("checked")
+	assertion.Annotate("reviewer", "ops")
+	assertion.AddNote("seen twice")
+	found := store.FindAssertionsBySubject("E1001")
+	if len(found) != 1 || found[0] == assertion || !found[0].HasTag("checked") || len(found[0].Notes()) != 1 {
+		t.Fatalf("Expected the changes read back from a fresh assertion, got %v", found)
+	}
+	if level, source := found[0].GetConfidence(); level != 0.3 || source != "OPERATOR" {
+		t.Errorf("Expected the stored confidence, got %v %s", level, source)
+	}
+	if meta, _ := store.GetMetadata("F1001"); !meta.HasTag("checked") {
+		t.Error("Expected the tag stored as metadata")
+	}
+	if changes, _, _ := store.ChangesSince("", 0); len(changes) != 4 {
+		t.Errorf("Expected each change journaled as the store's own mutation would be, got %d", len(changes))
+	}
+
+	// Removing a tag through a later handle removes it from the store
+	found[0].RemoveTag("checked")
+	if meta, _ := store.GetMetadata("F1001"); meta.HasTag("checked") {
+		t.Error("Expected the removed tag gone from the store")
+	}
+
+	// A handle on a removed assertion writes nothing
+	store.RemoveAssertion("F1001", "duplicate")
+	assertion.SetConfidence(0.9, "OPERATOR")
+	if _, err := store.GetAssertion("F1001"); err == nil {
+		t.Error("Expected the removed assertion to stay removed")
+	}
+}
+
+func TestSemanticStoreLimits(t *testing.T) {
+	store := NewSemanticStore()
+	if err := store.SetLimits(&StoreLimits{MaxEntities: 3}); err != nil {
+		t.Fatalf("SetLimits failed: %v", err)
+	}
+	for i, label := range []string{"Alpha", "Bravo", "Charlie"} {
+		store.AddEntity(fmt.Sprintf("E100%d", i+1), label, "")
+	}
+	store.CreateAssertion("F1001", "E1001", "R1001", "E1002")
+	store.GetEntity("E1001")
+	store.GetEntity("E1002")
+
+	// E1003 is the least recently used
+	store.AddEntity("E1004", "Delta", "")
+	if _, err := store.GetEntity("E1003"); err == nil {
+		t.Error("Expected E1003 to be evicted")
+	}
+	store.GetEntity("E1001")
+	store.GetEntity("E1004")
+	store.AddEntity("E1005", "Echo", "")
+	if _, err := store.GetEntity("E1002"); err == nil {
+		t.Error("Expected E1002 to be evicted")
+	}
+	if _, err := store.GetAssertion("F1001"); err == nil {
+		t.Error("Expected assertions about an evicted entity to be evicted")
+	}
+	if stats := store.GetStatistics(); stats["entities"] != 3 || stats["evicted_entities"] != 2 || stats["evicted_assertions"] != 1 {
+		t.Errorf("Unexpected statistics: %v", stats)
+	}
+
+	store = NewSemanticStore()
+	store.AddEntity("E1001", "Sensor", "")
+	store.AddEntity("E1002", "Reading", "")
+	for i, level := range []float64{0.9, 0.2, 0.6} {
+		id := fmt.Sprintf("F100%d", i+1)
+		store.CreateAssertion(id, "E1001", "R1001", "E1002")
+		store.SetAssertionConfidence(id, level, "SENSOR")
+	}
+	store.CreateAssertion("F1004", "F1003", "R1002", "E1001")
+	if err := store.SetLimits(&StoreLimits{MaxAssertions: 3, Policy: EvictLowestConfidence}); err != nil {
+		t.Fatalf("SetLimits failed: %v", err)
+	}
+	if _, err := store.GetAssertion("F1002"); err == nil {
+		t.Error("Expected the least confident assertion to be evicted")
+	}
+	store.Retract("F1001", "sensor fault")
+	store.CreateAssertion("F1005", "E1002", "R1001", "E1001")
+	if got := store.GetStatistics()["assertions"]; got != 3 {
+		t.Errorf("Expected the retracted assertion to be evicted first, leaving 3, got %d", got)
+	}
+	store.CreateAssertion("F1006", "E1002", "R1001", "E1001")
+	for _, id := range []string{"F1003", "F1004"} {
+		if _, err := store.GetAssertion(id); err == nil {
+			t.Errorf("Expected %s to be evicted along with the assertion it refers to", id)
+		}
+	}
+
+	if err := store.SetLimits(&StoreLimits{Policy: "RANDOM"}); err == nil {
+		t.Error("Expected error for unknown policy")
+	}
+	if err := store.SetLimits(nil); err != nil || store.Limits() != nil {
+		t.Error("Expected nil to remove the limits")
+	}
+
+	// Reads of a bounded store record accesses from several goroutines at once
+	store = NewSemanticStore()
+	for i := 0; i < 150; i++ {
+		store.AddEntity(fmt.Sprintf("E%d", 2000+i), "Reading", "")
+	}
+	store.SetLimits(&StoreLimits{MaxEntities: 150})
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 149; i >= 0; i-- {
+				store.GetEntity(fmt.Sprintf("E%d", 2000+i))
+			}
+		}()
+	}
+	wg.Wait()
+	store.SetLimits(&StoreLimits{MaxEntities: 100})
+	if _, err := store.GetEntity("E2050"); err != nil {
+		t.Errorf("Expected the most recently read entities to stay, got %v", err)
+	}
+	if _, err := store.GetEntity("E2149"); err == nil {
+		t.Error("Expected the least recently read entities to be evicted")
+	}
+
+	// A stream of assertions at the limit keeps the newest, in order
+	store = NewSemanticStore()
+	store.AddEntity("E1001", "Sensor", "")
+	store.AddEntity("E1002", "Reading", "")
+	store.SetLimits(&StoreLimits{MaxAssertions: 50})
+	for i := 0; i < 175; i++ {
+		store.CreateAssertion(fmt.Sprintf("F%d", 1000+i), "E1001", "R1001", "E1002")
+	}
+	found := store.FindAssertionsByRelation("R1001")
+	if len(found) != 50 || found[0].ID() != "F1125" || found[49].ID() != "F1174" {
+		t.Fatalf("Expected the newest 50 assertions in order, got %d", len(found))
+	}
+	if stats := store.GetStatistics(); stats["assertions"] != 50 || stats["evicted_assertions"] != 125 {
+		t.Errorf("Unexpected statistics: %v", stats)
+	}
+	if statements := store.Statements(); len(statements) != 52 {
+		t.Errorf("Expected evicted assertions left out of the export, got %d statements", len(statements))
+	}
+
+	// Symbols held only by evicted assertions are freed, so under churn the
+	// table holds at most two per row of live assertions and uncompacted holes
+	peak := 0
+	for i := 0; i < 2000; i++ {
+		id := fmt.Sprintf("F%d", 2000+i)
+		store.CreateAssertion(id, "E1001", "R1001", "E1002")
+		store.SetAssertionConfidence(id, 0.5, fmt.Sprintf("SENSOR-%d", i))
+		if n := store.symbols.len(); n > peak {
+			peak = n
+		}
+	}
+	if peak > 2*2*50+4 {
+		t.Errorf("Expected the symbol table to stay flat under churn, peaked at %d symbols", peak)
+	}
+	if _, exists := store.symbols.lookup("SENSOR-0"); exists {
+		t.Error("Expected the source of an evicted assertion to be released")
+	}
+}
+
+func TestSemanticStoreCreateAssertions(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Highway", "")
+	store.AddEntity("E1002", "Truck", "")
+
+	result := store.CreateAssertions([]AssertionSpec{
+		{ID: "F1001", Subject: "E1002", Relation: "R1001", Object: "E1001"},
+		{ID: "F1002", Subject: "E1002", Relation: "R1001", Object: "E9999"},
+		{ID: "F1003", Subject: "F1001", Relation: "R1002", Object: "E1001"},
+		{ID: "F1001", Subject: "E1001", Relation: "R1001", Object: "E1002"},
+		{ID: "X1004", Subject: "E1001", Relation: "R1001", Object: "E1002"},
+	})
+	if result.Processed != 5 || result.Created != 2 || len(result.Errors) != 3 {
+		t.Fatalf("Unexpected result: %+v", result)
+	}
+	for i, index := range []int{1, 3, 4} {
+		if result.Errors[i].Index != index {
+			t.Errorf("Expected error %d for item %d, got item %d: %v", i, index, result.Errors[i].Index, result.Errors[i])
+		}
+	}
+	if err := result.Err(); err == nil || !strings.Contains(err.Error(), "3 of 5 assertions rejected") {
+		t.Errorf("Unexpected summary: %v", err)
+	}
+	if assertion, err := store.GetAssertion("F1001"); err != nil || assertion.Subject() != "E1002" {
+		t.Errorf("Expected the first F1001 to stand, got %v %v", assertion, err)
+	}
+	if about := store.FindAssertionsAbout("F1001"); len(about) != 1 {
+		t.Errorf("Expected an assertion about F1001 from the same batch, got %d", len(about))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := store.CreateAssertionsContext(ctx, []AssertionSpec{{ID: "F2001", Subject: "E1001", Relation: "R1001", Object: "E1002"}})
+	if err == nil || result.Processed != 0 {
+		t.Errorf("Expected a cancelled batch to stop before applying anything, got %+v %v", result, err)
+	}
+	if result := store.CreateAssertions(nil); result.Err() != nil || result.Created != 0 {
+		t.Errorf("Expected an empty batch to succeed, got %+v", result)
+	}
+}
+
+func TestSemanticStoreReadSnapshot(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Truck", "")
+	store.AddEntity("E1002", "Depot", "")
+	store.AddRelation("R1001", "parked_at", "FUNCTIONAL")
+	store.CreateAssertion("F1001", "E1001", "R1001", "E1002")
+	store.Tag("F1001", "unverified")
+	store.RegisterInvariant(Invariant{Name: "meddling", Check: func(s *SemanticStore) []string {
+		s.AddEntity("E1999", "Intruder", "")
+		return nil
+	}})
+
+	// The snapshot shares the store's statements until the store writes them
+	snapshot := store.ReadSnapshot()
+	same := func(a, b any) bool { return reflect.ValueOf(a).UnsafePointer() == reflect.ValueOf(b).UnsafePointer() }
+	if !same(snapshot.store.entities, store.entities) || !same(snapshot.store.assertions.subjects, store.assertions.subjects) {
+		t.Error("Expected the snapshot to share the store's statements")
+	}
+	store.Tag("E1001", "moving")
+	if same(snapshot.store.entities, store.entities) || !same(snapshot.store.assertions.subjects, store.assertions.subjects) {
+		t.Error("Expected a write to copy the maps but not the untouched columns")
+	}
+
+	store.AddEntity("E1003", "Garage", "")
+	store.CreateAssertion("F1002", "E1001", "R1001", "E1003")
+	if same(snapshot.store.assertions.subjects, store.assertions.subjects) {
+		t.Error("Expected a new assertion to copy the columns")
+	}
+	store.Untag("F1001", "unverified")
+	store.Retract("F1001", "moved")
+	entityRef, _ := store.GetEntity("E1001")
+	entityRef.KMACEntity.SetProperty("color", "red")
+	store.RemoveEntity("E1002", "closed")
+
+	if _, err := snapshot.GetEntity("E1003"); err == nil {
+		t.Error("Expected entity added after the snapshot to be absent")
+	}
+	if _, err := snapshot.GetEntity("E1002"); err != nil {
+		t.Errorf("Expected entity removed after the snapshot to remain, got %v", err)
+	}
+	snapEntity, _ := snapshot.GetEntity("E1001")
+	if snapEntity.KMACEntity.HasProperty("color") {
+		t.Error("Expected property set after the snapshot to be absent")
+	}
+	if assertions := snapshot.FindAssertionsBySubject("E1001"); len(assertions) != 1 || assertions[0].ID() != "F1001" {
+		t.Errorf("Expected only F1001 live in the snapshot, got %v", assertions)
+	}
+	if _, retracted := snapshot.GetRetraction("F1001"); retracted {
+		t.Error("Expected retraction after the snapshot to be absent")
+	}
+	if ids := snapshot.FindByTag("unverified"); len(ids) != 1 || ids[0] != "F1001" {
+		t.Errorf("Expected F1001 still tagged in the snapshot, got %v", ids)
+	}
+	if stats := snapshot.GetStatistics(); stats["entities"] != 2 || stats["assertions"] != 1 {
+		t.Errorf("Expected snapshot statistics as taken, got %v", stats)
+	}
+	if warnings := snapshot.ValidateStore(); len(warnings) != 0 {
+		t.Errorf("Expected a consistent snapshot, got %v", warnings)
+	}
+	if len(snapshot.store.entities) != 2 {
+		t.Errorf("Expected validation to leave the snapshot as taken, got %d entities", len(snapshot.store.entities))
+	}
+	if _, err := store.GetEntity("E1999"); err == nil {
+		t.Error("Expected an invariant run on the snapshot to leave the store alone")
+	}
+	assertion, _ := snapshot.GetAssertion("F1001")
+	assertion.AddTag("reviewed")
+	if ids := snapshot.FindByTag("reviewed"); len(ids) != 0 {
+		t.Errorf("Expected assertions read from a snapshot not written back, got %v", ids)
+	}
+	if snapshot.TakenAt().IsZero() {
+		t.Error("Expected the snapshot time to be set")
+	}
+}
+
+func TestSemanticStoreWriteKMAC(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Truck", "")
+	store.AddEntity("E1002", "Depot", "")
+	store.AddRelation("R1001", "parked_at", "FUNCTIONAL")
+	store.CreateAssertion("F1001", "E1001", "R1001", "E1002")
+	store.CreateAssertion("F1002", "F1001", "R1001", "E1002")
+	store.CreateAssertion("F1003", "E1002", "R1001", "E1001")
+	store.SetAssertionConfidence("F1001", 0.7, "sensor")
+	store.Annotate("F1001", "source", "gate camera")
+	store.Retract("F1003", "wrong way round")
+
+	var buf bytes.Buffer
+	if err := store.WriteKMAC(&buf); err != nil {
+		t.Fatalf("WriteKMAC failed: %v", err)
+	}
+	restored := NewSemanticStore()
+	if err := restored.LoadKMAC(&buf); err != nil {
+		t.Fatalf("LoadKMAC failed: %v", err)
+	}
+
+	if stats := restored.GetStatistics(); stats["entities"] != 2 || stats["relations"] != 1 || stats["assertions"] != 2 {
+		t.Errorf("Expected live statements restored, got %v", stats)
+	}
+	assertion, err := restored.GetAssertion("F1001")
+	if err != nil {
+		t.Fatalf("GetAssertion failed: %v", err)
+	}
+	if level, source := assertion.GetConfidence(); level != 0.7 || source != "sensor" {
+		t.Errorf("Expected confidence restored, got %v %s", level, source)
+	}
+	if value, _ := assertion.Annotation("source"); value != "gate camera" {
+		t.Errorf("Expected annotation restored, got %q", value)
+	}
+}
+
+func TestSemanticStoreWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.wal")
+	store := NewSemanticStore()
+	if err := store.OpenWAL(path, WALOptions{Sync: true}); err != nil {
+		t.Fatalf("OpenWAL failed: %v", err)
+	}
+	store.AddEntity("E1001", "Truck [north]", "")
+	store.AddEntity("E1002", "Depot", "")
+	store.AddEntity("E1003", "Garage", "")
+	store.AddRelation("R1001", "parked_at", "FUNCTIONAL")
+	store.CreateAssertion("F1001", "E1001", "R1001", "E1002")
+	store.CreateAssertions([]AssertionSpec{
+		{ID: "F1002", Subject: "E1001", Relation: "R1001", Object: "E1003"},
+		{ID: "F1003", Subject: "E1009", Relation: "R1001", Object: "E1003"},
+		{ID: "F1004", Subject: "E1002", Relation: "R1001", Object: "E1003"},
+	})
+	store.CreateDerivedAssertion("F1005", "E1003", "R1001", "E1001", []string{"F1002"})
+	store.SetAssertionConfidence("F1001", 0.25, "rumour")
+	store.AddNote("F1001", "seen at dawn\nby the gate")
+	store.Tag("F1004", "unverified", "stale")
+	store.Untag("F1004", "stale")
+	store.Retract("F1002", "moved away")
+	store.LoadKMAC(strings.NewReader("DEF_ENTITY #E1004 [Crane] type=[]\nPROPERTY #E1004 [capacity] value=[40t]\n"))
+	store.RemoveEntity("E1004", "sold")
+	if err := store.CloseWAL(); err != nil {
+		t.Fatalf("CloseWAL failed: %v", err)
+	}
+	store.AddEntity("E1005", "Unlogged", "")
+
+	replayed := NewSemanticStore()
+	if err := replayed.OpenWAL(path, WALOptions{}); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	defer replayed.CloseWAL()
+	entityRef, err := replayed.GetEntity("E1001")
+	if err != nil || entityRef.KMACEntity.Label() != "Truck [north]" {
+		t.Errorf("Expected entity with escaped label replayed, got %v %v", entityRef, err)
+	}
+	if _, err := replayed.GetEntity("E1005"); err == nil {
+		t.Error("Expected mutations after CloseWAL to be absent")
+	}
+	if _, err := replayed.GetAssertion("F1003"); err == nil {
+		t.Error("Expected rejected batch item to be absent")
+	}
+	assertion, err := replayed.GetAssertion("F1001")
+	if err != nil {
+		t.Fatalf("GetAssertion failed: %v", err)
+	}
+	if level, source := assertion.GetConfidence(); level != 0.25 || source != "rumour" {
+		t.Errorf("Expected confidence replayed, got %v %s", level, source)
+	}
+	if notes := assertion.Notes(); len(notes) != 1 || notes[0] != "seen at dawn\nby the gate" {
+		t.Errorf("Expected multi-line note replayed, got %q", notes)
+	}
+	if ids := replayed.FindByTag("unverified"); len(ids) != 1 || ids[0] != "F1004" {
+		t.Errorf("Expected tags replayed, got %v", ids)
+	}
+	if ids := replayed.FindByTag("stale"); len(ids) != 0 {
+		t.Errorf("Expected removed tag to stay removed, got %v", ids)
+	}
+	if retraction, ok := replayed.GetRetraction("F1005"); !ok || retraction.Cause != "F1002" {
+		t.Errorf("Expected derived assertion retracted with its premise, got %+v", retraction)
+	}
+	if _, ok := replayed.GetTombstone("E1004"); !ok {
+		t.Error("Expected loaded entity to be removed again")
+	}
+
+	// A record torn by a crash is dropped, and logging carries on after it
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	file.WriteString("ENTITY [E1006] [Half")
+	file.Close()
+	replayed.CloseWAL()
+	reopened := NewSemanticStore()
+	if err := reopened.OpenWAL(path, WALOptions{}); err != nil {
+		t.Fatalf("Replay of torn log failed: %v", err)
+	}
+	reopened.AddEntity("E1007", "Forklift", "")
+	reopened.CloseWAL()
+	final := NewSemanticStore()
+	if err := final.OpenWAL(path, WALOptions{}); err != nil {
+		t.Fatalf("Replay after torn record failed: %v", err)
+	}
+	defer final.CloseWAL()
+	if _, err := final.GetEntity("E1007"); err != nil {
+		t.Errorf("Expected entity logged after the torn record, got %v", err)
+	}
+	if stats := final.GetStatistics(); stats["entities"] != 4 {
+		t.Errorf("Expected 4 entities after replay, got %v", stats)
+	}
+}
+
+func TestSemanticStoreRedaction(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Field_Hospital", "11B3ME-DHO-SPT")
+	store.AddEntity("E1002", "Supply_Depot", "00B3SO-LAR-ERT")
+	store.AddEntity("E1003", "Informant", "")
+	store.AddEntity("E1004", "Convoy", "")
+	store.AddRelation("R1001", "supplies", "FUNCTIONAL")
+	store.CreateAssertion("F1001", "E1002", "R1001", "E1001")
+	store.CreateAssertion("F1002", "F1001", "R1001", "E1004")
+	store.CreateAssertion("F1003", "E1003", "R1001", "E1004")
+	store.CreateAssertion("F1004", "E1004", "R1001", "E1002")
+	store.Tag("E1003", "source")
+	store.Tag("F1004", "secret")
+	state, _ := kmac.NewStateAssertion("F1005", "E1003", "status", "active", time.Date(2025, 5, 19, 8, 0, 0, 0, time.UTC))
+	store.AddStateAssertion(state)
+
+	if _, _, err := store.RedactedStatements(&RedactionFilter{Rules: []RedactionRule{{}}}); err == nil {
+		t.Error("Expected error for a rule that matches nothing")
+	}
+
+	filter := &RedactionFilter{Rules: []RedactionRule{
+		{TOSIDPattern: "11B-3ME-*"},
+		{Tag: "source", Action: RedactMask},
+		{Tag: "secret"},
+	}}
+	var buf bytes.Buffer
+	report, err := store.WriteRedactedKMAC(&buf, filter)
+	if err != nil {
+		t.Fatalf("WriteRedactedKMAC failed: %v", err)
+	}
+	if strings.Join(report.Stripped, ",") != "E1001,F1001,F1002,F1004" {
+		t.Errorf("Expected the hospital, the assertions about it, and the tagged assertion stripped, got %v", report.Stripped)
+	}
+	if strings.Join(report.Masked, ",") != "E1003" {
+		t.Errorf("Expected the informant masked, got %v", report.Masked)
+	}
+
+	redacted := NewSemanticStore()
+	if err := redacted.LoadKMAC(&buf); err != nil {
+		t.Fatalf("LoadKMAC failed: %v", err)
+	}
+	if stats := redacted.GetStatistics(); stats["entities"] != 3 || stats["assertions"] != 1 {
+		t.Errorf("Expected only unredacted statements exported, got %v", stats)
+	}
+	informant, err := redacted.GetEntity("E1003")
+	if err != nil {
+		t.Fatalf("Expected masked entity kept: %v", err)
+	}
+	if informant.KMACEntity.Label() != DefaultMaskLabel || informant.KMACEntity.HasTag("source") {
+		t.Errorf("Expected masked entity to hide its label and tags, got %s %v", informant.KMACEntity.Label(), informant.KMACEntity.Tags())
+	}
+	if _, err := redacted.GetAssertion("F1003"); err != nil {
+		t.Errorf("Expected assertion about a masked entity kept: %v", err)
+	}
+	if history := redacted.StateHistory("E1003", "status"); len(history) != 0 {
+		t.Errorf("Expected masked entity states dropped, got %v", history)
+	}
+}
+
+func BenchmarkStreamAtLimit(b *testing.B) {
+	for _, max := range []int{5000, 20000} {
+		b.Run(fmt.Sprintf("MaxAssertions=%d", max), func(b *testing.B) {
+			store := NewSemanticStore()
+			store.AddEntity("E1", "Sensor", "")
+			store.AddEntity("E2", "Reading", "")
+			store.SetLimits(&StoreLimits{MaxAssertions: max})
+			for i := 0; i < max; i++ {
+				store.CreateAssertion(fmt.Sprintf("F%d", i), "E1", "R1", "E2")
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				store.CreateAssertion(fmt.Sprintf("F%d", max+i), "E1", "R1", "E2")
+			}
+		})
+	}
+}
+
+func BenchmarkCreateAssertions(b *testing.B) {
+	const entityCount = 1000
+	const batchSize = 10000
+
+	newStore := func() *SemanticStore {
+		store := NewSemanticStore()
+		for i := 0; i < entityCount; i++ {
+			store.AddEntity(fmt.Sprintf("E%d", i), fmt.Sprintf("Entity_%d", i), "")
+		}
+		// A few outstanding references make every single insert scan them
+		store.SetIntegrityMode(IntegrityDeferred)
+		for i := 0; i < 100; i++ {
+			store.CreateAssertion(fmt.Sprintf("F9%d", i), "E0", "R1", fmt.Sprintf("E9%d", i))
+		}
+		return store
+	}
+	batch := make([]AssertionSpec, batchSize)
+	for i := range batch {
+		batch[i] = AssertionSpec{
+			ID:       fmt.Sprintf("F%d", i),
+			Subject:  fmt.Sprintf("E%d", i%entityCount),
+			Relation: "R1",
+			Object:   fmt.Sprintf("E%d", (i*7+1)%entityCount),
+		}
+	}
+
+	b.Run("Loop", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			store := newStore()
+			b.StartTimer()
+			for _, spec := range batch {
+				store.CreateAssertion(spec.ID, spec.Subject, spec.Relation, spec.Object)
+			}
+		}
+	})
+	b.Run("Batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			store := newStore()
+			b.StartTimer()
+			store.CreateAssertions(batch)
+		}
+	})
+
+	// Bounded stores evict once per batch rather than once per assertion
+	b.Run("BoundedLoop", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			store := newStore()
+			store.SetLimits(&StoreLimits{MaxAssertions: batchSize - 1000})
+			b.StartTimer()
+			for _, spec := range batch {
+				store.CreateAssertion(spec.ID, spec.Subject, spec.Relation, spec.Object)
+			}
+		}
+	})
+	b.Run("BoundedBatch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			store := newStore()
+			store.SetLimits(&StoreLimits{MaxAssertions: batchSize - 1000})
+			b.StartTimer()
+			store.CreateAssertions(batch)
+		}
+	})
+}
+
+func TestSemanticStoreRetention(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Bridge_Status", "11B3ME-DHO-SPT")
+	store.AddEntity("E1002", "Battle_Of_Hastings", "00B3SO-LAR-ERT")
+	store.AddEntity("E1003", "Engineer", "")
+	store.AddRelation("R1001", "reported", "FUNCTIONAL")
+	store.CreateAssertion("F1001", "E1001", "R1001", "E1003")
+	store.CreateAssertion("F1002", "E1002", "R1001", "E1003")
+	store.CreateAssertion("F1003", "E1003", "R1001", "F1001")
+	store.CreateAssertion("F1004", "E1003", "R1001", "E1002")
+
+	if _, err := LoadRetentionPolicy(strings.NewReader(`{"rules": [{"pattern": "11B", "ttl": "soon"}]}`)); err == nil {
+		t.Error("Expected error for an invalid TTL")
+	}
+	policy, err := LoadRetentionPolicy(strings.NewReader(`{"rules": [
+		{"pattern": "11B-3ME", "ttl": "24h"},
+		{"pattern": "00B", "ttl": "never"},
+		{"ttl": "720h"}
+	]}`))
+	if err != nil {
+		t.Fatalf("LoadRetentionPolicy failed: %v", err)
+	}
+	if err := store.SetRetentionPolicy(policy); err != nil {
+		t.Fatalf("SetRetentionPolicy failed: %v", err)
+	}
+
+	now := time.Now()
+	if expired := store.ExpiredAssertions(now.Add(time.Hour)); len(expired) != 0 {
+		t.Errorf("Expected nothing expired yet, got %v", expired)
+	}
+	report, err := store.PurgeExpired(now.Add(25 * time.Hour))
+	if err != nil {
+		t.Fatalf("PurgeExpired failed: %v", err)
+	}
+	if len(report.Expired) != 1 || report.Expired[0].AssertionID != "F1001" || report.Expired[0].TTL != 24*time.Hour {
+		t.Errorf("Expected the status assertion expired, got %+v", report.Expired)
+	}
+	if strings.Join(report.Removed, ",") != "F1001,F1003" {
+		t.Errorf("Expected the assertion about it removed too, got %v", report.Removed)
+	}
+	if lines := report.Lines(); len(lines) != 2 || !strings.Contains(lines[0], "expired after 24h0m0s") {
+		t.Errorf("Unexpected audit lines: %v", lines)
+	}
+	if tombstone, removed := store.GetTombstone("F1001"); !removed || !strings.HasPrefix(tombstone.Reason, "retention:") {
+		t.Errorf("Expected a retention tombstone, got %+v", tombstone)
+	}
+
+	report, _ = store.PurgeExpired(now.Add(24 * 365 * time.Hour))
+	if strings.Join(report.Removed, ",") != "F1004" {
+		t.Errorf("Expected only the catch-all rule to expire more, got %v", report.Removed)
+	}
+	if _, err := store.GetAssertion("F1002"); err != nil {
+		t.Errorf("Expected the historical assertion kept: %v", err)
+	}
+}
+
+func TestSemanticStoreInverses(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Red_Cross", "")
+	store.AddEntity("E1002", "Field_Hospital", "")
+	store.AddEntity("E1003", "Shelter", "")
+	store.AddRelation("R1001", "SUPPLIES", "RESOURCE_OWNERSHIP")
+	store.AddRelation("R1002", "SUPPLIED_BY", "RESOURCE_OWNERSHIP")
+	store.AddRelation("R1003", "LOCATED_AT", "SPATIAL_RELATIONSHIP")
+	store.CreateAssertion("F1001", "E1001", "R1001", "E1002")
+	store.CreateAssertion("F1002", "E1003", "R1002", "E1001")
+
+	if err := store.DeclareInverse("R1001", "R1002"); err != nil {
+		t.Fatalf("DeclareInverse failed: %v", err)
+	}
+	if inverse, has := store.Inverse("R1002"); !has || inverse != "R1001" {
+		t.Errorf("Expected R1001 the inverse of R1002, got %q", inverse)
+	}
+	if err := store.DeclareInverse("R1001", "R1003"); err == nil {
+		t.Error("Expected a second inverse rejected")
+	}
+
+	// Either relation answers in both directions
+	if objects := store.FindObjects("E1001", "R1001"); strings.Join(objects, ",") != "E1002,E1003" {
+		t.Errorf("Expected E1001 to supply E1002 and E1003, got %v", objects)
+	}
+	if objects := store.FindObjects("E1002", "R1002"); strings.Join(objects, ",") != "E1001" {
+		t.Errorf("Expected E1002 supplied by E1001, got %v", objects)
+	}
+
+	created, err := store.MaterializeInverses()
+	if err != nil {
+		t.Fatalf("MaterializeInverses failed: %v", err)
+	}
+	if strings.Join(created, ",") != "F1,F2" {
+		t.Fatalf("Expected two inverses created, got %v", created)
+	}
+	inverse, _ := store.GetAssertion("F1")
+	if inverse.Subject() != "E1002" || inverse.Relation() != "R1002" || inverse.Object() != "E1001" {
+		t.Errorf("Unexpected inverse of F1001: %v", inverse)
+	}
+	if premises := store.Premises("F1"); strings.Join(premises, ",") != "F1001" {
+		t.Errorf("Expected F1 derived from F1001, got %v", premises)
+	}
+	if created, _ := store.MaterializeInverses(); len(created) != 0 {
+		t.Errorf("Expected nothing more to materialize, got %v", created)
+	}
+
+	store.Retract("F1001", "contract ended")
+	if _, retracted := store.GetRetraction("F1"); !retracted {
+		t.Error("Expected the inverse retracted with its premise")
+	}
+
+	var buf bytes.Buffer
+	store.WriteKMAC(&buf)
+	loaded := NewSemanticStore()
+	if err := loaded.LoadKMAC(&buf); err != nil {
+		t.Fatalf("LoadKMAC failed: %v", err)
+	}
+	if inverse, has := loaded.Inverse("R1001"); !has || inverse != "R1002" {
+		t.Errorf("Expected the declaration kept in KMAC, got %q", inverse)
+	}
+}
+
+func TestSemanticStoreEvidence(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Kepler-22b", "")
+	store.AddEntity("E1002", "Kepler-22", "")
+	store.AddRelation("R1001", "ORBITS", "CELESTIAL_MOTION")
+	store.CreateAssertion("F1001", "E1001", "R1001", "E1002")
+	store.SetAssertionConfidence("F1001", 0.9, "TRANSIT_OBSERVATIONS")
+	store.CreateDerivedAssertion("F1002", "E1002", "R1001", "E1001", []string{"F1001"})
+
+	paper, _ := kmac.NewEvidence("D1001", "F1001")
+	paper.SetDOI("10.1088/0004-637X/745/2/120")
+	if err := store.AddEvidence(paper); err != nil {
+		t.Fatalf("AddEvidence failed: %v", err)
+	}
+	missing, _ := kmac.NewEvidence("D1002", "F1999")
+	missing.SetURL("https://example.org/")
+	if err := store.AddEvidence(missing); err == nil {
+		t.Error("Expected evidence for an unknown assertion rejected")
+	}
+	empty, _ := kmac.NewEvidence("D1003", "F1001")
+	if err := store.AddEvidence(empty); err == nil {
+		t.Error("Expected evidence without an artifact rejected")
+	}
+
+	provenance, err := store.Provenance("F1002")
+	if err != nil {
+		t.Fatalf("Provenance failed: %v", err)
+	}
+	if strings.Join(provenance.Premises, ",") != "F1001" || provenance.Source != "DERIVED" || len(provenance.Evidence) != 0 {
+		t.Errorf("Unexpected provenance of F1002: %+v", provenance)
+	}
+
+	var buf bytes.Buffer
+	store.WriteKMAC(&buf)
+	loaded := NewSemanticStore()
+	if err := loaded.LoadKMAC(&buf); err != nil {
+		t.Fatalf("LoadKMAC failed: %v", err)
+	}
+	if evidence := loaded.EvidenceFor("F1001"); len(evidence) != 1 || evidence[0].DOI() != "10.1088/0004-637X/745/2/120" {
+		t.Errorf("Expected the evidence kept in KMAC, got %v", evidence)
+	}
+
+	store.Retract("F1001", "transit was an artifact")
+	provenance, err = store.Provenance("F1001")
+	if err != nil {
+		t.Fatalf("Provenance failed: %v", err)
+	}
+	if provenance.Retraction == nil || len(provenance.Evidence) != 1 || provenance.Confidence != 0.9 {
+		t.Errorf("Expected a retracted assertion to keep its provenance, got %+v", provenance)
+	}
+}
+
+func TestSemanticStoreCombinedConfidence(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Kepler-22b", "")
+	store.AddEntity("E1002", "Kepler-22", "")
+	store.AddRelation("R1001", "ORBITS", "CELESTIAL_MOTION")
+	store.CreateAssertion("F1001", "E1001", "R1001", "E1002")
+	store.CreateAssertion("F1002", "E1001", "R1001", "E1002")
+	store.SetAssertionConfidence("F1001", 0.9, "TRANSIT_OBSERVATIONS")
+	store.SetAssertionConfidence("F1002", 0.5, "survey")
+
+	// 1 - (1 - 0.9*0.9) * (1 - 0.5*0.5)
+	level, ok := store.CombinedConfidence("E1001", "R1001", "E1002")
+	if !ok || math.Abs(level-0.8575) > 1e-9 {
+		t.Errorf("Expected combined confidence 0.8575, got %v", level)
+	}
+
+	sources := kmac.DefaultSources()
+	sources.Register("SURVEY", 1.0, "")
+	store.SetSources(sources)
+	if level, _ := store.CombinedConfidence("E1001", "R1001", "E1002"); math.Abs(level-0.905) > 1e-9 {
+		t.Errorf("Expected a trusted survey to raise the combined confidence to 0.905, got %v", level)
+	}
+	if _, ok := store.CombinedConfidence("E1002", "R1001", "E1001"); ok {
+		t.Error("Expected no confidence for an unasserted claim")
+	}
+}
+
+func TestSemanticStoreSituations(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Apollo_11", "")
+	store.AddEntity("E1002", "Moon", "")
+	store.AddEntity("E1003", "Armstrong", "")
+	store.AddRelation("R1001", "LANDED_ON", "SPATIAL")
+	store.AddRelation("R1002", "WALKED_ON", "SPATIAL")
+	store.CreateAssertion("F1001", "E1001", "R1001", "E1002")
+	store.CreateAssertion("F1002", "E1003", "R1002", "E1002")
+	store.CreateAssertion("F1003", "E1003", "R1001", "E1002")
+
+	if err := store.AddSituation("S1001", "Apollo_11_Mission", ""); err != nil {
+		t.Fatalf("AddSituation failed: %v", err)
+	}
+	if err := store.AddSituation("S1002", "Lunar_EVA", "S1001"); err != nil {
+		t.Fatalf("AddSituation failed: %v", err)
+	}
+	if err := store.AddSituation("S1003", "Orphan", "S1999"); err == nil {
+		t.Error("Expected an unknown parent rejected")
+	}
+	if err := store.AddSituation("S1001", "Apollo_11_Mission", "S1002"); err == nil {
+		t.Error("Expected a cycle of situations rejected")
+	}
+
+	if err := store.AddToSituation("S1001", "F1001", "F1003"); err != nil {
+		t.Fatalf("AddToSituation failed: %v", err)
+	}
+	if err := store.AddToSituation("S1002", "F1002", "F1003"); err != nil {
+		t.Fatalf("AddToSituation failed: %v", err)
+	}
+	if err := store.AddToSituation("S1002", "F1999"); err == nil {
+		t.Error("Expected an unknown assertion rejected")
+	}
+
+	ids := func(assertions []*kmac.Assertion) string {
+		var ids []string
+		for _, assertion := range assertions {
+			ids = append(ids, assertion.ID())
+		}
+		return strings.Join(ids, ",")
+	}
+	if got := ids(store.FindAssertionsInSituation("S1001", false)); got != "F1001,F1003" {
+		t.Errorf("Expected F1001,F1003 in S1001, got %s", got)
+	}
+	if got := ids(store.FindAssertionsInSituation("S1001", true)); got != "F1001,F1003,F1002" {
+		t.Errorf("Expected F1001,F1003,F1002 in S1001 and within it, got %s", got)
+	}
+	if situations := store.SituationsOf("F1003"); strings.Join(situations, ",") != "S1001,S1002" {
+		t.Errorf("Expected F1003 in S1001 and S1002, got %v", situations)
+	}
+	if children := store.SubSituations("S1001"); len(children) != 1 || children[0].ID() != "S1002" {
+		t.Errorf("Expected S1002 within S1001, got %v", children)
+	}
+
+	store.Retract("F1003", "mistaken")
+	if got := ids(store.FindAssertionsInSituation("S1002", false)); got != "F1002" {
+		t.Errorf("Expected retracted assertions left out, got %s", got)
+	}
+
+	var buf bytes.Buffer
+	store.WriteKMAC(&buf)
+	loaded := NewSemanticStore()
+	if err := loaded.LoadKMAC(&buf); err != nil {
+		t.Fatalf("LoadKMAC failed: %v", err)
+	}
+	if got := ids(loaded.FindAssertionsInSituation("S1001", true)); got != "F1001,F1002" {
+		t.Errorf("Expected situations kept in KMAC, got %s", got)
+	}
+	if eva, err := loaded.GetSituation("S1002"); err != nil || eva.ParentID() != "S1001" {
+		t.Errorf("Expected S1002 kept within S1001, got %v, %v", eva, err)
+	}
+}
+
+func TestSemanticStoreImpactOf(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Kepler-22b", "")
+	store.AddEntity("E1002", "Kepler-22", "")
+	store.AddEntity("E1003", "Kepler", "")
+	store.AddRelation("R1001", "ORBITS", "CELESTIAL_MOTION")
+	store.AddRelation("R1002", "OBSERVED", "OBSERVATION")
+	store.CreateAssertion("F1001", "E1001", "R1001", "E1002")
+	store.CreateAssertion("F1002", "E1003", "R1002", "F1001")
+	store.CreateAssertion("F1003", "E1003", "R1002", "E1002")
+	store.CreateDerivedAssertion("F1004", "E1002", "R1001", "E1003", []string{"F1003", "F1002"})
+	store.SetEntityVector("E1001", []float32{1, 0})
+	store.AddSituation("S1001", "Discovery", "")
+	store.AddToSituation("S1001", "F1003", "F1004")
+	paper, _ := kmac.NewEvidence("D1001", "F1001")
+	paper.SetDOI("10.1088/0004-637X/745/2/120")
+	store.AddEvidence(paper)
+
+	impact, err := store.ImpactOf("E1001")
+	if err != nil {
+		t.Fatalf("ImpactOf failed: %v", err)
+	}
+	if strings.Join(impact.Removed, ",") != "F1001,F1002" || strings.Join(impact.Retracted, ",") != "F1004" {
+		t.Errorf("Unexpected assertions affected: %+v", impact)
+	}
+	if strings.Join(impact.Evidence, ",") != "D1001" || strings.Join(impact.Situations, ",") != "S1001" || !impact.Vector {
+		t.Errorf("Unexpected attachments affected: %+v", impact)
+	}
+	if lines := impact.Lines(); len(lines) != 6 || lines[0] != "assertion F1001 would be removed" {
+		t.Errorf("Unexpected impact lines: %v", lines)
+	}
+	if _, err := store.GetAssertion("F1001"); err != nil {
+		t.Errorf("Expected ImpactOf to leave the store unchanged, got %v", err)
+	}
+
+	if err := store.RemoveEntity("E1001", "duplicate"); err != nil {
+		t.Fatalf("RemoveEntity failed: %v", err)
+	}
+	for _, id := range impact.Removed {
+		if _, removed := store.GetTombstone(id); !removed {
+			t.Errorf("Expected %s removed with E1001", id)
+		}
+	}
+	if _, retracted := store.GetRetraction("F1004"); !retracted {
+		t.Error("Expected F1004 retracted with E1001")
+	}
+	if _, err := store.ImpactOf("E1001"); err == nil {
+		t.Error("Expected the impact of a removed entity rejected")
+	}
+}
+
+func TestSemanticStoreExplain(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Sun", "00B2SO-LAR-SUN:000-000-000-001")
+	store.AddEntity("E1002", "Earth", "00B2SO-LAR-PLA:000-000-000-003")
+	store.AddEntity("E1003", "Moon", "00B2SO-MON-SAT:000-000-000-001")
+	store.AddRelation("R1001", "ORBITS", "CELESTIAL_MOTION")
+	store.AddRelation("R1002", "ORBITED_BY", "CELESTIAL_MOTION")
+	store.DeclareInverse("R1001", "R1002")
+	store.CreateAssertion("F1001", "E1002", "R1001", "E1001")
+	store.CreateAssertion("F1002", "E1002", "R1002", "E1003")
+	store.CreateDerivedAssertion("F1003", "E1003", "R1001", "E1001", []string{"F1001", "F1002"})
+
+	matches := store.ExplainTOSIDPattern("00B*LAR")
+	if len(matches) != 2 || matches[0].ID != "E1001" || matches[1].ID != "E1002" {
+		t.Fatalf("Expected the Sun and Earth to match, got %+v", matches)
+	}
+	var names []string
+	for _, segment := range matches[0].Segments {
+		names = append(names, segment.Name+"="+segment.Value)
+	}
+	if got := strings.Join(names, " "); got != "taxonomy=00 netmask=B category 2=LAR" {
+		t.Errorf("Unexpected matched segments %s", got)
+	}
+
+	explanations := store.ExplainObjects("E1003", "R1001")
+	if len(explanations) != 2 {
+		t.Fatalf("Expected two objects, got %+v", explanations)
+	}
+	if sun := explanations[0]; sun.ID != "E1001" || strings.Join(sun.Assertions, ",") != "F1003" ||
+		strings.Join(sun.Rules, ";") != "derived F1003 from F1001, F1002" {
+		t.Errorf("Unexpected explanation of E1001: %+v", sun)
+	}
+	if earth := explanations[1]; earth.ID != "E1002" || strings.Join(earth.Assertions, ",") != "F1002" ||
+		strings.Join(earth.Rules, ";") != "inverse R1002 of R1001" {
+		t.Errorf("Unexpected explanation of E1002: %+v", earth)
+	}
+	if objects := store.FindObjects("E1003", "R1001"); strings.Join(objects, ",") != "E1001,E1002" {
+		t.Errorf("Expected FindObjects to agree with ExplainObjects, got %v", objects)
+	}
+}
+
+func TestSemanticStoreContentAssertions(t *testing.T) {
+	newStore := func() *SemanticStore {
+		store := NewSemanticStore()
+		store.AddEntity("E1001", "Earth", "")
+		store.AddEntity("E1002", "Sun", "")
+		store.AddRelation("R1001", "ORBITS", "CELESTIAL_MOTION")
+		return store
+	}
+
+	store := newStore()
+	id, err := store.CreateContentAssertion("E1001", "R1001", "E1002", "")
+	if err != nil {
+		t.Fatalf("CreateContentAssertion failed: %v", err)
+	}
+	if id != kmac.ContentAssertionID("E1001", "R1001", "E1002", "") {
+		t.Errorf("Expected the ID derived from the content, got %s", id)
+	}
+	other, _ := newStore().CreateContentAssertion("E1001", "R1001", "E1002", "")
+	if other != id {
+		t.Errorf("Expected another producer to derive the same ID, got %s and %s", id, other)
+	}
+
+	store.SetAssertionConfidence(id, 0.8, "OFFICIAL_REPORT")
+	if again, err := store.CreateContentAssertion("E1001", "R1001", "E1002", ""); err != nil || again != id {
+		t.Fatalf("Expected re-creating to return %s, got %s (%v)", id, again, err)
+	}
+	if assertion, _ := store.GetAssertion(id); assertion == nil {
+		t.Fatal("Expected the assertion kept")
+	} else if level, _ := assertion.GetConfidence(); level != 0.8 {
+		t.Errorf("Expected re-creating to leave the assertion unchanged, got confidence %v", level)
+	}
+	if inSituation, _ := store.CreateContentAssertion("E1001", "R1001", "E1002", "S1001"); inSituation == id {
+		t.Error("Expected the context to change the ID")
+	}
+
+	result := store.CreateAssertions([]AssertionSpec{
+		{Subject: "E1001", Relation: "R1001", Object: "E1002"},
+		{Subject: "E1002", Relation: "R1001", Object: "E1001"},
+		{Subject: "E1002", Relation: "R1001", Object: "E1001"},
+	})
+	if result.Err() != nil || result.Created != 1 || result.Duplicates != 2 {
+		t.Errorf("Expected one created and two duplicates, got %+v", result)
+	}
+	if result := store.CreateAssertions([]AssertionSpec{{Subject: "E1002", Relation: "R1001", Object: "E1001"}}); result.Created != 0 || result.Duplicates != 1 {
+		t.Errorf("Expected re-importing to create nothing, got %+v", result)
+	}
+}
+
+func TestSemanticStoreDisassembler(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Sun", "00B3SO-LAR-STR")
+	store.AddEntity("E1002", "Earth", "00B3SO-LAR-ERT")
+	store.AddRelation("R1001", "orbits", "SPATIAL")
+	if err := store.CreateAssertion("F1001", "E1002", "R1001", "E1001"); err != nil {
+		t.Fatalf("Failed to create assertion: %v", err)
+	}
+
+	var buf bytes.Buffer
+	d := kmac.NewDisassemblerFromStore(store, &buf)
+	d.SetColorEnabled(false)
+	d.DisassembleAssertion("F1001")
+	if output := buf.String(); !strings.Contains(output, "DESCRIPTION: Earth orbits Sun") {
+		t.Errorf("Expected the store's statements registered, got:\n%s", output)
+	}
+}
+
+func TestSemanticStoreWalk(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Sun", "00B3SO-LAR-STR")
+	store.AddEntity("E1002", "Earth", "00B3SO-LAR-ERT")
+	store.AddRelation("R1001", "orbits", "SPATIAL")
+	store.CreateAssertion("F1001", "E1002", "R1001", "E1001")
+
+	err := store.Walk(kmac.Chain(
+		kmac.RenameRelation("orbits", "revolves_around"),
+		kmac.RemapTOSIDs(map[string]string{"00B3SO-LAR-ERT": "00B3SO-LAR-TRR"}),
+	))
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	if relation, _ := store.GetRelation("R1001"); relation == nil || relation.Label() != "revolves_around" {
+		t.Errorf("Expected the relation renamed, got %v", relation)
+	}
+	if earth, err := store.GetEntity("E1002"); err != nil || earth.KMACEntity.TOSIDType() != "00B3SO-LAR-TRR" || earth.TOSIDObj == nil {
+		t.Errorf("Expected Earth remapped and its TOSID parsed, got %v (%v)", earth, err)
+	}
+	if len(store.FindAssertionsBySubject("E1002")) != 1 {
+		t.Error("Expected the assertion kept")
+	}
+
+	dropEarth := func(stmt kmac.Statement) (kmac.Statement, error) {
+		if stmt.ID() == "E1002" {
+			return nil, nil
+		}
+		return stmt, nil
+	}
+	if err := store.SetIntegrityMode(IntegrityStrict); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Walk(dropEarth); err == nil {
+		t.Error("Expected dropping an asserted-about entity to fail in strict mode")
+	}
+	if _, err := store.GetEntity("E1002"); err != nil {
+		t.Error("Expected a failed walk to leave the store unchanged")
+	}
+}
+
+func TestSemanticStoreLabels(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Red Cross", "11B1ME-ORG-NGO")
+	store.AddRelation("R1001", "supplied_by", "RESOURCE")
+
+	for _, label := range []struct{ lang, text string }{
+		{"fr", "Croix-Rouge"},
+		{"es", "Cruz Roja"},
+		{"fr", "Croix-Rouge"},
+		{"", "ICRC"},
+	} {
+		if err := store.AddLabel("E1001", label.lang, label.text); err != nil {
+			t.Fatalf("AddLabel failed: %v", err)
+		}
+	}
+	store.AddLabel("R1001", "fr", "fourni par")
+	if err := store.AddLabel("E9999", "fr", "Inconnu"); err == nil {
+		t.Error("Expected an error for an unknown entity")
+	}
+	if err := store.AddSynonym("E1001", "two\nlines"); err == nil {
+		t.Error("Expected an error for a label with a line break")
+	}
+
+	labels, _ := store.Labels("E1001")
+	expected := []LocalizedLabel{{"", "ICRC"}, {"es", "Cruz Roja"}, {"fr", "Croix-Rouge"}}
+	if fmt.Sprint(labels) != fmt.Sprint(expected) {
+		t.Errorf("Expected labels %v, got %v", expected, labels)
+	}
+	if label, _ := store.LabelIn("E1001", "es"); label != "Cruz Roja" {
+		t.Errorf("Expected the Spanish label, got %q", label)
+	}
+	if label, _ := store.LabelIn("E1001", "de"); label != "Red Cross" {
+		t.Errorf("Expected the entity's own label without a German one, got %q", label)
+	}
+
+	for _, pattern := range []string{"red cross", "croix", "ROJA", "icrc"} {
+		if found := store.FindEntitiesByLabel(pattern); len(found) != 1 {
+			t.Errorf("Expected %q to find the entity, got %v", pattern, found)
+		}
+	}
+	if found := store.FindRelationsByLabel("fourni"); len(found) != 1 || found[0].ID() != "R1001" {
+		t.Errorf("Expected the relation found by its French label, got %v", found)
+	}
+
+	// Labels are metadata, so they survive a round trip through KMAC text
+	var buf bytes.Buffer
+	if err := store.WriteKMAC(&buf); err != nil {
+		t.Fatalf("WriteKMAC failed: %v", err)
+	}
+	loaded := NewSemanticStore()
+	if err := loaded.LoadKMAC(&buf); err != nil {
+		t.Fatalf("LoadKMAC failed: %v", err)
+	}
+	if found := loaded.FindEntitiesByLabel("cruz"); len(found) != 1 {
+		t.Errorf("Expected labels kept through KMAC text, got %v", found)
+	}
+}
+
+func TestSemanticStoreFuzzyLabels(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Kepler-186f", "00B3SO-LAR-EXO")
+	store.AddEntity("E1002", "Kepler-186", "00B2SO-LAR-STR")
+	store.AddEntity("E1003", "TRAPPIST-1e", "00B3SO-LAR-EXO")
+	store.AddEntity("E1004", "Red Cross", "11B1ME-ORG-NGO")
+	store.AddLabel("E1004", "fr", "Croix-Rouge")
+
+	matches := store.FindEntitiesByLabelFuzzy("Kepler 186-f", 0.8)
+	if len(matches) != 2 {
+		t.Fatalf("Expected both Kepler entities, got %v", matches)
+	}
+	if matches[0].Entity.KMACEntity.ID() != "E1001" || matches[0].Similarity != 1 {
+		t.Errorf("Expected Kepler-186f first as an exact match, got %+v", matches[0])
+	}
+	if matches[1].Entity.KMACEntity.ID() != "E1002" || matches[1].Similarity >= 1 {
+		t.Errorf("Expected Kepler-186 second, got %+v", matches[1])
+	}
+
+	if matches := store.FindEntitiesByLabelFuzzy("Trapist 1e", 0.9); len(matches) != 1 || matches[0].Label != "TRAPPIST-1e" {
+		t.Errorf("Expected a misspelling to find TRAPPIST-1e, got %v", matches)
+	}
+	if matches := store.FindEntitiesByLabelFuzzy("croix rouge", 0.9); len(matches) != 1 || matches[0].Label != "Croix-Rouge" {
+		t.Errorf("Expected the French label to match, got %v", matches)
+	}
+	if matches := store.FindEntitiesByLabelFuzzy("Andromeda", 0.9); len(matches) != 0 {
+		t.Errorf("Expected nothing above the threshold, got %v", matches)
+	}
+
+	for _, c := range []struct {
+		a, b string
+		want float64
+	}{
+		{"martha", "marhta", 0.9611},
+		{"dixon", "dicksonx", 0.8133},
+		{"abc", "", 0},
+	} {
+		if got := jaroWinkler([]rune(c.a), []rune(c.b)); math.Abs(got-c.want) > 0.0001 {
+			t.Errorf("jaroWinkler(%q, %q) = %.4f, expected %.4f", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestSemanticStorePagination(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddRelation("R1001", "near", "SPATIAL")
+	for i := 1; i <= 7; i++ {
+		store.AddEntity(fmt.Sprintf("E100%d", i), fmt.Sprintf("Depot %d", i), "10B3TR-DEP-WHS")
+		if i > 1 {
+			store.CreateAssertion(fmt.Sprintf("F100%d", i), "E1001", "R1001", fmt.Sprintf("E100%d", i))
+		}
+	}
+
+	var ids []string
+	req := PageRequest{Limit: 3}
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("Expected paging to end")
+		}
+		page, err := store.FindEntitiesByLabelPage("depot", req)
+		if err != nil {
+			t.Fatalf("FindEntitiesByLabelPage failed: %v", err)
+		}
+		for _, entityRef := range page.Items {
+			ids = append(ids, entityRef.KMACEntity.ID())
+		}
+		if page.Next == "" {
+			break
+		}
+		req.Cursor = page.Next
+		if pages == 0 {
+			// Removing the cursor entity between pages must not disturb paging
+			if err := store.RemoveEntity(page.Next, "closed"); err != nil {
+				t.Fatalf("RemoveEntity failed: %v", err)
+			}
+		}
+	}
+	if strings.Join(ids, ",") != "E1001,E1002,E1003,E1004,E1005,E1006,E1007" {
+		t.Errorf("Expected every entity once in ID order, got %v", ids)
+	}
+
+	// F1003 went with E1003
+	page, _ := store.FindAssertionsBySubjectPage("E1001", PageRequest{Limit: 2, Offset: 2})
+	if len(page.Items) != 2 || page.Items[0].ID() != "F1005" || page.Next != "F1006" {
+		t.Errorf("Expected F1005 and F1006 after skipping two, got %v, next %q", page.Items, page.Next)
+	}
+	page, _ = store.FindAssertionsByRelationPage("R1001", PageRequest{Cursor: page.Next})
+	if len(page.Items) != 1 || page.Items[0].ID() != "F1007" || page.Next != "" {
+		t.Errorf("Expected the last page to hold F1007, got %v, next %q", page.Items, page.Next)
+	}
+	if _, err := store.FindEntitiesByTOSIDPatternPage("10B", PageRequest{Limit: -1}); err == nil {
+		t.Error("Expected an error for a negative limit")
+	}
+}
+
+func TestSemanticStoreQueryOrder(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Zebra", "10B3AN-MAM-ZEB")
+	store.AddEntity("E1002", "Antelope", "10B3AN-MAM-ANT")
+	store.AddEntity("E1003", "Lion", "10B3AN-MAM-LIO")
+	store.AddRelation("R1001", "hunts", "BEHAVIOR")
+	store.CreateAssertion("F1003", "E1003", "R1001", "E1001")
+	store.CreateAssertion("F1001", "E1003", "R1001", "E1002")
+	store.CreateAssertion("F1002", "E1003", "R1001", "E1003")
+	store.SetAssertionConfidence("F1003", 0.2, "")
+	store.SetAssertionConfidence("F1001", 0.9, "")
+	store.SetAssertionConfidence("F1002", 0.5, "")
+
+	entityIDs := func(entities []*EntityReference) string {
+		var ids []string
+		for _, entityRef := range entities {
+			ids = append(ids, entityRef.KMACEntity.ID())
+		}
+		return strings.Join(ids, ",")
+	}
+	for i := 0; i < 5; i++ {
+		if got := entityIDs(store.FindEntitiesByLabel("")); got != "E1001,E1002,E1003" {
+			t.Fatalf("Expected entities in ID order, got %s", got)
+		}
+	}
+
+	// Each order is paged through a result at a time, so cursors are used
+	for _, c := range []struct {
+		order, expected string
+	}{
+		{OrderByLabel, "E1002,E1003,E1001"},
+		{OrderByTOSID, "E1002,E1003,E1001"},
+		{"", "E1001,E1002,E1003"},
+	} {
+		var ids []string
+		req := PageRequest{Limit: 1, Order: c.order}
+		for {
+			page, err := store.FindEntitiesByLabelPage("", req)
+			if err != nil {
+				t.Fatalf("Order %q: %v", c.order, err)
+			}
+			ids = append(ids, entityIDs(page.Items))
+			if req.Cursor = page.Next; page.Next == "" {
+				break
+			}
+		}
+		if got := strings.Join(ids, ","); got != c.expected {
+			t.Errorf("Order %q: expected %s, got %s", c.order, c.expected, got)
+		}
+	}
+
+	for _, c := range []struct {
+		order, expected string
+	}{
+		{OrderByConfidence, "F1001,F1002,F1003"},
+		{OrderByRecency, "F1002,F1001,F1003"},
+	} {
+		store.assertedAt["F1003"] = time.Unix(100, 0)
+		store.assertedAt["F1001"] = time.Unix(200, 0)
+		store.assertedAt["F1002"] = time.Unix(300, 0)
+		var ids []string
+		req := PageRequest{Limit: 2, Order: c.order}
+		for {
+			page, err := store.FindAssertionsBySubjectPage("E1003", req)
+			if err != nil {
+				t.Fatalf("Order %q: %v", c.order, err)
+			}
+			for _, assertion := range page.Items {
+				ids = append(ids, assertion.ID())
+			}
+			if req.Cursor = page.Next; page.Next == "" {
+				break
+			}
+		}
+		if got := strings.Join(ids, ","); got != c.expected {
+			t.Errorf("Order %q: expected %s, got %s", c.order, c.expected, got)
+		}
+	}
+
+	if _, err := store.FindEntitiesByLabelPage("", PageRequest{Order: OrderByConfidence}); err == nil {
+		t.Error("Expected an error ordering entities by confidence")
+	}
+	if _, err := store.FindAssertionsForEntityPage("E1003", PageRequest{Order: OrderByLabel}); err == nil {
+		t.Error("Expected an error ordering assertions by label")
+	}
+	if _, err := store.FindEntitiesByLabelPage("", PageRequest{Order: OrderByLabel, Cursor: "not a cursor"}); err == nil {
+		t.Error("Expected an error for an invalid cursor")
+	}
+}
+
+func TestSemanticStoreSavedQueries(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Field hospital", "10B3MD-FAC-HSP")
+	store.AddEntity("E1002", "Water pump", "10B3UT-EQP-PMP")
+	store.AddEntity("E1003", "Clinic", "10B3MD-FAC-CLN")
+	store.AddEntity("E2001", "North region", "10B2GE-REG-NTH")
+	store.AddEntity("E2002", "South region", "10B2GE-REG-STH")
+	store.AddRelation("R1001", "located in", "SPATIAL")
+	store.CreateAssertion("F1001", "E1001", "R1001", "E2001")
+	store.CreateAssertion("F1002", "E1002", "R1001", "E2001")
+	store.CreateAssertion("F1003", "E1003", "R1001", "E2002")
+	store.Tag("E1001", "urgent")
+
+	query := SavedQuery{
+		Name:   "medical_needs_in",
+		Params: []string{"region"},
+		Query:  EntityQuery{Pattern: "10B-3MD", Relation: "located in", Object: "{region}"},
+	}
+	if err := store.RegisterQuery(query); err != nil {
+		t.Fatalf("Failed to register query: %v", err)
+	}
+
+	entityIDs := func(page Page[*EntityReference], err error) string {
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		var ids []string
+		for _, entityRef := range page.Items {
+			ids = append(ids, entityRef.KMACEntity.ID())
+		}
+		return strings.Join(ids, ",")
+	}
+	if got := entityIDs(store.CallQuery("medical_needs_in(E2001)", PageRequest{})); got != "E1001" {
+		t.Errorf("Expected E1001 in the north region, got %q", got)
+	}
+	if got := entityIDs(store.CallQuery("medical_needs_in( south region )", PageRequest{})); got != "E1003" {
+		t.Errorf("Expected the object to match by label, got %q", got)
+	}
+	if got := entityIDs(store.RunQuery("medical_needs_in", map[string]string{"region": "E2002"}, PageRequest{})); got != "E1003" {
+		t.Errorf("Expected E1003 from RunQuery, got %q", got)
+	}
+	if got := entityIDs(store.FindEntitiesPage(EntityQuery{Tag: "urgent"}, PageRequest{})); got != "E1001" {
+		t.Errorf("Expected the tagged entity, got %q", got)
+	}
+
+	if _, err := store.CallQuery("medical_needs_in()", PageRequest{}); err == nil {
+		t.Error("Expected an error calling without arguments")
+	}
+	if _, err := store.RunQuery("medical_needs_in", map[string]string{"region": "E2001", "year": "2024"}, PageRequest{}); err == nil {
+		t.Error("Expected an error for an unknown argument")
+	}
+	if _, err := store.CallQuery("unknown(E2001)", PageRequest{}); err == nil {
+		t.Error("Expected an error for an unregistered query")
+	}
+	undeclared := SavedQuery{Name: "broken", Query: EntityQuery{Label: "{name}"}}
+	if err := store.RegisterQuery(undeclared); err == nil {
+		t.Error("Expected an error registering a query with an undeclared parameter")
+	}
+
+	name, args, err := ParseQueryCall("between(E1, E2)")
+	if err != nil || name != "between" || len(args) != 2 || args[1] != "E2" {
+		t.Errorf("Unexpected parse: %s %v %v", name, args, err)
+	}
+	if _, _, err := ParseQueryCall("between(E1"); err == nil {
+		t.Error("Expected an error for an unclosed call")
+	}
+
+	queries, err := LoadSavedQueries(strings.NewReader(`[{"name": "in", "params": ["region"], "query": {"object": "{region}"}}]`))
+	if err != nil || len(queries) != 1 || queries[0].Query.Object != "{region}" {
+		t.Fatalf("Unexpected saved queries: %+v %v", queries, err)
+	}
+	if _, err := LoadSavedQueries(strings.NewReader(`[{"name": "bad name"}]`)); err == nil {
+		t.Error("Expected an error for an invalid query name")
+	}
+	if saved := store.SavedQueries(); len(saved) != 1 || saved[0].Name != "medical_needs_in" {
+		t.Errorf("Unexpected registered queries: %+v", saved)
+	}
+}
+
+func TestSemanticStoreSampling(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddRelation("R1001", "near", "SPATIAL")
+	for i := 1; i <= 40; i++ {
+		code := "10B3TR-DEP-WHS"
+		if i%2 == 0 {
+			code = "10B3MD-FAC-HSP"
+		}
+		store.AddEntity(fmt.Sprintf("E%04d", 1000+i), fmt.Sprintf("Site %d", i), code)
+		if i > 1 {
+			store.CreateAssertion(fmt.Sprintf("F%04d", 1000+i), "E1001", "R1001", fmt.Sprintf("E%04d", 1000+i))
+		}
+	}
+	store.Retract("F1002", "superseded")
+
+	store.SetSampleSeed(7)
+	sample := store.SampleEntities(5, "10B-3MD")
+	if len(sample) != 5 {
+		t.Fatalf("Expected 5 entities, got %d", len(sample))
+	}
+	for i, entityRef := range sample {
+		if !entityRef.TOSIDObj.MatchesPattern("10B-3MD") {
+			t.Errorf("Expected only hospitals, got %s", entityRef.KMACEntity.TOSIDType())
+		}
+		if i > 0 && sample[i-1].KMACEntity.ID() >= entityRef.KMACEntity.ID() {
+			t.Errorf("Expected the sample in ID order, got %v before %v", sample[i-1].KMACEntity.ID(), entityRef.KMACEntity.ID())
+		}
+	}
+	store.SetSampleSeed(7)
+	again := store.SampleEntities(5, "10B-3MD")
+	for i := range again {
+		if again[i] != sample[i] {
+			t.Errorf("Expected the same seed to draw the same sample, got %s for %s", again[i].KMACEntity.ID(), sample[i].KMACEntity.ID())
+		}
+	}
+	if all := store.SampleEntities(100); len(all) != 40 {
+		t.Errorf("Expected every entity when asking for more, got %d", len(all))
+	}
+
+	assertions := store.SampleAssertions(38)
+	if len(assertions) != 38 {
+		t.Fatalf("Expected every live assertion, got %d", len(assertions))
+	}
+	for _, assertion := range assertions {
+		if assertion.ID() == "F1002" {
+			t.Error("Expected retracted assertions to be left out")
+		}
+	}
+	if len(store.SampleAssertions(0)) != 0 {
+		t.Error("Expected an empty sample for n = 0")
+	}
+}
+
+func TestSemanticStoreQuality(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddRelation("R1001", "supplies", "LOGISTICS")
+	store.AddEntity("E1001", "Depot", "10B3TR-DEP-WHS")
+	store.AddEntity("E1002", "Hospital", "10B3MD-FAC-HSP")
+	store.AddEntity("E1003", "", "10B3MD-FAC-CLN")
+	store.AddEntity("E1004", "Clinic", "")
+	store.CreateAssertion("F1001", "E1001", "R1001", "E1002")
+	store.SetAssertionConfidence("F1001", 0.9, "SURVEY")
+	store.CreateAssertion("F1002", "E1001", "R1001", "E1004")
+	store.SetAssertionConfidence("F1002", 0.4, "")
+
+	report := store.ScoreQuality(QualityWeights{})
+	if len(report.Scores) != 4 {
+		t.Fatalf("Expected 4 scores, got %d", len(report.Scores))
+	}
+	worst := report.Worst(1)[0]
+	if worst.EntityID != "E1003" || worst.Completeness != 1.0/3 || worst.Confidence != 0 {
+		t.Errorf("Expected the unlabelled, unconnected entity to score worst, got %+v", worst)
+	}
+	if !strings.Contains(strings.Join(worst.Problems, "; "), "no label") {
+		t.Errorf("Expected the missing label among the problems, got %v", worst.Problems)
+	}
+
+	hospital, err := store.EntityQuality("E1002", QualityWeights{})
+	if err != nil {
+		t.Fatalf("EntityQuality failed: %v", err)
+	}
+	if hospital.Completeness != 1 || hospital.Confidence != 0.9 || hospital.Provenance != 1 || len(hospital.Problems) != 0 {
+		t.Errorf("Expected a complete, sourced entity, got %+v", hospital)
+	}
+	clinic, _ := store.EntityQuality("E1004", QualityWeights{})
+	if clinic.Score >= hospital.Score || clinic.Provenance != 0 {
+		t.Errorf("Expected the unsourced, uncertain clinic below the hospital, got %+v", clinic)
+	}
+	if last := report.Scores[len(report.Scores)-1]; last.EntityID != "E1002" {
+		t.Errorf("Expected the hospital to rank best, got %s", last.EntityID)
+	}
+
+	onlyCompleteness, _ := store.EntityQuality("E1004", QualityWeights{Completeness: 1})
+	if onlyCompleteness.Score != 2.0/3 {
+		t.Errorf("Expected the score to follow the weights, got %v", onlyCompleteness.Score)
+	}
+	if _, err := store.EntityQuality("E9999", QualityWeights{}); err == nil {
+		t.Error("Expected an error for an unknown entity")
+	}
+}
+
+func TestSemanticStoreLikelyDuplicates(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddRelation("R1001", "orbits", "ORBITAL")
+	store.AddEntity("E1001", "Kepler-186f", "00B3EX-TER-KPL:186-00F-000-000")
+	store.AddEntity("E1002", "Kepler 186 f", "00B3EX-TER-KPL:186-00F-000-000")
+	store.AddEntity("E1003", "Kepler-186", "00B2SO-STR-RDW")
+	store.AddEntity("E1004", "Red Cross", "11B1ME-ORG-NGO")
+	store.AddEntity("E1005", "Red-Cross", "")
+	store.AddEntity("E1006", "Red Crescent", "11B1ME-ORG-NGO")
+	store.AddEntity("E1007", "Kepler-186f", "00B3EX-TER-KPL:186-00E-000-000")
+	store.AddEntity("E1008", "Depot North", "10B3TR-DEP-WHS")
+	store.AddEntity("E1009", "Depot Norht", "10B3TR-DEP-WHS")
+	store.CreateAssertion("F1001", "E1002", "R1001", "E1003")
+
+	suggestions := store.FindLikelyDuplicates()
+	if len(suggestions) != 3 {
+		t.Fatalf("Expected 3 merge suggestions, got %v", suggestions)
+	}
+	byTOSID := suggestions[0]
+	if byTOSID.Survivor != "E1002" || strings.Join(byTOSID.Duplicates, ",") != "E1001" || byTOSID.Matches[0].Reason != DuplicateSameTOSID {
+		t.Errorf("Expected E1001 to merge into E1002, which has an assertion, got %+v", byTOSID)
+	}
+	byLabel := suggestions[1]
+	if byLabel.Survivor != "E1004" || strings.Join(byLabel.Duplicates, ",") != "E1005" || byLabel.Confidence != 1 {
+		t.Errorf("Expected the untyped Red-Cross to merge into E1004, got %+v", byLabel)
+	}
+	if typo := suggestions[2]; typo.Survivor != "E1008" || typo.Confidence >= 1 || typo.Confidence < DefaultDuplicateThreshold {
+		t.Errorf("Expected the misspelt depot last, got %+v", typo)
+	}
+
+	if loose := store.FindLikelyDuplicatesWith(DuplicateOptions{Threshold: 0.8}); len(loose) != 3 || len(loose[2].Duplicates) != 2 {
+		t.Errorf("Expected a lower threshold to draw Red Crescent into the Red Cross cluster, got %v", loose)
+	}
+}
+
+func TestSemanticStoreMergeEntities(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.wal")
+	store := NewSemanticStore()
+	if err := store.OpenWAL(path, WALOptions{}); err != nil {
+		t.Fatalf("OpenWAL failed: %v", err)
+	}
+	store.AddRelation("R1001", "supplies", "FUNCTIONAL")
+	store.LoadKMAC(strings.NewReader(`DEF_ENTITY #E1001 [Depot North] type=[10B3TR-DEP-WHS]
+PROPERTY #E1001 [capacity] value=[200t]
+DEF_ENTITY #E1002 [Depot Norht] type=[10B3TR-DEP-WHS]
+PROPERTY #E1002 [capacity] value=[150t]
+PROPERTY #E1002 [manager] value=[Okafor]
+`))
+	store.AddEntity("E1003", "Field Hospital", "")
+	store.CreateAssertion("F1001", "E1001", "R1001", "E1003")
+	store.CreateAssertion("F1002", "E1002", "R1001", "E1003")
+	store.CreateAssertion("F1003", "E1003", "R1001", "E1002")
+	store.SetAssertionConfidence("F1003", 0.4, "radio")
+	store.Retract("F1003", "unconfirmed")
+	store.Tag("E1002", "unverified")
+	state, _ := kmac.NewStateAssertion("F3001", "E1002", "stock", "80%", time.Now())
+	store.AddStateAssertion(state)
+
+	if _, err := store.MergeEntities("E1001", "E1001"); err == nil {
+		t.Error("Expected merging an entity into itself to fail")
+	}
+	if _, err := store.MergeEntities("E1001", "E9999"); err == nil {
+		t.Error("Expected merging an unknown entity to fail")
+	}
+
+	report, err := store.MergeEntities("E1001", "E1002")
+	if err != nil {
+		t.Fatalf("MergeEntities failed: %v", err)
+	}
+	if strings.Join(report.Rewritten, ",") != "F1002,F1003" {
+		t.Errorf("Expected F1002 and F1003 rewritten, got %v", report.Rewritten)
+	}
+	if strings.Join(report.Properties, ",") != "manager" || len(report.Conflicts) != 1 {
+		t.Errorf("Expected manager taken and capacity in conflict, got %v %v", report.Properties, report.Conflicts)
+	}
+	if len(report.Warnings) != 1 || !strings.Contains(report.Warnings[0], "F1002 now repeats F1001") {
+		t.Errorf("Expected F1002 reported as a repeat, got %v", report.Warnings)
+	}
+
+	if _, err := store.GetEntity("E1002"); err == nil {
+		t.Error("Expected the duplicate removed")
+	}
+	if tombstone, _ := store.GetTombstone("E1002"); tombstone == nil || tombstone.Reason != "SAME_AS E1001" {
+		t.Errorf("Expected a SAME_AS tombstone, got %v", tombstone)
+	}
+	row, _ := store.assertions.row("F1003")
+	if object := store.assertions.object(row); object != "E1001" || !store.assertions.isRetracted(row) {
+		t.Errorf("Expected retracted F1003 rewritten too, got object %s", object)
+	}
+	if level, source := store.assertions.confidence(row); level != 0.4 || source != "radio" {
+		t.Errorf("Expected confidence kept through the merge, got %v %q", level, source)
+	}
+	entityRef, _ := store.GetEntity("E1001")
+	if value, _ := entityRef.KMACEntity.GetProperty("capacity"); value != "200t" {
+		t.Errorf("Expected the survivor's capacity kept, got %q", value)
+	}
+	if !entityRef.KMACEntity.HasTag("unverified") {
+		t.Error("Expected the duplicate's tag carried over")
+	}
+	if sameAs, _ := entityRef.KMACEntity.Annotation("same_as"); sameAs != "E1002" {
+		t.Errorf("Expected same_as annotation, got %q", sameAs)
+	}
+	if found := store.FindEntitiesByLabel("norht"); len(found) != 1 || found[0].KMACEntity.ID() != "E1001" {
+		t.Errorf("Expected the duplicate's label kept as a synonym, got %v", found)
+	}
+	if current, ok := store.CurrentState("E1001", "stock"); !ok || current.Value() != "80%" {
+		t.Errorf("Expected state history moved to the survivor, got %v", current)
+	}
+	store.CloseWAL()
+
+	replayed := NewSemanticStore()
+	if err := replayed.OpenWAL(path, WALOptions{}); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	defer replayed.CloseWAL()
+	if assertion, _ := replayed.GetAssertion("F1002"); assertion == nil || assertion.Subject() != "E1001" {
+		t.Errorf("Expected the merge replayed, got %v", assertion)
+	}
+}
+
+func TestSemanticStoreRelationUsage(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Depot", "")
+	store.AddEntity("E1002", "Hospital", "")
+	store.AddEntity("E1003", "Shelter", "")
+	store.AddRelation("R1001", "supplies", "LOGISTICS_CAPABILITY")
+	store.AddRelation("R1002", "supplied_by", "LOGISTICS_CAPABILITY")
+	store.AddRelation("R1003", "near", "spatial-relationship")
+	store.AddRelation("R1004", "staffs", "AGENT_OPERATION")
+	store.AddRelation("R1005", "owns", "RESOURCE_OWNERSHIP")
+	store.DeclareInverse("R1001", "R1002")
+	store.CreateAssertion("F1001", "E1001", "R1001", "E1002")
+	store.CreateAssertion("F1002", "E1001", "R1001", "E1003")
+	store.CreateAssertion("F1003", "E1002", "R1003", "E1003")
+	store.CreateAssertion("F1004", "E1003", "R1004", "E1002")
+	store.Retract("F1004", "shift ended")
+
+	usage := store.RelationUsage()
+	if len(usage) != 5 || usage[0].RelationID != "R1001" || usage[0].Assertions != 2 || usage[0].Subjects != 1 || usage[0].Objects != 2 {
+		t.Errorf("Expected R1001 most used, with 2 assertions from 1 subject to 2 objects, got %+v", usage)
+	}
+	if usage[1].RelationID != "R1003" || usage[2].RelationID != "R1002" {
+		t.Errorf("Expected ties in ID order, got %+v", usage)
+	}
+	if staffs := usage[3]; staffs.RelationID != "R1004" || staffs.Assertions != 0 || staffs.Retracted != 1 {
+		t.Errorf("Expected the retracted assertion counted apart, got %+v", staffs)
+	}
+
+	if unused := store.UnusedRelations(); strings.Join(unused, ",") != "R1004,R1005" {
+		t.Errorf("Expected R1004 and R1005 unused, with inverse R1002 kept, got %v", unused)
+	}
+	pruned, err := store.PruneRelations("unused")
+	if err != nil || len(pruned) != 2 {
+		t.Fatalf("Expected 2 relations pruned, got %v %v", pruned, err)
+	}
+	if _, err := store.GetRelation("R1005"); err == nil {
+		t.Error("Expected R1005 removed")
+	}
+	if tombstone, _ := store.GetTombstone("R1004"); tombstone == nil || tombstone.Reason != "unused" {
+		t.Errorf("Expected a tombstone for R1004, got %v", tombstone)
+	}
+
+	var conventions []string
+	for _, warning := range store.ValidateStore() {
+		if strings.Contains(warning, "upper snake case") {
+			conventions = append(conventions, warning)
+		}
+	}
+	if len(conventions) != 1 || !strings.Contains(conventions[0], "assertion F1003") {
+		t.Errorf("Expected a naming convention warning for F1003, got %v", conventions)
+	}
+}
+
+func TestSemanticStoreInvariants(t *testing.T) {
+	store := NewSemanticStore()
+	store.AddRelation("R1001", "located_in", "SPATIAL_RELATIONSHIP")
+	store.AddEntity("E1001", "Region North", "")
+
+	located := func(s *SemanticStore) []string {
+		var violations []string
+		for _, entityRef := range s.FindEntitiesByLabel("depot") {
+			if len(s.FindObjects(entityRef.KMACEntity.ID(), "R1001")) == 0 {
+				violations = append(violations, entityRef.KMACEntity.ID()+" has no location")
+			}
+		}
+		return violations
+	}
+	if err := store.RegisterInvariant(Invariant{Name: "depots located", Check: located}); err == nil {
+		t.Error("Expected an invalid invariant name to be rejected")
+	}
+	if err := store.RegisterInvariant(Invariant{Name: "no_check"}); err == nil {
+		t.Error("Expected an invariant without a check to be rejected")
+	}
+	if err := store.RegisterInvariant(Invariant{Name: "depots_located", Check: located, OnMutation: true}); err != nil {
+		t.Fatalf("RegisterInvariant failed: %v", err)
+	}
+	store.RegisterInvariant(Invariant{Name: "few_entities", Check: func(s *SemanticStore) []string {
+		if count := s.GetStatistics()["entities"]; count > 2 {
+			return []string{fmt.Sprintf("%d entities", count)}
+		}
+		return nil
+	}})
+
+	err := store.AddEntity("E1002", "Depot North", "")
+	var broken *InvariantError
+	if !errors.As(err, &broken) || len(broken.Violations) != 1 || broken.Violations[0] != "invariant depots_located: E1002 has no location" {
+		t.Fatalf("Expected the depot invariant to fail the mutation, got %v", err)
+	}
+	if _, err := store.GetEntity("E1002"); err != nil {
+		t.Error("Expected the mutation kept despite the violation")
+	}
+	if err := store.CreateAssertion("F1001", "E1002", "R1001", "E1001"); err != nil {
+		t.Errorf("Expected the fix to pass the invariant, got %v", err)
+	}
+
+	if err := store.AddEntity("E1003", "Shelter", ""); err != nil {
+		t.Errorf("Expected on-demand invariants not checked on mutation, got %v", err)
+	}
+	var violations []string
+	for _, warning := range store.ValidateStore() {
+		if strings.HasPrefix(warning, "invariant ") {
+			violations = append(violations, warning)
+		}
+	}
+	if len(violations) != 1 || violations[0] != "invariant few_entities: 3 entities" {
+		t.Errorf("Expected the on-demand invariant in the validation report, got %v", violations)
+	}
+	if checked := store.ReadSnapshot().ValidateStore(); !strings.Contains(strings.Join(checked, "\n"), "invariant few_entities") {
+		t.Errorf("Expected snapshots to keep the invariants, got %v", checked)
+	}
+
+	if !store.UnregisterInvariant("few_entities") || store.UnregisterInvariant("few_entities") {
+		t.Error("Expected UnregisterInvariant to report whether it was registered")
+	}
+	if violations := store.CheckInvariants(); len(violations) != 0 {
+		t.Errorf("Expected no violations left, got %v", violations)
+	}
+}
+
+// failingEngine is a storage engine whose writes fail while broken is set
+type failingEngine struct {
+	*MemoryEngine
+	broken bool
+}
+
+func (e *failingEngine) Put(ctx context.Context, record Record) error {
+	if e.broken {
+		return fmt.Errorf("disk full")
+	}
+	return e.MemoryEngine.Put(ctx, record)
+}
+
+func TestSemanticStoreStorageEngine(t *testing.T) {
+	engine := &failingEngine{MemoryEngine: NewMemoryEngine()}
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Depot", "10B3TR-DEP-WHS")
+	if err := store.AttachEngine(engine); err != nil {
+		t.Fatalf("AttachEngine failed: %v", err)
+	}
+	if err := store.AttachEngine(engine); err == nil {
+		t.Error("Expected a second engine to be rejected")
+	}
+	store.LoadKMAC(strings.NewReader("DEF_ENTITY #E1002 [Hospital] type=[]\nPROPERTY #E1002 [beds] value=[40]\n"))
+	store.AddRelation("R1001", "supplies", "LOGISTICS_CAPABILITY")
+	store.AddRelation("R1002", "supplied_by", "LOGISTICS_CAPABILITY")
+	store.DeclareInverse("R1001", "R1002")
+	store.CreateAssertion("F1001", "E1001", "R1001", "E1002")
+	store.CreateAssertion("F1002", "E1002", "R1002", "E1001")
+	store.SetAssertionConfidence("F1001", 0.75, "radio")
+	store.Retract("F1002", "duplicate")
+	store.Tag("E1002", "verified")
+	store.AddNote("E1002", "beds counted")
+	state, _ := kmac.NewStateAssertion("F3001", "E1002", "status", "open", time.Now())
+	store.AddStateAssertion(state)
+
+	ctx := context.Background()
+	if record, ok, _ := engine.Get(ctx, RecordEntity, "E1002"); !ok || record.Fields[PropertyFieldPrefix+"beds"] != "40" {
+		t.Errorf("Expected the loaded entity and its property in the engine, got %v", record)
+	}
+	if record, _, _ := engine.Get(ctx, RecordAssertion, "F1001"); record.Fields["confidence"] != "0.75" || record.Fields["source"] != "radio" {
+		t.Errorf("Expected the confidence written through, got %v", record)
+	}
+	var about []string
+	engine.Scan(ctx, RecordAssertion, &IndexHint{Field: "subject", Value: "E1002"}, func(record Record) bool {
+		about = append(about, record.ID)
+		return true
+	})
+	if strings.Join(about, ",") != "F1002" {
+		t.Errorf("Expected the hint to narrow the scan to F1002, got %v", about)
+	}
+
+	engine.broken = true
+	if err := store.AddEntity("E1003", "Shelter", ""); err == nil {
+		t.Error("Expected a failed write to fail the mutation")
+	}
+	engine.broken = false
+	store.SetEntityVector("E1003", []float32{1, 0.5})
+	store.RemoveEntity("E1001", "closed")
+	if _, ok, _ := engine.Get(ctx, RecordEntity, "E1003"); !ok {
+		t.Error("Expected the failed write retried by the next mutation")
+	}
+	if _, ok, _ := engine.Get(ctx, RecordEntity, "E1001"); ok {
+		t.Error("Expected the removed entity deleted from the engine")
+	}
+	if _, ok, _ := engine.Get(ctx, RecordAssertion, "F1001"); ok {
+		t.Error("Expected the removed entity's assertions deleted from the engine")
+	}
+
+	reopened := NewSemanticStore()
+	if err := reopened.AttachEngine(engine); err != nil {
+		t.Fatalf("AttachEngine failed: %v", err)
+	}
+	if len(reopened.entities) != 1 || !reopened.ThawsOnRead() {
+		t.Errorf("Expected only the entity with a vector read on attaching, got %d", len(reopened.entities))
+	}
+	if stats := reopened.GetStatistics(); stats["entities"] != 2 || stats["retracted_assertions"] != 1 {
+		t.Errorf("Expected the engine's statements counted, got %v", stats)
+	}
+	entityRef, err := reopened.GetEntity("E1002")
+	if err != nil {
+		t.Fatalf("Expected the engine's entities loaded, got %v", err)
+	}
+	if beds, _ := entityRef.KMACEntity.GetProperty("beds"); beds != "40" {
+		t.Errorf("Expected the property loaded, got %q", beds)
+	}
+	if inverse, _ := reopened.Inverse("R1001"); inverse != "R1002" {
+		t.Errorf("Expected the inverse declaration loaded, got %q", inverse)
+	}
+	if retraction, ok := reopened.GetRetraction("F1002"); !ok || retraction.Reason != "duplicate" {
+		t.Errorf("Expected the retraction loaded, got %v", retraction)
+	}
+	if _, err := reopened.GetEntity("E1001"); err == nil {
+		t.Error("Expected the removed entity to stay removed")
+	}
+	if tombstone, ok := reopened.GetTombstone("E1001"); !ok || tombstone.Reason != "closed" {
+		t.Errorf("Expected the tombstone loaded, got %v", tombstone)
+	}
+	if meta, _ := reopened.GetMetadata("E1002"); !meta.HasTag("verified") || len(meta.Notes()) != 1 {
+		t.Errorf("Expected the metadata loaded, got %v", meta)
+	}
+	if current, ok := reopened.CurrentState("E1002", "status"); !ok || current.Value() != "open" {
+		t.Errorf("Expected the state history loaded, got %v", current)
+	}
+	if vector, ok := reopened.EntityVector("E1003"); !ok || vector[1] != 0.5 {
+		t.Errorf("Expected the vector loaded, got %v", vector)
+	}
+}
+
+func TestSemanticStoreEngineEviction(t *testing.T) {
+	engine := NewMemoryEngine()
+	store := NewSemanticStore()
+	store.AttachEngine(engine)
+	store.SetLimits(&StoreLimits{MaxEntities: 2})
+	store.AddRelation("R1001", "supplies", "LOGISTICS_CAPABILITY")
+	for i := 1; i <= 4; i++ {
+		store.AddEntity(fmt.Sprintf("E100%d", i), fmt.Sprintf("Site %d", i), "")
+		if i > 1 {
+			store.CreateAssertion(fmt.Sprintf("F100%d", i), fmt.Sprintf("E100%d", i-1), "R1001", fmt.Sprintf("E100%d", i))
+		}
+		if i == 2 {
+			store.Tag("F1002", "checked")
+		}
+	}
+
+	if len(store.entities) > 2 {
+		t.Errorf("Expected at most 2 entities in memory, got %d", len(store.entities))
+	}
+	if stats := store.GetStatistics(); stats["entities"] != 4 || stats["assertions"] != 3 {
+		t.Errorf("Expected the evicted statements still counted, got %v", stats)
+	}
+	assertions := store.FindAssertionsBySubject("E1001")
+	if len(assertions) != 1 || !assertions[0].HasTag("checked") {
+		t.Fatalf("Expected the evicted assertion read back with its tag, got %v", assertions)
+	}
+	if _, err := store.GetEntity("E1002"); err != nil {
+		t.Errorf("Expected the evicted entity read back, got %v", err)
+	}
+	if warnings := store.ValidateStore(); len(warnings) != 0 {
+		t.Errorf("Expected the engine's statements validated with the store's, got %v", warnings)
+	}
+	if err := store.DetachEngine(); err != nil {
+		t.Fatalf("DetachEngine failed: %v", err)
+	}
+	if len(store.entities) != 4 {
+		t.Errorf("Expected detaching to read every entity back, got %d", len(store.entities))
+	}
+}
+
+// countingEngine is a storage engine that records the keys written to it
+type countingEngine struct {
+	*MemoryEngine
+	written []string
+}
+
+func (e *countingEngine) Put(ctx context.Context, record Record) error {
+	e.written = append(e.written, record.ID)
+	return e.MemoryEngine.Put(ctx, record)
+}
+
+func (e *countingEngine) Delete(ctx context.Context, kind string, id string) error {
+	e.written = append(e.written, "-"+id)
+	return e.MemoryEngine.Delete(ctx, kind, id)
+}
+
+func TestSemanticStoreEngineChangedRecords(t *testing.T) {
+	engine := &countingEngine{MemoryEngine: NewMemoryEngine()}
+	store := NewSemanticStore()
+	if err := store.AttachEngine(engine); err != nil {
+		t.Fatalf("AttachEngine failed: %v", err)
+	}
+	for i := 1; i <= 4; i++ {
+		store.AddEntity(fmt.Sprintf("E100%d", i), fmt.Sprintf("Site %d", i), "")
+	}
+	store.AddRelation("R1001", "supplies", "LOGISTICS_CAPABILITY")
+	store.CreateAssertion("F1001", "E1001", "R1001", "E1002")
+	store.CreateAssertion("F1002", "E1002", "R1001", "E1003")
+	store.CreateDerivedAssertion("F1003", "E1001", "R1001", "E1003", []string{"F1001", "F1002"})
+	store.CreateAssertion("F1004", "F1001", "R1001", "E1004")
+
+	engine.written = nil
+	store.SetAssertionConfidence("F1001", 0.5, "radio")
+	if got := strings.Join(engine.written, ","); got != "F1001,F1003" {
+		t.Errorf("Expected only the assertion and what was derived from it written, got %s", got)
+	}
+	engine.written = nil
+	store.Tag("E1001", "urgent")
+	if got := strings.Join(engine.written, ","); got != "E1001" {
+		t.Errorf("Expected a tag to write only the tagged entity, got %s", got)
+	}
+
+	// Whatever the mutation, the engine ends up holding what the store
+	// holds, in memory or evicted from it
+	matches := func(when string) {
+		t.Helper()
+		view, err := store.readView(context.Background())
+		if err != nil {
+			t.Fatalf("After %s, failed to read the store: %v", when, err)
+		}
+		want := make(map[string]bool)
+		view.eachRecordKey(func(kind string, id string) {
+			want[recordKey(kind, id)] = true
+			record, _ := view.record(kind, id)
+			if held, ok, _ := engine.Get(context.Background(), kind, id); !ok || !reflect.DeepEqual(held.Fields, record.Fields) {
+				t.Errorf("After %s, expected the engine to hold %s %v, got %v", when, id, record.Fields, held.Fields)
+			}
+		})
+		for _, kind := range recordKinds {
+			engine.Scan(context.Background(), kind, nil, func(record Record) bool {
+				if !want[recordKey(kind, record.ID)] {
+					t.Errorf("After %s, expected %s %s deleted from the engine", when, kind, record.ID)
+				}
+				return true
+			})
+		}
+	}
+	store.MergeEntities("E1003", "E1004")
+	matches("a merge")
+	store.RemoveAssertion("F1002", "wrong")
+	matches("a removal")
+	store.Compact()
+	matches("a compaction")
+	store.SetLimits(&StoreLimits{MaxEntities: 2})
+	store.AddEntity("E1005", "Site 5", "")
+	matches("an eviction")
+	store.Clear()
+	matches("a clear")
+	if len(engine.records[RecordEntity]) != 0 {
+		t.Errorf("Expected a clear to empty the engine, got %v", engine.records[RecordEntity])
+	}
+}
+
+func BenchmarkEngineSetConfidence(b *testing.B) {
+	for _, size := range []int{1000, 10000} {
+		b.Run(fmt.Sprintf("assertions=%d", size), func(b *testing.B) {
+			store := NewSemanticStore()
+			store.AttachEngine(NewMemoryEngine())
+			store.AddEntity("E1001", "Depot", "")
+			store.AddRelation("R1001", "supplies", "LOGISTICS_CAPABILITY")
+			for i := 0; i < size; i++ {
+				store.CreateAssertion(fmt.Sprintf("F%d", i), "E1001", "R1001", "E1001")
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				store.SetAssertionConfidence("F0", float64(i%10)/10, "radio")
+			}
+		})
+	}
+}
+
+// batchingEngine is a storage engine that counts the batches written to it
+type batchingEngine struct {
+	*MemoryEngine
+	batches int
+}
+
+func (e *batchingEngine) WriteBatch(ctx context.Context, puts []Record, deletes []Record) error {
+	e.batches++
+	for _, record := range puts {
+		e.Put(ctx, record)
+	}
+	for _, record := range deletes {
+		e.Delete(ctx, record.Kind, record.ID)
+	}
+	return nil
+}
+
+func TestSemanticStoreWriteBatching(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.wal")
+	store := NewSemanticStore()
+	if err := store.OpenWAL(path, WALOptions{Sync: true, Batch: BatchPolicy{MaxRecords: 3}}); err != nil {
+		t.Fatalf("OpenWAL failed: %v", err)
+	}
+	logged := func() int {
+		data, _ := os.ReadFile(path)
+		return strings.Count(string(data), "\n")
+	}
+	store.AddEntity("E1001", "Depot", "")
+	store.AddEntity("E1002", "Hospital", "")
+	if n := logged(); n != 0 {
+		t.Errorf("Expected the records held until the batch is full, got %d logged", n)
+	}
+	store.AddRelation("R1001", "supplies", "LOGISTICS_CAPABILITY")
+	if n := logged(); n != 3 {
+		t.Errorf("Expected the full batch written, got %d logged", n)
+	}
+	store.CreateAssertion("F1001", "E1001", "R1001", "E1002")
+	if err := store.FlushWAL(); err != nil {
+		t.Fatalf("FlushWAL failed: %v", err)
+	}
+	if n := logged(); n != 4 {
+		t.Errorf("Expected the flush to write the held record, got %d logged", n)
+	}
+	store.AddEntity("E1003", "Shelter", "")
+	if err := store.CloseWAL(); err != nil {
+		t.Fatalf("CloseWAL failed: %v", err)
+	}
+	replayed := NewSemanticStore()
+	if err := replayed.OpenWAL(path, WALOptions{}); err != nil {
+		t.Fatalf("OpenWAL failed: %v", err)
+	}
+	if _, err := replayed.GetEntity("E1003"); err != nil {
+		t.Errorf("Expected closing to write the held record, got %v", err)
+	}
+	replayed.CloseWAL()
+
+	engine := &batchingEngine{MemoryEngine: NewMemoryEngine()}
+	store = NewSemanticStore()
+	if err := store.SetEngineBatch(BatchPolicy{MaxRecords: 2}); err == nil {
+		t.Error("Expected a batch policy without an engine to be rejected")
+	}
+	if err := store.AttachEngine(engine); err != nil {
+		t.Fatalf("AttachEngine failed: %v", err)
+	}
+	if err := store.SetEngineBatch(BatchPolicy{MaxRecords: -1}); err == nil {
+		t.Error("Expected a negative batch size to be rejected")
+	}
+	store.SetEngineBatch(BatchPolicy{MaxRecords: 2, MaxDelay: time.Hour})
+	ctx := context.Background()
+	store.AddEntity("E1001", "Depot", "")
+	if _, ok, _ := engine.Get(ctx, RecordEntity, "E1001"); ok {
+		t.Error("Expected the entity held until the batch is full")
+	}
+	store.AddEntity("E1002", "Hospital", "")
+	if _, ok, _ := engine.Get(ctx, RecordEntity, "E1001"); !ok || engine.batches != 1 {
+		t.Errorf("Expected both entities written in one batch, got %d batches", engine.batches)
+	}
+	store.AddRelation("R1001", "supplies", "LOGISTICS_CAPABILITY")
+	if err := store.DetachEngine(); err != nil {
+		t.Fatalf("DetachEngine failed: %v", err)
+	}
+	if _, ok, _ := engine.Get(ctx, RecordRelation, "R1001"); !ok || engine.batches != 2 {
+		t.Errorf("Expected detaching to write the held relation, got %d batches", engine.batches)
+	}
+
+	// An idle store writes its held records once MaxDelay has passed
+	eventually := func(written func() bool) bool {
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+			if written() {
+				return true
+			}
+		}
+		return false
+	}
+	path = filepath.Join(t.TempDir(), "idle.wal")
+	memory := NewMemoryEngine()
+	store = NewSemanticStore()
+	store.OpenWAL(path, WALOptions{Batch: BatchPolicy{MaxRecords: 100, MaxDelay: 20 * time.Millisecond}})
+	store.AttachEngine(memory)
+	store.SetEngineBatch(BatchPolicy{MaxRecords: 100, MaxDelay: 20 * time.Millisecond})
+	store.AddEntity("E1001", "Depot", "")
+	store.AddEntity("E1002", "Hospital", "")
+	if !eventually(func() bool { return logged() == 2 }) {
+		t.Errorf("Expected the idle log flushed after MaxDelay, got %d logged", logged())
+	}
+	if !eventually(func() bool { _, ok, _ := memory.Get(ctx, RecordEntity, "E1002"); return ok }) {
+		t.Error("Expected the idle engine written after MaxDelay")
+	}
+	store.AddEntity("E1003", "Shelter", "")
+	if err := store.CloseWAL(); err != nil || logged() != 3 {
+		t.Errorf("Expected closing to write the next batch, got %d logged and %v", logged(), err)
+	}
+	if err := store.DetachEngine(); err != nil {
+		t.Fatalf("DetachEngine failed: %v", err)
+	}
+	if _, ok, _ := memory.Get(ctx, RecordEntity, "E1003"); !ok {
+		t.Error("Expected detaching to write the next batch")
+	}
+}
+
+func TestSemanticStoreTiering(t *testing.T) {
+	dir := t.TempDir()
+	store := NewSemanticStore()
+	store.AddEntity("E1001", "Depot", "")
+	store.AddEntity("E1002", "Hospital", "")
+	store.AddEntity("E1003", "Shelter", "")
+	store.AddRelation("R1001", "supplies", "LOGISTICS_CAPABILITY")
+	store.CreateAssertion("F1001", "E1001", "R1001", "E1002")
+	store.SetAssertionConfidence("F1001", 0.5, "radio")
+	store.Tag("E1001", "north")
+	store.AddNote("F1001", "weekly")
+	store.SetEntityVector("E1003", []float32{1, 0})
+
+	if _, err := store.FreezeColdEntities(0); err == nil {
+		t.Error("Expected freezing without tiering to be rejected")
+	}
+	if err := store.EnableTiering(TieringOptions{Dir: dir}); err != nil {
+		t.Fatalf("EnableTiering failed: %v", err)
+	}
+	if err := store.AttachEngine(NewMemoryEngine()); err == nil {
+		t.Error("Expected a storage engine to be rejected while tiering")
+	}
+	frozen, err := store.FreezeColdEntities(0)
+	if err != nil {
+		t.Fatalf("FreezeColdEntities failed: %v", err)
+	}
+	stats := store.TieringStats()
+	if frozen != 2 || stats.HotEntities != 1 || stats.ColdEntities != 2 || stats.ColdAssertions != 1 || stats.Segments != 1 {
+		t.Errorf("Expected the entity with a vector kept in memory, got %d frozen and %+v", frozen, stats)
+	}
+	if segments, _ := filepath.Glob(filepath.Join(dir, "*.gz")); len(segments) != 1 {
+		t.Errorf("Expected one segment written, got %v", segments)
+	}
+	if _, exists := store.entities["E1001"]; exists {
+		t.Error("Expected the frozen entity dropped from memory")
+	}
+
+	assertions := store.FindAssertionsForEntity("E1002")
+	if len(assertions) != 1 || assertions[0].ID() != "F1001" {
+		t.Fatalf("Expected the frozen assertion loaded on demand, got %v", assertions)
+	}
+	if stats := store.TieringStats(); stats.ColdEntities != 0 || stats.Segments != 0 || stats.Thawed != 2 {
+		t.Errorf("Expected both groups thawed and the segment removed, got %+v", stats)
+	}
+	if segments, _ := filepath.Glob(filepath.Join(dir, "*.gz")); len(segments) != 0 {
+		t.Errorf("Expected the empty segment removed, got %v", segments)
+	}
+	if meta, _ := store.GetMetadata("E1001"); !meta.HasTag("north") {
+		t.Error("Expected the entity's tags kept through cold storage")
+	}
+	if meta, _ := store.GetMetadata("F1001"); len(meta.Notes()) != 1 {
+		t.Error("Expected the assertion's notes kept through cold storage")
+	}
+	row, _ := store.assertions.row("F1001")
+	if level, source := store.assertions.confidence(row); level != 0.5 || source != "radio" {
+		t.Errorf("Expected the confidence kept through cold storage, got %v from %q", level, source)
+	}
+
+	store = NewSemanticStore()
+	store.AddRelation("R1001", "supplies", "LOGISTICS_CAPABILITY")
+	store.EnableTiering(TieringOptions{Dir: t.TempDir(), MaxHotEntities: 4})
+	for i := 1; i <= 5; i++ {
+		store.AddEntity(fmt.Sprintf("E200%d", i), "Depot", "")
+	}
+	if stats := store.TieringStats(); stats.HotEntities != 3 || stats.ColdEntities != 2 {
+		t.Errorf("Expected the store frozen down to 3 entities, got %+v", stats)
+	}
+	if _, err := store.GetEntity("E2001"); err != nil {
+		t.Errorf("Expected the least recently added entity loaded on demand, got %v", err)
+	}
+	if err := store.CreateAssertion("F2001", "E2002", "R1001", "E2005"); err != nil {
+		t.Errorf("Expected a frozen subject loaded to check the assertion, got %v", err)
+	}
+	if err := store.DisableTiering(); err != nil {
+		t.Fatalf("DisableTiering failed: %v", err)
+	}
+	if len(store.entities) != 5 {
+		t.Errorf("Expected every entity back in memory, got %d", len(store.entities))
+	}
+	if _, err := store.GetAssertion("F2001"); err != nil {
+		t.Errorf("Expected the assertion back in memory, got %v", err)
+	}
+
+	// Segments left by an earlier run are skipped, and whole-store reads see
+	// frozen statements
+	dir = t.TempDir()
+	os.WriteFile(filepath.Join(dir, "segment-000001.gz"), []byte("stale"), 0o644)
+	store = NewSemanticStore()
+	store.AddEntity("E3001", "Field hospital", "10B3MD-FAC-HSP")
+	store.AddEntity("E3002", "Hospital", "")
+	store.AddEntity("E3003", "Shelter", "")
+	store.AddRelation("R1001", "supplies", "LOGISTICS_CAPABILITY")
+	store.AddRelation("R1002", "reported_by", "PROVENANCE")
+	store.CreateAssertion("F3001", "E3003", "R1001", "E3002")
+	store.CreateAssertion("F3002", "E3001", "R1002", "F3001")
+	if err := store.EnableTiering(TieringOptions{Dir: dir}); err != nil {
+		t.Fatalf("EnableTiering failed: %v", err)
+	}
+	// E3001 first takes F3002, which refers to F3001 in E3002's group
+	store.GetEntity("E3002")
+	store.GetEntity("E3003")
+	if frozen, err := store.FreezeColdEntities(0); err != nil || frozen != 3 {
+		t.Fatalf("Expected every entity frozen past the stale segment, got %d %v", frozen, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "segment-000001.gz")); string(data) != "stale" {
+		t.Error("Expected the stale segment left alone")
+	}
+	if stats := store.GetStatistics(); stats["entities"] != 3 || stats["assertions"] != 2 || stats["taxonomy_10"] != 1 {
+		t.Errorf("Expected statistics to count frozen statements, got %v", stats)
+	}
+	if stats := store.TieringStats(); stats.ColdEntities != 3 {
+		t.Fatalf("Expected statistics read without thawing, got %+v", stats)
+	}
+	snapshot := store.ReadSnapshot()
+	if _, err := snapshot.GetAssertion("F3002"); err != nil {
+		t.Errorf("Expected the snapshot to hold frozen statements, got %v", err)
+	}
+	if stats := store.TieringStats(); stats.ColdEntities != 3 {
+		t.Errorf("Expected a snapshot to leave the store frozen, got %+v", stats)
+	}
+	if unused := store.UnusedRelations(); len(unused) != 0 {
+		t.Errorf("Expected relations of frozen assertions to be in use, got %v", unused)
+	}
+	var buf bytes.Buffer
+	if err := store.WriteKMAC(&buf); err != nil {
+		t.Fatalf("WriteKMAC failed: %v", err)
+	}
+	loaded := NewSemanticStore()
+	if err := loaded.LoadKMAC(&buf); err != nil {
+		t.Fatalf("Expected the export of a tiered store to load, got %v", err)
+	}
+	if _, err := loaded.GetAssertion("F3002"); err != nil {
+		t.Errorf("Expected frozen assertions exported, got %v", err)
+	}
+	if found := store.FindEntitiesByLabel("hospital"); len(found) != 2 {
+		t.Errorf("Expected frozen entities found by label, got %d", len(found))
+	}
+	if warnings := store.ValidateStore(); len(warnings) != 0 {
+		t.Errorf("Expected a frozen store to validate, got %v", warnings)
+	}
+	if statements := store.Statements(); len(statements) != 7 {
+		t.Errorf("Expected frozen statements exported, got %d", len(statements))
+	}
+	ranged := 0
+	store.RangeEntities(func(*EntityReference) bool { ranged++; return true })
+	if ranged != 3 {
+		t.Errorf("Expected frozen entities ranged over, got %d", ranged)
+	}
+	if stats := store.TieringStats(); stats.ColdEntities != 3 || stats.Thawed != 0 {
+		t.Errorf("Expected whole-store reads to leave the store frozen, got %+v", stats)
+	}
+
+	// Reads that cannot get at cold storage fail rather than leave it out
+	segments, _ := filepath.Glob(filepath.Join(dir, "segment-*.gz"))
+	for _, segment := range segments {
+		if filepath.Base(segment) != "segment-000001.gz" {
+			os.Remove(segment)
+		}
+	}
+	if err := store.RangeEntitiesContext(context.Background(), func(*EntityReference) bool { return true }); err == nil {
+		t.Error("Expected ranging over a missing segment to fail")
+	}
+	if _, err := store.ReadSnapshotContext(context.Background()); err == nil {
+		t.Error("Expected a snapshot of a missing segment to fail")
+	}
+	if _, err := store.StatementsContext(context.Background()); err == nil {
+		t.Error("Expected exporting a missing segment to fail")
+	}
+	if _, err := store.FindAssertionsBySubjectContext(context.Background(), "E3003"); err == nil {
+		t.Error("Expected finding assertions in a missing segment to fail")
+	}
+
+	// Traversals load each hop's frozen statements before expanding it
+	store = NewSemanticStore()
+	store.AddEntity("E1001", "Depot", "")
+	store.AddEntity("E1002", "Hospital", "")
+	store.AddRelation("R1001", "supplies", "LOGISTICS_CAPABILITY")
+	store.CreateAssertion("F1001", "E1001", "R1001", "E1002")
+	store.EnableTiering(TieringOptions{Dir: t.TempDir()})
+	if frozen, err := store.FreezeColdEntities(0); err != nil || frozen != 2 {
+		t.Fatalf("Expected both entities frozen, got %d %v", frozen, err)
+	}
+	reached, err := store.TraverseParallel(context.Background(), []string{"E1001", "E1002"}, 2, ParallelQueryOptions{})
+	if err != nil {
+		t.Fatalf("TraverseParallel failed: %v", err)
+	}
+	if !reflect.DeepEqual(reached["E1001"], []string{"E1002"}) || !reflect.DeepEqual(reached["E1002"], []string{"E1001"}) {
+		t.Errorf("Expected frozen entities reached across the assertion, got %v", reached)
+	}
+}
@@ -0,0 +1,191 @@
+package semantic
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+// AddSituation adds a situation grouping the assertions that describe one
+// scenario. parentID names the situation it is part of, which must already
+// be in the store, or is empty for a top-level situation.
+func (s *SemanticStore) AddSituation(id string, label string, parentID string) error {
+	s.own()
+	situation, err := kmac.NewSituation(id, label, parentID)
+	if err != nil {
+		return fmt.Errorf("failed to create KMAC situation: %v", err)
+	}
+	if err := s.addSituation(situation); err != nil {
+		return err
+	}
+	return s.journal(walSituation, id, label, parentID)
+}
+
+// addSituation stores a situation, checking that its parent exists and that
+// it would not end up within itself
+func (s *SemanticStore) addSituation(situation *kmac.Situation) error {
+	for parentID := situation.ParentID(); parentID != ""; parentID = s.situations[parentID].ParentID() {
+		if _, exists := s.situations[parentID]; !exists {
+			return fmt.Errorf("situation %s not found", parentID)
+		}
+		if parentID == situation.ID() {
+			return fmt.Errorf("situation %s would be within itself", situation.ID())
+		}
+	}
+	s.situations[situation.ID()] = situation
+	return nil
+}
+
+// GetSituation retrieves a situation by ID
+func (s *SemanticStore) GetSituation(id string) (*kmac.Situation, error) {
+	situation, exists := s.situations[id]
+	if !exists {
+		return nil, fmt.Errorf("situation %s not found", id)
+	}
+	return situation, nil
+}
+
+// AddToSituation adds assertions to a situation. An assertion may belong to
+// several situations; adding one twice has no effect.
+func (s *SemanticStore) AddToSituation(situationID string, assertionIDs ...string) error {
+	s.own()
+	if _, exists := s.situations[situationID]; !exists {
+		return fmt.Errorf("situation %s not found", situationID)
+	}
+	for _, id := range assertionIDs {
+		if _, err := s.GetAssertion(id); err != nil {
+			return err
+		}
+	}
+
+	for _, id := range assertionIDs {
+		if !s.inSituation(situationID, id) {
+			s.situationMembers[situationID] = append(s.situationMembers[situationID], id)
+		}
+	}
+	return s.journal(walInSituation, append([]string{situationID}, assertionIDs...)...)
+}
+
+// inSituation reports whether an assertion was added to a situation itself
+func (s *SemanticStore) inSituation(situationID string, assertionID string) bool {
+	for _, id := range s.situationMembers[situationID] {
+		if id == assertionID {
+			return true
+		}
+	}
+	return false
+}
+
+// SubSituations returns the situations directly within a situation, in ID
+// order
+func (s *SemanticStore) SubSituations(situationID string) []*kmac.Situation {
+	var children []*kmac.Situation
+	for _, id := range sortedIDs(s.situations) {
+		if situation := s.situations[id]; situation.ParentID() == situationID {
+			children = append(children, situation)
+		}
+	}
+	return children
+}
+
+// FindAssertionsInSituation returns the live assertions of a situation, in
+// the order they were added. With nested, assertions of the situations
+// within it are included too, each once.
+func (s *SemanticStore) FindAssertionsInSituation(situationID string, nested bool) []*kmac.Assertion {
+	var results []*kmac.Assertion
+	seen := make(map[string]bool)
+	var collect func(id string)
+	collect = func(id string) {
+		for _, assertionID := range s.situationMembers[id] {
+			if seen[assertionID] {
+				continue
+			}
+			seen[assertionID] = true
+			if assertion, err := s.GetAssertion(assertionID); err == nil {
+				results = append(results, assertion)
+			}
+		}
+		if nested {
+			for _, child := range s.SubSituations(id) {
+				collect(child.ID())
+			}
+		}
+	}
+	collect(situationID)
+	return results
+}
+
+// SituationsOf returns the IDs of the situations an assertion was added to,
+// in order
+func (s *SemanticStore) SituationsOf(assertionID string) []string {
+	var ids []string
+	for _, id := range sortedIDs(s.situationMembers) {
+		if s.inSituation(id, assertionID) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// forgetSituationMember drops an assertion from every situation
+func (s *SemanticStore) forgetSituationMember(assertionID string) {
+	for id, members := range s.situationMembers {
+		kept := members[:0]
+		for _, member := range members {
+			if member != assertionID {
+				kept = append(kept, member)
+			}
+		}
+		if len(kept) == 0 {
+			delete(s.situationMembers, id)
+		} else {
+			s.situationMembers[id] = kept
+		}
+	}
+}
+
+// sortedSituations returns the stored situations in ID order, except that
+// each follows the situation it is within
+func (s *SemanticStore) sortedSituations() []*kmac.Situation {
+	var ordered []*kmac.Situation
+	placed := make(map[string]bool)
+	var place func(situation *kmac.Situation)
+	place = func(situation *kmac.Situation) {
+		if placed[situation.ID()] {
+			return
+		}
+		placed[situation.ID()] = true
+		if parent, exists := s.situations[situation.ParentID()]; exists {
+			place(parent)
+		}
+		ordered = append(ordered, situation)
+	}
+	for _, id := range sortedIDs(s.situations) {
+		place(s.situations[id])
+	}
+	return ordered
+}
+
+// loadSituations adds decoded situations to the store, parents before the
+// situations within them
+func (s *SemanticStore) loadSituations(situations []*kmac.Situation) error {
+	sort.SliceStable(situations, func(i, j int) bool { return situations[i].ID() < situations[j].ID() })
+	for len(situations) > 0 {
+		var waiting []*kmac.Situation
+		for _, situation := range situations {
+			if _, exists := s.situations[situation.ParentID()]; situation.ParentID() != "" && !exists {
+				waiting = append(waiting, situation)
+				continue
+			}
+			if err := s.addSituation(situation); err != nil {
+				return fmt.Errorf("situation %s: %v", situation.ID(), err)
+			}
+		}
+		if len(waiting) == len(situations) {
+			return fmt.Errorf("situation %s: situation %s not found", waiting[0].ID(), waiting[0].ParentID())
+		}
+		situations = waiting
+	}
+	return nil
+}
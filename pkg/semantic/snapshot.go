@@ -0,0 +1,268 @@
+package semantic
+
+import (
+	"context"
+	"maps"
+	"time"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+// Snapshot is an immutable view of a store as it was when the snapshot was
+// taken. Writes to the store after that are not seen, and a snapshot is safe
+// for concurrent reads, so long-running exports and analytics can run on one
+// without holding the writers up.
+type Snapshot struct {
+	store   *SemanticStore
+	takenAt time.Time
+	cursor  string
+}
+
+// ReadSnapshot takes an immutable view of the store. The snapshot shares
+// the store's statements rather than copying them, and the store copies
+// what it shares before it next writes, so taking a snapshot costs the
+// same however much the store holds, and reads on the snapshot need no
+// locking. Until the store next writes, the entities and other statements
+// its getters return are the snapshot's too, so change them through the
+// store rather than in place. Entity vectors are not included. Statements
+// in cold storage or only in a storage engine are copied into the snapshot
+// from their segments or the engine, so the snapshot holds every statement
+// in memory while the store leaves them there. Statements that cannot be
+// read are left out; ReadSnapshotContext reports them.
+func (s *SemanticStore) ReadSnapshot() *Snapshot {
+	sn, _ := s.snapshot(context.Background())
+	return sn
+}
+
+// ReadSnapshotContext takes an immutable view of the store as ReadSnapshot
+// does, returning an error if a frozen statement cannot be read or ctx is
+// done before the frozen statements are copied
+func (s *SemanticStore) ReadSnapshotContext(ctx context.Context) (*Snapshot, error) {
+	sn, err := s.snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sn, nil
+}
+
+// snapshot takes a snapshot, returning it with the frozen statements copied
+// before one failed to be read
+func (s *SemanticStore) snapshot(ctx context.Context) (*Snapshot, error) {
+	c := s.share()
+	c.readOnly = true
+	err := s.copyColdInto(ctx, c)
+	return &Snapshot{store: c, takenAt: time.Now(), cursor: s.ChangeCursor()}, err
+}
+
+// share returns an unbounded store holding the same statements as s
+// without copying them. Both copy the statements before they next write
+// to them, so neither sees the other's changes.
+func (s *SemanticStore) share() *SemanticStore {
+	c := *s
+	c.limits, c.tiering, c.engine, c.wal, c.feed = nil, nil, nil, nil, nil
+	c.vectors, c.vectorDims, c.vectorIndex, c.sampler = make(map[string]entityVector), 0, nil, nil
+	c.entityAccess, c.assertionAccess = newAccessOrder(), newAccessOrder()
+	c.queries, c.invariants = maps.Clone(s.queries), maps.Clone(s.invariants)
+	c.nested = 0
+	c.symbols = s.symbols.share()
+	c.assertions = s.assertions.share(c.symbols)
+	c.shared = true
+	if !s.shared {
+		s.shared = true
+	}
+	return &c
+}
+
+// own copies the statements the store shares with a snapshot before it
+// writes to them. Mutations call it first, as do reads that load frozen
+// statements back. The assertion table and its symbols copy themselves
+// when they are first written.
+func (s *SemanticStore) own() {
+	if !s.shared {
+		return
+	}
+	s.shared = false
+	s.tosids = s.tosids.Clone()
+	s.entities = cloneValues(s.entities, func(entityRef *EntityReference) *EntityReference {
+		return &EntityReference{KMACEntity: entityRef.KMACEntity.Clone(), TOSIDObj: entityRef.TOSIDObj}
+	})
+	s.relations = cloneValues(s.relations, (*kmac.Relation).Clone)
+	s.properties = maps.Clone(s.properties)
+	s.states = cloneValues(s.states, (*kmac.StateHistory).Clone)
+	s.retractions = cloneValues(s.retractions, func(retraction *Retraction) *Retraction {
+		copied := *retraction
+		return &copied
+	})
+	s.derivations = cloneValues(s.derivations, cloneStrings)
+	s.dependents = cloneValues(s.dependents, cloneStrings)
+	s.times = cloneValues(s.times, (*kmac.TimeReference).Clone)
+	s.temporals = cloneValues(s.temporals, (*kmac.Temporal).Clone)
+	s.pending = cloneValues(s.pending, cloneStrings)
+	s.assertionMeta = cloneValues(s.assertionMeta, func(meta *kmac.Metadata) *kmac.Metadata {
+		copied := &kmac.Metadata{}
+		copied.Merge(meta)
+		return copied
+	})
+	s.tombstones = cloneValues(s.tombstones, func(tombstone *Tombstone) *Tombstone {
+		copied := *tombstone
+		return &copied
+	})
+	s.removedEntities = maps.Clone(s.removedEntities)
+	s.removedRelations = maps.Clone(s.removedRelations)
+	s.assertedAt = maps.Clone(s.assertedAt)
+	s.situations = cloneValues(s.situations, (*kmac.Situation).Clone)
+	s.situationMembers = cloneValues(s.situationMembers, cloneStrings)
+	s.evidence = cloneValues(s.evidence, (*kmac.Evidence).Clone)
+	s.syncCursors = maps.Clone(s.syncCursors)
+}
+
+// cloneValues returns a copy of a map with each value copied by clone
+func cloneValues[V any](m map[string]V, clone func(V) V) map[string]V {
+	c := make(map[string]V, len(m))
+	for key, value := range m {
+		c[key] = clone(value)
+	}
+	return c
+}
+
+// cloneStrings returns a copy of a slice of strings
+func cloneStrings(values []string) []string {
+	return append([]string(nil), values...)
+}
+
+// TakenAt returns when the snapshot was taken
+func (sn *Snapshot) TakenAt() time.Time {
+	return sn.takenAt
+}
+
+// Cursor returns the store's change feed cursor when the snapshot was taken,
+// or "" if the store had no change feed. A follower loaded from the snapshot
+// pulls the changes since it.
+func (sn *Snapshot) Cursor() string {
+	return sn.cursor
+}
+
+// GetEntity retrieves an entity as it was when the snapshot was taken
+func (sn *Snapshot) GetEntity(id string) (*EntityReference, error) {
+	return sn.store.GetEntity(id)
+}
+
+// GetRelation retrieves a relation as it was when the snapshot was taken
+func (sn *Snapshot) GetRelation(id string) (*kmac.Relation, error) {
+	return sn.store.GetRelation(id)
+}
+
+// GetAssertion retrieves an assertion as it was when the snapshot was taken
+func (sn *Snapshot) GetAssertion(id string) (*kmac.Assertion, error) {
+	return sn.store.GetAssertion(id)
+}
+
+// GetMetadata returns a copy of a statement's tags, annotations, and notes
+func (sn *Snapshot) GetMetadata(id string) (*kmac.Metadata, error) {
+	return sn.store.GetMetadata(id)
+}
+
+// GetRetraction returns the record of a retracted assertion, if it was retracted
+func (sn *Snapshot) GetRetraction(assertionID string) (*Retraction, bool) {
+	return sn.store.GetRetraction(assertionID)
+}
+
+// Tombstones returns every tombstone, ordered by ID
+func (sn *Snapshot) Tombstones() []*Tombstone {
+	return sn.store.Tombstones()
+}
+
+// RangeEntities calls fn for each entity until fn returns false
+func (sn *Snapshot) RangeEntities(fn func(*EntityReference) bool) {
+	sn.store.RangeEntities(fn)
+}
+
+// FindEntitiesByTOSIDPattern finds entities whose TOSID matches a pattern
+func (sn *Snapshot) FindEntitiesByTOSIDPattern(pattern string) []*EntityReference {
+	return sn.store.FindEntitiesByTOSIDPattern(pattern)
+}
+
+// FindEntitiesByLabel finds entities whose label contains a pattern
+func (sn *Snapshot) FindEntitiesByLabel(labelPattern string) []*EntityReference {
+	return sn.store.FindEntitiesByLabel(labelPattern)
+}
+
+// FindEntitiesByLabelFuzzy finds entities with a label similar to a query,
+// most similar first
+func (sn *Snapshot) FindEntitiesByLabelFuzzy(query string, threshold float64) []LabelMatch {
+	return sn.store.FindEntitiesByLabelFuzzy(query, threshold)
+}
+
+// FindRelatedEntities finds the entities related to an entity, by relation
+func (sn *Snapshot) FindRelatedEntities(entityID string) map[string][]*EntityReference {
+	return sn.store.FindRelatedEntities(entityID)
+}
+
+// FindAssertionsForEntity finds the assertions involving an entity
+func (sn *Snapshot) FindAssertionsForEntity(entityID string) []*kmac.Assertion {
+	return sn.store.FindAssertionsForEntity(entityID)
+}
+
+// FindAssertionsBySubject finds the assertions with a given subject
+func (sn *Snapshot) FindAssertionsBySubject(subjectID string) []*kmac.Assertion {
+	return sn.store.FindAssertionsBySubject(subjectID)
+}
+
+// FindAssertionsByRelation finds the assertions using a given relation
+func (sn *Snapshot) FindAssertionsByRelation(relationID string) []*kmac.Assertion {
+	return sn.store.FindAssertionsByRelation(relationID)
+}
+
+// FindAssertionsByObject finds the assertions with a given object
+func (sn *Snapshot) FindAssertionsByObject(objectID string) []*kmac.Assertion {
+	return sn.store.FindAssertionsByObject(objectID)
+}
+
+// FindAssertionsAbout finds the assertions made about an assertion
+func (sn *Snapshot) FindAssertionsAbout(assertionID string) []*kmac.Assertion {
+	return sn.store.FindAssertionsAbout(assertionID)
+}
+
+// FindByTag returns the IDs of the statements carrying a tag, in order
+func (sn *Snapshot) FindByTag(tag string) []string {
+	return sn.store.FindByTag(tag)
+}
+
+// CurrentState returns an entity's latest state for an attribute
+func (sn *Snapshot) CurrentState(entityID string, attribute string) (*kmac.StateAssertion, bool) {
+	return sn.store.CurrentState(entityID, attribute)
+}
+
+// StateAt returns an entity's state for an attribute at a time
+func (sn *Snapshot) StateAt(entityID string, attribute string, t time.Time) (*kmac.StateAssertion, bool) {
+	return sn.store.StateAt(entityID, attribute, t)
+}
+
+// StateHistory returns an entity's states for an attribute, oldest first
+func (sn *Snapshot) StateHistory(entityID string, attribute string) []*kmac.StateAssertion {
+	return sn.store.StateHistory(entityID, attribute)
+}
+
+// FindAssertionsHoldingAt finds the assertions that hold at a time
+func (sn *Snapshot) FindAssertionsHoldingAt(t time.Time) []*kmac.Assertion {
+	return sn.store.FindAssertionsHoldingAt(t)
+}
+
+// GetStatistics returns the store's statistics as of the snapshot
+func (sn *Snapshot) GetStatistics() map[string]int {
+	return sn.store.GetStatistics()
+}
+
+// ValidateStore checks the snapshot for consistency. Invariants run on a
+// store sharing the snapshot's statements, so any that write leave the
+// snapshot as it was.
+func (sn *Snapshot) ValidateStore() []string {
+	warnings, _ := sn.ValidateStoreContext(context.Background())
+	return warnings
+}
+
+// ValidateStoreContext checks the snapshot for consistency, stopping early if
+// ctx is done
+func (sn *Snapshot) ValidateStoreContext(ctx context.Context) ([]string, error) {
+	return sn.store.share().ValidateStoreContext(ctx)
+}
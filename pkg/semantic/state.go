@@ -0,0 +1,65 @@
+package semantic
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+// AddStateAssertion records the state of an entity attribute at a point in time
+func (s *SemanticStore) AddStateAssertion(state *kmac.StateAssertion) error {
+	s.own()
+	if _, err := s.GetEntity(state.EntityID()); err != nil {
+		return fmt.Errorf("state entity not found: %v", err)
+	}
+
+	history, exists := s.states[state.EntityID()]
+	if !exists {
+		history = kmac.NewStateHistory()
+		s.states[state.EntityID()] = history
+	}
+	history.Add(state)
+	s.recordChanged(RecordEntity, state.EntityID())
+	return s.journalStatements(state)
+}
+
+// CurrentState returns the most recent state of an entity attribute
+func (s *SemanticStore) CurrentState(entityID string, attribute string) (*kmac.StateAssertion, bool) {
+	s.thawStatement(entityID)
+	history, exists := s.states[entityID]
+	if !exists {
+		return nil, false
+	}
+	return history.Current(attribute)
+}
+
+// StateAt returns the state of an entity attribute in effect at the given time
+func (s *SemanticStore) StateAt(entityID string, attribute string, t time.Time) (*kmac.StateAssertion, bool) {
+	s.thawStatement(entityID)
+	history, exists := s.states[entityID]
+	if !exists {
+		return nil, false
+	}
+	return history.At(attribute, t)
+}
+
+// StateHistory returns every recorded state of an entity attribute, oldest first
+func (s *SemanticStore) StateHistory(entityID string, attribute string) []*kmac.StateAssertion {
+	s.thawStatement(entityID)
+	history, exists := s.states[entityID]
+	if !exists {
+		return nil
+	}
+	return history.History(attribute)
+}
+
+// StateAttributes returns the attributes with recorded state for an entity, in sorted order
+func (s *SemanticStore) StateAttributes(entityID string) []string {
+	s.thawStatement(entityID)
+	history, exists := s.states[entityID]
+	if !exists {
+		return nil
+	}
+	return history.Attributes()
+}
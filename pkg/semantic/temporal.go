@@ -0,0 +1,114 @@
+package semantic
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+// AddTimeReference stores a time reference, replacing any with the same ID
+func (s *SemanticStore) AddTimeReference(timeRef *kmac.TimeReference) error {
+	s.own()
+	if timeRef == nil {
+		return errors.New("time reference cannot be nil")
+	}
+	s.times[timeRef.ID()] = timeRef
+	return s.journalStatements(timeRef)
+}
+
+// GetTimeReference retrieves a time reference from the store
+func (s *SemanticStore) GetTimeReference(id string) (*kmac.TimeReference, error) {
+	timeRef, exists := s.times[strings.TrimPrefix(id, "#")]
+	if !exists {
+		return nil, fmt.Errorf("time reference %s not found", id)
+	}
+	return timeRef, nil
+}
+
+// SetTemporal qualifies an assertion in time, replacing any earlier
+// qualification of the same assertion
+func (s *SemanticStore) SetTemporal(temporal *kmac.Temporal) error {
+	s.own()
+	if _, exists := s.assertions.row(temporal.AssertionID()); !exists {
+		return fmt.Errorf("assertion %s not found", temporal.AssertionID())
+	}
+	s.temporals[temporal.AssertionID()] = temporal
+	return s.journalStatements(temporal)
+}
+
+// Temporal returns the temporal qualification of an assertion
+func (s *SemanticStore) Temporal(assertionID string) (*kmac.Temporal, bool) {
+	temporal, exists := s.temporals[assertionID]
+	return temporal, exists
+}
+
+// FindTimeReferencesAt returns the time references with an occurrence in
+// progress at a time, sorted by ID
+func (s *SemanticStore) FindTimeReferencesAt(t time.Time) []*kmac.TimeReference {
+	var found []*kmac.TimeReference
+	for _, timeRef := range s.times {
+		if timeRef.Contains(t) {
+			found = append(found, timeRef)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].ID() < found[j].ID() })
+	return found
+}
+
+// FindAssertionsHoldingAt returns the temporally qualified assertions that
+// hold at a time, sorted by ID. POINT_IN_TIME, DURING, and SIMULTANEOUS
+// assertions hold within their time's occurrences, BEGAN_AT assertions from
+// its start on, and ENDED_AT assertions until its end. Assertions without a
+// temporal qualification, or qualified BEFORE or AFTER, are not returned.
+func (s *SemanticStore) FindAssertionsHoldingAt(t time.Time) []*kmac.Assertion {
+	var found []*kmac.Assertion
+	for assertionID, temporal := range s.temporals {
+		if !s.holdsAt(temporal, t) {
+			continue
+		}
+		if assertion, err := s.GetAssertion(assertionID); err == nil {
+			found = append(found, assertion)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].ID() < found[j].ID() })
+	return found
+}
+
+// holdsAt applies a temporal qualification's state to a time
+func (s *SemanticStore) holdsAt(temporal *kmac.Temporal, t time.Time) bool {
+	timeRef, ok := s.temporalTime(temporal)
+	if !ok {
+		return false
+	}
+	switch temporal.State() {
+	case "POINT_IN_TIME", "DURING", "SIMULTANEOUS":
+		return timeRef.Contains(t)
+	case "BEGAN_AT":
+		return !t.Before(timeRef.Value())
+	case "ENDED_AT":
+		end := timeRef.Value().Add(timeRef.Duration())
+		return t.Before(end) || (timeRef.Duration() == 0 && t.Equal(end))
+	}
+	return false
+}
+
+// temporalTime resolves the time of a temporal qualification: a referenced
+// time, its own start and end, or a literal RFC 3339 timestamp
+func (s *SemanticStore) temporalTime(temporal *kmac.Temporal) (*kmac.TimeReference, bool) {
+	if timeRef, err := s.GetTimeReference(temporal.Timestamp()); err == nil {
+		return timeRef, true
+	}
+	if start, end := temporal.GetStartTime(), temporal.GetEndTime(); start != nil && end != nil {
+		timeRef, err := kmac.NewTimeInterval("T_"+temporal.AssertionID(), "", *start, *end)
+		return timeRef, err == nil
+	}
+	if at, err := time.Parse(time.RFC3339Nano, temporal.Timestamp()); err == nil {
+		timeRef, err := kmac.NewTimeReference("T_"+temporal.AssertionID(), "", at)
+		return timeRef, err == nil
+	}
+	return nil, false
+}
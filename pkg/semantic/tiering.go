@@ -0,0 +1,826 @@
+package semantic
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+	"github.com/ha1tch/tosid-go/pkg/tosid"
+)
+
+// TieringOptions configures cold storage for a store
+type TieringOptions struct {
+	Dir            string // Directory the cold segments are written to, created if missing
+	MaxHotEntities int    // Entities kept in memory; 0 means entities are only frozen by FreezeColdEntities
+}
+
+// TieringStats reports how much of a store is in cold storage
+type TieringStats struct {
+	HotEntities    int
+	ColdEntities   int
+	ColdAssertions int
+	Segments       int // Segment files still holding frozen statements
+	Frozen         int // Entities frozen since tiering was enabled
+	Thawed         int // Entities loaded back since tiering was enabled
+}
+
+// tierLink is a store's cold storage: where each frozen entity, and the
+// assertions frozen with it, are kept
+type tierLink struct {
+	opts     TieringOptions
+	groups   map[string]*coldGroupRef // Frozen entity ID -> its group
+	members  map[string]string        // Frozen assertion ID -> entity ID of its group
+	refs     map[string][]string      // ID outside a group its assertions refer to, relations included -> entity IDs of those groups
+	live     map[int]int              // Segment -> groups still frozen in it
+	next     int                      // Number of the next segment
+	frozen   int
+	thawed   int
+	coldRows int // Frozen assertions
+}
+
+// coldGroupRef locates a frozen group in its segment
+type coldGroupRef struct {
+	segment    int
+	offset     int64
+	length     int64
+	assertions []string
+	retracted  int    // Of the assertions, those retracted
+	taxonomy   string // Taxonomy code of the entity's TOSID, if it has one
+	refs       []string
+}
+
+// coldGroup is an entity as a segment holds it, with the assertions that
+// referred to it, directly or through one another, when it was frozen
+type coldGroup struct {
+	Entity     coldStatement   `json:"entity"`
+	Assertions []coldStatement `json:"assertions,omitempty"`
+}
+
+// coldStatement is a frozen entity or assertion: its record, with its
+// metadata and, for assertions, when it was made and any retraction
+type coldStatement struct {
+	Record      Record            `json:"record"`
+	Tags        []string          `json:"tags,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Notes       []string          `json:"notes,omitempty"`
+	AssertedAt  time.Time         `json:"asserted_at,omitempty"`
+	Retraction  *Retraction       `json:"retraction,omitempty"`
+}
+
+// EnableTiering moves rarely read entities, with the assertions on them, out
+// of memory into gzip-compressed segment files in a directory, keeping the
+// working set of a large historical store small. Reads record access times
+// as they do for LRU eviction, and when a mutation takes the store past
+// MaxHotEntities, the least recently read entities are frozen until a
+// quarter of the limit is free, so segments are written a batch at a time.
+//
+// Frozen statements are loaded back, and count as read, when they are read
+// or changed by ID: GetEntity, GetAssertion, the FindAssertions lookups,
+// metadata and label calls, retraction, confidence, and removal, assertions
+// referring to them, and traversals reaching them. Reads over the whole
+// store read frozen statements from the segments and leave them frozen:
+// listings and pattern, label, and fuzzy searches scan the segments a group
+// at a time, and queries, validation, sampling, relation usage, quality and
+// duplicate reports, retention, and export run on a view holding the frozen
+// statements as well, dropped when the read is done. Frozen entities such
+// reads return are copies, so changes to them are not stored. Statistics
+// count frozen statements without reading them, and snapshots copy them
+// from the segments. Since reads by ID may load statements back, a tiered
+// store shared between goroutines must be read by ID under an exclusive
+// lock while ThawsOnRead reports true; reads over the whole store never
+// change it.
+//
+// Segments left in the directory by an earlier run, which never disabled
+// tiering, are neither read nor written over.
+//
+// Entities with state histories or vectors stay in memory, as do those with
+// an assertion that is derived, a premise, removed, temporal, pending, in a
+// situation, or backed by evidence. Tiering cannot be combined with a
+// storage engine, which reads statements into memory the same way.
+func (s *SemanticStore) EnableTiering(opts TieringOptions) error {
+	s.own()
+	if s.tiering != nil {
+		return errors.New("tiering already enabled")
+	}
+	if s.engine != nil {
+		return errors.New("tiering cannot be enabled with a storage engine attached")
+	}
+	if opts.Dir == "" {
+		return errors.New("tiering needs a directory")
+	}
+	if opts.MaxHotEntities < 0 {
+		return fmt.Errorf("invalid hot entity limit %d", opts.MaxHotEntities)
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create cold storage directory: %v", err)
+	}
+	next, err := nextSegment(opts.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to read cold storage directory: %v", err)
+	}
+	s.trackAccess()
+	s.tiering = &tierLink{
+		opts:    opts,
+		groups:  make(map[string]*coldGroupRef),
+		members: make(map[string]string),
+		refs:    make(map[string][]string),
+		live:    make(map[int]int),
+		next:    next,
+	}
+	return s.enforceTiering()
+}
+
+// DisableTiering loads every frozen statement back into memory and stops
+// freezing
+func (s *SemanticStore) DisableTiering() error {
+	s.own()
+	if s.tiering == nil {
+		return nil
+	}
+	if err := s.ThawAll(); err != nil {
+		return err
+	}
+	s.tiering = nil
+	if s.limits == nil {
+		s.entityAccess.reset()
+		s.assertionAccess.reset()
+	}
+	return nil
+}
+
+// ThawAll loads every frozen statement back into memory, removing the
+// segments as they empty
+func (s *SemanticStore) ThawAll() error {
+	s.own()
+	if s.tiering == nil {
+		return nil
+	}
+	for _, id := range sortedIDs(s.tiering.groups) {
+		if err := s.thawGroup(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FreezeColdEntities freezes every entity but the keep most recently read,
+// as far as they can be frozen, and returns how many it froze
+func (s *SemanticStore) FreezeColdEntities(keep int) (int, error) {
+	s.own()
+	if s.tiering == nil {
+		return 0, errors.New("tiering is not enabled")
+	}
+	if keep < 0 {
+		return 0, fmt.Errorf("invalid number of entities to keep %d", keep)
+	}
+	return s.freeze(keep)
+}
+
+// TieringStats reports the store's hot and cold statements
+func (s *SemanticStore) TieringStats() TieringStats {
+	stats := TieringStats{HotEntities: len(s.entities)}
+	if t := s.tiering; t != nil {
+		stats.ColdEntities = len(t.groups)
+		stats.ColdAssertions = t.coldRows
+		stats.Segments = len(t.live)
+		stats.Frozen = t.frozen
+		stats.Thawed = t.thawed
+	}
+	return stats
+}
+
+// enforceTiering freezes the least recently read entities when the store
+// holds more than its hot limit
+func (s *SemanticStore) enforceTiering() error {
+	if s.tiering == nil {
+		return nil
+	}
+	max := s.tiering.opts.MaxHotEntities
+	if max == 0 || len(s.entities) <= max {
+		return nil
+	}
+	_, err := s.freeze(max - max/4)
+	return err
+}
+
+// freeze writes the least recently read entities that can be frozen, and
+// the assertions on them, to a new segment until keep entities are left in
+// memory, then drops them from memory
+func (s *SemanticStore) freeze(keep int) (int, error) {
+	t := s.tiering
+	// Entities whose accesses were never recorded go first, then the rest
+	// from the least recently read
+	var candidates []string
+	for _, id := range s.sortedEntityIDs() {
+		if !s.entityAccess.tracks(id) {
+			candidates = append(candidates, id)
+		}
+	}
+	s.entityAccess.each(func(id string) bool {
+		if _, exists := s.entities[id]; exists {
+			candidates = append(candidates, id)
+		}
+		return true
+	})
+
+	pinned := s.pinnedAssertions()
+	var entityIDs []string
+	var groups []coldGroup
+	taken := make(map[string]bool)
+	for _, id := range candidates {
+		if len(s.entities)-len(entityIDs) <= keep {
+			break
+		}
+		group, ok := s.coldGroupOf(id, pinned, taken)
+		if !ok {
+			continue
+		}
+		entityIDs = append(entityIDs, id)
+		groups = append(groups, group)
+	}
+	if len(groups) == 0 {
+		return 0, nil
+	}
+
+	segment, refs, err := t.writeSegment(groups)
+	if err != nil {
+		return 0, err
+	}
+	t.live[segment] = len(groups)
+
+	done := s.nest()
+	defer done()
+	for i, group := range groups {
+		entityID := entityIDs[i]
+		ids := make([]string, len(group.Assertions))
+		for j, statement := range group.Assertions {
+			ids[j] = statement.Record.ID
+			t.members[ids[j]] = entityID
+		}
+		refs[i].assertions = ids
+		if tosidObj := s.entities[entityID].TOSIDObj; tosidObj != nil {
+			refs[i].taxonomy = tosidObj.TaxonomyCode
+		}
+		for _, statement := range group.Assertions {
+			if statement.Retraction != nil {
+				refs[i].retracted++
+			}
+		}
+		refs[i].refs = groupRefs(group, entityID)
+		for _, ref := range refs[i].refs {
+			t.refs[ref] = append(t.refs[ref], entityID)
+		}
+		t.groups[entityID] = refs[i]
+		t.coldRows += len(ids)
+		s.removeAssertions(ids)
+		s.removeEntity(entityID)
+	}
+	t.frozen += len(groups)
+	return len(groups), nil
+}
+
+// pinnedAssertions returns the assertions that must stay in memory, as a
+// segment does not keep what is recorded about them
+func (s *SemanticStore) pinnedAssertions() map[string]bool {
+	pinned := make(map[string]bool)
+	for id := range s.derivations {
+		pinned[id] = true
+	}
+	for id := range s.dependents {
+		pinned[id] = true
+	}
+	for id := range s.temporals {
+		pinned[id] = true
+	}
+	for id := range s.tombstones {
+		pinned[id] = true
+	}
+	for _, ids := range s.pending {
+		for _, id := range ids {
+			pinned[id] = true
+		}
+	}
+	for _, members := range s.situationMembers {
+		for _, id := range members {
+			pinned[id] = true
+		}
+	}
+	for _, evidence := range s.evidence {
+		pinned[evidence.AssertionID()] = true
+	}
+	return pinned
+}
+
+// coldGroupOf builds the group an entity would be frozen as, leaving out
+// the assertions already taken by another group, and reports false if it or
+// one of its assertions must stay in memory
+func (s *SemanticStore) coldGroupOf(id string, pinned map[string]bool, taken map[string]bool) (coldGroup, bool) {
+	if _, has := s.states[id]; has {
+		return coldGroup{}, false
+	}
+	if _, has := s.vectors[id]; has {
+		return coldGroup{}, false
+	}
+	closure := make(map[string]bool)
+	s.closeOver(id, closure)
+	rows := make([]int, 0, len(closure))
+	for assertionID := range closure {
+		if pinned[assertionID] {
+			return coldGroup{}, false
+		}
+		if !taken[assertionID] {
+			row, _ := s.assertions.row(assertionID)
+			rows = append(rows, row)
+		}
+	}
+	// In the order they were made, so they are thawed after the assertions
+	// they refer to
+	sort.Ints(rows)
+	ids := make([]string, len(rows))
+	for i, row := range rows {
+		ids[i] = s.assertions.id(row)
+	}
+
+	entityRef := s.entities[id]
+	record, _ := s.record(RecordEntity, id)
+	group := coldGroup{Entity: coldStatementOf(record, &entityRef.KMACEntity.Metadata)}
+	for _, assertionID := range ids {
+		taken[assertionID] = true
+		row, _ := s.assertions.row(assertionID)
+		level, source := s.assertions.confidence(row)
+		record := Record{Kind: RecordAssertion, ID: assertionID, Fields: map[string]string{
+			"subject":    s.assertions.subject(row),
+			"relation":   s.assertions.relation(row),
+			"object":     s.assertions.object(row),
+			"confidence": strconv.FormatFloat(level, 'g', -1, 64),
+			"source":     source,
+		}}
+		statement := coldStatementOf(record, s.assertionMeta[assertionID])
+		statement.AssertedAt = s.assertedAt[assertionID]
+		statement.Retraction = s.retractions[assertionID]
+		group.Assertions = append(group.Assertions, statement)
+	}
+	return group, true
+}
+
+// coldStatementOf pairs a record with a copy of its metadata
+func coldStatementOf(record Record, meta *kmac.Metadata) coldStatement {
+	statement := coldStatement{Record: record}
+	if meta != nil && !meta.IsEmpty() {
+		statement.Tags = meta.Tags()
+		statement.Annotations = meta.Annotations()
+		statement.Notes = meta.Notes()
+	}
+	return statement
+}
+
+// groupRefs returns the IDs a group's assertions refer to outside it, their
+// relations included
+func groupRefs(group coldGroup, entityID string) []string {
+	inside := map[string]bool{entityID: true}
+	for _, statement := range group.Assertions {
+		inside[statement.Record.ID] = true
+	}
+	var refs []string
+	for _, statement := range group.Assertions {
+		fields := statement.Record.Fields
+		for _, ref := range []string{fields["subject"], fields["relation"], fields["object"]} {
+			if !inside[ref] {
+				inside[ref] = true
+				refs = append(refs, ref)
+			}
+		}
+	}
+	return refs
+}
+
+// segmentPath returns the file of a segment
+func (t *tierLink) segmentPath(segment int) string {
+	return filepath.Join(t.opts.Dir, fmt.Sprintf("segment-%06d.gz", segment))
+}
+
+// nextSegment returns the number after the highest segment in a directory,
+// so segments left there by an earlier run are not written over
+func nextSegment(dir string) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	next := 1
+	for _, entry := range entries {
+		var segment int
+		if _, err := fmt.Sscanf(entry.Name(), "segment-%d.gz", &segment); err == nil && segment >= next {
+			next = segment + 1
+		}
+	}
+	return next, nil
+}
+
+// writeSegment writes groups to a new segment file, each as its own gzip
+// member so it can be read back alone, and returns the segment and where
+// each group is in it. Numbers taken by files it did not write, such as
+// those of another store sharing the directory, are skipped.
+func (t *tierLink) writeSegment(groups []coldGroup) (int, []*coldGroupRef, error) {
+	var data bytes.Buffer
+	refs := make([]*coldGroupRef, len(groups))
+	for i, group := range groups {
+		offset := int64(data.Len())
+		zw := gzip.NewWriter(&data)
+		if err := json.NewEncoder(zw).Encode(group); err != nil {
+			return 0, nil, fmt.Errorf("failed to encode cold entity %s: %v", group.Entity.Record.ID, err)
+		}
+		if err := zw.Close(); err != nil {
+			return 0, nil, fmt.Errorf("failed to compress cold entity %s: %v", group.Entity.Record.ID, err)
+		}
+		refs[i] = &coldGroupRef{offset: offset, length: int64(data.Len()) - offset}
+	}
+
+	var segment int
+	var path string
+	var file *os.File
+	var err error
+	for {
+		segment = t.next
+		t.next++
+		path = t.segmentPath(segment)
+		file, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return 0, nil, fmt.Errorf("failed to create cold segment: %v", err)
+		}
+	}
+	for _, ref := range refs {
+		ref.segment = segment
+	}
+	_, err = file.Write(data.Bytes())
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return 0, nil, fmt.Errorf("failed to write cold segment: %v", err)
+	}
+	return segment, refs, nil
+}
+
+// readGroup reads a frozen group from its segment
+func (t *tierLink) readGroup(ref *coldGroupRef) (coldGroup, error) {
+	var group coldGroup
+	file, err := os.Open(t.segmentPath(ref.segment))
+	if err != nil {
+		return group, err
+	}
+	defer file.Close()
+	zr, err := gzip.NewReader(io.NewSectionReader(file, ref.offset, ref.length))
+	if err != nil {
+		return group, err
+	}
+	defer zr.Close()
+	err = json.NewDecoder(zr).Decode(&group)
+	return group, err
+}
+
+// thawStatement loads the frozen entity or assertion with an ID back into
+// memory, with the group it was frozen in, or reads it from the storage
+// engine
+func (s *SemanticStore) thawStatement(id string) error {
+	if s.tiering == nil {
+		return s.fetchStatement(id)
+	}
+	if _, frozen := s.tiering.groups[id]; frozen {
+		return s.thawGroup(id)
+	}
+	if owner, frozen := s.tiering.members[id]; frozen {
+		return s.thawGroup(owner)
+	}
+	return nil
+}
+
+// thawAbout loads a frozen statement back into memory along with the
+// frozen assertions that refer to it, or reads them from the storage engine
+func (s *SemanticStore) thawAbout(id string) error {
+	if s.tiering == nil {
+		return s.fetchAbout(id)
+	}
+	if err := s.thawStatement(id); err != nil {
+		return err
+	}
+	for _, owner := range append([]string(nil), s.tiering.refs[id]...) {
+		if err := s.thawGroup(owner); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// thawGroup loads a frozen entity and its assertions back into memory, and
+// the frozen statements they refer to, so nothing in memory dangles
+func (s *SemanticStore) thawGroup(entityID string) error {
+	t := s.tiering
+	ref, frozen := t.groups[entityID]
+	if !frozen {
+		return nil
+	}
+	group, err := t.readThawedGroup(ref)
+	if err != nil {
+		return fmt.Errorf("failed to load %s from cold storage: %v", entityID, err)
+	}
+
+	delete(t.groups, entityID)
+	for _, id := range ref.assertions {
+		delete(t.members, id)
+	}
+	for _, id := range ref.refs {
+		t.refs[id] = removeString(t.refs[id], entityID)
+		if len(t.refs[id]) == 0 {
+			delete(t.refs, id)
+		}
+	}
+	if t.live[ref.segment]--; t.live[ref.segment] == 0 {
+		delete(t.live, ref.segment)
+		os.Remove(t.segmentPath(ref.segment))
+	}
+	t.coldRows -= len(ref.assertions)
+	t.thawed++
+
+	// What the group refers to is loaded first, so its assertions follow the
+	// assertions they are about, as they did when they were made
+	for _, id := range ref.refs {
+		if thawErr := s.thawStatement(id); thawErr != nil && err == nil {
+			err = thawErr
+		}
+	}
+	s.restoreGroup(group)
+	s.touchEntity(entityID)
+	for _, assertion := range group.assertions {
+		s.touchAssertion(assertion.ID())
+	}
+	return err
+}
+
+// thawedGroup is a frozen group rebuilt, ready to be put in a store
+type thawedGroup struct {
+	entity     *EntityReference
+	assertions []*kmac.Assertion
+	statements []coldStatement
+}
+
+// readThawedGroup reads a frozen group from its segment and rebuilds it
+func (t *tierLink) readThawedGroup(ref *coldGroupRef) (*thawedGroup, error) {
+	group, err := t.readGroup(ref)
+	if err != nil {
+		return nil, err
+	}
+	thawed := &thawedGroup{statements: group.Assertions}
+	if thawed.entity, err = thawedEntity(group.Entity); err != nil {
+		return nil, err
+	}
+	thawed.assertions = make([]*kmac.Assertion, len(group.Assertions))
+	for i, statement := range group.Assertions {
+		fields := statement.Record.Fields
+		if thawed.assertions[i], err = kmac.NewAssertion(statement.Record.ID, fields["subject"], fields["relation"], fields["object"]); err != nil {
+			return nil, err
+		}
+	}
+	return thawed, nil
+}
+
+// restoreGroup puts a rebuilt group's entity and assertions in the store
+func (s *SemanticStore) restoreGroup(group *thawedGroup) {
+	s.own()
+	if code := group.entity.KMACEntity.TOSIDType(); code != "" {
+		group.entity.TOSIDObj, _ = s.tosids.Intern(code)
+	}
+	s.entities[group.entity.KMACEntity.ID()] = group.entity
+	for i, statement := range group.statements {
+		assertion := group.assertions[i]
+		id := assertion.ID()
+		row := s.assertions.put(id, assertion.Subject(), assertion.Relation(), assertion.Object())
+		level, _ := strconv.ParseFloat(statement.Record.Fields["confidence"], 64)
+		s.assertions.setConfidence(row, level, statement.Record.Fields["source"])
+		if statement.Retraction != nil {
+			s.retract(row, statement.Retraction)
+		}
+		if meta := thawedMetadata(statement); meta != nil {
+			s.assertionMeta[id] = meta
+		}
+		if !statement.AssertedAt.IsZero() {
+			s.assertedAt[id] = statement.AssertedAt
+		}
+	}
+}
+
+// owner returns the entity ID of the group a frozen statement is in
+func (t *tierLink) owner(id string) (string, bool) {
+	if _, frozen := t.groups[id]; frozen {
+		return id, true
+	}
+	owner, frozen := t.members[id]
+	return owner, frozen
+}
+
+// copyColdInto copies every frozen statement into another store as thawing
+// would load it, leaving it frozen in this one, stopping early if ctx is
+// done. The groups copied before a group fails to be read stay in the other
+// store. Statements only the storage engine holds are copied as well.
+func (s *SemanticStore) copyColdInto(ctx context.Context, c *SemanticStore) error {
+	if s.tiering == nil {
+		return s.copyEngineInto(ctx, c)
+	}
+	t := s.tiering
+	copied := make(map[string]bool)
+	var copyGroup func(entityID string) error
+	copyGroup = func(entityID string) error {
+		if copied[entityID] {
+			return nil
+		}
+		copied[entityID] = true
+		ref := t.groups[entityID]
+		for _, id := range ref.refs {
+			if owner, frozen := t.owner(id); frozen {
+				if err := copyGroup(owner); err != nil {
+					return err
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		group, err := t.readThawedGroup(ref)
+		if err != nil {
+			return fmt.Errorf("failed to read %s from cold storage: %v", entityID, err)
+		}
+		c.restoreGroup(group)
+		return nil
+	}
+	for _, id := range sortedIDs(t.groups) {
+		if err := copyGroup(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readView returns the store a read over every statement runs on: the
+// store itself while nothing is frozen or only in its storage engine, or
+// else a view of it that also holds those statements, copied from their
+// segments or the engine. The view shares
+// with the store whatever frozen statements do not add to, so the store
+// must not change while it is read, and it is dropped with the read.
+func (s *SemanticStore) readView(ctx context.Context) (*SemanticStore, error) {
+	if !s.ThawsOnRead() {
+		return s, nil
+	}
+	view := *s
+	view.limits, view.tiering, view.engine, view.wal, view.feed = nil, nil, nil, nil, nil
+	// The view copies what frozen statements add to, so it need not own the rest
+	view.shared = false
+	view.entities = maps.Clone(s.entities)
+	view.symbols = s.symbols.clone()
+	view.tosids = s.tosids.Clone()
+	view.assertions = s.assertions.clone(view.symbols)
+	view.retractions = maps.Clone(s.retractions)
+	view.assertionMeta = maps.Clone(s.assertionMeta)
+	view.assertedAt = maps.Clone(s.assertedAt)
+	view.states = maps.Clone(s.states)
+	if err := s.copyColdInto(ctx, &view); err != nil {
+		return nil, err
+	}
+	return &view, nil
+}
+
+// rangeAllEntities calls fn with each entity until fn returns false: those
+// in memory, then the frozen ones, read from their segments a group at a
+// time and left frozen, or those only the storage engine holds, left there
+func (s *SemanticStore) rangeAllEntities(ctx context.Context, fn func(*EntityReference) bool) error {
+	for _, entityRef := range s.entities {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !fn(entityRef) {
+			return nil
+		}
+	}
+	if s.fetches() {
+		var err error
+		if scanErr := s.scanEngine(ctx, RecordEntity, nil, func(record Record) bool {
+			var entityRef *EntityReference
+			if entityRef, err = recordEntity(record); err != nil {
+				err = fmt.Errorf("failed to read %s from storage engine: %v", record.ID, err)
+				return false
+			}
+			return fn(entityRef)
+		}); scanErr != nil {
+			return scanErr
+		}
+		return err
+	}
+	if s.tiering == nil {
+		return nil
+	}
+	for _, id := range sortedIDs(s.tiering.groups) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		group, err := s.tiering.readThawedGroup(s.tiering.groups[id])
+		if err != nil {
+			return fmt.Errorf("failed to read %s from cold storage: %v", id, err)
+		}
+		if !fn(group.entity) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// ThawsOnRead reports whether reads by ID may load frozen statements back
+// into memory, or read them from a storage engine, changing the store, so
+// that goroutines sharing it must make them under an exclusive lock
+func (s *SemanticStore) ThawsOnRead() bool {
+	return (s.tiering != nil && len(s.tiering.groups) > 0) || s.fetches()
+}
+
+// thawedEntity rebuilds a frozen entity
+func thawedEntity(statement coldStatement) (*EntityReference, error) {
+	record := statement.Record
+	entity, err := kmac.NewEntity(record.ID, record.Fields["label"], record.Fields["tosid"])
+	if err != nil {
+		return nil, err
+	}
+	entityRef := &EntityReference{KMACEntity: entity}
+	if code := record.Fields["tosid"]; code != "" {
+		// Parsed to check it; the store interns it when the entity is restored
+		if entityRef.TOSIDObj, err = tosid.Parse(code); err != nil {
+			return nil, err
+		}
+	}
+	for key, value := range record.Fields {
+		if property, ok := strings.CutPrefix(key, PropertyFieldPrefix); ok {
+			entity.SetProperty(property, value)
+		}
+	}
+	if meta := thawedMetadata(statement); meta != nil {
+		entity.Metadata.Merge(meta)
+	}
+	return entityRef, nil
+}
+
+// thawedMetadata rebuilds a frozen statement's metadata, nil if it had none
+func thawedMetadata(statement coldStatement) *kmac.Metadata {
+	if len(statement.Tags) == 0 && len(statement.Annotations) == 0 && len(statement.Notes) == 0 {
+		return nil
+	}
+	meta := &kmac.Metadata{}
+	for _, tag := range statement.Tags {
+		meta.AddTag(tag)
+	}
+	for key, value := range statement.Annotations {
+		meta.Annotate(key, value)
+	}
+	for _, note := range statement.Notes {
+		meta.AddNote(note)
+	}
+	return meta
+}
+
+// dropTiering forgets every frozen statement and removes the segments
+func (s *SemanticStore) dropTiering() {
+	t := s.tiering
+	for segment := range t.live {
+		os.Remove(t.segmentPath(segment))
+	}
+	t.groups = make(map[string]*coldGroupRef)
+	t.members = make(map[string]string)
+	t.refs = make(map[string][]string)
+	t.live = make(map[int]int)
+	t.coldRows = 0
+}
+
+// removeString returns values without v
+func removeString(values []string, v string) []string {
+	kept := values[:0]
+	for _, value := range values {
+		if value != v {
+			kept = append(kept, value)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,193 @@
+package semantic
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+// Tombstone records that an entity, relation, or assertion was removed.
+// Removed statements are hidden from queries but their data and tombstones
+// are kept until Compact, so replicas and audits can see what was deleted
+// and when.
+type Tombstone struct {
+	ID        string
+	Kind      string // Statement type: DEF_ENTITY, DEF_RELATION, or ASSERT
+	Reason    string
+	RemovedAt time.Time
+	Cause     string // ID of the removed statement this follows from; empty if removed directly
+}
+
+// RemoveEntity removes an entity along with the assertions that refer to it,
+// and the assertions about those, leaving tombstones
+func (s *SemanticStore) RemoveEntity(id string, reason string) error {
+	s.own()
+	if err := s.thawAbout(id); err != nil {
+		return err
+	}
+	entityRef, exists := s.entities[id]
+	if !exists {
+		if _, removed := s.tombstones[id]; removed {
+			return fmt.Errorf("entity %s has already been removed", id)
+		}
+		return fmt.Errorf("entity %s not found", id)
+	}
+
+	now := time.Now()
+	delete(s.entities, id)
+	s.recordChanged(RecordEntity, id)
+	s.removedEntities[id] = entityRef
+	s.tombstones[id] = &Tombstone{ID: id, Kind: entityRef.KMACEntity.Type(), Reason: reason, RemovedAt: now}
+	s.recordChanged(RecordTombstone, id)
+	s.removeReferencing(id, reason, now)
+	return s.journal(walRemoveEntity, id, reason)
+}
+
+// RemoveRelation removes a relation definition, leaving a tombstone.
+// Assertions using the relation are kept, as they are when it was never
+// defined.
+func (s *SemanticStore) RemoveRelation(id string, reason string) error {
+	s.own()
+	relation, exists := s.relations[id]
+	if !exists {
+		if _, removed := s.tombstones[id]; removed {
+			return fmt.Errorf("relation %s has already been removed", id)
+		}
+		return fmt.Errorf("relation %s not found", id)
+	}
+
+	delete(s.relations, id)
+	s.recordChanged(RecordRelation, id)
+	s.removedRelations[id] = relation
+	s.tombstones[id] = &Tombstone{ID: id, Kind: relation.Type(), Reason: reason, RemovedAt: time.Now()}
+	s.recordChanged(RecordTombstone, id)
+	return s.journal(walRemoveRelation, id, reason)
+}
+
+// RemoveAssertion removes an assertion along with the assertions about it,
+// leaving tombstones. Assertions derived from a removed assertion are
+// retracted, since their premises are gone.
+func (s *SemanticStore) RemoveAssertion(id string, reason string) error {
+	s.own()
+	if err := s.thawAbout(id); err != nil {
+		return err
+	}
+	row, exists := s.assertions.row(id)
+	if !exists {
+		return fmt.Errorf("assertion %s not found", id)
+	}
+	if _, removed := s.tombstones[id]; removed {
+		return fmt.Errorf("assertion %s has already been removed", id)
+	}
+
+	now := time.Now()
+	s.tombstoneAssertion(row, &Tombstone{ID: id, Kind: "ASSERT", Reason: reason, RemovedAt: now})
+	s.removeReferencing(id, reason, now)
+	return s.journal(walRemoveAssertion, id, reason)
+}
+
+// removeReferencing tombstones the live assertions that refer to a removed
+// statement, and those that refer to them in turn
+func (s *SemanticStore) removeReferencing(id string, reason string, now time.Time) {
+	queue := []string{id}
+	for len(queue) > 0 {
+		causeID := queue[0]
+		queue = queue[1:]
+		for _, row := range s.assertions.live(s.assertions.rowsReferencing(causeID)) {
+			assertionID := s.assertions.id(row)
+			s.tombstoneAssertion(row, &Tombstone{
+				ID:        assertionID,
+				Kind:      "ASSERT",
+				Reason:    fmt.Sprintf("%s removed: %s", causeID, reason),
+				RemovedAt: now,
+				Cause:     causeID,
+			})
+			queue = append(queue, assertionID)
+		}
+	}
+}
+
+// tombstoneAssertion hides an assertion row and retracts what was derived from it
+func (s *SemanticStore) tombstoneAssertion(row int, tombstone *Tombstone) {
+	s.assertions.setRetracted(row, true)
+	s.tombstones[tombstone.ID] = tombstone
+	s.recordChanged(RecordAssertion, tombstone.ID)
+	s.recordChanged(RecordTombstone, tombstone.ID)
+	s.retractDependents(tombstone.ID, "removed: "+tombstone.Reason, tombstone.RemovedAt)
+}
+
+// isRemoved reports whether an ID has been removed and not yet compacted
+func (s *SemanticStore) isRemoved(id string) bool {
+	_, removed := s.tombstones[id]
+	return removed
+}
+
+// forgetTombstone clears a removed ID so it can be used again. An entity's
+// state history and vector went with it and are dropped.
+func (s *SemanticStore) forgetTombstone(id string) {
+	if entityRef, removed := s.removedEntities[id]; removed {
+		s.releaseTOSID(entityRef.KMACEntity.TOSIDType())
+		delete(s.removedEntities, id)
+		delete(s.states, id)
+		s.forgetVector(id)
+	}
+	delete(s.removedRelations, id)
+	if _, removed := s.tombstones[id]; removed {
+		delete(s.tombstones, id)
+		s.recordChanged(RecordTombstone, id)
+	}
+}
+
+// GetTombstone returns the tombstone of a removed statement, if it has one
+func (s *SemanticStore) GetTombstone(id string) (*Tombstone, bool) {
+	tombstone, exists := s.tombstones[id]
+	return tombstone, exists
+}
+
+// Tombstones returns every tombstone, ordered by ID
+func (s *SemanticStore) Tombstones() []*Tombstone {
+	ids := make([]string, 0, len(s.tombstones))
+	for id := range s.tombstones {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	results := make([]*Tombstone, 0, len(ids))
+	for _, id := range ids {
+		results = append(results, s.tombstones[id])
+	}
+	return results
+}
+
+// Compact physically drops removed statements and their tombstones, and
+// returns the IDs dropped, in order. Replicas should have seen the
+// tombstones before the store is compacted.
+func (s *SemanticStore) Compact() []string {
+	s.own()
+	var assertionIDs []string
+	for _, tombstone := range s.Tombstones() {
+		if tombstone.Kind == "ASSERT" {
+			assertionIDs = append(assertionIDs, tombstone.ID)
+		}
+	}
+	if len(assertionIDs) > 0 {
+		s.removeAssertions(assertionIDs)
+	}
+	for id, entityRef := range s.removedEntities {
+		s.releaseTOSID(entityRef.KMACEntity.TOSIDType())
+		s.removeEntity(id)
+	}
+
+	compacted := make([]string, 0, len(s.tombstones))
+	for _, tombstone := range s.Tombstones() {
+		compacted = append(compacted, tombstone.ID)
+		s.recordChanged(RecordTombstone, tombstone.ID)
+	}
+	s.tombstones = make(map[string]*Tombstone)
+	s.removedEntities = make(map[string]*EntityReference)
+	s.removedRelations = make(map[string]*kmac.Relation)
+	s.journal(walCompact)
+	return compacted
+}
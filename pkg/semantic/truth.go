@@ -0,0 +1,144 @@
+package semantic
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+// derivedSource is the confidence source recorded on derived assertions
+const derivedSource = "DERIVED"
+
+// SetConfidenceThreshold sets the confidence below which derived assertions
+// are retracted, and re-evaluates every derived assertion. Derived assertions
+// that rise back above the threshold are reinstated. A threshold of 0 disables
+// confidence-based retraction.
+func (s *SemanticStore) SetConfidenceThreshold(threshold float64) {
+	s.own()
+	s.confidenceThreshold = threshold
+
+	premiseIDs := make([]string, 0, len(s.dependents))
+	for premiseID := range s.dependents {
+		premiseIDs = append(premiseIDs, premiseID)
+	}
+	sort.Strings(premiseIDs)
+	s.maintain(premiseIDs)
+}
+
+// ConfidenceThreshold returns the confidence below which derived assertions are retracted
+func (s *SemanticStore) ConfidenceThreshold() float64 {
+	return s.confidenceThreshold
+}
+
+// SetSources sets the registry of confidence sources that weighs evidence
+// in CombinedConfidence. Stores start with kmac.DefaultSources.
+func (s *SemanticStore) SetSources(sources *kmac.SourceRegistry) {
+	s.sources = sources
+}
+
+// Sources returns the store's registry of confidence sources
+func (s *SemanticStore) Sources() *kmac.SourceRegistry {
+	return s.sources
+}
+
+// CombinedConfidence weighs every live assertion relating a subject to an
+// object by a relation, each by the reliability of its source, and returns
+// the confidence that the claim holds. It reports false if nothing asserts
+// the claim.
+func (s *SemanticStore) CombinedConfidence(subjectID string, relationID string, objectID string) (float64, bool) {
+	var evidence []*kmac.Assertion
+	for _, assertion := range s.FindAssertionsBySubject(subjectID) {
+		if assertion.Relation() == relationID && assertion.Object() == objectID {
+			evidence = append(evidence, assertion)
+		}
+	}
+	level, err := s.sources.Combine(evidence)
+	if err != nil {
+		return 0, false
+	}
+	return level, true
+}
+
+// SetAssertionConfidence sets the confidence of an assertion and recomputes
+// the confidence of every assertion derived from it
+func (s *SemanticStore) SetAssertionConfidence(assertionID string, level float64, source string) error {
+	s.own()
+	if err := s.thawStatement(assertionID); err != nil {
+		return err
+	}
+	row, exists := s.assertions.row(assertionID)
+	if !exists {
+		return fmt.Errorf("assertion %s not found", assertionID)
+	}
+	if s.assertions.isRetracted(row) {
+		return fmt.Errorf("assertion %s has been retracted", assertionID)
+	}
+	if level < 0.0 || level > 1.0 {
+		return fmt.Errorf("confidence %v is outside [0, 1]", level)
+	}
+
+	s.assertions.setConfidence(row, level, source)
+	s.recordChanged(RecordAssertion, assertionID)
+	s.maintain([]string{assertionID})
+	return s.journal(walConfidence, assertionID, strconv.FormatFloat(level, 'g', -1, 64), source)
+}
+
+// maintain re-evaluates the assertions derived from the given premises,
+// following the derivation graph as long as results keep changing
+func (s *SemanticStore) maintain(premiseIDs []string) {
+	queue := append([]string(nil), premiseIDs...)
+	for len(queue) > 0 {
+		premiseID := queue[0]
+		queue = queue[1:]
+		for _, derivedID := range s.dependents[premiseID] {
+			if s.reevaluate(derivedID) {
+				queue = append(queue, derivedID)
+			}
+		}
+	}
+}
+
+// reevaluate recomputes a derived assertion's confidence as the product of its
+// premises' confidences, retracting or reinstating it against the threshold.
+// It reports whether anything changed.
+func (s *SemanticStore) reevaluate(derivedID string) bool {
+	row, exists := s.assertions.row(derivedID)
+	if !exists || s.isRemoved(derivedID) {
+		return false
+	}
+
+	// Explicit retractions, and those caused by retracted premises, are final
+	retraction, retracted := s.retractions[derivedID]
+	if retracted && !retraction.LowConfidence {
+		return false
+	}
+
+	level := 1.0
+	for _, premiseID := range s.derivations[derivedID] {
+		premiseRow, _ := s.assertions.row(premiseID)
+		premiseLevel, _ := s.assertions.confidence(premiseRow)
+		level *= premiseLevel
+	}
+
+	previous, _ := s.assertions.confidence(row)
+	below := level < s.confidenceThreshold
+	s.assertions.setConfidence(row, level, derivedSource)
+	s.recordChanged(RecordAssertion, derivedID)
+
+	switch {
+	case below && !retracted:
+		s.retract(row, &Retraction{
+			AssertionID:   derivedID,
+			Reason:        fmt.Sprintf("confidence %.4f fell below threshold %.4f", level, s.confidenceThreshold),
+			RetractedAt:   time.Now(),
+			LowConfidence: true,
+		})
+	case !below && retracted:
+		s.assertions.setRetracted(row, false)
+		delete(s.retractions, derivedID)
+	}
+	return level != previous || below != retracted
+}
@@ -4,15 +4,8 @@ import (
 	"errors"
 
 	"github.com/ha1tch/tosid-go/pkg/kmac"
-	"github.com/ha1tch/tosid-go/pkg/tosid"
 )
 
-// EntityReference represents a reference to an entity with both KMAC and TOSID information
-type EntityReference struct {
-	KMACEntity *kmac.Entity
-	TOSIDObj   *tosid.TOSID
-}
-
 // SemanticProcessor is an interface for processing semantic data
 type SemanticProcessor interface {
 	// AddEntity adds a new entity to the store
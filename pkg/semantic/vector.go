@@ -0,0 +1,184 @@
+package semantic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// SimilarEntity is an entity found by vector search
+type SimilarEntity struct {
+	Entity     *EntityReference
+	Similarity float64 // Cosine similarity, from -1 to 1
+}
+
+// entityVector holds an entity's vector as given and normalized to unit length
+type entityVector struct {
+	raw  []float32
+	unit []float32
+}
+
+// SetEntityVector attaches a vector, such as an embedding from an external
+// model, to an entity. All vectors in a store must have the same dimension.
+func (s *SemanticStore) SetEntityVector(entityID string, vector []float32) error {
+	s.own()
+	if err := s.thawStatement(entityID); err != nil {
+		return err
+	}
+	if _, exists := s.entities[entityID]; !exists {
+		return fmt.Errorf("entity %s not found", entityID)
+	}
+	if err := s.putVector(entityID, vector); err != nil {
+		return err
+	}
+	s.recordChanged(RecordEntity, entityID)
+	if s.engine != nil && s.nested == 0 {
+		// Vectors are not logged, but a storage engine keeps them
+		return s.writeThrough(context.Background())
+	}
+	return nil
+}
+
+// putVector attaches a vector to an entity the store holds
+func (s *SemanticStore) putVector(entityID string, vector []float32) error {
+	if len(vector) == 0 {
+		return errors.New("vector cannot be empty")
+	}
+	if s.vectorDims != 0 && len(vector) != s.vectorDims {
+		return fmt.Errorf("vector has %d dimensions, store uses %d", len(vector), s.vectorDims)
+	}
+
+	norm := math.Sqrt(dot(vector, vector))
+	if norm == 0 || math.IsNaN(norm) || math.IsInf(norm, 0) {
+		return errors.New("vector must have a finite, non-zero length")
+	}
+	raw := append([]float32(nil), vector...)
+	unit := make([]float32, len(vector))
+	for i, v := range vector {
+		unit[i] = float32(float64(v) / norm)
+	}
+
+	s.vectorDims = len(vector)
+	s.vectors[entityID] = entityVector{raw: raw, unit: unit}
+	if s.vectorIndex != nil {
+		s.vectorIndex.insert(entityID, unit)
+	}
+	return nil
+}
+
+// EntityVector returns the vector attached to an entity
+func (s *SemanticStore) EntityVector(entityID string) ([]float32, bool) {
+	s.thawStatement(entityID)
+	vector, exists := s.vectors[entityID]
+	if !exists {
+		return nil, false
+	}
+	return append([]float32(nil), vector.raw...), true
+}
+
+// EnableVectorIndex builds an HNSW index over the entity vectors, making
+// similarity searches approximate but sublinear. Vectors set later are added
+// to the index as they arrive.
+func (s *SemanticStore) EnableVectorIndex(opts HNSWOptions) {
+	s.vectorIndex = newHNSWIndex(opts)
+	entityIDs := make([]string, 0, len(s.vectors))
+	for entityID := range s.vectors {
+		entityIDs = append(entityIDs, entityID)
+	}
+	sort.Strings(entityIDs)
+	for _, entityID := range entityIDs {
+		s.vectorIndex.insert(entityID, s.vectors[entityID].unit)
+	}
+}
+
+// forgetVector drops an entity's vector, from the index too
+func (s *SemanticStore) forgetVector(entityID string) {
+	delete(s.vectors, entityID)
+	if s.vectorIndex != nil {
+		s.vectorIndex.remove(entityID)
+	}
+}
+
+// DisableVectorIndex drops the HNSW index, returning to exact search
+func (s *SemanticStore) DisableVectorIndex() {
+	s.vectorIndex = nil
+}
+
+// FindSimilarEntities finds the k entities whose vectors are most similar to
+// the given entity's vector, most similar first
+func (s *SemanticStore) FindSimilarEntities(entityID string, k int) ([]SimilarEntity, error) {
+	return s.FindSimilarEntitiesByTOSIDPattern(entityID, k, "")
+}
+
+// FindSimilarEntitiesByTOSIDPattern finds the k entities most similar to the
+// given entity among those whose TOSID matches a pattern
+func (s *SemanticStore) FindSimilarEntitiesByTOSIDPattern(entityID string, k int, pattern string) ([]SimilarEntity, error) {
+	vector, exists := s.vectors[entityID]
+	if !exists {
+		return nil, fmt.Errorf("entity %s has no vector", entityID)
+	}
+	return s.findSimilar(vector.unit, k, pattern, entityID), nil
+}
+
+// FindEntitiesNearVector finds the k entities whose vectors are most similar
+// to a query vector, optionally restricted to a TOSID pattern
+func (s *SemanticStore) FindEntitiesNearVector(vector []float32, k int, pattern string) ([]SimilarEntity, error) {
+	if len(vector) != s.vectorDims {
+		return nil, fmt.Errorf("vector has %d dimensions, store uses %d", len(vector), s.vectorDims)
+	}
+	norm := math.Sqrt(dot(vector, vector))
+	if norm == 0 {
+		return nil, errors.New("vector must have a non-zero length")
+	}
+	unit := make([]float32, len(vector))
+	for i, v := range vector {
+		unit[i] = float32(float64(v) / norm)
+	}
+	return s.findSimilar(unit, k, pattern, ""), nil
+}
+
+// findSimilar searches the index if enabled, or every vector otherwise
+func (s *SemanticStore) findSimilar(unit []float32, k int, pattern string, excludeID string) []SimilarEntity {
+	accept := func(entityID string) bool {
+		if entityID == excludeID {
+			return false
+		}
+		entityRef, exists := s.entities[entityID]
+		if !exists {
+			// Removed entities keep their vectors until their IDs are reused
+			// or compacted
+			return false
+		}
+		if pattern == "" {
+			return true
+		}
+		return entityRef.TOSIDObj != nil && entityRef.TOSIDObj.MatchesPattern(pattern)
+	}
+
+	var results []SimilarEntity
+	if s.vectorIndex != nil {
+		for _, c := range s.vectorIndex.search(unit, k, accept) {
+			entityID := s.vectorIndex.nodes[c.node].id
+			results = append(results, SimilarEntity{Entity: s.entities[entityID], Similarity: 1 - c.distance})
+		}
+		return results
+	}
+
+	for entityID, vector := range s.vectors {
+		if accept(entityID) {
+			results = append(results, SimilarEntity{Entity: s.entities[entityID], Similarity: dot(unit, vector.unit)})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Similarity != results[j].Similarity {
+			return results[i].Similarity > results[j].Similarity
+		}
+		return results[i].Entity.KMACEntity.ID() < results[j].Entity.KMACEntity.ID()
+	})
+	if k >= 0 && len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
@@ -0,0 +1,459 @@
+package semantic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+// Write-ahead log record operations
+const (
+	walEntity          = "ENTITY"
+	walRelation        = "RELATION"
+	walAssert          = "ASSERT"
+	walBatch           = "BATCH"
+	walDerive          = "DERIVE"
+	walConfidence      = "CONFIDENCE"
+	walRetract         = "RETRACT"
+	walRemoveEntity    = "REMOVE_ENTITY"
+	walRemoveRelation  = "REMOVE_RELATION"
+	walRemoveAssertion = "REMOVE_ASSERTION"
+	walCompact         = "COMPACT"
+	walCleanup         = "CLEANUP"
+	walTag             = "TAG"
+	walUntag           = "UNTAG"
+	walAnnotate        = "ANNOTATE"
+	walNote            = "NOTE"
+	walLoad            = "LOAD"
+	walClear           = "CLEAR"
+	walSync            = "SYNC"
+	walInverse         = "INVERSE"
+	walSituation       = "SITUATION"
+	walInSituation     = "IN_SITUATION"
+	walMerge           = "MERGE"
+)
+
+// WALOptions configures a store's write-ahead log
+type WALOptions struct {
+	Sync  bool        // Flush each record to disk before the mutation returns, or each batch when batching
+	Batch BatchPolicy // Write records in batches rather than one at a time
+}
+
+// BatchPolicy groups the writes to a write-ahead log or storage engine, so
+// a bulk ingest pays for a flush or transaction per batch rather than per
+// mutation. Writes are held until MaxRecords mutations are pending or
+// MaxDelay has passed since the first of them, when a timer writes them
+// even if the store has gone idle; FlushWAL and FlushEngine write them
+// sooner. The zero policy writes every mutation at once.
+//
+// Held writes are lost if the process dies: a crash can take the last
+// MaxRecords mutations, or the last MaxDelay of them, with it. A failed
+// batch write is reported by the mutation that set it off, or when the
+// timer made it, by the next mutation or flush.
+type BatchPolicy struct {
+	MaxRecords int           // Mutations per batch; 0 means no limit when MaxDelay is set
+	MaxDelay   time.Duration // Longest a mutation waits to be written; 0 means no limit when MaxRecords is set
+}
+
+// due reports whether a batch of pending mutations, the first made at
+// since, should be written
+func (p BatchPolicy) due(pending int, since time.Time) bool {
+	if p.MaxRecords <= 1 && p.MaxDelay <= 0 {
+		return true
+	}
+	return (p.MaxRecords > 0 && pending >= p.MaxRecords) || (p.MaxDelay > 0 && time.Since(since) >= p.MaxDelay)
+}
+
+// writeAheadLog is the open log of a store
+type writeAheadLog struct {
+	mu      sync.Mutex // Guards the log against the batch timer
+	file    *os.File
+	buf     *bufio.Writer
+	sync    bool
+	batch   BatchPolicy
+	pending int         // Records buffered since the last flush
+	since   time.Time   // When the first of them was buffered
+	timer   *time.Timer // Flushes the buffer MaxDelay after its first record
+	err     error       // First failed write; the log accepts nothing after it
+}
+
+// OpenWAL replays the log at path into the store, creating the log if it
+// does not exist, then appends every later mutation to it, so the store
+// survives a restart without a database behind it. Each record is a line
+// of text, and loaded statements are kept as KMAC text.
+//
+// Configuration is not logged: set the integrity mode, naming policy,
+// limits, and confidence threshold before opening the log, as they were
+// when it was written. Entity vectors are not logged either. Replayed
+// retractions and tombstones carry the time of the replay, and replayed
+// assertions are aged for retention from it.
+//
+// A mutation is logged once it has been applied. If its record cannot be
+// written the mutation returns the error, or for mutations that return
+// none, the next one does; the change stays in memory but is lost on
+// restart, and the log accepts nothing more until it is reopened. A record
+// torn by a crash is dropped on replay.
+//
+// With a batch policy, records are buffered and written, and with Sync
+// flushed to disk, a batch at a time; see BatchPolicy for what a crash
+// loses. Records are still written in order, so replay always sees a
+// prefix of the mutations.
+func (s *SemanticStore) OpenWAL(path string, opts WALOptions) error {
+	s.own()
+	if s.wal != nil {
+		return errors.New("write-ahead log already open")
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open write-ahead log: %v", err)
+	}
+	end, err := s.replayWAL(file)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to replay write-ahead log %s: %v", path, err)
+	}
+	if err := file.Truncate(end); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to trim write-ahead log: %v", err)
+	}
+	if _, err := file.Seek(end, io.SeekStart); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open write-ahead log: %v", err)
+	}
+	s.wal = &writeAheadLog{file: file, buf: bufio.NewWriter(file), sync: opts.Sync, batch: opts.Batch}
+	return nil
+}
+
+// FlushWAL writes the records a batch policy holds to the write-ahead log,
+// and flushes them to disk if the log syncs
+func (s *SemanticStore) FlushWAL() error {
+	if s.wal == nil {
+		return nil
+	}
+	return s.wal.flush()
+}
+
+// CloseWAL flushes and closes the write-ahead log, reporting any record
+// that failed to be written. Later mutations are not logged.
+func (s *SemanticStore) CloseWAL() error {
+	if s.wal == nil {
+		return nil
+	}
+	wal := s.wal
+	s.wal = nil
+	err := wal.flush()
+	if syncErr := wal.file.Sync(); err == nil && syncErr != nil {
+		err = fmt.Errorf("failed to flush write-ahead log: %v", syncErr)
+	}
+	if closeErr := wal.file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close write-ahead log: %v", closeErr)
+	}
+	return err
+}
+
+// replayWAL applies the records in a log and returns the offset just past
+// the last complete one
+func (s *SemanticStore) replayWAL(r io.Reader) (int64, error) {
+	reader := bufio.NewReader(r)
+	var offset int64
+	for lineNo := 1; ; lineNo++ {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF {
+			// A line without its newline was torn by a crash
+			return offset, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		offset += int64(len(line))
+
+		op, args, err := parseWALRecord(strings.TrimSuffix(line, "\n"))
+		if err == nil {
+			err = s.applyWALRecord(op, args)
+			// The mutation was kept when it was logged, invariant or not
+			var broken *InvariantError
+			if errors.As(err, &broken) {
+				err = nil
+			}
+		}
+		if err != nil {
+			return 0, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+	}
+}
+
+// applyWALRecord redoes a logged mutation
+func (s *SemanticStore) applyWALRecord(op string, args []string) error {
+	arity := map[string]int{
+		walEntity: 3, walRelation: 3, walAssert: 4, walConfidence: 3, walRetract: 2,
+		walRemoveEntity: 2, walRemoveRelation: 2, walRemoveAssertion: 2, walCompact: 0,
+		walCleanup: 2, walUntag: 2, walAnnotate: 3, walNote: 2, walLoad: 1, walClear: 0,
+		walSync: 2, walInverse: 2, walSituation: 3,
+	}
+	if n, fixed := arity[op]; fixed && len(args) != n {
+		return fmt.Errorf("%s record has %d fields, expected %d", op, len(args), n)
+	}
+
+	switch op {
+	case walEntity:
+		return s.AddEntity(args[0], args[1], args[2])
+	case walRelation:
+		return s.AddRelation(args[0], args[1], args[2])
+	case walAssert:
+		return s.CreateAssertion(args[0], args[1], args[2], args[3])
+	case walBatch:
+		if len(args)%4 != 0 {
+			return fmt.Errorf("%s record has %d fields, expected a multiple of 4", op, len(args))
+		}
+		batch := make([]AssertionSpec, 0, len(args)/4)
+		for i := 0; i < len(args); i += 4 {
+			batch = append(batch, AssertionSpec{ID: args[i], Subject: args[i+1], Relation: args[i+2], Object: args[i+3]})
+		}
+		return s.CreateAssertions(batch).Err()
+	case walDerive:
+		if len(args) < 4 {
+			return fmt.Errorf("%s record has %d fields, expected at least 4", op, len(args))
+		}
+		return s.CreateDerivedAssertion(args[0], args[1], args[2], args[3], args[4:])
+	case walConfidence:
+		level, err := strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			return fmt.Errorf("invalid confidence: %v", err)
+		}
+		return s.SetAssertionConfidence(args[0], level, args[2])
+	case walRetract:
+		return s.Retract(args[0], args[1])
+	case walRemoveEntity:
+		return s.RemoveEntity(args[0], args[1])
+	case walRemoveRelation:
+		return s.RemoveRelation(args[0], args[1])
+	case walRemoveAssertion:
+		return s.RemoveAssertion(args[0], args[1])
+	case walCompact:
+		s.Compact()
+		return nil
+	case walCleanup:
+		_, err := s.Cleanup(CleanupPolicy{Dangling: args[0], PruneOrphans: args[1] == "true"})
+		return err
+	case walTag:
+		if len(args) < 1 {
+			return fmt.Errorf("%s record has no ID", op)
+		}
+		return s.Tag(args[0], args[1:]...)
+	case walUntag:
+		return s.Untag(args[0], args[1])
+	case walAnnotate:
+		return s.Annotate(args[0], args[1], args[2])
+	case walNote:
+		return s.AddNote(args[0], args[1])
+	case walLoad:
+		return s.LoadKMAC(strings.NewReader(args[0]))
+	case walClear:
+		s.Clear()
+		return nil
+	case walSync:
+		s.syncCursors[args[0]] = args[1]
+		return nil
+	case walInverse:
+		return s.DeclareInverse(args[0], args[1])
+	case walSituation:
+		return s.AddSituation(args[0], args[1], args[2])
+	case walInSituation:
+		if len(args) < 2 {
+			return fmt.Errorf("%s record has %d fields, expected at least 2", op, len(args))
+		}
+		return s.AddToSituation(args[0], args[1:]...)
+	case walMerge:
+		if len(args) < 2 {
+			return fmt.Errorf("%s record has %d fields, expected at least 2", op, len(args))
+		}
+		_, err := s.MergeEntities(args[0], args[1:]...)
+		return err
+	}
+	return fmt.Errorf("unknown record %s", op)
+}
+
+// journal appends a mutation to the change feed and the write-ahead log,
+// if they are enabled, freezes cold entities if the store is over its hot
+// limit, then checks the invariants checked on mutation
+func (s *SemanticStore) journal(op string, args ...string) error {
+	if s.nested > 0 {
+		return nil
+	}
+	if err := s.writeRecord(op, args); err != nil {
+		return err
+	}
+	if err := s.enforceTiering(); err != nil {
+		return err
+	}
+	if s.engine != nil {
+		if err := s.writeThrough(context.Background()); err != nil {
+			return err
+		}
+	}
+	return s.checkMutationInvariants()
+}
+
+// writeRecord appends a record to the change feed and the write-ahead log
+func (s *SemanticStore) writeRecord(op string, args []string) error {
+	if s.feed != nil {
+		s.feed.append(op, args)
+	}
+	if s.wal == nil {
+		return nil
+	}
+	wal := s.wal
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+	if wal.err != nil {
+		return wal.err
+	}
+	if _, err := wal.buf.WriteString(formatWALRecord(op, args)); err != nil {
+		wal.err = fmt.Errorf("failed to write to write-ahead log: %v", err)
+		return wal.err
+	}
+	if wal.pending == 0 {
+		wal.since = time.Now()
+		if wal.batch.MaxDelay > 0 {
+			wal.timer = time.AfterFunc(wal.batch.MaxDelay, func() { wal.flush() })
+		}
+	}
+	wal.pending++
+	if !wal.batch.due(wal.pending, wal.since) {
+		return nil
+	}
+	return wal.write()
+}
+
+// flush writes the buffered records to the file, and to disk if the log
+// syncs
+func (wal *writeAheadLog) flush() error {
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+	return wal.write()
+}
+
+// write flushes the buffered records, with the log locked
+func (wal *writeAheadLog) write() error {
+	if wal.timer != nil {
+		wal.timer.Stop()
+		wal.timer = nil
+	}
+	if wal.err != nil {
+		return wal.err
+	}
+	if wal.pending == 0 {
+		return nil
+	}
+	wal.pending = 0
+	if err := wal.buf.Flush(); err != nil {
+		wal.err = fmt.Errorf("failed to write to write-ahead log: %v", err)
+		return wal.err
+	}
+	if wal.sync {
+		if err := wal.file.Sync(); err != nil {
+			wal.err = fmt.Errorf("failed to flush write-ahead log: %v", err)
+			return wal.err
+		}
+	}
+	return nil
+}
+
+// journalStatements logs statements as KMAC text
+func (s *SemanticStore) journalStatements(statements ...kmac.Statement) error {
+	if (s.wal == nil && s.feed == nil && s.engine == nil) || s.nested > 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := kmac.NewTextSerializer().Encode(&buf, statements); err != nil {
+		return fmt.Errorf("failed to encode KMAC for write-ahead log: %v", err)
+	}
+	return s.journal(walLoad, buf.String())
+}
+
+// nest stops logging the mutations a logged mutation is made of, until the
+// returned function is called
+func (s *SemanticStore) nest() func() {
+	s.nested++
+	return func() { s.nested-- }
+}
+
+// formatWALRecord renders a record as a line: the operation, then each
+// argument in brackets
+func formatWALRecord(op string, args []string) string {
+	var b strings.Builder
+	b.WriteString(op)
+	for _, arg := range args {
+		b.WriteString(" [")
+		for _, c := range arg {
+			switch c {
+			case '\\':
+				b.WriteString(`\\`)
+			case ']':
+				b.WriteString(`\]`)
+			case '\n':
+				b.WriteString(`\n`)
+			case '\r':
+				b.WriteString(`\r`)
+			default:
+				b.WriteRune(c)
+			}
+		}
+		b.WriteByte(']')
+	}
+	b.WriteByte('\n')
+	return b.String()
+}
+
+// parseWALRecord splits a record line into its operation and arguments
+func parseWALRecord(line string) (string, []string, error) {
+	op, rest, _ := strings.Cut(line, " ")
+	if op == "" {
+		return "", nil, errors.New("empty record")
+	}
+	var args []string
+	for rest != "" {
+		rest = strings.TrimPrefix(rest, " ")
+		if !strings.HasPrefix(rest, "[") {
+			return "", nil, fmt.Errorf("malformed %s record", op)
+		}
+		var arg strings.Builder
+		closed := false
+		i := 1
+		for ; i < len(rest); i++ {
+			c := rest[i]
+			if c == ']' {
+				closed = true
+				break
+			}
+			if c == '\\' && i+1 < len(rest) {
+				i++
+				switch rest[i] {
+				case 'n':
+					c = '\n'
+				case 'r':
+					c = '\r'
+				default:
+					c = rest[i]
+				}
+			}
+			arg.WriteByte(c)
+		}
+		if !closed {
+			return "", nil, fmt.Errorf("unterminated field in %s record", op)
+		}
+		args = append(args, arg.String())
+		rest = rest[i+1:]
+	}
+	return op, args, nil
+}
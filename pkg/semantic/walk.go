@@ -0,0 +1,33 @@
+package semantic
+
+import (
+	"context"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+)
+
+// Walk calls fn for each of the store's statements, in the order Statements
+// gives them, and rebuilds the store from the statements kept, for
+// migrations that rename relations, remap TOSIDs, or normalize labels in
+// place. As with Statements, retracted and removed assertions, tombstones,
+// and derivations do not survive the rebuild. The store is only changed if
+// the walk succeeds and its result loads.
+func (s *SemanticStore) Walk(fn kmac.WalkFunc) error {
+	statements, err := s.StatementsContext(context.Background())
+	if err != nil {
+		return err
+	}
+	kept, err := kmac.Walk(statements, fn)
+	if err != nil {
+		return err
+	}
+
+	check := NewSemanticStore()
+	check.integrity = s.integrity
+	check.naming = s.naming
+	if err := check.LoadStatements(kept); err != nil {
+		return err
+	}
+	s.Clear()
+	return s.LoadStatements(kept)
+}
@@ -0,0 +1,298 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ha1tch/tosid-go/pkg/convert"
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+)
+
+// exportJSON is the format name of the plain JSON export, which the convert
+// package does not provide
+const exportJSON = "json"
+
+// exportType is a media type /export can respond with
+type exportType struct {
+	mediaType string
+	format    string // convert format name, or exportJSON
+}
+
+// exportTypes are the media types /export offers, in order of preference
+// when a client accepts several equally. N-Triples are served as Turtle,
+// of which they are a subset.
+var exportTypes = []exportType{
+	{"text/x-kmac", convert.KMACText},
+	{"text/plain", convert.KMACText},
+	{"application/json", exportJSON},
+	{"application/ld+json", convert.JSONLD},
+	{"text/turtle", convert.RDF},
+	{"application/n-triples", convert.RDF},
+	{"text/csv", convert.CSV},
+	{"application/x-protobuf", convert.Protobuf},
+}
+
+// handleExport streams the store's statements in the format the Accept
+// header asks for, or the format parameter names, defaulting to KMAC text.
+// With pattern parameters, only the entities whose TOSIDs match one of
+// them are exported, with the assertions involving them and what those
+// refer to. The export is taken from a snapshot, so writes go on while a
+// large store streams out. The body is gzip-compressed when the client's
+// Accept-Encoding allows it. It responds 406 when no offered type is
+// acceptable.
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	if !allowRead(w, r) {
+		return
+	}
+	chosen, err := negotiateExport(r)
+	if err != nil {
+		writeJSON(w, http.StatusNotAcceptable, errorResponse{Error: err.Error()})
+		return
+	}
+
+	s.mu.RLock()
+	snapshot, err := s.store.ReadSnapshotContext(r.Context())
+	s.mu.RUnlock()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+	statements := snapshot.Statements()
+	if patterns := r.URL.Query()["pattern"]; len(patterns) > 0 {
+		statements = filterByTOSID(snapshot, statements, patterns)
+	}
+
+	w.Header().Set("Content-Type", chosen.mediaType)
+	w.Header().Add("Vary", "Accept-Encoding")
+	gzipped := acceptsGzip(r)
+	if gzipped {
+		w.Header().Set("Content-Encoding", "gzip")
+	}
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+	var body io.Writer = w
+	if gzipped {
+		zw, _ := kmac.NewCompressWriter(w, kmac.CompressionGzip)
+		defer zw.Close()
+		body = zw
+	}
+	var writer convert.Writer
+	if chosen.format == exportJSON {
+		writer = newExportJSONWriter(body)
+	} else {
+		writer, _ = convert.NewWriter(chosen.format, body)
+	}
+	// The status is already sent, so a failure can only cut the body short
+	serializer := kmac.NewTextSerializer()
+	for _, stmt := range statements {
+		for _, text := range serializer.FormatStatement(stmt) {
+			line, err := kmac.ParseTextLine(text)
+			if err == nil {
+				err = writer.Write(line)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+	writer.Close()
+}
+
+// acceptsGzip reports whether a request's Accept-Encoding header allows a
+// gzip-compressed response
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(coding) != "gzip" {
+			continue
+		}
+		if q, weighted := strings.CutPrefix(strings.TrimSpace(params), "q="); weighted {
+			if quality, err := strconv.ParseFloat(q, 64); err != nil || quality == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// negotiateExport picks the export type for a request
+func negotiateExport(r *http.Request) (exportType, error) {
+	if name := r.URL.Query().Get("format"); name != "" {
+		for _, t := range exportTypes {
+			if t.format == name {
+				return t, nil
+			}
+		}
+		return exportType{}, fmt.Errorf("unknown format %q", name)
+	}
+
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return exportTypes[0], nil
+	}
+	best, bestQuality := -1, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, exists := params["q"]; exists {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		for i, t := range exportTypes {
+			if !mediaTypeMatches(mediaType, t.mediaType) {
+				continue
+			}
+			if quality > bestQuality || (quality == bestQuality && quality > 0 && i < best) {
+				best, bestQuality = i, quality
+			}
+			break
+		}
+	}
+	if best < 0 || bestQuality <= 0 {
+		return exportType{}, fmt.Errorf("none of %s is acceptable", strings.Join(exportMediaTypes(), ", "))
+	}
+	return exportTypes[best], nil
+}
+
+// mediaTypeMatches reports whether an Accept media range, which may be
+// */* or type/*, covers a media type
+func mediaTypeMatches(accepted string, mediaType string) bool {
+	if accepted == "*/*" || accepted == mediaType {
+		return true
+	}
+	major, _, _ := strings.Cut(mediaType, "/")
+	return accepted == major+"/*"
+}
+
+// filterByTOSID keeps the entities whose TOSIDs match any of the patterns,
+// the assertions involving them or made about those assertions, and what
+// the kept assertions refer to: their other entities, relations,
+// situations, and qualifiers. Times are kept whole.
+func filterByTOSID(snapshot *semantic.Snapshot, statements []kmac.Statement, patterns []string) []kmac.Statement {
+	matched := make(map[string]bool)
+	for _, pattern := range patterns {
+		for _, entityRef := range snapshot.FindEntitiesByTOSIDPattern(pattern) {
+			matched[entityRef.KMACEntity.ID()] = true
+		}
+	}
+
+	// Assertions come in the order they were made, so one made about
+	// another follows it
+	kept := make(map[string]bool)
+	for id := range matched {
+		kept[id] = true
+	}
+	keptAssertions := make(map[string]bool)
+	for _, stmt := range statements {
+		switch stmt := stmt.(type) {
+		case *kmac.Assertion:
+			if matched[stmt.Subject()] || matched[stmt.Object()] || keptAssertions[stmt.Subject()] || keptAssertions[stmt.Object()] {
+				keptAssertions[stmt.ID()] = true
+				kept[stmt.ID()] = true
+				kept[stmt.Subject()] = true
+				kept[stmt.Relation()] = true
+				kept[stmt.Object()] = true
+			}
+		case *kmac.SituationMember:
+			if keptAssertions[stmt.AssertionID()] {
+				kept[stmt.SituationID()] = true
+			}
+		}
+	}
+
+	var filtered []kmac.Statement
+	for _, stmt := range statements {
+		keep := true
+		switch stmt := stmt.(type) {
+		case *kmac.Entity, *kmac.Relation, *kmac.Situation, *kmac.Assertion:
+			keep = kept[stmt.ID()]
+		case *kmac.StateAssertion:
+			keep = kept[stmt.EntityID()]
+		case *kmac.Temporal:
+			keep = keptAssertions[stmt.AssertionID()]
+		case *kmac.SituationMember:
+			keep = keptAssertions[stmt.AssertionID()]
+		case *kmac.Evidence:
+			keep = keptAssertions[stmt.AssertionID()]
+		}
+		if keep {
+			filtered = append(filtered, stmt)
+		}
+	}
+	return filtered
+}
+
+// exportJSONWriter writes lines as the objects of a JSON array under
+// "statements", with their fields as an object
+type exportJSONWriter struct {
+	w     *bufio.Writer
+	lines int
+}
+
+// exportJSONLine is a line in the JSON export
+type exportJSONLine struct {
+	Keyword string            `json:"keyword"`
+	ID      string            `json:"id,omitempty"`
+	Label   string            `json:"label,omitempty"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+func newExportJSONWriter(w io.Writer) *exportJSONWriter {
+	return &exportJSONWriter{w: bufio.NewWriter(w)}
+}
+
+func (jw *exportJSONWriter) Write(line *kmac.TextLine) error {
+	out := exportJSONLine{Keyword: line.Keyword, ID: line.ID, Label: line.Label}
+	for _, field := range line.Fields {
+		if out.Fields == nil {
+			out.Fields = make(map[string]string, len(line.Fields))
+		}
+		out.Fields[field.Name] = field.Value
+	}
+	data, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	separator := ","
+	if jw.lines == 0 {
+		separator = `{"statements":[`
+	}
+	jw.lines++
+	_, err = jw.w.WriteString(separator + "\n" + string(data))
+	return err
+}
+
+func (jw *exportJSONWriter) Close() error {
+	closing := "\n]}\n"
+	if jw.lines == 0 {
+		closing = `{"statements":[]}` + "\n"
+	}
+	if _, err := jw.w.WriteString(closing); err != nil {
+		return err
+	}
+	return jw.w.Flush()
+}
+
+// exportMediaTypes returns the media types /export offers, in order of
+// preference
+func exportMediaTypes() []string {
+	types := make([]string, 0, len(exportTypes))
+	for _, t := range exportTypes {
+		types = append(types, t.mediaType)
+	}
+	return types
+}
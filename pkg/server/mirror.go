@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+)
+
+// ErrReadOnly is returned for changes asked of a read-only mirror
+var ErrReadOnly = errors.New("server is a read-only mirror")
+
+// mirrorState is what a mirror knows of its refreshes
+type mirrorState struct {
+	refreshed time.Time // When the store being served was built
+	err       error     // Of the last refresh, if it failed
+}
+
+// NewMirror creates a read-only server for a store, for scaling out read
+// traffic or publishing reference knowledge. Queries are served as by New,
+// but subscriptions cannot be made and Update fails with ErrReadOnly; the
+// store served is replaced by RunMirror instead, and is never written to.
+func NewMirror(store *semantic.SemanticStore) *Server {
+	s := New(store)
+	s.mirror = &mirrorState{refreshed: time.Now()}
+	return s
+}
+
+// ReadOnly reports whether the server is a read-only mirror
+func (s *Server) ReadOnly() bool {
+	return s.mirror != nil
+}
+
+// RunMirror replaces the store a mirror serves with a fresh one from
+// refresh every interval, until ctx is done. Requests in flight finish on
+// the store they started with. A failed refresh leaves the last store
+// served, shows as a warning on /healthz, and is passed to onError, which
+// may be nil.
+func (s *Server) RunMirror(ctx context.Context, interval time.Duration, refresh func(ctx context.Context) (*semantic.SemanticStore, error), onError func(err error)) error {
+	if s.mirror == nil {
+		return errors.New("server is not a mirror")
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			// Built without the lock, so readers are only held up by the swap
+			store, err := refresh(ctx)
+			s.mu.Lock()
+			if err == nil {
+				s.store = store
+				s.mirror.refreshed = time.Now()
+			}
+			s.mirror.err = err
+			s.mu.Unlock()
+			if err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// mirrorCheck reports how fresh a mirror's store is. It is called with the
+// store locked for reading.
+func (s *Server) mirrorCheck() HealthCheck {
+	check := HealthCheck{Name: "mirror", Status: StatusOK,
+		Detail: fmt.Sprintf("read-only, refreshed %s", s.mirror.refreshed.UTC().Format(time.RFC3339))}
+	if s.mirror.err != nil {
+		check.Status = StatusWarning
+		check.Detail += fmt.Sprintf("; last refresh failed: %v", s.mirror.err)
+	}
+	return check
+}
+
+// allowWrite rejects changes asked of a mirror with 403
+func (s *Server) allowWrite(w http.ResponseWriter) bool {
+	if s.mirror == nil {
+		return true
+	}
+	writeJSON(w, http.StatusForbidden, errorResponse{Error: ErrReadOnly.Error()})
+	return false
+}
@@ -0,0 +1,318 @@
+package server
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ha1tch/tosid-go/pkg/convert"
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+)
+
+// openAPIVersion is the version of the OpenAPI specification the document
+// follows
+const openAPIVersion = "3.0.3"
+
+// OpenAPIDocument is an OpenAPI 3 description of the server's endpoints,
+// from which typed clients can be generated
+type OpenAPIDocument struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       OpenAPIInfo                      `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"` // Path -> lower-case method -> operation
+	Components OpenAPIComponents                `json:"components"`
+}
+
+// OpenAPIInfo describes the API
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// OpenAPIComponents holds the schemas operations refer to
+type OpenAPIComponents struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Operation is one method of one path
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"` // By status code
+}
+
+// Parameter is a path or query parameter of an operation
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // "path" or "query"
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is the body an operation accepts
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is a response an operation may give
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType gives the schema of a body in one media type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is a JSON schema, or a reference to one among the components
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// endpoint describes one method of one path for the document. Bodies are
+// given as values of their Go types, from which their schemas are drawn.
+type endpoint struct {
+	path      string
+	method    string
+	id        string
+	summary   string
+	params    []Parameter
+	body      interface{}         // Request body; nil for none
+	responses map[int]interface{} // Status -> response body
+	produces  []string            // Media types of a 200 response that is not JSON
+}
+
+// pageParameters are the parameters of paged endpoints
+var pageParameters = []Parameter{
+	{Name: "limit", In: "query", Description: "Most results in the page", Schema: &Schema{Type: "integer"}},
+	{Name: "offset", In: "query", Description: "Results to skip after the cursor", Schema: &Schema{Type: "integer"}},
+	{Name: "cursor", In: "query", Description: "Cursor of the page before", Schema: &Schema{Type: "string"}},
+	{Name: "order", In: "query", Description: "Order of the results", Schema: &Schema{Type: "string",
+		Enum: []string{semantic.OrderByID, semantic.OrderByLabel, semantic.OrderByTOSID, semantic.OrderByConfidence, semantic.OrderByRecency}}},
+}
+
+// idParameters returns query parameters taking IDs
+func idParameters(names ...string) []Parameter {
+	params := make([]Parameter, 0, len(names))
+	for _, name := range names {
+		params = append(params, Parameter{Name: name, In: "query", Description: "ID of the " + name, Schema: &Schema{Type: "string"}})
+	}
+	return params
+}
+
+// endpoints are the server's endpoints, as registered by New
+var endpoints = []endpoint{
+	{path: "/healthz", method: http.MethodGet, id: "getHealth", summary: "Report lightweight health checks",
+		responses: map[int]interface{}{http.StatusOK: HealthReport{}}},
+	{path: "/consistency", method: http.MethodGet, id: "getConsistency", summary: "Validate the whole store",
+		responses: map[int]interface{}{http.StatusOK: ConsistencyReport{}, http.StatusServiceUnavailable: ConsistencyReport{}}},
+	{path: "/changes", method: http.MethodGet, id: "listChanges", summary: "List the changes made after a cursor",
+		params: []Parameter{
+			{Name: "cursor", In: "query", Description: "Cursor of the last change seen", Schema: &Schema{Type: "string"}},
+			{Name: "limit", In: "query", Description: "Most changes to list", Schema: &Schema{Type: "integer"}},
+		},
+		responses: map[int]interface{}{http.StatusOK: ChangesResponse{}, http.StatusBadRequest: errorResponse{},
+			http.StatusNotFound: errorResponse{}, http.StatusGone: errorResponse{}}},
+	{path: "/entities", method: http.MethodGet, id: "listEntities", summary: "Page through entities by TOSID pattern or label",
+		params: append([]Parameter{
+			{Name: "pattern", In: "query", Description: "TOSID pattern", Schema: &Schema{Type: "string"}},
+			{Name: "label", In: "query", Description: "Label pattern", Schema: &Schema{Type: "string"}},
+		}, pageParameters...),
+		responses: map[int]interface{}{http.StatusOK: EntitiesResponse{}, http.StatusBadRequest: errorResponse{}}},
+	{path: "/assertions", method: http.MethodGet, id: "listAssertions", summary: "Page through the assertions involving an entity or relation",
+		params:    append(idParameters("entity", "subject", "relation", "object"), pageParameters...),
+		responses: map[int]interface{}{http.StatusOK: AssertionsResponse{}, http.StatusBadRequest: errorResponse{}}},
+	{path: "/queries", method: http.MethodGet, id: "listQueries", summary: "List the saved queries",
+		responses: map[int]interface{}{http.StatusOK: []semantic.SavedQuery{}}},
+	{path: "/queries/{name}", method: http.MethodGet, id: "runQuery", summary: "Run a saved query, its arguments given as query parameters",
+		params: append([]Parameter{
+			{Name: "name", In: "path", Description: "Name of the saved query", Required: true, Schema: &Schema{Type: "string"}},
+		}, pageParameters...),
+		responses: map[int]interface{}{http.StatusOK: EntitiesResponse{}, http.StatusBadRequest: errorResponse{}, http.StatusNotFound: errorResponse{}}},
+	{path: "/subscriptions", method: http.MethodGet, id: "listSubscriptions", summary: "List the webhook subscriptions",
+		responses: map[int]interface{}{http.StatusOK: SubscriptionsResponse{}}},
+	{path: "/subscriptions", method: http.MethodPost, id: "subscribe", summary: "Register a webhook subscription",
+		body: Subscription{},
+		responses: map[int]interface{}{http.StatusCreated: Subscription{}, http.StatusBadRequest: errorResponse{},
+			http.StatusForbidden: errorResponse{}, http.StatusRequestEntityTooLarge: errorResponse{}}},
+	{path: "/subscriptions/{id}", method: http.MethodDelete, id: "unsubscribe", summary: "Remove a webhook subscription",
+		params: []Parameter{
+			{Name: "id", In: "path", Description: "ID of the subscription", Required: true, Schema: &Schema{Type: "string"}},
+		},
+		responses: map[int]interface{}{http.StatusOK: struct{}{}, http.StatusForbidden: errorResponse{}, http.StatusNotFound: errorResponse{}}},
+	{path: "/export", method: http.MethodGet, id: "export", summary: "Stream the statements in the format the Accept header asks for",
+		params: []Parameter{
+			{Name: "pattern", In: "query", Description: "Export only the entities matching a TOSID pattern (repeatable)", Schema: &Schema{Type: "string"}},
+			{Name: "format", In: "query", Description: "Format to use regardless of the Accept header", Schema: &Schema{Type: "string",
+				Enum: []string{convert.KMACText, exportJSON, convert.JSONLD, convert.RDF, convert.CSV, convert.Protobuf}}},
+		},
+		responses: map[int]interface{}{http.StatusNotAcceptable: errorResponse{}},
+		produces:  exportMediaTypes()},
+	{path: "/openapi.json", method: http.MethodGet, id: "getOpenAPI", summary: "Describe the API as an OpenAPI document",
+		responses: map[int]interface{}{http.StatusOK: map[string]interface{}{}}},
+}
+
+// OpenAPI returns the OpenAPI document describing the server's endpoints,
+// which /openapi.json serves. Every operation may also be refused 429 by
+// the quotas.
+func OpenAPI() *OpenAPIDocument {
+	doc := &OpenAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info: OpenAPIInfo{
+			Title:       "TOSID knowledge base",
+			Description: "Queries over a semantic store of TOSID-classified entities and KMAC assertions",
+			Version:     "1",
+		},
+		Paths:      make(map[string]map[string]*Operation),
+		Components: OpenAPIComponents{Schemas: make(map[string]*Schema)},
+	}
+	for _, e := range endpoints {
+		op := &Operation{OperationID: e.id, Summary: e.summary, Parameters: e.params, Responses: make(map[string]*Response)}
+		if e.body != nil {
+			op.RequestBody = &RequestBody{Required: true, Content: jsonContent(doc.schemaOf(reflect.TypeOf(e.body)))}
+		}
+		for status, body := range e.responses {
+			op.Responses[strconv.Itoa(status)] = &Response{
+				Description: http.StatusText(status),
+				Content:     jsonContent(doc.schemaOf(reflect.TypeOf(body))),
+			}
+		}
+		if len(e.produces) > 0 {
+			response := &Response{Description: http.StatusText(http.StatusOK), Content: make(map[string]MediaType)}
+			for _, mediaType := range e.produces {
+				response.Content[mediaType] = MediaType{Schema: &Schema{Type: "string", Format: "binary"}}
+			}
+			op.Responses[strconv.Itoa(http.StatusOK)] = response
+		}
+		op.Responses[strconv.Itoa(http.StatusTooManyRequests)] = &Response{
+			Description: http.StatusText(http.StatusTooManyRequests),
+			Content:     jsonContent(doc.schemaOf(reflect.TypeOf(errorResponse{}))),
+		}
+		if doc.Paths[e.path] == nil {
+			doc.Paths[e.path] = make(map[string]*Operation)
+		}
+		doc.Paths[e.path][strings.ToLower(e.method)] = op
+	}
+	return doc
+}
+
+// handleOpenAPI serves the OpenAPI document
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if !allowRead(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, OpenAPI())
+}
+
+// jsonContent gives a schema as the content of a JSON body
+func jsonContent(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: schema}}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaOf returns the schema of values of a Go type as encoding/json
+// encodes them. Named structs are added to the components and referred to.
+func (doc *OpenAPIDocument) schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: doc.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: doc.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t == timeType {
+			return &Schema{Type: "string", Format: "date-time"}
+		}
+		if t.Name() == "" {
+			return doc.structSchema(t)
+		}
+		name := schemaName(t)
+		if _, exists := doc.Components.Schemas[name]; !exists {
+			// Reserved before the fields are walked, so recursive types end
+			doc.Components.Schemas[name] = &Schema{}
+			doc.Components.Schemas[name] = doc.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	default:
+		// Interfaces may hold anything
+		return &Schema{}
+	}
+}
+
+// structSchema returns the object schema of a struct type, with its
+// exported fields as properties under their JSON names. Fields without
+// omitempty are required.
+func (doc *OpenAPIDocument) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			embedded := doc.structSchema(field.Type)
+			for property, propertySchema := range embedded.Properties {
+				schema.Properties[property] = propertySchema
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = doc.schemaOf(field.Type)
+		if !strings.Contains(","+options+",", ",omitempty,") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+// schemaName names the component schema of a named type. Types from other
+// packages are prefixed with their package's name, as their names may
+// clash.
+func schemaName(t reflect.Type) string {
+	name := capitalize(t.Name())
+	if pkg := t.PkgPath(); pkg != reflect.TypeOf(Server{}).PkgPath() {
+		name = capitalize(pkg[strings.LastIndex(pkg, "/")+1:]) + name
+	}
+	return name
+}
+
+// capitalize upper-cases the first letter of a name
+func capitalize(name string) string {
+	runes := []rune(name)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxTrackedPrincipals bounds the rate limiter's memory. Past it, the
+// buckets of principals that have gone quiet are dropped.
+const maxTrackedPrincipals = 10000
+
+// QuotaOptions limits what each client of a shared server may ask of it, so
+// one misbehaving client cannot overwhelm it. The zero value limits nothing.
+type QuotaOptions struct {
+	Rate         float64                      // Requests per second allowed to each principal; 0 for no limit
+	Burst        int                          // Requests a principal may make at once; below 1 is taken as 1
+	MaxBodyBytes int64                        // Largest request body accepted; 0 for no limit
+	Principal    func(r *http.Request) string // Identifies a request's client; nil uses the X-API-Key header or else the remote host
+}
+
+// rateLimiter is a token bucket per principal
+type rateLimiter struct {
+	mu      sync.Mutex
+	opts    QuotaOptions
+	buckets map[string]*bucket
+}
+
+// bucket holds the requests a principal may still make, refilled at the
+// rate up to the burst
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// SetQuotas sets the rate and payload limits applied to every request,
+// replacing any set before. Requests over the rate are answered 429 with a
+// Retry-After header, and bodies over the size 413.
+func (s *Server) SetQuotas(opts QuotaOptions) {
+	if opts.Burst < 1 {
+		opts.Burst = 1
+	}
+	s.limiter.mu.Lock()
+	defer s.limiter.mu.Unlock()
+	s.limiter.opts = opts
+	s.limiter.buckets = make(map[string]*bucket)
+}
+
+// admit applies the quotas to a request, writing the error response and
+// returning false when it is refused
+func (s *Server) admit(w http.ResponseWriter, r *http.Request) bool {
+	s.limiter.mu.Lock()
+	opts := s.limiter.opts
+	wait := s.limiter.take(r, time.Now())
+	s.limiter.mu.Unlock()
+
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		writeJSON(w, http.StatusTooManyRequests, errorResponse{Error: "rate limit exceeded"})
+		return false
+	}
+	if opts.MaxBodyBytes > 0 {
+		if r.ContentLength > opts.MaxBodyBytes {
+			writeJSON(w, http.StatusRequestEntityTooLarge, errorResponse{Error: fmt.Sprintf("request body exceeds %d bytes", opts.MaxBodyBytes)})
+			return false
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, opts.MaxBodyBytes)
+	}
+	return true
+}
+
+// take spends one of the principal's requests, returning how long it must
+// wait when it has none left. It is called with the limiter locked.
+func (l *rateLimiter) take(r *http.Request, now time.Time) time.Duration {
+	if l.opts.Rate <= 0 {
+		return 0
+	}
+	principal := principalOf(r)
+	if l.opts.Principal != nil {
+		principal = l.opts.Principal(r)
+	}
+
+	b, exists := l.buckets[principal]
+	if !exists {
+		if len(l.buckets) >= maxTrackedPrincipals {
+			l.dropIdle(now)
+		}
+		b = &bucket{tokens: float64(l.opts.Burst), updated: now}
+		l.buckets[principal] = b
+	}
+	b.tokens = math.Min(float64(l.opts.Burst), b.tokens+now.Sub(b.updated).Seconds()*l.opts.Rate)
+	b.updated = now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.opts.Rate * float64(time.Second))
+	}
+	b.tokens--
+	return 0
+}
+
+// dropIdle forgets the principals whose buckets have refilled, as they are
+// no different from principals never seen
+func (l *rateLimiter) dropIdle(now time.Time) {
+	for principal, b := range l.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*l.opts.Rate >= float64(l.opts.Burst) {
+			delete(l.buckets, principal)
+		}
+	}
+}
+
+// principalOf identifies a request's client by its X-API-Key header, or
+// else by its remote host
+func principalOf(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return "key:" + key
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "host:" + host
+}
@@ -0,0 +1,539 @@
+// Package server serves a semantic store over HTTP.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+)
+
+// defaultChangesLimit is the most changes /changes returns unless asked for fewer or more
+const defaultChangesLimit = 1000
+
+// defaultPageLimit is the most results /entities and /assertions return
+// unless asked for fewer or more
+const defaultPageLimit = 100
+
+// Check statuses
+const (
+	StatusOK           = "ok"
+	StatusWarning      = "warning"
+	StatusConsistent   = "consistent"
+	StatusInconsistent = "inconsistent"
+)
+
+// Server serves a semantic store over HTTP. The store is not safe for
+// concurrent use, so the server serializes access to it.
+type Server struct {
+	mu      sync.RWMutex
+	store   *semantic.SemanticStore
+	mux     *http.ServeMux
+	started time.Time
+
+	subscriptions map[string]*subscription // By ID; nil until Subscribe is called
+	webhookClient *http.Client
+	webhookErrors func(n *Notification, err error) // nil unless SetWebhookErrorHandler was called
+
+	limiter rateLimiter
+	mirror  *mirrorState // nil unless created by NewMirror
+}
+
+// HealthCheck is the result of one lightweight check
+type HealthCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// HealthReport is the response of /healthz
+type HealthReport struct {
+	Status     string         `json:"status"`
+	Uptime     string         `json:"uptime"`
+	Statistics map[string]int `json:"statistics"`
+	Checks     []HealthCheck  `json:"checks"`
+}
+
+// ConsistencyReport is the response of /consistency
+type ConsistencyReport struct {
+	Status    string    `json:"status"`
+	Warnings  []string  `json:"warnings"`
+	CheckedAt time.Time `json:"checked_at"`
+	Duration  string    `json:"duration"`
+}
+
+// ChangesResponse is the response of /changes
+type ChangesResponse struct {
+	Changes []semantic.Change `json:"changes"`
+	Cursor  string            `json:"cursor"` // Cursor to ask from next
+	More    bool              `json:"more"`   // More changes follow the cursor
+}
+
+// Entity is an entity in a query response
+type Entity struct {
+	ID         string            `json:"id"`
+	Label      string            `json:"label"`
+	TOSID      string            `json:"tosid,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+// Assertion is an assertion in a query response
+type Assertion struct {
+	ID         string  `json:"id"`
+	Subject    string  `json:"subject"`
+	Relation   string  `json:"relation"`
+	Object     string  `json:"object"`
+	Confidence float64 `json:"confidence"`
+	Source     string  `json:"source,omitempty"`
+}
+
+// EntitiesResponse is the response of /entities
+type EntitiesResponse struct {
+	Entities []Entity `json:"entities"`
+	Cursor   string   `json:"cursor,omitempty"` // Cursor to ask for the next page with
+	More     bool     `json:"more"`             // More entities follow the cursor
+}
+
+// AssertionsResponse is the response of /assertions
+type AssertionsResponse struct {
+	Assertions []Assertion `json:"assertions"`
+	Cursor     string      `json:"cursor,omitempty"` // Cursor to ask for the next page with
+	More       bool        `json:"more"`             // More assertions follow the cursor
+}
+
+// errorResponse is the body of an error response
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// New creates a server for a store
+func New(store *semantic.SemanticStore) *Server {
+	s := &Server{
+		store:   store,
+		mux:     http.NewServeMux(),
+		started: time.Now(),
+
+		webhookClient: &http.Client{Timeout: webhookTimeout},
+	}
+	s.mux.HandleFunc("/healthz", s.handleHealth)
+	s.mux.HandleFunc("/consistency", s.handleConsistency)
+	s.mux.HandleFunc("/changes", s.handleChanges)
+	s.mux.HandleFunc("/entities", s.handleEntities)
+	s.mux.HandleFunc("/assertions", s.handleAssertions)
+	s.mux.HandleFunc("/queries", s.handleQueries)
+	s.mux.HandleFunc("/queries/", s.handleQuery)
+	s.mux.HandleFunc("/subscriptions", s.handleSubscriptions)
+	s.mux.HandleFunc("/subscriptions/", s.handleSubscription)
+	s.mux.HandleFunc("/export", s.handleExport)
+	s.mux.HandleFunc("/openapi.json", s.handleOpenAPI)
+	return s
+}
+
+// ServeHTTP dispatches a request to the server's endpoints, once it is
+// within the quotas
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.admit(w, r) {
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// Update runs fn with exclusive access to the store, for changes made
+// while the server is running. Subscribers are then notified of the
+// entities it added or changed. Mirrors cannot be updated, and return
+// ErrReadOnly.
+func (s *Server) Update(fn func(store *semantic.SemanticStore) error) error {
+	if s.mirror != nil {
+		return ErrReadOnly
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := fn(s.store)
+	s.notifySubscribers()
+	return err
+}
+
+// lockRead locks the store for reads by ID and returns the unlock. Reads by
+// ID of a store with statements in cold storage may load them back into
+// memory, changing it, so they lock it exclusively. Reads of the whole store
+// leave frozen statements where they are and share the read lock.
+func (s *Server) lockRead() (unlock func()) {
+	s.mu.RLock()
+	if !s.store.ThawsOnRead() {
+		return s.mu.RUnlock
+	}
+	s.mu.RUnlock()
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+// RunRetention purges the assertions the store's retention policy has
+// expired every interval, until ctx is done. Each purge that removes
+// something or fails is passed to audit, which may be nil. A mirror's store
+// is never purged, so for mirrors it returns at once.
+func (s *Server) RunRetention(ctx context.Context, interval time.Duration, audit func(report *semantic.RetentionReport, err error)) {
+	if s.mirror != nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			report, err := s.store.PurgeExpired(now)
+			s.notifySubscribers()
+			s.mu.Unlock()
+			if audit != nil && (err != nil || len(report.Removed) > 0) {
+				audit(report, err)
+			}
+		}
+	}
+}
+
+// Health runs the lightweight checks behind /healthz. It never walks the
+// assertions, so it is cheap enough for liveness probes.
+func (s *Server) Health() *HealthReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	report := &HealthReport{
+		Status:     StatusOK,
+		Uptime:     time.Since(s.started).Round(time.Second).String(),
+		Statistics: s.store.GetStatistics(),
+	}
+	report.Checks = append(report.Checks, HealthCheck{Name: "store", Status: StatusOK,
+		Detail: fmt.Sprintf("%d entities, %d assertions", report.Statistics["entities"], report.Statistics["assertions"])})
+
+	pending := HealthCheck{Name: "pending_references", Status: StatusOK}
+	if references := s.store.PendingReferences(); len(references) > 0 {
+		pending.Status = StatusWarning
+		pending.Detail = fmt.Sprintf("%d unknown IDs awaited in %s mode", len(references), s.store.IntegrityMode())
+	}
+	report.Checks = append(report.Checks, pending)
+
+	if limits := s.store.Limits(); limits != nil {
+		capacity := HealthCheck{Name: "capacity", Status: StatusOK}
+		evicted := report.Statistics["evicted_entities"] + report.Statistics["evicted_assertions"]
+		if evicted > 0 {
+			capacity.Status = StatusWarning
+			capacity.Detail = fmt.Sprintf("%d statements evicted under the %s policy", evicted, limits.Policy)
+		}
+		report.Checks = append(report.Checks, capacity)
+	}
+	if s.mirror != nil {
+		report.Checks = append(report.Checks, s.mirrorCheck())
+	}
+
+	for _, check := range report.Checks {
+		if check.Status == StatusWarning {
+			report.Status = StatusWarning
+		}
+	}
+	return report
+}
+
+// handleHealth serves the lightweight health report. Warnings leave the
+// server healthy, so it always responds 200 while the server is up.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if !allowRead(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.Health())
+}
+
+// handleConsistency runs full validation of the store. It responds 503 when
+// the store is inconsistent, so it can serve as a readiness probe.
+func (s *Server) handleConsistency(w http.ResponseWriter, r *http.Request) {
+	if !allowRead(w, r) {
+		return
+	}
+
+	start := time.Now()
+	// Invariants may read by ID
+	unlock := s.lockRead()
+	warnings, err := s.store.ValidateStoreContext(r.Context())
+	unlock()
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, errorResponse{Error: fmt.Sprintf("validation stopped: %v", err)})
+		return
+	}
+
+	report := &ConsistencyReport{
+		Status:    StatusConsistent,
+		Warnings:  warnings,
+		CheckedAt: start.UTC(),
+		Duration:  time.Since(start).String(),
+	}
+	if report.Warnings == nil {
+		report.Warnings = []string{}
+	}
+	status := http.StatusOK
+	if len(warnings) > 0 {
+		report.Status = StatusInconsistent
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+// handleChanges serves the changes made to the store after the cursor
+// parameter, at most limit of them, for followers replicating it. It
+// responds 410 when the cursor has expired, so the follower knows to start
+// over from a snapshot, and 404 when the store records no changes.
+func (s *Server) handleChanges(w http.ResponseWriter, r *http.Request) {
+	if !allowRead(w, r) {
+		return
+	}
+	limit := defaultChangesLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid limit %q", value)})
+			return
+		}
+		limit = parsed
+	}
+
+	s.mu.RLock()
+	// One more than the limit is read to tell whether more follow
+	changes, _, err := s.store.ChangesSince(r.URL.Query().Get("cursor"), limit+1)
+	s.mu.RUnlock()
+	switch {
+	case errors.Is(err, semantic.ErrNoChangeFeed):
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+		return
+	case errors.Is(err, semantic.ErrCursorExpired):
+		writeJSON(w, http.StatusGone, errorResponse{Error: err.Error()})
+		return
+	case err != nil:
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	response := &ChangesResponse{Changes: changes, Cursor: r.URL.Query().Get("cursor")}
+	if len(changes) > limit {
+		response.Changes = changes[:limit]
+		response.More = true
+	}
+	if len(response.Changes) > 0 {
+		response.Cursor = response.Changes[len(response.Changes)-1].Cursor
+	} else {
+		response.Changes = []semantic.Change{}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleEntities serves a page of the entities matching the pattern
+// parameter, a TOSID pattern, or the label parameter; with neither, every
+// entity is paged through. Pages hold limit entities, after skipping offset
+// of them from the cursor parameter, which is the cursor of the page before.
+// The order parameter orders them by id, label, or tosid.
+func (s *Server) handleEntities(w http.ResponseWriter, r *http.Request) {
+	if !allowRead(w, r) {
+		return
+	}
+	req, err := pageRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	query := r.URL.Query()
+	if query.Has("pattern") && query.Has("label") {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "pattern and label cannot both be given"})
+		return
+	}
+
+	s.mu.RLock()
+	var page semantic.Page[*semantic.EntityReference]
+	if query.Has("pattern") {
+		page, err = s.store.FindEntitiesByTOSIDPatternPage(query.Get("pattern"), req)
+	} else {
+		page, err = s.store.FindEntitiesByLabelPage(query.Get("label"), req)
+	}
+	response := entitiesResponse(page)
+	s.mu.RUnlock()
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleQueries lists the saved queries registered with the store
+func (s *Server) handleQueries(w http.ResponseWriter, r *http.Request) {
+	if !allowRead(w, r) {
+		return
+	}
+	s.mu.RLock()
+	queries := s.store.SavedQueries()
+	s.mu.RUnlock()
+	writeJSON(w, http.StatusOK, queries)
+}
+
+// handleQuery runs the saved query named by the path, /queries/<name>, with
+// its arguments given as parameters of the same names, and serves a page of
+// the entities it selects, paged as /entities pages. It responds 404 for
+// queries that are not registered.
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
+	if !allowRead(w, r) {
+		return
+	}
+	req, err := pageRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/queries/")
+	args := make(map[string]string)
+	for param, values := range r.URL.Query() {
+		if !pageParams[param] {
+			args[param] = values[0]
+		}
+	}
+
+	s.mu.RLock()
+	_, exists := s.store.SavedQuery(name)
+	page, err := s.store.RunQuery(name, args, req)
+	response := entitiesResponse(page)
+	s.mu.RUnlock()
+	switch {
+	case !exists:
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+	case err != nil:
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+	default:
+		writeJSON(w, http.StatusOK, response)
+	}
+}
+
+// entitiesResponse builds the response for a page of entities
+func entitiesResponse(page semantic.Page[*semantic.EntityReference]) *EntitiesResponse {
+	response := &EntitiesResponse{Entities: []Entity{}, Cursor: page.Next, More: page.Next != ""}
+	for _, entityRef := range page.Items {
+		response.Entities = append(response.Entities, toEntity(entityRef))
+	}
+	return response
+}
+
+// toEntity converts an entity for a response
+func toEntity(entityRef *semantic.EntityReference) Entity {
+	entity := entityRef.KMACEntity
+	return Entity{
+		ID:         entity.ID(),
+		Label:      entity.Label(),
+		TOSID:      entity.TOSIDType(),
+		Properties: entity.GetAllProperties(),
+	}
+}
+
+// handleAssertions serves a page of the assertions involving the entity
+// parameter, or with the subject, relation, or object parameter, paged as
+// /entities pages. The order parameter orders them by id, confidence, or
+// recency.
+func (s *Server) handleAssertions(w http.ResponseWriter, r *http.Request) {
+	if !allowRead(w, r) {
+		return
+	}
+	req, err := pageRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	queries := map[string]func(*semantic.SemanticStore, string, semantic.PageRequest) (semantic.Page[*kmac.Assertion], error){
+		"entity":   (*semantic.SemanticStore).FindAssertionsForEntityPage,
+		"subject":  (*semantic.SemanticStore).FindAssertionsBySubjectPage,
+		"relation": (*semantic.SemanticStore).FindAssertionsByRelationPage,
+		"object":   (*semantic.SemanticStore).FindAssertionsByObjectPage,
+	}
+	var find func(*semantic.SemanticStore, string, semantic.PageRequest) (semantic.Page[*kmac.Assertion], error)
+	var id string
+	for _, param := range []string{"entity", "subject", "relation", "object"} {
+		if value := r.URL.Query().Get(param); value != "" {
+			if find != nil {
+				writeJSON(w, http.StatusBadRequest, errorResponse{Error: "only one of entity, subject, relation, and object may be given"})
+				return
+			}
+			find, id = queries[param], value
+		}
+	}
+	if find == nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "one of entity, subject, relation, or object is required"})
+		return
+	}
+
+	unlock := s.lockRead()
+	page, err := find(s.store, id, req)
+	unlock()
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	response := &AssertionsResponse{Assertions: []Assertion{}, Cursor: page.Next, More: page.Next != ""}
+	for _, assertion := range page.Items {
+		confidence, source := assertion.GetConfidence()
+		response.Assertions = append(response.Assertions, Assertion{
+			ID:         assertion.ID(),
+			Subject:    assertion.Subject(),
+			Relation:   assertion.Relation(),
+			Object:     assertion.Object(),
+			Confidence: confidence,
+			Source:     source,
+		})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// pageParams are the query parameters pageRequest reads
+var pageParams = map[string]bool{"limit": true, "offset": true, "cursor": true, "order": true}
+
+// pageRequest reads the limit, offset, cursor, and order parameters of a
+// query
+func pageRequest(r *http.Request) (semantic.PageRequest, error) {
+	req := semantic.PageRequest{
+		Limit:  defaultPageLimit,
+		Cursor: r.URL.Query().Get("cursor"),
+		Order:  r.URL.Query().Get("order"),
+	}
+	for param, target := range map[string]*int{"limit": &req.Limit, "offset": &req.Offset} {
+		value := r.URL.Query().Get(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 || (param == "limit" && parsed == 0) {
+			return req, fmt.Errorf("invalid %s %q", param, value)
+		}
+		*target = parsed
+	}
+	return req, nil
+}
+
+// allowRead rejects requests other than GET and HEAD
+func allowRead(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return true
+	}
+	w.Header().Set("Allow", "GET, HEAD")
+	writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: fmt.Sprintf("method %s not allowed", r.Method)})
+	return false
+}
+
+// writeJSON writes v as an indented JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
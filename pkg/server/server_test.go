@@ -0,0 +1,476 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+)
+
+// get performs a request against the server and decodes its JSON response
+func get(t *testing.T, srv *Server, method string, path string, v interface{}) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("%s %s: expected JSON, got %q", method, path, got)
+	}
+	if err := json.NewDecoder(rec.Body).Decode(v); err != nil {
+		t.Fatalf("%s %s: failed to decode response: %v", method, path, err)
+	}
+	return rec.Code
+}
+
+func TestHealthAndConsistency(t *testing.T) {
+	store := semantic.NewSemanticStore()
+	store.AddEntity("E1001", "Highway", "")
+	store.AddEntity("E1002", "Truck", "")
+	store.CreateAssertion("F1001", "E1002", "R1001", "E1001")
+	srv := New(store)
+
+	var health HealthReport
+	if code := get(t, srv, http.MethodGet, "/healthz", &health); code != http.StatusOK {
+		t.Errorf("Expected 200 from /healthz, got %d", code)
+	}
+	if health.Status != StatusOK || health.Statistics["entities"] != 2 || len(health.Checks) != 2 {
+		t.Errorf("Unexpected health report: %+v", health)
+	}
+
+	var consistency ConsistencyReport
+	if code := get(t, srv, http.MethodGet, "/consistency", &consistency); code != http.StatusOK {
+		t.Errorf("Expected 200 from /consistency, got %d", code)
+	}
+	if consistency.Status != StatusConsistent || len(consistency.Warnings) != 0 {
+		t.Errorf("Unexpected consistency report: %+v", consistency)
+	}
+
+	// Deferred imports leave references pending and the store inconsistent
+	srv.Update(func(store *semantic.SemanticStore) error {
+		store.SetIntegrityMode(semantic.IntegrityDeferred)
+		return store.CreateAssertion("F1002", "E1002", "R1001", "E9999")
+	})
+	get(t, srv, http.MethodGet, "/healthz", &health)
+	if health.Status != StatusWarning || health.Checks[1].Status != StatusWarning {
+		t.Errorf("Expected a pending reference warning, got %+v", health)
+	}
+	consistency = ConsistencyReport{}
+	if code := get(t, srv, http.MethodGet, "/consistency", &consistency); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 from an inconsistent store, got %d", code)
+	}
+	if consistency.Status != StatusInconsistent || len(consistency.Warnings) != 1 {
+		t.Errorf("Unexpected consistency report: %+v", consistency)
+	}
+
+	var failure errorResponse
+	if code := get(t, srv, http.MethodPost, "/healthz", &failure); code != http.StatusMethodNotAllowed || failure.Error == "" {
+		t.Errorf("Expected 405 for POST, got %d %+v", code, failure)
+	}
+}
+
+func TestRunRetention(t *testing.T) {
+	store := semantic.NewSemanticStore()
+	store.AddEntity("E1001", "Highway", "")
+	store.AddEntity("E1002", "Truck", "")
+	store.CreateAssertion("F1001", "E1002", "R1001", "E1001")
+	store.SetRetentionPolicy(&semantic.RetentionPolicy{Rules: []semantic.RetentionRule{{TTL: "1ns"}}})
+	srv := New(store)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	audited := make(chan *semantic.RetentionReport, 1)
+	go srv.RunRetention(ctx, time.Millisecond, func(report *semantic.RetentionReport, err error) {
+		if err != nil {
+			t.Errorf("Purge failed: %v", err)
+		}
+		audited <- report
+		cancel()
+	})
+
+	select {
+	case report := <-audited:
+		if len(report.Removed) != 1 || report.Removed[0] != "F1001" {
+			t.Errorf("Expected the expired assertion purged, got %v", report.Removed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a purge to be audited")
+	}
+	srv.Update(func(store *semantic.SemanticStore) error {
+		if _, removed := store.GetTombstone("F1001"); !removed {
+			t.Error("Expected a tombstone for the purged assertion")
+		}
+		return nil
+	})
+}
+
+func TestQueryPagination(t *testing.T) {
+	store := semantic.NewSemanticStore()
+	store.AddEntity("E1001", "Highway", "10B2TR-INF-HWY")
+	store.AddEntity("E1002", "Truck", "10B3TR-VEH-TRK")
+	store.AddEntity("E1003", "Van", "10B3TR-VEH-VAN")
+	store.AddRelation("R1001", "uses", "LOGISTICS")
+	store.CreateAssertion("F1001", "E1002", "R1001", "E1001")
+	store.CreateAssertion("F1002", "E1003", "R1001", "E1001")
+	srv := New(store)
+
+	var entities EntitiesResponse
+	if code := get(t, srv, http.MethodGet, "/entities?limit=2", &entities); code != http.StatusOK {
+		t.Fatalf("Expected 200 from /entities, got %d", code)
+	}
+	if len(entities.Entities) != 2 || entities.Entities[0].ID != "E1001" || !entities.More || entities.Cursor != "E1002" {
+		t.Errorf("Unexpected first page: %+v", entities)
+	}
+	var last EntitiesResponse
+	get(t, srv, http.MethodGet, "/entities?limit=2&cursor="+entities.Cursor, &last)
+	if len(last.Entities) != 1 || last.Entities[0].ID != "E1003" || last.More || last.Cursor != "" {
+		t.Errorf("Unexpected last page: %+v", last)
+	}
+	var vehicles EntitiesResponse
+	get(t, srv, http.MethodGet, "/entities?pattern=10B-3TR", &vehicles)
+	if len(vehicles.Entities) != 2 || vehicles.Entities[0].TOSID != "10B3TR-VEH-TRK" {
+		t.Errorf("Unexpected pattern query: %+v", vehicles)
+	}
+
+	var assertions AssertionsResponse
+	if code := get(t, srv, http.MethodGet, "/assertions?object=E1001&offset=1", &assertions); code != http.StatusOK {
+		t.Fatalf("Expected 200 from /assertions, got %d", code)
+	}
+	if len(assertions.Assertions) != 1 || assertions.Assertions[0].ID != "F1002" || assertions.More {
+		t.Errorf("Unexpected assertions page: %+v", assertions)
+	}
+
+	var byLabel EntitiesResponse
+	get(t, srv, http.MethodGet, "/entities?order=label&limit=1", &byLabel)
+	if len(byLabel.Entities) != 1 || byLabel.Entities[0].ID != "E1001" || !byLabel.More {
+		t.Errorf("Unexpected label-ordered page: %+v", byLabel)
+	}
+	get(t, srv, http.MethodGet, "/entities?order=label&limit=1&cursor="+byLabel.Cursor, &byLabel)
+	if len(byLabel.Entities) != 1 || byLabel.Entities[0].ID != "E1002" {
+		t.Errorf("Unexpected second label-ordered page: %+v", byLabel)
+	}
+
+	var failure errorResponse
+	for _, path := range []string{"/entities?limit=0", "/entities?order=confidence", "/entities?pattern=10B&label=Van", "/assertions", "/assertions?subject=E1002&object=E1001"} {
+		if code := get(t, srv, http.MethodGet, path, &failure); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 from %s, got %d", path, code)
+		}
+	}
+}
+
+func TestSavedQueries(t *testing.T) {
+	store := semantic.NewSemanticStore()
+	store.AddEntity("E1001", "Field hospital", "10B3MD-FAC-HSP")
+	store.AddEntity("E2001", "North region", "10B2GE-REG-NTH")
+	store.AddRelation("R1001", "located in", "SPATIAL")
+	store.CreateAssertion("F1001", "E1001", "R1001", "E2001")
+	store.RegisterQuery(semantic.SavedQuery{
+		Name:   "medical_needs_in",
+		Params: []string{"region"},
+		Query:  semantic.EntityQuery{Pattern: "10B-3MD", Object: "{region}"},
+	})
+	srv := New(store)
+
+	var queries []semantic.SavedQuery
+	if code := get(t, srv, http.MethodGet, "/queries", &queries); code != http.StatusOK {
+		t.Fatalf("Expected 200 from /queries, got %d", code)
+	}
+	if len(queries) != 1 || queries[0].Name != "medical_needs_in" {
+		t.Errorf("Unexpected saved queries: %+v", queries)
+	}
+
+	var entities EntitiesResponse
+	if code := get(t, srv, http.MethodGet, "/queries/medical_needs_in?region=E2001", &entities); code != http.StatusOK {
+		t.Fatalf("Expected 200 running the query, got %d", code)
+	}
+	if len(entities.Entities) != 1 || entities.Entities[0].ID != "E1001" {
+		t.Errorf("Unexpected query results: %+v", entities)
+	}
+
+	var failure errorResponse
+	if code := get(t, srv, http.MethodGet, "/queries/medical_needs_in", &failure); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a missing argument, got %d", code)
+	}
+	if code := get(t, srv, http.MethodGet, "/queries/unknown", &failure); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown query, got %d", code)
+	}
+}
+
+func TestSubscriptions(t *testing.T) {
+	store := semantic.NewSemanticStore()
+	store.AddEntity("E1001", "Water need", "11B1ME-NED-WAT")
+	store.AddRelation("R1001", "located in", "SPATIAL")
+	srv := New(store)
+
+	notifications := make(chan Notification, 4)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n Notification
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			t.Errorf("Failed to decode notification: %v", err)
+		}
+		notifications <- n
+	}))
+	defer hook.Close()
+
+	if err := srv.Subscribe(Subscription{ID: "logistics", URL: hook.URL, Pattern: "11B-1ME-NED"}); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	receive := func() Notification {
+		t.Helper()
+		select {
+		case n := <-notifications:
+			return n
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected a notification")
+			return Notification{}
+		}
+	}
+
+	// Entities matching when the subscription was registered are not notified
+	srv.Update(func(store *semantic.SemanticStore) error {
+		return store.AddEntity("E1002", "Drinking water need", "11B1ME-NED-WAT")
+	})
+	n := receive()
+	if n.Subscription != "logistics" || len(n.Changes) != 1 || n.Changes[0].Change != ChangeAdded || n.Changes[0].Entity.ID != "E1002" {
+		t.Errorf("Unexpected notification: %+v", n)
+	}
+
+	srv.Update(func(store *semantic.SemanticStore) error {
+		store.AddEntity("E2001", "North region", "10B2GE-REG-NTH")
+		return store.CreateAssertion("F1001", "E1001", "R1001", "E2001")
+	})
+	n = receive()
+	if len(n.Changes) != 1 || n.Changes[0].Change != ChangeChanged || n.Changes[0].Entity.ID != "E1001" {
+		t.Errorf("Unexpected notification: %+v", n)
+	}
+
+	var subscriptions SubscriptionsResponse
+	if code := get(t, srv, http.MethodGet, "/subscriptions", &subscriptions); code != http.StatusOK {
+		t.Fatalf("Expected 200 from /subscriptions, got %d", code)
+	}
+	if len(subscriptions.Subscriptions) != 1 || subscriptions.Subscriptions[0].Pattern != "11B-1ME-NED" {
+		t.Errorf("Unexpected subscriptions: %+v", subscriptions)
+	}
+	var failure errorResponse
+	if err := srv.Subscribe(Subscription{ID: "bad", URL: "ftp://example.com", Pattern: "11B1"}); err == nil {
+		t.Error("Expected an invalid webhook URL to be rejected")
+	}
+	if code := get(t, srv, http.MethodDelete, "/subscriptions/unknown", &failure); code != http.StatusNotFound {
+		t.Errorf("Expected 404 removing an unknown subscription, got %d", code)
+	}
+	var removed struct{}
+	if code := get(t, srv, http.MethodDelete, "/subscriptions/logistics", &removed); code != http.StatusOK {
+		t.Errorf("Expected 200 removing the subscription, got %d", code)
+	}
+	if len(srv.Subscriptions()) != 0 {
+		t.Error("Expected no subscriptions left")
+	}
+}
+
+func TestQuotas(t *testing.T) {
+	srv := New(semantic.NewSemanticStore())
+	srv.SetQuotas(QuotaOptions{Rate: 0.001, Burst: 2, MaxBodyBytes: 64})
+
+	var health HealthReport
+	for i := 0; i < 2; i++ {
+		if code := get(t, srv, http.MethodGet, "/healthz", &health); code != http.StatusOK {
+			t.Fatalf("Expected request %d within the burst, got %d", i+1, code)
+		}
+	}
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 429 with Retry-After over the rate, got %d", rec.Code)
+	}
+
+	// Other principals have buckets of their own
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("X-API-Key", "logistics")
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected another principal to be served, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(strings.Repeat(" ", 100)))
+	req.Header.Set("X-API-Key", "reports")
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for an oversized body, got %d", rec.Code)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	srv := New(semantic.NewSemanticStore())
+
+	var doc OpenAPIDocument
+	if code := get(t, srv, http.MethodGet, "/openapi.json", &doc); code != http.StatusOK {
+		t.Fatalf("Expected 200 from /openapi.json, got %d", code)
+	}
+	if doc.OpenAPI != openAPIVersion || len(doc.Paths) != 11 {
+		t.Errorf("Unexpected document: %s with %d paths", doc.OpenAPI, len(doc.Paths))
+	}
+	entities := doc.Paths["/entities"]["get"]
+	if entities == nil || entities.Responses["200"].Content["application/json"].Schema.Ref != "#/components/schemas/EntitiesResponse" {
+		t.Errorf("Unexpected /entities operation: %+v", entities)
+	}
+	if subscribe := doc.Paths["/subscriptions"]["post"]; subscribe == nil || subscribe.RequestBody == nil {
+		t.Errorf("Expected a request body for subscribing, got %+v", subscribe)
+	}
+	for _, name := range []string{"Entity", "Assertion", "ConsistencyReport", "SemanticSavedQuery", "ErrorResponse"} {
+		if doc.Components.Schemas[name] == nil {
+			t.Errorf("Expected a %s schema", name)
+		}
+	}
+	assertion := doc.Components.Schemas["Assertion"]
+	if assertion.Properties["confidence"].Type != "number" || len(assertion.Required) != 5 {
+		t.Errorf("Unexpected Assertion schema: %+v", assertion)
+	}
+}
+
+func TestExport(t *testing.T) {
+	store := semantic.NewSemanticStore()
+	store.AddEntity("E1001", "Water need", "11B1ME-NED-WAT")
+	store.AddEntity("E1002", "Truck", "10B3TR-VEH-TRK")
+	store.AddEntity("E1003", "Highway", "10B2TR-INF-HWY")
+	store.AddRelation("R1001", "supplies", "LOGISTICS")
+	store.AddRelation("R1002", "uses", "LOGISTICS")
+	store.CreateAssertion("F1001", "E1002", "R1001", "E1001")
+	store.CreateAssertion("F1002", "E1002", "R1002", "E1003")
+	srv := New(store)
+
+	export := func(path string, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := export("/export", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/x-kmac" {
+		t.Fatalf("Expected KMAC text by default, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	loaded := semantic.NewSemanticStore()
+	if err := loaded.LoadKMAC(rec.Body); err != nil {
+		t.Fatalf("Failed to load the export: %v", err)
+	}
+	if stats := loaded.GetStatistics(); stats["entities"] != 3 || stats["assertions"] != 2 {
+		t.Errorf("Unexpected export contents: %v", stats)
+	}
+
+	for accept, contentType := range map[string]string{
+		"application/json":                     "application/json",
+		"application/ld+json":                  "application/ld+json",
+		"text/turtle":                          "text/turtle",
+		"text/html, text/csv;q=0.9, */*;q=0.1": "text/csv",
+	} {
+		if rec := export("/export", accept); rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != contentType {
+			t.Errorf("Accept %q: expected %s, got %d %q", accept, contentType, rec.Code, rec.Header().Get("Content-Type"))
+		}
+	}
+
+	var statements struct {
+		Statements []struct {
+			Keyword string `json:"keyword"`
+			ID      string `json:"id"`
+		} `json:"statements"`
+	}
+	rec = export("/export?pattern=11B-1ME-NED", "application/json")
+	if err := json.NewDecoder(rec.Body).Decode(&statements); err != nil {
+		t.Fatalf("Failed to decode the JSON export: %v", err)
+	}
+	var ids []string
+	for _, stmt := range statements.Statements {
+		ids = append(ids, stmt.ID)
+	}
+	if strings.Join(ids, ",") != "E1001,E1002,R1001,F1001" {
+		t.Errorf("Expected the need and what supplies it, got %v", ids)
+	}
+
+	if rec := export("/export", "image/png"); rec.Code != http.StatusNotAcceptable {
+		t.Errorf("Expected 406 for an unacceptable type, got %d", rec.Code)
+	}
+	if rec := export("/export?format=yaml", ""); rec.Code != http.StatusNotAcceptable {
+		t.Errorf("Expected 406 for an unknown format, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/export", nil)
+	req.Header.Set("Accept-Encoding", "br, gzip;q=0.8")
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected a gzip-compressed export, got %q", rec.Header().Get("Content-Encoding"))
+	}
+	loaded = semantic.NewSemanticStore()
+	if err := loaded.LoadKMAC(rec.Body); err != nil {
+		t.Fatalf("Failed to load the compressed export: %v", err)
+	}
+	if stats := loaded.GetStatistics(); stats["entities"] != 3 {
+		t.Errorf("Unexpected compressed export contents: %v", stats)
+	}
+}
+
+func TestMirror(t *testing.T) {
+	store := semantic.NewSemanticStore()
+	store.AddEntity("E1001", "Highway", "10B2TR-INF-HWY")
+	srv := NewMirror(store)
+
+	var entities EntitiesResponse
+	if code := get(t, srv, http.MethodGet, "/entities", &entities); code != http.StatusOK || len(entities.Entities) != 1 {
+		t.Fatalf("Expected the mirror to serve queries, got %d %+v", code, entities)
+	}
+	if err := srv.Update(func(store *semantic.SemanticStore) error { return nil }); err != ErrReadOnly {
+		t.Errorf("Expected ErrReadOnly from Update, got %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(`{"id":"s","url":"http://example.com","pattern":"10B"}`))
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 subscribing to a mirror, got %d", rec.Code)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	refreshes := 0
+	failed := make(chan error, 1)
+	go srv.RunMirror(ctx, time.Millisecond, func(ctx context.Context) (*semantic.SemanticStore, error) {
+		refreshes++
+		if refreshes > 1 {
+			return nil, errors.New("source unreachable")
+		}
+		next := semantic.NewSemanticStore()
+		next.AddEntity("E1001", "Highway", "10B2TR-INF-HWY")
+		next.AddEntity("E1002", "Truck", "10B3TR-VEH-TRK")
+		return next, nil
+	}, func(err error) {
+		select {
+		case failed <- err:
+			cancel()
+		default:
+		}
+	})
+	select {
+	case <-failed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a failed refresh to be reported")
+	}
+
+	// The last good refresh is still served
+	get(t, srv, http.MethodGet, "/entities", &entities)
+	if len(entities.Entities) != 2 {
+		t.Errorf("Expected the refreshed store served, got %+v", entities)
+	}
+	var health HealthReport
+	get(t, srv, http.MethodGet, "/healthz", &health)
+	if health.Status != StatusWarning || health.Checks[len(health.Checks)-1].Name != "mirror" {
+		t.Errorf("Expected a mirror warning, got %+v", health)
+	}
+}
@@ -0,0 +1,280 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+)
+
+// Kinds of change a notification reports
+const (
+	ChangeAdded   = "added"   // The entity has started matching the subscription
+	ChangeChanged = "changed" // A matching entity or an assertion involving it has changed
+)
+
+// webhookTimeout bounds each webhook delivery
+const webhookTimeout = 10 * time.Second
+
+// Subscription asks for a webhook to be called when entities matching a
+// TOSID pattern or a saved query are added or changed, such as logistics
+// being told of any 11B1-NED-WAT need. Exactly one of Pattern and Query is
+// given.
+type Subscription struct {
+	ID      string `json:"id"`
+	URL     string `json:"url"`
+	Pattern string `json:"pattern,omitempty"` // TOSID pattern
+	Query   string `json:"query,omitempty"`   // Saved query call, such as medical_needs_in(E2001)
+}
+
+// Notification is the body POSTed to a subscription's webhook
+type Notification struct {
+	Subscription string         `json:"subscription"`
+	Changes      []EntityChange `json:"changes"`
+	SentAt       time.Time      `json:"sent_at"`
+}
+
+// EntityChange is a change to an entity in a notification
+type EntityChange struct {
+	Change string `json:"change"` // ChangeAdded or ChangeChanged
+	Entity Entity `json:"entity"`
+}
+
+// subscription is a registered subscription and the digests of the
+// entities it matched when last checked
+type subscription struct {
+	Subscription
+	digests map[string]string // Entity ID -> digest
+}
+
+// SubscriptionsResponse is the response of /subscriptions
+type SubscriptionsResponse struct {
+	Subscriptions []Subscription `json:"subscriptions"`
+}
+
+// Subscribe registers a subscription, replacing any with the same ID. Only
+// changes made after it is registered are notified. Mirrors take no
+// subscriptions, and return ErrReadOnly.
+func (s *Server) Subscribe(sub Subscription) error {
+	if s.mirror != nil {
+		return ErrReadOnly
+	}
+	if sub.ID == "" {
+		return fmt.Errorf("subscription has no ID")
+	}
+	if target, err := url.Parse(sub.URL); err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return fmt.Errorf("subscription %s: invalid webhook URL %q", sub.ID, sub.URL)
+	}
+	if (sub.Pattern == "") == (sub.Query == "") {
+		return fmt.Errorf("subscription %s: give exactly one of pattern and query", sub.ID)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	digests, _, err := s.matchDigests(sub)
+	if err != nil {
+		return fmt.Errorf("subscription %s: %v", sub.ID, err)
+	}
+	if s.subscriptions == nil {
+		s.subscriptions = make(map[string]*subscription)
+	}
+	s.subscriptions[sub.ID] = &subscription{Subscription: sub, digests: digests}
+	return nil
+}
+
+// Unsubscribe removes a subscription, reporting whether it was registered
+func (s *Server) Unsubscribe(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, exists := s.subscriptions[id]
+	delete(s.subscriptions, id)
+	return exists
+}
+
+// Subscriptions returns the registered subscriptions, ordered by ID
+func (s *Server) Subscriptions() []Subscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	subscriptions := make([]Subscription, 0, len(s.subscriptions))
+	for _, sub := range s.subscriptions {
+		subscriptions = append(subscriptions, sub.Subscription)
+	}
+	sort.Slice(subscriptions, func(i, j int) bool { return subscriptions[i].ID < subscriptions[j].ID })
+	return subscriptions
+}
+
+// SetWebhookErrorHandler sets a function called with each notification
+// that could not be delivered, for logging. Failed deliveries are not
+// retried.
+func (s *Server) SetWebhookErrorHandler(fn func(n *Notification, err error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.webhookErrors = fn
+}
+
+// notifySubscribers checks each subscription for matching entities that
+// were added or changed since it was last checked, and delivers a
+// notification of them in the background. It is called with the store
+// locked for writing, after each change made through the server.
+func (s *Server) notifySubscribers() {
+	for _, sub := range s.subscriptions {
+		digests, entities, err := s.matchDigests(sub.Subscription)
+		if err != nil {
+			// A saved query the subscription relies on may have gone;
+			// it is checked again after the next change
+			continue
+		}
+		notification := &Notification{Subscription: sub.ID}
+		for _, id := range sortedKeys(digests) {
+			change := ChangeChanged
+			previous, matched := sub.digests[id]
+			switch {
+			case !matched:
+				change = ChangeAdded
+			case previous == digests[id]:
+				continue
+			}
+			notification.Changes = append(notification.Changes, EntityChange{Change: change, Entity: toEntity(entities[id])})
+		}
+		sub.digests = digests
+		if len(notification.Changes) > 0 {
+			go s.deliver(sub.URL, notification, s.webhookErrors)
+		}
+	}
+}
+
+// deliver POSTs a notification to a webhook
+func (s *Server) deliver(target string, notification *Notification, onError func(*Notification, error)) {
+	notification.SentAt = time.Now().UTC()
+	body, err := json.Marshal(notification)
+	if err == nil {
+		var resp *http.Response
+		if resp, err = s.webhookClient.Post(target, "application/json", bytes.NewReader(body)); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode/100 != 2 {
+				err = fmt.Errorf("webhook responded %s", resp.Status)
+			}
+		}
+	}
+	if err != nil && onError != nil {
+		onError(notification, err)
+	}
+}
+
+// matchDigests returns the digests of the entities a subscription matches,
+// and the entities, by ID
+func (s *Server) matchDigests(sub Subscription) (map[string]string, map[string]*semantic.EntityReference, error) {
+	var page semantic.Page[*semantic.EntityReference]
+	var err error
+	if sub.Pattern != "" {
+		page, err = s.store.FindEntitiesByTOSIDPatternPage(sub.Pattern, semantic.PageRequest{})
+	} else {
+		page, err = s.store.CallQuery(sub.Query, semantic.PageRequest{})
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	digests := make(map[string]string, len(page.Items))
+	entities := make(map[string]*semantic.EntityReference, len(page.Items))
+	for _, entityRef := range page.Items {
+		digest, err := s.entityDigest(entityRef)
+		if err != nil {
+			return nil, nil, err
+		}
+		digests[entityRef.KMACEntity.ID()] = digest
+		entities[entityRef.KMACEntity.ID()] = entityRef
+	}
+	return digests, entities, nil
+}
+
+// entityDigest hashes an entity along with the assertions involving it, so
+// a change to either changes the digest
+func (s *Server) entityDigest(entityRef *semantic.EntityReference) (string, error) {
+	var assertions []*kmac.Assertion
+	s.store.RangeAssertionsForEntity(entityRef.KMACEntity.ID(), func(assertion *kmac.Assertion) bool {
+		assertions = append(assertions, assertion)
+		return true
+	})
+	sort.Slice(assertions, func(i, j int) bool { return assertions[i].ID() < assertions[j].ID() })
+	statements := []kmac.Statement{entityRef.KMACEntity}
+	for _, assertion := range assertions {
+		statements = append(statements, assertion)
+	}
+
+	var buf bytes.Buffer
+	if err := kmac.NewTextSerializer().Encode(&buf, statements); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// handleSubscriptions lists the subscriptions on GET and registers the
+// subscription in the body on POST
+func (s *Server) handleSubscriptions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		writeJSON(w, http.StatusOK, SubscriptionsResponse{Subscriptions: s.Subscriptions()})
+	case http.MethodPost:
+		if !s.allowWrite(w) {
+			return
+		}
+		var sub Subscription
+		if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+			status := http.StatusBadRequest
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			writeJSON(w, status, errorResponse{Error: fmt.Sprintf("invalid subscription: %v", err)})
+			return
+		}
+		if err := s.Subscribe(sub); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusCreated, sub)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: fmt.Sprintf("method %s not allowed", r.Method)})
+	}
+}
+
+// handleSubscription removes the subscription named by the path,
+// /subscriptions/<id>, on DELETE
+func (s *Server) handleSubscription(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "DELETE")
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: fmt.Sprintf("method %s not allowed", r.Method)})
+		return
+	}
+	if !s.allowWrite(w) {
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/subscriptions/")
+	if !s.Unsubscribe(id) {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: fmt.Sprintf("no subscription %s", id)})
+		return
+	}
+	writeJSON(w, http.StatusOK, struct{}{})
+}
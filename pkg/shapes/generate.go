@@ -0,0 +1,304 @@
+package shapes
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// generatedShape is a shape as the generator writes it
+type generatedShape struct {
+	Type       string // Go type name
+	Shape      Shape
+	Properties []generatedProperty
+	Relations  []generatedRelation
+}
+
+// generatedProperty is a property constraint as the generator writes it
+type generatedProperty struct {
+	Method  string
+	Key     string
+	Numeric bool // Accessed as float64
+}
+
+// generatedRelation is a relation constraint as the generator writes it
+type generatedRelation struct {
+	Method   string
+	Relation string
+	Inbound  bool
+	Single   bool   // At most one, so accessed as a value rather than a slice
+	Target   string // Go type of the shape whose pattern is the target pattern; empty for IDs
+}
+
+// Generate writes Go source for a package that gives typed access to the
+// entities of each shape in the set, so applications can read
+// helicopter.Capacity() rather than looking up the "capacity" property by
+// name. Each shape becomes a type named after it, with a Get function, a
+// List function, and an accessor per property and relation constraint.
+// Properties whose pattern is numeric are float64; the rest are strings.
+// Relations lead to IDs, or to the shape whose pattern is the constraint's
+// target pattern; those capped at one lead to a single value, and inbound
+// ones are suffixed Inbound. The package should hold no other generated
+// file, since each declares the same helpers.
+func (s *ShapeSet) Generate(w io.Writer, packageName string) error {
+	if !token.IsIdentifier(packageName) {
+		return fmt.Errorf("invalid package name %q", packageName)
+	}
+	if err := s.Check(); err != nil {
+		return err
+	}
+	if len(s.Shapes) == 0 {
+		return fmt.Errorf("no shapes to generate code for")
+	}
+
+	types := make(map[string]string) // Go type name by shape pattern
+	declared := map[string]string{}  // Shape name by top-level name
+	for _, shape := range s.Shapes {
+		typeName := goName(shape.Name)
+		for _, name := range []string{typeName, "Get" + typeName, "List" + typeName} {
+			if other, exists := declared[name]; exists {
+				return fmt.Errorf("shapes %s and %s both generate %s", other, shape.Name, name)
+			}
+			declared[name] = shape.Name
+		}
+		if _, exists := types[shape.Pattern]; !exists {
+			types[shape.Pattern] = typeName
+		}
+	}
+
+	generated := make([]generatedShape, 0, len(s.Shapes))
+	for _, shape := range s.Shapes {
+		g := generatedShape{Type: goName(shape.Name), Shape: shape}
+		methods := map[string]string{"ID": "", "Label": "", "Entity": ""} // Constraint by method
+		method := func(name string, constraint string) error {
+			for _, m := range []string{name, "Set" + name} {
+				if other, exists := methods[m]; exists {
+					if other == "" {
+						other = "the built-in method"
+					}
+					return fmt.Errorf("shape %s: %s and %s both generate %s", shape.Name, other, constraint, m)
+				}
+				methods[m] = constraint
+			}
+			return nil
+		}
+
+		for _, property := range shape.Properties {
+			p := generatedProperty{Method: goName(property.Key), Key: property.Key, Numeric: property.Pattern == numericPattern}
+			if err := method(p.Method, "property "+property.Key); err != nil {
+				return err
+			}
+			g.Properties = append(g.Properties, p)
+		}
+		for _, relation := range shape.Relations {
+			r := generatedRelation{
+				Method:   goName(relation.Relation),
+				Relation: relation.Relation,
+				Inbound:  relation.Direction == Inbound,
+				Single:   relation.Max == 1,
+			}
+			if r.Inbound {
+				r.Method += "Inbound"
+			}
+			if relation.TargetPattern != "" {
+				r.Target = types[relation.TargetPattern]
+			}
+			if err := method(r.Method, "relation "+relation.Relation); err != nil {
+				return err
+			}
+			g.Relations = append(g.Relations, r)
+		}
+		generated = append(generated, g)
+	}
+
+	var buf bytes.Buffer
+	err := generateTemplate.Execute(&buf, struct {
+		Package string
+		Shapes  []generatedShape
+	}{packageName, generated})
+	if err != nil {
+		return fmt.Errorf("failed to generate code: %v", err)
+	}
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to format generated code: %v", err)
+	}
+	_, err = w.Write(source)
+	return err
+}
+
+// goName turns a shape name, property key, or relation into an exported Go
+// identifier: "serial_number" becomes SerialNumber, and OPERATED_BY becomes
+// OperatedBy. Names that would start with a digit, such as the class
+// patterns Infer names shapes after, are prefixed with X.
+func goName(name string) string {
+	var sb strings.Builder
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if strings.ToUpper(word) == word {
+			word = strings.ToLower(word)
+		}
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		sb.WriteString(string(runes))
+	}
+	if sb.Len() == 0 || unicode.IsDigit(rune(sb.String()[0])) {
+		return "X" + sb.String()
+	}
+	return sb.String()
+}
+
+var generateTemplate = template.Must(template.New("generate").Parse(`// Code generated from shapes by kmac generate. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+)
+{{range .Shapes}}{{$type := .Type}}
+// {{.Type}} is an entity of shape {{.Shape.Name}}{{with .Shape.Pattern}}, matching TOSID pattern {{.}}{{end}}
+type {{.Type}} struct {
+	store  *semantic.SemanticStore
+	entity *semantic.EntityReference
+}
+
+// Get{{.Type}} returns the entity with an ID, failing if it does not match the shape's pattern
+func Get{{.Type}}(store *semantic.SemanticStore, id string) (*{{.Type}}, error) {
+	entityRef, err := store.GetEntity(id)
+	if err != nil {
+		return nil, err
+	}
+	if !matchesPattern(entityRef, {{printf "%q" .Shape.Pattern}}) {
+		return nil, fmt.Errorf("entity %s is not of shape %s", id, {{printf "%q" .Shape.Name}})
+	}
+	return &{{.Type}}{store: store, entity: entityRef}, nil
+}
+
+// List{{.Type}} returns the entities matching the shape's pattern, ordered by ID
+func List{{.Type}}(store *semantic.SemanticStore) []*{{.Type}} {
+	var entities []*{{.Type}}
+	store.RangeEntities(func(entityRef *semantic.EntityReference) bool {
+		if matchesPattern(entityRef, {{printf "%q" .Shape.Pattern}}) {
+			entities = append(entities, &{{.Type}}{store: store, entity: entityRef})
+		}
+		return true
+	})
+	sort.Slice(entities, func(i, j int) bool { return entities[i].ID() < entities[j].ID() })
+	return entities
+}
+
+// ID returns the entity's ID
+func (e *{{.Type}}) ID() string {
+	return e.entity.KMACEntity.ID()
+}
+
+// Label returns the entity's label
+func (e *{{.Type}}) Label() string {
+	return e.entity.KMACEntity.Label()
+}
+
+// Entity returns the underlying entity
+func (e *{{.Type}}) Entity() *semantic.EntityReference {
+	return e.entity
+}
+{{range .Properties}}{{if .Numeric}}
+// {{.Method}} returns the {{.Key}} property
+func (e *{{$type}}) {{.Method}}() (float64, error) {
+	return numberProperty(e.entity, {{printf "%q" .Key}})
+}
+
+// Set{{.Method}} sets the {{.Key}} property
+func (e *{{$type}}) Set{{.Method}}(value float64) {
+	e.entity.KMACEntity.SetProperty({{printf "%q" .Key}}, strconv.FormatFloat(value, 'g', -1, 64))
+}
+{{else}}
+// {{.Method}} returns the {{.Key}} property, or "" if it is not set
+func (e *{{$type}}) {{.Method}}() string {
+	value, _ := e.entity.KMACEntity.GetProperty({{printf "%q" .Key}})
+	return value
+}
+
+// Set{{.Method}} sets the {{.Key}} property
+func (e *{{$type}}) Set{{.Method}}(value string) {
+	e.entity.KMACEntity.SetProperty({{printf "%q" .Key}}, value)
+}
+{{end}}{{end}}{{range .Relations}}
+// {{.Method}} returns the {{if .Target}}{{.Target}} {{if .Single}}entity{{else}}entities{{end}}{{else}}{{if .Single}}ID{{else}}IDs{{end}}{{end}} {{if .Inbound}}related to the entity by{{else}}the entity is related to by{{end}} {{.Relation}}{{if .Single}}, or {{if .Target}}nil{{else}}""{{end}} if there is none{{end}}
+{{- if .Target}}; related entities of other shapes are skipped{{end}}
+func (e *{{$type}}) {{.Method}}() {{if not .Single}}[]{{end}}{{if .Target}}*{{.Target}}{{else}}string{{end}} {
+	{{- if .Target}}
+	var related []*{{.Target}}
+	for _, id := range relatedIDs(e.store, e.ID(), {{printf "%q" .Relation}}, {{.Inbound}}) {
+		if entity, err := Get{{.Target}}(e.store, id); err == nil {
+			related = append(related, entity)
+		}
+	}
+	{{- else}}
+	related := relatedIDs(e.store, e.ID(), {{printf "%q" .Relation}}, {{.Inbound}})
+	{{- end}}
+	{{- if .Single}}
+	if len(related) == 0 {
+		return {{if .Target}}nil{{else}}""{{end}}
+	}
+	return related[0]
+	{{- else}}
+	return related
+	{{- end}}
+}
+{{end}}{{end}}
+// matchesPattern reports whether an entity's TOSID matches a pattern; the
+// empty pattern matches every entity
+func matchesPattern(entityRef *semantic.EntityReference, pattern string) bool {
+	if pattern == "" {
+		return true
+	}
+	return entityRef.TOSIDObj != nil && entityRef.TOSIDObj.MatchesPattern(pattern)
+}
+
+// numberProperty returns a numeric property of an entity
+func numberProperty(entityRef *semantic.EntityReference, key string) (float64, error) {
+	value, set := entityRef.KMACEntity.GetProperty(key)
+	if !set {
+		return 0, fmt.Errorf("entity %s has no %s property", entityRef.KMACEntity.ID(), key)
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("entity %s has non-numeric %s property %q", entityRef.KMACEntity.ID(), key, value)
+	}
+	return number, nil
+}
+
+// relatedIDs returns the IDs an entity is related to by a relation, named by
+// ID or by the label of a relation defined in the store, in the order asserted
+func relatedIDs(store *semantic.SemanticStore, id string, relation string, inbound bool) []string {
+	assertions := store.FindAssertionsBySubject(id)
+	if inbound {
+		assertions = store.FindAssertionsByObject(id)
+	}
+	var ids []string
+	for _, assertion := range assertions {
+		if assertion.Relation() != relation {
+			defined, err := store.GetRelation(assertion.Relation())
+			if err != nil || defined.Label() != relation {
+				continue
+			}
+		}
+		if inbound {
+			ids = append(ids, assertion.Subject())
+		} else {
+			ids = append(ids, assertion.Object())
+		}
+	}
+	return ids
+}
+`))
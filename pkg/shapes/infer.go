@@ -0,0 +1,310 @@
+package shapes
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+	"github.com/ha1tch/tosid-go/pkg/tosid"
+)
+
+// numericPattern is proposed for properties whose observed values are all numbers
+const numericPattern = `^-?[0-9]+(\.[0-9]+)?$`
+
+// PatternCount counts occurrences of an entity class
+type PatternCount struct {
+	Pattern string
+	Count   int
+}
+
+// PropertyUsage describes how a property key is used within a class
+type PropertyUsage struct {
+	Key     string
+	Count   int  // Entities carrying the property
+	Numeric bool // Every observed value parses as a number
+}
+
+// RelationUsage describes the cardinality of a relation within a class
+type RelationUsage struct {
+	Relation  string
+	Direction string // Outbound or Inbound
+	Entities  int    // Entities with at least one assertion
+	Total     int    // Assertions across the class
+	Min, Max  int    // Assertions per entity, counting entities without any
+}
+
+// Mean returns the average number of assertions per entity of the class
+func (u RelationUsage) Mean(classSize int) float64 {
+	if classSize == 0 {
+		return 0
+	}
+	return float64(u.Total) / float64(classSize)
+}
+
+// ClassSchema summarizes the entities sharing a TOSID prefix
+type ClassSchema struct {
+	Pattern    string
+	Entities   int
+	Properties []PropertyUsage // Sorted by key
+	Relations  []RelationUsage // Sorted by relation, outbound first
+}
+
+// RelationSchema records the classes a relation connects
+type RelationSchema struct {
+	Relation   string
+	Assertions int
+	Domains    []PatternCount // Subject classes, most frequent first
+	Ranges     []PatternCount // Object classes, most frequent first
+}
+
+// Schema is the structure observed in a store
+type Schema struct {
+	Classes      []ClassSchema    // Sorted by pattern
+	Relations    []RelationSchema // Sorted by relation
+	Unclassified int              // Entities without a TOSID, left out of the classes
+}
+
+// Infer inspects a store and summarizes its structure. Entities are grouped into
+// classes by TOSID prefix: the taxonomy code and netmask followed by the given
+// number of identifier segments, so segments 1 groups "10B3TR-AIR-JET" under
+// "10B-3TR". Relations are named by their label when defined in the store.
+func Infer(store *semantic.SemanticStore, segments int) *Schema {
+	schema := &Schema{}
+	classOf := make(map[string]string)
+	members := make(map[string][]*semantic.EntityReference)
+
+	store.RangeEntities(func(entityRef *semantic.EntityReference) bool {
+		if entityRef.TOSIDObj == nil {
+			schema.Unclassified++
+			return true
+		}
+		class := classPattern(entityRef.TOSIDObj, segments)
+		classOf[entityRef.KMACEntity.ID()] = class
+		members[class] = append(members[class], entityRef)
+		return true
+	})
+
+	type relationKey struct{ relation, direction string }
+	relations := make(map[string]*RelationSchema)
+	domains := make(map[string]map[string]int)
+	ranges := make(map[string]map[string]int)
+
+	for class, entities := range members {
+		cs := ClassSchema{Pattern: class, Entities: len(entities)}
+
+		properties := make(map[string]*PropertyUsage)
+		perEntity := make(map[relationKey][]int)
+		for i, entityRef := range entities {
+			for key, value := range entityRef.KMACEntity.GetAllProperties() {
+				usage, ok := properties[key]
+				if !ok {
+					usage = &PropertyUsage{Key: key, Numeric: true}
+					properties[key] = usage
+				}
+				usage.Count++
+				if _, err := strconv.ParseFloat(value, 64); err != nil {
+					usage.Numeric = false
+				}
+			}
+
+			entityID := entityRef.KMACEntity.ID()
+			count := func(relation, direction string) {
+				key := relationKey{relation, direction}
+				if perEntity[key] == nil {
+					perEntity[key] = make([]int, len(entities))
+				}
+				perEntity[key][i]++
+			}
+			for _, assertion := range store.FindAssertionsBySubject(entityID) {
+				relation := relationName(store, assertion.Relation())
+				count(relation, Outbound)
+
+				rs, ok := relations[relation]
+				if !ok {
+					rs = &RelationSchema{Relation: relation}
+					relations[relation] = rs
+					domains[relation] = make(map[string]int)
+					ranges[relation] = make(map[string]int)
+				}
+				rs.Assertions++
+				domains[relation][class]++
+				if objectClass, ok := classOf[assertion.Object()]; ok {
+					ranges[relation][objectClass]++
+				}
+			}
+			for _, assertion := range store.FindAssertionsByObject(entityID) {
+				count(relationName(store, assertion.Relation()), Inbound)
+			}
+		}
+
+		for _, usage := range properties {
+			cs.Properties = append(cs.Properties, *usage)
+		}
+		sort.Slice(cs.Properties, func(i, j int) bool { return cs.Properties[i].Key < cs.Properties[j].Key })
+
+		for key, counts := range perEntity {
+			usage := RelationUsage{Relation: key.relation, Direction: key.direction, Min: counts[0], Max: counts[0]}
+			for _, n := range counts {
+				if n > 0 {
+					usage.Entities++
+				}
+				usage.Total += n
+				if n < usage.Min {
+					usage.Min = n
+				}
+				if n > usage.Max {
+					usage.Max = n
+				}
+			}
+			cs.Relations = append(cs.Relations, usage)
+		}
+		sort.Slice(cs.Relations, func(i, j int) bool {
+			a, b := cs.Relations[i], cs.Relations[j]
+			if a.Relation != b.Relation {
+				return a.Relation < b.Relation
+			}
+			return a.Direction == Outbound && b.Direction == Inbound
+		})
+
+		schema.Classes = append(schema.Classes, cs)
+	}
+	sort.Slice(schema.Classes, func(i, j int) bool { return schema.Classes[i].Pattern < schema.Classes[j].Pattern })
+
+	for relation, rs := range relations {
+		rs.Domains = sortedCounts(domains[relation])
+		rs.Ranges = sortedCounts(ranges[relation])
+		schema.Relations = append(schema.Relations, *rs)
+	}
+	sort.Slice(schema.Relations, func(i, j int) bool { return schema.Relations[i].Relation < schema.Relations[j].Relation })
+
+	return schema
+}
+
+// Shapes proposes a shape per class. Properties and outbound relations present
+// on at least requiredFraction of a class's entities become required; a
+// relation never seen more than once per entity is capped at one, and a
+// relation whose objects all fall in one class is given that target pattern.
+func (s *Schema) Shapes(requiredFraction float64) *ShapeSet {
+	ranges := make(map[string][]PatternCount)
+	for _, rs := range s.Relations {
+		ranges[rs.Relation] = rs.Ranges
+	}
+
+	set := &ShapeSet{}
+	for _, cs := range s.Classes {
+		shape := Shape{Name: cs.Pattern, Pattern: cs.Pattern}
+		required := func(count int) bool {
+			return float64(count) >= requiredFraction*float64(cs.Entities)
+		}
+
+		for _, usage := range cs.Properties {
+			if !required(usage.Count) {
+				continue
+			}
+			constraint := PropertyConstraint{Key: usage.Key}
+			if usage.Numeric {
+				constraint.Pattern = numericPattern
+			}
+			shape.Properties = append(shape.Properties, constraint)
+		}
+
+		for _, usage := range cs.Relations {
+			if usage.Direction != Outbound || !required(usage.Entities) {
+				continue
+			}
+			constraint := RelationConstraint{Relation: usage.Relation, Min: 1}
+			if usage.Max == 1 {
+				constraint.Max = 1
+			}
+			if r := ranges[usage.Relation]; len(r) == 1 {
+				constraint.TargetPattern = r[0].Pattern
+			}
+			shape.Relations = append(shape.Relations, constraint)
+		}
+
+		set.Add(shape)
+	}
+	return set
+}
+
+// String returns a human-readable summary of the schema
+func (s *Schema) String() string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("CLASSES (%d):\n", len(s.Classes)))
+	for _, cs := range s.Classes {
+		sb.WriteString(fmt.Sprintf("  %s: %d entities\n", cs.Pattern, cs.Entities))
+		for _, usage := range cs.Properties {
+			kind := "text"
+			if usage.Numeric {
+				kind = "numeric"
+			}
+			sb.WriteString(fmt.Sprintf("    property %s: %d/%d (%s)\n", usage.Key, usage.Count, cs.Entities, kind))
+		}
+		for _, usage := range cs.Relations {
+			sb.WriteString(fmt.Sprintf("    %s %s: %d/%d entities, %d-%d per entity, mean %.2f\n",
+				usage.Direction, usage.Relation, usage.Entities, cs.Entities, usage.Min, usage.Max, usage.Mean(cs.Entities)))
+		}
+	}
+	if s.Unclassified > 0 {
+		sb.WriteString(fmt.Sprintf("  (%d entities without a TOSID)\n", s.Unclassified))
+	}
+
+	sb.WriteString(fmt.Sprintf("RELATIONS (%d):\n", len(s.Relations)))
+	for _, rs := range s.Relations {
+		sb.WriteString(fmt.Sprintf("  %s: %d assertions, domain %s, range %s\n",
+			rs.Relation, rs.Assertions, countsString(rs.Domains), countsString(rs.Ranges)))
+	}
+	return sb.String()
+}
+
+// classPattern returns the class prefix of a TOSID
+func classPattern(t *tosid.TOSID, segments int) string {
+	pattern := t.TaxonomyCode + t.NetmaskIndicator
+	if segments <= 0 {
+		return pattern
+	}
+	category, _, _ := strings.Cut(t.Identifier, ":")
+	parts := strings.Split(category, "-")
+	if segments < len(parts) {
+		parts = parts[:segments]
+	}
+	return pattern + "-" + strings.Join(parts, "-")
+}
+
+// relationName returns the label of a relation defined in the store, or the ID
+func relationName(store *semantic.SemanticStore, relationID string) string {
+	if relation, err := store.GetRelation(relationID); err == nil {
+		return relation.Label()
+	}
+	return relationID
+}
+
+// sortedCounts orders class counts by frequency, then pattern
+func sortedCounts(counts map[string]int) []PatternCount {
+	sorted := make([]PatternCount, 0, len(counts))
+	for pattern, count := range counts {
+		sorted = append(sorted, PatternCount{Pattern: pattern, Count: count})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Count != sorted[j].Count {
+			return sorted[i].Count > sorted[j].Count
+		}
+		return sorted[i].Pattern < sorted[j].Pattern
+	})
+	return sorted
+}
+
+// countsString formats class counts as "A(2), B(1)"
+func countsString(counts []PatternCount) string {
+	if len(counts) == 0 {
+		return "-"
+	}
+	parts := make([]string, len(counts))
+	for i, c := range counts {
+		parts[i] = fmt.Sprintf("%s(%d)", c.Pattern, c.Count)
+	}
+	return strings.Join(parts, ", ")
+}
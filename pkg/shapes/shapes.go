@@ -0,0 +1,310 @@
+// Package shapes validates entities in a semantic store against declared
+// shapes: constraints on the properties and relations that every entity
+// matching a TOSID pattern must have.
+package shapes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ha1tch/tosid-go/pkg/kmac"
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+)
+
+// Relation directions, seen from the entity being validated
+const (
+	Outbound = "outbound" // The entity is the subject of the assertion
+	Inbound  = "inbound"  // The entity is the object of the assertion
+)
+
+// PropertyConstraint requires an entity property, optionally with a value shape
+type PropertyConstraint struct {
+	Key      string `json:"key"`
+	Pattern  string `json:"pattern,omitempty"`  // Regular expression the value must match
+	Optional bool   `json:"optional,omitempty"` // Only check the pattern when the property is present
+}
+
+// RelationConstraint bounds the number of assertions an entity takes part in
+// through a relation. The relation matches an assertion's relation ID or the
+// label of the relation it refers to.
+type RelationConstraint struct {
+	Relation      string `json:"relation"`
+	Direction     string `json:"direction,omitempty"`      // Outbound or Inbound; defaults to Outbound
+	Min           int    `json:"min,omitempty"`            // Minimum number of matching assertions
+	Max           int    `json:"max,omitempty"`            // Maximum number of matching assertions; 0 means unbounded
+	TargetPattern string `json:"target_pattern,omitempty"` // TOSID pattern the other entity must match
+}
+
+// Shape declares the constraints for entities matching a TOSID pattern
+type Shape struct {
+	Name       string               `json:"name"`
+	Pattern    string               `json:"pattern"` // TOSID pattern; empty matches every entity
+	Properties []PropertyConstraint `json:"properties,omitempty"`
+	Relations  []RelationConstraint `json:"relations,omitempty"`
+}
+
+// ShapeSet is a collection of shapes, stored as a JSON shapes file
+type ShapeSet struct {
+	Shapes []Shape `json:"shapes"`
+}
+
+// Load reads a shapes file
+func Load(r io.Reader) (*ShapeSet, error) {
+	var set ShapeSet
+	if err := json.NewDecoder(r).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode shapes: %v", err)
+	}
+	if err := set.Check(); err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+// Save writes the shape set as an indented shapes file
+func (s *ShapeSet) Save(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+// Add appends a shape to the set
+func (s *ShapeSet) Add(shape Shape) {
+	s.Shapes = append(s.Shapes, shape)
+}
+
+// Check reports the first malformed shape in the set
+func (s *ShapeSet) Check() error {
+	_, err := s.compile()
+	return err
+}
+
+// compiledShape is a shape with its value patterns compiled
+type compiledShape struct {
+	shape    Shape
+	patterns []*regexp.Regexp // Parallel to shape.Properties; nil when unset
+}
+
+// compile checks every shape and compiles its value patterns
+func (s *ShapeSet) compile() ([]compiledShape, error) {
+	compiled := make([]compiledShape, 0, len(s.Shapes))
+	names := make(map[string]bool)
+	for _, shape := range s.Shapes {
+		if shape.Name == "" {
+			return nil, fmt.Errorf("shape with pattern %q has no name", shape.Pattern)
+		}
+		if names[shape.Name] {
+			return nil, fmt.Errorf("duplicate shape name: %s", shape.Name)
+		}
+		names[shape.Name] = true
+
+		c := compiledShape{shape: shape, patterns: make([]*regexp.Regexp, len(shape.Properties))}
+		for i, property := range shape.Properties {
+			if property.Key == "" {
+				return nil, fmt.Errorf("shape %s: property constraint has no key", shape.Name)
+			}
+			if property.Pattern != "" {
+				re, err := regexp.Compile(property.Pattern)
+				if err != nil {
+					return nil, fmt.Errorf("shape %s: invalid pattern for property %s: %v", shape.Name, property.Key, err)
+				}
+				c.patterns[i] = re
+			}
+		}
+		for _, relation := range shape.Relations {
+			if relation.Relation == "" {
+				return nil, fmt.Errorf("shape %s: relation constraint has no relation", shape.Name)
+			}
+			if relation.Direction != "" && relation.Direction != Outbound && relation.Direction != Inbound {
+				return nil, fmt.Errorf("shape %s: invalid direction for relation %s: %s", shape.Name, relation.Relation, relation.Direction)
+			}
+			if relation.Min < 0 || relation.Max < 0 || (relation.Max > 0 && relation.Max < relation.Min) {
+				return nil, fmt.Errorf("shape %s: invalid cardinality for relation %s", shape.Name, relation.Relation)
+			}
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+// Violation describes one constraint an entity fails
+type Violation struct {
+	EntityID   string
+	Shape      string
+	Constraint string // "property:<key>" or "relation:<relation>"
+	Message    string
+}
+
+// String returns a one-line description of the violation
+func (v Violation) String() string {
+	return fmt.Sprintf("%s [%s] %s: %s", v.EntityID, v.Shape, v.Constraint, v.Message)
+}
+
+// Report is the result of validating a store against a shape set
+type Report struct {
+	Conforms   bool
+	Checked    map[string]int // Number of entities checked per shape
+	Violations []Violation    // Sorted by entity ID, then shape
+}
+
+// String returns a human-readable conformance report
+func (r *Report) String() string {
+	var sb strings.Builder
+
+	shapeNames := make([]string, 0, len(r.Checked))
+	for name := range r.Checked {
+		shapeNames = append(shapeNames, name)
+	}
+	sort.Strings(shapeNames)
+
+	sb.WriteString("SHAPE CONFORMANCE REPORT\n")
+	for _, name := range shapeNames {
+		failing := make(map[string]bool)
+		for _, v := range r.Violations {
+			if v.Shape == name {
+				failing[v.EntityID] = true
+			}
+		}
+		sb.WriteString(fmt.Sprintf("  %s: %d checked, %d failing\n", name, r.Checked[name], len(failing)))
+	}
+
+	if r.Conforms {
+		sb.WriteString("CONFORMS\n")
+		return sb.String()
+	}
+
+	sb.WriteString(fmt.Sprintf("VIOLATIONS (%d):\n", len(r.Violations)))
+	for _, v := range r.Violations {
+		sb.WriteString("  " + v.String() + "\n")
+	}
+	return sb.String()
+}
+
+// Validate checks every entity in the store against the shapes whose pattern it
+// matches. Entities without a TOSID only match shapes with an empty pattern.
+func (s *ShapeSet) Validate(store *semantic.SemanticStore) (*Report, error) {
+	compiled, err := s.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Checked: make(map[string]int)}
+	for _, c := range compiled {
+		report.Checked[c.shape.Name] = 0
+	}
+
+	store.RangeEntities(func(entityRef *semantic.EntityReference) bool {
+		for _, c := range compiled {
+			if !matchesPattern(entityRef, c.shape.Pattern) {
+				continue
+			}
+			report.Checked[c.shape.Name]++
+			report.Violations = append(report.Violations, c.validate(store, entityRef)...)
+		}
+		return true
+	})
+
+	sort.SliceStable(report.Violations, func(i, j int) bool {
+		a, b := report.Violations[i], report.Violations[j]
+		if a.EntityID != b.EntityID {
+			return a.EntityID < b.EntityID
+		}
+		return a.Shape < b.Shape
+	})
+	report.Conforms = len(report.Violations) == 0
+	return report, nil
+}
+
+// validate checks one entity against the shape
+func (c compiledShape) validate(store *semantic.SemanticStore, entityRef *semantic.EntityReference) []Violation {
+	var violations []Violation
+	entityID := entityRef.KMACEntity.ID()
+	violate := func(constraint, format string, args ...interface{}) {
+		violations = append(violations, Violation{
+			EntityID:   entityID,
+			Shape:      c.shape.Name,
+			Constraint: constraint,
+			Message:    fmt.Sprintf(format, args...),
+		})
+	}
+
+	for i, property := range c.shape.Properties {
+		constraint := "property:" + property.Key
+		value, ok := entityRef.KMACEntity.GetProperty(property.Key)
+		if !ok {
+			if !property.Optional {
+				violate(constraint, "missing required property")
+			}
+			continue
+		}
+		if c.patterns[i] != nil && !c.patterns[i].MatchString(value) {
+			violate(constraint, "value %q does not match %s", value, property.Pattern)
+		}
+	}
+
+	for _, relation := range c.shape.Relations {
+		constraint := "relation:" + relation.Relation
+		var assertions []*kmac.Assertion
+		if relation.Direction == Inbound {
+			assertions = store.FindAssertionsByObject(entityID)
+		} else {
+			assertions = store.FindAssertionsBySubject(entityID)
+		}
+
+		count := 0
+		for _, assertion := range assertions {
+			if !matchesRelation(store, assertion.Relation(), relation.Relation) {
+				continue
+			}
+			count++
+			if relation.TargetPattern == "" {
+				continue
+			}
+			targetID := assertion.Object()
+			if relation.Direction == Inbound {
+				targetID = assertion.Subject()
+			}
+			target, err := store.GetEntity(targetID)
+			if err != nil || !matchesPattern(target, relation.TargetPattern) {
+				violate(constraint, "%s does not match target pattern %s", targetID, relation.TargetPattern)
+			}
+		}
+
+		if count < relation.Min {
+			violate(constraint, "has %d %s assertions, expected at least %d", count, directionName(relation.Direction), relation.Min)
+		}
+		if relation.Max > 0 && count > relation.Max {
+			violate(constraint, "has %d %s assertions, expected at most %d", count, directionName(relation.Direction), relation.Max)
+		}
+	}
+	return violations
+}
+
+// matchesPattern reports whether an entity's TOSID matches a pattern
+func matchesPattern(entityRef *semantic.EntityReference, pattern string) bool {
+	if pattern == "" {
+		return true
+	}
+	return entityRef.TOSIDObj != nil && entityRef.TOSIDObj.MatchesPattern(pattern)
+}
+
+// matchesRelation reports whether an assertion's relation is the named one,
+// either by ID or by the label of a relation defined in the store
+func matchesRelation(store *semantic.SemanticStore, relationID, name string) bool {
+	if relationID == name {
+		return true
+	}
+	relation, err := store.GetRelation(relationID)
+	return err == nil && relation.Label() == name
+}
+
+// directionName returns the direction used in messages
+func directionName(direction string) string {
+	if direction == "" {
+		return Outbound
+	}
+	return direction
+}
@@ -0,0 +1,305 @@
+package shapes
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+)
+
+// newTestStore builds two aircraft, one operated and one not, and an operator
+func newTestStore(t *testing.T) *semantic.SemanticStore {
+	t.Helper()
+	store := semantic.NewSemanticStore()
+
+	entities := []struct {
+		id, label, code string
+	}{
+		{"E1001", "Boeing_747", "10B3TR-AIR-JET"},
+		{"E1002", "Bell_206", "10B3TR-AIR-HEL"},
+		{"E1003", "Pan_Am", "10C1OR-GOV-USA"},
+	}
+	for _, e := range entities {
+		if err := store.AddEntity(e.id, e.label, e.code); err != nil {
+			t.Fatalf("Failed to add entity: %v", err)
+		}
+	}
+	aircraft, _ := store.GetEntity("E1001")
+	aircraft.KMACEntity.SetProperty("capacity", "416")
+	helicopter, _ := store.GetEntity("E1002")
+	helicopter.KMACEntity.SetProperty("capacity", "five")
+
+	if err := store.AddRelation("R1001", "OPERATED_BY", "ORGANIZATIONAL"); err != nil {
+		t.Fatalf("Failed to add relation: %v", err)
+	}
+	if err := store.CreateAssertion("F1001", "E1001", "R1001", "E1003"); err != nil {
+		t.Fatalf("Failed to create assertion: %v", err)
+	}
+	return store
+}
+
+func aircraftShapes() *ShapeSet {
+	return &ShapeSet{Shapes: []Shape{{
+		Name:       "Aircraft",
+		Pattern:    "10B-3TR-AIR",
+		Properties: []PropertyConstraint{{Key: "capacity", Pattern: `^\d+$`}},
+		Relations:  []RelationConstraint{{Relation: "OPERATED_BY", Min: 1, Max: 1, TargetPattern: "10C"}},
+	}}}
+}
+
+func TestValidateReportsViolations(t *testing.T) {
+	store := newTestStore(t)
+
+	report, err := aircraftShapes().Validate(store)
+	if err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if report.Conforms {
+		t.Fatal("Expected the store not to conform")
+	}
+	if report.Checked["Aircraft"] != 2 {
+		t.Errorf("Expected 2 entities checked, got %d", report.Checked["Aircraft"])
+	}
+
+	if len(report.Violations) != 2 {
+		t.Fatalf("Expected 2 violations, got %d: %v", len(report.Violations), report.Violations)
+	}
+	for _, v := range report.Violations {
+		if v.EntityID != "E1002" {
+			t.Errorf("Unexpected violation for %s: %s", v.EntityID, v)
+		}
+	}
+	if report.Violations[0].Constraint != "property:capacity" || report.Violations[1].Constraint != "relation:OPERATED_BY" {
+		t.Errorf("Unexpected constraints: %v", report.Violations)
+	}
+
+	text := report.String()
+	if !strings.Contains(text, "Aircraft: 2 checked, 1 failing") || !strings.Contains(text, "VIOLATIONS (2)") {
+		t.Errorf("Unexpected report:\n%s", text)
+	}
+}
+
+func TestValidateConforms(t *testing.T) {
+	store := newTestStore(t)
+	helicopter, _ := store.GetEntity("E1002")
+	helicopter.KMACEntity.SetProperty("capacity", "5")
+	if err := store.CreateAssertion("F1002", "E1002", "OPERATED_BY", "E1003"); err != nil {
+		t.Fatalf("Failed to create assertion: %v", err)
+	}
+
+	report, err := aircraftShapes().Validate(store)
+	if err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if !report.Conforms {
+		t.Errorf("Expected the store to conform, got:\n%s", report)
+	}
+}
+
+func TestValidateCardinalityAndTarget(t *testing.T) {
+	store := newTestStore(t)
+	store.CreateAssertion("F1002", "E1001", "R1001", "E1002")
+
+	set := &ShapeSet{Shapes: []Shape{
+		aircraftShapes().Shapes[0],
+		{Name: "Operator", Pattern: "10C", Relations: []RelationConstraint{{Relation: "OPERATED_BY", Direction: Inbound, Min: 2}}},
+	}}
+	report, err := set.Validate(store)
+	if err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+
+	var messages []string
+	for _, v := range report.Violations {
+		if v.EntityID != "E1002" {
+			messages = append(messages, v.String())
+		}
+	}
+	want := []string{
+		"E1001 [Aircraft] relation:OPERATED_BY: E1002 does not match target pattern 10C",
+		"E1001 [Aircraft] relation:OPERATED_BY: has 2 outbound assertions, expected at most 1",
+		"E1003 [Operator] relation:OPERATED_BY: has 1 inbound assertions, expected at least 2",
+	}
+	if strings.Join(messages, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected violations:\n%s", strings.Join(messages, "\n"))
+	}
+}
+
+func TestShapeSetRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := aircraftShapes().Save(&buf); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(loaded.Shapes) != 1 || loaded.Shapes[0].Relations[0].TargetPattern != "10C" {
+		t.Errorf("Unexpected shapes after round trip: %+v", loaded.Shapes)
+	}
+}
+
+func TestLoadRejectsMalformedShapes(t *testing.T) {
+	inputs := []string{
+		`{"shapes": [{"pattern": "10B"}]}`,
+		`{"shapes": [{"name": "A"}, {"name": "A"}]}`,
+		`{"shapes": [{"name": "A", "properties": [{"key": "x", "pattern": "("}]}]}`,
+		`{"shapes": [{"name": "A", "relations": [{"relation": "R", "direction": "sideways"}]}]}`,
+		`{"shapes": [{"name": "A", "relations": [{"relation": "R", "min": 3, "max": 1}]}]}`,
+	}
+	for _, input := range inputs {
+		if _, err := Load(strings.NewReader(input)); err == nil {
+			t.Errorf("Expected error loading %s", input)
+		}
+	}
+}
+
+func TestInferSchema(t *testing.T) {
+	store := newTestStore(t)
+	store.AddEntity("E1004", "Unnamed", "")
+
+	schema := Infer(store, 1)
+	if len(schema.Classes) != 2 || schema.Unclassified != 1 {
+		t.Fatalf("Unexpected classes:\n%s", schema)
+	}
+
+	aircraft := schema.Classes[0]
+	if aircraft.Pattern != "10B-3TR" || aircraft.Entities != 2 {
+		t.Errorf("Unexpected aircraft class: %+v", aircraft)
+	}
+	if len(aircraft.Properties) != 1 || aircraft.Properties[0].Count != 2 || aircraft.Properties[0].Numeric {
+		t.Errorf("Unexpected aircraft properties: %+v", aircraft.Properties)
+	}
+	if len(aircraft.Relations) != 1 {
+		t.Fatalf("Unexpected aircraft relations: %+v", aircraft.Relations)
+	}
+	usage := aircraft.Relations[0]
+	if usage.Relation != "OPERATED_BY" || usage.Direction != Outbound || usage.Entities != 1 || usage.Min != 0 || usage.Max != 1 {
+		t.Errorf("Unexpected relation usage: %+v", usage)
+	}
+	if usage.Mean(aircraft.Entities) != 0.5 {
+		t.Errorf("Expected mean 0.5, got %v", usage.Mean(aircraft.Entities))
+	}
+
+	if len(schema.Relations) != 1 {
+		t.Fatalf("Unexpected relations:\n%s", schema)
+	}
+	relation := schema.Relations[0]
+	if relation.Domains[0].Pattern != "10B-3TR" || relation.Ranges[0].Pattern != "10C-1OR" {
+		t.Errorf("Unexpected domain and range: %+v", relation)
+	}
+}
+
+func TestInferredShapesValidate(t *testing.T) {
+	store := newTestStore(t)
+	helicopter, _ := store.GetEntity("E1002")
+	helicopter.KMACEntity.SetProperty("capacity", "5")
+	store.CreateAssertion("F1002", "E1002", "R1001", "E1003")
+
+	set := Infer(store, 1).Shapes(1.0)
+	var buf bytes.Buffer
+	if err := set.Save(&buf); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	aircraft := loaded.Shapes[0]
+	if aircraft.Properties[0].Pattern != numericPattern {
+		t.Errorf("Expected numeric capacity, got %+v", aircraft.Properties)
+	}
+	if want := (RelationConstraint{Relation: "OPERATED_BY", Min: 1, Max: 1, TargetPattern: "10C-1OR"}); aircraft.Relations[0] != want {
+		t.Errorf("Expected %+v, got %+v", want, aircraft.Relations[0])
+	}
+
+	report, err := loaded.Validate(store)
+	if err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if !report.Conforms {
+		t.Errorf("Expected the source store to conform to its inferred shapes:\n%s", report)
+	}
+
+	helicopter.KMACEntity.SetProperty("capacity", "five")
+	if report, _ := loaded.Validate(store); report.Conforms {
+		t.Error("Expected a non-numeric capacity to violate the inferred shapes")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	set := &ShapeSet{Shapes: []Shape{
+		{
+			Name:       "Aircraft",
+			Pattern:    "10B-3TR-AIR",
+			Properties: []PropertyConstraint{{Key: "capacity", Pattern: numericPattern}, {Key: "serial_number"}},
+			Relations:  []RelationConstraint{{Relation: "OPERATED_BY", Min: 1, Max: 1, TargetPattern: "10C"}},
+		},
+		{
+			Name:      "operator",
+			Pattern:   "10C",
+			Relations: []RelationConstraint{{Relation: "OPERATED_BY", Direction: Inbound, TargetPattern: "10B-3TR-AIR"}, {Relation: "R1002"}},
+		},
+	}}
+
+	var buf bytes.Buffer
+	if err := set.Generate(&buf, "fleet"); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	file, err := parser.ParseFile(token.NewFileSet(), "fleet.go", buf.Bytes(), 0)
+	if err != nil {
+		t.Fatalf("Generated code does not parse: %v\n%s", err, buf.String())
+	}
+	if file.Name.Name != "fleet" {
+		t.Errorf("Expected package fleet, got %s", file.Name.Name)
+	}
+	for _, want := range []string{
+		"func GetAircraft(store *semantic.SemanticStore, id string) (*Aircraft, error)",
+		"func ListOperator(store *semantic.SemanticStore) []*Operator",
+		"func (e *Aircraft) Capacity() (float64, error)",
+		"func (e *Aircraft) SetCapacity(value float64)",
+		"func (e *Aircraft) SerialNumber() string",
+		"func (e *Aircraft) OperatedBy() *Operator",
+		"func (e *Operator) OperatedByInbound() []*Aircraft",
+		"func (e *Operator) R1002() []string",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected generated code to contain %q", want)
+		}
+	}
+
+	for _, bad := range []struct {
+		set     *ShapeSet
+		pkgName string
+	}{
+		{set, "not a package"},
+		{&ShapeSet{}, "fleet"},
+		{&ShapeSet{Shapes: []Shape{{Name: "A", Properties: []PropertyConstraint{{Key: "label"}}}}}, "fleet"},
+		{&ShapeSet{Shapes: []Shape{{Name: "A", Properties: []PropertyConstraint{{Key: "crew"}}, Relations: []RelationConstraint{{Relation: "CREW"}}}}}, "fleet"},
+		{&ShapeSet{Shapes: []Shape{{Name: "air-craft"}, {Name: "AIR_CRAFT"}}}, "fleet"},
+	} {
+		if err := bad.set.Generate(&bytes.Buffer{}, bad.pkgName); err == nil {
+			t.Errorf("Expected error generating %+v into package %q", bad.set.Shapes, bad.pkgName)
+		}
+	}
+}
+
+func TestGoName(t *testing.T) {
+	for name, want := range map[string]string{
+		"capacity":      "Capacity",
+		"serial_number": "SerialNumber",
+		"OPERATED_BY":   "OperatedBy",
+		"hasPart":       "HasPart",
+		"10B-3TR":       "X10b3tr",
+		"R1002":         "R1002",
+	} {
+		if got := goName(name); got != want {
+			t.Errorf("goName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+)
+
+// recordTable is the table a kind of semantic record is kept in, with the
+// record fields that have columns of their own
+type recordTable struct {
+	name    string
+	fields  []string // Record fields kept in columns
+	columns []string // Their columns, in the same order
+}
+
+// recordTables maps the kinds of semantic record onto the tables
+var recordTables = map[string]recordTable{
+	semantic.RecordEntity:    {"entities", []string{"label", "tosid"}, []string{"label", "tosid"}},
+	semantic.RecordRelation:  {"relations", []string{"label", "type"}, []string{"label", "type"}},
+	semantic.RecordAssertion: {"assertions", []string{"subject", "relation", "object"}, []string{"subject_id", "relation_id", "object_id"}},
+	semantic.RecordTombstone: {"tombstones", []string{"statement", "reason", "removed_at", "cause"}, []string{"statement_type", "reason", "removed_at", "cause"}},
+}
+
+// column returns the column a record field is kept in, if it has one
+func (t recordTable) column(field string) (string, bool) {
+	for i, name := range t.fields {
+		if name == field {
+			return t.columns[i], true
+		}
+	}
+	return "", false
+}
+
+// engine is a semantic storage engine over a store's tables
+type engine struct {
+	store *Store
+}
+
+var _ semantic.BatchEngine = (*engine)(nil)
+
+// Engine returns a storage engine over the store's tables, so a semantic
+// store can sit on the database and run its full query and validation layer
+// over it:
+//
+//	kb := semantic.NewSemanticStore()
+//	err := kb.AttachEngine(store.Engine())
+//
+// Entities, relations, assertions, and tombstones go in their tables and
+// entity properties in the properties table, so SQL tools can query them;
+// other fields, such as an assertion's confidence or a statement's tags, go
+// in the record_fields table. Each write is its own transaction; with a
+// batch policy set on the semantic store, each batch is one transaction
+// instead.
+func (s *Store) Engine() semantic.StorageEngine {
+	return &engine{store: s}
+}
+
+// tableFor returns the table of a kind of record
+func tableFor(kind string) (recordTable, error) {
+	table, known := recordTables[kind]
+	if !known {
+		return recordTable{}, fmt.Errorf("unknown record kind %s", kind)
+	}
+	return table, nil
+}
+
+// Put stores a record in its table, its properties, and its other fields
+func (e *engine) Put(ctx context.Context, record semantic.Record) error {
+	return e.WriteBatch(ctx, []semantic.Record{record}, nil)
+}
+
+// WriteBatch stores and deletes records in one transaction
+func (e *engine) WriteBatch(ctx context.Context, puts []semantic.Record, deletes []semantic.Record) error {
+	tx, err := e.store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, record := range puts {
+		if err := e.put(ctx, tx, record); err != nil {
+			return err
+		}
+	}
+	for _, record := range deletes {
+		if err := e.delete(ctx, tx, record.Kind, record.ID); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// put stores a record within a transaction
+func (e *engine) put(ctx context.Context, tx *sql.Tx, record semantic.Record) error {
+	table, err := tableFor(record.Kind)
+	if err != nil {
+		return err
+	}
+	args := []interface{}{record.ID}
+	updates := make([]string, 0, len(table.columns))
+	for i, column := range table.columns {
+		args = append(args, record.Fields[table.fields[i]])
+		updates = append(updates, column+" = excluded."+column)
+	}
+	upsert := fmt.Sprintf("INSERT INTO %s (id, %s) VALUES (?%s)\nON CONFLICT (id) DO UPDATE SET %s",
+		table.name, strings.Join(table.columns, ", "), strings.Repeat(", ?", len(table.columns)), strings.Join(updates, ", "))
+	if _, err := tx.ExecContext(ctx, e.store.dialect.bind(upsert), args...); err != nil {
+		return fmt.Errorf("failed to store %s %s: %v", record.Kind, record.ID, err)
+	}
+	if err := e.deleteFields(ctx, tx, record.Kind, record.ID); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(record.Fields))
+	for key := range record.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, inColumn := table.column(key); inColumn {
+			continue
+		}
+		query := `INSERT INTO record_fields (kind, id, field, value) VALUES (?, ?, ?, ?)`
+		args := []interface{}{record.Kind, record.ID, key, record.Fields[key]}
+		if property, ok := strings.CutPrefix(key, semantic.PropertyFieldPrefix); ok && record.Kind == semantic.RecordEntity {
+			query = `INSERT INTO properties (entity_id, key, value) VALUES (?, ?, ?)`
+			args = []interface{}{record.ID, property, record.Fields[key]}
+		}
+		if _, err := tx.ExecContext(ctx, e.store.dialect.bind(query), args...); err != nil {
+			return fmt.Errorf("failed to store %s of %s %s: %v", key, record.Kind, record.ID, err)
+		}
+	}
+	// Remembered before the commit: if it fails, the ID only costs a query
+	if record.Kind == semantic.RecordEntity || record.Kind == semantic.RecordAssertion {
+		e.store.remember(record.ID)
+	}
+	return nil
+}
+
+// Get returns a record from its table, with its properties and other fields
+func (e *engine) Get(ctx context.Context, kind string, id string) (semantic.Record, bool, error) {
+	if (kind == semantic.RecordEntity || kind == semantic.RecordAssertion) && !e.store.mayExist(id) {
+		return semantic.Record{}, false, nil
+	}
+	var found semantic.Record
+	exists := false
+	err := e.scan(ctx, kind, "id", id, func(record semantic.Record) bool {
+		found, exists = record, true
+		return false
+	})
+	return found, exists, err
+}
+
+// Scan calls fn with each record of a kind matching the hint, using the
+// column indexes for hints on fields with columns
+func (e *engine) Scan(ctx context.Context, kind string, hint *semantic.IndexHint, fn func(semantic.Record) bool) error {
+	if hint == nil {
+		return e.scan(ctx, kind, "", "", fn)
+	}
+	table, err := tableFor(kind)
+	if err != nil {
+		return err
+	}
+	if column, inColumn := table.column(hint.Field); inColumn {
+		return e.scan(ctx, kind, column, hint.Value, fn)
+	}
+	return e.scan(ctx, kind, "", "", func(record semantic.Record) bool {
+		if record.Fields[hint.Field] != hint.Value {
+			return true
+		}
+		return fn(record)
+	})
+}
+
+// scan reads the records of a kind in ID order, those whose column has a
+// value if a column is given, and calls fn with each until it returns false
+func (e *engine) scan(ctx context.Context, kind string, column string, value string, fn func(semantic.Record) bool) error {
+	table, err := tableFor(kind)
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf("SELECT id, %s FROM %s", strings.Join(table.columns, ", "), table.name)
+	var args []interface{}
+	if column != "" {
+		query += " WHERE " + column + " = ?"
+		args = append(args, value)
+	}
+	rows, err := e.store.query(ctx, query+" ORDER BY id", args...)
+	if err != nil {
+		return fmt.Errorf("failed to read %s records: %v", kind, err)
+	}
+	var records []semantic.Record
+	for rows.Next() {
+		values := make([]string, len(table.columns)+1)
+		targets := make([]interface{}, len(values))
+		for i := range values {
+			targets[i] = &values[i]
+		}
+		if err := rows.Scan(targets...); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read %s records: %v", kind, err)
+		}
+		record := semantic.Record{Kind: kind, ID: values[0], Fields: make(map[string]string, len(table.fields))}
+		for i, field := range table.fields {
+			record.Fields[field] = values[i+1]
+		}
+		records = append(records, record)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read %s records: %v", kind, err)
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	// The other fields are read once the rows are closed, as SQLite
+	// connections run one query at a time
+	if err := e.readFields(ctx, table, records, column, value); err != nil {
+		return err
+	}
+	for _, record := range records {
+		if !fn(record) {
+			break
+		}
+	}
+	return nil
+}
+
+// readFields adds their properties and other fields to records of a kind,
+// those whose column has a value if a column is given, with one query for
+// each table the fields are kept in
+func (e *engine) readFields(ctx context.Context, table recordTable, records []semantic.Record, column string, value string) error {
+	byID := make(map[string]semantic.Record, len(records))
+	for _, record := range records {
+		byID[record.ID] = record
+	}
+	kind := records[0].Kind
+	read := func(query string, prefix string, args ...interface{}) error {
+		if column != "" {
+			query += " WHERE t." + column + " = ?"
+			args = append(args, value)
+		}
+		rows, err := e.store.query(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to read fields of %s records: %v", kind, err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id, key, value string
+			if err := rows.Scan(&id, &key, &value); err != nil {
+				return fmt.Errorf("failed to read fields of %s records: %v", kind, err)
+			}
+			if record, read := byID[id]; read {
+				record.Fields[prefix+key] = value
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to read fields of %s records: %v", kind, err)
+		}
+		return nil
+	}
+
+	if kind == semantic.RecordEntity {
+		if err := read(`SELECT p.entity_id, p.key, p.value FROM properties p JOIN entities t ON t.id = p.entity_id`, semantic.PropertyFieldPrefix); err != nil {
+			return err
+		}
+	}
+	return read(`SELECT f.id, f.field, f.value FROM record_fields f JOIN `+table.name+` t ON t.id = f.id AND f.kind = ?`, "", kind)
+}
+
+// Delete removes a record from its table, with its properties and other
+// fields
+func (e *engine) Delete(ctx context.Context, kind string, id string) error {
+	return e.WriteBatch(ctx, nil, []semantic.Record{{Kind: kind, ID: id}})
+}
+
+// delete removes a record within a transaction
+func (e *engine) delete(ctx context.Context, tx *sql.Tx, kind string, id string) error {
+	table, err := tableFor(kind)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, e.store.dialect.bind(`DELETE FROM `+table.name+` WHERE id = ?`), id); err != nil {
+		return fmt.Errorf("failed to delete %s %s: %v", kind, id, err)
+	}
+	return e.deleteFields(ctx, tx, kind, id)
+}
+
+// deleteFields removes a record's properties and other fields
+func (e *engine) deleteFields(ctx context.Context, tx *sql.Tx, kind string, id string) error {
+	if kind == semantic.RecordEntity {
+		if _, err := tx.ExecContext(ctx, e.store.dialect.bind(`DELETE FROM properties WHERE entity_id = ?`), id); err != nil {
+			return fmt.Errorf("failed to delete properties of %s: %v", id, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, e.store.dialect.bind(`DELETE FROM record_fields WHERE kind = ? AND id = ?`), kind, id); err != nil {
+		return fmt.Errorf("failed to delete fields of %s %s: %v", kind, id, err)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package sqlstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// bloomFilter is a set of IDs that may report an ID it was never given, at
+// about its false positive rate, but never misses one it was
+type bloomFilter struct {
+	mu     sync.RWMutex
+	bits   []uint64
+	hashes int
+}
+
+// newBloomFilter sizes a filter for a number of IDs and a false positive rate
+func newBloomFilter(expected int, falsePositiveRate float64) *bloomFilter {
+	if expected < 1 {
+		expected = 1
+	}
+	bits := math.Ceil(-float64(expected) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	hashes := int(math.Round(bits / float64(expected) * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+	return &bloomFilter{bits: make([]uint64, (int(bits)+63)/64), hashes: hashes}
+}
+
+// positions returns the two hashes an ID's bit positions are derived from
+func positions(id string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	sum := h.Sum64()
+	// The second hash only needs to be odd and independent enough of the first
+	return sum, (sum*0x9e3779b97f4a7c15)>>1 | 1
+}
+
+// add puts an ID in the filter
+func (f *bloomFilter) add(id string) {
+	h1, h2 := positions(id)
+	size := uint64(len(f.bits) * 64)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := 0; i < f.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % size
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// mayContain reports whether an ID may be in the filter; false means it is not
+func (f *bloomFilter) mayContain(id string) bool {
+	h1, h2 := positions(id)
+	size := uint64(len(f.bits) * 64)
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for i := 0; i < f.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % size
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// idFilter is a store's Bloom filter over its entity and assertion IDs, with
+// counts of the lookups it answered
+type idFilter struct {
+	ids     *bloomFilter
+	lookups atomic.Int64
+	skipped atomic.Int64
+}
+
+// IDFilterStats reports how many lookups the ID filter answered without the
+// database
+type IDFilterStats struct {
+	Bits    int
+	Hashes  int
+	Lookups int64 // Entity and assertion lookups checked against the filter
+	Skipped int64 // Of those, the ones answered as missing without a query
+}
+
+// EnableIDFilter keeps a Bloom filter over the IDs of the store's entities
+// and assertions, so the engine's lookups of missing IDs, such as those a
+// semantic store makes for each new statement of a bulk import, are
+// answered without a database query. The filter is sized for expected IDs,
+// or the number already stored if that is more, at the given false positive
+// rate: the share of missing IDs that still cost a query.
+//
+// The filter only learns the IDs this store's engine writes, so enable it
+// only where no other store writes entities or assertions to the same
+// database, and
+// before the store is shared between goroutines. IDs are never taken out of
+// the filter, which only makes it query more.
+func (s *Store) EnableIDFilter(expected int, falsePositiveRate float64) error {
+	return s.EnableIDFilterContext(context.Background(), expected, falsePositiveRate)
+}
+
+// EnableIDFilterContext enables the ID filter as EnableIDFilter does,
+// stopping early if ctx is done
+func (s *Store) EnableIDFilterContext(ctx context.Context, expected int, falsePositiveRate float64) error {
+	if s.filter != nil {
+		return errors.New("ID filter already enabled")
+	}
+	if expected < 0 {
+		return fmt.Errorf("invalid expected number of IDs %d", expected)
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		return fmt.Errorf("false positive rate %v is outside (0, 1)", falsePositiveRate)
+	}
+
+	var stored int
+	if err := s.db.QueryRowContext(ctx, `SELECT (SELECT COUNT(*) FROM entities) + (SELECT COUNT(*) FROM assertions)`).Scan(&stored); err != nil {
+		return fmt.Errorf("failed to count IDs: %v", err)
+	}
+	ids := newBloomFilter(max(expected, stored), falsePositiveRate)
+	rows, err := s.query(ctx, `SELECT id FROM entities UNION ALL SELECT id FROM assertions`)
+	if err != nil {
+		return fmt.Errorf("failed to read IDs: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return fmt.Errorf("failed to read IDs: %v", err)
+		}
+		ids.add(id)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read IDs: %v", err)
+	}
+	s.filter = &idFilter{ids: ids}
+	return nil
+}
+
+// IDFilterStats reports the ID filter's size and use, zero if it is not
+// enabled
+func (s *Store) IDFilterStats() IDFilterStats {
+	if s.filter == nil {
+		return IDFilterStats{}
+	}
+	return IDFilterStats{
+		Bits:    len(s.filter.ids.bits) * 64,
+		Hashes:  s.filter.ids.hashes,
+		Lookups: s.filter.lookups.Load(),
+		Skipped: s.filter.skipped.Load(),
+	}
+}
+
+// mayExist reports whether an entity or assertion ID may be stored; false
+// means it is not, and the database need not be asked
+func (s *Store) mayExist(id string) bool {
+	if s.filter == nil {
+		return true
+	}
+	s.filter.lookups.Add(1)
+	if s.filter.ids.mayContain(id) {
+		return true
+	}
+	s.filter.skipped.Add(1)
+	return false
+}
+
+// remember adds a written entity or assertion ID to the filter
+func (s *Store) remember(id string) {
+	if s.filter != nil {
+		s.filter.ids.add(id)
+	}
+}
@@ -0,0 +1,177 @@
+// Package sqlstore keeps a knowledge base in a SQL database, so it persists
+// across runs and can be queried with plain SQL tools as well as through a
+// semantic store sitting on the database.
+//
+// The package holds no database driver. Callers open the database with the
+// driver of their choice and pass it to Open, for SQLite, or OpenContext,
+// then attach a semantic store to the store's engine:
+//
+//	db, err := sql.Open("sqlite3", "knowledge.db")
+//	store, err := sqlstore.Open(db)
+//	kb := semantic.NewSemanticStore()
+//	err = kb.AttachEngine(store.Engine())
+//
+//	db, err := sql.Open("pgx", "postgres://kb.example.com/knowledge")
+//	store, err := sqlstore.OpenContext(ctx, db, sqlstore.Postgres)
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Dialect names the SQL database a store is kept in
+type Dialect string
+
+const (
+	// SQLite keeps the store in a SQLite database, version 3.24 or later
+	SQLite Dialect = "sqlite"
+	// Postgres keeps the store in a PostgreSQL database, version 9.5 or
+	// later, which several application instances can share
+	Postgres Dialect = "postgres"
+)
+
+// tables creates the tables and column indexes of a knowledge base
+var tables = []string{
+	`CREATE TABLE IF NOT EXISTS entities (
+	id TEXT PRIMARY KEY,
+	label TEXT NOT NULL,
+	tosid TEXT NOT NULL DEFAULT ''
+)`,
+	`CREATE TABLE IF NOT EXISTS relations (
+	id TEXT PRIMARY KEY,
+	label TEXT NOT NULL,
+	type TEXT NOT NULL
+)`,
+	`CREATE TABLE IF NOT EXISTS assertions (
+	id TEXT PRIMARY KEY,
+	subject_id TEXT NOT NULL,
+	relation_id TEXT NOT NULL,
+	object_id TEXT NOT NULL
+)`,
+	`CREATE INDEX IF NOT EXISTS assertions_subject ON assertions (subject_id)`,
+	`CREATE INDEX IF NOT EXISTS assertions_relation ON assertions (relation_id)`,
+	`CREATE INDEX IF NOT EXISTS assertions_object ON assertions (object_id)`,
+	`CREATE INDEX IF NOT EXISTS entities_tosid ON entities (tosid)`,
+	`CREATE TABLE IF NOT EXISTS properties (
+	entity_id TEXT NOT NULL,
+	key TEXT NOT NULL,
+	value TEXT NOT NULL,
+	PRIMARY KEY (entity_id, key)
+)`,
+	`CREATE TABLE IF NOT EXISTS tombstones (
+	id TEXT PRIMARY KEY,
+	statement_type TEXT NOT NULL,
+	reason TEXT NOT NULL,
+	removed_at TEXT NOT NULL,
+	cause TEXT NOT NULL DEFAULT ''
+)`,
+	`CREATE TABLE IF NOT EXISTS record_fields (
+	kind TEXT NOT NULL,
+	id TEXT NOT NULL,
+	field TEXT NOT NULL,
+	value TEXT NOT NULL,
+	PRIMARY KEY (kind, id, field)
+)`,
+}
+
+// views defines the views of a knowledge base by name
+var views = []struct {
+	name  string
+	query string
+}{
+	// assertion_labels spells out each assertion with the labels of its
+	// parts; subjects and objects that are assertions have no label
+	{"assertion_labels", `SELECT a.id, a.subject_id, s.label AS subject_label,
+	a.relation_id, r.label AS relation_label,
+	a.object_id, o.label AS object_label
+FROM assertions a
+LEFT JOIN entities s ON s.id = a.subject_id
+LEFT JOIN relations r ON r.id = a.relation_id
+LEFT JOIN entities o ON o.id = a.object_id`},
+	// entity_properties lists each entity's properties alongside the entity
+	{"entity_properties", `SELECT e.id AS entity_id, e.label, e.tosid, p.key, p.value
+FROM entities e
+JOIN properties p ON p.entity_id = e.id`},
+}
+
+// schema returns the statements that create a knowledge base in a database
+func (d Dialect) schema() []string {
+	statements := append([]string(nil), tables...)
+	for _, view := range views {
+		if d == Postgres {
+			statements = append(statements, fmt.Sprintf("CREATE OR REPLACE VIEW %s AS\n%s", view.name, view.query))
+		} else {
+			statements = append(statements, fmt.Sprintf("CREATE VIEW IF NOT EXISTS %s AS\n%s", view.name, view.query))
+		}
+	}
+	return statements
+}
+
+// bind rewrites a query's ? placeholders in the dialect's style
+func (d Dialect) bind(query string) string {
+	if d != Postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// Store is a knowledge base kept in a SQL database, which a semantic store
+// reads and writes through Engine. It is safe for concurrent use to the
+// extent the database handle is. Its engine's writes are upserts and
+// deletes, so several engines, in one process or many, may write to the
+// same database at once, unless one of them has an ID filter.
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+	filter  *idFilter // nil unless EnableIDFilter was called
+}
+
+// Open creates the knowledge base schema in a SQLite database, if it is not
+// there already, and returns a store over it
+func Open(db *sql.DB) (*Store, error) {
+	return OpenContext(context.Background(), db, SQLite)
+}
+
+// OpenContext creates the knowledge base schema in a database of the given
+// dialect, stopping early if ctx is done
+func OpenContext(ctx context.Context, db *sql.DB, dialect Dialect) (*Store, error) {
+	switch dialect {
+	case SQLite, Postgres:
+	default:
+		return nil, fmt.Errorf("unknown SQL dialect %s", dialect)
+	}
+	for _, statement := range dialect.schema() {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return nil, fmt.Errorf("failed to create schema: %v", err)
+		}
+	}
+	return &Store{db: db, dialect: dialect}, nil
+}
+
+// DB returns the database the store is kept in
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+// Dialect returns the kind of database the store is kept in
+func (s *Store) Dialect() Dialect {
+	return s.dialect
+}
+
+// query runs a query written with ? placeholders
+func (s *Store) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return s.db.QueryContext(ctx, s.dialect.bind(query), args...)
+}
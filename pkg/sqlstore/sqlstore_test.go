@@ -0,0 +1,433 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestDialectSchema(t *testing.T) {
+	for _, dialect := range []Dialect{SQLite, Postgres} {
+		all := strings.Join(dialect.schema(), "\n")
+		for _, name := range []string{"entities", "relations", "assertions", "tombstones", "properties", "record_fields", "assertion_labels", "entity_properties"} {
+			if !strings.Contains(all, " "+name+" ") {
+				t.Errorf("Expected the %s schema to create %s", dialect, name)
+			}
+		}
+		if !strings.Contains(all, "entities_tosid ON entities (tosid)") {
+			t.Errorf("Expected the %s schema to index TOSIDs", dialect)
+		}
+	}
+}
+
+func TestDialectBind(t *testing.T) {
+	query := `SELECT id FROM assertions WHERE subject_id = ? OR object_id = ?`
+	if got := SQLite.bind(query); got != query {
+		t.Errorf("Expected SQLite to keep ? placeholders, got %q", got)
+	}
+	expected := `SELECT id FROM assertions WHERE subject_id = $1 OR object_id = $2`
+	if got := Postgres.bind(query); got != expected {
+		t.Errorf("Expected numbered placeholders, got %q", got)
+	}
+}
+
+func TestRecordTables(t *testing.T) {
+	for kind, table := range recordTables {
+		if len(table.fields) != len(table.columns) {
+			t.Errorf("Expected a column for each %s field, got %v and %v", kind, table.fields, table.columns)
+		}
+	}
+	if column, ok := recordTables["assertion"].column("subject"); !ok || column != "subject_id" {
+		t.Errorf("Expected assertion subjects in subject_id, got %q", column)
+	}
+	if _, ok := recordTables["assertion"].column("confidence"); ok {
+		t.Error("Expected confidence to have no column")
+	}
+	if column, ok := recordTables["tombstone"].column("statement"); !ok || column != "statement_type" {
+		t.Errorf("Expected tombstone statement types in statement_type, got %q", column)
+	}
+	if _, err := tableFor("situation"); err == nil {
+		t.Error("Expected an unknown record kind to be rejected")
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	filter := newBloomFilter(10000, 0.01)
+	for i := 0; i < 10000; i++ {
+		filter.add(fmt.Sprintf("E%d", i))
+	}
+	for i := 0; i < 10000; i++ {
+		if !filter.mayContain(fmt.Sprintf("E%d", i)) {
+			t.Fatalf("Expected E%d in the filter", i)
+		}
+	}
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if filter.mayContain(fmt.Sprintf("F%d", i)) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 200 {
+		t.Errorf("Expected about 1%% false positives, got %d in 10000", falsePositives)
+	}
+
+	// With no database behind it, any query would panic
+	ctx := context.Background()
+	store := &Store{filter: &idFilter{ids: newBloomFilter(100, 0.01)}}
+	if _, ok, err := store.Engine().Get(ctx, semantic.RecordEntity, "E1001"); ok || err != nil {
+		t.Errorf("Expected a missing entity to be reported, got %v", err)
+	}
+	if _, ok, err := store.Engine().Get(ctx, semantic.RecordAssertion, "F1001"); ok || err != nil {
+		t.Errorf("Expected a missing assertion to be reported, got %v", err)
+	}
+	if stats := store.IDFilterStats(); stats.Lookups != 2 || stats.Skipped != 2 || stats.Hashes != 7 {
+		t.Errorf("Expected both lookups answered by the filter, got %+v", stats)
+	}
+	if err := store.EnableIDFilter(100, 0.01); err == nil {
+		t.Error("Expected a second filter to be rejected")
+	}
+}
+
+// exerciseEngine runs a semantic store over the store's tables
+func exerciseEngine(t *testing.T, store *Store) {
+	t.Helper()
+	ctx := context.Background()
+	kb := semantic.NewSemanticStore()
+	if err := kb.AttachEngine(store.Engine()); err != nil {
+		t.Fatalf("AttachEngine failed: %v", err)
+	}
+	kb.LoadKMAC(strings.NewReader("DEF_ENTITY #E2001 [Shelter] type=[]\nPROPERTY #E2001 [capacity] value=[120]\n" +
+		"DEF_ENTITY #E2003 [Tent] type=[10B3TR-DEP-WHS]\nPROPERTY #E2003 [pegs] value=[8]\n"))
+	kb.AddEntity("E2002", "Clinic", "")
+	if err := kb.AddEntity("E2002", "Field clinic", "10B3MD-FAC-HSP"); err != nil {
+		t.Fatalf("Expected an entity to be replaced, got %v", err)
+	}
+	kb.AddRelation("R2001", "supplies", "LOGISTICS_CAPABILITY")
+	kb.CreateAssertion("F2001", "E2001", "R2001", "E2002")
+	kb.CreateAssertion("F2002", "E2003", "R2001", "E2002")
+	kb.SetAssertionConfidence("F2001", 0.75, "radio")
+	if err := kb.SetAssertionConfidence("F2001", 0.5, "radio"); err != nil {
+		t.Fatalf("Expected an assertion's fields to be rewritten, got %v", err)
+	}
+	kb.Tag("F2001", "verified")
+	if err := kb.RemoveEntity("E2003", "struck"); err != nil {
+		t.Fatalf("RemoveEntity failed: %v", err)
+	}
+
+	engine := store.Engine()
+	if record, ok, err := engine.Get(ctx, semantic.RecordEntity, "E2002"); err != nil || !ok || record.Fields["label"] != "Field clinic" {
+		t.Errorf("Expected the replaced entity in its table, got %v %v", record, err)
+	}
+	if record, ok, err := engine.Get(ctx, semantic.RecordAssertion, "F2001"); err != nil || !ok || record.Fields["confidence"] != "0.5" || record.Fields["subject"] != "E2001" {
+		t.Errorf("Expected the assertion with its confidence, got %v %v", record, err)
+	}
+	var about []string
+	engine.Scan(ctx, semantic.RecordAssertion, &semantic.IndexHint{Field: "object", Value: "E2002"}, func(record semantic.Record) bool {
+		about = append(about, record.ID)
+		return true
+	})
+	if !slices.Equal(about, []string{"F2001"}) {
+		t.Errorf("Expected the live assertion found through its object column, got %v", about)
+	}
+	if _, ok, _ := engine.Get(ctx, semantic.RecordEntity, "E2003"); ok {
+		t.Error("Expected the removed entity deleted")
+	}
+	var properties int
+	var reason string
+	store.db.QueryRowContext(ctx, store.dialect.bind(`SELECT COUNT(*) FROM properties WHERE entity_id = ?`), "E2003").Scan(&properties)
+	store.db.QueryRowContext(ctx, store.dialect.bind(`SELECT reason FROM tombstones WHERE id = ?`), "E2003").Scan(&reason)
+	if properties != 0 || reason != "struck" {
+		t.Errorf("Expected the entity's properties deleted and its tombstone kept, got %d properties and %q", properties, reason)
+	}
+
+	reloaded := semantic.NewSemanticStore()
+	if err := reloaded.AttachEngine(store.Engine()); err != nil {
+		t.Fatalf("Expected the store loaded from its tables, got %v", err)
+	}
+	if found := reloaded.FindEntitiesByTOSIDPattern("10B*FAC"); len(found) != 1 || found[0].KMACEntity.ID() != "E2002" {
+		t.Errorf("Expected the entity found by TOSID in the tables, got %v", found)
+	}
+	assertions := reloaded.FindAssertionsBySubject("E2001")
+	if len(assertions) != 1 || !assertions[0].HasTag("verified") {
+		t.Fatalf("Expected the assertion read with its tag, got %v", assertions)
+	}
+	if level, _ := assertions[0].GetConfidence(); level != 0.5 {
+		t.Errorf("Expected the assertion's confidence read, got %v", level)
+	}
+	if entityRef, err := reloaded.GetEntity("E2001"); err != nil {
+		t.Errorf("Expected the entity read, got %v", err)
+	} else if capacity, _ := entityRef.KMACEntity.GetProperty("capacity"); capacity != "120" {
+		t.Errorf("Expected the property read from the properties table, got %q", capacity)
+	}
+	if _, removed := reloaded.GetTombstone("E2003"); !removed {
+		t.Error("Expected the tombstone read")
+	}
+}
+
+// openSQLite opens a SQLite database in a file of its own
+func openSQLite(tb testing.TB, name string) *sql.DB {
+	tb.Helper()
+	db, err := sql.Open("sqlite3", sqliteDSN(tb, name))
+	if err != nil {
+		tb.Fatalf("failed to open SQLite: %v", err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	if err := db.Ping(); err != nil {
+		tb.Skipf("SQLite is unavailable: %v", err)
+	}
+	return db
+}
+
+// sqliteDSN names a SQLite database file in a temporary directory. Writes
+// are not synced, as the database is thrown away.
+func sqliteDSN(tb testing.TB, name string) string {
+	return "file:" + filepath.Join(tb.TempDir(), name+".db") + "?_journal_mode=WAL&_synchronous=OFF"
+}
+
+func TestStoreSQLite(t *testing.T) {
+	db := openSQLite(t, "knowledge")
+	store, err := Open(db)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if _, err := Open(db); err != nil {
+		t.Errorf("Expected the schema to be created again harmlessly, got %v", err)
+	}
+	exerciseEngine(t, store)
+
+	// SQL tools see the statements through the views
+	var subject, relation, object string
+	err = db.QueryRow(`SELECT subject_label, relation_label, object_label FROM assertion_labels WHERE id = 'F2001'`).Scan(&subject, &relation, &object)
+	if err != nil || subject != "Shelter" || relation != "supplies" || object != "Field clinic" {
+		t.Errorf("Expected the assertion spelled out with labels, got %q %q %q %v", subject, relation, object, err)
+	}
+	var properties int
+	db.QueryRow(`SELECT COUNT(*) FROM entity_properties WHERE entity_id = 'E2001' AND key = 'capacity' AND value = '120'`).Scan(&properties)
+	if properties != 1 {
+		t.Errorf("Expected the entity's property in entity_properties, got %d rows", properties)
+	}
+	var plan string
+	rows, err := db.Query(`EXPLAIN QUERY PLAN SELECT id FROM entities WHERE tosid = '10B3MD-FAC-HSP'`)
+	if err != nil {
+		t.Fatalf("EXPLAIN failed: %v", err)
+	}
+	for rows.Next() {
+		var id, parent, unused int
+		var detail string
+		rows.Scan(&id, &parent, &unused, &detail)
+		plan += detail
+	}
+	rows.Close()
+	if !strings.Contains(plan, "entities_tosid") {
+		t.Errorf("Expected TOSID lookups to use the TOSID index, got %q", plan)
+	}
+}
+
+// TestStorePostgresServer runs the store against a real PostgreSQL server
+// through pgx. It runs when TOSID_PG_DSN names a scratch database, whose
+// knowledge base tables it drops.
+func TestStorePostgresServer(t *testing.T) {
+	dsn := os.Getenv("TOSID_PG_DSN")
+	if dsn == "" {
+		t.Skip("TOSID_PG_DSN is not set")
+	}
+	open := func() *sql.DB {
+		db, err := sql.Open("pgx", dsn)
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", dsn, err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+
+	ctx := context.Background()
+	db := open()
+	for _, statement := range []string{
+		`DROP VIEW IF EXISTS assertion_labels, entity_properties`,
+		`DROP TABLE IF EXISTS entities, relations, assertions, tombstones, properties, record_fields`,
+	} {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			t.Fatalf("failed to drop the knowledge base: %v", err)
+		}
+	}
+	store, err := OpenContext(ctx, db, Postgres)
+	if err != nil {
+		t.Fatalf("OpenContext failed: %v", err)
+	}
+	if _, err := OpenContext(ctx, db, Postgres); err != nil {
+		t.Errorf("Expected the schema to be created again harmlessly, got %v", err)
+	}
+	var definition string
+	if err := db.QueryRowContext(ctx, `SELECT indexdef FROM pg_indexes WHERE indexname = 'entities_tosid'`).Scan(&definition); err != nil {
+		t.Fatalf("failed to read the TOSID index: %v", err)
+	}
+	if !strings.Contains(definition, "btree (tosid)") {
+		t.Errorf("Expected a B-tree index on TOSIDs, got %s", definition)
+	}
+	exerciseEngine(t, store)
+
+	// Engines on separate connections upsert the same rows at once
+	other, err := OpenContext(ctx, open(), Postgres)
+	if err != nil {
+		t.Fatalf("OpenContext failed: %v", err)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for writer := 0; writer < 8; writer++ {
+		writerStore := store
+		if writer%2 == 1 {
+			writerStore = other
+		}
+		wg.Add(1)
+		go func(writer int) {
+			defer wg.Done()
+			engine := writerStore.Engine().(semantic.BatchEngine)
+			for i := 0; i < 50; i++ {
+				id := fmt.Sprintf("E3%03d", i)
+				entity := semantic.Record{Kind: semantic.RecordEntity, ID: id, Fields: map[string]string{
+					"label": fmt.Sprintf("Depot %d", writer),
+					"tosid": "10B3TR-DEP-WHS",
+				}}
+				assertion := semantic.Record{Kind: semantic.RecordAssertion, ID: fmt.Sprintf("F3%03d", i), Fields: map[string]string{
+					"subject":  id,
+					"relation": "R2001",
+					"object":   "E2001",
+				}}
+				if err := engine.WriteBatch(ctx, []semantic.Record{entity, assertion}, nil); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(writer)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Expected concurrent upserts to succeed, got %v", err)
+	}
+	var entities, assertions int
+	db.QueryRowContext(ctx, `SELECT COUNT(*) FROM entities WHERE id LIKE 'E3%'`).Scan(&entities)
+	db.QueryRowContext(ctx, `SELECT COUNT(*) FROM assertions WHERE id LIKE 'F3%'`).Scan(&assertions)
+	if entities != 50 || assertions != 50 {
+		t.Errorf("Expected each row stored once, got %d entities and %d assertions", entities, assertions)
+	}
+}
+
+func BenchmarkBloomFilter(b *testing.B) {
+	filter := newBloomFilter(1000000, 0.01)
+	ids := make([]string, 1000)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("E%d", i)
+		filter.add(ids[i])
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		filter.mayContain(ids[i%len(ids)])
+	}
+}
+
+// countingConnector opens connections to a database that count the
+// queries looking up an entity or assertion by ID
+type countingConnector struct {
+	driver  driver.Driver
+	dsn     string
+	lookups atomic.Int64
+}
+
+func (c *countingConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &countingConn{Conn: conn, lookups: &c.lookups}, nil
+}
+
+func (c *countingConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// countingConn is a connection that counts lookups by ID as it prepares them
+type countingConn struct {
+	driver.Conn
+	lookups *atomic.Int64
+}
+
+func (c *countingConn) Prepare(query string) (driver.Stmt, error) {
+	if strings.HasPrefix(query, "SELECT ") && strings.Contains(query, "WHERE id = ?") {
+		c.lookups.Add(1)
+	}
+	return c.Conn.Prepare(query)
+}
+
+// BenchmarkImport imports entities and assertions through a semantic store
+// on a SQLite store that already holds statements, looking each up first as
+// importers that skip statements already stored do, with and without the ID
+// filter. Lookups of new IDs, which the semantic store makes in the
+// database, are the ones the filter saves.
+func BenchmarkImport(b *testing.B) {
+	const size = 1000
+	sqlite := openSQLite(b, "driver").Driver()
+	for _, filtered := range []bool{false, true} {
+		b.Run(fmt.Sprintf("filter=%v", filtered), func(b *testing.B) {
+			lookups := int64(0)
+			for n := 0; n < b.N; n++ {
+				b.StopTimer()
+				connector := &countingConnector{driver: sqlite, dsn: sqliteDSN(b, fmt.Sprintf("import%d", n))}
+				db := sql.OpenDB(connector)
+				store, err := Open(db)
+				if err != nil {
+					b.Fatalf("Open failed: %v", err)
+				}
+				if filtered {
+					store.EnableIDFilter(2*size, 0.01)
+				}
+				ctx := context.Background()
+				store.Engine().Put(ctx, semantic.Record{Kind: semantic.RecordEntity, ID: "S1", Fields: map[string]string{"label": "Stored"}})
+				kb := semantic.NewSemanticStore()
+				if err := kb.AttachEngine(store.Engine()); err != nil {
+					b.Fatalf("AttachEngine failed: %v", err)
+				}
+				kb.AddRelation("R1001", "supplies", "LOGISTICS_CAPABILITY")
+				before := connector.lookups.Load()
+				b.StartTimer()
+
+				for i := 0; i < size; i++ {
+					id := fmt.Sprintf("E%d", i)
+					if _, err := kb.GetEntity(id); err == nil {
+						continue
+					}
+					if err := kb.AddEntity(id, "Depot", ""); err != nil {
+						b.Fatalf("AddEntity failed: %v", err)
+					}
+				}
+				for i := 1; i < size; i++ {
+					id := fmt.Sprintf("F%d", i)
+					if _, err := kb.GetAssertion(id); err == nil {
+						continue
+					}
+					if err := kb.CreateAssertion(id, fmt.Sprintf("E%d", i-1), "R1001", fmt.Sprintf("E%d", i)); err != nil {
+						b.Fatalf("CreateAssertion failed: %v", err)
+					}
+				}
+
+				b.StopTimer()
+				lookups += connector.lookups.Load() - before
+				db.Close()
+				b.StartTimer()
+			}
+			b.ReportMetric(float64(lookups)/float64(b.N), "lookups/op")
+		})
+	}
+}
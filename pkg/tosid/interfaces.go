@@ -1,43 +1,8 @@
 package tosid
 
-// TOSIDParser is an interface for parsing TOSID codes
-type TOSIDParser interface {
-	// Parse creates a TOSID from a string representation
-	Parse(code string) (*TOSID, error)
-	
-	// ParseBatch parses multiple TOSID codes
-	ParseBatch(codes []string) ([]*TOSID, []error)
-	
-	// ValidateFormat checks if a string matches TOSID format
-	ValidateFormat(code string) bool
-}
-
-// TOSIDCreator is an interface for creating TOSID codes
-type TOSIDCreator interface {
-	// Create creates a new TOSID with the specified components
-	Create(taxonomyCode, netmaskIndicator, identifier string) (*TOSID, error)
-	
-	// CreateFromTemplate creates a TOSID from a template
-	CreateFromTemplate(template string, values map[string]string) (*TOSID, error)
-	
-	// GenerateNext generates the next TOSID in a sequence
-	GenerateNext(base *TOSID) (*TOSID, error)
-}
-
-// TOSIDClassifier is an interface for working with TOSID classifications
-type TOSIDClassifier interface {
-	// ClassificationDescription returns a human-readable description
-	ClassificationDescription() string
-	
-	// IsCompatibleWith checks if this TOSID is compatible with another
-	IsCompatibleWith(other *TOSID) bool
-	
-	// MatchesPattern checks if a TOSID matches a pattern
-	MatchesPattern(pattern string) bool
-	
-	// GetHierarchy returns the hierarchical levels
-	GetHierarchy() []string
-}
+import (
+	"context"
+)
 
 // TOSIDValidator is an interface for validating TOSID codes
 type TOSIDValidator interface {
@@ -97,4 +62,17 @@ type ComparisonResult struct {
 	SharedLevels  int
 	Differences   []string
 	Relationship  string // "parent", "child", "sibling", "unrelated"
-}
\ No newline at end of file
+}
+
+// TOSIDSuggester is an interface for proposing TOSID codes from free-text descriptions
+type TOSIDSuggester interface {
+	// Suggest proposes candidate codes for a description, most confident first
+	Suggest(ctx context.Context, description string) ([]Suggestion, error)
+}
+
+// Suggestion is a candidate TOSID code proposed by a TOSIDSuggester
+type Suggestion struct {
+	Code       string
+	Confidence float64 // 0.0 to 1.0
+	Rationale  string
+}
@@ -0,0 +1,230 @@
+// Package suggest proposes TOSID codes for free-text entity descriptions by
+// asking a text completion service.
+//
+// The completion is expected to list one candidate per line as
+//
+//	CODE | CONFIDENCE | RATIONALE
+//
+// Candidates that do not parse as TOSID codes are discarded, so a suggestion
+// is always a valid code even when the model is not.
+package suggest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ha1tch/tosid-go/pkg/tosid"
+)
+
+// Doer sends HTTP requests; *http.Client satisfies it, and tests or callers
+// with custom transports can supply their own
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// HTTPSuggester is a TOSIDSuggester backed by an HTTP completion endpoint
+type HTTPSuggester struct {
+	Endpoint       string      // URL receiving completion requests
+	Model          string      // Model name sent with each request, if any
+	Header         http.Header // Extra request headers, e.g. authorization
+	Client         Doer        // Defaults to http.DefaultClient
+	MaxSuggestions int         // Defaults to 5
+
+	// BuildRequest encodes a prompt as a request body. The default sends
+	// {"model", "prompt", "max_tokens", "temperature"} as JSON.
+	BuildRequest func(model string, prompt string) ([]byte, error)
+	// ParseResponse extracts the completion text from a response body. The
+	// default accepts {"completion": ...}, {"text": ...}, and
+	// {"choices": [{"text": ...}]} shapes.
+	ParseResponse func(body []byte) (string, error)
+}
+
+var _ tosid.TOSIDSuggester = (*HTTPSuggester)(nil)
+
+// NewHTTPSuggester creates a suggester for a completion endpoint
+func NewHTTPSuggester(endpoint string) *HTTPSuggester {
+	return &HTTPSuggester{Endpoint: endpoint}
+}
+
+// Suggest asks the endpoint for candidate codes and returns the valid ones,
+// most confident first
+func (s *HTTPSuggester) Suggest(ctx context.Context, description string) ([]tosid.Suggestion, error) {
+	description = strings.TrimSpace(description)
+	if description == "" {
+		return nil, errors.New("description cannot be empty")
+	}
+
+	buildRequest := s.BuildRequest
+	if buildRequest == nil {
+		buildRequest = defaultBuildRequest
+	}
+	parseResponse := s.ParseResponse
+	if parseResponse == nil {
+		parseResponse = defaultParseResponse
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	body, err := buildRequest(s.Model, Prompt(description))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build completion request: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build completion request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, values := range s.Header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("completion request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read completion response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("completion endpoint returned %s", resp.Status)
+	}
+
+	completion, err := parseResponse(respBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse completion response: %v", err)
+	}
+
+	maxSuggestions := s.MaxSuggestions
+	if maxSuggestions <= 0 {
+		maxSuggestions = 5
+	}
+	suggestions := ParseSuggestions(completion)
+	if len(suggestions) == 0 {
+		return nil, errors.New("completion contained no valid TOSID codes")
+	}
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+	}
+	return suggestions, nil
+}
+
+// Prompt builds the completion prompt for a description, listing the TOSID
+// taxonomy so the model can pick a classification
+func Prompt(description string) string {
+	var sb strings.Builder
+	sb.WriteString("Classify the entity below with TOSID codes.\n\n")
+	sb.WriteString("A TOSID code is TTN[D]XX-XXX-XXX with an optional :XXX-XXX-XXX-XXX suffix, where TT is the taxonomy code,\n")
+	sb.WriteString("N the scope letter, D an optional digit, and X uppercase letters (digits are also allowed in the suffix).\n")
+	sb.WriteString("Example: 10B3TR-AIR-HEL:CAP-12P-S33-000\n\n")
+	sb.WriteString("Taxonomy codes and scopes:\n")
+
+	taxonomyCodes := make([]string, 0, len(tosid.NetmaskDescriptions))
+	for code := range tosid.NetmaskDescriptions {
+		taxonomyCodes = append(taxonomyCodes, code)
+	}
+	sort.Strings(taxonomyCodes)
+	for _, code := range taxonomyCodes {
+		sb.WriteString(fmt.Sprintf("  %s (%s, %s):", code, tosid.TaxonomyDomains[code[:1]], tosid.TaxonomyTypes[code[1:]]))
+		scopes := tosid.NetmaskDescriptions[code]
+		netmasks := make([]string, 0, len(scopes))
+		for netmask := range scopes {
+			netmasks = append(netmasks, netmask)
+		}
+		sort.Strings(netmasks)
+		for _, netmask := range netmasks {
+			sb.WriteString(fmt.Sprintf(" %s=%s", netmask, scopes[netmask]))
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("\nReply with up to five candidates, one per line, as CODE | CONFIDENCE | RATIONALE,\n")
+	sb.WriteString("where CONFIDENCE is between 0 and 1. Reply with nothing else.\n\n")
+	sb.WriteString("Entity: " + description + "\n")
+	return sb.String()
+}
+
+// ParseSuggestions extracts valid candidates from a completion, most
+// confident first. Lines that are not candidates, codes that do not parse,
+// and repeated codes are skipped.
+func ParseSuggestions(completion string) []tosid.Suggestion {
+	var suggestions []tosid.Suggestion
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(completion, "\n") {
+		fields := strings.SplitN(line, "|", 3)
+		if len(fields) < 2 {
+			continue
+		}
+		code := strings.Trim(strings.TrimSpace(fields[0]), "`*-• ")
+		if _, err := tosid.Parse(code); err != nil || seen[code] {
+			continue
+		}
+		confidence, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+		if err != nil {
+			continue
+		}
+		if confidence < 0.0 {
+			confidence = 0.0
+		} else if confidence > 1.0 {
+			confidence = 1.0
+		}
+
+		suggestion := tosid.Suggestion{Code: code, Confidence: confidence}
+		if len(fields) == 3 {
+			suggestion.Rationale = strings.TrimSpace(fields[2])
+		}
+		seen[code] = true
+		suggestions = append(suggestions, suggestion)
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Confidence > suggestions[j].Confidence
+	})
+	return suggestions
+}
+
+// defaultBuildRequest encodes a prompt as a generic completion request
+func defaultBuildRequest(model string, prompt string) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"model":       model,
+		"prompt":      prompt,
+		"max_tokens":  512,
+		"temperature": 0,
+	})
+}
+
+// defaultParseResponse extracts completion text from common response shapes
+func defaultParseResponse(body []byte) (string, error) {
+	var response struct {
+		Completion string `json:"completion"`
+		Text       string `json:"text"`
+		Choices    []struct {
+			Text string `json:"text"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", err
+	}
+	switch {
+	case response.Completion != "":
+		return response.Completion, nil
+	case response.Text != "":
+		return response.Text, nil
+	case len(response.Choices) > 0:
+		return response.Choices[0].Text, nil
+	}
+	return "", errors.New("response has no completion text")
+}
@@ -0,0 +1,101 @@
+package suggest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPSuggester(t *testing.T) {
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		json.NewEncoder(w).Encode(map[string]string{"completion": strings.Join([]string{
+			"Here are some candidates:",
+			"10D3WA-TER-PUR | 0.6 | Handheld device",
+			"10B3WA-TER-PUR:POR-TAB-LE1-000 | 0.85 | Portable purification unit",
+			"not-a-code | 0.9 | Invalid",
+			"10D3WA-TER-PUR | 0.2 | Duplicate",
+			"10E2FI-LTR-MEM | 1.7 | Membrane component",
+		}, "\n")})
+	}))
+	defer server.Close()
+
+	suggester := NewHTTPSuggester(server.URL)
+	suggester.Model = "test-model"
+	suggester.Header = http.Header{"Authorization": {"Bearer secret"}}
+	suggester.MaxSuggestions = 2
+
+	suggestions, err := suggester.Suggest(context.Background(), "portable water purifier")
+	if err != nil {
+		t.Fatalf("Suggest failed: %v", err)
+	}
+	if len(suggestions) != 2 {
+		t.Fatalf("Expected 2 suggestions, got %v", suggestions)
+	}
+	if suggestions[0].Code != "10E2FI-LTR-MEM" || suggestions[0].Confidence != 1.0 {
+		t.Errorf("Expected clamped membrane suggestion first, got %+v", suggestions[0])
+	}
+	if suggestions[1].Code != "10B3WA-TER-PUR:POR-TAB-LE1-000" || suggestions[1].Rationale != "Portable purification unit" {
+		t.Errorf("Unexpected second suggestion: %+v", suggestions[1])
+	}
+
+	if received["model"] != "test-model" || !strings.Contains(received["prompt"].(string), "Entity: portable water purifier") {
+		t.Errorf("Unexpected request: %v", received)
+	}
+}
+
+func TestHTTPSuggesterErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/down":
+			http.Error(w, "down", http.StatusServiceUnavailable)
+		case "/empty":
+			w.Write([]byte(`{"choices": [{"text": "I cannot classify this."}]}`))
+		default:
+			w.Write([]byte(`not json`))
+		}
+	}))
+	defer server.Close()
+
+	for _, path := range []string{"/down", "/empty", "/garbage"} {
+		if _, err := NewHTTPSuggester(server.URL+path).Suggest(context.Background(), "water"); err == nil {
+			t.Errorf("Expected error from %s", path)
+		}
+	}
+	if _, err := NewHTTPSuggester(server.URL).Suggest(context.Background(), "  "); err == nil {
+		t.Error("Expected error for empty description")
+	}
+}
+
+func TestHTTPSuggesterCustomClient(t *testing.T) {
+	suggester := NewHTTPSuggester("http://example.invalid")
+	suggester.Client = doerFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     "200 OK",
+			Body:       io.NopCloser(strings.NewReader("10B3TR-AIR-HEL | 0.7 | Helicopter")),
+		}, nil
+	})
+	suggester.ParseResponse = func(body []byte) (string, error) { return string(body), nil }
+
+	suggestions, err := suggester.Suggest(context.Background(), "rescue helicopter")
+	if err != nil {
+		t.Fatalf("Suggest failed: %v", err)
+	}
+	if len(suggestions) != 1 || suggestions[0].Code != "10B3TR-AIR-HEL" {
+		t.Errorf("Unexpected suggestions: %v", suggestions)
+	}
+}
+
+type doerFunc func(*http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(r *http.Request) (*http.Response, error) { return f(r) }
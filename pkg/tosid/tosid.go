@@ -6,6 +6,10 @@ import (
 
 // Re-export types from internal package
 type TOSID = internal_tosid.TOSID
+type Segment = internal_tosid.Segment
+type Collection = internal_tosid.TOSIDCollection
+type TreeNode = internal_tosid.TreeNode
+type Interner = internal_tosid.Interner
 
 // Re-export maps and constants
 var (
@@ -20,6 +24,24 @@ func Parse(code string) (*TOSID, error) {
 	return parser.Parse(code)
 }
 
+// Intern returns a shared, parsed TOSID for a code. Repeated calls with the same
+// code return the same instance, so the result must not be modified. The
+// process-wide interner behind it never forgets a code; use an Interner of
+// your own, releasing codes once done with them, for unbounded streams.
+func Intern(code string) (*TOSID, error) {
+	return internal_tosid.DefaultInterner().Intern(code)
+}
+
+// NewInterner creates an interner of TOSIDs separate from the process-wide one
+func NewInterner() *Interner {
+	return internal_tosid.NewInterner()
+}
+
+// NewCollection creates an empty collection of TOSIDs
+func NewCollection() *Collection {
+	return internal_tosid.NewTOSIDCollection()
+}
+
 // Create creates a new TOSID with the specified components
 func Create(taxonomyCode, netmaskIndicator, identifier string) (*TOSID, error) {
 	validator := internal_tosid.NewValidator()
@@ -40,6 +62,21 @@ func ValidateFormat(code string) error {
 	return validator.ValidateFormat(code)
 }
 
+// Validate checks a TOSID code against the format, component, and semantic
+// consistency rules, returning the problems found: none for a valid code
+func Validate(code string) []string {
+	validator := internal_tosid.NewValidator()
+	if err := validator.ValidateFormat(code); err != nil {
+		return []string{err.Error()}
+	}
+	t, err := Parse(code)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	_, problems := validator.IsWellFormed(t)
+	return problems
+}
+
 // GetClassification returns the classification description for a TOSID
 func GetClassification(taxonomyCode, netmaskIndicator string) string {
 	classifier := internal_tosid.NewTaxonomyClassifier()
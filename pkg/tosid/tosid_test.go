@@ -1,6 +1,7 @@
 package tosid
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -48,6 +49,105 @@ func TestCreate(t *testing.T) {
 	}
 }
 
+func TestIntern(t *testing.T) {
+	code := "00B2SO-LAR-SUN:000-000-000-001"
+
+	first, err := Intern(code)
+	if err != nil {
+		t.Fatalf("Failed to intern %s: %v", code, err)
+	}
+
+	second, err := Intern(code)
+	if err != nil {
+		t.Fatalf("Failed to intern %s: %v", code, err)
+	}
+
+	if first != second {
+		t.Errorf("Expected interned TOSIDs for %s to share one instance", code)
+	}
+
+	if _, err := Intern("invalid-tosid"); err == nil {
+		t.Error("Expected interning an invalid code to fail")
+	}
+
+	// An interner of one's own forgets a code once every hold is released
+	interner := NewInterner()
+	interner.Intern(code)
+	interner.Intern(code)
+	interner.Release(code)
+	if interner.Count() != 1 {
+		t.Errorf("Expected %s to stay interned while held, got %d codes", code, interner.Count())
+	}
+	interner.Release(code)
+	if interner.Count() != 0 {
+		t.Errorf("Expected %s to be forgotten once released, got %d codes", code, interner.Count())
+	}
+}
+
+func TestMatchedSegments(t *testing.T) {
+	code, err := Parse("00B2SO-LAR-SUN:000-000-000-001")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	segments := code.Segments()
+	if len(segments) != 9 || segments[2] != (Segment{Name: "category 1", Value: "2SO"}) || segments[8].Name != "identifier 4" {
+		t.Errorf("Unexpected segments %v", segments)
+	}
+
+	testCases := []struct {
+		pattern string
+		want    string
+	}{
+		{"00B", "taxonomy netmask"},
+		{"00*LAR", "taxonomy category 2"},
+		{"0*SUN:*-001", "taxonomy category 3 identifier 4"},
+		{"", ""},
+	}
+	for _, tc := range testCases {
+		matched := code.MatchedSegments(tc.pattern)
+		if matched == nil {
+			t.Errorf("Expected %q to match", tc.pattern)
+			continue
+		}
+		var names []string
+		for _, segment := range matched {
+			names = append(names, segment.Name)
+		}
+		if got := strings.Join(names, " "); got != tc.want {
+			t.Errorf("Pattern %q: expected segments %q, got %q", tc.pattern, tc.want, got)
+		}
+	}
+
+	if matched := code.MatchedSegments("11*"); matched != nil {
+		t.Errorf("Expected no segments for a pattern that does not match, got %v", matched)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add("00B2SO-LAR-SUN:000-000-000-001")
+	f.Add("11A3SC-PHY-EIN")
+	f.Add("invalid-tosid")
+	f.Fuzz(func(t *testing.T, code string) {
+		tosid, err := Parse(code)
+		formatErr := ValidateFormat(code)
+		if (err == nil) != (formatErr == nil) {
+			t.Fatalf("Parse and ValidateFormat disagree on %q: %v vs %v", code, err, formatErr)
+		}
+		if err != nil {
+			return
+		}
+
+		// Parsing must be deterministic and the derived views must not panic
+		again, err := Parse(code)
+		if err != nil || *again != *tosid {
+			t.Fatalf("Re-parsing %q gave %+v (%v), expected %+v", code, again, err, tosid)
+		}
+		tosid.GetHierarchy()
+		tosid.ClassificationDescription()
+	})
+}
+
 func BenchmarkParse(b *testing.B) {
 	tosidCode := "00B2-SOL-STR-SUN:000-000-000-001"
 	for i := 0; i < b.N; i++ {
@@ -67,11 +167,74 @@ func BenchmarkCreate(b *testing.B) {
 	}
 }
 
+func BenchmarkIntern(b *testing.B) {
+	tosidCode := "00B2SO-LAR-SUN:000-000-000-001"
+	for i := 0; i < b.N; i++ {
+		_, err := Intern(tosidCode)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func BenchmarkPatternMatch(b *testing.B) {
-	tosid, _ := Parse("00B2-SOL-STR-SUN:000-000-000-001")
+	tosid, _ := Parse("00B2SO-LAR-SUN:000-000-000-001")
 	pattern := "00B*"
 	
 	for i := 0; i < b.N; i++ {
 		tosid.MatchesPattern(pattern)
 	}
-}
\ No newline at end of file
+}
+
+func TestValidate(t *testing.T) {
+	if problems := Validate("10B3TR-AIR-HEL"); len(problems) != 0 {
+		t.Errorf("Expected no problems, got %v", problems)
+	}
+	if problems := Validate("10B"); len(problems) != 1 {
+		t.Errorf("Expected a format problem, got %v", problems)
+	}
+	if problems := Validate("00F1NA-GAL-ART"); len(problems) != 2 {
+		t.Errorf("Expected two consistency problems, got %v", problems)
+	}
+}
+
+func TestCollectionTree(t *testing.T) {
+	collection := NewCollection()
+	for _, code := range []string{"10B3TR-AIR-HEL", "10B3TR-VEH-TRK", "10B2SP-MSN-APL", "00B2SO-STR-RDW:M1V-000-000-000"} {
+		tosid, err := Parse(code)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", code, err)
+		}
+		if err := collection.Add(tosid); err != nil {
+			t.Fatalf("Failed to add %s: %v", code, err)
+		}
+	}
+
+	tree := collection.Tree(3)
+	if tree.Count != 4 || len(tree.Children) != 2 {
+		t.Fatalf("Expected 4 codes under 2 taxonomies, got %d under %d", tree.Count, len(tree.Children))
+	}
+	buildings := tree.Children[1].Children[0]
+	if buildings.Prefix != "10B" || buildings.Count != 3 || len(buildings.Children) != 2 {
+		t.Errorf("Expected 3 codes under 10B in 2 branches, got %+v", buildings)
+	}
+	if transport := buildings.Children[1]; transport.Prefix != "10B-3TR" || transport.Count != 2 || transport.Children != nil {
+		t.Errorf("Expected 2 codes under 10B-3TR at the last level, got %+v", transport)
+	}
+
+	var dot strings.Builder
+	if err := collection.ExportTreeDOT(&dot, 3); err != nil {
+		t.Fatalf("Failed to export DOT: %v", err)
+	}
+	if !strings.HasPrefix(dot.String(), "digraph tosid {") || !strings.Contains(dot.String(), `[label="10B-3TR\n2"]`) {
+		t.Errorf("Unexpected DOT output:\n%s", dot.String())
+	}
+
+	var data strings.Builder
+	if err := collection.ExportTreeJSON(&data, 0); err != nil {
+		t.Fatalf("Failed to export JSON: %v", err)
+	}
+	if !strings.Contains(data.String(), `"prefix": "00B-2SO-STR-RDW:M1V-000-000-000"`) {
+		t.Errorf("Expected the full codes at the leaves, got:\n%s", data.String())
+	}
+}
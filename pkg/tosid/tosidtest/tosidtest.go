@@ -0,0 +1,211 @@
+// Package tosidtest provides generators of TOSID codes for property-based tests.
+//
+// Valid codes are always accepted by tosid.Parse; near-valid codes differ from a
+// valid code by a single mutation and are always rejected, which makes them
+// useful for negative tests.
+package tosidtest
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/ha1tch/tosid-go/pkg/tosid"
+)
+
+const (
+	letters      = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digits       = "0123456789"
+	alphanumeric = letters + digits
+)
+
+// Mutation describes how a near-valid code was derived from a valid one
+type Mutation string
+
+// Mutations applied by NearValid
+const (
+	NonNumericTaxonomy Mutation = "non_numeric_taxonomy"
+	LowercaseNetmask   Mutation = "lowercase_netmask"
+	MissingSegment     Mutation = "missing_segment"
+	ShortSegment       Mutation = "short_segment"
+	ExtraCharacter     Mutation = "extra_character"
+	BadSeparator       Mutation = "bad_separator"
+	TruncatedSpecific  Mutation = "truncated_specific"
+)
+
+var mutations = []Mutation{
+	NonNumericTaxonomy, LowercaseNetmask, MissingSegment, ShortSegment,
+	ExtraCharacter, BadSeparator, TruncatedSpecific,
+}
+
+// Generator produces random TOSID codes from a seeded source, so failures are reproducible
+type Generator struct {
+	rng              *rand.Rand
+	taxonomyCodes    []string
+	netmasks         []string
+	specificFraction float64
+}
+
+// NewGenerator creates a generator covering every known taxonomy code and netmask
+func NewGenerator(seed int64) *Generator {
+	taxonomyCodes := make([]string, 0, len(tosid.NetmaskDescriptions))
+	for code := range tosid.NetmaskDescriptions {
+		taxonomyCodes = append(taxonomyCodes, code)
+	}
+	sort.Strings(taxonomyCodes)
+
+	return &Generator{
+		rng:              rand.New(rand.NewSource(seed)),
+		taxonomyCodes:    taxonomyCodes,
+		specificFraction: 0.5,
+	}
+}
+
+// WithTaxonomy restricts generated codes to the given taxonomy codes
+func (g *Generator) WithTaxonomy(codes ...string) *Generator {
+	g.taxonomyCodes = codes
+	return g
+}
+
+// WithNetmask restricts generated codes to the given netmask indicators.
+// Netmasks that are not valid for a chosen taxonomy code are skipped.
+func (g *Generator) WithNetmask(indicators ...string) *Generator {
+	g.netmasks = indicators
+	return g
+}
+
+// WithSpecificFraction sets the fraction of codes that carry a specific identifier suffix
+func (g *Generator) WithSpecificFraction(fraction float64) *Generator {
+	g.specificFraction = fraction
+	return g
+}
+
+// Valid returns a random code that tosid.Parse accepts
+func (g *Generator) Valid() string {
+	taxonomyCode, netmask := g.pickClassification()
+
+	var sb strings.Builder
+	sb.WriteString(taxonomyCode)
+	sb.WriteString(netmask)
+	if g.rng.Intn(2) == 0 {
+		sb.WriteString(g.pick(digits, 1))
+	}
+	sb.WriteString(g.pick(letters, 2))
+	sb.WriteString("-" + g.pick(letters, 3))
+	sb.WriteString("-" + g.pick(letters, 3))
+
+	if g.rng.Float64() < g.specificFraction {
+		for i := 0; i < 4; i++ {
+			if i == 0 {
+				sb.WriteString(":")
+			} else {
+				sb.WriteString("-")
+			}
+			sb.WriteString(g.pick(alphanumeric, 3))
+		}
+	}
+	return sb.String()
+}
+
+// ValidN returns n random valid codes
+func (g *Generator) ValidN(n int) []string {
+	codes := make([]string, n)
+	for i := range codes {
+		codes[i] = g.Valid()
+	}
+	return codes
+}
+
+// NearValid returns a code that tosid.Parse rejects, along with the mutation applied
+func (g *Generator) NearValid() (string, Mutation) {
+	for {
+		code := g.Valid()
+		mutation := mutations[g.rng.Intn(len(mutations))]
+		if mutated, ok := g.mutate(code, mutation); ok {
+			return mutated, mutation
+		}
+	}
+}
+
+// mutate applies a mutation to a valid code, reporting false if it does not apply
+func (g *Generator) mutate(code string, mutation Mutation) (string, bool) {
+	category, specific, hasSpecific := strings.Cut(code, ":")
+	lastDash := strings.LastIndex(category, "-")
+
+	switch mutation {
+	case NonNumericTaxonomy:
+		return g.pick(letters, 1) + code[1:], true
+	case LowercaseNetmask:
+		return code[:2] + strings.ToLower(code[2:3]) + code[3:], true
+	case MissingSegment:
+		return category[:lastDash] + suffix(specific, hasSpecific), true
+	case ShortSegment:
+		return category[:len(category)-1] + suffix(specific, hasSpecific), true
+	case ExtraCharacter:
+		return category + g.pick(letters, 1) + suffix(specific, hasSpecific), true
+	case BadSeparator:
+		return category[:lastDash] + "_" + category[lastDash+1:] + suffix(specific, hasSpecific), true
+	case TruncatedSpecific:
+		if !hasSpecific {
+			return "", false
+		}
+		return category + ":" + specific[:len(specific)-4], true
+	}
+	return "", false
+}
+
+// pickClassification picks a taxonomy code and a netmask valid for it. It panics
+// if the taxonomy and netmask restrictions leave no combination to choose from.
+func (g *Generator) pickClassification() (string, string) {
+	for _, i := range g.rng.Perm(len(g.taxonomyCodes)) {
+		taxonomyCode := g.taxonomyCodes[i]
+
+		scopes, known := tosid.NetmaskDescriptions[taxonomyCode]
+		if !known {
+			// Unknown taxonomy codes still produce syntactically valid codes
+			if len(g.netmasks) > 0 {
+				return taxonomyCode, g.netmasks[g.rng.Intn(len(g.netmasks))]
+			}
+			return taxonomyCode, g.pick(letters, 1)
+		}
+
+		var candidates []string
+		for netmask := range scopes {
+			if len(g.netmasks) == 0 || contains(g.netmasks, netmask) {
+				candidates = append(candidates, netmask)
+			}
+		}
+		if len(candidates) > 0 {
+			sort.Strings(candidates)
+			return taxonomyCode, candidates[g.rng.Intn(len(candidates))]
+		}
+	}
+	panic("tosidtest: no netmask is valid for the configured taxonomy codes")
+}
+
+// pick returns n random characters from a set
+func (g *Generator) pick(set string, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = set[g.rng.Intn(len(set))]
+	}
+	return string(b)
+}
+
+// suffix re-attaches a specific identifier
+func suffix(specific string, hasSpecific bool) string {
+	if !hasSpecific {
+		return ""
+	}
+	return ":" + specific
+}
+
+// contains reports whether values includes value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package tosidtest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ha1tch/tosid-go/pkg/tosid"
+)
+
+func TestValidCodesParse(t *testing.T) {
+	g := NewGenerator(1)
+	for _, code := range g.ValidN(1000) {
+		if _, err := tosid.Parse(code); err != nil {
+			t.Errorf("Parse(%q) failed: %v", code, err)
+		}
+	}
+}
+
+func TestValidCodesRespectRestrictions(t *testing.T) {
+	g := NewGenerator(2).WithTaxonomy("10").WithNetmask("C", "D").WithSpecificFraction(1)
+	for _, code := range g.ValidN(200) {
+		parsed, err := tosid.Parse(code)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", code, err)
+		}
+		if parsed.TaxonomyCode != "10" {
+			t.Errorf("Expected taxonomy 10 in %q, got %s", code, parsed.TaxonomyCode)
+		}
+		if parsed.NetmaskIndicator != "C" && parsed.NetmaskIndicator != "D" {
+			t.Errorf("Expected netmask C or D in %q, got %s", code, parsed.NetmaskIndicator)
+		}
+		if !strings.Contains(parsed.Identifier, ":") {
+			t.Errorf("Expected specific identifier in %q", code)
+		}
+	}
+}
+
+func TestNearValidCodesAreRejected(t *testing.T) {
+	g := NewGenerator(3)
+	seen := make(map[Mutation]bool)
+	for i := 0; i < 1000; i++ {
+		code, mutation := g.NearValid()
+		seen[mutation] = true
+		if _, err := tosid.Parse(code); err == nil {
+			t.Errorf("Parse(%q) accepted code mutated by %s", code, mutation)
+		}
+	}
+	for _, mutation := range mutations {
+		if !seen[mutation] {
+			t.Errorf("Mutation %s was never applied", mutation)
+		}
+	}
+}
+
+func TestGeneratorIsReproducible(t *testing.T) {
+	a := NewGenerator(42).ValidN(50)
+	b := NewGenerator(42).ValidN(50)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("Code %d differs between runs: %q vs %q", i, a[i], b[i])
+		}
+	}
+}
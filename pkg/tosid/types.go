@@ -28,15 +28,13 @@ type TOSIDClassifier interface {
 
 // TOSID public interfaces
 var (
-	Parser    TOSIDParser    = tosidParser{}
-	Creator   TOSIDCreator   = tosidCreator{}
-	Classifier TOSIDClassifier = tosidClassifier{}
+	Parser  TOSIDParser  = tosidParser{}
+	Creator TOSIDCreator = tosidCreator{}
 )
 
 // Internal implementations
 type tosidParser struct{}
 type tosidCreator struct{}
-type tosidClassifier struct{}
 
 func (p tosidParser) Parse(code string) (*TOSID, error) {
 	return Parse(code)
@@ -0,0 +1,305 @@
+// Package wikidata links store entities that have labels but no TOSID code to
+// Wikidata items. A linked entity gets the item ID and any configured external
+// identifiers as properties, and TOSID suggestions derived from the item's
+// instance-of classes.
+package wikidata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+	"github.com/ha1tch/tosid-go/pkg/tosid"
+)
+
+// DefaultEndpoint is the Wikidata action API
+const DefaultEndpoint = "https://www.wikidata.org/w/api.php"
+
+// ItemProperty is the entity property holding the linked Wikidata item ID
+const ItemProperty = "wikidata"
+
+// instanceOf is the Wikidata "instance of" property
+const instanceOf = "P31"
+
+// Doer sends HTTP requests; *http.Client satisfies it
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Linker matches entity labels against Wikidata
+type Linker struct {
+	Endpoint  string      // Defaults to DefaultEndpoint
+	Language  string      // Search language; defaults to en
+	UserAgent string      // Sent with every request, as Wikimedia asks clients to identify themselves
+	Client    Doer        // Defaults to http.DefaultClient
+	Header    http.Header // Extra request headers
+
+	// Classes maps Wikidata class IDs, such as Q5 for human, to TOSID codes
+	// suggested for instances of the class
+	Classes map[string]string
+	// ExternalIDs maps Wikidata property IDs, such as P213 for ISNI, to the
+	// entity property keys their values are stored under
+	ExternalIDs map[string]string
+}
+
+// Link is the outcome of linking one entity
+type Link struct {
+	EntityID    string
+	Item        string // Wikidata item ID, e.g. Q23548
+	Label       string // Item label in the search language
+	Description string
+	ExactMatch  bool              // The item label or alias equals the entity label
+	ExternalIDs map[string]string // Values stored on the entity, by property key
+	Classes     []string          // Instance-of class IDs of the item
+	Suggestions []tosid.Suggestion
+}
+
+// NewLinker creates a linker for the public Wikidata API
+func NewLinker(userAgent string) *Linker {
+	return &Linker{UserAgent: userAgent}
+}
+
+// LinkStore links every entity that has a label but no TOSID code, in ID
+// order. Entities with no matching item are left out of the result.
+func (l *Linker) LinkStore(ctx context.Context, store *semantic.SemanticStore) ([]Link, error) {
+	var candidates []*semantic.EntityReference
+	store.RangeEntities(func(entityRef *semantic.EntityReference) bool {
+		if entityRef.TOSIDObj == nil && strings.TrimSpace(entityRef.KMACEntity.Label()) != "" {
+			candidates = append(candidates, entityRef)
+		}
+		return true
+	})
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].KMACEntity.ID() < candidates[j].KMACEntity.ID()
+	})
+
+	var links []Link
+	for _, entityRef := range candidates {
+		link, found, err := l.LinkEntity(ctx, entityRef)
+		if err != nil {
+			return links, fmt.Errorf("entity %s: %v", entityRef.KMACEntity.ID(), err)
+		}
+		if found {
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
+// LinkEntity searches Wikidata for an entity's label and, if an item is
+// found, stores its ID and external identifiers on the entity
+func (l *Linker) LinkEntity(ctx context.Context, entityRef *semantic.EntityReference) (Link, bool, error) {
+	entity := entityRef.KMACEntity
+	link := Link{EntityID: entity.ID()}
+
+	hit, found, err := l.search(ctx, entity.Label())
+	if err != nil || !found {
+		return link, false, err
+	}
+	link.Item = hit.ID
+	link.Label = hit.Label
+	link.Description = hit.Description
+	link.ExactMatch = strings.EqualFold(hit.Match.Text, strings.TrimSpace(entity.Label()))
+
+	claims, err := l.claims(ctx, hit.ID)
+	if err != nil {
+		return link, false, err
+	}
+	link.Classes = claims[instanceOf]
+	link.ExternalIDs = make(map[string]string)
+	for property, key := range l.ExternalIDs {
+		if values := claims[property]; len(values) > 0 {
+			link.ExternalIDs[key] = values[0]
+		}
+	}
+	link.Suggestions = l.suggestions(link)
+
+	entity.SetProperty(ItemProperty, link.Item)
+	for key, value := range link.ExternalIDs {
+		entity.SetProperty(key, value)
+	}
+	return link, true, nil
+}
+
+// suggestions maps the item's classes to TOSID codes. Exact label matches
+// are trusted more than fuzzy ones.
+func (l *Linker) suggestions(link Link) []tosid.Suggestion {
+	confidence := 0.6
+	if link.ExactMatch {
+		confidence = 0.9
+	}
+	var suggestions []tosid.Suggestion
+	seen := make(map[string]bool)
+	for _, class := range link.Classes {
+		code, mapped := l.Classes[class]
+		if !mapped || seen[code] {
+			continue
+		}
+		seen[code] = true
+		suggestions = append(suggestions, tosid.Suggestion{
+			Code:       code,
+			Confidence: confidence,
+			Rationale:  fmt.Sprintf("%s (%s) is an instance of %s", link.Item, link.Label, class),
+		})
+	}
+	return suggestions
+}
+
+// searchHit is one result of wbsearchentities
+type searchHit struct {
+	ID          string `json:"id"`
+	Label       string `json:"label"`
+	Description string `json:"description"`
+	Match       struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"match"`
+}
+
+// search returns the best item for a label, preferring exact label or alias
+// matches over the search engine's ranking
+func (l *Linker) search(ctx context.Context, label string) (searchHit, bool, error) {
+	var response struct {
+		Search []searchHit `json:"search"`
+	}
+	params := url.Values{
+		"action":   {"wbsearchentities"},
+		"search":   {strings.TrimSpace(label)},
+		"language": {l.language()},
+		"type":     {"item"},
+		"limit":    {"5"},
+	}
+	if err := l.get(ctx, params, &response); err != nil {
+		return searchHit{}, false, err
+	}
+	if len(response.Search) == 0 {
+		return searchHit{}, false, nil
+	}
+	for _, hit := range response.Search {
+		if strings.EqualFold(hit.Match.Text, strings.TrimSpace(label)) {
+			return hit, true, nil
+		}
+	}
+	return response.Search[0], true, nil
+}
+
+// claims returns the string and item values of an item's statements, by property
+func (l *Linker) claims(ctx context.Context, item string) (map[string][]string, error) {
+	var response struct {
+		Entities map[string]struct {
+			Claims map[string][]struct {
+				MainSnak struct {
+					DataValue struct {
+						Type  string          `json:"type"`
+						Value json.RawMessage `json:"value"`
+					} `json:"datavalue"`
+				} `json:"mainsnak"`
+			} `json:"claims"`
+		} `json:"entities"`
+	}
+	params := url.Values{
+		"action": {"wbgetentities"},
+		"ids":    {item},
+		"props":  {"claims"},
+	}
+	if err := l.get(ctx, params, &response); err != nil {
+		return nil, err
+	}
+	itemData, exists := response.Entities[item]
+	if !exists {
+		return nil, fmt.Errorf("item %s missing from response", item)
+	}
+
+	claims := make(map[string][]string)
+	for property, statements := range itemData.Claims {
+		for _, statement := range statements {
+			dataValue := statement.MainSnak.DataValue
+			switch dataValue.Type {
+			case "string", "external-id":
+				var value string
+				if json.Unmarshal(dataValue.Value, &value) == nil {
+					claims[property] = append(claims[property], value)
+				}
+			case "wikibase-entityid":
+				var value struct {
+					ID string `json:"id"`
+				}
+				if json.Unmarshal(dataValue.Value, &value) == nil && value.ID != "" {
+					claims[property] = append(claims[property], value.ID)
+				}
+			}
+		}
+	}
+	return claims, nil
+}
+
+// get calls the action API and decodes its JSON response
+func (l *Linker) get(ctx context.Context, params url.Values, v interface{}) error {
+	endpoint := l.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	client := l.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	params.Set("format", "json")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to build Wikidata request: %v", err)
+	}
+	for key, values := range l.Header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	if l.UserAgent != "" {
+		req.Header.Set("User-Agent", l.UserAgent)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Wikidata request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read Wikidata response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Wikidata returned %s", resp.Status)
+	}
+
+	var apiError struct {
+		Error *struct {
+			Code string `json:"code"`
+			Info string `json:"info"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &apiError); err != nil {
+		return fmt.Errorf("failed to decode Wikidata response: %v", err)
+	}
+	if apiError.Error != nil {
+		return errors.New("Wikidata error " + apiError.Error.Code + ": " + apiError.Error.Info)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to decode Wikidata response: %v", err)
+	}
+	return nil
+}
+
+// language returns the search language
+func (l *Linker) language() string {
+	if l.Language == "" {
+		return "en"
+	}
+	return l.Language
+}
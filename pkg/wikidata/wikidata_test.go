@@ -0,0 +1,106 @@
+package wikidata
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ha1tch/tosid-go/pkg/semantic"
+)
+
+const searchNASA = `{"search": [
+  {"id": "Q1000", "label": "NASA TV", "match": {"type": "label", "text": "NASA TV"}},
+  {"id": "Q23548", "label": "NASA", "description": "space agency", "match": {"type": "alias", "text": "nasa"}}
+]}`
+
+const claimsNASA = `{"entities": {"Q23548": {"claims": {
+  "P31": [
+    {"mainsnak": {"datavalue": {"type": "wikibase-entityid", "value": {"id": "Q327333"}}}},
+    {"mainsnak": {"datavalue": {"type": "wikibase-entityid", "value": {"id": "Q2029841"}}}}
+  ],
+  "P213": [{"mainsnak": {"datavalue": {"type": "string", "value": "0000 0001 2169 4786"}}}]
+}}}}`
+
+func TestLinkStore(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != "tosid-test/1.0" {
+			http.Error(w, "missing user agent", http.StatusForbidden)
+			return
+		}
+		query := r.URL.Query()
+		switch {
+		case query.Get("action") == "wbsearchentities" && query.Get("search") == "NASA":
+			w.Write([]byte(searchNASA))
+		case query.Get("action") == "wbsearchentities":
+			w.Write([]byte(`{"search": []}`))
+		case query.Get("action") == "wbgetentities" && query.Get("ids") == "Q23548":
+			w.Write([]byte(claimsNASA))
+		default:
+			w.Write([]byte(`{"error": {"code": "no-such-entity", "info": "unknown"}}`))
+		}
+	}))
+	defer server.Close()
+
+	store := semantic.NewSemanticStore()
+	store.AddEntity("E1001", "NASA", "")
+	store.AddEntity("E1002", "Unknown Widget", "")
+	store.AddEntity("E1003", "Apollo 11", "10B3TR-AIR-JET")
+
+	linker := NewLinker("tosid-test/1.0")
+	linker.Endpoint = server.URL
+	linker.Classes = map[string]string{"Q327333": "10C1OR-GOV-USA", "Q2029841": "10C1OR-GOV-USA"}
+	linker.ExternalIDs = map[string]string{"P213": "isni"}
+
+	links, err := linker.LinkStore(context.Background(), store)
+	if err != nil {
+		t.Fatalf("LinkStore failed: %v", err)
+	}
+	if len(links) != 1 {
+		t.Fatalf("Expected one link, got %+v", links)
+	}
+
+	link := links[0]
+	if link.EntityID != "E1001" || link.Item != "Q23548" || !link.ExactMatch {
+		t.Errorf("Unexpected link: %+v", link)
+	}
+	if len(link.Suggestions) != 1 || link.Suggestions[0].Code != "10C1OR-GOV-USA" || link.Suggestions[0].Confidence != 0.9 {
+		t.Errorf("Unexpected suggestions: %+v", link.Suggestions)
+	}
+
+	nasa, _ := store.GetEntity("E1001")
+	if item, _ := nasa.KMACEntity.GetProperty(ItemProperty); item != "Q23548" {
+		t.Errorf("Expected item property, got %q", item)
+	}
+	if isni, _ := nasa.KMACEntity.GetProperty("isni"); isni != "0000 0001 2169 4786" {
+		t.Errorf("Expected ISNI property, got %q", isni)
+	}
+	apollo, _ := store.GetEntity("E1003")
+	if _, linked := apollo.KMACEntity.GetProperty(ItemProperty); linked {
+		t.Error("Entities with a TOSID code should not be linked")
+	}
+}
+
+func TestLinkerErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/down":
+			http.Error(w, "down", http.StatusServiceUnavailable)
+		case "/api-error":
+			w.Write([]byte(`{"error": {"code": "maxlag", "info": "try later"}}`))
+		default:
+			w.Write([]byte(`not json`))
+		}
+	}))
+	defer server.Close()
+
+	store := semantic.NewSemanticStore()
+	store.AddEntity("E1001", "NASA", "")
+	for _, path := range []string{"/down", "/api-error", "/garbage"} {
+		linker := NewLinker("tosid-test/1.0")
+		linker.Endpoint = server.URL + path
+		if _, err := linker.LinkStore(context.Background(), store); err == nil {
+			t.Errorf("Expected error from %s", path)
+		}
+	}
+}